@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/alexnthnz/notification-system/api/proto/gen"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// expectAPIKey expects an API key lookup returning a key of tenant with scopes
+func expectAPIKey(mock *dbtest.Mock, tenant string, scopes ...string) {
+	mock.ExpectQuery("FROM api_keys").
+		WillReturnRows(dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at").
+			AddRow("k1", tenant, "", "nsk_12345678", pq.Array(scopes), time.Now(), nil))
+}
+
+// withAPIKey returns an incoming call context carrying apiKey
+func withAPIKey(apiKey string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyMetadata, apiKey))
+}
+
+// withJWT returns an incoming call context carrying a bearer JWT for tenant
+// signed with secret
+func withJWT(t *testing.T, secret, tenant, scopes string) context.Context {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1", "tenant_id": tenant, "scope": scopes}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// intercept runs a GetNotification call for n1 through the API key interceptor
+func intercept(s *Server, ctx context.Context, requireAPIKey bool) (*pb.GetNotificationResponse, error) {
+	interceptor := APIKeyInterceptor(s.notificationService, requireAPIKey, zap.NewNop())
+	resp, err := interceptor(ctx, &pb.GetNotificationRequest{Id: "n1"}, &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/GetNotification"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.GetNotification(ctx, req.(*pb.GetNotificationRequest))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.GetNotificationResponse), nil
+}
+
+func TestAPIKeyInterceptor(t *testing.T) {
+	row := notification.Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: notification.StatusSent, TenantID: "tenant-a"}
+
+	t.Run("owning tenant", func(t *testing.T) {
+		s, mock := newTestServer(t)
+		expectAPIKey(mock, "tenant-a", notification.ScopeNotificationsRead)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+
+		resp, err := intercept(s, withAPIKey("nsk_a"), false)
+		if err != nil {
+			t.Fatalf("GetNotification() error = %v", err)
+		}
+		if resp.Notification.Id != "n1" {
+			t.Errorf("GetNotification() = %v, want n1", resp.Notification)
+		}
+	})
+
+	t.Run("other tenant", func(t *testing.T) {
+		s, mock := newTestServer(t)
+		expectAPIKey(mock, "tenant-b", notification.ScopeNotificationsRead)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+
+		if _, err := intercept(s, withAPIKey("nsk_b"), false); status.Code(err) != codes.NotFound {
+			t.Errorf("GetNotification() error = %v, want NotFound", err)
+		}
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		s, mock := newTestServer(t)
+		expectAPIKey(mock, "tenant-a", notification.ScopeNotificationsCreate)
+
+		if _, err := intercept(s, withAPIKey("nsk_a"), false); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("GetNotification() error = %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		s, mock := newTestServer(t)
+		mock.ExpectQuery("FROM api_keys").WillReturnRows(dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at"))
+
+		if _, err := intercept(s, withAPIKey("nsk_unknown"), false); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("GetNotification() error = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("missing key when required", func(t *testing.T) {
+		s, _ := newTestServer(t)
+		if _, err := intercept(s, context.Background(), true); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("GetNotification() error = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("JWT of the owning tenant", func(t *testing.T) {
+		s, mock := newTestServerWithConfig(t, &config.Config{Auth: config.AuthConfig{JWTSecret: "test-secret"}})
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+
+		resp, err := intercept(s, withJWT(t, "test-secret", "tenant-a", notification.ScopeNotificationsRead), true)
+		if err != nil {
+			t.Fatalf("GetNotification() error = %v", err)
+		}
+		if resp.Notification.Id != "n1" {
+			t.Errorf("GetNotification() = %v, want n1", resp.Notification)
+		}
+	})
+
+	t.Run("JWT of another tenant", func(t *testing.T) {
+		s, mock := newTestServerWithConfig(t, &config.Config{Auth: config.AuthConfig{JWTSecret: "test-secret"}})
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+
+		if _, err := intercept(s, withJWT(t, "test-secret", "tenant-b", notification.ScopeNotificationsRead), true); status.Code(err) != codes.NotFound {
+			t.Errorf("GetNotification() error = %v, want NotFound", err)
+		}
+	})
+
+	t.Run("JWT with the wrong signature", func(t *testing.T) {
+		s, _ := newTestServerWithConfig(t, &config.Config{Auth: config.AuthConfig{JWTSecret: "test-secret"}})
+
+		if _, err := intercept(s, withJWT(t, "other-secret", "tenant-a", notification.ScopeNotificationsRead), true); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("GetNotification() error = %v, want Unauthenticated", err)
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package grpc
 
 import (
+	"encoding/base64"
+	"fmt"
+
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	pb "github.com/alexnthnz/notification-system/api/proto/gen"
@@ -21,6 +24,36 @@ func channelFromProto(channel pb.Channel) string {
 	}
 }
 
+// priorityFromProto converts proto Priority to the internal priority, 0 when
+// unspecified so the default applies. The proto enum counts up to high while
+// internal priorities count down from it.
+func priorityFromProto(priority pb.Priority) int {
+	switch priority {
+	case pb.Priority_PRIORITY_HIGH:
+		return notification.PriorityHigh
+	case pb.Priority_PRIORITY_MEDIUM:
+		return notification.PriorityMedium
+	case pb.Priority_PRIORITY_LOW:
+		return notification.PriorityLow
+	default:
+		return 0
+	}
+}
+
+// priorityToProto converts the internal priority to proto Priority
+func priorityToProto(priority int) pb.Priority {
+	switch priority {
+	case notification.PriorityHigh:
+		return pb.Priority_PRIORITY_HIGH
+	case notification.PriorityMedium:
+		return pb.Priority_PRIORITY_MEDIUM
+	case notification.PriorityLow:
+		return pb.Priority_PRIORITY_LOW
+	default:
+		return pb.Priority_PRIORITY_UNSPECIFIED
+	}
+}
+
 // channelToProto converts string channel to proto Channel
 func channelToProto(channel string) pb.Channel {
 	switch channel {
@@ -48,6 +81,8 @@ func statusFromProto(status pb.NotificationStatus) notification.NotificationStat
 		return notification.StatusFailed
 	case pb.NotificationStatus_NOTIFICATION_STATUS_CANCELLED:
 		return notification.StatusCancelled
+	case pb.NotificationStatus_NOTIFICATION_STATUS_UNKNOWN:
+		return notification.StatusUnknown
 	default:
 		return notification.StatusPending
 	}
@@ -66,6 +101,8 @@ func statusToProto(status notification.NotificationStatus) pb.NotificationStatus
 		return pb.NotificationStatus_NOTIFICATION_STATUS_FAILED
 	case notification.StatusCancelled:
 		return pb.NotificationStatus_NOTIFICATION_STATUS_CANCELLED
+	case notification.StatusUnknown:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_UNKNOWN
 	default:
 		return pb.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
 	}
@@ -74,25 +111,41 @@ func statusToProto(status notification.NotificationStatus) pb.NotificationStatus
 // notificationToProto converts internal Notification to proto Notification
 func notificationToProto(n *notification.Notification) *pb.Notification {
 	protoNotif := &pb.Notification{
-		Id:          n.ID,
-		UserId:      n.UserID,
-		Channel:     channelToProto(n.Channel),
-		Recipient:   n.Recipient,
-		Subject:     n.Subject,
-		Body:        n.Body,
-		Status:      statusToProto(n.Status),
-		ExternalId:  n.ExternalID,
+		Id:           n.ID,
+		UserId:       n.UserID,
+		Channel:      channelToProto(n.Channel),
+		Recipient:    n.Recipient,
+		Subject:      n.Subject,
+		Body:         n.Body,
+		Status:       statusToProto(n.Status),
+		ExternalId:   n.ExternalID,
 		ErrorMessage: n.ErrorMessage,
-		RetryCount:  int32(n.RetryCount),
-		CreatedAt:   timestamppb.New(n.CreatedAt),
-		UpdatedAt:   timestamppb.New(n.UpdatedAt),
-		Metadata:    n.Metadata,
+		RetryCount:   int32(n.RetryCount),
+		CreatedAt:    timestamppb.New(n.CreatedAt),
+		UpdatedAt:    timestamppb.New(n.UpdatedAt),
+		Metadata:     n.Metadata,
+		GroupId:      n.GroupID,
+		CollapseKey:  n.CollapseKey,
+		Category:     n.Category,
+		CallbackUrl:  n.CallbackURL,
+		Priority:     priorityToProto(n.Priority),
+		CreatedBy:    n.CreatedBy,
+		Source:       n.Source,
 	}
 
 	// Handle optional timestamps
 	if n.ScheduledAt != nil {
 		protoNotif.ScheduledAt = timestamppb.New(*n.ScheduledAt)
 	}
+	if n.ExpiresAt != nil {
+		protoNotif.ExpiresAt = timestamppb.New(*n.ExpiresAt)
+	}
+	if n.SendWindowStart != nil {
+		protoNotif.SendWindowStart = timestamppb.New(*n.SendWindowStart)
+	}
+	if n.SendWindowEnd != nil {
+		protoNotif.SendWindowEnd = timestamppb.New(*n.SendWindowEnd)
+	}
 	if n.SentAt != nil {
 		protoNotif.SentAt = timestamppb.New(*n.SentAt)
 	}
@@ -123,6 +176,8 @@ func userPreferenceToProto(p *notification.UserPreference) *pb.UserPreference {
 		Channel:   channelToProto(p.Channel),
 		Enabled:   p.Enabled,
 		Frequency: frequency,
+		Locale:    p.Locale,
+		Category:  p.Category,
 		CreatedAt: timestamppb.New(p.CreatedAt),
 		UpdatedAt: timestamppb.New(p.UpdatedAt),
 	}
@@ -148,7 +203,59 @@ func userPreferenceFromProto(p *pb.UserPreference) *notification.UserPreference
 		Channel:   channelFromProto(p.Channel),
 		Enabled:   p.Enabled,
 		Frequency: frequency,
+		Locale:    p.Locale,
+		Category:  p.Category,
 		CreatedAt: p.CreatedAt.AsTime(),
 		UpdatedAt: p.UpdatedAt.AsTime(),
 	}
-}
\ No newline at end of file
+}
+
+// userPreferenceRequestFromProto converts a proto UserPreference to one
+// preference of a replacement set, checking the fields the REST API validates
+func userPreferenceRequestFromProto(p *pb.UserPreference) (notification.UserPreferenceRequest, error) {
+	channel := channelFromProto(p.Channel)
+	if channel == "" {
+		return notification.UserPreferenceRequest{}, fmt.Errorf("channel is required")
+	}
+	switch p.Category {
+	case "", notification.CategoryTransactional, notification.CategoryMarketing:
+	default:
+		return notification.UserPreferenceRequest{}, fmt.Errorf("category must be transactional or marketing")
+	}
+	if len(p.Locale) > 20 {
+		return notification.UserPreferenceRequest{}, fmt.Errorf("locale must be at most 20 characters")
+	}
+
+	var frequency string
+	switch p.Frequency {
+	case pb.Frequency_FREQUENCY_HOURLY:
+		frequency = "hourly"
+	case pb.Frequency_FREQUENCY_DAILY:
+		frequency = "daily"
+	default:
+		frequency = "immediate"
+	}
+
+	enabled := p.Enabled
+	return notification.UserPreferenceRequest{
+		Channel:   channel,
+		Enabled:   &enabled,
+		Frequency: frequency,
+		Locale:    p.Locale,
+		Category:  p.Category,
+	}, nil
+}
+
+// attachmentFromProto converts a proto Attachment with raw content to an internal base64-encoded Attachment
+func attachmentFromProto(a *pb.Attachment) notification.Attachment {
+	attachment := notification.Attachment{
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		URL:         a.Url,
+		ContentID:   a.ContentId,
+	}
+	if len(a.Content) > 0 || a.Url == "" {
+		attachment.Content = base64.StdEncoding.EncodeToString(a.Content)
+	}
+	return attachment
+}
@@ -0,0 +1,62 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/alexnthnz/notification-system/api/proto/gen"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// newTestGateway returns a gateway serving s as JSON, as cmd/api mounts it
+func newTestGateway(t *testing.T, s *Server) http.Handler {
+	t.Helper()
+	gateway := runtime.NewServeMux()
+	if err := pb.RegisterNotificationServiceHandlerServer(context.Background(), gateway, s); err != nil {
+		t.Fatalf("RegisterNotificationServiceHandlerServer() error = %v", err)
+	}
+	return gateway
+}
+
+func TestGatewayReachesGRPCHandler(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, mock := newTestServer(t)
+	mock.ExpectQuery("SELECT id, status, updated_at, external_id FROM notifications WHERE id = $1").WithArgs("n1", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "delivered", updatedAt, "SM123"))
+
+	rec := httptest.NewRecorder()
+	newTestGateway(t, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/notifications/n1/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		ID         string `json:"id"`
+		Status     string `json:"status"`
+		ExternalID string `json:"externalId"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.ID != "n1" || body.ExternalID != "SM123" || body.Status == "" {
+		t.Errorf("response = %s, want the notification's status", rec.Body)
+	}
+}
+
+func TestGatewayMapsGRPCErrors(t *testing.T) {
+	s, mock := newTestServer(t)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("missing", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id"))
+
+	rec := httptest.NewRecorder()
+	newTestGateway(t, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/notifications/missing/status", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
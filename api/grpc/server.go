@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,8 +19,8 @@ import (
 type Server struct {
 	pb.UnimplementedNotificationServiceServer
 	notificationService *notification.Service
-	metrics            *monitoring.Metrics
-	logger             *zap.Logger
+	metrics             *monitoring.Metrics
+	logger              *zap.Logger
 }
 
 // NewServer creates a new gRPC server
@@ -30,18 +31,22 @@ func NewServer(
 ) *Server {
 	return &Server{
 		notificationService: notificationService,
-		metrics:            metrics,
-		logger:             logger,
+		metrics:             metrics,
+		logger:              logger,
 	}
 }
 
 // CreateNotification creates a new notification
 func (s *Server) CreateNotification(ctx context.Context, req *pb.CreateNotificationRequest) (*pb.CreateNotificationResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsCreate); err != nil {
+		return nil, err
+	}
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start).Seconds()
 		s.metrics.RecordProcessingDuration("grpc", "create_notification", duration)
 	}()
+	ctx = notification.WithSource(ctx, notification.SourceGRPC)
 
 	s.logger.Info("gRPC CreateNotification request",
 		zap.String("user_id", req.UserId),
@@ -49,30 +54,155 @@ func (s *Server) CreateNotification(ctx context.Context, req *pb.CreateNotificat
 		zap.String("recipient", req.Recipient),
 	)
 
+	notifReq, err := notificationRequestFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Broadcast to a topic instead of a user
+	if req.Topic != "" {
+		notifReq.Topic = req.Topic
+		notifReq.Recipients = req.Recipients
+		return s.createBroadcast(ctx, notifReq)
+	}
+
+	// Fan out when several recipients were given
+	if len(req.Recipients) > 0 {
+		if req.Recipient != "" {
+			return nil, status.Error(codes.InvalidArgument, "specify either recipient or recipients, not both")
+		}
+		if len(req.FallbackChannels) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "fallback_channels cannot be combined with recipients")
+		}
+		if len(req.Recipients) > 100 {
+			return nil, status.Error(codes.InvalidArgument, "at most 100 recipients are allowed")
+		}
+		notifReq.Recipients = req.Recipients
+		return s.createNotificationGroup(ctx, notifReq)
+	}
+
+	// Create notification
+	notif, err := s.notificationService.CreateNotification(ctx, notifReq)
+	if err != nil {
+		return nil, s.createError(notifReq.Channel, err)
+	}
+
+	s.metrics.RecordNotificationSent(notifReq.Channel, "created")
+	s.logger.Info("Notification created via gRPC",
+		zap.String("id", notif.ID),
+		zap.String("channel", notif.Channel),
+	)
+
+	response := &pb.CreateNotificationResponse{
+		Id:        notif.ID,
+		Status:    statusToProto(notif.Status),
+		Message:   "Notification created successfully",
+		CreatedAt: timestamppb.New(notif.CreatedAt),
+		Truncated: notif.Truncated,
+	}
+	setDeferred(response, notif)
+	return response, nil
+}
+
+// setDeferred adds when and why notif is held instead of queued right away
+// to response
+func setDeferred(response *pb.CreateNotificationResponse, notif *notification.Notification) {
+	if notif.DeferredReason == "" {
+		return
+	}
+	response.ScheduledAt = timestamppb.New(*notif.ScheduledAt)
+	response.DeferredReason = notif.DeferredReason
+}
+
+// DryRunNotification runs the checks of CreateNotification on a request and
+// returns the notification it would create, without storing or queueing it
+func (s *Server) DryRunNotification(ctx context.Context, req *pb.CreateNotificationRequest) (*pb.DryRunNotificationResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsCreate); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "dry_run_notification", duration)
+	}()
+	ctx = notification.WithSource(ctx, notification.SourceGRPC)
+
+	notifReq, err := notificationRequestFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+	notifReq.Topic = req.Topic
+	notifReq.Recipients = req.Recipients
+
+	result, err := s.notificationService.DryRunNotification(ctx, notifReq)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidDryRun) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, s.checkError(err)
+	}
+
+	return &pb.DryRunNotificationResponse{
+		Notification:     notificationToProto(result.Notification),
+		RequestedChannel: channelToProto(result.RequestedChannel),
+		Scheduled:        result.Scheduled,
+	}, nil
+}
+
+// notificationRequestFromProto validates a create request and converts it to
+// the internal request, leaving out its topic and recipients
+func notificationRequestFromProto(req *pb.CreateNotificationRequest) (notification.NotificationRequest, error) {
 	// Validate request
-	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	if req.UserId == "" && req.Topic == "" {
+		return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "user_id or topic is required")
 	}
 	if req.Channel == pb.Channel_CHANNEL_UNSPECIFIED {
-		return nil, status.Error(codes.InvalidArgument, "channel is required")
+		return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "channel is required")
+	}
+	if req.Body == "" && req.Template == "" {
+		return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "body or template is required")
 	}
-	if req.Recipient == "" {
-		return nil, status.Error(codes.InvalidArgument, "recipient is required")
+	if len(req.CollapseKey) > 64 {
+		return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "collapse_key must be at most 64 characters")
 	}
-	if req.Body == "" {
-		return nil, status.Error(codes.InvalidArgument, "body is required")
+	if req.Category != "" && !notification.IsValidCategory(req.Category) {
+		return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "category must be transactional or marketing")
 	}
 
 	// Convert gRPC request to internal request
 	notifReq := notification.NotificationRequest{
-		UserID:    req.UserId,
-		Channel:   channelFromProto(req.Channel),
-		Recipient: req.Recipient,
-		Subject:   req.Subject,
-		Body:      req.Body,
-		Priority:  int(req.Priority),
-		Variables: req.Variables,
-		Metadata:  req.Metadata,
+		UserID:      req.UserId,
+		Channel:     channelFromProto(req.Channel),
+		Recipient:   req.Recipient,
+		Subject:     req.Subject,
+		Body:        req.Body,
+		Priority:    priorityFromProto(req.Priority),
+		Template:    req.Template,
+		Locale:      req.Locale,
+		Variables:   req.Variables,
+		Metadata:    req.Metadata,
+		CollapseKey: req.CollapseKey,
+		Category:    req.Category,
+		CallbackURL: req.CallbackUrl,
+	}
+
+	for _, c := range req.FallbackChannels {
+		channel := channelFromProto(c)
+		if channel == "" {
+			return notification.NotificationRequest{}, status.Error(codes.InvalidArgument, "fallback_channels must be valid channels")
+		}
+		notifReq.FallbackChannels = append(notifReq.FallbackChannels, channel)
+	}
+
+	if len(req.ProviderOptions) > 0 {
+		notifReq.ProviderOptions = make(notification.ProviderOptions, len(req.ProviderOptions))
+		for provider, options := range req.ProviderOptions {
+			notifReq.ProviderOptions[provider] = options.GetOptions()
+		}
+	}
+
+	for _, a := range req.Attachments {
+		notifReq.Attachments = append(notifReq.Attachments, attachmentFromProto(a))
 	}
 
 	// Handle scheduled_at
@@ -81,30 +211,159 @@ func (s *Server) CreateNotification(ctx context.Context, req *pb.CreateNotificat
 		notifReq.ScheduledAt = &scheduledAt
 	}
 
-	// Create notification
-	notif, err := s.notificationService.CreateNotification(ctx, notifReq)
+	// Handle expires_at
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		notifReq.ExpiresAt = &expiresAt
+	}
+
+	// Handle the send window
+	if req.SendWindowStart != nil {
+		windowStart := req.SendWindowStart.AsTime()
+		notifReq.SendWindowStart = &windowStart
+	}
+	if req.SendWindowEnd != nil {
+		windowEnd := req.SendWindowEnd.AsTime()
+		notifReq.SendWindowEnd = &windowEnd
+	}
+
+	return notifReq, nil
+}
+
+// createNotificationGroup creates one notification per recipient
+func (s *Server) createNotificationGroup(ctx context.Context, notifReq notification.NotificationRequest) (*pb.CreateNotificationResponse, error) {
+	group, err := s.notificationService.CreateNotificationGroup(ctx, notifReq)
+	if err != nil {
+		return nil, s.createError(notifReq.Channel, err)
+	}
+
+	// Every notification in the group has the same content and schedule
+	var truncated []string
+	if len(group.Notifications) > 0 {
+		truncated = group.Notifications[0].Truncated
+	}
+
+	ids := make([]string, 0, len(group.Notifications))
+	for _, notif := range group.Notifications {
+		s.metrics.RecordNotificationSent(notif.Channel, "created")
+		ids = append(ids, notif.ID)
+	}
+
+	s.logger.Info("Notification group created via gRPC",
+		zap.String("group_id", group.GroupID),
+		zap.Int("count", len(ids)),
+	)
+
+	response := &pb.CreateNotificationResponse{
+		Ids:       ids,
+		GroupId:   group.GroupID,
+		Status:    pb.NotificationStatus_NOTIFICATION_STATUS_PENDING,
+		Message:   "Notifications created successfully",
+		CreatedAt: timestamppb.Now(),
+		Truncated: truncated,
+	}
+	if len(group.Notifications) > 0 {
+		setDeferred(response, group.Notifications[0])
+	}
+	return response, nil
+}
+
+// createBroadcast creates a topic broadcast
+func (s *Server) createBroadcast(ctx context.Context, notifReq notification.NotificationRequest) (*pb.CreateNotificationResponse, error) {
+	broadcast, err := s.notificationService.CreateBroadcast(ctx, notifReq)
 	if err != nil {
-		s.logger.Error("Failed to create notification", zap.Error(err))
-		s.metrics.RecordNotificationFailed(notifReq.Channel, "creation_error")
-		return nil, status.Error(codes.Internal, "failed to create notification")
+		return nil, s.createError(notifReq.Channel, err)
 	}
 
 	s.metrics.RecordNotificationSent(notifReq.Channel, "created")
-	s.logger.Info("Notification created via gRPC",
-		zap.String("id", notif.ID),
-		zap.String("channel", notif.Channel),
+	s.logger.Info("Broadcast created via gRPC",
+		zap.String("id", broadcast.ID),
+		zap.String("topic", broadcast.Topic),
 	)
 
 	return &pb.CreateNotificationResponse{
-		Id:        notif.ID,
-		Status:    statusToProto(notif.Status),
-		Message:   "Notification created successfully",
-		CreatedAt: timestamppb.New(notif.CreatedAt),
+		Id:        broadcast.ID,
+		Status:    statusToProto(broadcast.Status),
+		Message:   "Broadcast created successfully",
+		CreatedAt: timestamppb.New(broadcast.CreatedAt),
+		Truncated: broadcast.Truncated,
 	}, nil
 }
 
+// createError maps a notification creation error to a gRPC status
+func (s *Server) createError(channel string, err error) error {
+	s.logger.Error("Failed to create notification", zap.Error(err))
+
+	reason, statusErr := createErrorStatus(err)
+	s.metrics.RecordNotificationFailed(channel, reason)
+	switch {
+	case errors.Is(err, notification.ErrNotificationsDisabled):
+		s.metrics.RecordNotificationBlocked(channel, monitoring.BlockedPreferences)
+	case errors.Is(err, notification.ErrRecipientSuppressed):
+		s.metrics.RecordNotificationBlocked(channel, monitoring.BlockedSuppressed)
+	}
+	return statusErr
+}
+
+// checkError maps the error of a request that creates nothing, such as a dry
+// run, to the status create would return for it. Nothing is counted as failed
+// or blocked since no notification exists.
+func (s *Server) checkError(err error) error {
+	s.logger.Error("Notification request rejected", zap.Error(err))
+
+	_, statusErr := createErrorStatus(err)
+	return statusErr
+}
+
+// createErrorStatus maps a notification creation error to the reason it is
+// counted as failed under and the gRPC status it is reported with
+func createErrorStatus(err error) (reason string, statusErr error) {
+	var attachmentErr *notification.AttachmentError
+	switch {
+	case errors.As(err, &attachmentErr):
+		return "invalid_attachment", status.Error(codes.InvalidArgument, attachmentErr.Error())
+	case errors.Is(err, notification.ErrInvalidPushOptions):
+		return "invalid_push_options", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidEmailOptions):
+		return "invalid_email_options", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidSMSOptions):
+		return "invalid_sms_options", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidProviderOptions):
+		return "invalid_provider_options", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidBroadcast):
+		return "invalid_broadcast", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidCallbackURL):
+		return "invalid_callback_url", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrInvalidSendWindow):
+		return "invalid_send_window", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrSubjectRequired):
+		return "subject_required", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrScheduleTooFar):
+		return "schedule_too_far", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrContentTooLong):
+		return "content_too_long", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, notification.ErrChannelDisabled):
+		return "channel_disabled", status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, notification.ErrNotificationsDisabled):
+		return "preferences_disabled", status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, notification.ErrNoRecipient):
+		return "no_recipient", status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, notification.ErrRecipientSuppressed):
+		return "recipient_suppressed", status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, notification.ErrTemplateNotFound):
+		return "template_error", status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, notification.ErrTemplateRender):
+		return "template_error", status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return "creation_error", status.Error(codes.Internal, "failed to create notification")
+	}
+}
+
 // GetNotification retrieves a notification by ID
 func (s *Server) GetNotification(ctx context.Context, req *pb.GetNotificationRequest) (*pb.GetNotificationResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsRead); err != nil {
+		return nil, err
+	}
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start).Seconds()
@@ -118,7 +377,7 @@ func (s *Server) GetNotification(ctx context.Context, req *pb.GetNotificationReq
 	notif, err := s.notificationService.GetNotification(ctx, req.Id)
 	if err != nil {
 		s.logger.Error("Failed to get notification", zap.Error(err), zap.String("id", req.Id))
-		if err.Error() == "notification not found" {
+		if errors.Is(err, notification.ErrNotificationNotFound) {
 			return nil, status.Error(codes.NotFound, "notification not found")
 		}
 		return nil, status.Error(codes.Internal, "failed to retrieve notification")
@@ -129,19 +388,91 @@ func (s *Server) GetNotification(ctx context.Context, req *pb.GetNotificationReq
 	}, nil
 }
 
-// ListNotifications lists notifications for a user (placeholder implementation)
+// GetNotificationStatus retrieves only the delivery status of a notification
+func (s *Server) GetNotificationStatus(ctx context.Context, req *pb.GetNotificationStatusRequest) (*pb.GetNotificationStatusResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsRead); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "get_notification_status", duration)
+	}()
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	summary, err := s.notificationService.GetNotificationStatus(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("Failed to get notification status", zap.Error(err), zap.String("id", req.Id))
+		if errors.Is(err, notification.ErrNotificationNotFound) {
+			return nil, status.Error(codes.NotFound, "notification not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to retrieve notification status")
+	}
+
+	return &pb.GetNotificationStatusResponse{
+		Id:         summary.ID,
+		Status:     statusToProto(summary.Status),
+		UpdatedAt:  timestamppb.New(summary.UpdatedAt),
+		ExternalId: summary.ExternalID,
+	}, nil
+}
+
+// ListNotifications lists notifications with optional filtering and pagination
 func (s *Server) ListNotifications(ctx context.Context, req *pb.ListNotificationsRequest) (*pb.ListNotificationsResponse, error) {
-	// This is a placeholder implementation
-	// In a real system, you'd implement pagination and filtering
+	if err := requireScope(ctx, notification.ScopeNotificationsRead); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "list_notifications", duration)
+	}()
+
+	if req.PageSize < 0 || req.PageSize > 200 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must be between 1 and 200")
+	}
+
+	filter := notification.ListNotificationsFilter{
+		UserID:    req.UserId,
+		Channel:   channelFromProto(req.Channel),
+		CreatedBy: req.CreatedBy,
+		Source:    req.Source,
+		Limit:     int(req.PageSize),
+		PageToken: req.PageToken,
+	}
+	if req.Status != pb.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED {
+		filter.Status = statusFromProto(req.Status)
+	}
+
+	result, err := s.notificationService.ListNotifications(ctx, filter)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		s.logger.Error("Failed to list notifications", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list notifications")
+	}
+
+	notifications := make([]*pb.Notification, 0, len(result.Notifications))
+	for _, notif := range result.Notifications {
+		notifications = append(notifications, notificationToProto(notif))
+	}
+
 	return &pb.ListNotificationsResponse{
-		Notifications:   []*pb.Notification{},
-		NextPageToken:   "",
-		TotalCount:      0,
+		Notifications: notifications,
+		NextPageToken: result.NextPageToken,
+		TotalCount:    int32(result.TotalCount),
 	}, nil
 }
 
 // UpdateNotificationStatus updates the status of a notification
 func (s *Server) UpdateNotificationStatus(ctx context.Context, req *pb.UpdateNotificationStatusRequest) (*pb.UpdateNotificationStatusResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsUpdate); err != nil {
+		return nil, err
+	}
 	if req.Id == "" {
 		return nil, status.Error(codes.InvalidArgument, "id is required")
 	}
@@ -158,6 +489,12 @@ func (s *Server) UpdateNotificationStatus(ctx context.Context, req *pb.UpdateNot
 	)
 	if err != nil {
 		s.logger.Error("Failed to update notification status", zap.Error(err), zap.String("id", req.Id))
+		switch {
+		case errors.Is(err, notification.ErrInvalidStatusTransition):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, notification.ErrNotificationNotFound):
+			return nil, status.Error(codes.NotFound, "notification not found")
+		}
 		return nil, status.Error(codes.Internal, "failed to update notification status")
 	}
 
@@ -167,19 +504,190 @@ func (s *Server) UpdateNotificationStatus(ctx context.Context, req *pb.UpdateNot
 	}, nil
 }
 
-// GetUserPreferences retrieves user notification preferences (placeholder)
-func (s *Server) GetUserPreferences(ctx context.Context, req *pb.GetUserPreferencesRequest) (*pb.GetUserPreferencesResponse, error) {
-	// Placeholder implementation
-	return &pb.GetUserPreferencesResponse{
-		Preferences: []*pb.UserPreference{},
+// UpdateNotificationStatusBatch updates the status of many notifications in
+// one statement. Updates fail individually: the response reports each outcome
+// in request order, and the RPC only fails when the batch could not be applied.
+func (s *Server) UpdateNotificationStatusBatch(ctx context.Context, req *pb.UpdateNotificationStatusBatchRequest) (*pb.UpdateNotificationStatusBatchResponse, error) {
+	if err := requireScope(ctx, notification.ScopeNotificationsUpdate); err != nil {
+		return nil, err
+	}
+	if len(req.Updates) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "updates are required")
+	}
+	if len(req.Updates) > notification.MaxStatusBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d updates are allowed", notification.MaxStatusBatchSize)
+	}
+
+	results := make([]*pb.StatusUpdateResult, len(req.Updates))
+	updates := make([]notification.StatusUpdate, 0, len(req.Updates))
+	positions := make([]int, 0, len(req.Updates))
+	for i, update := range req.Updates {
+		switch {
+		case update.Id == "":
+			results[i] = &pb.StatusUpdateResult{Code: codes.InvalidArgument.String(), ErrorMessage: "id is required"}
+		case update.Status == pb.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED:
+			results[i] = &pb.StatusUpdateResult{Id: update.Id, Code: codes.InvalidArgument.String(), ErrorMessage: "status is required"}
+		default:
+			updates = append(updates, notification.StatusUpdate{
+				ID:           update.Id,
+				Status:       statusFromProto(update.Status),
+				ExternalID:   update.ExternalId,
+				ErrorMessage: update.ErrorMessage,
+			})
+			positions = append(positions, i)
+		}
+	}
+
+	errs, err := s.notificationService.UpdateNotificationStatusBatch(ctx, updates)
+	if err != nil {
+		s.logger.Error("Failed to update notification statuses", zap.Error(err), zap.Int("count", len(updates)))
+		return nil, status.Error(codes.Internal, "failed to update notification statuses")
+	}
+
+	var updated int32
+	for j, err := range errs {
+		result := &pb.StatusUpdateResult{Id: updates[j].ID, Success: err == nil}
+		switch {
+		case err == nil:
+			updated++
+		case errors.Is(err, notification.ErrInvalidStatusTransition):
+			result.Code = codes.FailedPrecondition.String()
+			result.ErrorMessage = err.Error()
+		case errors.Is(err, notification.ErrNotificationNotFound):
+			result.Code = codes.NotFound.String()
+			result.ErrorMessage = "notification not found"
+		default:
+			result.Code = codes.InvalidArgument.String()
+			result.ErrorMessage = err.Error()
+		}
+		results[positions[j]] = result
+	}
+
+	return &pb.UpdateNotificationStatusBatchResponse{
+		Results:      results,
+		UpdatedCount: updated,
 	}, nil
 }
 
-// UpdateUserPreferences updates user notification preferences (placeholder)
+// GetUserPreferences retrieves the user's stored notification preferences.
+// Channels and categories without one use the defaults.
+func (s *Server) GetUserPreferences(ctx context.Context, req *pb.GetUserPreferencesRequest) (*pb.GetUserPreferencesResponse, error) {
+	if err := requireScope(ctx, notification.ScopePreferencesRead); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	preferences, err := s.notificationService.GetUserPreferenceSet(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error("Failed to get user preferences", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to get user preferences")
+	}
+
+	response := &pb.GetUserPreferencesResponse{
+		Preferences: make([]*pb.UserPreference, 0, len(preferences)),
+	}
+	for i := range preferences {
+		response.Preferences = append(response.Preferences, userPreferenceToProto(&preferences[i]))
+	}
+	return response, nil
+}
+
+// UpdateUserPreferences replaces the user's notification preferences with the
+// given set in one transaction
 func (s *Server) UpdateUserPreferences(ctx context.Context, req *pb.UpdateUserPreferencesRequest) (*pb.UpdateUserPreferencesResponse, error) {
-	// Placeholder implementation
-	return &pb.UpdateUserPreferencesResponse{
-		Success: true,
-		Message: "User preferences updated successfully",
+	if err := requireScope(ctx, notification.ScopePreferencesWrite); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "update_user_preferences", duration)
+	}()
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	reqs := make([]notification.UserPreferenceRequest, 0, len(req.Preferences))
+	for i, pref := range req.Preferences {
+		prefReq, err := userPreferenceRequestFromProto(pref)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "preference %d: %v", i+1, err)
+		}
+		reqs = append(reqs, prefReq)
+	}
+
+	preferences, err := s.notificationService.ReplaceUserPreferences(ctx, req.UserId, reqs)
+	if err != nil {
+		switch {
+		case errors.Is(err, notification.ErrDuplicatePreference):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, notification.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		default:
+			s.logger.Error("Failed to replace user preferences", zap.Error(err), zap.String("user_id", req.UserId))
+			return nil, status.Error(codes.Internal, "failed to update user preferences")
+		}
+	}
+
+	response := &pb.UpdateUserPreferencesResponse{
+		Success:     true,
+		Message:     "User preferences updated successfully",
+		Preferences: make([]*pb.UserPreference, 0, len(preferences)),
+	}
+	for i := range preferences {
+		response.Preferences = append(response.Preferences, userPreferenceToProto(&preferences[i]))
+	}
+	return response, nil
+}
+
+// RegisterPushToken registers or refreshes a push token for one of a user's devices
+func (s *Server) RegisterPushToken(ctx context.Context, req *pb.RegisterPushTokenRequest) (*pb.RegisterPushTokenResponse, error) {
+	if err := requireScope(ctx, notification.ScopeDevicesWrite); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "register_push_token", duration)
+	}()
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if req.Token == "" || len(req.Token) > 500 {
+		return nil, status.Error(codes.InvalidArgument, "token is required and must be at most 500 characters")
+	}
+	switch req.Platform {
+	case "", "ios", "android", "web":
+	default:
+		return nil, status.Error(codes.InvalidArgument, "platform must be one of ios, android or web")
+	}
+
+	device, err := s.notificationService.RegisterPushToken(ctx, req.UserId, notification.PushTokenRequest{
+		Token:         req.Token,
+		Platform:      req.Platform,
+		PreviousToken: req.PreviousToken,
+	})
+	if err != nil {
+		if errors.Is(err, notification.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		s.logger.Error("Failed to register push token", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to register push token")
+	}
+
+	return &pb.RegisterPushTokenResponse{
+		Device: &pb.UserDevice{
+			Id:        device.ID,
+			UserId:    device.UserID,
+			Token:     device.Token,
+			Platform:  device.Platform,
+			Active:    device.Active,
+			CreatedAt: timestamppb.New(device.CreatedAt),
+			UpdatedAt: timestamppb.New(device.UpdatedAt),
+		},
 	}, nil
-}
\ No newline at end of file
+}
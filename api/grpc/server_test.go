@@ -0,0 +1,230 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/alexnthnz/notification-system/api/proto/gen"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testMetrics is shared by all tests since metrics register globally
+var testMetrics = monitoring.NewMetrics()
+
+// newTestServer returns a server backed by a mock database, without Redis or
+// Kafka
+func newTestServer(t *testing.T) (*Server, *dbtest.Mock) {
+	t.Helper()
+	return newTestServerWithConfig(t, &config.Config{})
+}
+
+// newTestServerWithConfig is newTestServer with the service configured by cfg
+func newTestServerWithConfig(t *testing.T, cfg *config.Config) (*Server, *dbtest.Mock) {
+	t.Helper()
+	db, mock := dbtest.New(t)
+	service, err := notification.NewService(cfg, db, nil, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return NewServer(service, testMetrics, zap.NewNop()), mock
+}
+
+func TestGetNotificationStatus(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, mock := newTestServer(t)
+	mock.ExpectQuery("SELECT id, status, updated_at, external_id FROM notifications WHERE id = $1").WithArgs("n1", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "delivered", updatedAt, "SM123"))
+
+	resp, err := s.GetNotificationStatus(context.Background(), &pb.GetNotificationStatusRequest{Id: "n1"})
+	if err != nil {
+		t.Fatalf("GetNotificationStatus() error = %v", err)
+	}
+	if resp.Id != "n1" || resp.Status != statusToProto(notification.StatusDelivered) || resp.ExternalId != "SM123" || !resp.UpdatedAt.AsTime().Equal(updatedAt) {
+		t.Errorf("GetNotificationStatus() = %v", resp)
+	}
+}
+
+func TestGetNotificationStatusErrors(t *testing.T) {
+	s, mock := newTestServer(t)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("missing", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id"))
+
+	tests := []struct {
+		id   string
+		want codes.Code
+	}{
+		{"", codes.InvalidArgument},
+		{"missing", codes.NotFound},
+	}
+	for _, tt := range tests {
+		_, err := s.GetNotificationStatus(context.Background(), &pb.GetNotificationStatusRequest{Id: tt.id})
+		if status.Code(err) != tt.want {
+			t.Errorf("GetNotificationStatus(%q) error = %v, want %v", tt.id, err, tt.want)
+		}
+	}
+}
+
+func TestUpdateNotificationStatusBatch(t *testing.T) {
+	const (
+		sentID    = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b01"
+		pendingID = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b02"
+		missingID = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b03"
+	)
+	s, mock := newTestServer(t)
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").
+		WillReturnRows(notificationtest.BatchRows([]int64{0}, notification.Notification{ID: sentID, Channel: "sms", Status: notification.StatusSent}))
+	mock.ExpectQuery("SELECT id, status FROM notifications WHERE id = ANY($1::uuid[])").
+		WillReturnRows(dbtest.NewRows("id", "status").AddRow(sentID, "sent").AddRow(pendingID, "delivered"))
+	notificationtest.ExpectEvent(mock, sentID, notification.StatusSent)
+
+	resp, err := s.UpdateNotificationStatusBatch(context.Background(), &pb.UpdateNotificationStatusBatchRequest{Updates: []*pb.UpdateNotificationStatusRequest{
+		{Id: sentID, Status: pb.NotificationStatus_NOTIFICATION_STATUS_SENT, ExternalId: "SM1"},
+		{Id: ""},
+		{Id: pendingID, Status: pb.NotificationStatus_NOTIFICATION_STATUS_PENDING},
+		{Id: missingID, Status: pb.NotificationStatus_NOTIFICATION_STATUS_SENT},
+		{Id: pendingID, Status: pb.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED},
+	}})
+	if err != nil {
+		t.Fatalf("UpdateNotificationStatusBatch() error = %v", err)
+	}
+	if resp.UpdatedCount != 1 || len(resp.Results) != 5 {
+		t.Fatalf("UpdateNotificationStatusBatch() = %v, want 1 of 5 updated", resp)
+	}
+
+	// Results are reported in request order
+	want := []struct {
+		id      string
+		success bool
+		code    codes.Code
+	}{
+		{sentID, true, codes.OK},
+		{"", false, codes.InvalidArgument},
+		{pendingID, false, codes.FailedPrecondition},
+		{missingID, false, codes.NotFound},
+		{pendingID, false, codes.InvalidArgument},
+	}
+	for i, w := range want {
+		result := resp.Results[i]
+		wantCode := ""
+		if w.code != codes.OK {
+			wantCode = w.code.String()
+		}
+		if result.Id != w.id || result.Success != w.success || result.Code != wantCode {
+			t.Errorf("result %d = %v, want id %q success %v code %q", i, result, w.id, w.success, wantCode)
+		}
+	}
+}
+
+func TestUpdateNotificationStatusBatchErrors(t *testing.T) {
+	s, mock := newTestServer(t)
+
+	if _, err := s.UpdateNotificationStatusBatch(context.Background(), &pb.UpdateNotificationStatusBatchRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty batch error = %v, want InvalidArgument", err)
+	}
+
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").WillReturnError(errors.New("connection reset"))
+	_, err := s.UpdateNotificationStatusBatch(context.Background(), &pb.UpdateNotificationStatusBatchRequest{Updates: []*pb.UpdateNotificationStatusRequest{
+		{Id: "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b01", Status: pb.NotificationStatus_NOTIFICATION_STATUS_SENT},
+	}})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("failed batch error = %v, want Internal", err)
+	}
+}
+
+func TestGetNotificationErrors(t *testing.T) {
+	s, mock := newTestServer(t)
+
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("missing").WillReturnRows(notificationtest.Rows())
+	if _, err := s.GetNotification(context.Background(), &pb.GetNotificationRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetNotification(missing) error = %v, want NotFound", err)
+	}
+
+	// Only ErrNotificationNotFound maps to NotFound, whatever the error reads
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnError(errors.New("notification not found"))
+	if _, err := s.GetNotification(context.Background(), &pb.GetNotificationRequest{Id: "n1"}); status.Code(err) != codes.Internal {
+		t.Errorf("GetNotification() database error = %v, want Internal", err)
+	}
+}
+
+func TestUpdateNotificationStatusErrors(t *testing.T) {
+	s, mock := newTestServer(t)
+
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationtest.Rows())
+	mock.ExpectQuery("SELECT status FROM notifications WHERE id = $1").WillReturnRows(dbtest.NewRows("status"))
+	_, err := s.UpdateNotificationStatus(context.Background(), &pb.UpdateNotificationStatusRequest{Id: "missing", Status: pb.NotificationStatus_NOTIFICATION_STATUS_SENT})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("UpdateNotificationStatus(missing) error = %v, want NotFound", err)
+	}
+
+	mock.ExpectQuery("UPDATE notifications").WillReturnError(errors.New("notification not found"))
+	_, err = s.UpdateNotificationStatus(context.Background(), &pb.UpdateNotificationStatusRequest{Id: "n1", Status: pb.NotificationStatus_NOTIFICATION_STATUS_SENT})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("UpdateNotificationStatus() database error = %v, want Internal", err)
+	}
+}
+
+func TestDryRunNotification(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	s, mock := newTestServerWithConfig(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at"))
+
+	resp, err := s.DryRunNotification(context.Background(), &pb.CreateNotificationRequest{
+		UserId: "user-1", Channel: pb.Channel_CHANNEL_SMS, Recipient: "+15551234567", Body: "hi",
+	})
+	if err != nil {
+		t.Fatalf("DryRunNotification() error = %v", err)
+	}
+	if resp.Notification.GetId() != "" || resp.Notification.GetBody() != "hi" || resp.RequestedChannel != pb.Channel_CHANNEL_SMS || resp.Scheduled {
+		t.Errorf("DryRunNotification() = %v, want the SMS queued right away without an ID", resp)
+	}
+}
+
+func TestDryRunNotificationErrors(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	s, mock := newTestServerWithConfig(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+			AddRow("p1", "user-1", "sms", false, "immediate", "", "", time.Now(), time.Now()))
+
+	tests := []struct {
+		name string
+		req  *pb.CreateNotificationRequest
+		want codes.Code
+	}{
+		{"preferences disabled", &pb.CreateNotificationRequest{UserId: "user-1", Channel: pb.Channel_CHANNEL_SMS, Recipient: "+15551234567", Body: "hi"}, codes.FailedPrecondition},
+		{"several recipients", &pb.CreateNotificationRequest{UserId: "user-1", Channel: pb.Channel_CHANNEL_SMS, Recipients: []string{"+15551234567", "+15557654321"}, Body: "hi"}, codes.InvalidArgument},
+		{"channel disabled", &pb.CreateNotificationRequest{UserId: "user-1", Channel: pb.Channel_CHANNEL_EMAIL, Recipient: "a@example.com", Subject: "Hi", Body: "hi"}, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		if _, err := s.DryRunNotification(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: DryRunNotification() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSetDeferred(t *testing.T) {
+	scheduledAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	response := &pb.CreateNotificationResponse{}
+	setDeferred(response, &notification.Notification{})
+	if response.ScheduledAt != nil || response.DeferredReason != "" {
+		t.Errorf("queued response = %v, want no deferral", response)
+	}
+
+	setDeferred(response, &notification.Notification{ScheduledAt: &scheduledAt, DeferredReason: notification.DeferredSendWindow})
+	if response.DeferredReason != notification.DeferredSendWindow || !response.ScheduledAt.AsTime().Equal(scheduledAt) {
+		t.Errorf("held response = %v, want send_window until %v", response, scheduledAt)
+	}
+}
@@ -0,0 +1,116 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/alexnthnz/notification-system/api/proto/gen"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// streamBatchSize is how many streamed requests are created per transaction
+const streamBatchSize = 100
+
+// CreateNotificationStream creates the notifications streamed by the client.
+// Requests are read and created in batches of streamBatchSize; no more are
+// read while a batch is being created, so a client sending faster than
+// notifications are stored is held back by gRPC flow control.
+func (s *Server) CreateNotificationStream(stream grpc.ClientStreamingServer[pb.CreateNotificationRequest, pb.CreateNotificationStreamResponse]) error {
+	ctx := stream.Context()
+	if err := requireScope(ctx, notification.ScopeNotificationsCreate); err != nil {
+		return err
+	}
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordProcessingDuration("grpc", "create_notification_stream", duration)
+	}()
+
+	summary := &pb.CreateNotificationStreamResponse{}
+	batch := make([]*pb.CreateNotificationRequest, 0, streamBatchSize)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, req)
+		if len(batch) == streamBatchSize {
+			s.createStreamBatch(ctx, batch, summary)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		s.createStreamBatch(ctx, batch, summary)
+	}
+
+	s.logger.Info("Notification stream created via gRPC",
+		zap.Int32("total", summary.Total),
+		zap.Int32("created", summary.Created),
+		zap.Int32("failed", summary.Failed),
+	)
+	return stream.SendAndClose(summary)
+}
+
+// createStreamBatch creates a batch of streamed requests and appends their
+// results to summary
+func (s *Server) createStreamBatch(ctx context.Context, batch []*pb.CreateNotificationRequest, summary *pb.CreateNotificationStreamResponse) {
+	results := make([]*pb.CreateNotificationResult, len(batch))
+	channels := make([]string, len(batch))
+
+	var reqs []notification.NotificationRequest
+	var positions []int
+	for i, req := range batch {
+		results[i] = &pb.CreateNotificationResult{Index: summary.Total + int32(i)}
+		channels[i] = channelFromProto(req.Channel)
+
+		notifReq, err := notificationRequestFromProto(req)
+		if err == nil && (req.Topic != "" || len(req.Recipients) > 0) {
+			err = status.Error(codes.InvalidArgument, "topic and recipients are not supported when streaming")
+		}
+		if err != nil {
+			setStreamError(results[i], err)
+			continue
+		}
+		reqs = append(reqs, notifReq)
+		positions = append(positions, i)
+	}
+
+	if len(reqs) > 0 {
+		notifs, errs := s.notificationService.CreateNotifications(ctx, reqs)
+		for j, i := range positions {
+			if errs[j] != nil {
+				setStreamError(results[i], s.createError(channels[i], errs[j]))
+				continue
+			}
+			s.metrics.RecordNotificationSent(notifs[j].Channel, "created")
+			results[i].Id = notifs[j].ID
+		}
+	}
+
+	for _, result := range results {
+		if result.Id != "" {
+			summary.Created++
+		} else {
+			summary.Failed++
+		}
+	}
+	summary.Total += int32(len(batch))
+	summary.Results = append(summary.Results, results...)
+}
+
+// setStreamError records a gRPC status error on a stream result
+func setStreamError(result *pb.CreateNotificationResult, err error) {
+	st := status.Convert(err)
+	result.Code = st.Code().String()
+	result.Error = st.Message()
+}
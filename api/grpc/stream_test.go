@@ -0,0 +1,154 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/alexnthnz/notification-system/api/proto/gen"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"google.golang.org/grpc"
+)
+
+// fakeCreateStream is a client stream sending reqs and recording the summary
+type fakeCreateStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	reqs    []*pb.CreateNotificationRequest
+	summary *pb.CreateNotificationStreamResponse
+}
+
+func (s *fakeCreateStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeCreateStream) Recv() (*pb.CreateNotificationRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeCreateStream) SendAndClose(summary *pb.CreateNotificationStreamResponse) error {
+	s.summary = summary
+	return nil
+}
+
+// newStreamTestServer returns a server with SMS enabled
+func newStreamTestServer(t *testing.T) (*Server, *dbtest.Mock) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	return newTestServerWithConfig(t, cfg)
+}
+
+// smsRequest returns a valid streamed SMS request for user-<i>
+func smsRequest(i int) *pb.CreateNotificationRequest {
+	return &pb.CreateNotificationRequest{UserId: fmt.Sprintf("user-%d", i), Channel: pb.Channel_CHANNEL_SMS, Recipient: "+15551234567", Body: "hi"}
+}
+
+// expectStreamBatch expects the notifications of one streamed batch, for the
+// given users, to be stored in one transaction
+func expectStreamBatch(mock *dbtest.Mock, userIDs ...string) {
+	for _, userID := range userIDs {
+		mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WithArgs(userID, dbtest.AnyArg()).
+			WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at"))
+	}
+	mock.ExpectBegin()
+	now := time.Now()
+	for _, userID := range userIDs {
+		row := notification.Notification{ID: "n-" + userID, UserID: userID, Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: notification.StatusPending, CreatedAt: now, UpdatedAt: now}
+		mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationtest.Rows(row))
+		mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	}
+	mock.ExpectCommit()
+	for range userIDs {
+		notificationtest.ExpectEvent(mock, dbtest.AnyArg(), notification.StatusPending)
+	}
+}
+
+func TestCreateNotificationStream(t *testing.T) {
+	s, mock := newStreamTestServer(t)
+	invalid := smsRequest(1)
+	invalid.Body = ""
+	broadcast := smsRequest(3)
+	broadcast.Topic = "news"
+	stream := &fakeCreateStream{ctx: context.Background(), reqs: []*pb.CreateNotificationRequest{
+		smsRequest(0), invalid, smsRequest(2), broadcast, smsRequest(4),
+	}}
+	// The valid requests are created together
+	expectStreamBatch(mock, "user-0", "user-2", "user-4")
+
+	if err := s.CreateNotificationStream(stream); err != nil {
+		t.Fatalf("CreateNotificationStream() error = %v", err)
+	}
+
+	summary := stream.summary
+	if summary.Total != 5 || summary.Created != 3 || summary.Failed != 2 || len(summary.Results) != 5 {
+		t.Fatalf("summary = total %d, created %d, failed %d, %d results, want 5, 3, 2, 5", summary.Total, summary.Created, summary.Failed, len(summary.Results))
+	}
+	for i, result := range summary.Results {
+		if result.Index != int32(i) {
+			t.Errorf("result %d index = %d", i, result.Index)
+		}
+		failed := i == 1 || i == 3
+		if failed && (result.Id != "" || result.Code != "InvalidArgument") {
+			t.Errorf("result %d = %+v, want an InvalidArgument failure", i, result)
+		}
+		if !failed && (result.Id == "" || result.Code != "") {
+			t.Errorf("result %d = %+v, want created", i, result)
+		}
+	}
+}
+
+func TestCreateNotificationStreamBatches(t *testing.T) {
+	s, mock := newStreamTestServer(t)
+	total := streamBatchSize + 1
+
+	stream := &fakeCreateStream{ctx: context.Background()}
+	var first []string
+	for i := 0; i < total; i++ {
+		stream.reqs = append(stream.reqs, smsRequest(i))
+		if i < streamBatchSize {
+			first = append(first, fmt.Sprintf("user-%d", i))
+		}
+	}
+	// A full batch is stored before the rest of the stream is read
+	expectStreamBatch(mock, first...)
+	expectStreamBatch(mock, fmt.Sprintf("user-%d", streamBatchSize))
+
+	if err := s.CreateNotificationStream(stream); err != nil {
+		t.Fatalf("CreateNotificationStream() error = %v", err)
+	}
+	summary := stream.summary
+	if summary.Total != int32(total) || summary.Created != int32(total) || summary.Failed != 0 {
+		t.Errorf("summary = total %d, created %d, failed %d, want %d created", summary.Total, summary.Created, summary.Failed, total)
+	}
+	if last := summary.Results[total-1]; last.Index != int32(streamBatchSize) || last.Id == "" {
+		t.Errorf("last result = %+v, want index %d created", last, streamBatchSize)
+	}
+}
+
+func TestCreateNotificationStreamStoreFailure(t *testing.T) {
+	s, mock := newStreamTestServer(t)
+	stream := &fakeCreateStream{ctx: context.Background(), reqs: []*pb.CreateNotificationRequest{smsRequest(0), smsRequest(1)}}
+	for _, userID := range []string{"user-0", "user-1"} {
+		mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WithArgs(userID, dbtest.AnyArg()).
+			WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at"))
+	}
+	mock.ExpectBegin().WillReturnError(fmt.Errorf("connection refused"))
+
+	if err := s.CreateNotificationStream(stream); err != nil {
+		t.Fatalf("CreateNotificationStream() error = %v", err)
+	}
+	if summary := stream.summary; summary.Created != 0 || summary.Failed != 2 {
+		t.Errorf("summary = created %d, failed %d, want both failed", summary.Created, summary.Failed)
+	}
+}
@@ -7,6 +7,7 @@
 package notificationv1
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
@@ -85,6 +86,8 @@ const (
 	NotificationStatus_NOTIFICATION_STATUS_DELIVERED   NotificationStatus = 3
 	NotificationStatus_NOTIFICATION_STATUS_FAILED      NotificationStatus = 4
 	NotificationStatus_NOTIFICATION_STATUS_CANCELLED   NotificationStatus = 5
+	// Sent, but delivery was not confirmed within the delivery SLA
+	NotificationStatus_NOTIFICATION_STATUS_UNKNOWN NotificationStatus = 6
 )
 
 // Enum value maps for NotificationStatus.
@@ -96,6 +99,7 @@ var (
 		3: "NOTIFICATION_STATUS_DELIVERED",
 		4: "NOTIFICATION_STATUS_FAILED",
 		5: "NOTIFICATION_STATUS_CANCELLED",
+		6: "NOTIFICATION_STATUS_UNKNOWN",
 	}
 	NotificationStatus_value = map[string]int32{
 		"NOTIFICATION_STATUS_UNSPECIFIED": 0,
@@ -104,6 +108,7 @@ var (
 		"NOTIFICATION_STATUS_DELIVERED":   3,
 		"NOTIFICATION_STATUS_FAILED":      4,
 		"NOTIFICATION_STATUS_CANCELLED":   5,
+		"NOTIFICATION_STATUS_UNKNOWN":     6,
 	}
 )
 
@@ -242,19 +247,44 @@ func (Frequency) EnumDescriptor() ([]byte, []int) {
 
 // CreateNotificationRequest represents a request to create a notification
 type CreateNotificationRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Channel       Channel                `protobuf:"varint,2,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
-	Recipient     string                 `protobuf:"bytes,3,opt,name=recipient,proto3" json:"recipient,omitempty"`
-	Subject       string                 `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
-	Body          string                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
-	Priority      Priority               `protobuf:"varint,6,opt,name=priority,proto3,enum=notification.v1.Priority" json:"priority,omitempty"`
-	ScheduledAt   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
-	Template      string                 `protobuf:"bytes,8,opt,name=template,proto3" json:"template,omitempty"`
-	Variables     map[string]string      `protobuf:"bytes,9,rep,name=variables,proto3" json:"variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Metadata      map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	UserId      string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Channel     Channel                `protobuf:"varint,2,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
+	Recipient   string                 `protobuf:"bytes,3,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Subject     string                 `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body        string                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	Priority    Priority               `protobuf:"varint,6,opt,name=priority,proto3,enum=notification.v1.Priority" json:"priority,omitempty"`
+	ScheduledAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
+	Template    string                 `protobuf:"bytes,8,opt,name=template,proto3" json:"template,omitempty"`
+	Variables   map[string]string      `protobuf:"bytes,9,rep,name=variables,proto3" json:"variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metadata    map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExpiresAt   *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// recipients fans the request out to several recipients; mutually exclusive with recipient
+	Recipients []string `protobuf:"bytes,12,rep,name=recipients,proto3" json:"recipients,omitempty"`
+	// locale selects the template translation; defaults to the user's preferred locale
+	Locale string `protobuf:"bytes,13,opt,name=locale,proto3" json:"locale,omitempty"`
+	// attachments are only supported for the email channel
+	Attachments []*Attachment `protobuf:"bytes,14,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	// collapse_key lets a newer notification replace an older one with the same key on the device
+	CollapseKey string `protobuf:"bytes,15,opt,name=collapse_key,json=collapseKey,proto3" json:"collapse_key,omitempty"`
+	// fallback_channels are tried in order when the user has disabled channel
+	FallbackChannels []Channel `protobuf:"varint,16,rep,packed,name=fallback_channels,json=fallbackChannels,proto3,enum=notification.v1.Channel" json:"fallback_channels,omitempty"`
+	// topic broadcasts to every device subscribed to the push topic instead of a user;
+	// push only, and user_id may then be empty
+	Topic string `protobuf:"bytes,17,opt,name=topic,proto3" json:"topic,omitempty"`
+	// category is transactional (default) or marketing; marketing opt-outs never block transactional notifications
+	Category string `protobuf:"bytes,18,opt,name=category,proto3" json:"category,omitempty"`
+	// callback_url receives a signed POST on each status change of the notification
+	CallbackUrl string `protobuf:"bytes,19,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	// send_window_start holds the notification until this time
+	SendWindowStart *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=send_window_start,json=sendWindowStart,proto3" json:"send_window_start,omitempty"`
+	// send_window_end cancels the notification as expired when it was not sent by this time
+	SendWindowEnd *timestamppb.Timestamp `protobuf:"bytes,21,opt,name=send_window_end,json=sendWindowEnd,proto3" json:"send_window_end,omitempty"`
+	// provider_options are passed through to each provider, keyed by provider name
+	// (sendgrid, twilio or fcm); unknown providers and options are ignored
+	ProviderOptions map[string]*ProviderOptions `protobuf:"bytes,22,rep,name=provider_options,json=providerOptions,proto3" json:"provider_options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *CreateNotificationRequest) Reset() {
@@ -354,35 +384,604 @@ func (x *CreateNotificationRequest) GetMetadata() map[string]string {
 	if x != nil {
 		return x.Metadata
 	}
-	return nil
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetRecipients() []string {
+	if x != nil {
+		return x.Recipients
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *CreateNotificationRequest) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetCollapseKey() string {
+	if x != nil {
+		return x.CollapseKey
+	}
+	return ""
+}
+
+func (x *CreateNotificationRequest) GetFallbackChannels() []Channel {
+	if x != nil {
+		return x.FallbackChannels
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *CreateNotificationRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *CreateNotificationRequest) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+func (x *CreateNotificationRequest) GetSendWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendWindowStart
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetSendWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendWindowEnd
+	}
+	return nil
+}
+
+func (x *CreateNotificationRequest) GetProviderOptions() map[string]*ProviderOptions {
+	if x != nil {
+		return x.ProviderOptions
+	}
+	return nil
+}
+
+// ProviderOptions are the options passed through to one provider
+type ProviderOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Options       map[string]string      `protobuf:"bytes,1,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProviderOptions) Reset() {
+	*x = ProviderOptions{}
+	mi := &file_notification_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProviderOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProviderOptions) ProtoMessage() {}
+
+func (x *ProviderOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProviderOptions.ProtoReflect.Descriptor instead.
+func (*ProviderOptions) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProviderOptions) GetOptions() map[string]string {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// Attachment represents a file attached to an email notification
+type Attachment struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Filename    string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	ContentType string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Content     []byte                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	// content_id sends the attachment inline, referenced from HTML as cid:<content_id>
+	ContentId string `protobuf:"bytes,4,opt,name=content_id,json=contentId,proto3" json:"content_id,omitempty"`
+	// url is fetched by the email channel at send time instead of content, e.g. a presigned S3 or GCS URL
+	Url           string `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attachment) Reset() {
+	*x = Attachment{}
+	mi := &file_notification_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attachment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attachment) ProtoMessage() {}
+
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Attachment) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Attachment) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Attachment) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *Attachment) GetContentId() string {
+	if x != nil {
+		return x.ContentId
+	}
+	return ""
+}
+
+func (x *Attachment) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+// CreateNotificationResponse represents the response for creating a notification
+type CreateNotificationResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status    NotificationStatus     `protobuf:"varint,2,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
+	Message   string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// ids and group_id are set when the request was fanned out to several recipients
+	Ids     []string `protobuf:"bytes,5,rep,name=ids,proto3" json:"ids,omitempty"`
+	GroupId string   `protobuf:"bytes,6,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	// fields shortened to the channel's limits, when it is configured to truncate
+	Truncated []string `protobuf:"bytes,7,rep,name=truncated,proto3" json:"truncated,omitempty"`
+	// scheduled_at and deferred_reason (scheduled or send_window) are set when the
+	// notification is held instead of queued right away
+	ScheduledAt    *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
+	DeferredReason string                 `protobuf:"bytes,9,opt,name=deferred_reason,json=deferredReason,proto3" json:"deferred_reason,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CreateNotificationResponse) Reset() {
+	*x = CreateNotificationResponse{}
+	mi := &file_notification_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateNotificationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateNotificationResponse) ProtoMessage() {}
+
+func (x *CreateNotificationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateNotificationResponse.ProtoReflect.Descriptor instead.
+func (*CreateNotificationResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateNotificationResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CreateNotificationResponse) GetStatus() NotificationStatus {
+	if x != nil {
+		return x.Status
+	}
+	return NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
+}
+
+func (x *CreateNotificationResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CreateNotificationResponse) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *CreateNotificationResponse) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *CreateNotificationResponse) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *CreateNotificationResponse) GetTruncated() []string {
+	if x != nil {
+		return x.Truncated
+	}
+	return nil
+}
+
+func (x *CreateNotificationResponse) GetScheduledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ScheduledAt
+	}
+	return nil
+}
+
+func (x *CreateNotificationResponse) GetDeferredReason() string {
+	if x != nil {
+		return x.DeferredReason
+	}
+	return ""
+}
+
+// CreateNotificationStreamResponse summarizes a notification stream
+type CreateNotificationStreamResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Total   int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Created int32                  `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	Failed  int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	// one result per streamed request, in stream order
+	Results       []*CreateNotificationResult `protobuf:"bytes,4,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateNotificationStreamResponse) Reset() {
+	*x = CreateNotificationStreamResponse{}
+	mi := &file_notification_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateNotificationStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateNotificationStreamResponse) ProtoMessage() {}
+
+func (x *CreateNotificationStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateNotificationStreamResponse.ProtoReflect.Descriptor instead.
+func (*CreateNotificationStreamResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateNotificationStreamResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *CreateNotificationStreamResponse) GetCreated() int32 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *CreateNotificationStreamResponse) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *CreateNotificationStreamResponse) GetResults() []*CreateNotificationResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// CreateNotificationResult is the outcome of one streamed request
+type CreateNotificationResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the position of the request in the stream, from 0
+	Index int32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// id is set when the notification was created
+	Id string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// code and error are set when it was not, code being the gRPC status code name
+	Code          string `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateNotificationResult) Reset() {
+	*x = CreateNotificationResult{}
+	mi := &file_notification_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateNotificationResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateNotificationResult) ProtoMessage() {}
+
+func (x *CreateNotificationResult) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateNotificationResult.ProtoReflect.Descriptor instead.
+func (*CreateNotificationResult) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateNotificationResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *CreateNotificationResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CreateNotificationResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *CreateNotificationResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// DryRunNotificationResponse is what creating the request would produce
+type DryRunNotificationResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// notification as it would be stored, with its channel, recipient and rendered content resolved; it has no id
+	Notification *Notification `protobuf:"bytes,1,opt,name=notification,proto3" json:"notification,omitempty"`
+	// requested_channel differs from the notification's channel when the user would be reached on a fallback channel
+	RequestedChannel Channel `protobuf:"varint,2,opt,name=requested_channel,json=requestedChannel,proto3,enum=notification.v1.Channel" json:"requested_channel,omitempty"`
+	// scheduled is set when the notification would be held until scheduled_at instead of queued right away
+	Scheduled     bool `protobuf:"varint,3,opt,name=scheduled,proto3" json:"scheduled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DryRunNotificationResponse) Reset() {
+	*x = DryRunNotificationResponse{}
+	mi := &file_notification_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRunNotificationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRunNotificationResponse) ProtoMessage() {}
+
+func (x *DryRunNotificationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRunNotificationResponse.ProtoReflect.Descriptor instead.
+func (*DryRunNotificationResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DryRunNotificationResponse) GetNotification() *Notification {
+	if x != nil {
+		return x.Notification
+	}
+	return nil
+}
+
+func (x *DryRunNotificationResponse) GetRequestedChannel() Channel {
+	if x != nil {
+		return x.RequestedChannel
+	}
+	return Channel_CHANNEL_UNSPECIFIED
+}
+
+func (x *DryRunNotificationResponse) GetScheduled() bool {
+	if x != nil {
+		return x.Scheduled
+	}
+	return false
+}
+
+// GetNotificationRequest represents a request to get a notification
+type GetNotificationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetNotificationRequest) Reset() {
+	*x = GetNotificationRequest{}
+	mi := &file_notification_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetNotificationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetNotificationRequest) ProtoMessage() {}
+
+func (x *GetNotificationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetNotificationRequest.ProtoReflect.Descriptor instead.
+func (*GetNotificationRequest) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetNotificationRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
 }
 
-// CreateNotificationResponse represents the response for creating a notification
-type CreateNotificationResponse struct {
+// GetNotificationResponse represents the response for getting a notification
+type GetNotificationResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Status        NotificationStatus     `protobuf:"varint,2,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
-	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Notification  *Notification          `protobuf:"bytes,1,opt,name=notification,proto3" json:"notification,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateNotificationResponse) Reset() {
-	*x = CreateNotificationResponse{}
-	mi := &file_notification_proto_msgTypes[1]
+func (x *GetNotificationResponse) Reset() {
+	*x = GetNotificationResponse{}
+	mi := &file_notification_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateNotificationResponse) String() string {
+func (x *GetNotificationResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateNotificationResponse) ProtoMessage() {}
+func (*GetNotificationResponse) ProtoMessage() {}
 
-func (x *CreateNotificationResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[1]
+func (x *GetNotificationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -393,62 +992,41 @@ func (x *CreateNotificationResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateNotificationResponse.ProtoReflect.Descriptor instead.
-func (*CreateNotificationResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *CreateNotificationResponse) GetId() string {
-	if x != nil {
-		return x.Id
-	}
-	return ""
-}
-
-func (x *CreateNotificationResponse) GetStatus() NotificationStatus {
-	if x != nil {
-		return x.Status
-	}
-	return NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
-}
-
-func (x *CreateNotificationResponse) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
+// Deprecated: Use GetNotificationResponse.ProtoReflect.Descriptor instead.
+func (*GetNotificationResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *CreateNotificationResponse) GetCreatedAt() *timestamppb.Timestamp {
+func (x *GetNotificationResponse) GetNotification() *Notification {
 	if x != nil {
-		return x.CreatedAt
+		return x.Notification
 	}
 	return nil
 }
 
-// GetNotificationRequest represents a request to get a notification
-type GetNotificationRequest struct {
+// GetNotificationStatusRequest represents a request to get a notification's status
+type GetNotificationStatusRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetNotificationRequest) Reset() {
-	*x = GetNotificationRequest{}
-	mi := &file_notification_proto_msgTypes[2]
+func (x *GetNotificationStatusRequest) Reset() {
+	*x = GetNotificationStatusRequest{}
+	mi := &file_notification_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetNotificationRequest) String() string {
+func (x *GetNotificationStatusRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetNotificationRequest) ProtoMessage() {}
+func (*GetNotificationStatusRequest) ProtoMessage() {}
 
-func (x *GetNotificationRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[2]
+func (x *GetNotificationStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -459,41 +1037,44 @@ func (x *GetNotificationRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetNotificationRequest.ProtoReflect.Descriptor instead.
-func (*GetNotificationRequest) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use GetNotificationStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetNotificationStatusRequest) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *GetNotificationRequest) GetId() string {
+func (x *GetNotificationStatusRequest) GetId() string {
 	if x != nil {
 		return x.Id
 	}
 	return ""
 }
 
-// GetNotificationResponse represents the response for getting a notification
-type GetNotificationResponse struct {
+// GetNotificationStatusResponse carries the delivery status without the notification content
+type GetNotificationStatusResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Notification  *Notification          `protobuf:"bytes,1,opt,name=notification,proto3" json:"notification,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        NotificationStatus     `protobuf:"varint,2,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ExternalId    string                 `protobuf:"bytes,4,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetNotificationResponse) Reset() {
-	*x = GetNotificationResponse{}
-	mi := &file_notification_proto_msgTypes[3]
+func (x *GetNotificationStatusResponse) Reset() {
+	*x = GetNotificationStatusResponse{}
+	mi := &file_notification_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetNotificationResponse) String() string {
+func (x *GetNotificationStatusResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetNotificationResponse) ProtoMessage() {}
+func (*GetNotificationStatusResponse) ProtoMessage() {}
 
-func (x *GetNotificationResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[3]
+func (x *GetNotificationStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -504,18 +1085,39 @@ func (x *GetNotificationResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetNotificationResponse.ProtoReflect.Descriptor instead.
-func (*GetNotificationResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use GetNotificationStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetNotificationStatusResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *GetNotificationResponse) GetNotification() *Notification {
+func (x *GetNotificationStatusResponse) GetId() string {
 	if x != nil {
-		return x.Notification
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetNotificationStatusResponse) GetStatus() NotificationStatus {
+	if x != nil {
+		return x.Status
+	}
+	return NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
+}
+
+func (x *GetNotificationStatusResponse) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
 	}
 	return nil
 }
 
+func (x *GetNotificationStatusResponse) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
 // ListNotificationsRequest represents a request to list notifications
 type ListNotificationsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -524,13 +1126,15 @@ type ListNotificationsRequest struct {
 	Status        NotificationStatus     `protobuf:"varint,3,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
 	PageSize      int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
 	PageToken     string                 `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	CreatedBy     string                 `protobuf:"bytes,6,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"` // API key ID, or admin
+	Source        string                 `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`                        // api, grpc or admin
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ListNotificationsRequest) Reset() {
 	*x = ListNotificationsRequest{}
-	mi := &file_notification_proto_msgTypes[4]
+	mi := &file_notification_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -542,7 +1146,7 @@ func (x *ListNotificationsRequest) String() string {
 func (*ListNotificationsRequest) ProtoMessage() {}
 
 func (x *ListNotificationsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[4]
+	mi := &file_notification_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -555,7 +1159,7 @@ func (x *ListNotificationsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNotificationsRequest.ProtoReflect.Descriptor instead.
 func (*ListNotificationsRequest) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{4}
+	return file_notification_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *ListNotificationsRequest) GetUserId() string {
@@ -593,6 +1197,20 @@ func (x *ListNotificationsRequest) GetPageToken() string {
 	return ""
 }
 
+func (x *ListNotificationsRequest) GetCreatedBy() string {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return ""
+}
+
+func (x *ListNotificationsRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
 // ListNotificationsResponse represents the response for listing notifications
 type ListNotificationsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -605,7 +1223,7 @@ type ListNotificationsResponse struct {
 
 func (x *ListNotificationsResponse) Reset() {
 	*x = ListNotificationsResponse{}
-	mi := &file_notification_proto_msgTypes[5]
+	mi := &file_notification_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -617,7 +1235,7 @@ func (x *ListNotificationsResponse) String() string {
 func (*ListNotificationsResponse) ProtoMessage() {}
 
 func (x *ListNotificationsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[5]
+	mi := &file_notification_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -630,7 +1248,7 @@ func (x *ListNotificationsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNotificationsResponse.ProtoReflect.Descriptor instead.
 func (*ListNotificationsResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{5}
+	return file_notification_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *ListNotificationsResponse) GetNotifications() []*Notification {
@@ -667,7 +1285,7 @@ type UpdateNotificationStatusRequest struct {
 
 func (x *UpdateNotificationStatusRequest) Reset() {
 	*x = UpdateNotificationStatusRequest{}
-	mi := &file_notification_proto_msgTypes[6]
+	mi := &file_notification_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -679,7 +1297,7 @@ func (x *UpdateNotificationStatusRequest) String() string {
 func (*UpdateNotificationStatusRequest) ProtoMessage() {}
 
 func (x *UpdateNotificationStatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[6]
+	mi := &file_notification_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -692,7 +1310,7 @@ func (x *UpdateNotificationStatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateNotificationStatusRequest.ProtoReflect.Descriptor instead.
 func (*UpdateNotificationStatusRequest) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{6}
+	return file_notification_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *UpdateNotificationStatusRequest) GetId() string {
@@ -734,7 +1352,7 @@ type UpdateNotificationStatusResponse struct {
 
 func (x *UpdateNotificationStatusResponse) Reset() {
 	*x = UpdateNotificationStatusResponse{}
-	mi := &file_notification_proto_msgTypes[7]
+	mi := &file_notification_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -746,7 +1364,7 @@ func (x *UpdateNotificationStatusResponse) String() string {
 func (*UpdateNotificationStatusResponse) ProtoMessage() {}
 
 func (x *UpdateNotificationStatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[7]
+	mi := &file_notification_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -759,7 +1377,7 @@ func (x *UpdateNotificationStatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateNotificationStatusResponse.ProtoReflect.Descriptor instead.
 func (*UpdateNotificationStatusResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{7}
+	return file_notification_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *UpdateNotificationStatusResponse) GetSuccess() bool {
@@ -771,9 +1389,177 @@ func (x *UpdateNotificationStatusResponse) GetSuccess() bool {
 
 func (x *UpdateNotificationStatusResponse) GetMessage() string {
 	if x != nil {
-		return x.Message
+		return x.Message
+	}
+	return ""
+}
+
+// UpdateNotificationStatusBatchRequest represents a request to update the
+// status of up to 500 notifications in one statement
+type UpdateNotificationStatusBatchRequest struct {
+	state         protoimpl.MessageState             `protogen:"open.v1"`
+	Updates       []*UpdateNotificationStatusRequest `protobuf:"bytes,1,rep,name=updates,proto3" json:"updates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateNotificationStatusBatchRequest) Reset() {
+	*x = UpdateNotificationStatusBatchRequest{}
+	mi := &file_notification_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateNotificationStatusBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateNotificationStatusBatchRequest) ProtoMessage() {}
+
+func (x *UpdateNotificationStatusBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateNotificationStatusBatchRequest.ProtoReflect.Descriptor instead.
+func (*UpdateNotificationStatusBatchRequest) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateNotificationStatusBatchRequest) GetUpdates() []*UpdateNotificationStatusRequest {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+// StatusUpdateResult is the outcome of one update of a batch
+type StatusUpdateResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"` // gRPC code name of the failure, e.g. NotFound or FailedPrecondition
+	ErrorMessage  string                 `protobuf:"bytes,4,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusUpdateResult) Reset() {
+	*x = StatusUpdateResult{}
+	mi := &file_notification_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusUpdateResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusUpdateResult) ProtoMessage() {}
+
+func (x *StatusUpdateResult) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusUpdateResult.ProtoReflect.Descriptor instead.
+func (*StatusUpdateResult) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StatusUpdateResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StatusUpdateResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *StatusUpdateResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *StatusUpdateResult) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+// UpdateNotificationStatusBatchResponse reports the outcome of each update, in request order
+type UpdateNotificationStatusBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*StatusUpdateResult  `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	UpdatedCount  int32                  `protobuf:"varint,2,opt,name=updated_count,json=updatedCount,proto3" json:"updated_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateNotificationStatusBatchResponse) Reset() {
+	*x = UpdateNotificationStatusBatchResponse{}
+	mi := &file_notification_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateNotificationStatusBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateNotificationStatusBatchResponse) ProtoMessage() {}
+
+func (x *UpdateNotificationStatusBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateNotificationStatusBatchResponse.ProtoReflect.Descriptor instead.
+func (*UpdateNotificationStatusBatchResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UpdateNotificationStatusBatchResponse) GetResults() []*StatusUpdateResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *UpdateNotificationStatusBatchResponse) GetUpdatedCount() int32 {
+	if x != nil {
+		return x.UpdatedCount
 	}
-	return ""
+	return 0
 }
 
 // GetUserPreferencesRequest represents a request to get user preferences
@@ -786,7 +1572,7 @@ type GetUserPreferencesRequest struct {
 
 func (x *GetUserPreferencesRequest) Reset() {
 	*x = GetUserPreferencesRequest{}
-	mi := &file_notification_proto_msgTypes[8]
+	mi := &file_notification_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -798,7 +1584,7 @@ func (x *GetUserPreferencesRequest) String() string {
 func (*GetUserPreferencesRequest) ProtoMessage() {}
 
 func (x *GetUserPreferencesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[8]
+	mi := &file_notification_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -811,7 +1597,7 @@ func (x *GetUserPreferencesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetUserPreferencesRequest.ProtoReflect.Descriptor instead.
 func (*GetUserPreferencesRequest) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{8}
+	return file_notification_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *GetUserPreferencesRequest) GetUserId() string {
@@ -831,7 +1617,7 @@ type GetUserPreferencesResponse struct {
 
 func (x *GetUserPreferencesResponse) Reset() {
 	*x = GetUserPreferencesResponse{}
-	mi := &file_notification_proto_msgTypes[9]
+	mi := &file_notification_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -843,7 +1629,7 @@ func (x *GetUserPreferencesResponse) String() string {
 func (*GetUserPreferencesResponse) ProtoMessage() {}
 
 func (x *GetUserPreferencesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[9]
+	mi := &file_notification_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -856,7 +1642,7 @@ func (x *GetUserPreferencesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetUserPreferencesResponse.ProtoReflect.Descriptor instead.
 func (*GetUserPreferencesResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{9}
+	return file_notification_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *GetUserPreferencesResponse) GetPreferences() []*UserPreference {
@@ -866,7 +1652,8 @@ func (x *GetUserPreferencesResponse) GetPreferences() []*UserPreference {
 	return nil
 }
 
-// UpdateUserPreferencesRequest represents a request to update user preferences
+// UpdateUserPreferencesRequest replaces the user's preference set with preferences;
+// an empty set resets the user to the defaults
 type UpdateUserPreferencesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
@@ -877,7 +1664,7 @@ type UpdateUserPreferencesRequest struct {
 
 func (x *UpdateUserPreferencesRequest) Reset() {
 	*x = UpdateUserPreferencesRequest{}
-	mi := &file_notification_proto_msgTypes[10]
+	mi := &file_notification_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -889,7 +1676,7 @@ func (x *UpdateUserPreferencesRequest) String() string {
 func (*UpdateUserPreferencesRequest) ProtoMessage() {}
 
 func (x *UpdateUserPreferencesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[10]
+	mi := &file_notification_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -902,7 +1689,7 @@ func (x *UpdateUserPreferencesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateUserPreferencesRequest.ProtoReflect.Descriptor instead.
 func (*UpdateUserPreferencesRequest) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{10}
+	return file_notification_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *UpdateUserPreferencesRequest) GetUserId() string {
@@ -921,16 +1708,18 @@ func (x *UpdateUserPreferencesRequest) GetPreferences() []*UserPreference {
 
 // UpdateUserPreferencesResponse represents the response for updating user preferences
 type UpdateUserPreferencesResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// preferences is the user's stored preference set after the update
+	Preferences   []*UserPreference `protobuf:"bytes,3,rep,name=preferences,proto3" json:"preferences,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateUserPreferencesResponse) Reset() {
 	*x = UpdateUserPreferencesResponse{}
-	mi := &file_notification_proto_msgTypes[11]
+	mi := &file_notification_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -942,7 +1731,7 @@ func (x *UpdateUserPreferencesResponse) String() string {
 func (*UpdateUserPreferencesResponse) ProtoMessage() {}
 
 func (x *UpdateUserPreferencesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[11]
+	mi := &file_notification_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -955,7 +1744,7 @@ func (x *UpdateUserPreferencesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateUserPreferencesResponse.ProtoReflect.Descriptor instead.
 func (*UpdateUserPreferencesResponse) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{11}
+	return file_notification_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *UpdateUserPreferencesResponse) GetSuccess() bool {
@@ -972,32 +1761,256 @@ func (x *UpdateUserPreferencesResponse) GetMessage() string {
 	return ""
 }
 
-// Notification represents a notification entity
-type Notification struct {
+func (x *UpdateUserPreferencesResponse) GetPreferences() []*UserPreference {
+	if x != nil {
+		return x.Preferences
+	}
+	return nil
+}
+
+// RegisterPushTokenRequest represents a request to register a device push token
+type RegisterPushTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Platform      string                 `protobuf:"bytes,3,opt,name=platform,proto3" json:"platform,omitempty"`                                // ios, android or web
+	PreviousToken string                 `protobuf:"bytes,4,opt,name=previous_token,json=previousToken,proto3" json:"previous_token,omitempty"` // token being replaced, deactivated on registration
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterPushTokenRequest) Reset() {
+	*x = RegisterPushTokenRequest{}
+	mi := &file_notification_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterPushTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterPushTokenRequest) ProtoMessage() {}
+
+func (x *RegisterPushTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterPushTokenRequest.ProtoReflect.Descriptor instead.
+func (*RegisterPushTokenRequest) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RegisterPushTokenRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RegisterPushTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *RegisterPushTokenRequest) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *RegisterPushTokenRequest) GetPreviousToken() string {
+	if x != nil {
+		return x.PreviousToken
+	}
+	return ""
+}
+
+// RegisterPushTokenResponse represents the response for registering a push token
+type RegisterPushTokenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        *UserDevice            `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterPushTokenResponse) Reset() {
+	*x = RegisterPushTokenResponse{}
+	mi := &file_notification_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterPushTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterPushTokenResponse) ProtoMessage() {}
+
+func (x *RegisterPushTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterPushTokenResponse.ProtoReflect.Descriptor instead.
+func (*RegisterPushTokenResponse) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RegisterPushTokenResponse) GetDevice() *UserDevice {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+// UserDevice represents a push token registered for one of a user's devices
+type UserDevice struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Channel       Channel                `protobuf:"varint,3,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
-	Recipient     string                 `protobuf:"bytes,4,opt,name=recipient,proto3" json:"recipient,omitempty"`
-	Subject       string                 `protobuf:"bytes,5,opt,name=subject,proto3" json:"subject,omitempty"`
-	Body          string                 `protobuf:"bytes,6,opt,name=body,proto3" json:"body,omitempty"`
-	Status        NotificationStatus     `protobuf:"varint,7,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
-	ExternalId    string                 `protobuf:"bytes,8,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
-	ErrorMessage  string                 `protobuf:"bytes,9,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
-	RetryCount    int32                  `protobuf:"varint,10,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
-	ScheduledAt   *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
-	SentAt        *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
-	DeliveredAt   *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=delivered_at,json=deliveredAt,proto3" json:"delivered_at,omitempty"`
-	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
-	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
-	Metadata      map[string]string      `protobuf:"bytes,16,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Token         string                 `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	Platform      string                 `protobuf:"bytes,4,opt,name=platform,proto3" json:"platform,omitempty"`
+	Active        bool                   `protobuf:"varint,5,opt,name=active,proto3" json:"active,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *UserDevice) Reset() {
+	*x = UserDevice{}
+	mi := &file_notification_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserDevice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserDevice) ProtoMessage() {}
+
+func (x *UserDevice) ProtoReflect() protoreflect.Message {
+	mi := &file_notification_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserDevice.ProtoReflect.Descriptor instead.
+func (*UserDevice) Descriptor() ([]byte, []int) {
+	return file_notification_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *UserDevice) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UserDevice) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserDevice) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *UserDevice) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *UserDevice) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *UserDevice) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *UserDevice) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// Notification represents a notification entity
+type Notification struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId          string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Channel         Channel                `protobuf:"varint,3,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
+	Recipient       string                 `protobuf:"bytes,4,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Subject         string                 `protobuf:"bytes,5,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body            string                 `protobuf:"bytes,6,opt,name=body,proto3" json:"body,omitempty"`
+	Status          NotificationStatus     `protobuf:"varint,7,opt,name=status,proto3,enum=notification.v1.NotificationStatus" json:"status,omitempty"`
+	ExternalId      string                 `protobuf:"bytes,8,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
+	ErrorMessage    string                 `protobuf:"bytes,9,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	RetryCount      int32                  `protobuf:"varint,10,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
+	ScheduledAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=scheduled_at,json=scheduledAt,proto3" json:"scheduled_at,omitempty"`
+	SentAt          *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
+	DeliveredAt     *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=delivered_at,json=deliveredAt,proto3" json:"delivered_at,omitempty"`
+	CreatedAt       *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt       *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Metadata        map[string]string      `protobuf:"bytes,16,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExpiresAt       *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	GroupId         string                 `protobuf:"bytes,18,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	CollapseKey     string                 `protobuf:"bytes,19,opt,name=collapse_key,json=collapseKey,proto3" json:"collapse_key,omitempty"`
+	Category        string                 `protobuf:"bytes,20,opt,name=category,proto3" json:"category,omitempty"`
+	CallbackUrl     string                 `protobuf:"bytes,21,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	Priority        Priority               `protobuf:"varint,22,opt,name=priority,proto3,enum=notification.v1.Priority" json:"priority,omitempty"`
+	SendWindowStart *timestamppb.Timestamp `protobuf:"bytes,23,opt,name=send_window_start,json=sendWindowStart,proto3" json:"send_window_start,omitempty"`
+	SendWindowEnd   *timestamppb.Timestamp `protobuf:"bytes,24,opt,name=send_window_end,json=sendWindowEnd,proto3" json:"send_window_end,omitempty"`
+	CreatedBy       string                 `protobuf:"bytes,25,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"` // ID of the API key, or admin, that created it
+	Source          string                 `protobuf:"bytes,26,opt,name=source,proto3" json:"source,omitempty"`                        // api, grpc or admin
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *Notification) Reset() {
 	*x = Notification{}
-	mi := &file_notification_proto_msgTypes[12]
+	mi := &file_notification_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1009,7 +2022,7 @@ func (x *Notification) String() string {
 func (*Notification) ProtoMessage() {}
 
 func (x *Notification) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[12]
+	mi := &file_notification_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1022,7 +2035,7 @@ func (x *Notification) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Notification.ProtoReflect.Descriptor instead.
 func (*Notification) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{12}
+	return file_notification_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *Notification) GetId() string {
@@ -1137,23 +2150,96 @@ func (x *Notification) GetMetadata() map[string]string {
 	return nil
 }
 
+func (x *Notification) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Notification) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *Notification) GetCollapseKey() string {
+	if x != nil {
+		return x.CollapseKey
+	}
+	return ""
+}
+
+func (x *Notification) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Notification) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+func (x *Notification) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_UNSPECIFIED
+}
+
+func (x *Notification) GetSendWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendWindowStart
+	}
+	return nil
+}
+
+func (x *Notification) GetSendWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendWindowEnd
+	}
+	return nil
+}
+
+func (x *Notification) GetCreatedBy() string {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return ""
+}
+
+func (x *Notification) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
 // UserPreference represents user notification preferences
 type UserPreference struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Channel       Channel                `protobuf:"varint,3,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
-	Enabled       bool                   `protobuf:"varint,4,opt,name=enabled,proto3" json:"enabled,omitempty"`
-	Frequency     Frequency              `protobuf:"varint,5,opt,name=frequency,proto3,enum=notification.v1.Frequency" json:"frequency,omitempty"`
-	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
-	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId    string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Channel   Channel                `protobuf:"varint,3,opt,name=channel,proto3,enum=notification.v1.Channel" json:"channel,omitempty"`
+	Enabled   bool                   `protobuf:"varint,4,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	Frequency Frequency              `protobuf:"varint,5,opt,name=frequency,proto3,enum=notification.v1.Frequency" json:"frequency,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Locale    string                 `protobuf:"bytes,8,opt,name=locale,proto3" json:"locale,omitempty"`
+	// category limits the preference to transactional or marketing notifications; empty applies to both
+	Category      string `protobuf:"bytes,9,opt,name=category,proto3" json:"category,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UserPreference) Reset() {
 	*x = UserPreference{}
-	mi := &file_notification_proto_msgTypes[13]
+	mi := &file_notification_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1165,7 +2251,7 @@ func (x *UserPreference) String() string {
 func (*UserPreference) ProtoMessage() {}
 
 func (x *UserPreference) ProtoReflect() protoreflect.Message {
-	mi := &file_notification_proto_msgTypes[13]
+	mi := &file_notification_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1178,7 +2264,7 @@ func (x *UserPreference) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserPreference.ProtoReflect.Descriptor instead.
 func (*UserPreference) Descriptor() ([]byte, []int) {
-	return file_notification_proto_rawDescGZIP(), []int{13}
+	return file_notification_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *UserPreference) GetId() string {
@@ -1230,11 +2316,26 @@ func (x *UserPreference) GetUpdatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *UserPreference) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *UserPreference) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
 var File_notification_proto protoreflect.FileDescriptor
 
 const file_notification_proto_rawDesc = "" +
 	"\n" +
-	"\x12notification.proto\x12\x0fnotification.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf0\x04\n" +
+	"\x12notification.proto\x12\x0fnotification.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xbf\n" +
+	"\n" +
 	"\x19CreateNotificationRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x122\n" +
 	"\achannel\x18\x02 \x01(\x0e2\x18.notification.v1.ChannelR\achannel\x12\x1c\n" +
@@ -1246,30 +2347,92 @@ const file_notification_proto_rawDesc = "" +
 	"\btemplate\x18\b \x01(\tR\btemplate\x12W\n" +
 	"\tvariables\x18\t \x03(\v29.notification.v1.CreateNotificationRequest.VariablesEntryR\tvariables\x12T\n" +
 	"\bmetadata\x18\n" +
-	" \x03(\v28.notification.v1.CreateNotificationRequest.MetadataEntryR\bmetadata\x1a<\n" +
+	" \x03(\v28.notification.v1.CreateNotificationRequest.MetadataEntryR\bmetadata\x129\n" +
+	"\n" +
+	"expires_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x1e\n" +
+	"\n" +
+	"recipients\x18\f \x03(\tR\n" +
+	"recipients\x12\x16\n" +
+	"\x06locale\x18\r \x01(\tR\x06locale\x12=\n" +
+	"\vattachments\x18\x0e \x03(\v2\x1b.notification.v1.AttachmentR\vattachments\x12!\n" +
+	"\fcollapse_key\x18\x0f \x01(\tR\vcollapseKey\x12E\n" +
+	"\x11fallback_channels\x18\x10 \x03(\x0e2\x18.notification.v1.ChannelR\x10fallbackChannels\x12\x14\n" +
+	"\x05topic\x18\x11 \x01(\tR\x05topic\x12\x1a\n" +
+	"\bcategory\x18\x12 \x01(\tR\bcategory\x12!\n" +
+	"\fcallback_url\x18\x13 \x01(\tR\vcallbackUrl\x12F\n" +
+	"\x11send_window_start\x18\x14 \x01(\v2\x1a.google.protobuf.TimestampR\x0fsendWindowStart\x12B\n" +
+	"\x0fsend_window_end\x18\x15 \x01(\v2\x1a.google.protobuf.TimestampR\rsendWindowEnd\x12j\n" +
+	"\x10provider_options\x18\x16 \x03(\v2?.notification.v1.CreateNotificationRequest.ProviderOptionsEntryR\x0fproviderOptions\x1a<\n" +
 	"\x0eVariablesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xbe\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1ad\n" +
+	"\x14ProviderOptionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .notification.v1.ProviderOptionsR\x05value:\x028\x01\"\x96\x01\n" +
+	"\x0fProviderOptions\x12G\n" +
+	"\aoptions\x18\x01 \x03(\v2-.notification.v1.ProviderOptions.OptionsEntryR\aoptions\x1a:\n" +
+	"\fOptionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x96\x01\n" +
+	"\n" +
+	"Attachment\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\fR\acontent\x12\x1d\n" +
+	"\n" +
+	"content_id\x18\x04 \x01(\tR\tcontentId\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\"\xf1\x02\n" +
 	"\x1aCreateNotificationResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12;\n" +
 	"\x06status\x18\x02 \x01(\x0e2#.notification.v1.NotificationStatusR\x06status\x12\x18\n" +
 	"\amessage\x18\x03 \x01(\tR\amessage\x129\n" +
 	"\n" +
-	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"(\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x10\n" +
+	"\x03ids\x18\x05 \x03(\tR\x03ids\x12\x19\n" +
+	"\bgroup_id\x18\x06 \x01(\tR\agroupId\x12\x1c\n" +
+	"\ttruncated\x18\a \x03(\tR\ttruncated\x12=\n" +
+	"\fscheduled_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\vscheduledAt\x12'\n" +
+	"\x0fdeferred_reason\x18\t \x01(\tR\x0edeferredReason\"\xaf\x01\n" +
+	" CreateNotificationStreamResponse\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x18\n" +
+	"\acreated\x18\x02 \x01(\x05R\acreated\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\x12C\n" +
+	"\aresults\x18\x04 \x03(\v2).notification.v1.CreateNotificationResultR\aresults\"j\n" +
+	"\x18CreateNotificationResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xc4\x01\n" +
+	"\x1aDryRunNotificationResponse\x12A\n" +
+	"\fnotification\x18\x01 \x01(\v2\x1d.notification.v1.NotificationR\fnotification\x12E\n" +
+	"\x11requested_channel\x18\x02 \x01(\x0e2\x18.notification.v1.ChannelR\x10requestedChannel\x12\x1c\n" +
+	"\tscheduled\x18\x03 \x01(\bR\tscheduled\"(\n" +
 	"\x16GetNotificationRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\"\\\n" +
 	"\x17GetNotificationResponse\x12A\n" +
-	"\fnotification\x18\x01 \x01(\v2\x1d.notification.v1.NotificationR\fnotification\"\xe0\x01\n" +
+	"\fnotification\x18\x01 \x01(\v2\x1d.notification.v1.NotificationR\fnotification\".\n" +
+	"\x1cGetNotificationStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xc8\x01\n" +
+	"\x1dGetNotificationStatusResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12;\n" +
+	"\x06status\x18\x02 \x01(\x0e2#.notification.v1.NotificationStatusR\x06status\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x1f\n" +
+	"\vexternal_id\x18\x04 \x01(\tR\n" +
+	"externalId\"\x97\x02\n" +
 	"\x18ListNotificationsRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x122\n" +
 	"\achannel\x18\x02 \x01(\x0e2\x18.notification.v1.ChannelR\achannel\x12;\n" +
 	"\x06status\x18\x03 \x01(\x0e2#.notification.v1.NotificationStatusR\x06status\x12\x1b\n" +
 	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1d\n" +
 	"\n" +
-	"page_token\x18\x05 \x01(\tR\tpageToken\"\xa9\x01\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\x12\x1d\n" +
+	"\n" +
+	"created_by\x18\x06 \x01(\tR\tcreatedBy\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\"\xa9\x01\n" +
 	"\x19ListNotificationsResponse\x12C\n" +
 	"\rnotifications\x18\x01 \x03(\v2\x1d.notification.v1.NotificationR\rnotifications\x12&\n" +
 	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\x12\x1f\n" +
@@ -1283,17 +2446,46 @@ const file_notification_proto_rawDesc = "" +
 	"\rerror_message\x18\x04 \x01(\tR\ferrorMessage\"V\n" +
 	" UpdateNotificationStatusResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"4\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"r\n" +
+	"$UpdateNotificationStatusBatchRequest\x12J\n" +
+	"\aupdates\x18\x01 \x03(\v20.notification.v1.UpdateNotificationStatusRequestR\aupdates\"w\n" +
+	"\x12StatusUpdateResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12#\n" +
+	"\rerror_message\x18\x04 \x01(\tR\ferrorMessage\"\x8b\x01\n" +
+	"%UpdateNotificationStatusBatchResponse\x12=\n" +
+	"\aresults\x18\x01 \x03(\v2#.notification.v1.StatusUpdateResultR\aresults\x12#\n" +
+	"\rupdated_count\x18\x02 \x01(\x05R\fupdatedCount\"4\n" +
 	"\x19GetUserPreferencesRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\"_\n" +
 	"\x1aGetUserPreferencesResponse\x12A\n" +
 	"\vpreferences\x18\x01 \x03(\v2\x1f.notification.v1.UserPreferenceR\vpreferences\"z\n" +
 	"\x1cUpdateUserPreferencesRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12A\n" +
-	"\vpreferences\x18\x02 \x03(\v2\x1f.notification.v1.UserPreferenceR\vpreferences\"S\n" +
+	"\vpreferences\x18\x02 \x03(\v2\x1f.notification.v1.UserPreferenceR\vpreferences\"\x96\x01\n" +
 	"\x1dUpdateUserPreferencesResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"\x8a\x06\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12A\n" +
+	"\vpreferences\x18\x03 \x03(\v2\x1f.notification.v1.UserPreferenceR\vpreferences\"\x8c\x01\n" +
+	"\x18RegisterPushTokenRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x1a\n" +
+	"\bplatform\x18\x03 \x01(\tR\bplatform\x12%\n" +
+	"\x0eprevious_token\x18\x04 \x01(\tR\rpreviousToken\"P\n" +
+	"\x19RegisterPushTokenResponse\x123\n" +
+	"\x06device\x18\x01 \x01(\v2\x1b.notification.v1.UserDeviceR\x06device\"\xf5\x01\n" +
+	"\n" +
+	"UserDevice\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\x12\x1a\n" +
+	"\bplatform\x18\x04 \x01(\tR\bplatform\x12\x16\n" +
+	"\x06active\x18\x05 \x01(\bR\x06active\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\xbc\t\n" +
 	"\fNotification\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x122\n" +
@@ -1315,10 +2507,22 @@ const file_notification_proto_rawDesc = "" +
 	"created_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
 	"\n" +
 	"updated_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12G\n" +
-	"\bmetadata\x18\x10 \x03(\v2+.notification.v1.Notification.MetadataEntryR\bmetadata\x1a;\n" +
+	"\bmetadata\x18\x10 \x03(\v2+.notification.v1.Notification.MetadataEntryR\bmetadata\x129\n" +
+	"\n" +
+	"expires_at\x18\x11 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x19\n" +
+	"\bgroup_id\x18\x12 \x01(\tR\agroupId\x12!\n" +
+	"\fcollapse_key\x18\x13 \x01(\tR\vcollapseKey\x12\x1a\n" +
+	"\bcategory\x18\x14 \x01(\tR\bcategory\x12!\n" +
+	"\fcallback_url\x18\x15 \x01(\tR\vcallbackUrl\x125\n" +
+	"\bpriority\x18\x16 \x01(\x0e2\x19.notification.v1.PriorityR\bpriority\x12F\n" +
+	"\x11send_window_start\x18\x17 \x01(\v2\x1a.google.protobuf.TimestampR\x0fsendWindowStart\x12B\n" +
+	"\x0fsend_window_end\x18\x18 \x01(\v2\x1a.google.protobuf.TimestampR\rsendWindowEnd\x12\x1d\n" +
+	"\n" +
+	"created_by\x18\x19 \x01(\tR\tcreatedBy\x12\x16\n" +
+	"\x06source\x18\x1a \x01(\tR\x06source\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb7\x02\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xeb\x02\n" +
 	"\x0eUserPreference\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x122\n" +
@@ -1328,19 +2532,22 @@ const file_notification_proto_rawDesc = "" +
 	"\n" +
 	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
 	"\n" +
-	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt*X\n" +
+	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x16\n" +
+	"\x06locale\x18\b \x01(\tR\x06locale\x12\x1a\n" +
+	"\bcategory\x18\t \x01(\tR\bcategory*X\n" +
 	"\aChannel\x12\x17\n" +
 	"\x13CHANNEL_UNSPECIFIED\x10\x00\x12\x11\n" +
 	"\rCHANNEL_EMAIL\x10\x01\x12\x0f\n" +
 	"\vCHANNEL_SMS\x10\x02\x12\x10\n" +
-	"\fCHANNEL_PUSH\x10\x03*\xde\x01\n" +
+	"\fCHANNEL_PUSH\x10\x03*\xff\x01\n" +
 	"\x12NotificationStatus\x12#\n" +
 	"\x1fNOTIFICATION_STATUS_UNSPECIFIED\x10\x00\x12\x1f\n" +
 	"\x1bNOTIFICATION_STATUS_PENDING\x10\x01\x12\x1c\n" +
 	"\x18NOTIFICATION_STATUS_SENT\x10\x02\x12!\n" +
 	"\x1dNOTIFICATION_STATUS_DELIVERED\x10\x03\x12\x1e\n" +
 	"\x1aNOTIFICATION_STATUS_FAILED\x10\x04\x12!\n" +
-	"\x1dNOTIFICATION_STATUS_CANCELLED\x10\x05*^\n" +
+	"\x1dNOTIFICATION_STATUS_CANCELLED\x10\x05\x12\x1f\n" +
+	"\x1bNOTIFICATION_STATUS_UNKNOWN\x10\x06*^\n" +
 	"\bPriority\x12\x18\n" +
 	"\x14PRIORITY_UNSPECIFIED\x10\x00\x12\x10\n" +
 	"\fPRIORITY_LOW\x10\x01\x12\x13\n" +
@@ -1350,14 +2557,19 @@ const file_notification_proto_rawDesc = "" +
 	"\x15FREQUENCY_UNSPECIFIED\x10\x00\x12\x17\n" +
 	"\x13FREQUENCY_IMMEDIATE\x10\x01\x12\x14\n" +
 	"\x10FREQUENCY_HOURLY\x10\x02\x12\x13\n" +
-	"\x0fFREQUENCY_DAILY\x10\x032\xbe\x05\n" +
-	"\x13NotificationService\x12m\n" +
-	"\x12CreateNotification\x12*.notification.v1.CreateNotificationRequest\x1a+.notification.v1.CreateNotificationResponse\x12d\n" +
-	"\x0fGetNotification\x12'.notification.v1.GetNotificationRequest\x1a(.notification.v1.GetNotificationResponse\x12j\n" +
-	"\x11ListNotifications\x12).notification.v1.ListNotificationsRequest\x1a*.notification.v1.ListNotificationsResponse\x12\x7f\n" +
-	"\x18UpdateNotificationStatus\x120.notification.v1.UpdateNotificationStatusRequest\x1a1.notification.v1.UpdateNotificationStatusResponse\x12m\n" +
-	"\x12GetUserPreferences\x12*.notification.v1.GetUserPreferencesRequest\x1a+.notification.v1.GetUserPreferencesResponse\x12v\n" +
-	"\x15UpdateUserPreferences\x12-.notification.v1.UpdateUserPreferencesRequest\x1a..notification.v1.UpdateUserPreferencesResponseBWZUgithub.com/alexnthnz/notification-system/api/proto/gen/notification/v1;notificationv1b\x06proto3"
+	"\x0fFREQUENCY_DAILY\x10\x032\xa4\r\n" +
+	"\x13NotificationService\x12\x8b\x01\n" +
+	"\x12CreateNotification\x12*.notification.v1.CreateNotificationRequest\x1a+.notification.v1.CreateNotificationResponse\"\x1c\x82\xd3\xe4\x93\x02\x16:\x01*\"\x11/v1/notifications\x12{\n" +
+	"\x18CreateNotificationStream\x12*.notification.v1.CreateNotificationRequest\x1a1.notification.v1.CreateNotificationStreamResponse(\x01\x12\x94\x01\n" +
+	"\x12DryRunNotification\x12*.notification.v1.CreateNotificationRequest\x1a+.notification.v1.DryRunNotificationResponse\"%\x82\xd3\xe4\x93\x02\x1f:\x01*\"\x1a/v1/notifications:validate\x12\x84\x01\n" +
+	"\x0fGetNotification\x12'.notification.v1.GetNotificationRequest\x1a(.notification.v1.GetNotificationResponse\"\x1e\x82\xd3\xe4\x93\x02\x18\x12\x16/v1/notifications/{id}\x12\x9d\x01\n" +
+	"\x15GetNotificationStatus\x12-.notification.v1.GetNotificationStatusRequest\x1a..notification.v1.GetNotificationStatusResponse\"%\x82\xd3\xe4\x93\x02\x1f\x12\x1d/v1/notifications/{id}/status\x12\x85\x01\n" +
+	"\x11ListNotifications\x12).notification.v1.ListNotificationsRequest\x1a*.notification.v1.ListNotificationsResponse\"\x19\x82\xd3\xe4\x93\x02\x13\x12\x11/v1/notifications\x12\xa9\x01\n" +
+	"\x18UpdateNotificationStatus\x120.notification.v1.UpdateNotificationStatusRequest\x1a1.notification.v1.UpdateNotificationStatusResponse\"(\x82\xd3\xe4\x93\x02\":\x01*2\x1d/v1/notifications/{id}/status\x12\xb9\x01\n" +
+	"\x1dUpdateNotificationStatusBatch\x125.notification.v1.UpdateNotificationStatusBatchRequest\x1a6.notification.v1.UpdateNotificationStatusBatchResponse\")\x82\xd3\xe4\x93\x02#:\x01*\"\x1e/v1/notifications/status:batch\x12\x96\x01\n" +
+	"\x12GetUserPreferences\x12*.notification.v1.GetUserPreferencesRequest\x1a+.notification.v1.GetUserPreferencesResponse\"'\x82\xd3\xe4\x93\x02!\x12\x1f/v1/users/{user_id}/preferences\x12\xa2\x01\n" +
+	"\x15UpdateUserPreferences\x12-.notification.v1.UpdateUserPreferencesRequest\x1a..notification.v1.UpdateUserPreferencesResponse\"*\x82\xd3\xe4\x93\x02$:\x01*\x1a\x1f/v1/users/{user_id}/preferences\x12\x95\x01\n" +
+	"\x11RegisterPushToken\x12).notification.v1.RegisterPushTokenRequest\x1a*.notification.v1.RegisterPushTokenResponse\")\x82\xd3\xe4\x93\x02#:\x01*\x1a\x1e/v1/users/{user_id}/push-tokenBWZUgithub.com/alexnthnz/notification-system/api/proto/gen/notification/v1;notificationv1b\x06proto3"
 
 var (
 	file_notification_proto_rawDescOnce sync.Once
@@ -1372,75 +2584,124 @@ func file_notification_proto_rawDescGZIP() []byte {
 }
 
 var file_notification_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
-var file_notification_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_notification_proto_msgTypes = make([]protoimpl.MessageInfo, 32)
 var file_notification_proto_goTypes = []any{
-	(Channel)(0),                             // 0: notification.v1.Channel
-	(NotificationStatus)(0),                  // 1: notification.v1.NotificationStatus
-	(Priority)(0),                            // 2: notification.v1.Priority
-	(Frequency)(0),                           // 3: notification.v1.Frequency
-	(*CreateNotificationRequest)(nil),        // 4: notification.v1.CreateNotificationRequest
-	(*CreateNotificationResponse)(nil),       // 5: notification.v1.CreateNotificationResponse
-	(*GetNotificationRequest)(nil),           // 6: notification.v1.GetNotificationRequest
-	(*GetNotificationResponse)(nil),          // 7: notification.v1.GetNotificationResponse
-	(*ListNotificationsRequest)(nil),         // 8: notification.v1.ListNotificationsRequest
-	(*ListNotificationsResponse)(nil),        // 9: notification.v1.ListNotificationsResponse
-	(*UpdateNotificationStatusRequest)(nil),  // 10: notification.v1.UpdateNotificationStatusRequest
-	(*UpdateNotificationStatusResponse)(nil), // 11: notification.v1.UpdateNotificationStatusResponse
-	(*GetUserPreferencesRequest)(nil),        // 12: notification.v1.GetUserPreferencesRequest
-	(*GetUserPreferencesResponse)(nil),       // 13: notification.v1.GetUserPreferencesResponse
-	(*UpdateUserPreferencesRequest)(nil),     // 14: notification.v1.UpdateUserPreferencesRequest
-	(*UpdateUserPreferencesResponse)(nil),    // 15: notification.v1.UpdateUserPreferencesResponse
-	(*Notification)(nil),                     // 16: notification.v1.Notification
-	(*UserPreference)(nil),                   // 17: notification.v1.UserPreference
-	nil,                                      // 18: notification.v1.CreateNotificationRequest.VariablesEntry
-	nil,                                      // 19: notification.v1.CreateNotificationRequest.MetadataEntry
-	nil,                                      // 20: notification.v1.Notification.MetadataEntry
-	(*timestamppb.Timestamp)(nil),            // 21: google.protobuf.Timestamp
+	(Channel)(0),                                  // 0: notification.v1.Channel
+	(NotificationStatus)(0),                       // 1: notification.v1.NotificationStatus
+	(Priority)(0),                                 // 2: notification.v1.Priority
+	(Frequency)(0),                                // 3: notification.v1.Frequency
+	(*CreateNotificationRequest)(nil),             // 4: notification.v1.CreateNotificationRequest
+	(*ProviderOptions)(nil),                       // 5: notification.v1.ProviderOptions
+	(*Attachment)(nil),                            // 6: notification.v1.Attachment
+	(*CreateNotificationResponse)(nil),            // 7: notification.v1.CreateNotificationResponse
+	(*CreateNotificationStreamResponse)(nil),      // 8: notification.v1.CreateNotificationStreamResponse
+	(*CreateNotificationResult)(nil),              // 9: notification.v1.CreateNotificationResult
+	(*DryRunNotificationResponse)(nil),            // 10: notification.v1.DryRunNotificationResponse
+	(*GetNotificationRequest)(nil),                // 11: notification.v1.GetNotificationRequest
+	(*GetNotificationResponse)(nil),               // 12: notification.v1.GetNotificationResponse
+	(*GetNotificationStatusRequest)(nil),          // 13: notification.v1.GetNotificationStatusRequest
+	(*GetNotificationStatusResponse)(nil),         // 14: notification.v1.GetNotificationStatusResponse
+	(*ListNotificationsRequest)(nil),              // 15: notification.v1.ListNotificationsRequest
+	(*ListNotificationsResponse)(nil),             // 16: notification.v1.ListNotificationsResponse
+	(*UpdateNotificationStatusRequest)(nil),       // 17: notification.v1.UpdateNotificationStatusRequest
+	(*UpdateNotificationStatusResponse)(nil),      // 18: notification.v1.UpdateNotificationStatusResponse
+	(*UpdateNotificationStatusBatchRequest)(nil),  // 19: notification.v1.UpdateNotificationStatusBatchRequest
+	(*StatusUpdateResult)(nil),                    // 20: notification.v1.StatusUpdateResult
+	(*UpdateNotificationStatusBatchResponse)(nil), // 21: notification.v1.UpdateNotificationStatusBatchResponse
+	(*GetUserPreferencesRequest)(nil),             // 22: notification.v1.GetUserPreferencesRequest
+	(*GetUserPreferencesResponse)(nil),            // 23: notification.v1.GetUserPreferencesResponse
+	(*UpdateUserPreferencesRequest)(nil),          // 24: notification.v1.UpdateUserPreferencesRequest
+	(*UpdateUserPreferencesResponse)(nil),         // 25: notification.v1.UpdateUserPreferencesResponse
+	(*RegisterPushTokenRequest)(nil),              // 26: notification.v1.RegisterPushTokenRequest
+	(*RegisterPushTokenResponse)(nil),             // 27: notification.v1.RegisterPushTokenResponse
+	(*UserDevice)(nil),                            // 28: notification.v1.UserDevice
+	(*Notification)(nil),                          // 29: notification.v1.Notification
+	(*UserPreference)(nil),                        // 30: notification.v1.UserPreference
+	nil,                                           // 31: notification.v1.CreateNotificationRequest.VariablesEntry
+	nil,                                           // 32: notification.v1.CreateNotificationRequest.MetadataEntry
+	nil,                                           // 33: notification.v1.CreateNotificationRequest.ProviderOptionsEntry
+	nil,                                           // 34: notification.v1.ProviderOptions.OptionsEntry
+	nil,                                           // 35: notification.v1.Notification.MetadataEntry
+	(*timestamppb.Timestamp)(nil),                 // 36: google.protobuf.Timestamp
 }
 var file_notification_proto_depIdxs = []int32{
 	0,  // 0: notification.v1.CreateNotificationRequest.channel:type_name -> notification.v1.Channel
 	2,  // 1: notification.v1.CreateNotificationRequest.priority:type_name -> notification.v1.Priority
-	21, // 2: notification.v1.CreateNotificationRequest.scheduled_at:type_name -> google.protobuf.Timestamp
-	18, // 3: notification.v1.CreateNotificationRequest.variables:type_name -> notification.v1.CreateNotificationRequest.VariablesEntry
-	19, // 4: notification.v1.CreateNotificationRequest.metadata:type_name -> notification.v1.CreateNotificationRequest.MetadataEntry
-	1,  // 5: notification.v1.CreateNotificationResponse.status:type_name -> notification.v1.NotificationStatus
-	21, // 6: notification.v1.CreateNotificationResponse.created_at:type_name -> google.protobuf.Timestamp
-	16, // 7: notification.v1.GetNotificationResponse.notification:type_name -> notification.v1.Notification
-	0,  // 8: notification.v1.ListNotificationsRequest.channel:type_name -> notification.v1.Channel
-	1,  // 9: notification.v1.ListNotificationsRequest.status:type_name -> notification.v1.NotificationStatus
-	16, // 10: notification.v1.ListNotificationsResponse.notifications:type_name -> notification.v1.Notification
-	1,  // 11: notification.v1.UpdateNotificationStatusRequest.status:type_name -> notification.v1.NotificationStatus
-	17, // 12: notification.v1.GetUserPreferencesResponse.preferences:type_name -> notification.v1.UserPreference
-	17, // 13: notification.v1.UpdateUserPreferencesRequest.preferences:type_name -> notification.v1.UserPreference
-	0,  // 14: notification.v1.Notification.channel:type_name -> notification.v1.Channel
-	1,  // 15: notification.v1.Notification.status:type_name -> notification.v1.NotificationStatus
-	21, // 16: notification.v1.Notification.scheduled_at:type_name -> google.protobuf.Timestamp
-	21, // 17: notification.v1.Notification.sent_at:type_name -> google.protobuf.Timestamp
-	21, // 18: notification.v1.Notification.delivered_at:type_name -> google.protobuf.Timestamp
-	21, // 19: notification.v1.Notification.created_at:type_name -> google.protobuf.Timestamp
-	21, // 20: notification.v1.Notification.updated_at:type_name -> google.protobuf.Timestamp
-	20, // 21: notification.v1.Notification.metadata:type_name -> notification.v1.Notification.MetadataEntry
-	0,  // 22: notification.v1.UserPreference.channel:type_name -> notification.v1.Channel
-	3,  // 23: notification.v1.UserPreference.frequency:type_name -> notification.v1.Frequency
-	21, // 24: notification.v1.UserPreference.created_at:type_name -> google.protobuf.Timestamp
-	21, // 25: notification.v1.UserPreference.updated_at:type_name -> google.protobuf.Timestamp
-	4,  // 26: notification.v1.NotificationService.CreateNotification:input_type -> notification.v1.CreateNotificationRequest
-	6,  // 27: notification.v1.NotificationService.GetNotification:input_type -> notification.v1.GetNotificationRequest
-	8,  // 28: notification.v1.NotificationService.ListNotifications:input_type -> notification.v1.ListNotificationsRequest
-	10, // 29: notification.v1.NotificationService.UpdateNotificationStatus:input_type -> notification.v1.UpdateNotificationStatusRequest
-	12, // 30: notification.v1.NotificationService.GetUserPreferences:input_type -> notification.v1.GetUserPreferencesRequest
-	14, // 31: notification.v1.NotificationService.UpdateUserPreferences:input_type -> notification.v1.UpdateUserPreferencesRequest
-	5,  // 32: notification.v1.NotificationService.CreateNotification:output_type -> notification.v1.CreateNotificationResponse
-	7,  // 33: notification.v1.NotificationService.GetNotification:output_type -> notification.v1.GetNotificationResponse
-	9,  // 34: notification.v1.NotificationService.ListNotifications:output_type -> notification.v1.ListNotificationsResponse
-	11, // 35: notification.v1.NotificationService.UpdateNotificationStatus:output_type -> notification.v1.UpdateNotificationStatusResponse
-	13, // 36: notification.v1.NotificationService.GetUserPreferences:output_type -> notification.v1.GetUserPreferencesResponse
-	15, // 37: notification.v1.NotificationService.UpdateUserPreferences:output_type -> notification.v1.UpdateUserPreferencesResponse
-	32, // [32:38] is the sub-list for method output_type
-	26, // [26:32] is the sub-list for method input_type
-	26, // [26:26] is the sub-list for extension type_name
-	26, // [26:26] is the sub-list for extension extendee
-	0,  // [0:26] is the sub-list for field type_name
+	36, // 2: notification.v1.CreateNotificationRequest.scheduled_at:type_name -> google.protobuf.Timestamp
+	31, // 3: notification.v1.CreateNotificationRequest.variables:type_name -> notification.v1.CreateNotificationRequest.VariablesEntry
+	32, // 4: notification.v1.CreateNotificationRequest.metadata:type_name -> notification.v1.CreateNotificationRequest.MetadataEntry
+	36, // 5: notification.v1.CreateNotificationRequest.expires_at:type_name -> google.protobuf.Timestamp
+	6,  // 6: notification.v1.CreateNotificationRequest.attachments:type_name -> notification.v1.Attachment
+	0,  // 7: notification.v1.CreateNotificationRequest.fallback_channels:type_name -> notification.v1.Channel
+	36, // 8: notification.v1.CreateNotificationRequest.send_window_start:type_name -> google.protobuf.Timestamp
+	36, // 9: notification.v1.CreateNotificationRequest.send_window_end:type_name -> google.protobuf.Timestamp
+	33, // 10: notification.v1.CreateNotificationRequest.provider_options:type_name -> notification.v1.CreateNotificationRequest.ProviderOptionsEntry
+	34, // 11: notification.v1.ProviderOptions.options:type_name -> notification.v1.ProviderOptions.OptionsEntry
+	1,  // 12: notification.v1.CreateNotificationResponse.status:type_name -> notification.v1.NotificationStatus
+	36, // 13: notification.v1.CreateNotificationResponse.created_at:type_name -> google.protobuf.Timestamp
+	36, // 14: notification.v1.CreateNotificationResponse.scheduled_at:type_name -> google.protobuf.Timestamp
+	9,  // 15: notification.v1.CreateNotificationStreamResponse.results:type_name -> notification.v1.CreateNotificationResult
+	29, // 16: notification.v1.DryRunNotificationResponse.notification:type_name -> notification.v1.Notification
+	0,  // 17: notification.v1.DryRunNotificationResponse.requested_channel:type_name -> notification.v1.Channel
+	29, // 18: notification.v1.GetNotificationResponse.notification:type_name -> notification.v1.Notification
+	1,  // 19: notification.v1.GetNotificationStatusResponse.status:type_name -> notification.v1.NotificationStatus
+	36, // 20: notification.v1.GetNotificationStatusResponse.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 21: notification.v1.ListNotificationsRequest.channel:type_name -> notification.v1.Channel
+	1,  // 22: notification.v1.ListNotificationsRequest.status:type_name -> notification.v1.NotificationStatus
+	29, // 23: notification.v1.ListNotificationsResponse.notifications:type_name -> notification.v1.Notification
+	1,  // 24: notification.v1.UpdateNotificationStatusRequest.status:type_name -> notification.v1.NotificationStatus
+	17, // 25: notification.v1.UpdateNotificationStatusBatchRequest.updates:type_name -> notification.v1.UpdateNotificationStatusRequest
+	20, // 26: notification.v1.UpdateNotificationStatusBatchResponse.results:type_name -> notification.v1.StatusUpdateResult
+	30, // 27: notification.v1.GetUserPreferencesResponse.preferences:type_name -> notification.v1.UserPreference
+	30, // 28: notification.v1.UpdateUserPreferencesRequest.preferences:type_name -> notification.v1.UserPreference
+	30, // 29: notification.v1.UpdateUserPreferencesResponse.preferences:type_name -> notification.v1.UserPreference
+	28, // 30: notification.v1.RegisterPushTokenResponse.device:type_name -> notification.v1.UserDevice
+	36, // 31: notification.v1.UserDevice.created_at:type_name -> google.protobuf.Timestamp
+	36, // 32: notification.v1.UserDevice.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 33: notification.v1.Notification.channel:type_name -> notification.v1.Channel
+	1,  // 34: notification.v1.Notification.status:type_name -> notification.v1.NotificationStatus
+	36, // 35: notification.v1.Notification.scheduled_at:type_name -> google.protobuf.Timestamp
+	36, // 36: notification.v1.Notification.sent_at:type_name -> google.protobuf.Timestamp
+	36, // 37: notification.v1.Notification.delivered_at:type_name -> google.protobuf.Timestamp
+	36, // 38: notification.v1.Notification.created_at:type_name -> google.protobuf.Timestamp
+	36, // 39: notification.v1.Notification.updated_at:type_name -> google.protobuf.Timestamp
+	35, // 40: notification.v1.Notification.metadata:type_name -> notification.v1.Notification.MetadataEntry
+	36, // 41: notification.v1.Notification.expires_at:type_name -> google.protobuf.Timestamp
+	2,  // 42: notification.v1.Notification.priority:type_name -> notification.v1.Priority
+	36, // 43: notification.v1.Notification.send_window_start:type_name -> google.protobuf.Timestamp
+	36, // 44: notification.v1.Notification.send_window_end:type_name -> google.protobuf.Timestamp
+	0,  // 45: notification.v1.UserPreference.channel:type_name -> notification.v1.Channel
+	3,  // 46: notification.v1.UserPreference.frequency:type_name -> notification.v1.Frequency
+	36, // 47: notification.v1.UserPreference.created_at:type_name -> google.protobuf.Timestamp
+	36, // 48: notification.v1.UserPreference.updated_at:type_name -> google.protobuf.Timestamp
+	5,  // 49: notification.v1.CreateNotificationRequest.ProviderOptionsEntry.value:type_name -> notification.v1.ProviderOptions
+	4,  // 50: notification.v1.NotificationService.CreateNotification:input_type -> notification.v1.CreateNotificationRequest
+	4,  // 51: notification.v1.NotificationService.CreateNotificationStream:input_type -> notification.v1.CreateNotificationRequest
+	4,  // 52: notification.v1.NotificationService.DryRunNotification:input_type -> notification.v1.CreateNotificationRequest
+	11, // 53: notification.v1.NotificationService.GetNotification:input_type -> notification.v1.GetNotificationRequest
+	13, // 54: notification.v1.NotificationService.GetNotificationStatus:input_type -> notification.v1.GetNotificationStatusRequest
+	15, // 55: notification.v1.NotificationService.ListNotifications:input_type -> notification.v1.ListNotificationsRequest
+	17, // 56: notification.v1.NotificationService.UpdateNotificationStatus:input_type -> notification.v1.UpdateNotificationStatusRequest
+	19, // 57: notification.v1.NotificationService.UpdateNotificationStatusBatch:input_type -> notification.v1.UpdateNotificationStatusBatchRequest
+	22, // 58: notification.v1.NotificationService.GetUserPreferences:input_type -> notification.v1.GetUserPreferencesRequest
+	24, // 59: notification.v1.NotificationService.UpdateUserPreferences:input_type -> notification.v1.UpdateUserPreferencesRequest
+	26, // 60: notification.v1.NotificationService.RegisterPushToken:input_type -> notification.v1.RegisterPushTokenRequest
+	7,  // 61: notification.v1.NotificationService.CreateNotification:output_type -> notification.v1.CreateNotificationResponse
+	8,  // 62: notification.v1.NotificationService.CreateNotificationStream:output_type -> notification.v1.CreateNotificationStreamResponse
+	10, // 63: notification.v1.NotificationService.DryRunNotification:output_type -> notification.v1.DryRunNotificationResponse
+	12, // 64: notification.v1.NotificationService.GetNotification:output_type -> notification.v1.GetNotificationResponse
+	14, // 65: notification.v1.NotificationService.GetNotificationStatus:output_type -> notification.v1.GetNotificationStatusResponse
+	16, // 66: notification.v1.NotificationService.ListNotifications:output_type -> notification.v1.ListNotificationsResponse
+	18, // 67: notification.v1.NotificationService.UpdateNotificationStatus:output_type -> notification.v1.UpdateNotificationStatusResponse
+	21, // 68: notification.v1.NotificationService.UpdateNotificationStatusBatch:output_type -> notification.v1.UpdateNotificationStatusBatchResponse
+	23, // 69: notification.v1.NotificationService.GetUserPreferences:output_type -> notification.v1.GetUserPreferencesResponse
+	25, // 70: notification.v1.NotificationService.UpdateUserPreferences:output_type -> notification.v1.UpdateUserPreferencesResponse
+	27, // 71: notification.v1.NotificationService.RegisterPushToken:output_type -> notification.v1.RegisterPushTokenResponse
+	61, // [61:72] is the sub-list for method output_type
+	50, // [50:61] is the sub-list for method input_type
+	50, // [50:50] is the sub-list for extension type_name
+	50, // [50:50] is the sub-list for extension extendee
+	0,  // [0:50] is the sub-list for field type_name
 }
 
 func init() { file_notification_proto_init() }
@@ -1454,7 +2715,7 @@ func file_notification_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_notification_proto_rawDesc), len(file_notification_proto_rawDesc)),
 			NumEnums:      4,
-			NumMessages:   17,
+			NumMessages:   32,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,12 +19,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	NotificationService_CreateNotification_FullMethodName       = "/notification.v1.NotificationService/CreateNotification"
-	NotificationService_GetNotification_FullMethodName          = "/notification.v1.NotificationService/GetNotification"
-	NotificationService_ListNotifications_FullMethodName        = "/notification.v1.NotificationService/ListNotifications"
-	NotificationService_UpdateNotificationStatus_FullMethodName = "/notification.v1.NotificationService/UpdateNotificationStatus"
-	NotificationService_GetUserPreferences_FullMethodName       = "/notification.v1.NotificationService/GetUserPreferences"
-	NotificationService_UpdateUserPreferences_FullMethodName    = "/notification.v1.NotificationService/UpdateUserPreferences"
+	NotificationService_CreateNotification_FullMethodName            = "/notification.v1.NotificationService/CreateNotification"
+	NotificationService_CreateNotificationStream_FullMethodName      = "/notification.v1.NotificationService/CreateNotificationStream"
+	NotificationService_DryRunNotification_FullMethodName            = "/notification.v1.NotificationService/DryRunNotification"
+	NotificationService_GetNotification_FullMethodName               = "/notification.v1.NotificationService/GetNotification"
+	NotificationService_GetNotificationStatus_FullMethodName         = "/notification.v1.NotificationService/GetNotificationStatus"
+	NotificationService_ListNotifications_FullMethodName             = "/notification.v1.NotificationService/ListNotifications"
+	NotificationService_UpdateNotificationStatus_FullMethodName      = "/notification.v1.NotificationService/UpdateNotificationStatus"
+	NotificationService_UpdateNotificationStatusBatch_FullMethodName = "/notification.v1.NotificationService/UpdateNotificationStatusBatch"
+	NotificationService_GetUserPreferences_FullMethodName            = "/notification.v1.NotificationService/GetUserPreferences"
+	NotificationService_UpdateUserPreferences_FullMethodName         = "/notification.v1.NotificationService/UpdateUserPreferences"
+	NotificationService_RegisterPushToken_FullMethodName             = "/notification.v1.NotificationService/RegisterPushToken"
 )
 
 // NotificationServiceClient is the client API for NotificationService service.
@@ -35,16 +40,30 @@ const (
 type NotificationServiceClient interface {
 	// CreateNotification creates a new notification
 	CreateNotification(ctx context.Context, in *CreateNotificationRequest, opts ...grpc.CallOption) (*CreateNotificationResponse, error)
+	// CreateNotificationStream creates the notifications streamed by the client,
+	// in batches, and returns a summary once the client closes the stream.
+	// Fan-out to several recipients and topic broadcasts are not supported.
+	CreateNotificationStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[CreateNotificationRequest, CreateNotificationStreamResponse], error)
+	// DryRunNotification runs the checks CreateNotification would run and
+	// returns the resolved notification, without storing or queueing it.
+	// Fan-out to several recipients and topic broadcasts are not supported.
+	DryRunNotification(ctx context.Context, in *CreateNotificationRequest, opts ...grpc.CallOption) (*DryRunNotificationResponse, error)
 	// GetNotification retrieves a notification by ID
 	GetNotification(ctx context.Context, in *GetNotificationRequest, opts ...grpc.CallOption) (*GetNotificationResponse, error)
+	// GetNotificationStatus retrieves only the delivery status of a notification
+	GetNotificationStatus(ctx context.Context, in *GetNotificationStatusRequest, opts ...grpc.CallOption) (*GetNotificationStatusResponse, error)
 	// ListNotifications lists notifications for a user
 	ListNotifications(ctx context.Context, in *ListNotificationsRequest, opts ...grpc.CallOption) (*ListNotificationsResponse, error)
 	// UpdateNotificationStatus updates the status of a notification
 	UpdateNotificationStatus(ctx context.Context, in *UpdateNotificationStatusRequest, opts ...grpc.CallOption) (*UpdateNotificationStatusResponse, error)
+	// UpdateNotificationStatusBatch updates the status of many notifications at once
+	UpdateNotificationStatusBatch(ctx context.Context, in *UpdateNotificationStatusBatchRequest, opts ...grpc.CallOption) (*UpdateNotificationStatusBatchResponse, error)
 	// GetUserPreferences retrieves user notification preferences
 	GetUserPreferences(ctx context.Context, in *GetUserPreferencesRequest, opts ...grpc.CallOption) (*GetUserPreferencesResponse, error)
 	// UpdateUserPreferences updates user notification preferences
 	UpdateUserPreferences(ctx context.Context, in *UpdateUserPreferencesRequest, opts ...grpc.CallOption) (*UpdateUserPreferencesResponse, error)
+	// RegisterPushToken registers or refreshes a push token for one of a user's devices
+	RegisterPushToken(ctx context.Context, in *RegisterPushTokenRequest, opts ...grpc.CallOption) (*RegisterPushTokenResponse, error)
 }
 
 type notificationServiceClient struct {
@@ -65,6 +84,29 @@ func (c *notificationServiceClient) CreateNotification(ctx context.Context, in *
 	return out, nil
 }
 
+func (c *notificationServiceClient) CreateNotificationStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[CreateNotificationRequest, CreateNotificationStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotificationService_ServiceDesc.Streams[0], NotificationService_CreateNotificationStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CreateNotificationRequest, CreateNotificationStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_CreateNotificationStreamClient = grpc.ClientStreamingClient[CreateNotificationRequest, CreateNotificationStreamResponse]
+
+func (c *notificationServiceClient) DryRunNotification(ctx context.Context, in *CreateNotificationRequest, opts ...grpc.CallOption) (*DryRunNotificationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DryRunNotificationResponse)
+	err := c.cc.Invoke(ctx, NotificationService_DryRunNotification_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *notificationServiceClient) GetNotification(ctx context.Context, in *GetNotificationRequest, opts ...grpc.CallOption) (*GetNotificationResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetNotificationResponse)
@@ -75,6 +117,16 @@ func (c *notificationServiceClient) GetNotification(ctx context.Context, in *Get
 	return out, nil
 }
 
+func (c *notificationServiceClient) GetNotificationStatus(ctx context.Context, in *GetNotificationStatusRequest, opts ...grpc.CallOption) (*GetNotificationStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetNotificationStatusResponse)
+	err := c.cc.Invoke(ctx, NotificationService_GetNotificationStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *notificationServiceClient) ListNotifications(ctx context.Context, in *ListNotificationsRequest, opts ...grpc.CallOption) (*ListNotificationsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ListNotificationsResponse)
@@ -95,6 +147,16 @@ func (c *notificationServiceClient) UpdateNotificationStatus(ctx context.Context
 	return out, nil
 }
 
+func (c *notificationServiceClient) UpdateNotificationStatusBatch(ctx context.Context, in *UpdateNotificationStatusBatchRequest, opts ...grpc.CallOption) (*UpdateNotificationStatusBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateNotificationStatusBatchResponse)
+	err := c.cc.Invoke(ctx, NotificationService_UpdateNotificationStatusBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *notificationServiceClient) GetUserPreferences(ctx context.Context, in *GetUserPreferencesRequest, opts ...grpc.CallOption) (*GetUserPreferencesResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetUserPreferencesResponse)
@@ -115,6 +177,16 @@ func (c *notificationServiceClient) UpdateUserPreferences(ctx context.Context, i
 	return out, nil
 }
 
+func (c *notificationServiceClient) RegisterPushToken(ctx context.Context, in *RegisterPushTokenRequest, opts ...grpc.CallOption) (*RegisterPushTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterPushTokenResponse)
+	err := c.cc.Invoke(ctx, NotificationService_RegisterPushToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NotificationServiceServer is the server API for NotificationService service.
 // All implementations must embed UnimplementedNotificationServiceServer
 // for forward compatibility.
@@ -123,16 +195,30 @@ func (c *notificationServiceClient) UpdateUserPreferences(ctx context.Context, i
 type NotificationServiceServer interface {
 	// CreateNotification creates a new notification
 	CreateNotification(context.Context, *CreateNotificationRequest) (*CreateNotificationResponse, error)
+	// CreateNotificationStream creates the notifications streamed by the client,
+	// in batches, and returns a summary once the client closes the stream.
+	// Fan-out to several recipients and topic broadcasts are not supported.
+	CreateNotificationStream(grpc.ClientStreamingServer[CreateNotificationRequest, CreateNotificationStreamResponse]) error
+	// DryRunNotification runs the checks CreateNotification would run and
+	// returns the resolved notification, without storing or queueing it.
+	// Fan-out to several recipients and topic broadcasts are not supported.
+	DryRunNotification(context.Context, *CreateNotificationRequest) (*DryRunNotificationResponse, error)
 	// GetNotification retrieves a notification by ID
 	GetNotification(context.Context, *GetNotificationRequest) (*GetNotificationResponse, error)
+	// GetNotificationStatus retrieves only the delivery status of a notification
+	GetNotificationStatus(context.Context, *GetNotificationStatusRequest) (*GetNotificationStatusResponse, error)
 	// ListNotifications lists notifications for a user
 	ListNotifications(context.Context, *ListNotificationsRequest) (*ListNotificationsResponse, error)
 	// UpdateNotificationStatus updates the status of a notification
 	UpdateNotificationStatus(context.Context, *UpdateNotificationStatusRequest) (*UpdateNotificationStatusResponse, error)
+	// UpdateNotificationStatusBatch updates the status of many notifications at once
+	UpdateNotificationStatusBatch(context.Context, *UpdateNotificationStatusBatchRequest) (*UpdateNotificationStatusBatchResponse, error)
 	// GetUserPreferences retrieves user notification preferences
 	GetUserPreferences(context.Context, *GetUserPreferencesRequest) (*GetUserPreferencesResponse, error)
 	// UpdateUserPreferences updates user notification preferences
 	UpdateUserPreferences(context.Context, *UpdateUserPreferencesRequest) (*UpdateUserPreferencesResponse, error)
+	// RegisterPushToken registers or refreshes a push token for one of a user's devices
+	RegisterPushToken(context.Context, *RegisterPushTokenRequest) (*RegisterPushTokenResponse, error)
 	mustEmbedUnimplementedNotificationServiceServer()
 }
 
@@ -146,21 +232,36 @@ type UnimplementedNotificationServiceServer struct{}
 func (UnimplementedNotificationServiceServer) CreateNotification(context.Context, *CreateNotificationRequest) (*CreateNotificationResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateNotification not implemented")
 }
+func (UnimplementedNotificationServiceServer) CreateNotificationStream(grpc.ClientStreamingServer[CreateNotificationRequest, CreateNotificationStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method CreateNotificationStream not implemented")
+}
+func (UnimplementedNotificationServiceServer) DryRunNotification(context.Context, *CreateNotificationRequest) (*DryRunNotificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DryRunNotification not implemented")
+}
 func (UnimplementedNotificationServiceServer) GetNotification(context.Context, *GetNotificationRequest) (*GetNotificationResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetNotification not implemented")
 }
+func (UnimplementedNotificationServiceServer) GetNotificationStatus(context.Context, *GetNotificationStatusRequest) (*GetNotificationStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNotificationStatus not implemented")
+}
 func (UnimplementedNotificationServiceServer) ListNotifications(context.Context, *ListNotificationsRequest) (*ListNotificationsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListNotifications not implemented")
 }
 func (UnimplementedNotificationServiceServer) UpdateNotificationStatus(context.Context, *UpdateNotificationStatusRequest) (*UpdateNotificationStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateNotificationStatus not implemented")
 }
+func (UnimplementedNotificationServiceServer) UpdateNotificationStatusBatch(context.Context, *UpdateNotificationStatusBatchRequest) (*UpdateNotificationStatusBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateNotificationStatusBatch not implemented")
+}
 func (UnimplementedNotificationServiceServer) GetUserPreferences(context.Context, *GetUserPreferencesRequest) (*GetUserPreferencesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserPreferences not implemented")
 }
 func (UnimplementedNotificationServiceServer) UpdateUserPreferences(context.Context, *UpdateUserPreferencesRequest) (*UpdateUserPreferencesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateUserPreferences not implemented")
 }
+func (UnimplementedNotificationServiceServer) RegisterPushToken(context.Context, *RegisterPushTokenRequest) (*RegisterPushTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterPushToken not implemented")
+}
 func (UnimplementedNotificationServiceServer) mustEmbedUnimplementedNotificationServiceServer() {}
 func (UnimplementedNotificationServiceServer) testEmbeddedByValue()                             {}
 
@@ -200,6 +301,31 @@ func _NotificationService_CreateNotification_Handler(srv interface{}, ctx contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_CreateNotificationStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NotificationServiceServer).CreateNotificationStream(&grpc.GenericServerStream[CreateNotificationRequest, CreateNotificationStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_CreateNotificationStreamServer = grpc.ClientStreamingServer[CreateNotificationRequest, CreateNotificationStreamResponse]
+
+func _NotificationService_DryRunNotification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateNotificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).DryRunNotification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_DryRunNotification_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).DryRunNotification(ctx, req.(*CreateNotificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _NotificationService_GetNotification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetNotificationRequest)
 	if err := dec(in); err != nil {
@@ -218,6 +344,24 @@ func _NotificationService_GetNotification_Handler(srv interface{}, ctx context.C
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_GetNotificationStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNotificationStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetNotificationStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetNotificationStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetNotificationStatus(ctx, req.(*GetNotificationStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _NotificationService_ListNotifications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListNotificationsRequest)
 	if err := dec(in); err != nil {
@@ -254,6 +398,24 @@ func _NotificationService_UpdateNotificationStatus_Handler(srv interface{}, ctx
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_UpdateNotificationStatusBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateNotificationStatusBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).UpdateNotificationStatusBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_UpdateNotificationStatusBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).UpdateNotificationStatusBatch(ctx, req.(*UpdateNotificationStatusBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _NotificationService_GetUserPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetUserPreferencesRequest)
 	if err := dec(in); err != nil {
@@ -290,6 +452,24 @@ func _NotificationService_UpdateUserPreferences_Handler(srv interface{}, ctx con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_RegisterPushToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterPushTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).RegisterPushToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_RegisterPushToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).RegisterPushToken(ctx, req.(*RegisterPushTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NotificationService_ServiceDesc is the grpc.ServiceDesc for NotificationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -301,10 +481,18 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateNotification",
 			Handler:    _NotificationService_CreateNotification_Handler,
 		},
+		{
+			MethodName: "DryRunNotification",
+			Handler:    _NotificationService_DryRunNotification_Handler,
+		},
 		{
 			MethodName: "GetNotification",
 			Handler:    _NotificationService_GetNotification_Handler,
 		},
+		{
+			MethodName: "GetNotificationStatus",
+			Handler:    _NotificationService_GetNotificationStatus_Handler,
+		},
 		{
 			MethodName: "ListNotifications",
 			Handler:    _NotificationService_ListNotifications_Handler,
@@ -313,6 +501,10 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateNotificationStatus",
 			Handler:    _NotificationService_UpdateNotificationStatus_Handler,
 		},
+		{
+			MethodName: "UpdateNotificationStatusBatch",
+			Handler:    _NotificationService_UpdateNotificationStatusBatch_Handler,
+		},
 		{
 			MethodName: "GetUserPreferences",
 			Handler:    _NotificationService_GetUserPreferences_Handler,
@@ -321,7 +513,17 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateUserPreferences",
 			Handler:    _NotificationService_UpdateUserPreferences_Handler,
 		},
+		{
+			MethodName: "RegisterPushToken",
+			Handler:    _NotificationService_RegisterPushToken_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateNotificationStream",
+			Handler:       _NotificationService_CreateNotificationStream_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "notification.proto",
 }
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// apiKeyHeader carries a tenant API key
+const apiKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates the X-API-Key header and scopes the request
+// to the key's tenant. Without a key, a bearer JWT is accepted instead when a
+// JWT secret is configured. Requests with neither pass through unless API keys
+// are required. It also guards the JSON gateway, whose handlers check scopes
+// themselves.
+func (h *Handler) APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plaintext := r.Header.Get(apiKeyHeader)
+		if plaintext == "" {
+			if token, ok := bearerToken(r); ok && h.notificationService.JWTEnabled() {
+				key, err := h.notificationService.AuthenticateJWT(token)
+				if err != nil {
+					h.logger.Warn("Rejected JWT", zap.String("path", r.URL.Path), zap.Error(err))
+					h.writeErrorResponse(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(notification.WithAPIKey(r.Context(), key)))
+				return
+			}
+			if h.requireAPIKey {
+				h.writeErrorResponse(w, "Missing API key", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := h.notificationService.AuthenticateAPIKey(r.Context(), plaintext)
+		if err != nil {
+			switch {
+			case errors.Is(err, notification.ErrInvalidAPIKey), errors.Is(err, notification.ErrAPIKeyRevoked):
+				h.logger.Warn("Rejected API key", zap.String("path", r.URL.Path), zap.Error(err))
+				h.writeErrorResponse(w, err.Error(), http.StatusUnauthorized)
+			default:
+				h.logger.Error("Failed to authenticate API key", zap.Error(err))
+				h.writeErrorResponse(w, "Failed to authenticate API key", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(notification.WithAPIKey(r.Context(), key)))
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+// requireScope authenticates the request's API key with APIKeyMiddleware and
+// rejects keys that lack scope. Requests without a key are left to the
+// middleware.
+func (h *Handler) requireScope(scope string, next http.HandlerFunc) http.Handler {
+	return h.APIKeyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := notification.APIKeyFromContext(r.Context()); key != nil && !key.HasScope(scope) {
+			h.writeErrorResponse(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}))
+}
+
+// CreateAPIKeyRequest represents the request body for issuing an API key
+type CreateAPIKeyRequest struct {
+	TenantID string   `json:"tenant_id" validate:"required"`
+	Name     string   `json:"name,omitempty"`
+	Scopes   []string `json:"scopes" validate:"required,min=1"`
+}
+
+// CreateAPIKeyResponse returns a new key; the key itself is only shown here
+type CreateAPIKeyResponse struct {
+	*notification.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey handles POST /admin/api-keys
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		h.writeErrorResponse(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, plaintext, err := h.notificationService.CreateAPIKey(r.Context(), req.TenantID, req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidAPIKeyRequest) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("Failed to create API key", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: key, Key: plaintext})
+}
+
+// ListAPIKeys handles GET /admin/api-keys, optionally filtered by tenant_id
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.notificationService.ListAPIKeys(r.Context(), r.URL.Query().Get("tenant_id"))
+	if err != nil {
+		h.logger.Error("Failed to list API keys", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to list API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_keys": keys,
+	})
+}
+
+// RevokeAPIKey handles DELETE /admin/api-keys/{id}
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.notificationService.RevokeAPIKey(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, notification.ErrAPIKeyNotFound) {
+			h.writeErrorResponse(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to revoke API key", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lib/pq"
+)
+
+// expectAPIKey expects an API key lookup returning a key of tenant-a with
+// scopes, revoked when revokedAt is set
+func expectAPIKey(mock *dbtest.Mock, revokedAt *time.Time, scopes ...string) {
+	mock.ExpectQuery("FROM api_keys").
+		WillReturnRows(dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at").
+			AddRow("k1", "tenant-a", "", "nsk_12345678", pq.Array(scopes), time.Now(), revokedAt))
+}
+
+// statusRequest returns a status request for n1 with the given API key
+func statusRequest(apiKey string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil)
+	if apiKey != "" {
+		req.Header.Set(apiKeyHeader, apiKey)
+	}
+	return req
+}
+
+// bearerRequest returns a status request for n1 with a JWT for tenant-a
+// signed with secret
+func bearerRequest(t *testing.T, secret string, scopes string) *http.Request {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1", "tenant_id": "tenant-a", "scope": scopes}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	req := statusRequest("")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	revokedAt := updatedAt
+
+	t.Run("valid key scopes the request to its tenant", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		expectAPIKey(mock, nil, "notifications:read")
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1", "tenant-a").
+			WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "sent", updatedAt, nil))
+
+		if rec := serve(h, statusRequest("nsk_valid")); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("FROM api_keys").WillReturnRows(dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at"))
+
+		if rec := serve(h, statusRequest("nsk_unknown")); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		expectAPIKey(mock, &revokedAt, "notifications:read")
+
+		if rec := serve(h, statusRequest("nsk_revoked")); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("key without the scope", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		expectAPIKey(mock, nil, "notifications:create")
+
+		if rec := serve(h, statusRequest("nsk_create_only")); rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("missing key when required", func(t *testing.T) {
+		h, _ := newTestHandler(t, &config.Config{Auth: config.AuthConfig{RequireAPIKey: true}})
+
+		if rec := serve(h, statusRequest("")); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("missing key with the default config", func(t *testing.T) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		// No query is expected: a request without a key must not read
+		// notifications across tenants
+		h, _ := newTestHandler(t, cfg)
+
+		if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1", nil)); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("missing key when optional", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1", "").
+			WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "sent", updatedAt, nil))
+
+		if rec := serve(h, statusRequest("")); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+	})
+
+	jwtConfig := &config.Config{Auth: config.AuthConfig{JWTSecret: "test-secret", RequireAPIKey: true}}
+
+	t.Run("JWT scopes the request to its tenant", func(t *testing.T) {
+		h, mock := newTestHandler(t, jwtConfig)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1", "tenant-a").
+			WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "sent", updatedAt, nil))
+
+		if rec := serve(h, bearerRequest(t, "test-secret", notification.ScopeNotificationsRead)); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("JWT without the scope", func(t *testing.T) {
+		h, _ := newTestHandler(t, jwtConfig)
+
+		if rec := serve(h, bearerRequest(t, "test-secret", notification.ScopeNotificationsCreate)); rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("JWT with the wrong signature", func(t *testing.T) {
+		h, _ := newTestHandler(t, jwtConfig)
+
+		if rec := serve(h, bearerRequest(t, "other-secret", notification.ScopeNotificationsRead)); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("JWT without a secret configured", func(t *testing.T) {
+		h, _ := newTestHandler(t, &config.Config{Auth: config.AuthConfig{RequireAPIKey: true}})
+
+		if rec := serve(h, bearerRequest(t, "test-secret", notification.ScopeNotificationsRead)); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+}
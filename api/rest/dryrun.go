@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// ValidateNotificationResponse reports what creating a notification would produce
+type ValidateNotificationResponse struct {
+	Valid            bool                  `json:"valid"`
+	RequestedChannel string                `json:"requested_channel"`
+	Channel          string                `json:"channel"`   // differs from requested_channel when a fallback channel would be used
+	Scheduled        bool                  `json:"scheduled"` // held until scheduled_at instead of queued right away
+	Notification     *NotificationResponse `json:"notification"`
+}
+
+// ValidateNotification handles POST /notifications/validate. It runs the
+// checks of POST /notifications on the request body and responds with the
+// notification that would be created, without storing or queueing it. A
+// request that would be rejected gets the error create would return.
+func (h *Handler) ValidateNotification(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "validate_notification", duration)
+	}()
+
+	var req CreateNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	notifReq := req.notificationRequest()
+	notifReq.Topic = req.Topic
+	notifReq.Recipients = req.Recipients
+
+	result, err := h.notificationService.DryRunNotification(r.Context(), notifReq)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidDryRun) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.writeCheckError(w, err)
+		return
+	}
+
+	response := ValidateNotificationResponse{
+		Valid:            true,
+		RequestedChannel: result.RequestedChannel,
+		Channel:          result.Notification.Channel,
+		Scheduled:        result.Scheduled,
+		Notification:     newNotificationResponse(result.Notification),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+)
+
+// validateRequest returns a validate request with body
+func validateRequest(body string) *http.Request {
+	return httptest.NewRequest("POST", "/api/v1/notifications/validate", strings.NewReader(body))
+}
+
+func TestValidateNotification(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+
+	rec := serve(h, validateRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var response ValidateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !response.Valid || response.RequestedChannel != "sms" || response.Channel != "sms" || response.Scheduled {
+		t.Errorf("response = %+v, want a valid SMS queued right away", response)
+	}
+	if response.Notification == nil || response.Notification.ID != "" || response.Notification.Body != "hi" {
+		t.Errorf("notification = %+v, want the rendered notification without an ID", response.Notification)
+	}
+}
+
+func TestValidateNotificationRejected(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	tests := []struct {
+		name   string
+		body   string
+		expect func(mock *dbtest.Mock)
+		status int
+	}{
+		{
+			name: "preferences disabled",
+			body: `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi"}`,
+			expect: func(mock *dbtest.Mock) {
+				mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+					WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+						AddRow("p1", "user-1", "sms", false, "immediate", "", "", time.Now(), time.Now()))
+			},
+			status: http.StatusUnprocessableEntity,
+		},
+		{name: "channel disabled", body: `{"user_id":"user-1","channel":"email","recipient":"a@example.com","subject":"Hi","body":"hi"}`, status: http.StatusServiceUnavailable},
+		{name: "several recipients", body: `{"user_id":"user-1","channel":"sms","recipients":["+15551234567","+15557654321"],"body":"hi"}`, status: http.StatusBadRequest},
+		{name: "invalid request", body: `{"user_id":"user-1","channel":"fax","body":"hi"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newTestHandler(t, cfg)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			if rec := serve(h, validateRequest(tt.body)); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestValidateNotificationCountsNothing(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+			AddRow("p1", "user-1", "sms", false, "immediate", "", "", time.Now(), time.Now()))
+	failed := map[string]string{"channel": "sms", "error_type": "preferences_disabled"}
+	blocked := map[string]string{"channel": "sms", "reason": monitoring.BlockedPreferences}
+	failedBefore := counterValue(t, "notifications_failed_total", failed)
+	blockedBefore := counterValue(t, "notifications_blocked_total", blocked)
+
+	rec := serve(h, validateRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi"}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+	if got := counterValue(t, "notifications_failed_total", failed) - failedBefore; got != 0 {
+		t.Errorf("notifications_failed_total%v increased by %v, want 0", failed, got)
+	}
+	if got := counterValue(t, "notifications_blocked_total", blocked) - blockedBefore; got != 0 {
+		t.Errorf("notifications_blocked_total%v increased by %v, want 0", blocked, got)
+	}
+}
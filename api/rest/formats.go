@@ -0,0 +1,346 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Representations GET endpoints can return, chosen by the Accept header
+const (
+	contentTypeJSON = "application/json"
+	contentTypeXML  = "application/xml"
+	contentTypeCSV  = "text/csv"
+)
+
+// negotiate returns the first of offers, in the client's order of preference,
+// that the request's Accept header allows. A missing Accept header allows the
+// first offer. It returns false when none is acceptable.
+func negotiate(r *http.Request, offers ...string) (string, bool) {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		for _, offer := range offers {
+			if mediaTypeMatches(mediaType, offer) {
+				best, bestQ = offer, q
+				break
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// mediaTypeMatches reports whether an Accept media range such as text/* covers offer
+func mediaTypeMatches(mediaRange, offer string) bool {
+	if mediaRange == "*/*" || mediaRange == offer {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(offer, prefix+"/")
+}
+
+// writeNotAcceptable rejects a request whose Accept header allows none of offers
+func (h *Handler) writeNotAcceptable(w http.ResponseWriter, offers ...string) {
+	h.writeErrorResponse(w, "Acceptable representations are "+strings.Join(offers, ", "), http.StatusNotAcceptable)
+}
+
+// writeRepresentation writes response as JSON or, with contentType
+// application/xml, as the XML document xmlResponse
+func (h *Handler) writeRepresentation(w http.ResponseWriter, contentType string, response, xmlResponse interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	if contentType != contentTypeXML {
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(xmlResponse)
+}
+
+// notificationXML is the XML representation of a notification
+type notificationXML struct {
+	XMLName         xml.Name               `xml:"notification"`
+	ID              string                 `xml:"id"`
+	UserID          string                 `xml:"user_id"`
+	Channel         string                 `xml:"channel"`
+	Recipient       string                 `xml:"recipient"`
+	Subject         string                 `xml:"subject,omitempty"`
+	Body            string                 `xml:"body"`
+	Status          string                 `xml:"status"`
+	ExternalID      string                 `xml:"external_id,omitempty"`
+	ErrorMessage    string                 `xml:"error_message,omitempty"`
+	RetryCount      int                    `xml:"retry_count"`
+	ScheduledAt     *time.Time             `xml:"scheduled_at,omitempty"`
+	ExpiresAt       *time.Time             `xml:"expires_at,omitempty"`
+	SendWindowStart *time.Time             `xml:"send_window_start,omitempty"`
+	SendWindowEnd   *time.Time             `xml:"send_window_end,omitempty"`
+	QueuedAt        *time.Time             `xml:"queued_at,omitempty"`
+	SentAt          *time.Time             `xml:"sent_at,omitempty"`
+	DeliveredAt     *time.Time             `xml:"delivered_at,omitempty"`
+	ResentFrom      string                 `xml:"resent_from,omitempty"`
+	GroupID         string                 `xml:"group_id,omitempty"`
+	CollapseKey     string                 `xml:"collapse_key,omitempty"`
+	TenantID        string                 `xml:"tenant_id,omitempty"`
+	Category        string                 `xml:"category"`
+	CallbackURL     string                 `xml:"callback_url,omitempty"`
+	Priority        int                    `xml:"priority"`
+	CreatedBy       string                 `xml:"created_by,omitempty"`
+	Source          string                 `xml:"source,omitempty"`
+	CreatedAt       time.Time              `xml:"created_at"`
+	UpdatedAt       time.Time              `xml:"updated_at"`
+	Metadata        *metadataXML           `xml:"metadata,omitempty"`
+	Attachments     *attachmentsXML        `xml:"attachments,omitempty"`
+	Truncated       *truncatedXML          `xml:"truncated,omitempty"`
+	Events          *notificationEventsXML `xml:"events,omitempty"`
+	DeliveryReport  *deliveryReportXML     `xml:"delivery_report,omitempty"`
+}
+
+// metadataXML lists metadata entries
+type metadataXML struct {
+	Entries []metadataEntryXML `xml:"entry"`
+}
+
+// metadataEntryXML is one metadata key and value
+type metadataEntryXML struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// attachmentsXML lists attachments
+type attachmentsXML struct {
+	Attachments []attachmentXML `xml:"attachment"`
+}
+
+// attachmentXML is the XML representation of an attachment
+type attachmentXML struct {
+	Filename    string `xml:"filename"`
+	ContentType string `xml:"content_type"`
+	Content     string `xml:"content,omitempty"`
+	URL         string `xml:"url,omitempty"`
+	ContentID   string `xml:"content_id,omitempty"`
+}
+
+// truncatedXML lists the fields shortened on creation
+type truncatedXML struct {
+	Fields []string `xml:"field"`
+}
+
+// notificationEventsXML lists status changes
+type notificationEventsXML struct {
+	Events []notificationEventXML `xml:"event"`
+}
+
+// notificationEventXML is the XML representation of a status change
+type notificationEventXML struct {
+	ID         int64     `xml:"id"`
+	Status     string    `xml:"status"`
+	ExternalID string    `xml:"external_id,omitempty"`
+	Detail     string    `xml:"detail,omitempty"`
+	CreatedAt  time.Time `xml:"created_at"`
+}
+
+// deliveryReportXML is the XML representation of a delivery report
+type deliveryReportXML struct {
+	ExternalID   string       `xml:"external_id"`
+	Status       string       `xml:"status"`
+	ErrorMessage string       `xml:"error_message,omitempty"`
+	Retryable    bool         `xml:"retryable"`
+	ProviderCode string       `xml:"provider_code,omitempty"`
+	DeliveredAt  *time.Time   `xml:"delivered_at,omitempty"`
+	Metadata     *metadataXML `xml:"metadata,omitempty"`
+}
+
+// notificationListXML is the XML representation of a notification list page
+type notificationListXML struct {
+	XMLName       xml.Name           `xml:"notifications"`
+	TotalCount    int                `xml:"total_count,attr"`
+	NextPageToken string             `xml:"next_page_token,attr,omitempty"`
+	Items         []*notificationXML `xml:"notification"`
+}
+
+// newNotificationXML converts a notification to its XML representation
+func newNotificationXML(n *NotificationResponse) *notificationXML {
+	doc := &notificationXML{
+		ID:              n.ID,
+		UserID:          n.UserID,
+		Channel:         n.Channel,
+		Recipient:       n.Recipient,
+		Subject:         n.Subject,
+		Body:            n.Body,
+		Status:          n.Status,
+		ExternalID:      n.ExternalID,
+		ErrorMessage:    n.ErrorMessage,
+		RetryCount:      n.RetryCount,
+		ScheduledAt:     n.ScheduledAt,
+		ExpiresAt:       n.ExpiresAt,
+		SendWindowStart: n.SendWindowStart,
+		SendWindowEnd:   n.SendWindowEnd,
+		QueuedAt:        n.QueuedAt,
+		SentAt:          n.SentAt,
+		DeliveredAt:     n.DeliveredAt,
+		ResentFrom:      n.ResentFrom,
+		GroupID:         n.GroupID,
+		CollapseKey:     n.CollapseKey,
+		TenantID:        n.TenantID,
+		Category:        n.Category,
+		CallbackURL:     n.CallbackURL,
+		Priority:        n.Priority,
+		CreatedBy:       n.CreatedBy,
+		Source:          n.Source,
+		CreatedAt:       n.CreatedAt,
+		UpdatedAt:       n.UpdatedAt,
+		Metadata:        newMetadataXML(n.Metadata),
+	}
+	if len(n.Truncated) > 0 {
+		doc.Truncated = &truncatedXML{Fields: n.Truncated}
+	}
+	if len(n.Attachments) > 0 {
+		doc.Attachments = &attachmentsXML{}
+	}
+	for _, a := range n.Attachments {
+		doc.Attachments.Attachments = append(doc.Attachments.Attachments, attachmentXML{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Content:     a.Content,
+			URL:         a.URL,
+			ContentID:   a.ContentID,
+		})
+	}
+	return doc
+}
+
+// newNotificationDetailsXML converts a notification with its expanded related
+// data to its XML representation
+func newNotificationDetailsXML(response NotificationDetailsResponse) *notificationXML {
+	doc := newNotificationXML(response.NotificationResponse)
+	if len(response.Events) > 0 {
+		doc.Events = &notificationEventsXML{}
+	}
+	for _, e := range response.Events {
+		doc.Events.Events = append(doc.Events.Events, notificationEventXML{
+			ID:         e.ID,
+			Status:     string(e.Status),
+			ExternalID: e.ExternalID,
+			Detail:     e.Detail,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	if report := response.DeliveryReport; report != nil {
+		doc.DeliveryReport = &deliveryReportXML{
+			ExternalID:   report.ExternalID,
+			Status:       string(report.Status),
+			ErrorMessage: report.ErrorMessage,
+			Retryable:    report.Retryable,
+			ProviderCode: report.ProviderCode,
+			DeliveredAt:  report.DeliveredAt,
+			Metadata:     newMetadataXML(report.Metadata),
+		}
+	}
+	return doc
+}
+
+// newNotificationListXML converts a list page to its XML representation
+func newNotificationListXML(response ListNotificationsResponse) *notificationListXML {
+	doc := &notificationListXML{
+		TotalCount:    response.TotalCount,
+		NextPageToken: response.NextPageToken,
+		Items:         make([]*notificationXML, 0, len(response.Items)),
+	}
+	for _, n := range response.Items {
+		doc.Items = append(doc.Items, newNotificationXML(n))
+	}
+	return doc
+}
+
+// newMetadataXML lists metadata entries sorted by key, so documents are
+// stable. It returns nil for empty metadata.
+func newMetadataXML(metadata map[string]string) *metadataXML {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	doc := &metadataXML{Entries: make([]metadataEntryXML, 0, len(keys))}
+	for _, key := range keys {
+		doc.Entries = append(doc.Entries, metadataEntryXML{Key: key, Value: metadata[key]})
+	}
+	return doc
+}
+
+// notificationCSVHeader names the columns of the CSV representation of a list
+var notificationCSVHeader = []string{
+	"id", "user_id", "channel", "recipient", "subject", "body", "status",
+	"external_id", "error_message", "retry_count", "scheduled_at", "expires_at",
+	"send_window_start", "send_window_end", "queued_at", "sent_at", "delivered_at", "resent_from", "group_id",
+	"collapse_key", "tenant_id", "category", "callback_url", "priority", "created_by", "source", "created_at", "updated_at",
+}
+
+// writeNotificationsCSV writes a list page as CSV, one row per notification.
+// Metadata and attachments are left out; the page token and total count are
+// sent in the X-Next-Page-Token and X-Total-Count headers.
+func writeNotificationsCSV(w http.ResponseWriter, response ListNotificationsResponse) error {
+	w.Header().Set("Content-Type", contentTypeCSV+"; charset=utf-8")
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("X-Total-Count", strconv.Itoa(response.TotalCount))
+	if response.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", response.NextPageToken)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(notificationCSVHeader); err != nil {
+		return err
+	}
+	for _, n := range response.Items {
+		record := []string{
+			n.ID, n.UserID, n.Channel, n.Recipient, n.Subject, n.Body, n.Status,
+			n.ExternalID, n.ErrorMessage, strconv.Itoa(n.RetryCount), csvTime(n.ScheduledAt), csvTime(n.ExpiresAt),
+			csvTime(n.SendWindowStart), csvTime(n.SendWindowEnd), csvTime(n.QueuedAt), csvTime(n.SentAt), csvTime(n.DeliveredAt), n.ResentFrom, n.GroupID,
+			n.CollapseKey, n.TenantID, n.Category, n.CallbackURL, strconv.Itoa(n.Priority), n.CreatedBy, n.Source, n.CreatedAt.Format(time.RFC3339), n.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvTime formats an optional timestamp for CSV, empty when unset
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,208 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{contentTypeJSON, contentTypeXML, contentTypeCSV}
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", contentTypeJSON, true},
+		{"*/*", contentTypeJSON, true},
+		{"application/xml", contentTypeXML, true},
+		{"text/csv; charset=utf-8", contentTypeCSV, true},
+		{"text/*", contentTypeCSV, true},
+		{"application/*", contentTypeJSON, true},
+		{"application/json;q=0.5, application/xml", contentTypeXML, true},
+		{"text/csv;q=0.2, application/xml;q=0.8", contentTypeXML, true},
+		{"text/html, application/xml;q=0.1", contentTypeXML, true},
+		{"text/html", "", false},
+		{"application/xml;q=bad", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		got, ok := negotiate(req, offers...)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("negotiate(%q) = %q, %v, want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// formatsNotification is a stored notification with fields that need escaping
+// in every representation
+func formatsNotification() notification.Notification {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := createdAt.Add(time.Second)
+	return notification.Notification{
+		ID: "n1", UserID: "user-1", Channel: "email", Recipient: "a@example.com", Subject: `Offers & "deals"`,
+		Body: "Hello, <b>world</b>\nBye", Status: notification.StatusSent, ExternalID: "msg-1", SentAt: &sentAt,
+		CreatedAt: createdAt, UpdatedAt: sentAt,
+	}
+}
+
+func getNotification(t *testing.T, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	h, mock := newTestHandler(t, nil)
+	if accept != "text/csv" && accept != "text/html" {
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(formatsNotification()))
+	}
+	req := httptest.NewRequest("GET", "/api/v1/notifications/n1", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	return serve(h, req)
+}
+
+func TestGetNotificationRepresentations(t *testing.T) {
+	want := formatsNotification()
+
+	t.Run("json", func(t *testing.T) {
+		rec := getNotification(t, "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentTypeJSON {
+			t.Fatalf("status = %d, Content-Type = %q, want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var got NotificationResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if got.ID != want.ID || got.Subject != want.Subject || got.Body != want.Body || got.Status != "sent" || !got.SentAt.Equal(*want.SentAt) {
+			t.Errorf("response = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		rec := getNotification(t, "application/xml")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentTypeXML {
+			t.Fatalf("status = %d, Content-Type = %q, want 200 XML", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if vary := rec.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("Vary = %q, want Accept", vary)
+		}
+		if !strings.HasPrefix(rec.Body.String(), xml.Header) {
+			t.Errorf("body does not start with the XML header: %s", rec.Body)
+		}
+		var got notificationXML
+		if err := xml.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if got.XMLName.Local != "notification" || got.ID != want.ID || got.UserID != want.UserID || got.Channel != want.Channel ||
+			got.Recipient != want.Recipient || got.Subject != want.Subject || got.Body != want.Body || got.Status != "sent" ||
+			got.ExternalID != want.ExternalID || got.Category != notification.CategoryTransactional ||
+			got.SentAt == nil || !got.SentAt.Equal(*want.SentAt) || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("response = %+v, want %+v", got, want)
+		}
+		if got.ScheduledAt != nil || got.Metadata != nil {
+			t.Errorf("response = %+v, want unset fields left out", got)
+		}
+	})
+
+	// CSV is only offered for lists
+	for _, accept := range []string{"text/csv", "text/html"} {
+		t.Run(accept, func(t *testing.T) {
+			rec := getNotification(t, accept)
+			if rec.Code != http.StatusNotAcceptable {
+				t.Errorf("status = %d, want 406", rec.Code)
+			}
+		})
+	}
+}
+
+func listNotifications(t *testing.T, accept string, rows ...notification.Notification) *httptest.ResponseRecorder {
+	t.Helper()
+	h, mock := newTestHandler(t, nil)
+	if accept != "text/html" {
+		mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1").WithArgs("user-1").
+			WillReturnRows(dbtest.NewRows("count").AddRow(len(rows)))
+		mock.ExpectQuery("FROM notifications WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2").WithArgs("user-1", 11).
+			WillReturnRows(notificationtest.Rows(rows...))
+	}
+	req := httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&limit=10", nil)
+	req.Header.Set("Accept", accept)
+	return serve(h, req)
+}
+
+func TestListNotificationsRepresentations(t *testing.T) {
+	want := formatsNotification()
+	second := want
+	second.ID, second.Status, second.SentAt, second.Subject, second.Body = "n2", notification.StatusPending, nil, "", "plain"
+
+	t.Run("xml", func(t *testing.T) {
+		rec := listNotifications(t, "application/xml", want, second)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentTypeXML {
+			t.Fatalf("status = %d, Content-Type = %q, want 200 XML", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var got notificationListXML
+		if err := xml.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if got.TotalCount != 2 || len(got.Items) != 2 {
+			t.Fatalf("response = %+v, want 2 notifications", got)
+		}
+		if got.Items[0].ID != "n1" || got.Items[0].Body != want.Body || got.Items[1].ID != "n2" || got.Items[1].Status != "pending" {
+			t.Errorf("items = %+v, %+v", got.Items[0], got.Items[1])
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := listNotifications(t, "text/csv", want, second)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Fatalf("status = %d, Content-Type = %q, want 200 CSV", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if total := rec.Header().Get("X-Total-Count"); total != "2" {
+			t.Errorf("X-Total-Count = %q, want 2", total)
+		}
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to parse CSV: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("got %d records, want a header and 2 rows", len(records))
+		}
+		column := make(map[string]int)
+		for i, name := range records[0] {
+			column[name] = i
+		}
+		if len(column) != len(notificationCSVHeader) {
+			t.Fatalf("header = %v, want %v", records[0], notificationCSVHeader)
+		}
+
+		first := records[1]
+		checks := map[string]string{
+			"id": "n1", "user_id": "user-1", "channel": "email", "recipient": "a@example.com",
+			"subject": want.Subject, "body": want.Body, "status": "sent", "external_id": "msg-1",
+			"retry_count": "0", "sent_at": "2024-05-01T12:00:01Z", "scheduled_at": "", "category": "transactional",
+			"priority": "2", "created_at": "2024-05-01T12:00:00Z",
+		}
+		for name, value := range checks {
+			if got := first[column[name]]; got != value {
+				t.Errorf("row 1 %s = %q, want %q", name, got, value)
+			}
+		}
+		if got := records[2][column["sent_at"]]; got != "" {
+			t.Errorf("row 2 sent_at = %q, want empty", got)
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		rec := listNotifications(t, "text/html")
+		if rec.Code != http.StatusNotAcceptable {
+			t.Errorf("status = %d, want 406", rec.Code)
+		}
+	})
+}
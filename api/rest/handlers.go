@@ -1,25 +1,45 @@
 package rest
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/monitoring"
 	"github.com/alexnthnz/notification-system/internal/notification"
 )
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
 // Handler holds dependencies for REST API handlers
 type Handler struct {
 	notificationService *notification.Service
-	metrics            *monitoring.Metrics
-	logger             *zap.Logger
-	validator          *validator.Validate
+	metrics             *monitoring.Metrics
+	logger              *zap.Logger
+	validator           *validator.Validate
+	adminToken          string
+	requireAPIKey       bool
+	sendGridVerifier    WebhookVerifier
+	testSender          *channels.ChannelManager // nil unless test sends are enabled
+	syncSender          *channels.ChannelManager // nil unless synchronous sends are enabled
+	requestTimeout      time.Duration            // deadline of each request, 0 for none
 }
 
 // NewHandler creates a new REST API handler
@@ -27,34 +47,110 @@ func NewHandler(
 	notificationService *notification.Service,
 	metrics *monitoring.Metrics,
 	logger *zap.Logger,
+	authConfig config.AuthConfig,
 ) *Handler {
 	return &Handler{
 		notificationService: notificationService,
-		metrics:            metrics,
-		logger:             logger,
-		validator:          validator.New(),
+		metrics:             metrics,
+		logger:              logger,
+		validator:           validator.New(),
+		adminToken:          authConfig.AdminToken,
+		requireAPIKey:       authConfig.RequireAPIKey,
 	}
 }
 
 // CreateNotificationRequest represents the request body for creating notifications
 type CreateNotificationRequest struct {
-	UserID      string            `json:"user_id" validate:"required"`
-	Channel     string            `json:"channel" validate:"required,oneof=email sms push"`
-	Recipient   string            `json:"recipient" validate:"required"`
-	Subject     string            `json:"subject"`
-	Body        string            `json:"body" validate:"required"`
-	Priority    int               `json:"priority,omitempty"`
-	ScheduledAt *time.Time        `json:"scheduled_at,omitempty"`
-	Template    string            `json:"template,omitempty"`
-	Variables   map[string]string `json:"variables,omitempty"`
-	Metadata    map[string]string `json:"metadata,omitempty"`
+	UserID           string                       `json:"user_id" validate:"required_without=Topic"`
+	Channel          string                       `json:"channel" validate:"required,oneof=email sms push"`
+	Topic            string                       `json:"topic,omitempty"` // broadcast to the push topic instead of a user
+	FallbackChannels []string                     `json:"fallback_channels,omitempty" validate:"omitempty,max=2,dive,oneof=email sms push"`
+	Recipient        string                       `json:"recipient"` // resolved from the user's contact details when empty
+	Recipients       []string                     `json:"recipients,omitempty" validate:"omitempty,max=100,dive,required"`
+	Subject          string                       `json:"subject"`
+	Body             string                       `json:"body" validate:"required_without=Template"`
+	Priority         int                          `json:"priority,omitempty" validate:"omitempty,min=1,max=3"` // 1 = high, 2 = medium (default), 3 = low
+	ScheduledAt      *time.Time                   `json:"scheduled_at,omitempty"`
+	ExpiresAt        *time.Time                   `json:"expires_at,omitempty"`
+	SendWindowStart  *time.Time                   `json:"send_window_start,omitempty"` // held until this time
+	SendWindowEnd    *time.Time                   `json:"send_window_end,omitempty"`   // expired when not sent by this time
+	Template         string                       `json:"template,omitempty"`
+	Locale           string                       `json:"locale,omitempty"`
+	Variables        map[string]string            `json:"variables,omitempty"`
+	Metadata         map[string]string            `json:"metadata,omitempty"`
+	ProviderOptions  notification.ProviderOptions `json:"provider_options,omitempty"` // passed through to each provider, keyed by provider
+	Attachments      []notification.Attachment    `json:"attachments,omitempty"`
+	CollapseKey      string                       `json:"collapse_key,omitempty" validate:"omitempty,max=64"`
+	Category         string                       `json:"category,omitempty" validate:"omitempty,oneof=transactional marketing"` // defaults to transactional
+	CallbackURL      string                       `json:"callback_url,omitempty"`                                                // receives a signed POST on each status change
+	Sync             bool                         `json:"sync,omitempty"`                                                        // send before responding instead of queueing, see createSyncNotification
+}
+
+// notificationRequest converts the request body to the internal request,
+// leaving out its topic and recipients
+func (req CreateNotificationRequest) notificationRequest() notification.NotificationRequest {
+	return notification.NotificationRequest{
+		UserID:           req.UserID,
+		Channel:          req.Channel,
+		FallbackChannels: req.FallbackChannels,
+		Recipient:        req.Recipient,
+		Subject:          req.Subject,
+		Body:             req.Body,
+		Priority:         req.Priority,
+		ScheduledAt:      req.ScheduledAt,
+		ExpiresAt:        req.ExpiresAt,
+		SendWindowStart:  req.SendWindowStart,
+		SendWindowEnd:    req.SendWindowEnd,
+		Template:         req.Template,
+		Locale:           req.Locale,
+		Variables:        req.Variables,
+		Metadata:         req.Metadata,
+		ProviderOptions:  req.ProviderOptions,
+		Attachments:      req.Attachments,
+		CollapseKey:      req.CollapseKey,
+		Category:         req.Category,
+		CallbackURL:      req.CallbackURL,
+	}
 }
 
 // CreateNotificationResponse represents the response for creating notifications
 type CreateNotificationResponse struct {
-	ID      string `json:"id"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	ID        string   `json:"id,omitempty"`
+	IDs       []string `json:"ids,omitempty"`       // set when the request was fanned out to several recipients
+	GroupID   string   `json:"group_id,omitempty"`  // set when the request was fanned out to several recipients
+	Locations []string `json:"locations,omitempty"` // URLs of the notifications in IDs, in the same order
+	Status    string   `json:"status"`
+	Message   string   `json:"message"`
+	Truncated []string `json:"truncated,omitempty"` // fields shortened to the channel's limits
+
+	// Set when the notification is held instead of queued right away
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
+	DeferredReason string     `json:"deferred_reason,omitempty"` // scheduled or send_window
+
+	// Set when the notification was sent synchronously
+	Provider string                       `json:"provider,omitempty"`
+	Report   *notification.DeliveryReport `json:"report,omitempty"`
+}
+
+// ListNotificationsResponse represents the response for listing notifications
+type ListNotificationsResponse struct {
+	Items         []*NotificationResponse `json:"items"`
+	NextPageToken string                  `json:"next_page_token"`
+	TotalCount    int                     `json:"total_count"`
+}
+
+// Related data GET /notifications/{id} can include through ?expand=
+const (
+	expandEvents         = "events"
+	expandDeliveryReport = "delivery_report"
+)
+
+// NotificationDetailsResponse represents a notification with the related data
+// requested through expand
+type NotificationDetailsResponse struct {
+	*NotificationResponse
+	Events         []notification.NotificationEvent `json:"events,omitempty"`
+	DeliveryReport *notification.DeliveryReport     `json:"delivery_report,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -72,9 +168,6 @@ func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordProcessingDuration("api", "create_notification", duration)
 	}()
 
-	h.metrics.IncrementActiveConnections()
-	defer h.metrics.DecrementActiveConnections()
-
 	var req CreateNotificationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Error("Failed to decode request", zap.Error(err))
@@ -90,38 +183,146 @@ func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert to notification request
-	notifReq := notification.NotificationRequest{
-		UserID:      req.UserID,
-		Channel:     req.Channel,
-		Recipient:   req.Recipient,
-		Subject:     req.Subject,
-		Body:        req.Body,
-		Priority:    req.Priority,
-		ScheduledAt: req.ScheduledAt,
-		Variables:   req.Variables,
-		Metadata:    req.Metadata,
+	notifReq := req.notificationRequest()
+
+	// Send before responding instead of queueing
+	if req.Sync {
+		if req.Topic != "" || len(req.Recipients) > 0 {
+			h.writeErrorResponse(w, "sync cannot be combined with topic or recipients", http.StatusBadRequest)
+			return
+		}
+		h.createSyncNotification(w, r, notifReq)
+		return
+	}
+
+	// Broadcast to a topic instead of a user
+	if req.Topic != "" {
+		notifReq.Topic = req.Topic
+		notifReq.Recipients = req.Recipients
+		h.createBroadcast(w, r, notifReq)
+		return
+	}
+
+	// Fan out when several recipients were given
+	if len(req.Recipients) > 0 {
+		if req.Recipient != "" {
+			h.writeErrorResponse(w, "Specify either recipient or recipients, not both", http.StatusBadRequest)
+			return
+		}
+		if len(req.FallbackChannels) > 0 {
+			h.writeErrorResponse(w, "fallback_channels cannot be combined with recipients", http.StatusBadRequest)
+			return
+		}
+		notifReq.Recipients = req.Recipients
+		h.createNotificationGroup(w, r, notifReq)
+		return
 	}
 
 	// Create notification
 	notif, err := h.notificationService.CreateNotification(r.Context(), notifReq)
 	if err != nil {
-		h.logger.Error("Failed to create notification", zap.Error(err))
-		h.metrics.RecordNotificationFailed(req.Channel, "creation_error")
-		h.writeErrorResponse(w, "Failed to create notification", http.StatusInternalServerError)
+		h.writeCreateError(w, req.Channel, err)
 		return
 	}
 
 	h.metrics.RecordNotificationSent(req.Channel, "created")
-	h.logger.Info("Notification created", 
+	h.logger.Info("Notification created",
 		zap.String("id", notif.ID),
 		zap.String("channel", notif.Channel),
 		zap.String("user_id", notif.UserID),
 	)
 
 	response := CreateNotificationResponse{
-		ID:      notif.ID,
-		Status:  string(notif.Status),
-		Message: "Notification created successfully",
+		ID:        notif.ID,
+		Status:    string(notif.Status),
+		Message:   "Notification created successfully",
+		Truncated: notif.Truncated,
+	}
+	statusCode := deferResponse(&response, notif)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", notificationLocation(notif.ID))
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// deferResponse adds when and why notif is held to response and returns the
+// status code to respond with: 202 Accepted when the notification is held
+// instead of queued right away, otherwise 201 Created
+func deferResponse(response *CreateNotificationResponse, notif *notification.Notification) int {
+	if notif.DeferredReason == "" {
+		return http.StatusCreated
+	}
+	response.ScheduledAt = notif.ScheduledAt
+	response.DeferredReason = notif.DeferredReason
+	return http.StatusAccepted
+}
+
+// notificationLocation returns the URL a notification can be fetched from
+func notificationLocation(id string) string {
+	return "/api/v1/notifications/" + id
+}
+
+// createNotificationGroup creates one notification per recipient and responds with their IDs
+func (h *Handler) createNotificationGroup(w http.ResponseWriter, r *http.Request, notifReq notification.NotificationRequest) {
+	group, err := h.notificationService.CreateNotificationGroup(r.Context(), notifReq)
+	if err != nil {
+		h.writeCreateError(w, notifReq.Channel, err)
+		return
+	}
+
+	ids := make([]string, 0, len(group.Notifications))
+	locations := make([]string, 0, len(group.Notifications))
+	for _, notif := range group.Notifications {
+		h.metrics.RecordNotificationSent(notif.Channel, "created")
+		ids = append(ids, notif.ID)
+		locations = append(locations, notificationLocation(notif.ID))
+	}
+
+	h.logger.Info("Notification group created",
+		zap.String("group_id", group.GroupID),
+		zap.String("channel", notifReq.Channel),
+		zap.Int("count", len(ids)),
+	)
+
+	response := CreateNotificationResponse{
+		IDs:       ids,
+		GroupID:   group.GroupID,
+		Locations: locations,
+		Status:    string(notification.StatusPending),
+		Message:   "Notifications created successfully",
+	}
+	// Every notification in the group has the same content and schedule
+	statusCode := http.StatusCreated
+	if len(group.Notifications) > 0 {
+		response.Truncated = group.Notifications[0].Truncated
+		statusCode = deferResponse(&response, group.Notifications[0])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// createBroadcast creates a topic broadcast and responds with its ID
+func (h *Handler) createBroadcast(w http.ResponseWriter, r *http.Request, notifReq notification.NotificationRequest) {
+	broadcast, err := h.notificationService.CreateBroadcast(r.Context(), notifReq)
+	if err != nil {
+		h.writeCreateError(w, notifReq.Channel, err)
+		return
+	}
+
+	h.metrics.RecordNotificationSent(notifReq.Channel, "created")
+	h.logger.Info("Broadcast created",
+		zap.String("id", broadcast.ID),
+		zap.String("topic", broadcast.Topic),
+	)
+
+	response := CreateNotificationResponse{
+		ID:        broadcast.ID,
+		Status:    string(broadcast.Status),
+		Message:   "Broadcast created successfully",
+		Truncated: broadcast.Truncated,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -129,6 +330,73 @@ func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// writeCreateError maps a notification creation error to an HTTP response
+func (h *Handler) writeCreateError(w http.ResponseWriter, channel string, err error) {
+	h.logger.Error("Failed to create notification", zap.Error(err))
+
+	reason, message, statusCode := createErrorStatus(err)
+	h.metrics.RecordNotificationFailed(channel, reason)
+	switch {
+	case errors.Is(err, notification.ErrNotificationsDisabled):
+		h.metrics.RecordNotificationBlocked(channel, monitoring.BlockedPreferences)
+	case errors.Is(err, notification.ErrRecipientSuppressed):
+		h.metrics.RecordNotificationBlocked(channel, monitoring.BlockedSuppressed)
+	}
+	h.writeErrorResponse(w, message, statusCode)
+}
+
+// writeCheckError responds to a request that creates nothing, such as a dry
+// run, with the error create would return for err. Nothing is counted as
+// failed or blocked since no notification exists.
+func (h *Handler) writeCheckError(w http.ResponseWriter, err error) {
+	h.logger.Error("Notification request rejected", zap.Error(err))
+
+	_, message, statusCode := createErrorStatus(err)
+	h.writeErrorResponse(w, message, statusCode)
+}
+
+// createErrorStatus maps a notification creation error to the reason it is
+// counted as failed under, and the message and HTTP status it is reported with
+func createErrorStatus(err error) (reason, message string, statusCode int) {
+	var attachmentErr *notification.AttachmentError
+	switch {
+	case errors.As(err, &attachmentErr):
+		return "invalid_attachment", attachmentErr.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidPushOptions):
+		return "invalid_push_options", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidEmailOptions):
+		return "invalid_email_options", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidSMSOptions):
+		return "invalid_sms_options", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidProviderOptions):
+		return "invalid_provider_options", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidBroadcast):
+		return "invalid_broadcast", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidCallbackURL):
+		return "invalid_callback_url", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrInvalidSendWindow):
+		return "invalid_send_window", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrSubjectRequired):
+		return "subject_required", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrScheduleTooFar):
+		return "schedule_too_far", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrContentTooLong):
+		return "content_too_long", err.Error(), http.StatusBadRequest
+	case errors.Is(err, notification.ErrChannelDisabled):
+		return "channel_disabled", err.Error(), http.StatusServiceUnavailable
+	case errors.Is(err, notification.ErrNotificationsDisabled):
+		return "preferences_disabled", err.Error(), http.StatusUnprocessableEntity
+	case errors.Is(err, notification.ErrNoRecipient):
+		return "no_recipient", err.Error(), http.StatusUnprocessableEntity
+	case errors.Is(err, notification.ErrRecipientSuppressed):
+		return "recipient_suppressed", err.Error(), http.StatusUnprocessableEntity
+	case errors.Is(err, notification.ErrTemplateNotFound), errors.Is(err, notification.ErrTemplateRender):
+		return "template_error", err.Error(), http.StatusUnprocessableEntity
+	default:
+		return "creation_error", "Failed to create notification", http.StatusInternalServerError
+	}
+}
+
 // GetNotification handles GET /notifications/{id}
 func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -137,9 +405,6 @@ func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
 		h.metrics.RecordProcessingDuration("api", "get_notification", duration)
 	}()
 
-	h.metrics.IncrementActiveConnections()
-	defer h.metrics.DecrementActiveConnections()
-
 	vars := mux.Vars(r)
 	id := vars["id"]
 
@@ -148,10 +413,29 @@ func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contentType, ok := negotiate(r, contentTypeJSON, contentTypeXML)
+	if !ok {
+		h.writeNotAcceptable(w, contentTypeJSON, contentTypeXML)
+		return
+	}
+
+	// Related data to include, e.g. ?expand=events,delivery_report
+	expand := make(map[string]bool)
+	if value := r.URL.Query().Get("expand"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != expandEvents && name != expandDeliveryReport {
+				h.writeErrorResponse(w, "expand must be a list of events and delivery_report", http.StatusBadRequest)
+				return
+			}
+			expand[name] = true
+		}
+	}
+
 	notif, err := h.notificationService.GetNotification(r.Context(), id)
 	if err != nil {
 		h.logger.Error("Failed to get notification", zap.Error(err), zap.String("id", id))
-		if err.Error() == "notification not found" {
+		if errors.Is(err, notification.ErrNotificationNotFound) {
 			h.writeErrorResponse(w, "Notification not found", http.StatusNotFound)
 		} else {
 			h.writeErrorResponse(w, "Failed to retrieve notification", http.StatusInternalServerError)
@@ -159,8 +443,733 @@ func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(expand) == 0 {
+		response := newNotificationResponse(notif)
+		h.writeRepresentation(w, contentType, response, newNotificationXML(response))
+		return
+	}
+
+	response := NotificationDetailsResponse{NotificationResponse: newNotificationResponse(notif)}
+	if expand[expandEvents] {
+		response.Events, err = h.notificationService.GetNotificationEvents(r.Context(), id)
+		if err != nil {
+			h.logger.Error("Failed to get notification events", zap.Error(err), zap.String("id", id))
+			h.writeErrorResponse(w, "Failed to retrieve notification events", http.StatusInternalServerError)
+			return
+		}
+	}
+	if expand[expandDeliveryReport] {
+		response.DeliveryReport = notification.DeliveryReportFor(notif)
+	}
+
+	h.writeRepresentation(w, contentType, response, newNotificationDetailsXML(response))
+}
+
+// GetNotificationStatus handles GET /notifications/{id}/status
+func (h *Handler) GetNotificationStatus(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "get_notification_status", duration)
+	}()
+
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		h.writeErrorResponse(w, "Notification ID is required", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.notificationService.GetNotificationStatus(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get notification status", zap.Error(err), zap.String("id", id))
+		if errors.Is(err, notification.ErrNotificationNotFound) {
+			h.writeErrorResponse(w, "Notification not found", http.StatusNotFound)
+		} else {
+			h.writeErrorResponse(w, "Failed to retrieve notification status", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(notif)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// ResendNotification handles POST /notifications/{id}/resend
+func (h *Handler) ResendNotification(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "resend_notification", duration)
+	}()
+
+	id := mux.Vars(r)["id"]
+
+	notif, err := h.notificationService.ResendNotification(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to resend notification", zap.Error(err), zap.String("id", id))
+		switch {
+		case errors.Is(err, notification.ErrNotificationNotFound):
+			h.writeErrorResponse(w, "Notification not found", http.StatusNotFound)
+		case errors.Is(err, notification.ErrNotResendable):
+			h.writeErrorResponse(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, notification.ErrRecipientSuppressed):
+			// Only email recipients are suppressed
+			h.metrics.RecordNotificationBlocked("email", monitoring.BlockedSuppressed)
+			h.writeErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			h.writeErrorResponse(w, "Failed to resend notification", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.metrics.RecordNotificationSent(notif.Channel, "resent")
+	h.logger.Info("Notification resent",
+		zap.String("id", notif.ID),
+		zap.String("resent_from", id),
+	)
+
+	response := CreateNotificationResponse{
+		ID:      notif.ID,
+		Status:  string(notif.Status),
+		Message: "Notification resent successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", notificationLocation(notif.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RescheduleRequest represents the request body for changing when a scheduled
+// notification is sent. An empty body, or no scheduled_at, sends it now.
+type RescheduleRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+}
+
+// RescheduleNotification handles POST /notifications/{id}/cancel-schedule
+func (h *Handler) RescheduleNotification(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "reschedule_notification", duration)
+	}()
+
+	id := mux.Vars(r)["id"]
+
+	var req RescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	notif, err := h.notificationService.RescheduleNotification(r.Context(), id, req.ScheduledAt)
+	if err != nil {
+		h.logger.Error("Failed to reschedule notification", zap.Error(err), zap.String("id", id))
+		switch {
+		case errors.Is(err, notification.ErrNotificationNotFound):
+			h.writeErrorResponse(w, "Notification not found", http.StatusNotFound)
+		case errors.Is(err, notification.ErrNotReschedulable):
+			h.writeErrorResponse(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, notification.ErrScheduleTooFar):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.writeErrorResponse(w, "Failed to reschedule notification", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.logger.Info("Notification rescheduled",
+		zap.String("id", notif.ID),
+		zap.Timep("scheduled_at", notif.ScheduledAt),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newNotificationResponse(notif))
+}
+
+// ListNotifications handles GET /notifications
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "list_notifications", duration)
+	}()
+
+	contentType, ok := negotiate(r, contentTypeJSON, contentTypeXML, contentTypeCSV)
+	if !ok {
+		h.writeNotAcceptable(w, contentTypeJSON, contentTypeXML, contentTypeCSV)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := notification.ListNotificationsFilter{
+		UserID:    query.Get("user_id"),
+		Channel:   query.Get("channel"),
+		Status:    notification.NotificationStatus(query.Get("status")),
+		CreatedBy: query.Get("created_by"),
+		Source:    query.Get("source"),
+		PageToken: query.Get("page_token"),
+		Limit:     defaultListLimit,
+	}
+
+	if filter.Channel != "" {
+		if err := h.validator.Var(filter.Channel, "oneof=email sms push"); err != nil {
+			h.writeErrorResponse(w, fmt.Sprintf("Invalid channel: %s", filter.Channel), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		h.writeErrorResponse(w, fmt.Sprintf("Invalid status: %s", filter.Status), http.StatusBadRequest)
+		return
+	}
+
+	if filter.Source != "" {
+		if err := h.validator.Var(filter.Source, "oneof=api grpc admin"); err != nil {
+			h.writeErrorResponse(w, fmt.Sprintf("Invalid source: %s", filter.Source), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			h.writeErrorResponse(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.notificationService.ListNotifications(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidPageToken) {
+			h.writeErrorResponse(w, "Invalid page token", http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("Failed to list notifications", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to list notifications", http.StatusInternalServerError)
+		return
+	}
+
+	response := ListNotificationsResponse{
+		Items:         newNotificationResponses(result.Notifications),
+		NextPageToken: result.NextPageToken,
+		TotalCount:    result.TotalCount,
+	}
+
+	if contentType == contentTypeCSV {
+		if err := writeNotificationsCSV(w, response); err != nil {
+			h.logger.Error("Failed to write notifications as CSV", zap.Error(err))
+		}
+		return
+	}
+	h.writeRepresentation(w, contentType, response, newNotificationListXML(response))
+}
+
+// SearchNotifications handles GET /notifications/search
+func (h *Handler) SearchNotifications(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "search_notifications", duration)
+	}()
+
+	contentType, ok := negotiate(r, contentTypeJSON, contentTypeXML, contentTypeCSV)
+	if !ok {
+		h.writeNotAcceptable(w, contentTypeJSON, contentTypeXML, contentTypeCSV)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := notification.SearchNotificationsFilter{
+		Query:     strings.TrimSpace(query.Get("q")),
+		UserID:    query.Get("user_id"),
+		Channel:   query.Get("channel"),
+		PageToken: query.Get("page_token"),
+		Limit:     defaultListLimit,
+	}
+
+	if filter.Query == "" {
+		h.writeErrorResponse(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	if filter.Channel != "" {
+		if err := h.validator.Var(filter.Channel, "oneof=email sms push"); err != nil {
+			h.writeErrorResponse(w, fmt.Sprintf("Invalid channel: %s", filter.Channel), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			h.writeErrorResponse(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	result, err := h.notificationService.SearchNotifications(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidPageToken) {
+			h.writeErrorResponse(w, "Invalid page token", http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("Failed to search notifications", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to search notifications", http.StatusInternalServerError)
+		return
+	}
+
+	response := ListNotificationsResponse{
+		Items:         newNotificationResponses(result.Notifications),
+		NextPageToken: result.NextPageToken,
+		TotalCount:    result.TotalCount,
+	}
+
+	if contentType == contentTypeCSV {
+		if err := writeNotificationsCSV(w, response); err != nil {
+			h.logger.Error("Failed to write notifications as CSV", zap.Error(err))
+		}
+		return
+	}
+	h.writeRepresentation(w, contentType, response, newNotificationListXML(response))
+}
+
+// GetUserStats handles GET /users/{id}/stats
+func (h *Handler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "get_user_stats", duration)
+	}()
+
+	userID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	from, err := parseTimeParam(query, "from")
+	if err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query, "to")
+	if err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.notificationService.GetUserStats(r.Context(), userID, from, to)
+	if err != nil {
+		h.logger.Error("Failed to get user stats", zap.Error(err), zap.String("user_id", userID))
+		h.writeErrorResponse(w, "Failed to retrieve user stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// RegisterPushToken handles PUT /users/{id}/push-token
+func (h *Handler) RegisterPushToken(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "register_push_token", duration)
+	}()
+
+	userID := mux.Vars(r)["id"]
+
+	var req notification.PushTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.notificationService.RegisterPushToken(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, notification.ErrUserNotFound) {
+			h.writeErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to register push token", zap.Error(err), zap.String("user_id", userID))
+		h.writeErrorResponse(w, "Failed to register push token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(device)
+}
+
+// ReplacePreferencesRequest represents the request body for replacing a user's preferences
+type ReplacePreferencesRequest struct {
+	Preferences []notification.UserPreferenceRequest `json:"preferences" validate:"max=100,dive"`
+}
+
+// UserPreferencesResponse represents a user's full preference set
+type UserPreferencesResponse struct {
+	UserID      string                        `json:"user_id"`
+	Preferences []notification.UserPreference `json:"preferences"`
+}
+
+// GetUserPreferences handles GET /users/{id}/preferences
+func (h *Handler) GetUserPreferences(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "get_user_preferences", duration)
+	}()
+
+	userID := mux.Vars(r)["id"]
+
+	preferences, err := h.notificationService.GetUserPreferenceSet(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to get user preferences", zap.Error(err), zap.String("user_id", userID))
+		h.writeErrorResponse(w, "Failed to get user preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserPreferencesResponse{UserID: userID, Preferences: preferences})
+}
+
+// ReplaceUserPreferences handles PUT /users/{id}/preferences/bulk
+func (h *Handler) ReplaceUserPreferences(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "replace_user_preferences", duration)
+	}()
+
+	userID := mux.Vars(r)["id"]
+
+	var req ReplacePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	preferences, err := h.notificationService.ReplaceUserPreferences(r.Context(), userID, req.Preferences)
+	if err != nil {
+		switch {
+		case errors.Is(err, notification.ErrDuplicatePreference):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, notification.ErrUserNotFound):
+			h.writeErrorResponse(w, "User not found", http.StatusNotFound)
+		default:
+			h.logger.Error("Failed to replace user preferences", zap.Error(err), zap.String("user_id", userID))
+			h.writeErrorResponse(w, "Failed to replace user preferences", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserPreferencesResponse{UserID: userID, Preferences: preferences})
+}
+
+// PreviewTemplateRequest represents the request body for previewing a template
+type PreviewTemplateRequest struct {
+	Channel   string            `json:"channel" validate:"required,oneof=email sms push"`
+	Locale    string            `json:"locale,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// PreviewTemplate handles POST /templates/{name}/preview
+func (h *Handler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "preview_template", duration)
+	}()
+
+	name := mux.Vars(r)["name"]
+
+	var req PreviewTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	preview, err := h.notificationService.PreviewTemplate(r.Context(), name, req.Channel, req.Locale, req.Variables)
+	if err != nil {
+		switch {
+		case errors.Is(err, notification.ErrTemplateNotFound):
+			h.writeErrorResponse(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, notification.ErrTemplateRender):
+			h.writeErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			h.logger.Error("Failed to preview template", zap.Error(err), zap.String("template", name))
+			h.writeErrorResponse(w, "Failed to preview template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// UpsertTemplateRequest represents the request body for creating or replacing a template
+type UpsertTemplateRequest struct {
+	Channel         string            `json:"channel" validate:"required,oneof=email sms push"`
+	Locale          string            `json:"locale,omitempty" validate:"omitempty,max=20"`
+	SubjectTemplate string            `json:"subject_template,omitempty" validate:"max=255"`
+	BodyTemplate    string            `json:"body_template" validate:"required"`
+	Variables       map[string]string `json:"variables,omitempty"`
+}
+
+// UpsertTemplate handles PUT /admin/templates/{name}
+func (h *Handler) UpsertTemplate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req UpsertTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tmpl := &notification.NotificationTemplate{
+		Name:            name,
+		Channel:         req.Channel,
+		Locale:          req.Locale,
+		SubjectTemplate: req.SubjectTemplate,
+		BodyTemplate:    req.BodyTemplate,
+		Variables:       req.Variables,
+	}
+	if err := h.notificationService.UpsertTemplate(r.Context(), tmpl); err != nil {
+		if errors.Is(err, notification.ErrTemplateRender) {
+			h.writeErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		h.logger.Error("Failed to save template", zap.Error(err), zap.String("template", name))
+		h.writeErrorResponse(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Template saved",
+		zap.String("template", name),
+		zap.String("channel", tmpl.Channel),
+		zap.String("locale", tmpl.Locale),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tmpl)
+}
+
+// PauseSending handles POST /admin/pause
+func (h *Handler) PauseSending(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumeSending handles POST /admin/resume
+func (h *Handler) ResumeSending(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// GetOverview handles GET /admin/overview. It summarizes notifications created
+// since the optional since parameter (24 hours ago by default) by status, the
+// outbox, scheduled and in-flight backlogs and Postgres and Redis health.
+func (h *Handler) GetOverview(w http.ResponseWriter, r *http.Request) {
+	since, err := parseTimeParam(r.URL.Query(), "since")
+	if err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if since == nil {
+		dayAgo := time.Now().Add(-24 * time.Hour)
+		since = &dayAgo
+	}
+
+	overview, err := h.notificationService.GetSystemOverview(r.Context(), *since)
+	if err != nil {
+		h.logger.Error("Failed to get system overview", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to retrieve system overview", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+// setPaused flips the global send pause flag
+func (h *Handler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if err := h.notificationService.SetPaused(r.Context(), paused); err != nil {
+		h.logger.Error("Failed to update pause flag", zap.Error(err), zap.Bool("paused", paused))
+		h.writeErrorResponse(w, "Failed to update pause state", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Outbound sending pause state changed", zap.Bool("paused", paused))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"paused": paused,
+	})
+}
+
+// ListSuppressions handles GET /admin/suppressions
+func (h *Handler) ListSuppressions(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			h.writeErrorResponse(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	suppressions, err := h.notificationService.ListSuppressions(r.Context(), limit)
+	if err != nil {
+		h.logger.Error("Failed to list suppressions", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to list suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppressions": suppressions,
+	})
+}
+
+// RemoveSuppression handles DELETE /admin/suppressions/{email}
+func (h *Handler) RemoveSuppression(w http.ResponseWriter, r *http.Request) {
+	email := mux.Vars(r)["email"]
+
+	if err := h.notificationService.RemoveSuppression(r.Context(), email); err != nil {
+		if errors.Is(err, notification.ErrSuppressionNotFound) {
+			h.writeErrorResponse(w, "Suppression not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to remove suppression", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to remove suppression", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Removed email suppression")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFailedCallbacks handles GET /admin/callbacks/failed, listing dead-lettered status callbacks
+func (h *Handler) ListFailedCallbacks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			h.writeErrorResponse(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	callbacks, err := h.notificationService.ListFailedCallbacks(r.Context(), limit)
+	if err != nil {
+		h.logger.Error("Failed to list failed callbacks", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to list failed callbacks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"callbacks": callbacks,
+	})
+}
+
+// RetryCallback handles POST /admin/callbacks/{id}/retry, moving a
+// dead-lettered status callback back to delivery
+func (h *Handler) RetryCallback(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid callback ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.notificationService.RetryCallback(r.Context(), id); err != nil {
+		if errors.Is(err, notification.ErrCallbackNotFound) {
+			h.writeErrorResponse(w, "Failed callback not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to retry callback", zap.Error(err))
+		h.writeErrorResponse(w, "Failed to retry callback", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequeueChannel handles POST /admin/requeue?channel=email[&include_failed=true]
+func (h *Handler) RequeueChannel(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	switch channel {
+	case "email", "sms", "push":
+	default:
+		h.writeErrorResponse(w, "channel must be one of email, sms, push", http.StatusBadRequest)
+		return
+	}
+
+	includeFailed := false
+	if value := r.URL.Query().Get("include_failed"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			h.writeErrorResponse(w, "include_failed must be a boolean", http.StatusBadRequest)
+			return
+		}
+		includeFailed = parsed
+	}
+
+	requeued, err := h.notificationService.RequeueChannel(r.Context(), channel, includeFailed)
+	if err != nil {
+		if errors.Is(err, notification.ErrNoProducer) || errors.Is(err, notification.ErrChannelDisabled) {
+			h.writeErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		h.logger.Error("Failed to requeue notifications", zap.Error(err), zap.String("channel", channel), zap.Int("requeued", requeued))
+		h.writeErrorResponse(w, "Failed to requeue notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel":  channel,
+		"requeued": requeued,
+	})
 }
 
 // HealthCheck handles GET /health
@@ -181,6 +1190,20 @@ func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
 	h.metrics.Handler().ServeHTTP(w, r)
 }
 
+// parseTimeParam parses an optional RFC 3339 timestamp query parameter
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &parsed, nil
+}
+
 // writeErrorResponse writes an error response
 func (h *Handler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	response := ErrorResponse{
@@ -200,30 +1223,113 @@ func (h *Handler) SetupRoutes() *mux.Router {
 
 	// API routes
 	api := router.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/notifications", h.CreateNotification).Methods("POST")
-	api.HandleFunc("/notifications/{id}", h.GetNotification).Methods("GET")
+
+	// Client routes, authenticated with a tenant API key when one is sent
+	api.Handle("/notifications", h.requireScope(notification.ScopeNotificationsCreate, h.CreateNotification)).Methods("POST")
+	api.Handle("/notifications", h.requireScope(notification.ScopeNotificationsRead, h.ListNotifications)).Methods("GET")
+	api.Handle("/notifications/search", h.requireScope(notification.ScopeNotificationsRead, h.SearchNotifications)).Methods("GET")
+	api.Handle("/notifications/validate", h.requireScope(notification.ScopeNotificationsCreate, h.ValidateNotification)).Methods("POST")
+	if h.testSender != nil {
+		api.Handle("/notifications/test", h.requireScope(notification.ScopeNotificationsCreate, h.TestNotification)).Methods("POST")
+	}
+	api.Handle("/notifications/{id}", h.requireScope(notification.ScopeNotificationsRead, h.GetNotification)).Methods("GET")
+	api.Handle("/notifications/{id}/status", h.requireScope(notification.ScopeNotificationsRead, h.GetNotificationStatus)).Methods("GET")
+	api.Handle("/notifications/{id}/resend", h.requireScope(notification.ScopeNotificationsCreate, h.ResendNotification)).Methods("POST")
+	api.Handle("/notifications/{id}/cancel-schedule", h.requireScope(notification.ScopeNotificationsCreate, h.RescheduleNotification)).Methods("POST")
+	api.Handle("/users/{id}/stats", h.requireScope(notification.ScopeNotificationsRead, h.GetUserStats)).Methods("GET")
+	api.Handle("/users/{id}/preferences", h.requireScope(notification.ScopePreferencesRead, h.GetUserPreferences)).Methods("GET")
+	api.Handle("/users/{id}/preferences/bulk", h.requireScope(notification.ScopePreferencesWrite, h.ReplaceUserPreferences)).Methods("PUT")
+	api.Handle("/users/{id}/push-token", h.requireScope(notification.ScopeDevicesWrite, h.RegisterPushToken)).Methods("PUT")
+	api.Handle("/templates/{name}/preview", h.requireScope(notification.ScopeTemplatesRead, h.PreviewTemplate)).Methods("POST")
+
+	api.Use(sourceMiddleware(notification.SourceAPI))
+
+	// Admin routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/overview", h.GetOverview).Methods("GET")
+	admin.HandleFunc("/pause", h.PauseSending).Methods("POST")
+	admin.HandleFunc("/resume", h.ResumeSending).Methods("POST")
+	admin.HandleFunc("/requeue", h.RequeueChannel).Methods("POST")
+	admin.HandleFunc("/suppressions", h.ListSuppressions).Methods("GET")
+	admin.HandleFunc("/suppressions/{email}", h.RemoveSuppression).Methods("DELETE")
+	admin.HandleFunc("/api-keys", h.CreateAPIKey).Methods("POST")
+	admin.HandleFunc("/api-keys", h.ListAPIKeys).Methods("GET")
+	admin.HandleFunc("/api-keys/{id}", h.RevokeAPIKey).Methods("DELETE")
+	admin.HandleFunc("/callbacks/failed", h.ListFailedCallbacks).Methods("GET")
+	admin.HandleFunc("/callbacks/{id}/retry", h.RetryCallback).Methods("POST")
+	admin.HandleFunc("/templates/{name}", h.UpsertTemplate).Methods("PUT")
+	admin.Use(h.adminAuthMiddleware)
+	admin.Use(sourceMiddleware(notification.SourceAdmin))
+
+	// Provider webhooks, only served when their signatures can be verified
+	if h.sendGridVerifier != nil {
+		webhooks := router.PathPrefix("/webhooks").Subrouter()
+		webhooks.HandleFunc("/sendgrid/events", h.SendGridEvents).Methods("POST")
+		webhooks.Use(h.WebhookSignatureMiddleware(h.sendGridVerifier))
+	}
 
 	// Health and metrics
 	router.HandleFunc("/health", h.HealthCheck).Methods("GET")
 	router.HandleFunc("/metrics", h.Metrics).Methods("GET")
 
-	// Add middleware
+	// Add middleware; recovery comes first so it also catches panics in the others
+	router.Use(h.recoveryMiddleware)
+	router.Use(h.activeConnectionsMiddleware)
 	router.Use(h.loggingMiddleware)
 	router.Use(h.corsMiddleware)
+	router.Use(h.timeoutMiddleware)
 
 	return router
 }
 
+// recoveryMiddleware turns a panic in a handler into a 500 response, logging
+// the stack, instead of dropping the connection
+func (h *Handler) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// The server aborts the response quietly on ErrAbortHandler, so re-panic
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			h.logger.Error("Recovered from panic in HTTP handler",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("panic", recovered),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// activeConnectionsMiddleware tracks in-flight requests. The decrement is
+// deferred, so requests that panic are counted out too.
+func (h *Handler) activeConnectionsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.metrics.IncrementActiveConnections()
+		defer h.metrics.DecrementActiveConnections()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // loggingMiddleware logs HTTP requests
 func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		
+
 		// Create a response recorder to capture status code
 		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
-		
+
 		next.ServeHTTP(recorder, r)
-		
+
 		duration := time.Since(start)
 		h.logger.Info("HTTP request",
 			zap.String("method", r.Method),
@@ -235,12 +1341,40 @@ func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// adminAuthMiddleware requires the configured admin bearer token
+func (h *Handler) adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			h.writeErrorResponse(w, "Admin API is not configured", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			h.writeErrorResponse(w, "Invalid or missing admin token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sourceMiddleware attributes requests to source, which is recorded on the
+// notifications they create
+func sourceMiddleware(source string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(notification.WithSource(r.Context(), source)))
+		})
+	}
+}
+
 // corsMiddleware adds CORS headers
 func (h *Handler) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -260,4 +1394,4 @@ type responseRecorder struct {
 func (r *responseRecorder) WriteHeader(statusCode int) {
 	r.statusCode = statusCode
 	r.ResponseWriter.WriteHeader(statusCode)
-}
\ No newline at end of file
+}
@@ -0,0 +1,1296 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/database/redistest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// testMetrics is shared by all tests since metrics register globally
+var testMetrics = monitoring.NewMetrics()
+
+// counterValue returns the value of the counter name with labels, or 0 when
+// it has not been recorded yet
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// histogramCount returns the number of observations of the histogram name
+// with labels, or 0 when none have been recorded yet
+func histogramCount(t *testing.T, name string, labels map[string]string) uint64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+// newTestHandler returns a handler backed by a mock database, without Redis
+// or Kafka
+func newTestHandler(t *testing.T, cfg *config.Config) (*Handler, *dbtest.Mock) {
+	t.Helper()
+	return newHandler(t, cfg, nil)
+}
+
+// newTestHandlerWithRedis returns a handler backed by a mock database and an
+// in-memory Redis server, without Kafka
+func newTestHandlerWithRedis(t *testing.T, cfg *config.Config) (*Handler, *dbtest.Mock, *redistest.Server) {
+	t.Helper()
+	redis, server := redistest.New(t)
+	h, mock := newHandler(t, cfg, redis)
+	return h, mock, server
+}
+
+func newHandler(t *testing.T, cfg *config.Config, redis *database.RedisClient) (*Handler, *dbtest.Mock) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	db, mock := dbtest.New(t)
+	service, err := notification.NewService(cfg, db, redis, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return NewHandler(service, testMetrics, zap.NewNop(), cfg.Auth), mock
+}
+
+// serve runs req through the handler's routes
+func serve(h *Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestListNotificationsFilters(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, mock := newTestHandler(t, nil)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND channel = $2 AND status = $3 AND created_at >= $4 AND created_at < $5").
+		WithArgs("user-1", "email", "sent", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(dbtest.NewRows("count").AddRow(1))
+	mock.ExpectQuery("FROM notifications WHERE user_id = $1 AND channel = $2 AND status = $3 AND created_at >= $4 AND created_at < $5 ORDER BY created_at DESC, id DESC LIMIT $6").
+		WithArgs("user-1", "email", "sent", dbtest.AnyArg(), dbtest.AnyArg(), 11).
+		WillReturnRows(notificationtest.Rows(notification.Notification{
+			ID: "n1", UserID: "user-1", Channel: "email", Recipient: "a@example.com", Body: "hi",
+			Status: notification.StatusSent, CreatedAt: createdAt, UpdatedAt: createdAt,
+		}))
+
+	req := httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&channel=email&status=sent&from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z&limit=10", nil)
+	rec := serve(h, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	var response ListNotificationsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.TotalCount != 1 || len(response.Items) != 1 || response.Items[0].ID != "n1" {
+		t.Errorf("response = %+v, want n1 of 1", response)
+	}
+	if response.NextPageToken != "" {
+		t.Errorf("NextPageToken = %q, want none on the last page", response.NextPageToken)
+	}
+}
+
+func TestListNotificationsPagination(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	page := func(ids ...string) *dbtest.Rows {
+		var notifications []notification.Notification
+		for i, id := range ids {
+			createdAt := base.Add(-time.Duration(i) * time.Minute)
+			notifications = append(notifications, notification.Notification{
+				ID: id, UserID: "user-1", Channel: "sms", Status: notification.StatusSent, CreatedAt: createdAt, UpdatedAt: createdAt,
+			})
+		}
+		return notificationtest.Rows(notifications...)
+	}
+	h, mock := newTestHandler(t, nil)
+
+	// The first page fetches one row more than the limit to detect the next page
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1").WillReturnRows(dbtest.NewRows("count").AddRow(3))
+	mock.ExpectQuery("ORDER BY created_at DESC, id DESC LIMIT $2").WithArgs("user-1", 3).WillReturnRows(page("n1", "n2", "n3"))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&limit=2", nil))
+	var first ListNotificationsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&first); err != nil {
+		t.Fatalf("failed to decode first page: %v", err)
+	}
+	if len(first.Items) != 2 || first.Items[1].ID != "n2" || first.NextPageToken == "" {
+		t.Fatalf("first page = %+v, want n1 and n2 with a next page token", first)
+	}
+
+	// The next page continues after the last row of the first one
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1").WillReturnRows(dbtest.NewRows("count").AddRow(3))
+	mock.ExpectQuery("WHERE user_id = $1 AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4").
+		WithArgs("user-1", base.Add(-time.Minute), "n2", 3).
+		WillReturnRows(page("n3"))
+
+	rec = serve(h, httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&limit=2&page_token="+first.NextPageToken, nil))
+	var second ListNotificationsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&second); err != nil {
+		t.Fatalf("failed to decode second page: %v", err)
+	}
+	if len(second.Items) != 1 || second.Items[0].ID != "n3" || second.NextPageToken != "" {
+		t.Errorf("second page = %+v, want only n3", second)
+	}
+}
+
+func TestListNotificationsRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"limit too small", "limit=0"},
+		{"limit too large", "limit=201"},
+		{"limit not a number", "limit=ten"},
+		{"unknown channel", "channel=fax"},
+		{"unknown status", "status=lost"},
+		{"invalid from", "from=yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, nil)
+			rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?"+tt.query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestListNotificationsRejectsInvalidPageToken(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications").WillReturnRows(dbtest.NewRows("count").AddRow(0))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?page_token=not-a-token", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPauseAndResumeSending(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, _, redis := newTestHandlerWithRedis(t, cfg)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantPaused bool
+	}{
+		{"pause without token", "/api/v1/admin/pause", "", http.StatusUnauthorized, false},
+		{"pause with wrong token", "/api/v1/admin/pause", "guess", http.StatusUnauthorized, false},
+		{"pause", "/api/v1/admin/pause", "admin-secret", http.StatusOK, true},
+		{"resume without token", "/api/v1/admin/resume", "", http.StatusUnauthorized, true},
+		{"resume", "/api/v1/admin/resume", "admin-secret", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := serve(h, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if _, paused := redis.Get("notifications:paused"); paused != tt.wantPaused {
+			t.Errorf("%s: paused = %v, want %v", tt.name, paused, tt.wantPaused)
+		}
+	}
+}
+
+func TestGetUserStats(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("SELECT channel, status, COUNT(*) FROM notifications WHERE user_id = $1 AND created_at >= $2 AND created_at < $3 GROUP BY channel, status").
+		WithArgs("user-1", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(dbtest.NewRows("channel", "status", "count").
+			AddRow("email", "sent", 3).
+			AddRow("email", "failed", 1).
+			AddRow("sms", "delivered", 2).
+			AddRow("push", "sent", 4))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/users/user-1/stats?from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	var stats notification.UserStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.UserID != "user-1" || stats.Total != 10 {
+		t.Errorf("stats = %+v, want 10 notifications of user-1", stats)
+	}
+	want := map[string]map[notification.NotificationStatus]int{
+		"email": {notification.StatusSent: 3, notification.StatusFailed: 1},
+		"sms":   {notification.StatusDelivered: 2},
+		"push":  {notification.StatusSent: 4},
+	}
+	for channel, statuses := range want {
+		for status, count := range statuses {
+			if got := stats.Channels[channel][status]; got != count {
+				t.Errorf("Channels[%s][%s] = %d, want %d", channel, status, got, count)
+			}
+		}
+	}
+	if len(stats.Channels["email"]) != 2 || len(stats.Channels) != 3 {
+		t.Errorf("Channels = %v, want only the counted channels and statuses", stats.Channels)
+	}
+}
+
+func TestGetUserStatsWithoutNotifications(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE user_id = $1 GROUP BY channel, status").WithArgs("user-1").
+		WillReturnRows(dbtest.NewRows("channel", "status", "count"))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/users/user-1/stats", nil))
+	var stats notification.UserStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.Total != 0 || len(stats.Channels) != 0 {
+		t.Errorf("stats = %+v, want no notifications", stats)
+	}
+}
+
+func TestGetUserStatsRejectsInvalidRange(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/users/user-1/stats?to=tomorrow", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCreateNotificationRejectsInvalidAttachment(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.SendGrid.Enabled = true
+	cfg.Channels.SendGrid.Attachments.AllowedTypes = []string{"application/pdf"}
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+
+	body := `{"user_id":"user-1","channel":"email","recipient":"a@example.com","subject":"Hi","body":"hi",
+		"attachments":[{"filename":"run.exe","content_type":"application/x-msdownload","content":"eA=="}]}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+
+	var response ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Message, "run.exe") {
+		t.Errorf("Message = %q, want the offending filename", response.Message)
+	}
+}
+
+func TestListNotificationsIncludesCollapseKey(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications").WillReturnRows(dbtest.NewRows("count").AddRow(1))
+	mock.ExpectQuery("ORDER BY created_at DESC, id DESC").
+		WillReturnRows(notificationtest.Rows(notification.Notification{
+			ID: "n1", UserID: "user-1", Channel: "push", Recipient: "token-1", Body: "3 new messages",
+			Status: notification.StatusSent, CollapseKey: "chat-42", CreatedAt: createdAt, UpdatedAt: createdAt,
+		}))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1", nil))
+	var response struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Items) != 1 || response.Items[0]["collapse_key"] != "chat-42" {
+		t.Errorf("items = %v, want n1 with collapse_key chat-42", response.Items)
+	}
+}
+
+func TestGetNotificationStatus(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("SELECT id, status, updated_at, external_id FROM notifications WHERE id = $1").WithArgs("n1", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "delivered", updatedAt, "SM123"))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var response map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]any{"id": "n1", "status": "delivered", "updated_at": "2024-05-01T12:00:00Z", "external_id": "SM123"}
+	if len(response) != len(want) {
+		t.Errorf("response = %v, want only %v", response, want)
+	}
+	for key, value := range want {
+		if response[key] != value {
+			t.Errorf("%s = %v, want %v", key, response[key], value)
+		}
+	}
+	for _, key := range []string{"body", "subject", "metadata", "recipient"} {
+		if _, ok := response[key]; ok {
+			t.Errorf("response includes %s", key)
+		}
+	}
+}
+
+func TestGetNotificationStatusNotFound(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("missing", "").
+		WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id"))
+
+	if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/missing/status", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestRegisterPushToken(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET push_token = $2").WithArgs("user-1", "token-1").WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO user_devices").WithArgs("user-1", "token-1", "android", nil).
+		WillReturnRows(dbtest.NewRows("id", "user_id", "token", "platform", "active", "created_at", "updated_at").
+			AddRow("d1", "user-1", "token-1", "android", true, time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	rec := serve(h, httptest.NewRequest("PUT", "/api/v1/users/user-1/push-token", strings.NewReader(`{"token":"token-1","platform":"android"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var device notification.UserDevice
+	if err := json.NewDecoder(rec.Body).Decode(&device); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if device.ID != "d1" || device.Token != "token-1" || !device.Active {
+		t.Errorf("device = %+v", device)
+	}
+}
+
+func TestRegisterPushTokenErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		known      bool // whether user-1 exists, when the request is valid
+		wantStatus int
+	}{
+		{"missing token", `{}`, true, http.StatusBadRequest},
+		{"unknown platform", `{"token":"token-1","platform":"windows"}`, true, http.StatusBadRequest},
+		{"unknown user", `{"token":"token-1"}`, false, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newTestHandler(t, nil)
+			if !tt.known {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE users SET push_token = $2").WillReturnResult(0)
+				mock.ExpectRollback()
+			}
+
+			rec := serve(h, httptest.NewRequest("PUT", "/api/v1/users/user-1/push-token", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSuppressionAdminEndpoints(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, mock := newTestHandler(t, cfg)
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		return serve(h, req)
+	}
+
+	mock.ExpectQuery("FROM suppressions").WithArgs(10).
+		WillReturnRows(dbtest.NewRows("email", "reason", "detail", "created_at").AddRow("a@example.com", "bounce", "", time.Now()))
+	rec := admin("GET", "/api/v1/admin/suppressions?limit=10")
+	var response struct {
+		Suppressions []notification.Suppression `json:"suppressions"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(response.Suppressions) != 1 || response.Suppressions[0].Email != "a@example.com" {
+		t.Errorf("list: status = %d, suppressions = %+v", rec.Code, response.Suppressions)
+	}
+
+	mock.ExpectExec("DELETE FROM suppressions WHERE email = $1").WithArgs("a@example.com").WillReturnResult(1)
+	if rec := admin("DELETE", "/api/v1/admin/suppressions/a@example.com"); rec.Code != http.StatusNoContent {
+		t.Errorf("remove: status = %d, want 204", rec.Code)
+	}
+
+	mock.ExpectExec("DELETE FROM suppressions WHERE email = $1").WithArgs("a@example.com").WillReturnResult(0)
+	if rec := admin("DELETE", "/api/v1/admin/suppressions/a@example.com"); rec.Code != http.StatusNotFound {
+		t.Errorf("remove again: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCreateBroadcastRejectsEmailAndSMS(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.SendGrid.Enabled = true
+	cfg.Channels.Twilio.Enabled = true
+	h, _ := newTestHandler(t, cfg)
+
+	for _, channel := range []string{"email", "sms"} {
+		body := `{"channel":"` + channel + `","topic":"news","subject":"Hi","body":"hi"}`
+		rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s broadcast: status = %d, want 400: %s", channel, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM notification_templates").
+		WillReturnRows(dbtest.NewRows("id", "name", "channel", "locale", "subject_template", "body_template", "variables", "created_at", "updated_at").
+			AddRow("t1", "welcome", "email", "en", "Welcome {{.name}}", "Hello {{.name}}, your plan is {{.plan}}", nil, time.Now(), time.Now()))
+
+	body := `{"channel":"email","variables":{"name":"Ana"}}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/templates/welcome/preview", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var preview notification.TemplatePreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if preview.Subject != "Welcome Ana" || preview.Body != "Hello Ana, your plan is " {
+		t.Errorf("preview = %+v", preview)
+	}
+	if len(preview.Warnings) != 1 || !strings.Contains(preview.Warnings[0], "plan") {
+		t.Errorf("Warnings = %v, want the unset plan variable", preview.Warnings)
+	}
+}
+
+func TestPreviewTemplateNotFound(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM notification_templates").
+		WillReturnRows(dbtest.NewRows("id", "name", "channel", "locale", "subject_template", "body_template", "variables", "created_at", "updated_at"))
+
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/templates/missing/preview", strings.NewReader(`{"channel":"sms"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestGetNotificationExpand(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := createdAt.Add(time.Second)
+	row := notification.Notification{
+		ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Your code is 1234",
+		Status: notification.StatusSent, ExternalID: "SM123", SentAt: &sentAt, CreatedAt: createdAt, UpdatedAt: sentAt,
+	}
+
+	t.Run("without expand", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+
+		rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var response map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response["id"] != "n1" || response["status"] != "sent" {
+			t.Errorf("response = %v, want n1", response)
+		}
+		for _, key := range []string{"events", "delivery_report"} {
+			if _, ok := response[key]; ok {
+				t.Errorf("response includes %s without expand", key)
+			}
+		}
+	})
+
+	t.Run("with expand", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows(row))
+		mock.ExpectQuery("FROM notification_events e").WithArgs("n1", "").
+			WillReturnRows(dbtest.NewRows("id", "notification_id", "status", "external_id", "detail", "created_at").
+				AddRow(1, "n1", "pending", nil, nil, createdAt).
+				AddRow(2, "n1", "sent", "SM123", nil, sentAt))
+
+		rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1?expand=events,delivery_report", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var response struct {
+			ID             string                           `json:"id"`
+			Events         []notification.NotificationEvent `json:"events"`
+			DeliveryReport *notification.DeliveryReport     `json:"delivery_report"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.ID != "n1" {
+			t.Errorf("id = %q, want n1", response.ID)
+		}
+		if len(response.Events) != 2 || response.Events[0].Status != notification.StatusPending ||
+			response.Events[1].Status != notification.StatusSent || response.Events[1].ExternalID != "SM123" {
+			t.Errorf("events = %+v, want pending then sent", response.Events)
+		}
+		if response.DeliveryReport == nil || response.DeliveryReport.ExternalID != "SM123" ||
+			response.DeliveryReport.AcceptedAt == nil || !response.DeliveryReport.AcceptedAt.Equal(sentAt) {
+			t.Errorf("delivery_report = %+v, want SM123 accepted at %v", response.DeliveryReport, sentAt)
+		}
+	})
+
+	t.Run("invalid expand", func(t *testing.T) {
+		h, _ := newTestHandler(t, nil)
+		if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1?expand=retries", nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}
+
+func TestRequeueChannelEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, _ := newTestHandler(t, cfg)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"channel=fax", http.StatusBadRequest},
+		{"channel=email&include_failed=maybe", http.StatusBadRequest},
+		// Without a producer nothing can be requeued
+		{"channel=email", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/admin/requeue?"+tt.query, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		if rec := serve(h, req); rec.Code != tt.want {
+			t.Errorf("POST /admin/requeue?%s status = %d, want %d", tt.query, rec.Code, tt.want)
+		}
+	}
+}
+
+// activeConnections scrapes the active connections gauge, which counts the
+// scrape request itself
+func activeConnections(t *testing.T, h *Handler) string {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, "active_connections "); ok {
+			return value
+		}
+	}
+	t.Fatal("active_connections missing from /metrics")
+	return ""
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	core, logs := observer.New(zap.ErrorLevel)
+	h.logger = zap.New(core)
+
+	router := h.SetupRoutes()
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	before := activeConnections(t, h)
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatalf("GET /panic error = %v", err)
+	}
+	var body ErrorResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || body.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, body code = %d, want 500", resp.StatusCode, body.Code)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	entries := logs.FilterMessage("Recovered from panic in HTTP handler").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d panic logs, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["panic"] != "boom" || fields["path"] != "/panic" {
+		t.Errorf("log fields = %v", fields)
+	}
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestRecoveryMiddleware") {
+		t.Errorf("stack does not include the panicking handler: %q", stack)
+	}
+
+	// The server keeps serving and the panicking request was counted out
+	resp, err = http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("GET /health after panic error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health status = %d, want 200", resp.StatusCode)
+	}
+	if after := activeConnections(t, h); after != before {
+		t.Errorf("active_connections = %s after panic, want %s", after, before)
+	}
+}
+
+func TestSearchNotificationsEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		name, query string
+	}{
+		{"missing query", ""},
+		{"blank query", "q=%20"},
+		{"invalid channel", "q=reset&channel=fax"},
+		{"invalid limit", "q=reset&limit=0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, nil)
+			rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/search?"+tt.query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+
+	t.Run("invalid page token", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE search_vector").WillReturnRows(dbtest.NewRows("count").AddRow(1))
+		rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/search?q=reset&page_token=garbage!", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		rows := notificationtest.SearchRows([]float32{0.9, 0.3},
+			notification.Notification{ID: "n1", UserID: "user-1", Channel: "email", Subject: "Password reset", Status: notification.StatusSent, CreatedAt: createdAt, UpdatedAt: createdAt},
+			notification.Notification{ID: "n2", UserID: "user-1", Channel: "email", Body: "password reset link", Status: notification.StatusSent, CreatedAt: createdAt, UpdatedAt: createdAt},
+		)
+		mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE search_vector").WithArgs("password reset", "user-1").
+			WillReturnRows(dbtest.NewRows("count").AddRow(2))
+		mock.ExpectQuery("ORDER BY ts_rank").WithArgs("password reset", "user-1", 21).
+			WillReturnRows(rows)
+
+		rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/search?q=password+reset&user_id=user-1&limit=20", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var response ListNotificationsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.TotalCount != 2 || len(response.Items) != 2 || response.Items[0].ID != "n1" || response.Items[1].ID != "n2" {
+			t.Errorf("response = %+v, want n1 then n2", response)
+		}
+	})
+}
+
+func TestCallbackAdminEndpoints(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, mock := newTestHandler(t, cfg)
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		return serve(h, req)
+	}
+
+	mock.ExpectExec("UPDATE status_callbacks SET failed_at = NULL").WithArgs(7).WillReturnResult(1)
+	if rec := admin("POST", "/api/v1/admin/callbacks/7/retry"); rec.Code != http.StatusNoContent {
+		t.Errorf("retry status = %d, want 204", rec.Code)
+	}
+	mock.ExpectExec("UPDATE status_callbacks SET failed_at = NULL").WithArgs(8).WillReturnResult(0)
+	if rec := admin("POST", "/api/v1/admin/callbacks/8/retry"); rec.Code != http.StatusNotFound {
+		t.Errorf("retry of an unknown callback status = %d, want 404", rec.Code)
+	}
+	if rec := admin("POST", "/api/v1/admin/callbacks/abc/retry"); rec.Code != http.StatusBadRequest {
+		t.Errorf("retry of an invalid ID status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCreateNotificationRejectsCallbackURLWithoutSecret(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+
+	body := `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi","callback_url":"https://example.com/hooks"}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestUpsertTemplateEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, mock := newTestHandler(t, cfg)
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/v1/admin/templates/welcome", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		return serve(h, req)
+	}
+
+	mock.ExpectQuery("INSERT INTO notification_templates").
+		WithArgs("welcome", "email", "fr", "Bienvenue", "Bonjour {{.name}}", dbtest.AnyArg(), "").
+		WillReturnRows(dbtest.NewRows("id", "created_at", "updated_at").AddRow("t1", time.Now(), time.Now()))
+	rec := put(`{"channel":"email","locale":"fr","subject_template":"Bienvenue","body_template":"Bonjour {{.name}}","variables":{"name":"Recipient name"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var saved notification.NotificationTemplate
+	if err := json.NewDecoder(rec.Body).Decode(&saved); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if saved.ID != "t1" || saved.Name != "welcome" || saved.Locale != "fr" {
+		t.Errorf("saved = %+v, want t1 welcome in fr", saved)
+	}
+
+	if rec := put(`{"channel":"email","body_template":"Hello {{.name"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("malformed template status = %d, want 422", rec.Code)
+	}
+	if rec := put(`{"channel":"fax","body_template":"Hello"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid channel status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRescheduleNotificationEndpoint(t *testing.T) {
+	scheduledAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	lockRows := func(status string) *dbtest.Rows {
+		return dbtest.NewRows("status", "queued", "send_window_start", "send_window_end").AddRow(status, false, nil, nil)
+	}
+	request := func() *http.Request {
+		return httptest.NewRequest("POST", "/api/v1/notifications/n1/cancel-schedule", strings.NewReader(`{"scheduled_at":"`+scheduledAt.Format(time.RFC3339)+`"}`))
+	}
+
+	t.Run("rescheduled", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").WithArgs("n1", "").WillReturnRows(lockRows("pending"))
+		mock.ExpectQuery("FROM outbox").WillReturnRows(dbtest.NewRows("exists").AddRow(false))
+		mock.ExpectQuery("UPDATE notifications SET scheduled_at = $2").WithArgs("n1", scheduledAt, dbtest.AnyArg()).
+			WillReturnRows(notificationtest.Rows(notification.Notification{ID: "n1", Channel: "sms", Status: notification.StatusPending, ScheduledAt: &scheduledAt}))
+		mock.ExpectCommit()
+		notificationtest.ExpectEvent(mock, "n1", notification.StatusPending)
+
+		rec := serve(h, request())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var response map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response["scheduled_at"] != scheduledAt.Format(time.RFC3339) {
+			t.Errorf("scheduled_at = %v, want %s", response["scheduled_at"], scheduledAt.Format(time.RFC3339))
+		}
+	})
+
+	t.Run("already sent", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").WillReturnRows(lockRows("sent"))
+		mock.ExpectRollback()
+
+		if rec := serve(h, request()); rec.Code != http.StatusConflict {
+			t.Errorf("status = %d, want 409", rec.Code)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").WillReturnRows(dbtest.NewRows("status", "queued", "send_window_start", "send_window_end"))
+		mock.ExpectRollback()
+
+		if rec := serve(h, request()); rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		h, _ := newTestHandler(t, nil)
+		req := httptest.NewRequest("POST", "/api/v1/notifications/n1/cancel-schedule", strings.NewReader(`{"scheduled_at":"tomorrow"}`))
+
+		if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}
+
+func TestCreateNotificationCountsBlocked(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		expect  func(mock *dbtest.Mock)
+		channel string
+		reason  string
+	}{
+		{
+			name: "channel disabled in preferences",
+			body: `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi"}`,
+			expect: func(mock *dbtest.Mock) {
+				mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+					WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+						AddRow("p1", "user-1", "sms", false, "immediate", "", "", time.Now(), time.Now()))
+			},
+			channel: "sms",
+			reason:  monitoring.BlockedPreferences,
+		},
+		{
+			name: "suppressed recipient",
+			body: `{"user_id":"user-1","channel":"email","recipient":"bounced@example.com","subject":"Hi","body":"hi"}`,
+			expect: func(mock *dbtest.Mock) {
+				mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+				mock.ExpectQuery("SELECT reason FROM suppressions WHERE email = $1").WillReturnRows(dbtest.NewRows("reason").AddRow(notification.SuppressionBounce))
+			},
+			channel: "email",
+			reason:  monitoring.BlockedSuppressed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Channels.SendGrid.Enabled = true
+			cfg.Channels.Twilio.Enabled = true
+			h, mock := newTestHandler(t, cfg)
+			tt.expect(mock)
+			labels := map[string]string{"channel": tt.channel, "reason": tt.reason}
+			before := counterValue(t, "notifications_blocked_total", labels)
+
+			rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+			}
+			if got := counterValue(t, "notifications_blocked_total", labels) - before; got != 1 {
+				t.Errorf("notifications_blocked_total%v increased by %v, want 1", labels, got)
+			}
+		})
+	}
+}
+
+func TestGetOverviewEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminToken = "admin-secret"
+	h, mock, _ := newTestHandlerWithRedis(t, cfg)
+	admin := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		return serve(h, req)
+	}
+
+	mock.ExpectQuery("SELECT status, COUNT(*) FROM notifications WHERE created_at >= $1").WithArgs(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(dbtest.NewRows("status", "count").AddRow("sent", 5).AddRow("failed", 1))
+	mock.ExpectQuery("FROM outbox WHERE published_at IS NULL").WillReturnRows(dbtest.NewRows("count").AddRow(0))
+	mock.ExpectQuery("scheduled_at <= NOW()").WillReturnRows(dbtest.NewRows("count").AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(*), MIN(queued_at)").WillReturnRows(dbtest.NewRows("count", "min").AddRow(0, nil))
+
+	rec := admin("/api/v1/admin/overview?since=2024-05-01T00:00:00Z")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var overview notification.SystemOverview
+	if err := json.NewDecoder(rec.Body).Decode(&overview); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if overview.Total != 6 || overview.Statuses[notification.StatusSent] != 5 || overview.Queue.ScheduledBacklog != 2 {
+		t.Errorf("overview = %+v, want 6 notifications and 2 scheduled", overview)
+	}
+	if !overview.Health.Healthy || !overview.Health.Database || !overview.Health.Redis {
+		t.Errorf("Health = %+v, want all healthy", overview.Health)
+	}
+
+	if rec := admin("/api/v1/admin/overview?since=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest("GET", "/api/v1/admin/overview", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without the admin token: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestCreateNotificationRejectsEmailWithoutSubject(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.SendGrid.Enabled = true
+	cfg.Channels.SendGrid.Limits.Subject = notification.SubjectRequired
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectQuery("SELECT reason FROM suppressions WHERE email = $1").WillReturnRows(dbtest.NewRows("reason"))
+
+	body := `{"user_id":"user-1","channel":"email","recipient":"a@example.com","body":"hi"}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+	var response ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Message, "subject required") {
+		t.Errorf("Message = %q, want the subject error", response.Message)
+	}
+}
+
+func TestReplaceUserPreferencesEndpoint(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectExec("DELETE FROM user_preferences WHERE user_id = $1").WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO user_preferences").WithArgs("user-1", "push", false, "hourly", nil, nil, nil).
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+			AddRow("p1", "user-1", "push", false, "hourly", "", "", time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	body := `{"preferences":[{"channel":"push","enabled":false,"frequency":"hourly"}]}`
+	rec := serve(h, httptest.NewRequest("PUT", "/api/v1/users/user-1/preferences/bulk", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var response UserPreferencesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.UserID != "user-1" || len(response.Preferences) != 1 || response.Preferences[0].Frequency != "hourly" {
+		t.Errorf("response = %+v, want the replaced push preference", response)
+	}
+}
+
+func TestReplaceUserPreferencesEndpointErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		expect func(mock *dbtest.Mock)
+		status int
+	}{
+		{name: "missing enabled", body: `{"preferences":[{"channel":"email"}]}`, status: http.StatusBadRequest},
+		{name: "unknown channel", body: `{"preferences":[{"channel":"fax","enabled":true}]}`, status: http.StatusBadRequest},
+		{
+			name:   "duplicate preference",
+			body:   `{"preferences":[{"channel":"email","enabled":true},{"channel":"email","enabled":false}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "unknown user",
+			body: `{"preferences":[]}`,
+			expect: func(mock *dbtest.Mock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(false))
+				mock.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "insert failure",
+			body: `{"preferences":[{"channel":"sms","enabled":true}]}`,
+			expect: func(mock *dbtest.Mock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+				mock.ExpectExec("DELETE FROM user_preferences WHERE user_id = $1").WillReturnResult(1)
+				mock.ExpectQuery("INSERT INTO user_preferences").WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newTestHandler(t, nil)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			rec := serve(h, httptest.NewRequest("PUT", "/api/v1/users/user-1/preferences/bulk", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetUserPreferencesEndpoint(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at"))
+
+	labels := map[string]string{"channel": "api", "operation": "get_user_preferences"}
+	before := histogramCount(t, "notification_processing_duration_seconds", labels)
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/users/user-1/preferences", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"preferences":[]`) {
+		t.Errorf("body = %s, want an empty preference list", rec.Body)
+	}
+	if got := histogramCount(t, "notification_processing_duration_seconds", labels) - before; got != 1 {
+		t.Errorf("get_user_preferences durations recorded = %d, want 1", got)
+	}
+}
+
+func TestNotificationNotFoundErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"get", "GET", "/api/v1/notifications/n1"},
+		{"status", "GET", "/api/v1/notifications/n1/status"},
+		{"resend", "POST", "/api/v1/notifications/n1/resend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newTestHandler(t, nil)
+			mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationtest.Rows())
+			if rec := serve(h, httptest.NewRequest(tt.method, tt.path, nil)); rec.Code != http.StatusNotFound {
+				t.Errorf("missing notification status = %d, want 404", rec.Code)
+			}
+
+			// Only ErrNotificationNotFound maps to 404, whatever the error reads
+			mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnError(errors.New("notification not found"))
+			if rec := serve(h, httptest.NewRequest(tt.method, tt.path, nil)); rec.Code != http.StatusInternalServerError {
+				t.Errorf("database error status = %d, want 500", rec.Code)
+			}
+		})
+	}
+}
+
+func TestScheduleBeyondHorizonRejected(t *testing.T) {
+	cfg := &config.Config{Scheduling: config.SchedulingConfig{MaxHorizon: 24 * time.Hour}}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	tooFar := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	body := `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi","scheduled_at":"` + tooFar + `"}`
+	if rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body))); rec.Code != http.StatusBadRequest {
+		t.Errorf("create status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+
+	body = `{"scheduled_at":"` + tooFar + `"}`
+	if rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications/n1/cancel-schedule", strings.NewReader(body))); rec.Code != http.StatusBadRequest {
+		t.Errorf("reschedule status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestListNotificationsAttributionFilters(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND created_by = $2 AND source = $3").
+		WithArgs("user-1", "k1", "admin").
+		WillReturnRows(dbtest.NewRows("count").AddRow(1))
+	mock.ExpectQuery("WHERE user_id = $1 AND created_by = $2 AND source = $3").
+		WillReturnRows(notificationtest.Rows(notification.Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: notification.StatusSent, CreatedBy: "k1", Source: "admin"}))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&created_by=k1&source=admin", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"created_by":"k1"`) || !strings.Contains(body, `"source":"admin"`) {
+		t.Errorf("body = %s, want the attribution", body)
+	}
+
+	if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications?user_id=user-1&source=cron", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid source status = %d, want 400", rec.Code)
+	}
+}
+
+// expectCreated expects count SMS notifications for user-1 to be stored and
+// queued in the outbox
+func expectCreated(mock *dbtest.Mock, count int) {
+	for range count {
+		mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	}
+	mock.ExpectBegin()
+	for i := range count {
+		row := notification.Notification{ID: fmt.Sprintf("n%d", i+1), UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: notification.StatusPending}
+		mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationtest.Rows(row))
+		mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	}
+	mock.ExpectCommit()
+	for range count {
+		notificationtest.ExpectEvent(mock, dbtest.AnyArg(), notification.StatusPending)
+	}
+}
+
+func TestCreateNotificationLocation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	expectCreated(mock, 1)
+
+	body := `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi"}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var response CreateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if location := rec.Header().Get("Location"); response.ID == "" || location != "/api/v1/notifications/"+response.ID {
+		t.Errorf("Location = %q, want the URL of %q", location, response.ID)
+	}
+}
+
+func TestCreateNotificationGroupLocations(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	expectCreated(mock, 2)
+
+	body := `{"user_id":"user-1","channel":"sms","recipients":["+15551234567","+15557654321"],"body":"hi"}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var response CreateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.IDs) != 2 || len(response.Locations) != 2 {
+		t.Fatalf("response = %+v, want two IDs and locations", response)
+	}
+	for i, id := range response.IDs {
+		if response.Locations[i] != "/api/v1/notifications/"+id {
+			t.Errorf("Locations[%d] = %q, want the URL of %q", i, response.Locations[i], id)
+		}
+	}
+	// A group has no single location
+	if location := rec.Header().Get("Location"); location != "" {
+		t.Errorf("Location = %q, want none", location)
+	}
+}
+
+func TestCreateNotificationRejectsInvalidProviderOptions(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+
+	body := `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi","provider_options":{"twilio":{"validity_period":"0"}}}`
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "validity_period") {
+		t.Errorf("body = %s, want the validity_period error", rec.Body)
+	}
+}
+
+func TestCreateNotificationDeferred(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	scheduledAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	// Held notifications are stored without an outbox entry
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationtest.Rows(notification.Notification{
+		ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: notification.StatusPending, ScheduledAt: &scheduledAt,
+	}))
+	mock.ExpectCommit()
+	notificationtest.ExpectEvent(mock, dbtest.AnyArg(), notification.StatusPending)
+
+	body := fmt.Sprintf(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi","scheduled_at":%q}`, scheduledAt.Format(time.RFC3339))
+	rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	var response CreateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.DeferredReason != notification.DeferredScheduled || response.ScheduledAt == nil || !response.ScheduledAt.Equal(scheduledAt) {
+		t.Errorf("deferred = %q until %v, want scheduled until %v", response.DeferredReason, response.ScheduledAt, scheduledAt)
+	}
+}
+
+func TestDeferResponse(t *testing.T) {
+	scheduledAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		notif  notification.Notification
+		status int
+	}{
+		{"queued", notification.Notification{}, http.StatusCreated},
+		{"scheduled", notification.Notification{ScheduledAt: &scheduledAt, DeferredReason: notification.DeferredScheduled}, http.StatusAccepted},
+		{"send window", notification.Notification{ScheduledAt: &scheduledAt, DeferredReason: notification.DeferredSendWindow}, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		var response CreateNotificationResponse
+		if status := deferResponse(&response, &tt.notif); status != tt.status {
+			t.Errorf("%s: deferResponse() = %d, want %d", tt.name, status, tt.status)
+		}
+		if response.DeferredReason != tt.notif.DeferredReason || response.ScheduledAt != tt.notif.ScheduledAt {
+			t.Errorf("%s: response deferred = %q until %v, want %q until %v", tt.name, response.DeferredReason, response.ScheduledAt, tt.notif.DeferredReason, tt.notif.ScheduledAt)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// NotificationResponse is the public representation of a notification. It is
+// mapped field by field from notification.Notification, so the JSON clients
+// see only changes when this type does, not when the internal model does.
+type NotificationResponse struct {
+	ID              string               `json:"id"`
+	UserID          string               `json:"user_id"`
+	Channel         string               `json:"channel"`
+	Recipient       string               `json:"recipient"`
+	Subject         string               `json:"subject,omitempty"`
+	Body            string               `json:"body"`
+	Status          string               `json:"status"`
+	ExternalID      string               `json:"external_id,omitempty"`
+	ErrorMessage    string               `json:"error_message,omitempty"`
+	RetryCount      int                  `json:"retry_count"`
+	ScheduledAt     *time.Time           `json:"scheduled_at,omitempty"`
+	ExpiresAt       *time.Time           `json:"expires_at,omitempty"`
+	SendWindowStart *time.Time           `json:"send_window_start,omitempty"`
+	SendWindowEnd   *time.Time           `json:"send_window_end,omitempty"`
+	QueuedAt        *time.Time           `json:"queued_at,omitempty"`
+	SentAt          *time.Time           `json:"sent_at,omitempty"`
+	DeliveredAt     *time.Time           `json:"delivered_at,omitempty"`
+	ResentFrom      string               `json:"resent_from,omitempty"`
+	GroupID         string               `json:"group_id,omitempty"`
+	CollapseKey     string               `json:"collapse_key,omitempty"`
+	TenantID        string               `json:"tenant_id,omitempty"`
+	Category        string               `json:"category"`
+	CallbackURL     string               `json:"callback_url,omitempty"`
+	Priority        int                  `json:"priority"`
+	CreatedBy       string               `json:"created_by,omitempty"`
+	Source          string               `json:"source,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+	Metadata        map[string]string    `json:"metadata,omitempty"`
+	Attachments     []AttachmentResponse `json:"attachments,omitempty"`
+	Truncated       []string             `json:"truncated,omitempty"`
+}
+
+// AttachmentResponse is the public representation of an attachment
+type AttachmentResponse struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content,omitempty"`
+	URL         string `json:"url,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// newNotificationResponse maps a notification to its public representation
+func newNotificationResponse(n *notification.Notification) *NotificationResponse {
+	response := &NotificationResponse{
+		ID:              n.ID,
+		UserID:          n.UserID,
+		Channel:         n.Channel,
+		Recipient:       n.Recipient,
+		Subject:         n.Subject,
+		Body:            n.Body,
+		Status:          string(n.Status),
+		ExternalID:      n.ExternalID,
+		ErrorMessage:    n.ErrorMessage,
+		RetryCount:      n.RetryCount,
+		ScheduledAt:     n.ScheduledAt,
+		ExpiresAt:       n.ExpiresAt,
+		SendWindowStart: n.SendWindowStart,
+		SendWindowEnd:   n.SendWindowEnd,
+		QueuedAt:        n.QueuedAt,
+		SentAt:          n.SentAt,
+		DeliveredAt:     n.DeliveredAt,
+		ResentFrom:      n.ResentFrom,
+		GroupID:         n.GroupID,
+		CollapseKey:     n.CollapseKey,
+		TenantID:        n.TenantID,
+		Category:        n.Category,
+		CallbackURL:     n.CallbackURL,
+		Priority:        n.Priority,
+		CreatedBy:       n.CreatedBy,
+		Source:          n.Source,
+		CreatedAt:       n.CreatedAt,
+		UpdatedAt:       n.UpdatedAt,
+		Metadata:        n.Metadata,
+		Truncated:       n.Truncated,
+	}
+	for _, a := range n.Attachments {
+		response.Attachments = append(response.Attachments, AttachmentResponse{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Content:     a.Content,
+			URL:         a.URL,
+			ContentID:   a.ContentID,
+		})
+	}
+	return response
+}
+
+// newNotificationResponses maps a page of notifications to their public
+// representation. The result is never nil, so an empty page encodes as [].
+func newNotificationResponses(notifications []*notification.Notification) []*NotificationResponse {
+	responses := make([]*NotificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		responses = append(responses, newNotificationResponse(n))
+	}
+	return responses
+}
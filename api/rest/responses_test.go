@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+)
+
+func TestNotificationResponseShape(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := createdAt.Add(time.Minute)
+	n := &notification.Notification{
+		ID: "n1", UserID: "user-1", Channel: "email", Recipient: "a@example.com", Subject: "Hi", Body: "hello",
+		Status: notification.StatusSent, ExternalID: "msg-1", RetryCount: 1, SentAt: &sentAt, GroupID: "g1",
+		Category: notification.CategoryTransactional, Priority: notification.PriorityHigh, Source: "api",
+		CreatedAt: createdAt, UpdatedAt: sentAt, Metadata: map[string]string{"order": "42"},
+		Attachments: []notification.Attachment{{Filename: "a.pdf", URL: "https://files.example.com/a.pdf"}},
+		// Internal fields are never part of the response
+		ProviderOptions: notification.ProviderOptions{"sendgrid": {"ip_pool": "transactional"}},
+		DeferredReason:  "quiet hours",
+	}
+
+	got, err := json.Marshal(newNotificationResponse(n))
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	want := `{"id":"n1","user_id":"user-1","channel":"email","recipient":"a@example.com","subject":"Hi","body":"hello",` +
+		`"status":"sent","external_id":"msg-1","retry_count":1,"sent_at":"2024-05-01T12:01:00Z","group_id":"g1",` +
+		`"category":"transactional","priority":1,"source":"api","created_at":"2024-05-01T12:00:00Z",` +
+		`"updated_at":"2024-05-01T12:01:00Z","metadata":{"order":"42"},` +
+		`"attachments":[{"filename":"a.pdf","url":"https://files.example.com/a.pdf"}]}`
+	if string(got) != want {
+		t.Errorf("response =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNotificationResponsesEncodeEmptyPage(t *testing.T) {
+	got, err := json.Marshal(newNotificationResponses(nil))
+	if err != nil {
+		t.Fatalf("failed to encode responses: %v", err)
+	}
+	if string(got) != "[]" {
+		t.Errorf("empty page = %s, want []", got)
+	}
+}
+
+func TestGetNotificationServesResponseShape(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").
+		WillReturnRows(notificationtest.Rows(notification.Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567",
+			Body: "hi", Status: notification.StatusPending, CreatedAt: createdAt, UpdatedAt: createdAt}))
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var response map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, key := range []string{"id", "user_id", "channel", "recipient", "body", "status", "retry_count", "category", "priority", "created_at", "updated_at"} {
+		if _, ok := response[key]; !ok {
+			t.Errorf("response lacks %s", key)
+		}
+	}
+	for _, key := range []string{"ID", "UserID", "provider_options", "deferred_reason", "key_version"} {
+		if _, ok := response[key]; ok {
+			t.Errorf("response includes %s", key)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// EnableSyncSend lets POST /api/v1/notifications send notifications with
+// "sync": true through manager's channels before responding
+func (h *Handler) EnableSyncSend(manager *channels.ChannelManager) {
+	h.syncSender = manager
+}
+
+// createSyncNotification sends a notification through its channel before
+// responding, for callers such as one-time passcodes that need the provider's
+// answer and accept the latency. The notification is stored with the outcome
+// and never queued. It responds 201 with the delivery report when the provider
+// accepted it and 502 when the send failed; a notification that was sent but
+// could not be stored is still reported, without a Location.
+func (h *Handler) createSyncNotification(w http.ResponseWriter, r *http.Request, notifReq notification.NotificationRequest) {
+	if h.syncSender == nil {
+		h.writeErrorResponse(w, "Synchronous sends are not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	notif, err := h.notificationService.PrepareSyncNotification(r.Context(), notifReq)
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidSyncSend) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.writeCreateError(w, notifReq.Channel, err)
+		return
+	}
+
+	report, provider, ok := sendDirect(r.Context(), h.syncSender, notif)
+	if !ok {
+		h.writeErrorResponse(w, fmt.Sprintf("Channel %s is not enabled", notif.Channel), http.StatusServiceUnavailable)
+		return
+	}
+
+	// The provider already has the notification, so its outcome is reported
+	// even when it cannot be stored
+	stored := true
+	if err := h.notificationService.RecordSyncSend(r.Context(), notif, report); err != nil {
+		h.logger.Error("Failed to persist synchronous send", zap.Error(err), zap.String("id", notif.ID))
+		stored = false
+	}
+
+	response := CreateNotificationResponse{
+		ID:        notif.ID,
+		Status:    string(report.Status),
+		Message:   "Notification sent successfully",
+		Truncated: notif.Truncated,
+		Provider:  provider,
+		Report:    report,
+	}
+	statusCode := http.StatusCreated
+	if report.Status == notification.StatusFailed {
+		h.metrics.RecordProviderFailed(notif.Channel, provider, report.ErrorType())
+		response.Message = "Notification could not be sent"
+		statusCode = http.StatusBadGateway
+	} else {
+		h.metrics.RecordProviderSent(notif.Channel, provider, "sent")
+	}
+	h.logger.Info("Notification sent synchronously",
+		zap.String("id", notif.ID),
+		zap.String("channel", notif.Channel),
+		zap.String("provider", provider),
+		zap.String("status", string(report.Status)),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	if stored {
+		w.Header().Set("Location", notificationLocation(notif.ID))
+	}
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
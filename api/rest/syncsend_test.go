@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// newSyncSendHandler returns a handler with synchronous sends enabled through
+// sender
+func newSyncSendHandler(t *testing.T, sender *fakeSender) (*Handler, *dbtest.Mock) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	manager := channels.NewChannelManager()
+	manager.RegisterChannel(sender)
+	h.EnableSyncSend(manager)
+	return h, mock
+}
+
+// syncSendRequest returns a synchronous create of an SMS to user-1
+func syncSendRequest() *http.Request {
+	body := `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"Your code is 1234","sync":true}`
+	return httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body))
+}
+
+func TestCreateNotificationSyncSend(t *testing.T) {
+	sender := &fakeSender{}
+	h, mock := newSyncSendHandler(t, sender)
+	// Stored with the outcome, without an outbox entry
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(dbtest.AnyArg(), "sent", "SM123", "sync send").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+
+	rec := serve(h, syncSendRequest())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var got CreateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(sender.sent) != 1 || got.ID != sender.sent[0].ID {
+		t.Fatalf("sent %+v, want the notification %q once", sender.sent, got.ID)
+	}
+	if got.Status != string(notification.StatusSent) || got.Provider != "twilio" || got.Report == nil || got.Report.ExternalID != "SM123" {
+		t.Errorf("response = %+v, want sent by twilio with the provider's report", got)
+	}
+	if location := rec.Header().Get("Location"); location != notificationLocation(got.ID) {
+		t.Errorf("Location = %q, want %q", location, notificationLocation(got.ID))
+	}
+}
+
+func TestCreateNotificationSyncSendFailed(t *testing.T) {
+	sender := &fakeSender{err: errors.New("twilio error: invalid number")}
+	h, mock := newSyncSendHandler(t, sender)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(dbtest.AnyArg(), "failed", nil, "sync send: twilio error: invalid number").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+
+	rec := serve(h, syncSendRequest())
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body)
+	}
+	var got CreateNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Status != string(notification.StatusFailed) || got.Report == nil || got.Report.ErrorMessage != "twilio error: invalid number" {
+		t.Errorf("response = %+v, want failed with the send error", got)
+	}
+	// The failed notification is stored and can be fetched
+	if rec.Header().Get("Location") == "" {
+		t.Error("Location not set for a stored failed send")
+	}
+}
+
+func TestCreateNotificationSyncSendNotStored(t *testing.T) {
+	sender := &fakeSender{}
+	h, mock := newSyncSendHandler(t, sender)
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectExec("INSERT INTO notifications").WillReturnError(errors.New("connection reset"))
+
+	// The provider has it, so the send is still reported
+	rec := serve(h, syncSendRequest())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if location := rec.Header().Get("Location"); location != "" {
+		t.Errorf("Location = %q, want none for an unstored notification", location)
+	}
+}
+
+func TestCreateNotificationSyncSendRejected(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Channels.Twilio.Enabled = true
+		h, _ := newTestHandler(t, cfg)
+		if rec := serve(h, syncSendRequest()); rec.Code != http.StatusNotImplemented {
+			t.Errorf("status = %d, want 501: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("channel not configured", func(t *testing.T) {
+		h, mock := newSyncSendHandler(t, &fakeSender{})
+		mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+		h.syncSender = channels.NewChannelManager()
+		if rec := serve(h, syncSendRequest()); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503: %s", rec.Code, rec.Body)
+		}
+	})
+
+	sender := &fakeSender{}
+	h, _ := newSyncSendHandler(t, sender)
+	for name, body := range map[string]string{
+		"topic":      `{"channel":"sms","topic":"news","body":"hi","sync":true}`,
+		"recipients": `{"user_id":"user-1","channel":"sms","recipients":["+15551234567","+15557654321"],"body":"hi","sync":true}`,
+		"scheduled":  `{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hi","scheduled_at":"2030-01-01T00:00:00Z","sync":true}`,
+	} {
+		rec := serve(h, httptest.NewRequest("POST", "/api/v1/notifications", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications, want none", len(sender.sent))
+	}
+}
@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// TestNotificationRequest represents the request body for a test send
+type TestNotificationRequest struct {
+	UserID          string                       `json:"user_id" validate:"required"`
+	Channel         string                       `json:"channel" validate:"required,oneof=email sms push"`
+	Recipient       string                       `json:"recipient"` // resolved from the user's contact details when empty
+	Subject         string                       `json:"subject"`
+	Body            string                       `json:"body" validate:"required_without=Template"`
+	Template        string                       `json:"template,omitempty"`
+	Locale          string                       `json:"locale,omitempty"`
+	Variables       map[string]string            `json:"variables,omitempty"`
+	Metadata        map[string]string            `json:"metadata,omitempty"`
+	ProviderOptions notification.ProviderOptions `json:"provider_options,omitempty"`
+	Attachments     []notification.Attachment    `json:"attachments,omitempty"`
+	Category        string                       `json:"category,omitempty" validate:"omitempty,oneof=transactional marketing"`
+	Priority        int                          `json:"priority,omitempty" validate:"omitempty,min=1,max=3"`
+	Persist         bool                         `json:"persist,omitempty"` // store the notification with its outcome
+}
+
+// TestNotificationResponse reports the outcome of a test send
+type TestNotificationResponse struct {
+	ID       string                       `json:"id,omitempty"` // set when the notification was persisted
+	Channel  string                       `json:"channel"`
+	Provider string                       `json:"provider"`
+	Report   *notification.DeliveryReport `json:"report"`
+}
+
+// EnableTestSend registers POST /api/v1/notifications/test, which sends
+// through manager's channels directly, when SetupRoutes is called
+func (h *Handler) EnableTestSend(manager *channels.ChannelManager) {
+	h.testSender = manager
+}
+
+// TestNotification handles POST /notifications/test. It sends the
+// notification synchronously through its channel, bypassing the queue, and
+// responds with the provider's delivery report: 200 when the provider
+// accepted it and 502 when the send failed. Nothing is stored unless persist
+// is set. Test sends always require an API key.
+func (h *Handler) TestNotification(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RecordProcessingDuration("api", "test_notification", duration)
+	}()
+
+	if notification.APIKeyFromContext(r.Context()) == nil {
+		h.writeErrorResponse(w, "Test sends require an API key", http.StatusUnauthorized)
+		return
+	}
+
+	var req TestNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.logger.Error("Request validation failed", zap.Error(err))
+		h.writeErrorResponse(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	notif, err := h.notificationService.PrepareTestNotification(r.Context(), notification.NotificationRequest{
+		UserID:          req.UserID,
+		Channel:         req.Channel,
+		Recipient:       req.Recipient,
+		Subject:         req.Subject,
+		Body:            req.Body,
+		Template:        req.Template,
+		Locale:          req.Locale,
+		Variables:       req.Variables,
+		Metadata:        req.Metadata,
+		ProviderOptions: req.ProviderOptions,
+		Attachments:     req.Attachments,
+		Category:        req.Category,
+		Priority:        req.Priority,
+	})
+	if err != nil {
+		if errors.Is(err, notification.ErrInvalidTestSend) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.writeCheckError(w, err)
+		return
+	}
+
+	report, provider, ok := sendDirect(r.Context(), h.testSender, notif)
+	if !ok {
+		h.writeErrorResponse(w, fmt.Sprintf("Channel %s is not enabled", notif.Channel), http.StatusServiceUnavailable)
+		return
+	}
+
+	response := TestNotificationResponse{
+		Channel:  notif.Channel,
+		Provider: provider,
+		Report:   report,
+	}
+	if req.Persist {
+		if err := h.notificationService.RecordTestSend(r.Context(), notif, report); err != nil {
+			h.logger.Error("Failed to persist test send", zap.Error(err), zap.String("id", notif.ID))
+			h.writeErrorResponse(w, "Failed to persist test send", http.StatusInternalServerError)
+			return
+		}
+		response.ID = notif.ID
+	}
+
+	statusCode := http.StatusOK
+	if report.Status == notification.StatusFailed {
+		h.metrics.RecordNotificationFailed(notif.Channel, "test_send_failed")
+		statusCode = http.StatusBadGateway
+	}
+	h.logger.Info("Test notification sent",
+		zap.String("channel", notif.Channel),
+		zap.String("provider", provider),
+		zap.String("status", string(report.Status)),
+		zap.Bool("persisted", req.Persist),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendDirect sends notif through its channel in manager, bypassing the queue,
+// and returns the provider's delivery report, failed when the send returned an
+// error, and the provider's name. ok is false when the channel is not enabled.
+func sendDirect(ctx context.Context, manager *channels.ChannelManager, notif *notification.Notification) (report *notification.DeliveryReport, provider string, ok bool) {
+	channel, ok := manager.GetChannel(notif.Channel)
+	if !ok {
+		return nil, "", false
+	}
+
+	report, err := channel.SendNotification(ctx, *notif)
+	if report == nil {
+		report = &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusFailed}
+	}
+	if err != nil && report.ErrorMessage == "" {
+		report.Status = notification.StatusFailed
+		report.ErrorMessage = err.Error()
+	}
+	return report, channel.GetProviderName(), true
+}
@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// fakeSender is an sms channel recording what it sent, failing with err when set
+type fakeSender struct {
+	sent []notification.Notification
+	err  error
+}
+
+func (c *fakeSender) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	c.sent = append(c.sent, notif)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusSent, ExternalID: "SM123", Provider: "twilio"}, nil
+}
+
+func (c *fakeSender) GetChannelType() string  { return "sms" }
+func (c *fakeSender) GetProviderName() string { return "twilio" }
+
+// newTestSendHandler returns a handler with test sends enabled through sender
+func newTestSendHandler(t *testing.T, sender *fakeSender) (*Handler, *dbtest.Mock) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Channels.Twilio.Enabled = true
+	h, mock := newTestHandler(t, cfg)
+	manager := channels.NewChannelManager()
+	manager.RegisterChannel(sender)
+	h.EnableTestSend(manager)
+	return h, mock
+}
+
+// testSendRequest returns a test send of body with an API key
+func testSendRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/notifications/test", strings.NewReader(body))
+	req.Header.Set(apiKeyHeader, "nsk_valid")
+	return req
+}
+
+func TestTestNotificationSendsSynchronously(t *testing.T) {
+	sender := &fakeSender{}
+	h, mock := newTestSendHandler(t, sender)
+	expectAPIKey(mock, nil, "notifications:create")
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WithArgs("user-1", "tenant-a").WillReturnRows(dbtest.NewRows("id"))
+	// Nothing is stored or queued: any further statement fails the test
+
+	rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var got TestNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.ID != "" || got.Channel != "sms" || got.Provider != "twilio" || got.Report.Status != notification.StatusSent || got.Report.ExternalID != "SM123" {
+		t.Errorf("response = %+v, report = %+v, want the provider's report without an ID", got, got.Report)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Recipient != "+15551234567" || sender.sent[0].Body != "hello" {
+		t.Errorf("sent %+v, want the test notification once", sender.sent)
+	}
+}
+
+func TestTestNotificationPersists(t *testing.T) {
+	sender := &fakeSender{}
+	h, mock := newTestSendHandler(t, sender)
+	expectAPIKey(mock, nil, "notifications:create")
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(dbtest.AnyArg(), "sent", "SM123", "test send").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+
+	rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello","persist":true}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var got TestNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.ID == "" || got.ID != sender.sent[0].ID {
+		t.Errorf("ID = %q, want the persisted notification's ID %q", got.ID, sender.sent[0].ID)
+	}
+}
+
+func TestTestNotificationRecordsAttribution(t *testing.T) {
+	sender := &fakeSender{}
+	h, mock := newTestSendHandler(t, sender)
+	expectAPIKey(mock, nil, "notifications:create")
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+	args := make([]any, 21)
+	for i := range args {
+		args[i] = dbtest.AnyArg()
+	}
+	args[19], args[20] = "k1", notification.SourceAPI
+	mock.ExpectExec("INSERT INTO notifications").WithArgs(args...).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO notification_events").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+
+	rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello","persist":true}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTestNotificationFailedSend(t *testing.T) {
+	sender := &fakeSender{err: errors.New("twilio error: invalid number")}
+	h, mock := newTestSendHandler(t, sender)
+	expectAPIKey(mock, nil, "notifications:create")
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WillReturnRows(dbtest.NewRows("id"))
+
+	rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello"}`))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body)
+	}
+	var got TestNotificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Report.Status != notification.StatusFailed || got.Report.ErrorMessage != "twilio error: invalid number" {
+		t.Errorf("report = %+v, want failed with the send error", got.Report)
+	}
+}
+
+func TestTestNotificationRejected(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		h, _ := newTestHandler(t, nil)
+		if rec := serve(h, testSendRequest(`{}`)); rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("without an API key", func(t *testing.T) {
+		sender := &fakeSender{}
+		h, _ := newTestSendHandler(t, sender)
+		req := testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello"}`)
+		req.Header.Del(apiKeyHeader)
+		if rec := serve(h, req); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+		if len(sender.sent) != 0 {
+			t.Errorf("sent %d notifications, want none", len(sender.sent))
+		}
+	})
+
+	t.Run("channel not enabled", func(t *testing.T) {
+		h, mock := newTestSendHandler(t, &fakeSender{})
+		expectAPIKey(mock, nil, "notifications:create")
+		if rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"email","recipient":"a@example.com","body":"hello"}`)); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503", rec.Code)
+		}
+	})
+
+	t.Run("preferences disabled", func(t *testing.T) {
+		sender := &fakeSender{}
+		h, mock := newTestSendHandler(t, sender)
+		expectAPIKey(mock, nil, "notifications:create")
+		mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").
+			WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+				AddRow("p1", "user-1", "sms", false, "immediate", "", "", time.Now(), time.Now()))
+		failed := map[string]string{"channel": "sms", "error_type": "preferences_disabled"}
+		blocked := map[string]string{"channel": "sms", "reason": monitoring.BlockedPreferences}
+		failedBefore := counterValue(t, "notifications_failed_total", failed)
+		blockedBefore := counterValue(t, "notifications_blocked_total", blocked)
+
+		if rec := serve(h, testSendRequest(`{"user_id":"user-1","channel":"sms","recipient":"+15551234567","body":"hello"}`)); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d, want 422: %s", rec.Code, rec.Body)
+		}
+		if len(sender.sent) != 0 {
+			t.Errorf("sent %d notifications, want none", len(sender.sent))
+		}
+		// Nothing was created, so nothing is counted as failed or blocked
+		if got := counterValue(t, "notifications_failed_total", failed) - failedBefore; got != 0 {
+			t.Errorf("notifications_failed_total%v increased by %v, want 0", failed, got)
+		}
+		if got := counterValue(t, "notifications_blocked_total", blocked) - blockedBefore; got != 0 {
+			t.Errorf("notifications_blocked_total%v increased by %v, want 0", blocked, got)
+		}
+	})
+}
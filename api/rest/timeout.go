@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SetRequestTimeout bounds every request to d: the request context is
+// cancelled once d has passed, which cancels the database queries, Redis calls
+// and provider requests made with it. Zero leaves requests unbounded.
+func (h *Handler) SetRequestTimeout(d time.Duration) {
+	h.requestTimeout = d
+}
+
+// timeoutMiddleware gives the request context a deadline of requestTimeout. A
+// handler that fails with a server error after the deadline passed failed
+// because of it, so that response is replaced by 504 Gateway Timeout.
+func (h *Handler) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.requestTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+		defer cancel()
+
+		writer := &deadlineWriter{ResponseWriter: w, ctx: ctx, handler: h}
+		next.ServeHTTP(writer, r.WithContext(ctx))
+		if writer.timedOut {
+			h.logger.Warn("Request timed out",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Duration("timeout", h.requestTimeout),
+			)
+		}
+	})
+}
+
+// deadlineWriter turns the server error a handler writes after the request
+// deadline passed into a 504, discarding the handler's body
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	handler     *Handler
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *deadlineWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if statusCode >= http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		w.handler.writeErrorResponse(w.ResponseWriter, "Request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
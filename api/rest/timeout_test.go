@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	statusRows := func() *dbtest.Rows {
+		return dbtest.NewRows("id", "status", "updated_at", "external_id").AddRow("n1", "sent", updatedAt, nil)
+	}
+
+	t.Run("slow query is cancelled", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		h.SetRequestTimeout(20 * time.Millisecond)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(statusRows()).WillDelayFor(5 * time.Second)
+
+		start := time.Now()
+		rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil))
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("request took %v, want it cancelled at the deadline", elapsed)
+		}
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status = %d, want 504: %s", rec.Code, rec.Body)
+		}
+		var response ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Message != "Request timed out" {
+			t.Errorf("Message = %q, want the timeout error alone", response.Message)
+		}
+	})
+
+	t.Run("fast query within the deadline", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		h.SetRequestTimeout(time.Second)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(statusRows())
+
+		if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil)); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("client errors are kept", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		h.SetRequestTimeout(time.Second)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(dbtest.NewRows("id", "status", "updated_at", "external_id"))
+
+		if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil)); rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		h, mock := newTestHandler(t, nil)
+		mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(statusRows()).WillDelayFor(50 * time.Millisecond)
+
+		if rec := serve(h, httptest.NewRequest("GET", "/api/v1/notifications/n1/status", nil)); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBodySize caps the body read for signature verification
+const maxWebhookBodySize = 1 << 20 // 1MB
+
+// Provider signature headers
+const (
+	twilioSignatureHeader   = "X-Twilio-Signature"
+	sendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	sendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// ErrInvalidSignature is returned by a WebhookVerifier when a callback is not
+// signed by the provider
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// WebhookVerifier checks a provider's signature over the raw request body
+type WebhookVerifier func(r *http.Request, body []byte) error
+
+type rawBodyKey struct{}
+
+// RawBody returns the request body read by WebhookSignatureMiddleware.
+// The body is also restored on r.Body, so handlers may decode it as usual.
+func RawBody(r *http.Request) []byte {
+	body, _ := r.Context().Value(rawBodyKey{}).([]byte)
+	return body
+}
+
+// WebhookSignatureMiddleware reads the raw body, verifies it with verifier and
+// rejects the request with 401 Unauthorized when the signature does not match
+func (h *Handler) WebhookSignatureMiddleware(verifier WebhookVerifier) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+			if err != nil {
+				h.writeErrorResponse(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+
+			if err := verifier(r, body); err != nil {
+				h.logger.Warn("Rejected webhook callback",
+					zap.String("path", r.URL.Path),
+					zap.Error(err),
+				)
+				h.writeErrorResponse(w, "Invalid webhook signature", http.StatusUnauthorized)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			ctx := context.WithValue(r.Context(), rawBodyKey{}, body)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NewTwilioSignatureVerifier verifies Twilio's X-Twilio-Signature: a base64
+// HMAC-SHA1, keyed with the auth token, over the full callback URL followed by
+// each POST parameter name and value in sorted order. publicURL is the
+// externally visible base URL Twilio calls, e.g. https://api.example.com.
+func NewTwilioSignatureVerifier(authToken, publicURL string) WebhookVerifier {
+	return func(r *http.Request, body []byte) error {
+		signature := r.Header.Get(twilioSignatureHeader)
+		if signature == "" {
+			return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, twilioSignatureHeader)
+		}
+
+		params, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Errorf("%w: malformed form body", ErrInvalidSignature)
+		}
+
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var payload strings.Builder
+		payload.WriteString(strings.TrimSuffix(publicURL, "/") + r.URL.RequestURI())
+		for _, k := range keys {
+			for _, v := range params[k] {
+				payload.WriteString(k)
+				payload.WriteString(v)
+			}
+		}
+
+		mac := hmac.New(sha1.New, []byte(authToken))
+		mac.Write([]byte(payload.String()))
+		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+}
+
+// NewSendGridSignatureVerifier verifies SendGrid signed event webhooks: an
+// ECDSA signature over the timestamp header followed by the raw body, checked
+// against the base64 DER verification key from the SendGrid settings page
+func NewSendGridSignatureVerifier(publicKey string) (WebhookVerifier, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SendGrid webhook public key: %w", err)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SendGrid webhook public key: %w", err)
+	}
+
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("SendGrid webhook public key is not an ECDSA key")
+	}
+
+	return func(r *http.Request, body []byte) error {
+		signature := r.Header.Get(sendGridSignatureHeader)
+		timestamp := r.Header.Get(sendGridTimestampHeader)
+		if signature == "" || timestamp == "" {
+			return fmt.Errorf("%w: missing %s or %s header", ErrInvalidSignature, sendGridSignatureHeader, sendGridTimestampHeader)
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			return fmt.Errorf("%w: signature is not valid base64", ErrInvalidSignature)
+		}
+
+		digest := sha256.Sum256(append([]byte(timestamp), body...))
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}, nil
+}
+
+// sendGridEvent is the part of a SendGrid event webhook entry used for suppressions
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`  // processed, delivered, bounce, spamreport, ...
+	Type   string `json:"type"`   // for bounce events: bounce (hard) or blocked (soft)
+	Reason string `json:"reason"` // provider bounce reason
+}
+
+// EnableSendGridEvents registers the SendGrid event webhook, verified with
+// verifier, when SetupRoutes is called
+func (h *Handler) EnableSendGridEvents(verifier WebhookVerifier) {
+	h.sendGridVerifier = verifier
+}
+
+// SendGridEvents handles POST /webhooks/sendgrid/events. Hard bounces and
+// spam reports add the address to the suppression list.
+func (h *Handler) SendGridEvents(w http.ResponseWriter, r *http.Request) {
+	var events []sendGridEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		h.logger.Error("Failed to decode SendGrid events", zap.Error(err))
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range events {
+		var reason string
+		switch {
+		case event.Event == "bounce" && event.Type != "blocked":
+			reason = notification.SuppressionBounce
+		case event.Event == "spamreport":
+			reason = notification.SuppressionComplaint
+		default:
+			continue
+		}
+		if event.Email == "" {
+			continue
+		}
+
+		if err := h.notificationService.AddSuppression(r.Context(), event.Email, reason, event.Reason); err != nil {
+			// A 5xx makes SendGrid retry the whole batch, which is safe as suppressions are upserts
+			h.logger.Error("Failed to add suppression", zap.Error(err), zap.String("event", event.Event))
+			h.writeErrorResponse(w, "Failed to process events", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
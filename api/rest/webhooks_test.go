@@ -0,0 +1,218 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// signTwilio returns Twilio's signature of a form POST of params to rawURL
+func signTwilio(authToken, rawURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	payload := rawURL
+	for _, k := range keys {
+		for _, v := range params[k] {
+			payload += k + v
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newSendGridKey returns an ECDSA key and its public part encoded as on the
+// SendGrid settings page
+func newSendGridKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+// signSendGrid returns SendGrid's signature of body sent at timestamp
+func signSendGrid(t *testing.T, key *ecdsa.PrivateKey, timestamp string, body []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestTwilioSignatureVerifier(t *testing.T) {
+	const token = "twilio-token"
+	const path = "/api/v1/webhooks/twilio/status?tenant=a"
+	params := url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"delivered"}, "To": {"+15551234567"}}
+	body := []byte(params.Encode())
+	verify := NewTwilioSignatureVerifier(token, "https://api.example.com/")
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", signTwilio(token, "https://api.example.com"+path, params), body, false},
+		{"wrong token", signTwilio("other-token", "https://api.example.com"+path, params), body, true},
+		{"wrong url", signTwilio(token, "https://evil.example.com"+path, params), body, true},
+		{"tampered body", signTwilio(token, "https://api.example.com"+path, params), []byte(strings.Replace(string(body), "delivered", "failed", 1)), true},
+		{"missing signature", "", body, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", path, nil)
+			if tt.signature != "" {
+				req.Header.Set(twilioSignatureHeader, tt.signature)
+			}
+			err := verify(req, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verify() error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestSendGridSignatureVerifier(t *testing.T) {
+	key, publicKey := newSendGridKey(t)
+	otherKey, _ := newSendGridKey(t)
+	verify, err := NewSendGridSignatureVerifier(publicKey)
+	if err != nil {
+		t.Fatalf("NewSendGridSignatureVerifier() error = %v", err)
+	}
+
+	const timestamp = "1700000000"
+	body := []byte(`[{"email":"a@example.com","event":"delivered"}]`)
+
+	tests := []struct {
+		name      string
+		signature string
+		timestamp string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", signSendGrid(t, key, timestamp, body), timestamp, body, false},
+		{"other key", signSendGrid(t, otherKey, timestamp, body), timestamp, body, true},
+		{"tampered body", signSendGrid(t, key, timestamp, body), timestamp, []byte(`[{"email":"a@example.com","event":"bounce"}]`), true},
+		{"replayed with another timestamp", signSendGrid(t, key, timestamp, body), "1700000001", body, true},
+		{"signature not base64", "not base64!", timestamp, body, true},
+		{"missing signature", "", timestamp, body, true},
+		{"missing timestamp", signSendGrid(t, key, timestamp, body), "", body, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/webhooks/sendgrid/events", nil)
+			if tt.signature != "" {
+				req.Header.Set(sendGridSignatureHeader, tt.signature)
+			}
+			if tt.timestamp != "" {
+				req.Header.Set(sendGridTimestampHeader, tt.timestamp)
+			}
+			err := verify(req, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verify() error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestNewSendGridSignatureVerifierRejectsInvalidKeys(t *testing.T) {
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("not a key"))} {
+		if _, err := NewSendGridSignatureVerifier(key); err == nil {
+			t.Errorf("NewSendGridSignatureVerifier(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestWebhookSignatureMiddleware(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	verify := func(r *http.Request, body []byte) error {
+		if r.Header.Get("X-Signature") != string(body) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	var rawBody, decodedBody []byte
+	called := false
+	handler := h.WebhookSignatureMiddleware(verify)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		rawBody = RawBody(r)
+		decodedBody, _ = io.ReadAll(r.Body)
+	}))
+
+	// A forged callback never reaches the handler
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader("payload"))
+	req.Header.Set("X-Signature", "forged")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || called {
+		t.Fatalf("forged callback: status = %d, handler called = %v, want 401 without the handler", rec.Code, called)
+	}
+
+	// A signed callback reaches it with the raw body, which can still be read
+	req = httptest.NewRequest("POST", "/webhook", strings.NewReader("payload"))
+	req.Header.Set("X-Signature", "payload")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !called {
+		t.Fatalf("signed callback: status = %d, handler called = %v", rec.Code, called)
+	}
+	if string(rawBody) != "payload" || string(decodedBody) != "payload" {
+		t.Errorf("handler got raw body %q and body %q, want the payload twice", rawBody, decodedBody)
+	}
+}
+
+func TestSendGridEventsSuppressBouncesAndComplaints(t *testing.T) {
+	h, mock := newTestHandler(t, nil)
+	mock.ExpectExec("INSERT INTO suppressions").WithArgs("bounced@example.com", notification.SuppressionBounce, "550 mailbox unavailable").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO suppressions").WithArgs("spam@example.com", notification.SuppressionComplaint, nil).WillReturnResult(1)
+
+	// Deliveries, blocks and events without an address are not suppressed
+	body := `[
+		{"email":"ok@example.com","event":"delivered"},
+		{"email":"bounced@example.com","event":"bounce","type":"bounce","reason":"550 mailbox unavailable"},
+		{"email":"blocked@example.com","event":"bounce","type":"blocked"},
+		{"email":"spam@example.com","event":"spamreport"},
+		{"event":"bounce"}
+	]`
+	rec := httptest.NewRecorder()
+	h.SendGridEvents(rec, httptest.NewRequest("POST", "/webhooks/sendgrid/events", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
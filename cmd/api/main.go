@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -18,16 +19,45 @@ import (
 	grpcapi "github.com/alexnthnz/notification-system/api/grpc"
 	pb "github.com/alexnthnz/notification-system/api/proto/gen"
 	"github.com/alexnthnz/notification-system/api/rest"
+	"github.com/alexnthnz/notification-system/internal/channels"
 	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/logging"
 	"github.com/alexnthnz/notification-system/internal/monitoring"
 	"github.com/alexnthnz/notification-system/internal/notification"
 	"github.com/alexnthnz/notification-system/internal/queue"
 )
 
+const (
+	// scheduleInterval is how often due scheduled notifications are dispatched
+	scheduleInterval = time.Second
+	// reconcileInterval is how often unqueued notifications are looked for
+	reconcileInterval = time.Minute
+	// reconcileAfter is how old a pending notification must be before it is republished
+	reconcileAfter = 2 * time.Minute
+	// outboxRelayInterval is how often the outbox is checked for unpublished rows
+	outboxRelayInterval = time.Second
+	// outboxRetention is how long published outbox rows are kept for debugging
+	outboxRetention = 24 * time.Hour
+	// callbackInterval is how often status callbacks are checked for delivery
+	callbackInterval = time.Second
+	// callbackRetention is how long delivered status callbacks are kept for debugging
+	callbackRetention = 24 * time.Hour
+	// staleSweepInterval is how often sent notifications are checked against the delivery SLA
+	staleSweepInterval = time.Minute
+	// preferenceWarmTimeout bounds warming the preference cache at startup
+	preferenceWarmTimeout = time.Minute
+)
+
 func main() {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
 	// Initialize logger
-	logger, err := zap.NewProduction()
+	logger, err := logging.NewLogger(cfg.Log)
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
@@ -35,12 +65,6 @@ func main() {
 
 	logger.Info("Starting Notification API Service")
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatal("Failed to load config", zap.Error(err))
-	}
-
 	// Initialize metrics
 	metrics := monitoring.NewMetrics()
 	logger.Info("Metrics initialized")
@@ -66,19 +90,95 @@ func main() {
 	defer redis.Close()
 	logger.Info("Redis connected")
 
+	// Fail fast if the channel topics are missing, or create them when configured
+	if err := queue.EnsureTopics(context.Background(), cfg.Kafka, queue.ChannelTopics(cfg.Kafka.Topic, "email", "sms", "push"), logger); err != nil {
+		logger.Fatal("Kafka topics are not ready", zap.Error(err))
+	}
+
 	// Initialize Kafka producer
-	producer := queue.NewProducer(cfg.Kafka)
+	producer := queue.NewProducer(cfg.Kafka, logger)
 	defer producer.Close()
 	logger.Info("Kafka producer initialized")
 
 	// Initialize notification service
-	notificationService := notification.NewService(postgres, redis, producer)
+	notificationService, err := notification.NewService(cfg, postgres, redis, producer, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize notification service", zap.Error(err))
+	}
 	logger.Info("Notification service initialized")
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Cache the most active users' preferences before traffic arrives
+	if cfg.Redis.WarmPreferences > 0 && cfg.Redis.PreferenceTTL > 0 {
+		go warmPreferenceCache(jobsCtx, notificationService, cfg.Redis, logger)
+	}
+
+	// Publish scheduled notifications once they are due, tracking the overdue backlog
+	go dispatchScheduled(jobsCtx, notificationService, metrics, logger)
+
+	// Publish outbox rows whose publish failed when their notification was created
+	go relayOutbox(jobsCtx, notificationService, logger)
+
+	// Republish notifications whose publish failed when they were created
+	go reconcileUnqueued(jobsCtx, notificationService, logger)
+
+	// POST status changes to the callback URLs of notifications
+	if cfg.Callbacks.SigningSecret != "" {
+		go deliverCallbacks(jobsCtx, notificationService, metrics, logger)
+	}
+
+	// Flag sent notifications that were never confirmed delivered
+	if cfg.Delivery.SLA > 0 {
+		policy := notification.NewStalePolicy(cfg.Delivery.StalePolicy, logger)
+		go sweepStaleSent(jobsCtx, notificationService, cfg.Delivery.SLA, policy, metrics, logger)
+	}
+
+	// Publish messages buffered on disk while Kafka was unavailable
+	if cfg.Kafka.BufferDir != "" {
+		go flushProducerBuffer(jobsCtx, producer, cfg.Kafka.BufferFlushInterval, metrics, logger)
+	}
+
 	// Initialize REST API handler
-	handler := rest.NewHandler(notificationService, metrics, logger)
+	handler := rest.NewHandler(notificationService, metrics, logger, cfg.Auth)
+	handler.SetRequestTimeout(cfg.API.RequestTimeout)
+	if key := cfg.Channels.SendGrid.WebhookPublicKey; key != "" {
+		verifier, err := rest.NewSendGridSignatureVerifier(key)
+		if err != nil {
+			logger.Fatal("Failed to load SendGrid webhook public key", zap.Error(err))
+		}
+		handler.EnableSendGridEvents(verifier)
+	} else {
+		logger.Warn("SendGrid webhook public key not set, bounce and complaint events are not accepted")
+	}
+	if cfg.API.TestSendEnabled || cfg.API.SyncSendEnabled {
+		manager, err := channels.NewConfiguredManager(jobsCtx, cfg.Channels, nil, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize channels for direct sends", zap.Error(err))
+		}
+		if cfg.API.TestSendEnabled {
+			handler.EnableTestSend(manager)
+			logger.Info("Test sends enabled", zap.Strings("channels", manager.ChannelTypes()))
+		}
+		if cfg.API.SyncSendEnabled {
+			handler.EnableSyncSend(manager)
+			logger.Info("Synchronous sends enabled", zap.Strings("channels", manager.ChannelTypes()))
+		}
+	}
 	router := handler.SetupRoutes()
 
+	// Initialize the gRPC handler, shared by the gRPC server and the gateway
+	grpcHandler := grpcapi.NewServer(notificationService, metrics, logger)
+
+	// Serve the gRPC API as JSON under /v1 through the gateway, calling the
+	// gRPC handler in-process
+	gateway := runtime.NewServeMux()
+	if err := pb.RegisterNotificationServiceHandlerServer(jobsCtx, gateway, grpcHandler); err != nil {
+		logger.Fatal("Failed to register gRPC gateway", zap.Error(err))
+	}
+	router.PathPrefix("/v1/").Handler(handler.APIKeyMiddleware(gateway))
+
 	// Create HTTP server
 	httpServer := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.API.Host, cfg.API.Port),
@@ -97,12 +197,14 @@ func main() {
 	}()
 
 	// Initialize gRPC server
-	grpcServer := grpc.NewServer()
-	grpcHandler := grpcapi.NewServer(notificationService, metrics, logger)
-	
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcapi.APIKeyInterceptor(notificationService, cfg.Auth.RequireAPIKey, logger)),
+		grpc.StreamInterceptor(grpcapi.APIKeyStreamInterceptor(notificationService, cfg.Auth.RequireAPIKey, logger)),
+	)
+
 	// Register the notification service
 	pb.RegisterNotificationServiceServer(grpcServer, grpcHandler)
-	
+
 	// Enable reflection for grpcurl and other tools
 	reflection.Register(grpcServer)
 
@@ -121,8 +223,9 @@ func main() {
 	}()
 
 	// Start metrics server if enabled
+	var metricsServer *http.Server
 	if cfg.Metrics.Enabled {
-		metricsServer := &http.Server{
+		metricsServer = &http.Server{
 			Addr:    fmt.Sprintf(":%d", cfg.Metrics.Port),
 			Handler: metrics.Handler(),
 		}
@@ -146,13 +249,291 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	shutdownServers(ctx, httpServer, grpcServer, metricsServer, logger)
+
+	logger.Info("Servers exited")
+}
+
+// shutdownServers gracefully stops the HTTP, gRPC and, when enabled, metrics
+// servers, forcing them closed once ctx expires
+func shutdownServers(
+	ctx context.Context,
+	httpServer *http.Server,
+	grpcServer *grpc.Server,
+	metricsServer *http.Server,
+	logger *zap.Logger,
+) {
 	// Shutdown HTTP server
 	if err := httpServer.Shutdown(ctx); err != nil {
 		logger.Error("HTTP server forced to shutdown", zap.Error(err))
 	}
 
-	// Shutdown gRPC server
-	grpcServer.GracefulStop()
+	// Shutdown gRPC server, stopping open streams if draining outlasts ctx
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Error("gRPC server forced to shutdown", zap.Error(ctx.Err()))
+		grpcServer.Stop()
+	}
 
-	logger.Info("Servers exited")
-}
\ No newline at end of file
+	// Shutdown metrics server last so metrics stay scrapeable while draining
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			logger.Error("Metrics server forced to shutdown", zap.Error(err))
+		}
+	}
+}
+
+// warmPreferenceCache caches the preferences of the most active users once,
+// within preferenceWarmTimeout. Failing only leaves the cache to fill as
+// requests arrive, so errors are logged rather than fatal.
+func warmPreferenceCache(ctx context.Context, notificationService *notification.Service, cfg config.RedisConfig, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, preferenceWarmTimeout)
+	defer cancel()
+
+	start := time.Now()
+	count, err := notificationService.WarmPreferenceCache(ctx, cfg.WarmPreferences, cfg.WarmWindow)
+	if err != nil {
+		logger.Warn("Failed to warm preference cache", zap.Int("users", count), zap.Error(err))
+		return
+	}
+	logger.Info("Warmed preference cache", zap.Int("users", count), zap.Duration("duration", time.Since(start)))
+}
+
+// dispatchScheduled publishes due scheduled notifications every
+// scheduleInterval until ctx is cancelled
+func dispatchScheduled(ctx context.Context, notificationService *notification.Service, metrics *monitoring.Metrics, logger *zap.Logger) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dispatchDue(ctx, notificationService, metrics, logger)
+	}
+}
+
+// dispatchDue publishes the scheduled notifications due now, following a full
+// batch with another run right away, and counts those cancelled because their
+// expiry or send window passed as blocked. It then refreshes the
+// scheduled_backlog gauge with the notifications still overdue, so operators
+// can alert on a lagging scheduler.
+func dispatchDue(ctx context.Context, notificationService *notification.Service, metrics *monitoring.Metrics, logger *zap.Logger) {
+	defer refreshScheduledBacklog(ctx, notificationService, metrics, logger)
+
+	for {
+		count, expired, err := notificationService.DispatchScheduled(ctx)
+		for channel, n := range expired {
+			metrics.RecordScheduledExpired(channel, n)
+		}
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Failed to dispatch scheduled notifications", zap.Error(err))
+		}
+		if count > 0 {
+			logger.Info("Dispatched scheduled notifications", zap.Int("count", count))
+		}
+		if err != nil || count < notification.ScheduledBatchSize {
+			return
+		}
+	}
+}
+
+// refreshScheduledBacklog sets the scheduled_backlog gauge to the number of
+// overdue scheduled notifications not yet dispatched
+func refreshScheduledBacklog(ctx context.Context, notificationService *notification.Service, metrics *monitoring.Metrics, logger *zap.Logger) {
+	count, err := notificationService.CountScheduledBacklog(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to count scheduled backlog", zap.Error(err))
+		}
+		return
+	}
+	metrics.SetScheduledBacklog(float64(count))
+}
+
+// reconcileUnqueued republishes pending notifications that never reached the
+// queue, and purges outbox rows published more than outboxRetention ago and
+// expired dedup claims, every reconcileInterval until ctx is cancelled
+func reconcileUnqueued(ctx context.Context, notificationService *notification.Service, logger *zap.Logger) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		count, err := notificationService.ReconcileUnqueued(ctx, reconcileAfter)
+		if err != nil {
+			logger.Error("Failed to reconcile unqueued notifications", zap.Error(err))
+		}
+		if count > 0 {
+			logger.Info("Republished unqueued notifications", zap.Int("count", count))
+		}
+
+		if _, err := notificationService.PurgeOutbox(ctx, outboxRetention); err != nil {
+			logger.Error("Failed to purge outbox", zap.Error(err))
+		}
+		if _, err := notificationService.PurgeDedupClaims(ctx); err != nil {
+			logger.Error("Failed to purge dedup claims", zap.Error(err))
+		}
+	}
+}
+
+// relayOutbox publishes the notifications left in the outbox every
+// outboxRelayInterval until ctx is cancelled. A full batch is followed by
+// another run right away.
+func relayOutbox(ctx context.Context, notificationService *notification.Service, logger *zap.Logger) {
+	ticker := time.NewTicker(outboxRelayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			count, err := notificationService.RelayOutbox(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Failed to relay outbox", zap.Error(err))
+			}
+			if count > 0 {
+				logger.Info("Relayed outbox notifications", zap.Int("count", count))
+			}
+			if err != nil || count < notification.OutboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverCallbacks POSTs due status callbacks every callbackInterval until
+// ctx is cancelled, purging delivered ones older than callbackRetention once
+// a minute. A full batch is followed by another run right away.
+func deliverCallbacks(ctx context.Context, notificationService *notification.Service, metrics *monitoring.Metrics, logger *zap.Logger) {
+	ticker := time.NewTicker(callbackInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			result, err := notificationService.DeliverCallbacks(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("Failed to deliver status callbacks", zap.Error(err))
+				}
+				break
+			}
+
+			metrics.RecordStatusCallbacks("delivered", result.Delivered)
+			metrics.RecordStatusCallbacks("retried", result.Retried)
+			metrics.RecordStatusCallbacks("dead_lettered", result.DeadLettered)
+			if result.Delivered+result.Retried+result.DeadLettered < notification.CallbackBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= time.Minute {
+			lastPurge = time.Now()
+			if _, err := notificationService.PurgeCallbacks(ctx, callbackRetention); err != nil {
+				logger.Error("Failed to purge status callbacks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// sweepStaleSent flags sent notifications past the delivery SLA in the
+// delivery_stale_total metric, applying policy to them, every
+// staleSweepInterval until ctx is cancelled. A full batch is followed by
+// another run right away.
+func sweepStaleSent(
+	ctx context.Context,
+	notificationService *notification.Service,
+	sla time.Duration,
+	policy string,
+	metrics *monitoring.Metrics,
+	logger *zap.Logger,
+) {
+	ticker := time.NewTicker(staleSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			counts, err := notificationService.SweepStaleSent(ctx, sla, policy)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("Failed to sweep stale notifications", zap.Error(err))
+				}
+				break
+			}
+
+			total := 0
+			for channel, count := range counts {
+				metrics.RecordDeliveryStale(channel, count)
+				total += count
+			}
+			if total < notification.StaleBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// flushProducerBuffer publishes messages the producer buffered while Kafka was
+// unavailable every interval until ctx is cancelled, keeping the buffer size
+// metric current
+func flushProducerBuffer(
+	ctx context.Context,
+	producer *queue.Producer,
+	interval time.Duration,
+	metrics *monitoring.Metrics,
+	logger *zap.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		metrics.SetProducerBuffered(float64(producer.Buffered()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if producer.Buffered() == 0 {
+			continue
+		}
+		if _, err := producer.FlushBuffer(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("Kafka still unavailable, keeping buffered notifications",
+				zap.Int("buffered", producer.Buffered()),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// testMetrics is shared by all tests since metrics register globally
+var testMetrics = monitoring.NewMetrics()
+
+// gaugeValue returns the current value of the registered gauge name
+func gaugeValue(t *testing.T, name string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) > 0 {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("metric %s is not registered", name)
+	return 0
+}
+
+// counterValue returns the current value of the registered counter name with
+// the given labels, or 0 if it has not been incremented yet
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestDispatchDueRecordsExpired(t *testing.T) {
+	db, mock := dbtest.New(t)
+	service, err := notification.NewService(&config.Config{}, db, nil, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	expired := notification.Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: notification.StatusCancelled, ErrorMessage: "expired", ExpiresAt: &past}
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WillReturnRows(notificationtest.Rows(expired, expired))
+	for range 2 {
+		notificationtest.ExpectEvent(mock, "n1", notification.StatusCancelled)
+	}
+	mock.ExpectBegin().WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications").WillReturnRows(dbtest.NewRows("count").AddRow(0))
+
+	labels := map[string]string{"channel": "sms", "reason": monitoring.BlockedExpired}
+	before := counterValue(t, "notifications_blocked_total", labels)
+	dispatchDue(context.Background(), service, testMetrics, zap.NewNop())
+
+	if got := counterValue(t, "notifications_blocked_total", labels) - before; got != 2 {
+		t.Errorf("notifications_blocked_total{channel=sms,reason=expired} increased by %v, want 2", got)
+	}
+}
+
+func TestDispatchDueRefreshesScheduledBacklog(t *testing.T) {
+	db, mock := dbtest.New(t)
+	service, err := notification.NewService(&config.Config{}, db, nil, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	// Dispatching fails, so three pending notifications stay overdue and out
+	// of the outbox
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WillReturnRows(notificationtest.Rows())
+	mock.ExpectBegin().WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE status = $1 AND queued_at IS NULL AND scheduled_at IS NOT NULL AND scheduled_at <= NOW()").
+		WithArgs("pending").
+		WillReturnRows(dbtest.NewRows("count").AddRow(3))
+
+	dispatchDue(context.Background(), service, testMetrics, zap.NewNop())
+
+	if got := gaugeValue(t, "scheduled_backlog"); got != 3 {
+		t.Errorf("scheduled_backlog = %v, want 3", got)
+	}
+}
+
+// serveHTTP serves server on a local port and returns its address and the
+// error Serve returned once it stops
+func serveHTTP(t *testing.T, server *http.Server) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(listener) }()
+	return listener.Addr().String(), done
+}
+
+// stopped returns the error sent on done, failing the test if it takes long
+func stopped(t *testing.T, name string, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s server did not stop", name)
+		return nil
+	}
+}
+
+func TestShutdownServers(t *testing.T) {
+	for _, withMetrics := range []bool{true, false} {
+		httpServer := &http.Server{Handler: http.NotFoundHandler()}
+		httpAddr, httpDone := serveHTTP(t, httpServer)
+
+		grpcServer := grpc.NewServer()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		grpcDone := make(chan error, 1)
+		go func() { grpcDone <- grpcServer.Serve(listener) }()
+
+		var metricsServer *http.Server
+		var metricsAddr string
+		var metricsDone <-chan error
+		if withMetrics {
+			metricsServer = &http.Server{Handler: testMetrics.Handler()}
+			metricsAddr, metricsDone = serveHTTP(t, metricsServer)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownServers(ctx, httpServer, grpcServer, metricsServer, zap.NewNop())
+		cancel()
+
+		if err := stopped(t, "HTTP", httpDone); !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("HTTP Serve() = %v, want http.ErrServerClosed", err)
+		}
+		// Serve returns ErrServerStopped when the stop came before it started
+		if err := stopped(t, "gRPC", grpcDone); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Errorf("gRPC Serve() = %v", err)
+		}
+		addrs := []string{httpAddr, listener.Addr().String()}
+		if withMetrics {
+			if err := stopped(t, "metrics", metricsDone); !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("metrics Serve() = %v, want http.ErrServerClosed", err)
+			}
+			addrs = append(addrs, metricsAddr)
+		}
+
+		// No listener is left open
+		for _, addr := range addrs {
+			if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+				conn.Close()
+				t.Errorf("%s still accepts connections after shutdown", addr)
+			}
+		}
+	}
+}
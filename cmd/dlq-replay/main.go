@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/logging"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+// replayFilter selects the dead letters to replay; empty fields match every
+// dead letter
+type replayFilter struct {
+	channel string
+	reason  string // text the dead letter's error must contain
+}
+
+// matches reports whether dead passes the filter
+func (f replayFilter) matches(dead *queue.DeadLetter) bool {
+	if f.channel != "" && dead.Channel != f.channel {
+		return false
+	}
+	return f.reason == "" || strings.Contains(dead.Error, f.reason)
+}
+
+func main() {
+	channel := flag.String("channel", "", "only replay dead letters for this channel (email, sms, push)")
+	reason := flag.String("reason", "", "only replay dead letters whose error contains this text")
+	dryRun := flag.Bool("dry-run", false, "count and log matching dead letters without republishing them")
+	limit := flag.Int("limit", 0, "stop after this many matching dead letters (0 for no limit)")
+	idle := flag.Duration("idle-timeout", 10*time.Second, "stop when no dead letter arrives for this long")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := logging.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// Connect to PostgreSQL to reopen failed notifications before they are replayed
+	postgres, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+	}
+	defer postgres.Close()
+
+	// Redis is optional here: it lets reopened notifications drop their cache
+	// entries and delivery claims, so replaying works without it
+	redis, err := database.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Warn("Failed to connect to Redis, continuing without it", zap.Error(err))
+		redis = nil
+	} else {
+		defer redis.Close()
+	}
+
+	notificationService, err := notification.NewService(cfg, postgres, redis, nil, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize notification service", zap.Error(err))
+	}
+
+	replayer := queue.NewDLQReplayer(cfg.Kafka, "dlq-replay", logger)
+	defer replayer.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logger.Info("Replaying dead letters",
+		zap.String("topic", queue.DLQTopic(cfg.Kafka.Topic)),
+		zap.String("channel", *channel),
+		zap.String("reason", *reason),
+		zap.Bool("dry_run", *dryRun),
+	)
+
+	filter := replayFilter{channel: *channel, reason: *reason}
+
+	// Dead letters that don't match the filters are put back on the dead letter
+	// topic once the run is over, so they are kept for a later replay
+	var skipped []*queue.DeadLetter
+	var runErr error
+	matched, replayed := 0, 0
+
+	for *limit == 0 || matched < *limit {
+		fetchCtx, cancelFetch := context.WithTimeout(ctx, *idle)
+		dead, err := replayer.Fetch(fetchCtx)
+		cancelFetch()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				break
+			}
+			runErr = err
+			break
+		}
+
+		if !filter.matches(dead) {
+			if !*dryRun {
+				skipped = append(skipped, dead)
+			}
+			continue
+		}
+		matched++
+
+		logger.Info("Dead letter",
+			zap.String("id", dead.Notification.ID),
+			zap.String("channel", dead.Channel),
+			zap.String("error", dead.Error),
+			zap.String("original_topic", dead.OriginalTopic),
+		)
+		if *dryRun {
+			continue
+		}
+
+		// Statuses only move forward, so the failed notification is reopened first
+		if err := notificationService.ReopenForReplay(ctx, dead.Notification.ID); err != nil {
+			runErr = err
+			break
+		}
+		if err := replayer.Republish(ctx, dead); err != nil {
+			runErr = err
+			break
+		}
+		if err := replayer.Commit(ctx, dead); err != nil {
+			runErr = err
+			break
+		}
+		replayed++
+	}
+
+	if err := replayer.Requeue(context.Background(), skipped); err != nil {
+		logger.Error("Failed to requeue skipped dead letters", zap.Error(err))
+		os.Exit(1)
+	}
+	for _, dead := range skipped {
+		if err := replayer.Commit(context.Background(), dead); err != nil {
+			logger.Error("Failed to commit skipped dead letter", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("Dead letter replay finished",
+		zap.Int("matched", matched),
+		zap.Int("replayed", replayed),
+		zap.Int("requeued", len(skipped)),
+		zap.Bool("dry_run", *dryRun),
+	)
+
+	if runErr != nil {
+		logger.Error("Dead letter replay stopped early", zap.Error(runErr))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+func TestReplayFilter(t *testing.T) {
+	dead := &queue.DeadLetter{Channel: "sms", Error: "twilio error: invalid number"}
+
+	tests := []struct {
+		name   string
+		filter replayFilter
+		want   bool
+	}{
+		{"no filter", replayFilter{}, true},
+		{"channel", replayFilter{channel: "sms"}, true},
+		{"other channel", replayFilter{channel: "email"}, false},
+		{"reason", replayFilter{reason: "invalid number"}, true},
+		{"other reason", replayFilter{reason: "timeout"}, false},
+		{"channel and reason", replayFilter{channel: "sms", reason: "twilio"}, true},
+		{"channel but other reason", replayFilter{channel: "sms", reason: "timeout"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.matches(dead); got != tt.want {
+			t.Errorf("%s: matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "github.com/alexnthnz/notification-system/internal/worker"
+
+// main runs the channels listed in WORKER_CHANNELS, or every enabled channel,
+// in one process
+func main() {
+	worker.Main("Notification Worker")
+}
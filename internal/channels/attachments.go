@@ -0,0 +1,146 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// maxAttachmentRedirects caps the redirects followed while fetching an attachment
+const maxAttachmentRedirects = 5
+
+// attachmentFetcher downloads email attachments given by URL, such as
+// presigned S3 or GCS object URLs
+type attachmentFetcher struct {
+	client *http.Client
+	config config.AttachmentConfig
+}
+
+// newAttachmentFetcher creates a fetcher that only follows redirects to the
+// allowed attachment hosts
+func newAttachmentFetcher(cfg config.AttachmentConfig) *attachmentFetcher {
+	client := &http.Client{
+		Timeout: cfg.FetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxAttachmentRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxAttachmentRedirects)
+			}
+			if reason := notification.CheckAttachmentURL(req.URL.String(), cfg.URLHosts); reason != "" {
+				return fmt.Errorf("redirect refused: %s", reason)
+			}
+			return nil
+		},
+	}
+	return &attachmentFetcher{client: client, config: cfg}
+}
+
+// hasReferences reports whether any of the attachments is given by URL
+func hasReferences(attachments []notification.Attachment) bool {
+	for _, a := range attachments {
+		if a.IsReference() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the attachments with those given by URL replaced by their
+// fetched content. Failures the object store may recover from are retryable;
+// refused, missing and oversized objects are AttachmentErrors.
+func (f *attachmentFetcher) resolve(ctx context.Context, attachments []notification.Attachment) ([]notification.Attachment, error) {
+	resolved := make([]notification.Attachment, len(attachments))
+	for i, a := range attachments {
+		if !a.IsReference() {
+			resolved[i] = a
+			continue
+		}
+
+		fetched, err := f.fetch(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = fetched
+	}
+	return resolved, nil
+}
+
+// fetch downloads one attachment, reading at most the per-file size cap, and
+// detects its content type when the attachment does not give one
+func (f *attachmentFetcher) fetch(ctx context.Context, a notification.Attachment) (notification.Attachment, error) {
+	// Presigned URLs are used exactly as given, since any change breaks the signature
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return a, &notification.AttachmentError{Filename: a.Filename, Reason: "url is malformed"}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Don't report the URL, its query may carry a signature
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return a, fmt.Errorf("failed to fetch attachment %q: %w", a.Filename, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("failed to fetch attachment %q: object store returned status %d", a.Filename, resp.StatusCode)
+		if isRetryableStatus(resp.StatusCode) {
+			return a, NewRetryableError(err)
+		}
+		return a, &notification.AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("object store returned status %d", resp.StatusCode)}
+	}
+
+	limit := f.config.MaxFileSize
+	if limit > 0 && resp.ContentLength > limit {
+		return a, oversizeError(a, limit)
+	}
+
+	reader := io.Reader(resp.Body)
+	if limit > 0 {
+		reader = io.LimitReader(resp.Body, limit+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return a, NewRetryableError(fmt.Errorf("failed to read attachment %q: %w", a.Filename, err))
+	}
+	if limit > 0 && int64(len(content)) > limit {
+		return a, oversizeError(a, limit)
+	}
+
+	if a.ContentType == "" {
+		a.ContentType = detectContentType(resp.Header.Get("Content-Type"), content)
+	}
+	a.Content = base64.StdEncoding.EncodeToString(content)
+	a.URL = ""
+	return a, nil
+}
+
+// oversizeError reports an attachment larger than the per-file size cap
+func oversizeError(a notification.Attachment, limit int64) error {
+	return &notification.AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("size exceeds limit of %d bytes", limit)}
+}
+
+// detectContentType returns the media type the object store reported, or the
+// one sniffed from the content when the store only reported a generic binary type
+func detectContentType(header string, content []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header); err == nil {
+		switch strings.ToLower(mediaType) {
+		case "application/octet-stream", "binary/octet-stream":
+		default:
+			return strings.ToLower(mediaType)
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	return mediaType
+}
@@ -0,0 +1,172 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// pdfContent is served by the stub object store; it sniffs as application/pdf
+const pdfContent = "%PDF-1.4 test"
+
+// newAttachmentEmailChannel returns an email channel that fetches attachments
+// from a stub object store served by objects, reporting the attachments
+// SendGrid received to sent
+func newAttachmentEmailChannel(t *testing.T, objects http.HandlerFunc, sent *[]sendGridAttachment) (*EmailChannel, *httptest.Server) {
+	t.Helper()
+	store := httptest.NewTLSServer(objects)
+	t.Cleanup(store.Close)
+
+	cfg := config.SendGridConfig{Attachments: config.AttachmentConfig{
+		AllowedTypes: []string{"application/pdf"},
+		MaxFileSize:  int64(len(pdfContent)),
+		URLHosts:     []string{"127.0.0.1"},
+	}}
+	channel := newTestEmailChannel(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Attachments []sendGridAttachment `json:"attachments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode SendGrid request: %v", err)
+		}
+		*sent = payload.Attachments
+		w.WriteHeader(http.StatusAccepted)
+	})
+	channel.fetcher.client.Transport = store.Client().Transport
+	return channel, store
+}
+
+// sendGridAttachment is an attachment of a SendGrid v3 mail send request
+type sendGridAttachment struct {
+	Content  string `json:"content"`
+	Type     string `json:"type"`
+	Filename string `json:"filename"`
+}
+
+func TestEmailChannelAttachesFetchedContent(t *testing.T) {
+	var sent []sendGridAttachment
+	var query string
+	channel, store := newAttachmentEmailChannel(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(pdfContent))
+	}, &sent)
+
+	// A presigned URL is fetched exactly as given
+	presigned := store.URL + "/bucket/invoice.pdf?X-Amz-Signature=abc%2Fdef&X-Amz-Expires=300"
+	notif := notification.Notification{ID: "n1", Recipient: "user@example.com", Subject: "Hi", Body: "Hello",
+		Attachments: []notification.Attachment{{Filename: "invoice.pdf", URL: presigned}}}
+	if _, err := channel.SendNotification(context.Background(), notif); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+
+	if query != "X-Amz-Signature=abc%2Fdef&X-Amz-Expires=300" {
+		t.Errorf("object store got query %q, want the presigned query unchanged", query)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("SendGrid got %d attachments, want 1", len(sent))
+	}
+	if content, _ := base64.StdEncoding.DecodeString(sent[0].Content); string(content) != pdfContent {
+		t.Errorf("attachment content = %q, want the fetched object", content)
+	}
+	if sent[0].Type != "application/pdf" || sent[0].Filename != "invoice.pdf" {
+		t.Errorf("attachment = %+v, want invoice.pdf detected as application/pdf", sent[0])
+	}
+}
+
+func TestEmailChannelRejectsUnfetchableAttachments(t *testing.T) {
+	tests := []struct {
+		name          string
+		object        http.HandlerFunc
+		wantRetryable bool
+		wantReason    string
+	}{
+		{
+			name: "oversize with content length",
+			object: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(pdfContent + "more"))
+			},
+			wantReason: "size exceeds limit",
+		},
+		{
+			name: "oversize without content length",
+			object: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				w.Write([]byte(pdfContent + "more"))
+			},
+			wantReason: "size exceeds limit",
+		},
+		{
+			name: "missing object",
+			object: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantReason: "status 404",
+		},
+		{
+			name: "object store unavailable",
+			object: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantRetryable: true,
+		},
+		{
+			name: "redirect to another host",
+			object: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://evil.example.org/a.pdf", http.StatusFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []sendGridAttachment
+			channel, store := newAttachmentEmailChannel(t, tt.object, &sent)
+
+			notif := notification.Notification{ID: "n1", Recipient: "user@example.com", Subject: "Hi", Body: "Hello",
+				Attachments: []notification.Attachment{{Filename: "a.pdf", URL: store.URL + "/a.pdf"}}}
+			report, err := channel.SendNotification(context.Background(), notif)
+			if err == nil {
+				t.Fatal("SendNotification() error = nil, want the fetch to fail")
+			}
+			if report.Status != notification.StatusFailed || report.Retryable != tt.wantRetryable {
+				t.Errorf("report = %+v, want failed with Retryable %v", report, tt.wantRetryable)
+			}
+			if tt.wantReason != "" {
+				var attachmentErr *notification.AttachmentError
+				if !errors.As(err, &attachmentErr) || !strings.Contains(attachmentErr.Reason, tt.wantReason) {
+					t.Errorf("SendNotification() error = %v, want an AttachmentError with %q", err, tt.wantReason)
+				}
+			}
+			if sent != nil {
+				t.Error("SendGrid was called despite the failed fetch")
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"application/pdf", "application/pdf"},
+		{"Image/PNG; charset=binary", "image/png"},
+		{"application/octet-stream", "application/pdf"},
+		{"binary/octet-stream", "application/pdf"},
+		{"", "application/pdf"},
+	}
+	for _, tt := range tests {
+		if got := detectContentType(tt.header, []byte(pdfContent)); got != tt.want {
+			t.Errorf("detectContentType(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
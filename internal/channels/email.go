@@ -3,39 +3,116 @@ package channels
 import (
 	"context"
 	"fmt"
-	"log"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/notification"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.uber.org/zap"
 )
 
 // EmailChannel handles email notifications using SendGrid
 type EmailChannel struct {
-	client *sendgrid.Client
-	config config.SendGridConfig
+	client   *sendgrid.Client
+	config   config.SendGridConfig
+	retry    RetryPolicy
+	throttle *throttle
+	fetcher  *attachmentFetcher
+	logger   *zap.Logger
 }
 
 // NewEmailChannel creates a new email channel
-func NewEmailChannel(cfg config.SendGridConfig) *EmailChannel {
-	client := sendgrid.NewSendClient(cfg.APIKey)
+func NewEmailChannel(cfg config.SendGridConfig, logger *zap.Logger) *EmailChannel {
+	request := sendgrid.GetRequest(cfg.APIKey, "/v3/mail/send", strings.TrimSuffix(cfg.BaseURL, "/"))
+	request.Method = "POST"
+	client := &sendgrid.Client{Request: request}
 	return &EmailChannel{
-		client: client,
-		config: cfg,
+		client:   client,
+		config:   cfg,
+		retry:    DefaultRetryPolicy(),
+		throttle: newThrottle("sendgrid", logger),
+		fetcher:  newAttachmentFetcher(cfg.Attachments),
+		logger:   logger,
 	}
 }
 
-// SendNotification sends an email notification
+// SendNotification sends an email notification, retrying transient SendGrid
+// failures and holding back while SendGrid signals rate limit pressure
 func (e *EmailChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
-	log.Printf("Sending email notification %s to %s", notif.ID, notif.Recipient)
+	e.logger.Info("Sending email notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel))
 
+	// Fetch attachments given by URL once, ahead of the SendGrid retries
+	if hasReferences(notif.Attachments) {
+		var attachments []notification.Attachment
+		err := WithRetry(ctx, e.logger, e.retry, func(ctx context.Context) error {
+			var fetchErr error
+			attachments, fetchErr = e.fetcher.resolve(ctx, notif.Attachments)
+			return fetchErr
+		})
+		if err != nil {
+			e.logger.Warn("Failed to fetch email attachments", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.Error(err))
+			return &notification.DeliveryReport{
+				NotificationID: notif.ID,
+				Status:         notification.StatusFailed,
+				ErrorMessage:   err.Error(),
+				Retryable:      retryableSend(ctx, err),
+			}, err
+		}
+		notif.Attachments = attachments
+	}
+
+	// Reject bad attachments before calling SendGrid
+	if err := notification.ValidateAttachments(notif.Attachments, e.config.Attachments); err != nil {
+		e.logger.Warn("Email notification has invalid attachments", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.Error(err))
+		return &notification.DeliveryReport{
+			NotificationID: notif.ID,
+			Status:         notification.StatusFailed,
+			ErrorMessage:   err.Error(),
+		}, err
+	}
+
+	var report *notification.DeliveryReport
+	err := WithRetry(ctx, e.logger, e.retry, func(ctx context.Context) error {
+		if err := e.throttle.wait(ctx); err != nil {
+			report = throttledReport(notif.ID, err)
+			return err
+		}
+		var sendErr error
+		report, sendErr = e.send(ctx, notif)
+		return e.retry.reportError(report, sendErr)
+	})
+	return report, err
+}
+
+// send performs a single SendGrid API call
+func (e *EmailChannel) send(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
 	// Create the email message
 	from := mail.NewEmail("Notification Service", "noreply@yourcompany.com")
 	to := mail.NewEmail("", notif.Recipient)
 
 	message := mail.NewSingleEmail(from, notif.Subject, to, notif.Body, notif.Body)
 
+	// Add attachments and inline images
+	for _, a := range notif.Attachments {
+		attachment := mail.NewAttachment().
+			SetContent(a.Content).
+			SetType(a.ContentType).
+			SetFilename(a.Filename)
+		if a.IsInline() {
+			attachment.SetDisposition("inline").SetContentID(a.ContentID)
+		} else {
+			attachment.SetDisposition("attachment")
+		}
+		message.AddAttachment(attachment)
+	}
+
+	// Apply engagement tracking and analytics categories
+	applyTracking(message, e.config.Tracking, notif.Metadata)
+	applySendGridOptions(message, notif.ProviderOptions)
+
 	// Add custom headers for tracking
 	message.SetHeader("X-Notification-ID", notif.ID)
 	if notif.UserID != "" {
@@ -43,41 +120,96 @@ func (e *EmailChannel) SendNotification(ctx context.Context, notif notification.
 	}
 
 	// Send the email
-	response, err := e.client.Send(message)
+	response, err := e.client.SendWithContext(ctx, message)
 	if err != nil {
-		log.Printf("Failed to send email notification %s: %v", notif.ID, err)
+		e.logger.Error("Failed to send email notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.Error(err))
 		return &notification.DeliveryReport{
 			NotificationID: notif.ID,
 			Status:         notification.StatusFailed,
 			ErrorMessage:   err.Error(),
+			Retryable:      retryableSend(ctx, err),
 		}, err
 	}
 
+	e.throttle.observe(http.Header(response.Headers))
+
 	// Check response status
 	if response.StatusCode >= 200 && response.StatusCode < 300 {
 		var messageID string
 		if msgIDs, ok := response.Headers["X-Message-Id"]; ok && len(msgIDs) > 0 {
 			messageID = msgIDs[0]
 		}
-		log.Printf("Successfully sent email notification %s (SendGrid ID: %s)", notif.ID, messageID)
-		return &notification.DeliveryReport{
-			NotificationID: notif.ID,
-			ExternalID:     messageID,
-			Status:         notification.StatusSent,
-		}, nil
+		e.logger.Info("Sent email notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.String("external_id", messageID))
+		return acceptedReport(notif.ID, messageID), nil
 	}
 
 	errorMsg := fmt.Sprintf("SendGrid returned status %d: %s", response.StatusCode, response.Body)
-	log.Printf("Email notification %s failed: %s", notif.ID, errorMsg)
+	e.logger.Error("SendGrid rejected email notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.Int("status_code", response.StatusCode), zap.String("error", errorMsg))
 
+	// SendGrid 4xx responses are permanent request errors, 429 and 5xx are transient
 	return &notification.DeliveryReport{
 		NotificationID: notif.ID,
 		Status:         notification.StatusFailed,
 		ErrorMessage:   errorMsg,
+		Retryable:      isRetryableStatus(response.StatusCode),
+		ProviderCode:   strconv.Itoa(response.StatusCode),
 	}, fmt.Errorf("sendgrid error: %s", errorMsg)
 }
 
+// applyTracking sets click and open tracking explicitly, so the account's
+// SendGrid defaults never turn tracking on for transactional mail, and adds the
+// configured and per-notification categories. Metadata options override the
+// configuration; they are validated when the notification is created.
+func applyTracking(message *mail.SGMailV3, cfg config.TrackingConfig, metadata map[string]string) {
+	clickTracking := cfg.ClickTracking
+	if value, err := strconv.ParseBool(metadata[notification.MetadataClickTracking]); err == nil {
+		clickTracking = value
+	}
+	openTracking := cfg.OpenTracking
+	if value, err := strconv.ParseBool(metadata[notification.MetadataOpenTracking]); err == nil {
+		openTracking = value
+	}
+
+	message.SetTrackingSettings(mail.NewTrackingSettings().
+		SetClickTracking(mail.NewClickTrackingSetting().SetEnable(clickTracking).SetEnableText(clickTracking)).
+		SetOpenTracking(mail.NewOpenTrackingSetting().SetEnable(openTracking)))
+
+	seen := make(map[string]bool)
+	categories := append(append([]string{}, cfg.Categories...), notification.ParseCategories(metadata[notification.MetadataCategories])...)
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seen[category] || len(seen) == notification.MaxEmailCategories {
+			continue
+		}
+		seen[category] = true
+		message.AddCategories(category)
+	}
+}
+
+// applySendGridOptions sets the unsubscribe group and IP pool from the
+// notification's SendGrid options, which are validated when it is created
+func applySendGridOptions(message *mail.SGMailV3, options notification.ProviderOptions) {
+	if groupID, err := strconv.Atoi(options.Get(notification.ProviderSendGrid, notification.OptionASMGroupID)); err == nil {
+		asm := mail.NewASM().SetGroupID(groupID)
+		asm.AddGroupsToDisplay(notification.ParseASMGroups(options.Get(notification.ProviderSendGrid, notification.OptionASMGroupsToDisplay))...)
+		message.SetASM(asm)
+	}
+	if pool := options.Get(notification.ProviderSendGrid, notification.OptionIPPoolName); pool != "" {
+		message.SetIPPoolID(pool)
+	}
+}
+
 // GetChannelType returns the channel type
 func (e *EmailChannel) GetChannelType() string {
 	return "email"
 }
+
+// GetProviderName returns the name of the provider used for metrics
+func (e *EmailChannel) GetProviderName() string {
+	return "sendgrid"
+}
+
+// OnRateLimit implements RateLimitReporter
+func (e *EmailChannel) OnRateLimit(fn func(remaining int)) {
+	e.throttle.setOnRemaining(fn)
+}
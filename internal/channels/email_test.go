@@ -0,0 +1,218 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// newTestEmailChannel returns an email channel sending to a SendGrid mock
+// served by handler, without retries
+func newTestEmailChannel(t *testing.T, cfg config.SendGridConfig, handler http.HandlerFunc) *EmailChannel {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.BaseURL = server.URL
+	cfg.APIKey = "SG.test"
+	channel := NewEmailChannel(cfg, zap.NewNop())
+	channel.retry = RetryPolicy{MaxAttempts: 1}
+	return channel
+}
+
+func TestEmailChannelClassifiesSendGridErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantStatus    notification.NotificationStatus
+		wantRetryable bool
+		wantCode      string
+	}{
+		{"accepted", 202, notification.StatusSent, false, ""},
+		{"bad request", 400, notification.StatusFailed, false, "400"},
+		{"unauthorized", 401, notification.StatusFailed, false, "401"},
+		{"payload too large", 413, notification.StatusFailed, false, "413"},
+		{"rate limited", 429, notification.StatusFailed, true, "429"},
+		{"server error", 500, notification.StatusFailed, true, "500"},
+		{"unavailable", 503, notification.StatusFailed, true, "503"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := newTestEmailChannel(t, config.SendGridConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Message-Id", "msg-1")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"errors":[]}`))
+			})
+
+			report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "user@example.com", Subject: "Hi", Body: "Hello"})
+			if (err != nil) != (tt.wantStatus == notification.StatusFailed) {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", report.Status, tt.wantStatus)
+			}
+			if report.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", report.Retryable, tt.wantRetryable)
+			}
+			if report.ProviderCode != tt.wantCode {
+				t.Errorf("ProviderCode = %q, want %q", report.ProviderCode, tt.wantCode)
+			}
+			if tt.wantStatus == notification.StatusSent && report.ExternalID != "msg-1" {
+				t.Errorf("ExternalID = %q, want msg-1", report.ExternalID)
+			}
+		})
+	}
+}
+
+// sendGridMail is the part of a SendGrid v3 mail send request checked by tests
+type sendGridMail struct {
+	Categories []string `json:"categories"`
+	ASM        *struct {
+		GroupID         int   `json:"group_id"`
+		GroupsToDisplay []int `json:"groups_to_display"`
+	} `json:"asm"`
+	IPPoolName       string `json:"ip_pool_name"`
+	TrackingSettings struct {
+		ClickTracking struct {
+			Enable     bool `json:"enable"`
+			EnableText bool `json:"enable_text"`
+		} `json:"click_tracking"`
+		OpenTracking struct {
+			Enable bool `json:"enable"`
+		} `json:"open_tracking"`
+	} `json:"tracking_settings"`
+}
+
+// sendEmail sends notif through an email channel with cfg and returns the
+// request SendGrid received
+func sendEmail(t *testing.T, cfg config.SendGridConfig, notif notification.Notification) sendGridMail {
+	t.Helper()
+	var payload sendGridMail
+	channel := newTestEmailChannel(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode SendGrid request: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	if _, err := channel.SendNotification(context.Background(), notif); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	return payload
+}
+
+func TestEmailChannelTracking(t *testing.T) {
+	var manyCategories []string
+	for i := 0; i < 12; i++ {
+		manyCategories = append(manyCategories, fmt.Sprintf("c%d", i))
+	}
+
+	tests := []struct {
+		name           string
+		tracking       config.TrackingConfig
+		metadata       map[string]string
+		wantClick      bool
+		wantOpen       bool
+		wantCategories []string
+	}{
+		{
+			name: "transactional default",
+		},
+		{
+			name:           "configured",
+			tracking:       config.TrackingConfig{ClickTracking: true, OpenTracking: true, Categories: []string{"marketing"}},
+			wantClick:      true,
+			wantOpen:       true,
+			wantCategories: []string{"marketing"},
+		},
+		{
+			name:           "metadata overrides",
+			tracking:       config.TrackingConfig{ClickTracking: true, Categories: []string{"marketing"}},
+			metadata:       map[string]string{notification.MetadataClickTracking: "false", notification.MetadataOpenTracking: "true", notification.MetadataCategories: "spring-sale, marketing,"},
+			wantOpen:       true,
+			wantCategories: []string{"marketing", "spring-sale"},
+		},
+		{
+			name:           "categories capped",
+			tracking:       config.TrackingConfig{Categories: manyCategories},
+			wantCategories: manyCategories[:notification.MaxEmailCategories],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := sendEmail(t, config.SendGridConfig{Tracking: tt.tracking}, notification.Notification{
+				ID: "n1", Recipient: "user@example.com", Subject: "Hi", Body: "Hello", Metadata: tt.metadata,
+			})
+
+			tracking := payload.TrackingSettings
+			if tracking.ClickTracking.Enable != tt.wantClick || tracking.ClickTracking.EnableText != tt.wantClick {
+				t.Errorf("click_tracking = %+v, want enabled %v", tracking.ClickTracking, tt.wantClick)
+			}
+			if tracking.OpenTracking.Enable != tt.wantOpen {
+				t.Errorf("open_tracking enabled = %v, want %v", tracking.OpenTracking.Enable, tt.wantOpen)
+			}
+			if !reflect.DeepEqual(payload.Categories, tt.wantCategories) {
+				t.Errorf("categories = %v, want %v", payload.Categories, tt.wantCategories)
+			}
+		})
+	}
+}
+
+func TestEmailChannelSendsToConfiguredBaseURL(t *testing.T) {
+	var method, path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	// A trailing slash on the configured host is tolerated
+	channel := NewEmailChannel(config.SendGridConfig{APIKey: "SG.secret", BaseURL: server.URL + "/"}, zap.NewNop())
+	channel.retry = RetryPolicy{MaxAttempts: 1}
+
+	if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if method != "POST" || path != "/v3/mail/send" {
+		t.Errorf("request = %s %s, want POST /v3/mail/send", method, path)
+	}
+	if auth != "Bearer SG.secret" {
+		t.Errorf("Authorization = %q, want Bearer SG.secret", auth)
+	}
+}
+
+func TestEmailChannelAppliesSendGridOptions(t *testing.T) {
+	payload := sendEmail(t, config.SendGridConfig{}, notification.Notification{
+		ID: "n1", Recipient: "a@example.com", Subject: "Hi", Body: "Hello",
+		ProviderOptions: notification.ProviderOptions{
+			notification.ProviderSendGrid: {
+				notification.OptionASMGroupID:         "42",
+				notification.OptionASMGroupsToDisplay: "42, 43",
+				notification.OptionIPPoolName:         "transactional",
+				"unknown_option":                      "ignored",
+			},
+			// Options of other providers are not applied
+			notification.ProviderTwilio: {notification.OptionValidityPeriod: "60"},
+		},
+	})
+	if payload.ASM == nil || payload.ASM.GroupID != 42 || !reflect.DeepEqual(payload.ASM.GroupsToDisplay, []int{42, 43}) {
+		t.Errorf("asm = %+v, want group 42 displaying 42 and 43", payload.ASM)
+	}
+	if payload.IPPoolName != "transactional" {
+		t.Errorf("ip_pool_name = %q, want transactional", payload.IPPoolName)
+	}
+
+	payload = sendEmail(t, config.SendGridConfig{}, notification.Notification{ID: "n2", Recipient: "a@example.com", Subject: "Hi", Body: "Hello"})
+	if payload.ASM != nil || payload.IPPoolName != "" {
+		t.Errorf("asm = %+v, ip_pool_name = %q, want neither without options", payload.ASM, payload.IPPoolName)
+	}
+}
@@ -0,0 +1,24 @@
+package channels
+
+import (
+	"net/http"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// newHTTPClient builds a provider HTTP client with an overall request timeout
+// and a pooled transport so connections to the provider are reused
+func newHTTPClient(cfg config.HTTPClientConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+	}
+}
@@ -2,14 +2,67 @@ package channels
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/alexnthnz/notification-system/internal/notification"
 )
 
-// Channel represents a notification channel interface
+// Channel represents a notification channel interface. SendNotification
+// reports StatusSent once the provider accepted the notification, never
+// StatusDelivered: providers confirm delivery asynchronously, through webhooks.
 type Channel interface {
 	SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error)
 	GetChannelType() string
+	GetProviderName() string
+}
+
+// BatchSender is implemented by channels whose provider can send several
+// notifications in one call. SendBatch returns one report per notification, in
+// the same order; an error means the batch as a whole could not be sent.
+type BatchSender interface {
+	SendBatch(ctx context.Context, notifs []notification.Notification) ([]*notification.DeliveryReport, error)
+}
+
+// TopicSender is implemented by channels that can broadcast to a topic that
+// devices subscribe to, rather than to one recipient
+type TopicSender interface {
+	SendToTopic(ctx context.Context, topic string, notif notification.Notification) (*notification.DeliveryReport, error)
+}
+
+// acceptedReport reports that the provider accepted the notification for delivery
+func acceptedReport(notificationID, externalID string) *notification.DeliveryReport {
+	now := time.Now()
+	return &notification.DeliveryReport{
+		NotificationID: notificationID,
+		ExternalID:     externalID,
+		Status:         notification.StatusSent,
+		AcceptedAt:     &now,
+	}
+}
+
+// SendBatch sends notifs through channel in one provider call when it
+// implements BatchSender, otherwise one at a time. Reports are in the order of
+// notifs; with single sends a failed notification's error is recorded in its
+// report rather than returned.
+func SendBatch(ctx context.Context, channel Channel, notifs []notification.Notification) ([]*notification.DeliveryReport, error) {
+	if batcher, ok := channel.(BatchSender); ok {
+		return batcher.SendBatch(ctx, notifs)
+	}
+
+	reports := make([]*notification.DeliveryReport, len(notifs))
+	for i, notif := range notifs {
+		report, err := channel.SendNotification(ctx, notif)
+		if report == nil {
+			report = &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusFailed}
+		}
+		if err != nil && report.ErrorMessage == "" {
+			report.Status = notification.StatusFailed
+			report.ErrorMessage = err.Error()
+		}
+		reports[i] = report
+	}
+	return reports, nil
 }
 
 // ChannelManager manages all notification channels
@@ -35,6 +88,16 @@ func (cm *ChannelManager) GetChannel(channelType string) (Channel, bool) {
 	return channel, exists
 }
 
+// ChannelTypes returns the types of the registered channels, sorted
+func (cm *ChannelManager) ChannelTypes() []string {
+	types := make([]string, 0, len(cm.channels))
+	for channelType := range cm.channels {
+		types = append(types, channelType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 // SendNotification sends a notification through the appropriate channel
 func (cm *ChannelManager) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
 	channel, exists := cm.GetChannel(notif.Channel)
@@ -47,4 +110,4 @@ func (cm *ChannelManager) SendNotification(ctx context.Context, notif notificati
 	}
 
 	return channel.SendNotification(ctx, notif)
-}
\ No newline at end of file
+}
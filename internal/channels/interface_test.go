@@ -0,0 +1,145 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// fakeChannel sends notifications one at a time, failing those addressed to
+// failRecipient
+type fakeChannel struct {
+	failRecipient string
+	sent          []string
+}
+
+func (c *fakeChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	c.sent = append(c.sent, notif.ID)
+	if notif.Recipient == c.failRecipient {
+		return nil, errors.New("provider rejected recipient")
+	}
+	return &notification.DeliveryReport{NotificationID: notif.ID, ExternalID: "ext-" + notif.ID, Status: notification.StatusSent}, nil
+}
+
+func (c *fakeChannel) GetChannelType() string  { return "sms" }
+func (c *fakeChannel) GetProviderName() string { return "fake" }
+
+// fakeBatchChannel is a fakeChannel that also sends batches in one call
+type fakeBatchChannel struct {
+	fakeChannel
+	batches [][]string
+}
+
+func (c *fakeBatchChannel) SendBatch(ctx context.Context, notifs []notification.Notification) ([]*notification.DeliveryReport, error) {
+	ids := make([]string, len(notifs))
+	reports := make([]*notification.DeliveryReport, len(notifs))
+	for i, notif := range notifs {
+		ids[i] = notif.ID
+		reports[i] = &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusSent}
+	}
+	c.batches = append(c.batches, ids)
+	return reports, nil
+}
+
+func batchOf(ids ...string) []notification.Notification {
+	notifs := make([]notification.Notification, len(ids))
+	for i, id := range ids {
+		notifs[i] = notification.Notification{ID: id, Recipient: "recipient-" + id}
+	}
+	return notifs
+}
+
+func TestSendBatchUsesBatchSender(t *testing.T) {
+	channel := &fakeBatchChannel{}
+
+	reports, err := SendBatch(context.Background(), channel, batchOf("n1", "n2", "n3"))
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	if len(channel.batches) != 1 || len(channel.batches[0]) != 3 {
+		t.Errorf("batches = %v, want one batch of 3", channel.batches)
+	}
+	if len(channel.sent) != 0 {
+		t.Errorf("sent %v one at a time, want none", channel.sent)
+	}
+	if len(reports) != 3 {
+		t.Errorf("got %d reports, want 3", len(reports))
+	}
+}
+
+func TestSendBatchFallsBackToSingleSends(t *testing.T) {
+	channel := &fakeChannel{failRecipient: "recipient-n2"}
+
+	reports, err := SendBatch(context.Background(), channel, batchOf("n1", "n2", "n3"))
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	if len(channel.sent) != 3 {
+		t.Fatalf("sent %v, want each notification sent on its own", channel.sent)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3", len(reports))
+	}
+
+	// A failed send is reported in order rather than failing the batch
+	for i, want := range []notification.NotificationStatus{notification.StatusSent, notification.StatusFailed, notification.StatusSent} {
+		if reports[i].Status != want {
+			t.Errorf("report %d status = %q, want %q", i, reports[i].Status, want)
+		}
+	}
+	if reports[1].NotificationID != "n2" || reports[1].ErrorMessage != "provider rejected recipient" {
+		t.Errorf("failed report = %+v, want n2 with the send error", reports[1])
+	}
+	if reports[2].ExternalID != "ext-n3" {
+		t.Errorf("report 2 ExternalID = %q, want ext-n3", reports[2].ExternalID)
+	}
+}
+
+func TestChannelsReportAcceptanceNotDelivery(t *testing.T) {
+	// Each provider says the message was accepted; even a provider status
+	// claiming delivery must not be reported as delivered
+	email := newTestEmailChannel(t, config.SendGridConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Message-Id", "msg-1")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	sms := newTestSMSChannel(t, config.TwilioConfig{}, twilioResponse(201, `{"sid":"SM1","status":"delivered"}`))
+	fcm, _ := recordFCM(t)
+	push := newTestPushChannel(t, config.FirebaseConfig{}, fcm)
+
+	tests := []struct {
+		name   string
+		send   func(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error)
+		wantID string
+	}{
+		{"email", email.SendNotification, "msg-1"},
+		{"sms", sms.SendNotification, "SM1"},
+		{"push", push.SendNotification, "projects/test/messages/1"},
+		{"push topic", func(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+			return push.SendToTopic(ctx, "news", notif)
+		}, "projects/test/messages/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			report, err := tt.send(context.Background(), notification.Notification{ID: "n1", Recipient: "recipient", Subject: "Hi", Body: "hi"})
+			if err != nil {
+				t.Fatalf("send error = %v", err)
+			}
+			if report.Status != notification.StatusSent || report.ExternalID != tt.wantID {
+				t.Errorf("report = %+v, want sent with external ID %s", report, tt.wantID)
+			}
+			if report.AcceptedAt == nil || report.AcceptedAt.Before(before) || report.AcceptedAt.After(time.Now()) {
+				t.Errorf("AcceptedAt = %v, want the time of the send", report.AcceptedAt)
+			}
+			if report.DeliveredAt != nil {
+				t.Errorf("DeliveredAt = %v, want nil until the provider confirms delivery", report.DeliveredAt)
+			}
+		})
+	}
+}
@@ -2,14 +2,15 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
 	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
 	"google.golang.org/api/option"
 )
 
@@ -17,10 +18,12 @@ import (
 type PushChannel struct {
 	client *messaging.Client
 	config config.FirebaseConfig
+	retry  RetryPolicy
+	logger *zap.Logger
 }
 
 // NewPushChannel creates a new push notification channel
-func NewPushChannel(ctx context.Context, cfg config.FirebaseConfig) (*PushChannel, error) {
+func NewPushChannel(ctx context.Context, cfg config.FirebaseConfig, logger *zap.Logger) (*PushChannel, error) {
 	// Check if credentials file exists
 	if _, err := os.Stat(cfg.CredentialsPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("Firebase credentials file not found at %s", cfg.CredentialsPath)
@@ -39,41 +42,102 @@ func NewPushChannel(ctx context.Context, cfg config.FirebaseConfig) (*PushChanne
 		return nil, fmt.Errorf("failed to get Firebase messaging client: %w", err)
 	}
 
+	retry := DefaultRetryPolicy()
+	if cfg.MaxAttempts > 0 {
+		retry.MaxAttempts = cfg.MaxAttempts
+	}
+
 	return &PushChannel{
 		client: client,
 		config: cfg,
+		retry:  retry,
+		logger: logger,
 	}, nil
 }
 
+// callContext bounds a single FCM call by the configured send timeout, so a
+// slow FCM response does not hold the worker for the whole send
+func (p *PushChannel) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.config.SendTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.config.SendTimeout)
+}
+
+// timedOut reports whether err is a call cut short by the send timeout while
+// ctx, the send's own context, is still live. Such a call is worth retrying.
+func timedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
+
 // SendNotification sends a push notification
 func (p *PushChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
-	log.Printf("Sending push notification %s to %s", notif.ID, notif.Recipient)
+	p.logger.Info("Sending push notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel))
+
+	message := buildMessage(notif)
+	message.Token = notif.Recipient // The recipient should be the FCM token
+	return p.deliver(ctx, notif, message)
+}
 
+// SendToTopic sends a broadcast to every device subscribed to the FCM topic
+func (p *PushChannel) SendToTopic(ctx context.Context, topic string, notif notification.Notification) (*notification.DeliveryReport, error) {
+	p.logger.Info("Sending push broadcast", zap.String("id", notif.ID), zap.String("topic", topic))
+
+	message := buildMessage(notif)
+	message.Topic = topic
+	return p.deliver(ctx, notif, message)
+}
+
+// Push delivery priorities: high wakes the device right away, normal lets the
+// platform batch delivery to save battery
+const (
+	androidPriorityHigh   = "high"
+	androidPriorityNormal = "normal"
+	apnsPriorityHigh      = "10"
+	apnsPriorityNormal    = "5"
+)
+
+// pushPriority returns the FCM Android and APNs priorities for a notification
+// priority. Only high-priority notifications are sent with high delivery
+// priority; medium and low ones are downgraded so they do not drain the
+// battery or count against the platforms' high-priority budgets.
+func pushPriority(priority int) (android string, androidNotification messaging.AndroidNotificationPriority, apns string) {
+	if priority == notification.PriorityHigh {
+		return androidPriorityHigh, messaging.PriorityHigh, apnsPriorityHigh
+	}
+	return androidPriorityNormal, messaging.PriorityDefault, apnsPriorityNormal
+}
+
+// buildMessage creates the FCM message for notif without a target
+func buildMessage(notif notification.Notification) *messaging.Message {
 	// Parse additional data from metadata
 	data := make(map[string]string)
 	if notif.Metadata != nil {
 		data = notif.Metadata
 	}
 	data["notification_id"] = notif.ID
-	data["user_id"] = notif.UserID
+	if notif.UserID != "" {
+		data["user_id"] = notif.UserID
+	}
+	androidPriority, androidNotificationPriority, apnsPriority := pushPriority(notif.Priority)
 
 	// Create the FCM message
 	message := &messaging.Message{
-		Token: notif.Recipient, // The recipient should be the FCM token
 		Notification: &messaging.Notification{
 			Title: notif.Subject,
 			Body:  notif.Body,
 		},
 		Data: data,
 		Android: &messaging.AndroidConfig{
-			Priority: "high",
+			Priority:    androidPriority,
+			CollapseKey: notif.CollapseKey,
 			Notification: &messaging.AndroidNotification{
-				Priority: messaging.PriorityHigh,
+				Priority: androidNotificationPriority,
 			},
 		},
 		APNS: &messaging.APNSConfig{
 			Headers: map[string]string{
-				"apns-priority": "10",
+				"apns-priority": apnsPriority,
 			},
 			Payload: &messaging.APNSPayload{
 				Aps: &messaging.Aps{
@@ -87,62 +151,249 @@ func (p *PushChannel) SendNotification(ctx context.Context, notif notification.N
 		},
 	}
 
-	// Send the message
-	response, err := p.client.Send(ctx, message)
+	applyPlatformOptions(message, notif.Metadata)
+
+	// Label the message in FCM delivery data
+	if label := notif.ProviderOptions.Get(notification.ProviderFCM, notification.OptionAnalyticsLabel); label != "" {
+		message.FCMOptions = &messaging.FCMOptions{AnalyticsLabel: label}
+	}
+
+	// Let newer notifications with the same key replace older ones on iOS
+	if notif.CollapseKey != "" {
+		message.APNS.Headers["apns-collapse-id"] = notif.CollapseKey
+	}
+
+	return message
+}
+
+// deliver sends an FCM message, retrying transient failures
+func (p *PushChannel) deliver(ctx context.Context, notif notification.Notification, message *messaging.Message) (*notification.DeliveryReport, error) {
+	// Send the message, retrying transient FCM failures
+	var report *notification.DeliveryReport
+	err := WithRetry(ctx, p.logger, p.retry, func(ctx context.Context) error {
+		var sendErr error
+		report, sendErr = p.send(ctx, notif, message)
+		return p.retry.reportError(report, sendErr)
+	})
+	return report, err
+}
+
+// applyPlatformOptions maps metadata-driven options onto the APNs payload
+// (badge, category, thread-id, interruption-level) and the Android
+// notification (channel_id, click_action)
+func applyPlatformOptions(message *messaging.Message, metadata map[string]string) {
+	aps := message.APNS.Payload.Aps
+	if value, ok := metadata[notification.MetadataBadge]; ok {
+		// Badges are validated when the notification is created
+		if badge, err := notification.ParseBadge(value); err == nil {
+			aps.Badge = &badge
+		}
+	}
+	aps.Category = metadata[notification.MetadataCategory]
+	aps.ThreadID = metadata[notification.MetadataThreadID]
+	if level := metadata[notification.MetadataInterruptionLevel]; level != "" {
+		aps.CustomData = map[string]interface{}{"interruption-level": level}
+	}
+
+	android := message.Android.Notification
+	android.ChannelID = metadata[notification.MetadataAndroidChannelID]
+	android.ClickAction = metadata[notification.MetadataClickAction]
+}
+
+// send performs a single FCM API call within the send timeout
+func (p *PushChannel) send(ctx context.Context, notif notification.Notification, message *messaging.Message) (*notification.DeliveryReport, error) {
+	callCtx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	response, err := p.client.Send(callCtx, message)
 	if err != nil {
-		log.Printf("Failed to send push notification %s: %v", notif.ID, err)
+		p.logger.Error("Failed to send push notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.String("provider_code", fcmErrorCode(err)), zap.Error(err))
 		return &notification.DeliveryReport{
 			NotificationID: notif.ID,
 			Status:         notification.StatusFailed,
 			ErrorMessage:   err.Error(),
+			Retryable:      isRetryableFCMError(err) || timedOut(ctx, err),
+			ProviderCode:   fcmErrorCode(err),
 		}, err
 	}
 
-	log.Printf("Successfully sent push notification %s (FCM response: %s)", notif.ID, response)
-	return &notification.DeliveryReport{
-		NotificationID: notif.ID,
-		ExternalID:     response,
-		Status:         notification.StatusSent,
-	}, nil
+	p.logger.Info("Sent push notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.String("external_id", response))
+	return acceptedReport(notif.ID, response), nil
 }
 
-// SendBulkNotification sends push notifications to multiple tokens
-func (p *PushChannel) SendBulkNotification(ctx context.Context, tokens []string, title, body string, data map[string]string) (*messaging.BatchResponse, error) {
+// maxBatchMessages is the most messages FCM accepts in one SendEach call
+const maxBatchMessages = 500
+
+// SendBatch sends notifications addressed to device tokens with FCM SendEach,
+// up to 500 per call, so a batch of queued notifications costs one round trip
+// per chunk instead of one per notification. Each notification gets its own
+// report; failures are not retried here, retryable ones are marked so the
+// caller can requeue them.
+func (p *PushChannel) SendBatch(ctx context.Context, notifs []notification.Notification) ([]*notification.DeliveryReport, error) {
+	reports := make([]*notification.DeliveryReport, 0, len(notifs))
+	for start := 0; start < len(notifs); start += maxBatchMessages {
+		end := start + maxBatchMessages
+		if end > len(notifs) {
+			end = len(notifs)
+		}
+		chunk := notifs[start:end]
+
+		messages := make([]*messaging.Message, len(chunk))
+		for i, notif := range chunk {
+			messages[i] = buildMessage(notif)
+			messages[i].Token = notif.Recipient
+		}
+
+		callCtx, cancel := p.callContext(ctx)
+		response, err := p.client.SendEach(callCtx, messages)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to send push batch: %w", err)
+		}
+
+		for i, result := range response.Responses {
+			notif := chunk[i]
+			if result.Success {
+				reports = append(reports, acceptedReport(notif.ID, result.MessageID))
+				continue
+			}
+			p.logger.Error("Failed to send push notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("provider_code", fcmErrorCode(result.Error)), zap.Error(result.Error))
+			reports = append(reports, p.failedReport(notif.ID, result.Error))
+		}
+
+		p.logger.Info("Sent push batch",
+			zap.Int("messages", len(chunk)),
+			zap.Int("success_count", response.SuccessCount),
+			zap.Int("failure_count", response.FailureCount),
+		)
+	}
+	return reports, nil
+}
+
+// failedReport reports a message FCM rejected in a batch, applying the
+// configured retry overrides
+func (p *PushChannel) failedReport(notificationID string, err error) *notification.DeliveryReport {
+	report := &notification.DeliveryReport{
+		NotificationID: notificationID,
+		Status:         notification.StatusFailed,
+		ErrorMessage:   err.Error(),
+		Retryable:      isRetryableFCMError(err),
+		ProviderCode:   fcmErrorCode(err),
+	}
+	p.retry.Overrides.apply(report)
+	return report
+}
+
+// TokenResult is the outcome of a bulk push send to one device token
+type TokenResult struct {
+	Token  string
+	Report *notification.DeliveryReport // sent with the FCM message ID, or failed with its error code and whether it is retryable
+}
+
+// SendBulkNotification sends a push notification to multiple tokens with FCM
+// SendMulticast, up to 500 tokens per call. It returns one result per token,
+// in the order of tokens, so the caller can update each device: for example
+// remove tokens that failed with UNREGISTERED and retry retryable ones. An
+// error is only returned when a call fails as a whole; results of the chunks
+// sent before it are returned with it.
+func (p *PushChannel) SendBulkNotification(ctx context.Context, tokens []string, title, body string, data map[string]string) ([]TokenResult, error) {
 	if len(tokens) == 0 {
 		return nil, fmt.Errorf("no tokens provided")
 	}
 
-	// Create the multicast message
-	message := &messaging.MulticastMessage{
-		Tokens: tokens,
-		Notification: &messaging.Notification{
-			Title: title,
-			Body:  body,
-		},
-		Data: data,
-		Android: &messaging.AndroidConfig{
-			Priority: "high",
-		},
-		APNS: &messaging.APNSConfig{
-			Headers: map[string]string{
-				"apns-priority": "10",
+	results := make([]TokenResult, 0, len(tokens))
+	for start := 0; start < len(tokens); start += maxBatchMessages {
+		end := start + maxBatchMessages
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		chunk := tokens[start:end]
+
+		// Create the multicast message
+		message := &messaging.MulticastMessage{
+			Tokens: chunk,
+			Notification: &messaging.Notification{
+				Title: title,
+				Body:  body,
 			},
-		},
-	}
+			Data: data,
+			Android: &messaging.AndroidConfig{
+				Priority: "high",
+			},
+			APNS: &messaging.APNSConfig{
+				Headers: map[string]string{
+					"apns-priority": "10",
+				},
+			},
+		}
 
-	// Send to multiple devices
-	response, err := p.client.SendMulticast(ctx, message)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send bulk push notification: %w", err)
+		// Send to multiple devices
+		callCtx, cancel := p.callContext(ctx)
+		response, err := p.client.SendMulticast(callCtx, message)
+		cancel()
+		if err != nil {
+			return results, fmt.Errorf("failed to send bulk push notification: %w", err)
+		}
+
+		// Responses are in the order of the message's tokens
+		for i, result := range response.Responses {
+			if result.Success {
+				results = append(results, TokenResult{Token: chunk[i], Report: acceptedReport("", result.MessageID)})
+				continue
+			}
+			results = append(results, TokenResult{Token: chunk[i], Report: p.failedReport("", result.Error)})
+		}
+
+		p.logger.Info("Sent bulk push notification",
+			zap.Int("tokens", len(chunk)),
+			zap.Int("success_count", response.SuccessCount),
+			zap.Int("failure_count", response.FailureCount),
+		)
 	}
 
-	log.Printf("Sent bulk push notification to %d tokens, %d successful, %d failed",
-		len(tokens), response.SuccessCount, response.FailureCount)
+	return results, nil
+}
+
+// isRetryableFCMError reports whether an FCM error is transient.
+// UNREGISTERED tokens and invalid arguments are permanent.
+func isRetryableFCMError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if messaging.IsUnregistered(err) || messaging.IsInvalidArgument(err) {
+		return false
+	}
+	return messaging.IsUnavailable(err) || messaging.IsInternal(err) || messaging.IsQuotaExceeded(err) || IsRetryable(err)
+}
 
-	return response, nil
+// fcmErrorCode returns the FCM error code for err, if known
+func fcmErrorCode(err error) string {
+	switch {
+	case messaging.IsUnregistered(err):
+		return "UNREGISTERED"
+	case messaging.IsInvalidArgument(err):
+		return "INVALID_ARGUMENT"
+	case messaging.IsSenderIDMismatch(err):
+		return "SENDER_ID_MISMATCH"
+	case messaging.IsQuotaExceeded(err):
+		return "QUOTA_EXCEEDED"
+	case messaging.IsUnavailable(err):
+		return "UNAVAILABLE"
+	case messaging.IsInternal(err):
+		return "INTERNAL"
+	case messaging.IsThirdPartyAuthError(err):
+		return "THIRD_PARTY_AUTH_ERROR"
+	default:
+		return ""
+	}
 }
 
 // GetChannelType returns the channel type
 func (p *PushChannel) GetChannelType() string {
 	return "push"
 }
+
+// GetProviderName returns the name of the provider used for metrics
+func (p *PushChannel) GetProviderName() string {
+	return "fcm"
+}
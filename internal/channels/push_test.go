@@ -0,0 +1,617 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+)
+
+// newTestPushChannel returns a push channel sending to an FCM mock served by
+// handler, without retries
+func newTestPushChannel(t *testing.T, cfg config.FirebaseConfig, handler http.HandlerFunc) *PushChannel {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "test"}, option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("firebase.NewApp() error = %v", err)
+	}
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		t.Fatalf("app.Messaging() error = %v", err)
+	}
+	return &PushChannel{client: client, config: cfg, retry: RetryPolicy{MaxAttempts: 1}, logger: zap.NewNop()}
+}
+
+// fcmError returns a handler answering with an FCM v1 error
+func fcmError(status int, grpcStatus, errorCode string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(fcmErrorBody(status, grpcStatus, errorCode)))
+	}
+}
+
+// fcmErrorBody returns the body of an FCM v1 error
+func fcmErrorBody(status int, grpcStatus, errorCode string) string {
+	return fmt.Sprintf(`{"error":{"code":%d,"message":"failed","status":%q,"details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":%q}]}}`,
+		status, grpcStatus, errorCode)
+}
+
+func TestPushChannelClassifiesFCMErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		wantStatus    notification.NotificationStatus
+		wantRetryable bool
+		wantCode      string
+	}{
+		{
+			name: "accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"name":"projects/test/messages/1"}`))
+			},
+			wantStatus: notification.StatusSent,
+		},
+		{"unregistered", fcmError(404, "NOT_FOUND", "UNREGISTERED"), notification.StatusFailed, false, "UNREGISTERED"},
+		{"invalid argument", fcmError(400, "INVALID_ARGUMENT", "INVALID_ARGUMENT"), notification.StatusFailed, false, "INVALID_ARGUMENT"},
+		{"sender mismatch", fcmError(403, "PERMISSION_DENIED", "SENDER_ID_MISMATCH"), notification.StatusFailed, false, "SENDER_ID_MISMATCH"},
+		{"quota exceeded", fcmError(429, "RESOURCE_EXHAUSTED", "QUOTA_EXCEEDED"), notification.StatusFailed, true, "QUOTA_EXCEEDED"},
+		// The Firebase client retries 503s itself, so UNAVAILABLE comes with a 500
+		{"unavailable", fcmError(500, "UNAVAILABLE", "UNAVAILABLE"), notification.StatusFailed, true, "UNAVAILABLE"},
+		{"internal", fcmError(500, "INTERNAL", "INTERNAL"), notification.StatusFailed, true, "INTERNAL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := newTestPushChannel(t, config.FirebaseConfig{}, tt.handler)
+
+			report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Subject: "Hi", Body: "Hello"})
+			if (err != nil) != (tt.wantStatus == notification.StatusFailed) {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", report.Status, tt.wantStatus)
+			}
+			if report.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", report.Retryable, tt.wantRetryable)
+			}
+			if report.ProviderCode != tt.wantCode {
+				t.Errorf("ProviderCode = %q, want %q", report.ProviderCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+// fcmRequest is the part of an FCM v1 send request checked by tests
+type fcmRequest struct {
+	Message struct {
+		Token   string `json:"token"`
+		Topic   string `json:"topic"`
+		Android struct {
+			CollapseKey string `json:"collapse_key"`
+			Priority    string `json:"priority"`
+		} `json:"android"`
+		APNS struct {
+			Headers map[string]string `json:"headers"`
+		} `json:"apns"`
+		FCMOptions struct {
+			AnalyticsLabel string `json:"analytics_label"`
+		} `json:"fcm_options"`
+	} `json:"message"`
+}
+
+// recordFCM returns a handler accepting every message and the requests it got
+func recordFCM(t *testing.T) (http.HandlerFunc, func() []fcmRequest) {
+	var mu sync.Mutex
+	var requests []fcmRequest
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var req fcmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode FCM request: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		w.Write([]byte(`{"name":"projects/test/messages/1"}`))
+	}
+	return handler, func() []fcmRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fcmRequest(nil), requests...)
+	}
+}
+
+func TestPushChannelSetsCollapseKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		collapseKey string
+	}{
+		{"with collapse key", "chat-42"},
+		{"without collapse key", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, requests := recordFCM(t)
+			channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+			notif := notification.Notification{ID: "n1", Recipient: "token-1", Subject: "Chat", Body: "3 new messages", CollapseKey: tt.collapseKey}
+			if _, err := channel.SendNotification(context.Background(), notif); err != nil {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+
+			sent := requests()
+			if len(sent) != 1 {
+				t.Fatalf("sent %d FCM requests, want 1", len(sent))
+			}
+			if got := sent[0].Message.Android.CollapseKey; got != tt.collapseKey {
+				t.Errorf("android.collapse_key = %q, want %q", got, tt.collapseKey)
+			}
+			if got := sent[0].Message.APNS.Headers["apns-collapse-id"]; got != tt.collapseKey {
+				t.Errorf("apns-collapse-id = %q, want %q", got, tt.collapseKey)
+			}
+		})
+	}
+}
+
+// platformMessage returns a message with the APNs payload and Android
+// notification that platform options are applied to
+func platformMessage() *messaging.Message {
+	return &messaging.Message{
+		Android: &messaging.AndroidConfig{Notification: &messaging.AndroidNotification{}},
+		APNS:    &messaging.APNSConfig{Payload: &messaging.APNSPayload{Aps: &messaging.Aps{}}},
+	}
+}
+
+func TestApplyPlatformOptions(t *testing.T) {
+	message := platformMessage()
+	applyPlatformOptions(message, map[string]string{
+		notification.MetadataBadge:             "3",
+		notification.MetadataCategory:          "MESSAGE",
+		notification.MetadataThreadID:          "chat-42",
+		notification.MetadataInterruptionLevel: "time-sensitive",
+		notification.MetadataAndroidChannelID:  "messages",
+		notification.MetadataClickAction:       "OPEN_CHAT",
+	})
+
+	aps := message.APNS.Payload.Aps
+	if aps.Badge == nil || *aps.Badge != 3 {
+		t.Errorf("aps.badge = %v, want 3", aps.Badge)
+	}
+	if aps.Category != "MESSAGE" || aps.ThreadID != "chat-42" {
+		t.Errorf("aps.category = %q, aps.thread-id = %q", aps.Category, aps.ThreadID)
+	}
+	if got := aps.CustomData["interruption-level"]; got != "time-sensitive" {
+		t.Errorf("aps.interruption-level = %v, want time-sensitive", got)
+	}
+	android := message.Android.Notification
+	if android.ChannelID != "messages" || android.ClickAction != "OPEN_CHAT" {
+		t.Errorf("android channel_id = %q, click_action = %q", android.ChannelID, android.ClickAction)
+	}
+}
+
+func TestApplyPlatformOptionsWithoutMetadata(t *testing.T) {
+	message := platformMessage()
+	applyPlatformOptions(message, nil)
+
+	aps := message.APNS.Payload.Aps
+	if aps.Badge != nil || aps.Category != "" || aps.ThreadID != "" || aps.CustomData != nil {
+		t.Errorf("aps = %+v, want no platform options", aps)
+	}
+	if android := message.Android.Notification; android.ChannelID != "" || android.ClickAction != "" {
+		t.Errorf("android notification = %+v, want no platform options", android)
+	}
+}
+
+func TestPushChannelSendToTopic(t *testing.T) {
+	handler, requests := recordFCM(t)
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	report, err := channel.SendToTopic(context.Background(), "news", notification.Notification{ID: "b1", Subject: "Breaking", Body: "Something happened"})
+	if err != nil {
+		t.Fatalf("SendToTopic() error = %v", err)
+	}
+	if report.Status != notification.StatusSent {
+		t.Errorf("Status = %q, want sent", report.Status)
+	}
+
+	sent := requests()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d FCM requests, want 1", len(sent))
+	}
+	if sent[0].Message.Topic != "news" || sent[0].Message.Token != "" {
+		t.Errorf("FCM message topic = %q, token = %q, want only topic news", sent[0].Message.Topic, sent[0].Message.Token)
+	}
+}
+
+func TestPushChannelSendBatch(t *testing.T) {
+	var mu sync.Mutex
+	var tokens []string
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		var req fcmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode FCM request: %v", err)
+		}
+		mu.Lock()
+		tokens = append(tokens, req.Message.Token)
+		mu.Unlock()
+		if req.Message.Token == "stale-token" {
+			fcmError(404, "NOT_FOUND", "UNREGISTERED")(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"name":"projects/test/messages/%s"}`, req.Message.Token)
+	})
+
+	reports, err := channel.SendBatch(context.Background(), []notification.Notification{
+		{ID: "n1", Recipient: "token-1", Subject: "Hi", Body: "Hello"},
+		{ID: "n2", Recipient: "stale-token", Subject: "Hi", Body: "Hello"},
+		{ID: "n3", Recipient: "token-3", Subject: "Hi", Body: "Hello"},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("FCM got %d messages, want 3", len(tokens))
+	}
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3", len(reports))
+	}
+
+	if reports[0].NotificationID != "n1" || reports[0].Status != notification.StatusSent || reports[0].ExternalID != "projects/test/messages/token-1" {
+		t.Errorf("report 0 = %+v, want n1 sent", reports[0])
+	}
+	if reports[1].NotificationID != "n2" || reports[1].Status != notification.StatusFailed || reports[1].ProviderCode != "UNREGISTERED" || reports[1].Retryable {
+		t.Errorf("report 1 = %+v, want n2 failed permanently with UNREGISTERED", reports[1])
+	}
+	if reports[2].NotificationID != "n3" || reports[2].Status != notification.StatusSent {
+		t.Errorf("report 2 = %+v, want n3 sent", reports[2])
+	}
+}
+
+func TestBuildMessagePriority(t *testing.T) {
+	tests := []struct {
+		name             string
+		priority         int
+		wantAndroid      string
+		wantAndroidNotif messaging.AndroidNotificationPriority
+		wantAPNSPriority string
+	}{
+		{"high", notification.PriorityHigh, "high", messaging.PriorityHigh, "10"},
+		{"medium", notification.PriorityMedium, "normal", messaging.PriorityDefault, "5"},
+		{"low", notification.PriorityLow, "normal", messaging.PriorityDefault, "5"},
+		{"unset", 0, "normal", messaging.PriorityDefault, "5"},
+	}
+	for _, tt := range tests {
+		message := buildMessage(notification.Notification{ID: "n1", Subject: "Hi", Body: "Hello", Priority: tt.priority})
+		if message.Android.Priority != tt.wantAndroid || message.Android.Notification.Priority != tt.wantAndroidNotif {
+			t.Errorf("%s: android priority = %q, notification priority = %v, want %q, %v",
+				tt.name, message.Android.Priority, message.Android.Notification.Priority, tt.wantAndroid, tt.wantAndroidNotif)
+		}
+		if got := message.APNS.Headers["apns-priority"]; got != tt.wantAPNSPriority {
+			t.Errorf("%s: apns-priority = %q, want %q", tt.name, got, tt.wantAPNSPriority)
+		}
+	}
+}
+
+func TestPushChannelSendsPriority(t *testing.T) {
+	handler, requests := recordFCM(t)
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	for _, priority := range []int{notification.PriorityHigh, notification.PriorityLow} {
+		notif := notification.Notification{ID: "n1", Recipient: "token-1", Subject: "Hi", Body: "Hello", Priority: priority}
+		if _, err := channel.SendNotification(context.Background(), notif); err != nil {
+			t.Fatalf("SendNotification() error = %v", err)
+		}
+	}
+
+	sent := requests()
+	if len(sent) != 2 {
+		t.Fatalf("FCM got %d requests, want 2", len(sent))
+	}
+	if high := sent[0].Message; high.Android.Priority != "high" || high.APNS.Headers["apns-priority"] != "10" {
+		t.Errorf("high priority sent with android %q, apns %q, want high and 10", high.Android.Priority, high.APNS.Headers["apns-priority"])
+	}
+	if low := sent[1].Message; low.Android.Priority != "normal" || low.APNS.Headers["apns-priority"] != "5" {
+		t.Errorf("low priority sent with android %q, apns %q, want normal and 5", low.Android.Priority, low.APNS.Headers["apns-priority"])
+	}
+}
+
+// fcmSequence returns a handler answering each request with the next of
+// handlers, repeating the last, and the number of requests it got
+func fcmSequence(handlers ...http.HandlerFunc) (http.HandlerFunc, func() int) {
+	var mu sync.Mutex
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		next := handlers[min(calls, len(handlers)-1)]
+		calls++
+		mu.Unlock()
+		next(w, r)
+	}
+	return handler, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+// fcmAccepted answers with an accepted FCM send
+func fcmAccepted(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"name":"projects/test/messages/1"}`))
+}
+
+// fcmSlow answers after delay, or when the request is abandoned
+func fcmSlow(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			fcmAccepted(w, r)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestPushChannelRetriesTransientErrors(t *testing.T) {
+	handler, calls := fcmSequence(fcmError(500, "INTERNAL", "INTERNAL"), fcmAccepted)
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+	channel.retry = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+
+	report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if report.Status != notification.StatusSent || calls() != 2 {
+		t.Errorf("Status = %q after %d calls, want sent after 2", report.Status, calls())
+	}
+}
+
+func TestPushChannelDoesNotRetryPermanentErrors(t *testing.T) {
+	handler, calls := fcmSequence(fcmError(404, "NOT_FOUND", "UNREGISTERED"), fcmAccepted)
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+	channel.retry = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+
+	if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello"}); err == nil {
+		t.Fatal("SendNotification() error = nil, want UNREGISTERED")
+	}
+	if calls() != 1 {
+		t.Errorf("FCM called %d times, want 1", calls())
+	}
+}
+
+func TestPushChannelSendTimeout(t *testing.T) {
+	// A call cut short by the send timeout is retried
+	handler, calls := fcmSequence(fcmSlow(300*time.Millisecond), fcmAccepted)
+	channel := newTestPushChannel(t, config.FirebaseConfig{SendTimeout: 20 * time.Millisecond}, handler)
+	channel.retry = RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond}
+
+	start := time.Now()
+	report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if report.Status != notification.StatusSent || calls() != 2 {
+		t.Errorf("Status = %q after %d calls, want sent after 2", report.Status, calls())
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("SendNotification() took %v, want the slow call cut short", elapsed)
+	}
+
+	// Out of attempts, the timed out send is reported as retryable
+	channel = newTestPushChannel(t, config.FirebaseConfig{SendTimeout: 20 * time.Millisecond}, fcmSlow(300*time.Millisecond))
+	report, err = channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello"})
+	if err == nil || report.Status != notification.StatusFailed || !report.Retryable {
+		t.Errorf("SendNotification() = %+v, %v, want a retryable failure", report, err)
+	}
+}
+
+func TestPushChannelSendCancelled(t *testing.T) {
+	handler, calls := fcmSequence(fcmSlow(300 * time.Millisecond))
+	channel := newTestPushChannel(t, config.FirebaseConfig{SendTimeout: time.Minute}, handler)
+	channel.retry = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+
+	// The send's own deadline is not the send timeout, so it is not retried
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report, err := channel.SendNotification(ctx, notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello"})
+	if err == nil || report.Retryable {
+		t.Errorf("SendNotification() = %+v, %v, want a permanent failure", report, err)
+	}
+	if calls() != 1 {
+		t.Errorf("FCM called %d times, want 1", calls())
+	}
+}
+
+func TestPushChannelAppliesFCMOptions(t *testing.T) {
+	handler, requests := recordFCM(t)
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	options := notification.ProviderOptions{notification.ProviderFCM: {notification.OptionAnalyticsLabel: "spring_sale"}}
+	if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "token-1", Body: "Hello", ProviderOptions: options}); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n2", Recipient: "token-1", Body: "Hello"}); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+
+	got := requests()
+	if label := got[0].Message.FCMOptions.AnalyticsLabel; label != "spring_sale" {
+		t.Errorf("analytics_label = %q, want spring_sale", label)
+	}
+	if label := got[1].Message.FCMOptions.AnalyticsLabel; label != "" {
+		t.Errorf("analytics_label = %q without options, want none", label)
+	}
+}
+
+// fcmMulticast returns a handler answering FCM batch requests, with respond
+// giving the status and body of each token's part, and the tokens of each
+// request it got
+func fcmMulticast(t *testing.T, respond func(token string) (int, string)) (http.HandlerFunc, func() [][]string) {
+	var mu sync.Mutex
+	var calls [][]string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Errorf("failed to parse FCM batch content type: %v", err)
+			return
+		}
+
+		var tokens []string
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("failed to read FCM batch part: %v", err)
+				return
+			}
+			inner, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Errorf("failed to read FCM batch request: %v", err)
+				return
+			}
+			var req fcmRequest
+			if err := json.NewDecoder(inner.Body).Decode(&req); err != nil {
+				t.Errorf("failed to decode FCM batch request: %v", err)
+			}
+			tokens = append(tokens, req.Message.Token)
+		}
+		mu.Lock()
+		calls = append(calls, tokens)
+		mu.Unlock()
+
+		writer := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+		for _, token := range tokens {
+			status, body := respond(token)
+			part, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}})
+			fmt.Fprintf(part, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\n\r\n%s", status, http.StatusText(status), body)
+		}
+		writer.Close()
+	}
+	return handler, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), calls...)
+	}
+}
+
+func TestPushChannelSendBulkNotification(t *testing.T) {
+	handler, calls := fcmMulticast(t, func(token string) (int, string) {
+		switch token {
+		case "stale-token":
+			return 404, fcmErrorBody(404, "NOT_FOUND", "UNREGISTERED")
+		case "busy-token":
+			return 429, fcmErrorBody(429, "RESOURCE_EXHAUSTED", "QUOTA_EXCEEDED")
+		}
+		return 200, fmt.Sprintf(`{"name":"projects/test/messages/%s"}`, token)
+	})
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	tokens := []string{"token-1", "stale-token", "token-3", "busy-token"}
+	results, err := channel.SendBulkNotification(context.Background(), tokens, "Hi", "Hello", nil)
+	if err != nil {
+		t.Fatalf("SendBulkNotification() error = %v", err)
+	}
+	if len(calls()) != 1 {
+		t.Errorf("FCM got %d calls, want 1", len(calls()))
+	}
+	if len(results) != len(tokens) {
+		t.Fatalf("got %d results, want %d", len(results), len(tokens))
+	}
+
+	// Results are in the order of the tokens
+	for i, token := range tokens {
+		if results[i].Token != token {
+			t.Errorf("result %d token = %q, want %q", i, results[i].Token, token)
+		}
+	}
+	if report := results[0].Report; report.Status != notification.StatusSent || report.ExternalID != "projects/test/messages/token-1" {
+		t.Errorf("token-1 report = %+v, want sent", report)
+	}
+	if report := results[1].Report; report.Status != notification.StatusFailed || report.ProviderCode != "UNREGISTERED" || report.Retryable {
+		t.Errorf("stale-token report = %+v, want failed permanently with UNREGISTERED", report)
+	}
+	if report := results[2].Report; report.Status != notification.StatusSent || report.ExternalID != "projects/test/messages/token-3" {
+		t.Errorf("token-3 report = %+v, want sent", report)
+	}
+	if report := results[3].Report; report.Status != notification.StatusFailed || report.ProviderCode != "QUOTA_EXCEEDED" || !report.Retryable {
+		t.Errorf("busy-token report = %+v, want a retryable failure with QUOTA_EXCEEDED", report)
+	}
+}
+
+func TestPushChannelSendBulkNotificationChunks(t *testing.T) {
+	handler, calls := fcmMulticast(t, func(token string) (int, string) {
+		return 200, fmt.Sprintf(`{"name":"projects/test/messages/%s"}`, token)
+	})
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	tokens := make([]string, maxBatchMessages+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+	results, err := channel.SendBulkNotification(context.Background(), tokens, "Hi", "Hello", nil)
+	if err != nil {
+		t.Fatalf("SendBulkNotification() error = %v", err)
+	}
+
+	got := calls()
+	if len(got) != 2 || len(got[0]) != maxBatchMessages || len(got[1]) != 1 {
+		t.Fatalf("FCM calls = %d, want one of %d tokens and one of 1", len(got), maxBatchMessages)
+	}
+	if len(results) != len(tokens) {
+		t.Fatalf("got %d results, want %d", len(results), len(tokens))
+	}
+	for i, result := range results {
+		if result.Token != tokens[i] || result.Report.ExternalID != "projects/test/messages/"+tokens[i] {
+			t.Errorf("result %d = %s with %q, want %s", i, result.Token, result.Report.ExternalID, tokens[i])
+		}
+	}
+}
+
+func TestPushChannelSendBulkNotificationFailure(t *testing.T) {
+	channel := newTestPushChannel(t, config.FirebaseConfig{}, fcmError(500, "INTERNAL", ""))
+	if _, err := channel.SendBulkNotification(context.Background(), nil, "Hi", "Hello", nil); err == nil {
+		t.Error("SendBulkNotification() without tokens error = nil, want an error")
+	}
+
+	// Results of the chunks sent before a failed call are returned with its error
+	multicast, _ := fcmMulticast(t, func(token string) (int, string) {
+		return 200, `{"name":"projects/test/messages/1"}`
+	})
+	handler, _ := fcmSequence(multicast, fcmError(500, "INTERNAL", ""))
+	channel = newTestPushChannel(t, config.FirebaseConfig{}, handler)
+
+	tokens := make([]string, maxBatchMessages+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+	results, err := channel.SendBulkNotification(context.Background(), tokens, "Hi", "Hello", nil)
+	if err == nil {
+		t.Fatal("SendBulkNotification() error = nil, want the failed call")
+	}
+	if len(results) != maxBatchMessages {
+		t.Errorf("got %d results, want the %d of the first chunk", len(results), maxBatchMessages)
+	}
+}
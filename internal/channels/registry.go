@@ -0,0 +1,135 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"go.uber.org/zap"
+)
+
+// Factory builds a channel from the provider configuration. It returns a nil
+// channel when the channel is disabled in cfg.
+type Factory func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error)
+
+var (
+	factoriesMu sync.RWMutex
+	// factories holds the channel types workers can run, keyed by channel type
+	factories = map[string]Factory{
+		"email": func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+			if !cfg.SendGrid.Enabled {
+				return nil, nil
+			}
+			overrides, err := retryOverrides(cfg, "sendgrid")
+			if err != nil {
+				return nil, err
+			}
+			channel := NewEmailChannel(cfg.SendGrid, logger)
+			channel.retry.Overrides = overrides
+			return channel, nil
+		},
+		"sms": func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+			if !cfg.Twilio.Enabled {
+				return nil, nil
+			}
+			overrides, err := retryOverrides(cfg, "twilio")
+			if err != nil {
+				return nil, err
+			}
+			channel, err := NewSMSChannel(cfg.Twilio, logger)
+			if err != nil {
+				return nil, err
+			}
+			channel.retry.Overrides = overrides
+			return channel, nil
+		},
+		"push": func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+			if !cfg.Firebase.Enabled {
+				return nil, nil
+			}
+			overrides, err := retryOverrides(cfg, "fcm")
+			if err != nil {
+				return nil, err
+			}
+			channel, err := NewPushChannel(ctx, cfg.Firebase, logger)
+			if err != nil {
+				return nil, err
+			}
+			channel.retry.Overrides = overrides
+			return channel, nil
+		},
+	}
+)
+
+// retryOverrides returns the configured retry classification overrides for provider
+func retryOverrides(cfg config.ChannelsConfig, provider string) (RetryOverrides, error) {
+	overrides, err := ParseRetryOverrides(cfg.RetryOverrides)
+	if err != nil {
+		return nil, err
+	}
+	return overrides[provider], nil
+}
+
+// Register makes a channel type available to NewConfiguredManager, replacing
+// any factory registered for it before
+func Register(channelType string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[channelType] = factory
+}
+
+// Registered returns the registered channel types, sorted
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for channelType := range factories {
+		types = append(types, channelType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewChannel builds a channel of the given type with its registered factory.
+// The channel is nil when it is disabled in cfg.
+func NewChannel(ctx context.Context, channelType string, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[channelType]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown channel type: %s", channelType)
+	}
+
+	channel, err := factory(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s channel: %w", channelType, err)
+	}
+	return channel, nil
+}
+
+// NewConfiguredManager builds and registers a channel for each of
+// channelTypes, or for every registered type when none are given. Channels
+// disabled in the configuration are skipped.
+func NewConfiguredManager(ctx context.Context, cfg config.ChannelsConfig, channelTypes []string, logger *zap.Logger) (*ChannelManager, error) {
+	if len(channelTypes) == 0 {
+		channelTypes = Registered()
+	}
+
+	manager := NewChannelManager()
+	for _, channelType := range channelTypes {
+		channel, err := NewChannel(ctx, channelType, cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		if channel == nil {
+			logger.Info("Channel disabled, not starting it", zap.String("channel", channelType))
+			continue
+		}
+		manager.RegisterChannel(channel)
+		logger.Info("Channel initialized", zap.String("channel", channelType), zap.String("provider", channel.GetProviderName()))
+	}
+	return manager, nil
+}
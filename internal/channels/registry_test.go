@@ -0,0 +1,201 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// typedChannel is a channel of any type recording what it sent
+type typedChannel struct {
+	channelType string
+	sent        []string
+}
+
+func (c *typedChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	c.sent = append(c.sent, notif.ID)
+	return &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusSent, Provider: c.channelType}, nil
+}
+
+func (c *typedChannel) GetChannelType() string  { return c.channelType }
+func (c *typedChannel) GetProviderName() string { return "fake-" + c.channelType }
+
+// registerFactory registers factory for channelType for the duration of the test
+func registerFactory(t *testing.T, channelType string, factory Factory) {
+	t.Helper()
+	factoriesMu.RLock()
+	previous, existed := factories[channelType]
+	factoriesMu.RUnlock()
+
+	Register(channelType, factory)
+	t.Cleanup(func() {
+		factoriesMu.Lock()
+		defer factoriesMu.Unlock()
+		if existed {
+			factories[channelType] = previous
+		} else {
+			delete(factories, channelType)
+		}
+	})
+}
+
+func TestRegisteredBuiltInChannels(t *testing.T) {
+	if got, want := Registered(), []string{"email", "push", "sms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Registered() = %v, want %v", got, want)
+	}
+}
+
+func TestRegisterFactory(t *testing.T) {
+	chat := &typedChannel{channelType: "chat"}
+	registerFactory(t, "chat", func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+		return chat, nil
+	})
+
+	if got, want := Registered(), []string{"chat", "email", "push", "sms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Registered() = %v, want %v", got, want)
+	}
+	channel, err := NewChannel(context.Background(), "chat", config.ChannelsConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewChannel() error = %v", err)
+	}
+	if channel != chat {
+		t.Errorf("NewChannel() = %v, want the registered factory's channel", channel)
+	}
+
+	// Registering again replaces the factory
+	replacement := &typedChannel{channelType: "chat"}
+	Register("chat", func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+		return replacement, nil
+	})
+	if channel, _ := NewChannel(context.Background(), "chat", config.ChannelsConfig{}, zap.NewNop()); channel != replacement {
+		t.Errorf("NewChannel() after re-registering = %v, want the replacement", channel)
+	}
+}
+
+func TestNewChannelErrors(t *testing.T) {
+	if _, err := NewChannel(context.Background(), "fax", config.ChannelsConfig{}, zap.NewNop()); err == nil || !strings.Contains(err.Error(), "unknown channel type: fax") {
+		t.Errorf("NewChannel(fax) error = %v, want unknown channel type", err)
+	}
+
+	errBroken := errors.New("missing credentials")
+	registerFactory(t, "chat", func(ctx context.Context, cfg config.ChannelsConfig, logger *zap.Logger) (Channel, error) {
+		return nil, errBroken
+	})
+	if _, err := NewChannel(context.Background(), "chat", config.ChannelsConfig{}, zap.NewNop()); !errors.Is(err, errBroken) {
+		t.Errorf("NewChannel(chat) error = %v, want %v", err, errBroken)
+	}
+
+	cfg := config.ChannelsConfig{SendGrid: config.SendGridConfig{Enabled: true}, RetryOverrides: []string{"sendgrid:400"}}
+	if _, err := NewChannel(context.Background(), "email", cfg, zap.NewNop()); err == nil {
+		t.Error("NewChannel(email) with an invalid retry override succeeded, want error")
+	}
+}
+
+func TestNewChannelBuiltIns(t *testing.T) {
+	cfg := config.ChannelsConfig{
+		SendGrid:       config.SendGridConfig{Enabled: true, APIKey: "SG.test"},
+		Twilio:         config.TwilioConfig{Enabled: true, AccountSID: "AC123", FromNumbers: []string{"+15550000001"}},
+		RetryOverrides: []string{"twilio:30007=true", "sendgrid:400=true"},
+	}
+
+	email, err := NewChannel(context.Background(), "email", cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewChannel(email) error = %v", err)
+	}
+	if overrides := email.(*EmailChannel).retry.Overrides; !reflect.DeepEqual(overrides, RetryOverrides{"400": true}) {
+		t.Errorf("email retry overrides = %v, want the sendgrid ones", overrides)
+	}
+
+	sms, err := NewChannel(context.Background(), "sms", cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewChannel(sms) error = %v", err)
+	}
+	if overrides := sms.(*SMSChannel).retry.Overrides; !reflect.DeepEqual(overrides, RetryOverrides{"30007": true}) {
+		t.Errorf("sms retry overrides = %v, want the twilio ones", overrides)
+	}
+
+	// Disabled channels are not built
+	push, err := NewChannel(context.Background(), "push", cfg, zap.NewNop())
+	if err != nil || push != nil {
+		t.Errorf("NewChannel(push) = %v, %v, want nil for a disabled channel", push, err)
+	}
+}
+
+func TestNewConfiguredManager(t *testing.T) {
+	cfg := config.ChannelsConfig{
+		SendGrid: config.SendGridConfig{Enabled: true},
+		Twilio:   config.TwilioConfig{Enabled: true, FromNumbers: []string{"+15550000001"}},
+	}
+
+	// Every registered channel that is enabled
+	manager, err := NewConfiguredManager(context.Background(), cfg, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewConfiguredManager() error = %v", err)
+	}
+	if got, want := manager.ChannelTypes(), []string{"email", "sms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelTypes() = %v, want %v", got, want)
+	}
+
+	// Only the requested channels
+	manager, err = NewConfiguredManager(context.Background(), cfg, []string{"sms"}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewConfiguredManager(sms) error = %v", err)
+	}
+	if got, want := manager.ChannelTypes(), []string{"sms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelTypes() = %v, want %v", got, want)
+	}
+
+	if _, err := NewConfiguredManager(context.Background(), cfg, []string{"sms", "fax"}, zap.NewNop()); err == nil {
+		t.Error("NewConfiguredManager() with an unknown channel succeeded, want error")
+	}
+
+	// A misconfigured channel stops the worker from starting
+	cfg.Twilio.FromSelection = "hash"
+	if _, err := NewConfiguredManager(context.Background(), cfg, []string{"sms"}, zap.NewNop()); err == nil {
+		t.Error("NewConfiguredManager() with an invalid from number selection succeeded, want error")
+	}
+}
+
+func TestChannelManagerDispatchesByChannel(t *testing.T) {
+	email := &typedChannel{channelType: "email"}
+	sms := &typedChannel{channelType: "sms"}
+	manager := NewChannelManager()
+	manager.RegisterChannel(email)
+	manager.RegisterChannel(sms)
+
+	for _, notif := range []notification.Notification{
+		{ID: "n1", Channel: "email"},
+		{ID: "n2", Channel: "sms"},
+		{ID: "n3", Channel: "email"},
+	} {
+		report, err := manager.SendNotification(context.Background(), notif)
+		if err != nil {
+			t.Fatalf("SendNotification(%s) error = %v", notif.ID, err)
+		}
+		if report.Provider != notif.Channel {
+			t.Errorf("%s sent through %s, want %s", notif.ID, report.Provider, notif.Channel)
+		}
+	}
+	if want := []string{"n1", "n3"}; !reflect.DeepEqual(email.sent, want) {
+		t.Errorf("email sent %v, want %v", email.sent, want)
+	}
+	if want := []string{"n2"}; !reflect.DeepEqual(sms.sent, want) {
+		t.Errorf("sms sent %v, want %v", sms.sent, want)
+	}
+
+	// Channels without a registered channel fail without an error so the
+	// message is not retried
+	report, err := manager.SendNotification(context.Background(), notification.Notification{ID: "n4", Channel: "push"})
+	if err != nil {
+		t.Fatalf("SendNotification(push) error = %v", err)
+	}
+	if report.Status != notification.StatusFailed || report.ErrorMessage != "unsupported channel type: push" {
+		t.Errorf("report = %+v, want failed as unsupported", report)
+	}
+}
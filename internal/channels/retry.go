@@ -0,0 +1,215 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// RetryPolicy configures capped exponential backoff for provider calls
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Retryable decides whether an error returned by a send attempt is transient.
+	// When nil, IsRetryable is used.
+	Retryable func(error) bool
+	// Overrides reclassifies failures by provider code, ahead of the channel's
+	// built-in rules
+	Overrides RetryOverrides
+}
+
+// RetryOverrides maps provider error codes to whether they are retryable
+type RetryOverrides map[string]bool
+
+// ParseRetryOverrides parses "provider:code=retryable" entries, such as
+// "twilio:30007=true" or "sendgrid:400=false", into overrides per provider
+func ParseRetryOverrides(entries []string) (map[string]RetryOverrides, error) {
+	overrides := make(map[string]RetryOverrides)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		provider, code, hasCode := strings.Cut(key, ":")
+		provider, code = strings.TrimSpace(provider), strings.TrimSpace(code)
+		if !ok || !hasCode || provider == "" || code == "" {
+			return nil, fmt.Errorf("invalid retry override %q, want provider:code=true|false", entry)
+		}
+		retryable, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry override %q, want provider:code=true|false", entry)
+		}
+
+		if overrides[provider] == nil {
+			overrides[provider] = make(RetryOverrides)
+		}
+		overrides[provider][code] = retryable
+	}
+	return overrides, nil
+}
+
+// apply sets whether the failure in report is retryable from the override
+// for its provider code, if there is one
+func (o RetryOverrides) apply(report *notification.DeliveryReport) {
+	if report == nil || report.ProviderCode == "" {
+		return
+	}
+	if retryable, ok := o[report.ProviderCode]; ok {
+		report.Retryable = retryable
+	}
+}
+
+// DefaultRetryPolicy returns the retry policy used by all channels
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 200 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+	}
+}
+
+// RetryableError marks a provider error as transient so it can be retried
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// NewRetryableError wraps err so that IsRetryable reports true for it
+func NewRetryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+// IsRetryable reports whether err is a transient error worth retrying.
+// Errors explicitly marked with NewRetryableError, which takes precedence so a
+// channel can retry its own per-call timeouts, and network errors are
+// retryable. That includes an HTTP client's Timeout, which also satisfies
+// errors.Is(err, context.DeadlineExceeded): a hung provider is worth another
+// attempt. Cancellation, a bare deadline and everything else is treated as
+// permanent; see retryableSend for a deadline of the send's own context.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var retryableErr *RetryableError
+	if errors.As(err, &retryableErr) {
+		return true
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// context.DeadlineExceeded is a net.Error itself, so only errors wrapping
+	// it, such as a request's *url.Error, count as network errors
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr != error(context.DeadlineExceeded) {
+		return true
+	}
+
+	return false
+}
+
+// retryableSend reports whether err, returned by a provider call made with
+// ctx, is worth retrying. Once ctx itself is done, cancelled or past its
+// deadline, the send is over and its error is permanent.
+func retryableSend(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && IsRetryable(err)
+}
+
+// reportError converts the result of a single send attempt into the error seen
+// by WithRetry, marking it retryable when the delivery report says so after
+// applying the policy's overrides
+func (p RetryPolicy) reportError(report *notification.DeliveryReport, err error) error {
+	p.Overrides.apply(report)
+	if err != nil && report != nil && report.Retryable {
+		return NewRetryableError(err)
+	}
+	return err
+}
+
+// isRetryableStatus reports whether an HTTP status code from a provider is transient
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == 429 || statusCode >= 500
+}
+
+// WithRetry calls fn until it succeeds, returns a permanent error, the policy's
+// attempts are exhausted, or ctx is done. The last error from fn is returned.
+func WithRetry(ctx context.Context, logger *zap.Logger, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = fn(ctx)
+		if err == nil || !retryable(err) || attempt == maxAttempts {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		logger.Warn("Retrying after retryable error",
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+// backoff returns the jittered delay before the next attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	// Jitter between half and the full delay to avoid synchronized retries
+	half := delay / 2
+	return time.Duration(half + rand.Float64()*half)
+}
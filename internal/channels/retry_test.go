@@ -0,0 +1,297 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+// clientTimeoutError is the error of a request cut short by http.Client's
+// Timeout, which is a timeout matching context.DeadlineExceeded
+type clientTimeoutError struct{}
+
+func (clientTimeoutError) Error() string {
+	return "context deadline exceeded (Client.Timeout exceeded while awaiting headers)"
+}
+func (clientTimeoutError) Timeout() bool        { return true }
+func (clientTimeoutError) Is(target error) bool { return target == context.DeadlineExceeded }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain error", errors.New("bad request"), false},
+		{"marked retryable", NewRetryableError(errors.New("503")), true},
+		{"wrapped retryable", fmt.Errorf("send: %w", NewRetryableError(errors.New("503"))), true},
+		{"network timeout", timeoutError{}, true},
+		{"wrapped network error", fmt.Errorf("dial: %w", &net.OpError{Op: "dial", Err: timeoutError{}}), true},
+		{"context canceled", context.Canceled, false},
+		{"context deadline", fmt.Errorf("send: %w", context.DeadlineExceeded), false},
+		{"retryable deadline", NewRetryableError(context.DeadlineExceeded), true},
+		{"client timeout", &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: clientTimeoutError{}}, true},
+		{"cancelled request", &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: context.Canceled}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryableSend(t *testing.T) {
+	clientTimeout := &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: clientTimeoutError{}}
+	if !retryableSend(context.Background(), clientTimeout) {
+		t.Error("retryableSend() = false for a client timeout, want true")
+	}
+
+	// The send's own deadline passing ends the send
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	deadline := &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: context.DeadlineExceeded}
+	if retryableSend(ctx, deadline) {
+		t.Error("retryableSend() = true after the send's deadline, want false")
+	}
+}
+
+func TestNewRetryableErrorNil(t *testing.T) {
+	if err := NewRetryableError(nil); err != nil {
+		t.Errorf("NewRetryableError(nil) = %v, want nil", err)
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{200, false},
+		{400, false},
+		{401, false},
+		{404, false},
+		{429, true},
+		{500, true},
+		{502, true},
+		{503, true},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.status); got != tt.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	permanent := errors.New("permanent")
+	transient := NewRetryableError(errors.New("transient"))
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		errs         []error // returned by successive attempts, nil once exhausted
+		wantErr      error
+		wantAttempts int
+	}{
+		{"succeeds first time", 3, nil, nil, 1},
+		{"succeeds after retries", 3, []error{transient, transient}, nil, 3},
+		{"permanent error is not retried", 3, []error{permanent}, permanent, 1},
+		{"retries until permanent error", 3, []error{transient, permanent}, permanent, 2},
+		{"attempts exhausted", 3, []error{transient, transient, transient, transient}, transient, 3},
+		{"zero attempts runs once", 0, []error{transient, transient}, transient, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := RetryPolicy{MaxAttempts: tt.maxAttempts, InitialDelay: time.Millisecond, Multiplier: 2}
+			attempts := 0
+			err := WithRetry(context.Background(), zap.NewNop(), policy, func(ctx context.Context) error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("WithRetry() made %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWithRetryCustomClassifier(t *testing.T) {
+	errBusy := errors.New("busy")
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		Retryable:    func(err error) bool { return errors.Is(err, errBusy) },
+	}
+
+	attempts := 0
+	err := WithRetry(context.Background(), zap.NewNop(), policy, func(ctx context.Context) error {
+		attempts++
+		return errBusy
+	})
+	if !errors.Is(err, errBusy) || attempts != 3 {
+		t.Errorf("WithRetry() = %v after %d attempts, want busy after 3", err, attempts)
+	}
+}
+
+func TestWithRetryStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour}
+	transient := NewRetryableError(errors.New("transient"))
+
+	attempts := 0
+	err := WithRetry(ctx, zap.NewNop(), policy, func(ctx context.Context) error {
+		attempts++
+		cancel()
+		return transient
+	})
+	if !errors.Is(err, transient) || attempts != 1 {
+		t.Errorf("WithRetry() = %v after %d attempts, want the last error after 1", err, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+
+	tests := []struct {
+		attempt int
+		full    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := policy.backoff(tt.attempt)
+			if delay < tt.full/2 || delay > tt.full {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, delay, tt.full/2, tt.full)
+			}
+		}
+	}
+}
+
+func TestParseRetryOverrides(t *testing.T) {
+	got, err := ParseRetryOverrides([]string{"twilio:30007=true", " sendgrid : 400 = false ", "", "twilio:21211=TRUE", "twilio:30007=false"})
+	if err != nil {
+		t.Fatalf("ParseRetryOverrides() error = %v", err)
+	}
+	want := map[string]RetryOverrides{
+		"twilio":   {"30007": false, "21211": true}, // the last entry for a code wins
+		"sendgrid": {"400": false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRetryOverrides() = %v, want %v", got, want)
+	}
+
+	for _, entry := range []string{"twilio:30007", "twilio=true", ":30007=true", "twilio:=true", "twilio:30007=maybe"} {
+		if _, err := ParseRetryOverrides([]string{entry}); err == nil {
+			t.Errorf("ParseRetryOverrides(%q) succeeded, want error", entry)
+		}
+	}
+}
+
+func TestRetryOverridesApply(t *testing.T) {
+	overrides := RetryOverrides{"21211": true, "500": false}
+	tests := []struct {
+		code      string
+		retryable bool
+		want      bool
+	}{
+		{"21211", false, true},
+		{"500", true, false},
+		{"30007", true, true},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		report := &notification.DeliveryReport{ProviderCode: tt.code, Retryable: tt.retryable}
+		overrides.apply(report)
+		if report.Retryable != tt.want {
+			t.Errorf("apply() for code %q = %v, want %v", tt.code, report.Retryable, tt.want)
+		}
+	}
+
+	// Channels without overrides leave reports alone
+	var none RetryOverrides
+	report := &notification.DeliveryReport{ProviderCode: "500", Retryable: true}
+	none.apply(report)
+	none.apply(nil)
+	if !report.Retryable {
+		t.Error("nil overrides changed the classification")
+	}
+}
+
+func TestSMSChannelRetryOverrides(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		overrides     RetryOverrides
+		wantRetryable bool
+		wantAttempts  int
+	}{
+		{"permanent by default", 400, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, nil, false, 1},
+		{"permanent made retryable", 400, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, RetryOverrides{"21211": true}, true, 3},
+		{"transient by default", 503, `{"code":20503,"message":"Service unavailable"}`, nil, true, 3},
+		{"transient made permanent", 503, `{"code":20503,"message":"Service unavailable"}`, RetryOverrides{"20503": false}, false, 1},
+		{"other code unaffected", 400, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, RetryOverrides{"30007": true}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int64
+			channel := newTestSMSChannel(t, config.TwilioConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				twilioResponse(tt.status, tt.body)(w, r)
+			})
+			channel.retry = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, Overrides: tt.overrides}
+
+			report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15551234567", Body: "hi"})
+			if err == nil {
+				t.Fatal("SendNotification() succeeded, want the Twilio error")
+			}
+			if report.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", report.Retryable, tt.wantRetryable)
+			}
+			if got := attempts.Load(); got != int64(tt.wantAttempts) {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// shadowTimeout bounds each shadow send, which no longer follows the primary
+// send's context once that finishes
+const shadowTimeout = 30 * time.Second
+
+// ShadowConfig names the candidate provider a channel's sends are mirrored to
+// and the percentage of sends mirrored
+type ShadowConfig struct {
+	Candidate string  // channel type the candidate is registered under, see Register
+	Percent   float64 // 0 to 100
+}
+
+// ParseShadowSends parses "channel=candidate:percent" entries, such as
+// "email=email-candidate:5", into the shadow configuration of each channel
+func ParseShadowSends(entries []string) (map[string]ShadowConfig, error) {
+	shadows := make(map[string]ShadowConfig)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		channel, value, ok := strings.Cut(entry, "=")
+		candidate, percent, hasPercent := strings.Cut(value, ":")
+		channel, candidate = strings.TrimSpace(channel), strings.TrimSpace(candidate)
+		if !ok || !hasPercent || channel == "" || candidate == "" {
+			return nil, fmt.Errorf("invalid shadow send %q, want channel=candidate:percent", entry)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid shadow send %q, percent must be between 0 and 100", entry)
+		}
+		if candidate == channel {
+			return nil, fmt.Errorf("invalid shadow send %q, the candidate must differ from the channel", entry)
+		}
+		shadows[channel] = ShadowConfig{Candidate: candidate, Percent: p}
+	}
+	return shadows, nil
+}
+
+// Shadow mirrors a sampled share of a channel's sends to a candidate provider,
+// so its delivery can be compared with the primary provider's. The candidate
+// really sends, so it is usually pointed at a sandbox or test account. Its
+// outcome is only observed, never recorded on the notification.
+type Shadow struct {
+	candidate Channel
+	percent   float64
+	logger    *zap.Logger
+	inFlight  sync.WaitGroup
+}
+
+// NewShadow creates a shadow that mirrors percent of sends to candidate
+func NewShadow(candidate Channel, percent float64, logger *zap.Logger) *Shadow {
+	return &Shadow{candidate: candidate, percent: percent, logger: logger}
+}
+
+// Candidate returns the channel sends are mirrored to
+func (s *Shadow) Candidate() Channel {
+	return s.candidate
+}
+
+// Sampled reports whether the notification with id is mirrored. Sampling is
+// decided by a hash of the ID, so a retried notification is sampled the same
+// way every time and the sampled share matches the percentage.
+func (s *Shadow) Sampled(id string) bool {
+	if s.percent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) < s.percent*100
+}
+
+// Mirror sends notif through the candidate in the background and passes its
+// outcome and duration to observe. The send is detached from ctx's
+// cancellation, so it is not cut short when the primary send returns.
+func (s *Shadow) Mirror(ctx context.Context, notif notification.Notification, observe func(report *notification.DeliveryReport, err error, duration time.Duration)) {
+	s.inFlight.Add(1)
+	go func() {
+		defer s.inFlight.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				s.logger.Error("Shadow send panicked", zap.String("id", notif.ID), zap.Any("panic", r))
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+		defer cancel()
+
+		start := time.Now()
+		report, err := s.candidate.SendNotification(ctx, notif)
+		observe(report, err, time.Since(start))
+	}()
+}
+
+// Wait blocks until the shadow sends in flight have finished
+func (s *Shadow) Wait() {
+	s.inFlight.Wait()
+}
@@ -0,0 +1,85 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+func TestParseShadowSends(t *testing.T) {
+	shadows, err := ParseShadowSends([]string{" email=email-candidate:5 ", "", "sms=sms-candidate:0.5"})
+	if err != nil {
+		t.Fatalf("ParseShadowSends() error = %v", err)
+	}
+	if len(shadows) != 2 || shadows["email"] != (ShadowConfig{Candidate: "email-candidate", Percent: 5}) ||
+		shadows["sms"] != (ShadowConfig{Candidate: "sms-candidate", Percent: 0.5}) {
+		t.Errorf("ParseShadowSends() = %v", shadows)
+	}
+
+	for _, entry := range []string{"email", "email=email-candidate", "=candidate:5", "email=:5", "email=candidate:x", "email=candidate:101", "email=candidate:-1", "email=email:5"} {
+		if _, err := ParseShadowSends([]string{entry}); err == nil {
+			t.Errorf("ParseShadowSends(%q) error = nil", entry)
+		}
+	}
+}
+
+func TestShadowSampled(t *testing.T) {
+	const sends = 10000
+	sampled := func(percent float64) int {
+		shadow := NewShadow(&typedChannel{channelType: "email"}, percent, zap.NewNop())
+		count := 0
+		for i := 0; i < sends; i++ {
+			if shadow.Sampled(fmt.Sprintf("notification-%d", i)) {
+				count++
+			}
+		}
+		return count
+	}
+
+	if got := sampled(0); got != 0 {
+		t.Errorf("0%% sampled %d sends, want none", got)
+	}
+	if got := sampled(100); got != sends {
+		t.Errorf("100%% sampled %d sends, want all", got)
+	}
+	if got := sampled(10); got < sends*8/100 || got > sends*12/100 {
+		t.Errorf("10%% sampled %d of %d sends", got, sends)
+	}
+
+	// A retried notification is sampled the same way
+	shadow := NewShadow(&typedChannel{channelType: "email"}, 50, zap.NewNop())
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("notification-%d", i)
+		if shadow.Sampled(id) != shadow.Sampled(id) {
+			t.Fatalf("Sampled(%q) is not stable", id)
+		}
+	}
+}
+
+func TestShadowMirror(t *testing.T) {
+	candidate := &typedChannel{channelType: "email"}
+	shadow := NewShadow(candidate, 100, zap.NewNop())
+
+	// The mirrored send outlives the primary send's context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var observed *notification.DeliveryReport
+	shadow.Mirror(ctx, notification.Notification{ID: "n1"}, func(report *notification.DeliveryReport, err error, duration time.Duration) {
+		if err != nil {
+			t.Errorf("candidate send error = %v", err)
+		}
+		observed = report
+	})
+	shadow.Wait()
+
+	if len(candidate.sent) != 1 || candidate.sent[0] != "n1" {
+		t.Errorf("candidate sent %v, want n1", candidate.sent)
+	}
+	if observed == nil || observed.Status != notification.StatusSent {
+		t.Errorf("observed report = %+v, want the candidate's", observed)
+	}
+}
@@ -4,49 +4,156 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
 )
 
 // SMSChannel handles SMS notifications using Twilio
 type SMSChannel struct {
-	config config.TwilioConfig
-	client *http.Client
+	config   config.TwilioConfig
+	client   *http.Client
+	retry    RetryPolicy
+	throttle *throttle
+	next     atomic.Uint64 // round-robin cursor into config.FromNumbers
+	logger   *zap.Logger
 }
 
-// NewSMSChannel creates a new SMS channel
-func NewSMSChannel(cfg config.TwilioConfig) *SMSChannel {
+// Sender number selection modes for TwilioConfig.FromSelection
+const (
+	FromSelectionRoundRobin = "round_robin"
+	FromSelectionHashed     = "hashed"
+)
+
+// NewSMSChannel creates a new SMS channel. It fails without sender numbers or
+// with an unknown selection mode, rather than failing every send later.
+func NewSMSChannel(cfg config.TwilioConfig, logger *zap.Logger) (*SMSChannel, error) {
+	if len(cfg.FromNumbers) == 0 {
+		return nil, fmt.Errorf("no Twilio from numbers configured")
+	}
+	switch cfg.FromSelection {
+	case "", FromSelectionRoundRobin, FromSelectionHashed:
+	default:
+		return nil, fmt.Errorf("invalid Twilio from number selection %q: want %s or %s", cfg.FromSelection, FromSelectionRoundRobin, FromSelectionHashed)
+	}
+
 	return &SMSChannel{
-		config: cfg,
-		client: &http.Client{},
+		config:   cfg,
+		client:   newHTTPClient(cfg.HTTP),
+		retry:    DefaultRetryPolicy(),
+		throttle: newThrottle("twilio", logger),
+		logger:   logger,
+	}, nil
+}
+
+// baseURL returns the Twilio API host, defaulting to the public API
+func (s *SMSChannel) baseURL() string {
+	if s.config.BaseURL == "" {
+		return "https://api.twilio.com"
 	}
+	return strings.TrimSuffix(s.config.BaseURL, "/")
+}
+
+// selectFrom picks the sender number for a recipient. In hashed mode the same
+// recipient always gets the same number; otherwise numbers are used round-robin.
+func (s *SMSChannel) selectFrom(recipient string) string {
+	numbers := s.config.FromNumbers
+	if s.config.FromSelection == FromSelectionHashed {
+		h := fnv.New32a()
+		h.Write([]byte(recipient))
+		return numbers[h.Sum32()%uint32(len(numbers))]
+	}
+
+	i := s.next.Add(1) - 1
+	return numbers[i%uint64(len(numbers))]
 }
 
 // TwilioResponse represents the response from Twilio API
 type TwilioResponse struct {
-	SID         string `json:"sid"`
-	Status      string `json:"status"`
-	ErrorCode   *int   `json:"error_code,omitempty"`
+	SID          string  `json:"sid"`
+	Status       string  `json:"status"`
+	ErrorCode    *int    `json:"error_code,omitempty"`
 	ErrorMessage *string `json:"error_message,omitempty"`
+	// Code and Message are set on Twilio API error responses
+	Code    *int    `json:"code,omitempty"`
+	Message *string `json:"message,omitempty"`
+}
+
+// Twilio error codes that affect retry classification
+const (
+	twilioInvalidToNumber    = 21211
+	twilioTooManyRequests    = 20429
+	twilioQueueOverflow      = 30001
+	twilioServiceUnavailable = 20503
+)
+
+// isRetryableTwilioError classifies a failed Twilio API call by error code,
+// falling back to the HTTP status when the code is unknown
+func isRetryableTwilioError(statusCode, code int) bool {
+	switch code {
+	case twilioTooManyRequests, twilioQueueOverflow, twilioServiceUnavailable:
+		return true
+	case twilioInvalidToNumber:
+		return false
+	}
+	return isRetryableStatus(statusCode)
 }
 
-// SendNotification sends an SMS notification
+// SendNotification sends an SMS notification, retrying transient Twilio
+// failures and holding back while Twilio signals rate limit pressure
 func (s *SMSChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
-	log.Printf("Sending SMS notification %s to %s", notif.ID, notif.Recipient)
+	s.logger.Info("Sending SMS notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel))
+
+	// Pick the sender once so retries go out from the same number
+	from := s.selectFrom(notif.Recipient)
 
+	var report *notification.DeliveryReport
+	err := WithRetry(ctx, s.logger, s.retry, func(ctx context.Context) error {
+		if err := s.throttle.wait(ctx); err != nil {
+			report = throttledReport(notif.ID, err)
+			return err
+		}
+		var sendErr error
+		report, sendErr = s.send(ctx, notif, from)
+		return s.retry.reportError(report, sendErr)
+	})
+	if report != nil {
+		if report.Metadata == nil {
+			report.Metadata = make(map[string]string)
+		}
+		report.Metadata["from_number"] = from
+	}
+	return report, err
+}
+
+// send performs a single Twilio API call
+func (s *SMSChannel) send(ctx context.Context, notif notification.Notification, from string) (*notification.DeliveryReport, error) {
 	// Prepare the request data
 	data := url.Values{}
 	data.Set("To", notif.Recipient)
-	data.Set("From", "+1234567890") // Your Twilio phone number
+	data.Set("From", from)
 	data.Set("Body", notif.Body)
+	// Media turns the message into an MMS
+	for _, mediaURL := range notification.ParseMediaURLs(notif.Metadata[notification.MetadataMediaURLs]) {
+		data.Add("MediaUrl", mediaURL)
+	}
+	// Pass through the notification's Twilio options
+	if callback := notif.ProviderOptions.Get(notification.ProviderTwilio, notification.OptionStatusCallback); callback != "" {
+		data.Set("StatusCallback", callback)
+	}
+	if period := notif.ProviderOptions.Get(notification.ProviderTwilio, notification.OptionValidityPeriod); period != "" {
+		data.Set("ValidityPeriod", period)
+	}
 
 	// Create the request
-	twilioURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", s.config.AccountSID)
+	twilioURL := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.baseURL(), s.config.AccountSID)
 	req, err := http.NewRequestWithContext(ctx, "POST", twilioURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return &notification.DeliveryReport{
@@ -63,14 +170,16 @@ func (s *SMSChannel) SendNotification(ctx context.Context, notif notification.No
 	// Send the request
 	resp, err := s.client.Do(req)
 	if err != nil {
-		log.Printf("Failed to send SMS notification %s: %v", notif.ID, err)
+		s.logger.Error("Failed to send SMS notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.Error(err))
 		return &notification.DeliveryReport{
 			NotificationID: notif.ID,
 			Status:         notification.StatusFailed,
 			ErrorMessage:   err.Error(),
+			Retryable:      retryableSend(ctx, err),
 		}, err
 	}
 	defer resp.Body.Close()
+	s.throttle.observe(resp.Header)
 
 	// Parse the response
 	var twilioResp TwilioResponse
@@ -79,34 +188,58 @@ func (s *SMSChannel) SendNotification(ctx context.Context, notif notification.No
 			NotificationID: notif.ID,
 			Status:         notification.StatusFailed,
 			ErrorMessage:   "Failed to parse Twilio response",
+			Retryable:      isRetryableStatus(resp.StatusCode),
+			ProviderCode:   strconv.Itoa(resp.StatusCode),
 		}, err
 	}
 
 	// Check if the request was successful
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Successfully sent SMS notification %s (Twilio SID: %s)", notif.ID, twilioResp.SID)
-		return &notification.DeliveryReport{
-			NotificationID: notif.ID,
-			ExternalID:     twilioResp.SID,
-			Status:         notification.StatusSent,
-		}, nil
+		s.logger.Info("Sent SMS notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.String("external_id", twilioResp.SID), zap.String("from", from))
+		return acceptedReport(notif.ID, twilioResp.SID), nil
 	}
 
 	// Handle error response
 	errorMsg := "Unknown Twilio error"
 	if twilioResp.ErrorMessage != nil {
 		errorMsg = *twilioResp.ErrorMessage
+	} else if twilioResp.Message != nil {
+		errorMsg = *twilioResp.Message
 	}
 
-	log.Printf("SMS notification %s failed: %s", notif.ID, errorMsg)
+	var code int
+	if twilioResp.Code != nil {
+		code = *twilioResp.Code
+	} else if twilioResp.ErrorCode != nil {
+		code = *twilioResp.ErrorCode
+	}
+
+	providerCode := strconv.Itoa(resp.StatusCode)
+	if code != 0 {
+		providerCode = strconv.Itoa(code)
+	}
+
+	s.logger.Error("Twilio rejected SMS notification", zap.String("id", notif.ID), zap.String("user_id", notif.UserID), zap.String("channel", notif.Channel), zap.String("provider_code", providerCode), zap.String("error", errorMsg))
 	return &notification.DeliveryReport{
 		NotificationID: notif.ID,
 		Status:         notification.StatusFailed,
 		ErrorMessage:   errorMsg,
+		Retryable:      isRetryableTwilioError(resp.StatusCode, code),
+		ProviderCode:   providerCode,
 	}, fmt.Errorf("twilio error: %s", errorMsg)
 }
 
 // GetChannelType returns the channel type
 func (s *SMSChannel) GetChannelType() string {
 	return "sms"
-}
\ No newline at end of file
+}
+
+// GetProviderName returns the name of the provider used for metrics
+func (s *SMSChannel) GetProviderName() string {
+	return "twilio"
+}
+
+// OnRateLimit implements RateLimitReporter
+func (s *SMSChannel) OnRateLimit(fn func(remaining int)) {
+	s.throttle.setOnRemaining(fn)
+}
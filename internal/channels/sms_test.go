@@ -0,0 +1,340 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// newTestSMSChannel returns an SMS channel sending to a Twilio mock served by
+// handler, without retries
+func newTestSMSChannel(t *testing.T, cfg config.TwilioConfig, handler http.HandlerFunc) *SMSChannel {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.BaseURL = server.URL
+	cfg.AccountSID = "AC123"
+	if len(cfg.FromNumbers) == 0 {
+		cfg.FromNumbers = []string{"+15550000001"}
+	}
+	channel, err := NewSMSChannel(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSMSChannel() error = %v", err)
+	}
+	channel.retry = RetryPolicy{MaxAttempts: 1}
+	return channel
+}
+
+// twilioResponse returns a handler answering with status and body
+func twilioResponse(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestSMSChannelClassifiesTwilioErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantStatus    notification.NotificationStatus
+		wantRetryable bool
+		wantCode      string
+	}{
+		{"accepted", 201, `{"sid":"SM1","status":"queued"}`, notification.StatusSent, false, ""},
+		{"invalid number", 400, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, notification.StatusFailed, false, "21211"},
+		{"too many requests", 429, `{"code":20429,"message":"Too Many Requests"}`, notification.StatusFailed, true, "20429"},
+		{"queue overflow", 400, `{"code":30001,"message":"Queue overflow"}`, notification.StatusFailed, true, "30001"},
+		{"unknown client error", 400, `{"code":21602,"message":"Message body is required"}`, notification.StatusFailed, false, "21602"},
+		{"server error without code", 503, `{"message":"Service Unavailable"}`, notification.StatusFailed, true, "503"},
+		{"unparseable server error", 502, `<html>Bad Gateway</html>`, notification.StatusFailed, true, "502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := newTestSMSChannel(t, config.TwilioConfig{}, twilioResponse(tt.status, tt.body))
+
+			report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15551234567", Body: "hi"})
+			if (err != nil) != (tt.wantStatus == notification.StatusFailed) {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", report.Status, tt.wantStatus)
+			}
+			if report.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", report.Retryable, tt.wantRetryable)
+			}
+			if report.ProviderCode != tt.wantCode {
+				t.Errorf("ProviderCode = %q, want %q", report.ProviderCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestIsRetryableTwilioError(t *testing.T) {
+	tests := []struct {
+		status int
+		code   int
+		want   bool
+	}{
+		{400, twilioInvalidToNumber, false},
+		{500, twilioInvalidToNumber, false},
+		{429, twilioTooManyRequests, true},
+		{400, twilioQueueOverflow, true},
+		{503, twilioServiceUnavailable, true},
+		{400, 0, false},
+		{429, 0, true},
+		{500, 12345, true},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryableTwilioError(tt.status, tt.code); got != tt.want {
+			t.Errorf("isRetryableTwilioError(%d, %d) = %v, want %v", tt.status, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestSMSChannelRoundRobinFromNumbers(t *testing.T) {
+	numbers := []string{"+15550000001", "+15550000002", "+15550000003"}
+	var mu sync.Mutex
+	used := make(map[string]int)
+	channel := newTestSMSChannel(t, config.TwilioConfig{FromNumbers: numbers}, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		used[r.FormValue("From")]++
+		mu.Unlock()
+		twilioResponse(201, `{"sid":"SM1","status":"queued"}`)(w, r)
+	})
+
+	// Each number is used equally often, even for a single recipient
+	const rounds = 4
+	for i := 0; i < rounds*len(numbers); i++ {
+		report, err := channel.SendNotification(context.Background(), notification.Notification{ID: fmt.Sprintf("n%d", i), Recipient: "+15551234567", Body: "hi"})
+		if err != nil {
+			t.Fatalf("SendNotification() error = %v", err)
+		}
+		if want := numbers[i%len(numbers)]; report.Metadata["from_number"] != want {
+			t.Errorf("message %d from_number = %q, want %q", i, report.Metadata["from_number"], want)
+		}
+	}
+	for _, number := range numbers {
+		if used[number] != rounds {
+			t.Errorf("%s sent %d messages, want %d", number, used[number], rounds)
+		}
+	}
+}
+
+func TestSMSChannelHashedFromNumbers(t *testing.T) {
+	numbers := []string{"+15550000001", "+15550000002", "+15550000003"}
+	channel := newTestSMSChannel(t, config.TwilioConfig{FromNumbers: numbers, FromSelection: FromSelectionHashed},
+		twilioResponse(201, `{"sid":"SM1","status":"queued"}`))
+
+	// A recipient always gets the same number
+	assigned := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		for r := 0; r < 20; r++ {
+			recipient := fmt.Sprintf("+1555100%04d", r)
+			report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: recipient, Body: "hi"})
+			if err != nil {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+			from := report.Metadata["from_number"]
+			if previous, ok := assigned[recipient]; ok && previous != from {
+				t.Errorf("%s sent from %s, then from %s", recipient, previous, from)
+			}
+			assigned[recipient] = from
+		}
+	}
+
+	// and recipients are spread across the pool
+	distinct := make(map[string]bool)
+	for _, from := range assigned {
+		distinct[from] = true
+	}
+	if len(distinct) != len(numbers) {
+		t.Errorf("20 recipients used %d numbers, want all %d", len(distinct), len(numbers))
+	}
+}
+
+func TestNewSMSChannelValidatesFromNumbers(t *testing.T) {
+	numbers := []string{"+15550000001"}
+	for _, selection := range []string{"", FromSelectionRoundRobin, FromSelectionHashed} {
+		if _, err := NewSMSChannel(config.TwilioConfig{FromNumbers: numbers, FromSelection: selection}, zap.NewNop()); err != nil {
+			t.Errorf("NewSMSChannel(%q) error = %v", selection, err)
+		}
+	}
+
+	invalid := []config.TwilioConfig{
+		{},
+		{FromNumbers: []string{}},
+		{FromNumbers: numbers, FromSelection: "hash"},
+	}
+	for _, cfg := range invalid {
+		if channel, err := NewSMSChannel(cfg, zap.NewNop()); err == nil {
+			t.Errorf("NewSMSChannel(%+v) = %v, want an error", cfg, channel)
+		}
+	}
+}
+
+func TestSMSChannelTimesOutHungTwilio(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	channel := newTestSMSChannel(t, config.TwilioConfig{HTTP: config.HTTPClientConfig{Timeout: timeout}}, func(w http.ResponseWriter, r *http.Request) {
+		// Never answer, until the client gives up. Reading the form lets the
+		// server notice the client going away.
+		r.ParseForm()
+		<-r.Context().Done()
+	})
+
+	start := time.Now()
+	report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15550000002", Body: "hi"})
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatalf("SendNotification() to a hung Twilio succeeded, report = %+v", report)
+	}
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("SendNotification() returned after %v, want the %v timeout", elapsed, timeout)
+	}
+	// The worker retries a hung provider later
+	if !report.Retryable {
+		t.Errorf("report = %+v, want a retryable failure", report)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client := newHTTPClient(config.HTTPClientConfig{Timeout: 5 * time.Second, MaxIdleConnsPerHost: 50, IdleConnTimeout: time.Minute})
+	transport := client.Transport.(*http.Transport)
+	if client.Timeout != 5*time.Second || transport.MaxIdleConnsPerHost != 50 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("client timeout = %v, MaxIdleConnsPerHost = %d, IdleConnTimeout = %v", client.Timeout, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+
+	// Unset values keep the defaults of the standard transport
+	defaults := http.DefaultTransport.(*http.Transport)
+	transport = newHTTPClient(config.HTTPClientConfig{}).Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != defaults.MaxIdleConnsPerHost || transport.IdleConnTimeout != defaults.IdleConnTimeout {
+		t.Errorf("default MaxIdleConnsPerHost = %d, IdleConnTimeout = %v", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+}
+
+func TestSMSChannelSendsToConfiguredBaseURL(t *testing.T) {
+	type request struct {
+		method, path, contentType string
+		user, password            string
+		to, from, body            string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		requests <- request{
+			method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type"),
+			user: user, password: password,
+			to: r.FormValue("To"), from: r.FormValue("From"), body: r.FormValue("Body"),
+		}
+		twilioResponse(201, `{"sid":"SM1","status":"queued"}`)(w, r)
+	}))
+	defer server.Close()
+
+	// A trailing slash on the configured host is tolerated
+	channel, err := NewSMSChannel(config.TwilioConfig{
+		AccountSID:  "AC123",
+		AuthToken:   "secret",
+		BaseURL:     server.URL + "/",
+		FromNumbers: []string{"+15550000001"},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSMSChannel() error = %v", err)
+	}
+	channel.retry = RetryPolicy{MaxAttempts: 1}
+
+	report, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if report.Status != notification.StatusSent || report.ExternalID != "SM1" {
+		t.Errorf("report = %+v, want sent with external ID SM1", report)
+	}
+
+	got := <-requests
+	want := request{
+		method: "POST", path: "/2010-04-01/Accounts/AC123/Messages.json", contentType: "application/x-www-form-urlencoded",
+		user: "AC123", password: "secret",
+		to: "+15551234567", from: "+15550000001", body: "hi",
+	}
+	if got != want {
+		t.Errorf("request = %+v, want %+v", got, want)
+	}
+}
+
+func TestSMSChannelDefaultBaseURL(t *testing.T) {
+	channel, err := NewSMSChannel(config.TwilioConfig{FromNumbers: []string{"+15550000001"}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSMSChannel() error = %v", err)
+	}
+	if got := channel.baseURL(); got != "https://api.twilio.com" {
+		t.Errorf("baseURL() = %q, want https://api.twilio.com", got)
+	}
+}
+
+func TestSMSChannelSendsMediaURLsAsMMS(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     []string
+	}{
+		{"sms", nil, nil},
+		{"mms", map[string]string{notification.MetadataMediaURLs: "https://cdn.example.com/a.png, https://cdn.example.com/b.gif"}, []string{"https://cdn.example.com/a.png", "https://cdn.example.com/b.gif"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			channel := newTestSMSChannel(t, config.TwilioConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				got = r.PostForm["MediaUrl"]
+				twilioResponse(201, `{"sid":"MM1","status":"queued"}`)(w, r)
+			})
+
+			if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15551234567", Body: "Look", Metadata: tt.metadata}); err != nil {
+				t.Fatalf("SendNotification() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MediaUrl = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMSChannelAppliesTwilioOptions(t *testing.T) {
+	var form url.Values
+	channel := newTestSMSChannel(t, config.TwilioConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		twilioResponse(201, `{"sid":"SM1","status":"queued"}`)(w, r)
+	})
+
+	options := notification.ProviderOptions{notification.ProviderTwilio: {
+		notification.OptionStatusCallback: "https://example.com/twilio/status",
+		notification.OptionValidityPeriod: "600",
+		"unknown_option":                  "ignored",
+	}}
+	if _, err := channel.SendNotification(context.Background(), notification.Notification{ID: "n1", Recipient: "+15551234567", Body: "hi", ProviderOptions: options}); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if form.Get("StatusCallback") != "https://example.com/twilio/status" || form.Get("ValidityPeriod") != "600" {
+		t.Errorf("StatusCallback = %q, ValidityPeriod = %q, want the Twilio options", form.Get("StatusCallback"), form.Get("ValidityPeriod"))
+	}
+	if _, ok := form["unknown_option"]; ok {
+		t.Error("unknown option was sent to Twilio")
+	}
+}
@@ -0,0 +1,168 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+// Rate limit response headers sent by SendGrid and Twilio
+const (
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// maxThrottleDelay caps how long a provider can hold back the next send, so a
+// bogus header cannot stall a channel indefinitely
+const maxThrottleDelay = time.Minute
+
+// RateLimitReporter is implemented by channels that track the quota their
+// provider reports. The function is called with the remaining requests each
+// time a response reports them.
+type RateLimitReporter interface {
+	OnRateLimit(fn func(remaining int))
+}
+
+// rateLimit is the provider quota reported in a response's headers
+type rateLimit struct {
+	limit      int           // requests per window, 0 when not reported
+	remaining  int           // requests left in the window, -1 when not reported
+	reset      time.Time     // when the window resets, zero when not reported
+	retryAfter time.Duration // how long to wait before the next request, 0 when not reported
+}
+
+// parseRateLimit reads Retry-After and the X-RateLimit-* headers. Retry-After
+// is in seconds or an HTTP date; X-RateLimit-Reset is a Unix timestamp, as
+// SendGrid sends it, or seconds from now for small values.
+func parseRateLimit(header http.Header, now time.Time) rateLimit {
+	limits := rateLimit{remaining: -1}
+
+	if value := strings.TrimSpace(header.Get(headerRetryAfter)); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			limits.retryAfter = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			limits.retryAfter = date.Sub(now)
+		}
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(header.Get(headerRateLimitLimit))); err == nil && value > 0 {
+		limits.limit = value
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(header.Get(headerRateLimitRemaining))); err == nil && value >= 0 {
+		limits.remaining = value
+	}
+	if value, err := strconv.ParseInt(strings.TrimSpace(header.Get(headerRateLimitReset)), 10, 64); err == nil && value > 0 {
+		if value > 1_000_000_000 {
+			limits.reset = time.Unix(value, 0)
+		} else {
+			limits.reset = now.Add(time.Duration(value) * time.Second)
+		}
+	}
+	return limits
+}
+
+// delay returns how long to hold back the next request: the Retry-After
+// period when given, until the reset once the quota is used up, and the rest
+// of the window spread over the remaining requests once fewer than a tenth of
+// them are left. It returns 0 when the provider signals no pressure.
+func (l rateLimit) delay(now time.Time) time.Duration {
+	var delay time.Duration
+	switch {
+	case l.retryAfter > 0:
+		delay = l.retryAfter
+	case l.remaining < 0 || l.reset.IsZero() || !l.reset.After(now):
+		return 0
+	case l.remaining == 0:
+		delay = l.reset.Sub(now)
+	case l.remaining <= max(1, l.limit/10):
+		delay = l.reset.Sub(now) / time.Duration(l.remaining+1)
+	default:
+		return 0
+	}
+	return min(delay, maxThrottleDelay)
+}
+
+// throttle holds back a channel's sends while its provider signals rate
+// limit pressure, so the channel slows down before it is answered with 429s
+type throttle struct {
+	mu          sync.Mutex
+	until       time.Time // no request before this time
+	onRemaining func(int)
+	provider    string
+	logger      *zap.Logger
+}
+
+// newThrottle creates a throttle for provider
+func newThrottle(provider string, logger *zap.Logger) *throttle {
+	return &throttle{provider: provider, logger: logger}
+}
+
+// observe records the rate limit headers of a provider response
+func (t *throttle) observe(header http.Header) {
+	now := time.Now()
+	limits := parseRateLimit(header, now)
+	delay := limits.delay(now)
+
+	t.mu.Lock()
+	onRemaining := t.onRemaining
+	if delay > 0 && now.Add(delay).After(t.until) {
+		t.until = now.Add(delay)
+	}
+	t.mu.Unlock()
+
+	if delay > 0 {
+		t.logger.Warn("Provider signalled rate limit pressure, slowing down",
+			zap.String("provider", t.provider),
+			zap.Int("remaining", limits.remaining),
+			zap.Duration("retry_after", limits.retryAfter),
+			zap.Duration("delay", delay),
+		)
+	}
+	if onRemaining != nil && limits.remaining >= 0 {
+		onRemaining(limits.remaining)
+	}
+}
+
+// wait blocks until the provider may be called again or ctx is done
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	delay := time.Until(t.until)
+	t.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReport reports a notification that was not sent because ctx ended
+// while waiting for the provider's rate limit
+func throttledReport(notificationID string, err error) *notification.DeliveryReport {
+	return &notification.DeliveryReport{
+		NotificationID: notificationID,
+		Status:         notification.StatusFailed,
+		ErrorMessage:   "waiting for provider rate limit: " + err.Error(),
+		Retryable:      true,
+	}
+}
+
+// setOnRemaining sets the function called with the remaining quota
+func (t *throttle) setOnRemaining(fn func(int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onRemaining = fn
+}
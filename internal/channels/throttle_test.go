@@ -0,0 +1,144 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"go.uber.org/zap"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    rateLimit
+	}{
+		{"none", nil, rateLimit{remaining: -1}},
+		{"retry after seconds", map[string]string{"Retry-After": "30"}, rateLimit{remaining: -1, retryAfter: 30 * time.Second}},
+		{"retry after date", map[string]string{"Retry-After": "Wed, 01 May 2024 12:00:45 GMT"}, rateLimit{remaining: -1, retryAfter: 45 * time.Second}},
+		{"retry after past date", map[string]string{"Retry-After": "Wed, 01 May 2024 11:00:00 GMT"}, rateLimit{remaining: -1}},
+		{"sendgrid quota", map[string]string{"X-RateLimit-Limit": "600", "X-RateLimit-Remaining": "12", "X-RateLimit-Reset": "1714564860"},
+			rateLimit{limit: 600, remaining: 12, reset: time.Unix(1714564860, 0)}},
+		{"relative reset", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "20"}, rateLimit{remaining: 0, reset: now.Add(20 * time.Second)}},
+		{"invalid values", map[string]string{"Retry-After": "soon", "X-RateLimit-Limit": "-1", "X-RateLimit-Remaining": "many", "X-RateLimit-Reset": "0"}, rateLimit{remaining: -1}},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for name, value := range tt.headers {
+			header.Set(name, value)
+		}
+		got := parseRateLimit(header, now)
+		if got.limit != tt.want.limit || got.remaining != tt.want.remaining || !got.reset.Equal(tt.want.reset) || got.retryAfter != tt.want.retryAfter {
+			t.Errorf("%s: parseRateLimit() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(10 * time.Second)
+	tests := []struct {
+		name   string
+		limits rateLimit
+		want   time.Duration
+	}{
+		{"not reported", rateLimit{remaining: -1}, 0},
+		{"retry after", rateLimit{remaining: 50, limit: 100, reset: reset, retryAfter: 3 * time.Second}, 3 * time.Second},
+		{"retry after capped", rateLimit{remaining: -1, retryAfter: time.Hour}, maxThrottleDelay},
+		{"plenty left", rateLimit{limit: 100, remaining: 50, reset: reset}, 0},
+		{"used up", rateLimit{limit: 100, remaining: 0, reset: reset}, 10 * time.Second},
+		{"few left", rateLimit{limit: 100, remaining: 4, reset: reset}, 2 * time.Second},
+		{"reset passed", rateLimit{limit: 100, remaining: 0, reset: now.Add(-time.Second)}, 0},
+		{"no reset", rateLimit{limit: 100, remaining: 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.delay(now); got != tt.want {
+			t.Errorf("%s: delay() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleHoldsBackUntilReset(t *testing.T) {
+	throttle := newThrottle("twilio", zap.NewNop())
+	var reported []int
+	throttle.setOnRemaining(func(remaining int) { reported = append(reported, remaining) })
+
+	// No pressure: nothing to wait for
+	throttle.observe(http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"80"}, "X-Ratelimit-Reset": {"30"}})
+	if err := throttle.wait(context.Background()); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	// Quota used up: sends wait for the reset
+	throttle.observe(http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"30"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := throttle.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait() error = %v, want to be held back past the deadline", err)
+	}
+
+	// A shorter delay does not shorten the wait
+	throttle.observe(http.Header{"Retry-After": {"1"}})
+	if until := time.Until(throttle.until); until < 20*time.Second {
+		t.Errorf("held back for %v, want the longer reset kept", until)
+	}
+
+	if len(reported) != 2 || reported[0] != 80 || reported[1] != 0 {
+		t.Errorf("reported remaining %v, want [80 0]", reported)
+	}
+}
+
+func TestSMSChannelThrottlesOnRateLimitHeaders(t *testing.T) {
+	calls := 0
+	channel := newTestSMSChannel(t, config.TwilioConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		twilioResponse(201, `{"sid":"SM1","status":"queued"}`)(w, r)
+	})
+	var remaining []int
+	channel.OnRateLimit(func(n int) { remaining = append(remaining, n) })
+
+	notif := notification.Notification{ID: "n1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}
+	if report, err := channel.SendNotification(context.Background(), notif); err != nil || report.Status != notification.StatusSent {
+		t.Fatalf("SendNotification() = %+v, %v, want sent", report, err)
+	}
+
+	// The next send waits for the window to reset instead of getting a 429
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report, err := channel.SendNotification(ctx, notif)
+	if err == nil {
+		t.Fatal("SendNotification() succeeded, want it held back")
+	}
+	if report.Status != notification.StatusFailed || !report.Retryable || !strings.HasPrefix(report.ErrorMessage, "waiting for provider rate limit") {
+		t.Errorf("report = %+v, want a retryable throttled failure", report)
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+	if len(remaining) != 1 || remaining[0] != 0 {
+		t.Errorf("reported remaining %v, want [0]", remaining)
+	}
+}
+
+func TestEmailChannelThrottlesOnRetryAfter(t *testing.T) {
+	channel := newTestEmailChannel(t, config.SendGridConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	notif := notification.Notification{ID: "n1", Channel: "email", Recipient: "a@example.com", Subject: "hi", Body: "hi"}
+	if report, _ := channel.SendNotification(context.Background(), notif); report.Status != notification.StatusFailed {
+		t.Fatalf("report = %+v, want the 429 reported as failed", report)
+	}
+	if until := time.Until(channel.throttle.until); until < 20*time.Second || until > 30*time.Second {
+		t.Errorf("held back for %v, want the Retry-After period", until)
+	}
+}
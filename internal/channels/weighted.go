@@ -0,0 +1,152 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// ProviderWeight is one provider of a channel whose sends are split between
+// several providers, and its share of the sends relative to the others
+type ProviderWeight struct {
+	Provider string // channel type the provider is registered under, see Register
+	Weight   int
+}
+
+// ParseProviderWeights parses "channel=provider:weight" entries, such as
+// "email=email:70" and "email=email-ses:30", into the providers of each
+// channel, in the order they were given
+func ParseProviderWeights(entries []string) (map[string][]ProviderWeight, error) {
+	weights := make(map[string][]ProviderWeight)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		channel, value, ok := strings.Cut(entry, "=")
+		provider, weight, hasWeight := strings.Cut(value, ":")
+		channel, provider = strings.TrimSpace(channel), strings.TrimSpace(provider)
+		if !ok || !hasWeight || channel == "" || provider == "" {
+			return nil, fmt.Errorf("invalid provider weight %q, want channel=provider:weight", entry)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid provider weight %q, weight must be a non-negative integer", entry)
+		}
+		for _, existing := range weights[channel] {
+			if existing.Provider == provider {
+				return nil, fmt.Errorf("invalid provider weight %q, %s is already weighted for %s", entry, provider, channel)
+			}
+		}
+		weights[channel] = append(weights[channel], ProviderWeight{Provider: provider, Weight: w})
+	}
+
+	for channel, providers := range weights {
+		total := 0
+		for _, provider := range providers {
+			total += provider.Weight
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("invalid provider weights for %s, at least one weight must be positive", channel)
+		}
+	}
+	return weights, nil
+}
+
+// Weighted splits a channel's sends between several providers at random, in
+// proportion to their weights, to balance cost or deliverability between them.
+// Each send goes to one provider only; a failed send is not retried on another.
+// The chosen provider is recorded in the report's Provider.
+type Weighted struct {
+	channelType string
+	providers   []Channel
+	cumulative  []int // running total of the weights, in provider order
+}
+
+// NewWeighted creates a channel that splits sends between providers, which all
+// send on the same channel, according to weights, one per provider
+func NewWeighted(providers []Channel, weights []int) (*Weighted, error) {
+	if len(providers) == 0 || len(providers) != len(weights) {
+		return nil, fmt.Errorf("weighted channel needs one weight per provider")
+	}
+
+	w := &Weighted{channelType: providers[0].GetChannelType(), providers: providers}
+	total := 0
+	for i, provider := range providers {
+		if provider.GetChannelType() != w.channelType {
+			return nil, fmt.Errorf("provider %s sends %s, not %s", provider.GetProviderName(), provider.GetChannelType(), w.channelType)
+		}
+		if weights[i] < 0 {
+			return nil, fmt.Errorf("provider %s has a negative weight", provider.GetProviderName())
+		}
+		total += weights[i]
+		w.cumulative = append(w.cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("weighted channel needs at least one positive weight")
+	}
+	return w, nil
+}
+
+// Pick chooses the provider for one send
+func (w *Weighted) Pick() Channel {
+	n := rand.Intn(w.cumulative[len(w.cumulative)-1])
+	for i, limit := range w.cumulative {
+		if n < limit {
+			return w.providers[i]
+		}
+	}
+	return w.providers[len(w.providers)-1]
+}
+
+// Providers returns the providers sends are split between
+func (w *Weighted) Providers() []Channel {
+	return w.providers
+}
+
+// SendNotification sends notif through a provider chosen by weight
+func (w *Weighted) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	provider := w.Pick()
+	report, err := provider.SendNotification(ctx, notif)
+	if report != nil {
+		report.Provider = provider.GetProviderName()
+	}
+	return report, err
+}
+
+// SendBatch sends the whole batch through one provider chosen by weight
+func (w *Weighted) SendBatch(ctx context.Context, notifs []notification.Notification) ([]*notification.DeliveryReport, error) {
+	provider := w.Pick()
+	reports, err := SendBatch(ctx, provider, notifs)
+	for _, report := range reports {
+		if report != nil {
+			report.Provider = provider.GetProviderName()
+		}
+	}
+	return reports, err
+}
+
+// GetChannelType returns the channel type the providers send on
+func (w *Weighted) GetChannelType() string {
+	return w.channelType
+}
+
+// GetProviderName returns the name of the weighted channel; the provider of
+// each send is in its report
+func (w *Weighted) GetProviderName() string {
+	return "weighted"
+}
+
+// Providers returns the providers behind channel: those of a weighted channel,
+// or the channel itself
+func Providers(channel Channel) []Channel {
+	if weighted, ok := channel.(*Weighted); ok {
+		return weighted.Providers()
+	}
+	return []Channel{channel}
+}
@@ -0,0 +1,147 @@
+package channels
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// namedProvider is an email provider that records the notifications it sent
+type namedProvider struct {
+	name string
+	sent []string
+}
+
+func (p *namedProvider) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	p.sent = append(p.sent, notif.ID)
+	return &notification.DeliveryReport{NotificationID: notif.ID, Status: notification.StatusSent}, nil
+}
+
+func (p *namedProvider) GetChannelType() string  { return "email" }
+func (p *namedProvider) GetProviderName() string { return p.name }
+
+func TestParseProviderWeights(t *testing.T) {
+	weights, err := ParseProviderWeights([]string{"email=email:70", " email = email-ses : 30 ", "", "sms=sms:0", "sms=sms-vonage:1"})
+	if err != nil {
+		t.Fatalf("ParseProviderWeights() error = %v", err)
+	}
+	want := map[string][]ProviderWeight{
+		"email": {{Provider: "email", Weight: 70}, {Provider: "email-ses", Weight: 30}},
+		"sms":   {{Provider: "sms", Weight: 0}, {Provider: "sms-vonage", Weight: 1}},
+	}
+	if !reflect.DeepEqual(weights, want) {
+		t.Errorf("ParseProviderWeights() = %v, want %v", weights, want)
+	}
+}
+
+func TestParseProviderWeightsRejectsInvalidEntries(t *testing.T) {
+	tests := [][]string{
+		{"email"},
+		{"email=email"},
+		{"=email:70"},
+		{"email=:70"},
+		{"email=email:many"},
+		{"email=email:-1"},
+		{"email=email:70", "email=email:30"},
+		{"email=email:0", "email=email-ses:0"},
+	}
+	for _, entries := range tests {
+		if _, err := ParseProviderWeights(entries); err == nil {
+			t.Errorf("ParseProviderWeights(%q) error = nil, want an error", entries)
+		}
+	}
+}
+
+func TestNewWeightedRejectsInvalidProviders(t *testing.T) {
+	sendgrid := &namedProvider{name: "sendgrid"}
+	tests := []struct {
+		name      string
+		providers []Channel
+		weights   []int
+	}{
+		{"no providers", nil, nil},
+		{"missing weight", []Channel{sendgrid}, nil},
+		{"mixed channels", []Channel{sendgrid, &typedChannel{channelType: "sms"}}, []int{1, 1}},
+		{"negative weight", []Channel{sendgrid}, []int{-1}},
+		{"all zero", []Channel{sendgrid}, []int{0}},
+	}
+	for _, tt := range tests {
+		if _, err := NewWeighted(tt.providers, tt.weights); err == nil {
+			t.Errorf("%s: NewWeighted() error = nil, want an error", tt.name)
+		}
+	}
+}
+
+func TestWeightedPickFollowsWeights(t *testing.T) {
+	sendgrid, ses, unused := &namedProvider{name: "sendgrid"}, &namedProvider{name: "ses"}, &namedProvider{name: "unused"}
+	weighted, err := NewWeighted([]Channel{sendgrid, ses, unused}, []int{70, 30, 0})
+	if err != nil {
+		t.Fatalf("NewWeighted() error = %v", err)
+	}
+
+	const picks = 20000
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		counts[weighted.Pick().GetProviderName()]++
+	}
+
+	// Within two percentage points of the weights, far beyond random variation
+	for name, want := range map[string]float64{"sendgrid": 0.7, "ses": 0.3} {
+		if share := float64(counts[name]) / picks; math.Abs(share-want) > 0.02 {
+			t.Errorf("%s picked %.3f of the time, want %.2f", name, share, want)
+		}
+	}
+	if counts["unused"] != 0 {
+		t.Errorf("provider weighted 0 picked %d times", counts["unused"])
+	}
+}
+
+func TestWeightedRecordsChosenProvider(t *testing.T) {
+	sendgrid, ses := &namedProvider{name: "sendgrid"}, &namedProvider{name: "ses"}
+	weighted, err := NewWeighted([]Channel{sendgrid, ses}, []int{0, 1})
+	if err != nil {
+		t.Fatalf("NewWeighted() error = %v", err)
+	}
+	if weighted.GetChannelType() != "email" || weighted.GetProviderName() != "weighted" {
+		t.Errorf("weighted channel = %s/%s, want email/weighted", weighted.GetChannelType(), weighted.GetProviderName())
+	}
+
+	report, err := weighted.SendNotification(context.Background(), notification.Notification{ID: "n1"})
+	if err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if report.Provider != "ses" || len(ses.sent) != 1 || len(sendgrid.sent) != 0 {
+		t.Errorf("report provider = %q, ses sent %v, want n1 sent by ses", report.Provider, ses.sent)
+	}
+
+	// A batch goes to a single provider
+	reports, err := weighted.SendBatch(context.Background(), []notification.Notification{{ID: "n2"}, {ID: "n3"}})
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	for _, report := range reports {
+		if report.Provider != "ses" {
+			t.Errorf("report %s provider = %q, want ses", report.NotificationID, report.Provider)
+		}
+	}
+	if !reflect.DeepEqual(ses.sent, []string{"n1", "n2", "n3"}) {
+		t.Errorf("ses sent %v, want n1, n2 and n3", ses.sent)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	sendgrid, ses := &namedProvider{name: "sendgrid"}, &namedProvider{name: "ses"}
+	weighted, err := NewWeighted([]Channel{sendgrid, ses}, []int{1, 1})
+	if err != nil {
+		t.Fatalf("NewWeighted() error = %v", err)
+	}
+	if got := Providers(weighted); len(got) != 2 || got[0] != Channel(sendgrid) || got[1] != Channel(ses) {
+		t.Errorf("Providers(weighted) = %v, want sendgrid and ses", got)
+	}
+	if got := Providers(sendgrid); len(got) != 1 || got[0] != Channel(sendgrid) {
+		t.Errorf("Providers(sendgrid) = %v, want sendgrid", got)
+	}
+}
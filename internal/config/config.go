@@ -2,19 +2,27 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for the notification service
 type Config struct {
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
-	API      APIConfig      `mapstructure:"api"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Channels ChannelsConfig `mapstructure:"channels"`
-	Metrics  MetricsConfig  `mapstructure:"metrics"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Kafka      KafkaConfig      `mapstructure:"kafka"`
+	API        APIConfig        `mapstructure:"api"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Channels   ChannelsConfig   `mapstructure:"channels"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Log        LogConfig        `mapstructure:"log"`
+	Worker     WorkerConfig     `mapstructure:"worker"`
+	Delivery   DeliveryConfig   `mapstructure:"delivery"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+	Callbacks  CallbacksConfig  `mapstructure:"callbacks"`
+	Dedup      DedupConfig      `mapstructure:"dedup"`
+	Scheduling SchedulingConfig `mapstructure:"scheduling"`
 }
 
 // DatabaseConfig holds PostgreSQL configuration
@@ -29,27 +37,64 @@ type DatabaseConfig struct {
 
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
-	Addr     string `mapstructure:"addr"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
+	Mode             string        `mapstructure:"mode"`        // single, sentinel or cluster
+	Addr             string        `mapstructure:"addr"`        // server address in single mode
+	Addrs            []string      `mapstructure:"addrs"`       // sentinel addresses, or cluster node addresses (defaults to addr)
+	MasterName       string        `mapstructure:"master_name"` // master monitored by the sentinels
+	Password         string        `mapstructure:"password"`
+	SentinelPassword string        `mapstructure:"sentinel_password"` // password of the sentinels, if different
+	DB               int           `mapstructure:"db"`                // must be 0 in cluster mode
+	NotificationTTL  time.Duration `mapstructure:"notification_ttl"`  // how long notifications are cached for GET; 0 disables the cache
+	TemplateTTL      time.Duration `mapstructure:"template_ttl"`      // how long resolved templates are cached; 0 disables the cache
+	PreferenceTTL    time.Duration `mapstructure:"preference_ttl"`    // how long user preference sets are cached; 0 disables the cache
+	WarmPreferences  int           `mapstructure:"warm_preferences"`  // most active users whose preferences are cached at startup; 0 disables warming
+	WarmWindow       time.Duration `mapstructure:"warm_window"`       // how far back activity is counted when picking users to warm
 }
 
 // KafkaConfig holds Kafka configuration
 type KafkaConfig struct {
-	Brokers []string `mapstructure:"brokers"`
-	Topic   string   `mapstructure:"topic"`
+	Brokers                []string      `mapstructure:"brokers"`
+	Topic                  string        `mapstructure:"topic"`                    // base topic; messages are published to <topic>.<channel>
+	DrainLegacyTopic       bool          `mapstructure:"drain_legacy_topic"`       // also consume the shared base topic during migration
+	RequiredAcks           string        `mapstructure:"required_acks"`            // none, one or all
+	MaxAttempts            int           `mapstructure:"max_attempts"`             // producer write attempts before giving up
+	PartitionKey           string        `mapstructure:"partition_key"`            // user_id (per-user ordering) or id
+	TombstoneOnCancel      bool          `mapstructure:"tombstone_on_cancel"`      // publish a tombstone keyed by ID when a notification is cancelled; requires partition_key id
+	PriorityBuffer         int           `mapstructure:"priority_buffer"`          // messages buffered to handle high priority first; 0 handles in topic order
+	CreateTopics           bool          `mapstructure:"create_topics"`            // create missing topics at startup instead of failing
+	TopicPartitions        int           `mapstructure:"topic_partitions"`         // partitions for topics created at startup
+	TopicReplicationFactor int           `mapstructure:"topic_replication_factor"` // replication factor for topics created at startup
+	MessageTTL             time.Duration `mapstructure:"message_ttl"`              // retention for topics created at startup; 0 keeps the broker default
+	BufferDir              string        `mapstructure:"buffer_dir"`               // directory for messages that could not be published; empty disables buffering
+	BufferMaxMessages      int           `mapstructure:"buffer_max_messages"`      // buffered messages before publishes fail again
+	BufferFlushInterval    time.Duration `mapstructure:"buffer_flush_interval"`    // how often buffered messages are retried
+	BatchSize              int           `mapstructure:"batch_size"`               // messages handed to batch-capable channels at once; 1 disables batching
+	BatchWait              time.Duration `mapstructure:"batch_wait"`               // how long to wait for a batch to fill after its first message
+	Consumers              int           `mapstructure:"consumers"`                // consumer group members per channel in each worker process
+	ChannelConsumers       []string      `mapstructure:"channel_consumers"`        // "channel=count" entries overriding consumers for a channel
+	ProducerBatchSize      int           `mapstructure:"producer_batch_size"`      // messages per partition the producer collects before writing
+	ProducerBatchTimeout   time.Duration `mapstructure:"producer_batch_timeout"`   // longest the producer waits for a batch to fill
+	ProducerAsync          bool          `mapstructure:"producer_async"`           // don't wait for writes to be acknowledged; failures are only logged or buffered
+	StartOffset            string        `mapstructure:"start_offset"`             // earliest or latest: where a consumer group without committed offsets starts reading
 }
 
 // APIConfig holds API server configuration
 type APIConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
-	GRPCPort int `mapstructure:"grpc_port"`
+	Host            string        `mapstructure:"host"`
+	Port            int           `mapstructure:"port"`
+	GRPCPort        int           `mapstructure:"grpc_port"`
+	PublicURL       string        `mapstructure:"public_url"`        // externally visible base URL, used to verify provider webhook signatures
+	IDGenerator     string        `mapstructure:"id_generator"`      // uuidv4 (random) or uuidv7 (time-ordered) notification IDs
+	TestSendEnabled bool          `mapstructure:"test_send_enabled"` // serve POST /api/v1/notifications/test, sending directly through the channels
+	SyncSendEnabled bool          `mapstructure:"sync_send_enabled"` // accept "sync": true on POST /api/v1/notifications, sending before responding
+	RequestTimeout  time.Duration `mapstructure:"request_timeout"`   // deadline of each REST request; 0 disables it
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecret string `mapstructure:"jwt_secret"`
+	JWTSecret     string `mapstructure:"jwt_secret"`      // HS256 secret for bearer JWTs accepted in place of an API key; JWTs are rejected when empty
+	AdminToken    string `mapstructure:"admin_token"`     // bearer token for /api/v1/admin routes; admin routes are disabled when empty
+	RequireAPIKey bool   `mapstructure:"require_api_key"` // reject /api/v1 requests without a valid X-API-Key; on by default
 }
 
 // ChannelsConfig holds third-party provider configurations
@@ -57,22 +102,111 @@ type ChannelsConfig struct {
 	SendGrid SendGridConfig `mapstructure:"sendgrid"`
 	Twilio   TwilioConfig   `mapstructure:"twilio"`
 	Firebase FirebaseConfig `mapstructure:"firebase"`
+	// RetryOverrides reclassify provider error codes as retryable or not, as
+	// "provider:code=true|false" entries, ahead of the built-in rules
+	RetryOverrides []string `mapstructure:"retry_overrides"`
+	// Shadow mirrors a percentage of a channel's sends to a candidate
+	// provider for comparison, as "channel=candidate:percent" entries
+	Shadow []string `mapstructure:"shadow"`
+	// Weights split a channel's sends between several providers, as
+	// "channel=provider:weight" entries
+	Weights []string `mapstructure:"weights"`
+	// InlineTemplates renders subjects and bodies written with Go template
+	// syntax using the request's variables when no stored template is used
+	InlineTemplates bool `mapstructure:"inline_templates"`
+}
+
+// IsEnabled reports whether the given channel is enabled globally
+func (c ChannelsConfig) IsEnabled(channel string) bool {
+	switch channel {
+	case "email":
+		return c.SendGrid.Enabled
+	case "sms":
+		return c.Twilio.Enabled
+	case "push":
+		return c.Firebase.Enabled
+	default:
+		return false
+	}
+}
+
+// Limits returns the subject and body limits of the given channel
+func (c ChannelsConfig) Limits(channel string) ContentLimits {
+	switch channel {
+	case "email":
+		return c.SendGrid.Limits
+	case "sms":
+		return c.Twilio.Limits
+	case "push":
+		return c.Firebase.Limits
+	default:
+		return ContentLimits{}
+	}
+}
+
+// ContentLimits holds a channel's subject rule and subject and body size limits
+type ContentLimits struct {
+	MaxSubject int    `mapstructure:"max_subject"` // characters; 0 means no limit
+	MaxBody    int    `mapstructure:"max_body"`    // characters; 0 means no limit
+	Overflow   string `mapstructure:"overflow"`    // "reject" or "truncate" oversized content
+	Subject    string `mapstructure:"subject"`     // "required", "optional" or "ignored"
 }
 
 // SendGridConfig holds SendGrid email configuration
 type SendGridConfig struct {
-	APIKey string `mapstructure:"api_key"`
+	Enabled          bool             `mapstructure:"enabled"`
+	APIKey           string           `mapstructure:"api_key"`
+	BaseURL          string           `mapstructure:"base_url"` // API host, e.g. https://api.eu.sendgrid.com or a local mock
+	Attachments      AttachmentConfig `mapstructure:"attachments"`
+	WebhookPublicKey string           `mapstructure:"webhook_public_key"` // base64 ECDSA key for signed event webhooks
+	Limits           ContentLimits    `mapstructure:"limits"`
+	Tracking         TrackingConfig   `mapstructure:"tracking"`
+}
+
+// TrackingConfig holds SendGrid engagement tracking defaults. Tracking is off
+// by default since most mail sent here is transactional; metadata can turn it
+// on per notification.
+type TrackingConfig struct {
+	ClickTracking bool     `mapstructure:"click_tracking"`
+	OpenTracking  bool     `mapstructure:"open_tracking"`
+	Categories    []string `mapstructure:"categories"` // added to every email for SendGrid analytics
+}
+
+// AttachmentConfig holds email attachment validation limits
+type AttachmentConfig struct {
+	AllowedTypes []string      `mapstructure:"allowed_types"`  // allowed MIME types
+	MaxFileSize  int64         `mapstructure:"max_file_size"`  // bytes per decoded file
+	MaxTotalSize int64         `mapstructure:"max_total_size"` // bytes across all files of one notification
+	URLHosts     []string      `mapstructure:"url_hosts"`      // hosts, or .domain suffixes, attachments may be fetched from; empty disables attachments by URL
+	FetchTimeout time.Duration `mapstructure:"fetch_timeout"`  // timeout of fetching one attachment by URL
 }
 
 // TwilioConfig holds Twilio SMS configuration
 type TwilioConfig struct {
-	AccountSID string `mapstructure:"account_sid"`
-	AuthToken  string `mapstructure:"auth_token"`
+	Enabled       bool             `mapstructure:"enabled"`
+	AccountSID    string           `mapstructure:"account_sid"`
+	AuthToken     string           `mapstructure:"auth_token"`
+	BaseURL       string           `mapstructure:"base_url"`       // API host, e.g. a regional edge or a local mock
+	FromNumbers   []string         `mapstructure:"from_numbers"`   // pool of sender numbers
+	FromSelection string           `mapstructure:"from_selection"` // "round_robin" or "hashed" (sticky per recipient)
+	HTTP          HTTPClientConfig `mapstructure:"http"`
+	Limits        ContentLimits    `mapstructure:"limits"`
+}
+
+// HTTPClientConfig holds timeouts and connection pooling for provider HTTP clients
+type HTTPClientConfig struct {
+	Timeout             time.Duration `mapstructure:"timeout"`                 // overall per-request timeout
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"` // idle connections kept per provider host
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`       // how long an idle connection is kept
 }
 
 // FirebaseConfig holds Firebase push notification configuration
 type FirebaseConfig struct {
-	CredentialsPath string `mapstructure:"credentials_path"`
+	Enabled         bool          `mapstructure:"enabled"`
+	CredentialsPath string        `mapstructure:"credentials_path"`
+	SendTimeout     time.Duration `mapstructure:"send_timeout"` // timeout of each FCM call; 0 waits as long as the send's context
+	MaxAttempts     int           `mapstructure:"max_attempts"` // attempts of a send failing with transient FCM errors
+	Limits          ContentLimits `mapstructure:"limits"`
 }
 
 // MetricsConfig holds monitoring configuration
@@ -82,6 +216,47 @@ type MetricsConfig struct {
 	Path    string `mapstructure:"path"`
 }
 
+// LogConfig holds logger configuration
+type LogConfig struct {
+	Level    string `mapstructure:"level"`    // debug, info, warn or error
+	Encoding string `mapstructure:"encoding"` // json or console
+	Sampling bool   `mapstructure:"sampling"` // sample repeated log entries under load
+}
+
+// WorkerConfig holds configuration for the multi-channel worker
+type WorkerConfig struct {
+	Channels []string `mapstructure:"channels"` // channel types to run; empty runs every enabled channel
+}
+
+// DeliveryConfig holds the delivery SLA of sent notifications
+type DeliveryConfig struct {
+	SLA         time.Duration `mapstructure:"sla"`          // how long a sent notification may wait for delivery confirmation; 0 disables the sweeper
+	StalePolicy string        `mapstructure:"stale_policy"` // flag, delivered or unknown: what happens to notifications past the SLA
+}
+
+// EncryptionConfig holds the keys notification bodies and recipients are encrypted with at rest
+type EncryptionConfig struct {
+	Keys       []string `mapstructure:"keys"`        // "version:base64 key" entries of 32-byte AES keys; empty stores plaintext
+	KeyVersion int      `mapstructure:"key_version"` // version new rows are encrypted with; 0 uses the highest configured
+}
+
+// DedupConfig holds where dedup state, such as workers' delivery claims, is kept
+type DedupConfig struct {
+	Store string `mapstructure:"store"` // redis or postgres
+}
+
+// SchedulingConfig holds limits on scheduled notifications
+type SchedulingConfig struct {
+	MaxHorizon time.Duration `mapstructure:"max_horizon"` // how far ahead scheduled_at may be; 0 means no limit
+}
+
+// CallbacksConfig holds the outbound status callbacks POSTed to customer URLs
+type CallbacksConfig struct {
+	SigningSecret string        `mapstructure:"signing_secret"` // HMAC key callbacks are signed with; callback URLs are rejected when empty
+	Timeout       time.Duration `mapstructure:"timeout"`        // per-request timeout of a callback
+	MaxAttempts   int           `mapstructure:"max_attempts"`   // attempts before a callback is dead-lettered
+}
+
 // LoadConfig loads configuration from environment variables and config files
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
@@ -121,36 +296,221 @@ func setDefaults() {
 	viper.SetDefault("database.ssl_mode", "disable")
 
 	// Redis defaults
+	viper.SetDefault("redis.mode", "single")
 	viper.SetDefault("redis.addr", "localhost:6379")
+	viper.SetDefault("redis.addrs", []string{})
+	viper.SetDefault("redis.master_name", "")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
+	viper.SetDefault("redis.notification_ttl", 30*time.Second)
+	viper.SetDefault("redis.template_ttl", 24*time.Hour)
+	viper.SetDefault("redis.preference_ttl", time.Hour)
+	viper.SetDefault("redis.warm_preferences", 0)
+	viper.SetDefault("redis.warm_window", 7*24*time.Hour)
+
+	// Dedup defaults
+	viper.SetDefault("dedup.store", "redis")
 
 	// Kafka defaults
 	viper.SetDefault("kafka.brokers", []string{"localhost:9092"})
 	viper.SetDefault("kafka.topic", "notifications")
+	viper.SetDefault("kafka.drain_legacy_topic", true)
+	viper.SetDefault("kafka.required_acks", "all")
+	viper.SetDefault("kafka.start_offset", "latest")
+	viper.SetDefault("kafka.max_attempts", 10)
+	viper.SetDefault("kafka.partition_key", "user_id")
+	viper.SetDefault("kafka.tombstone_on_cancel", false)
+	viper.SetDefault("kafka.priority_buffer", 0)
+	viper.SetDefault("kafka.create_topics", false)
+	viper.SetDefault("kafka.topic_partitions", 6)
+	viper.SetDefault("kafka.topic_replication_factor", 1)
+	viper.SetDefault("kafka.message_ttl", time.Duration(0))
+	viper.SetDefault("kafka.buffer_dir", "")
+	viper.SetDefault("kafka.buffer_max_messages", 10000)
+	viper.SetDefault("kafka.buffer_flush_interval", 5*time.Second)
+	viper.SetDefault("kafka.batch_size", 1)
+	viper.SetDefault("kafka.batch_wait", 50*time.Millisecond)
+	viper.SetDefault("kafka.consumers", 1)
+	viper.SetDefault("kafka.channel_consumers", []string{})
+	viper.SetDefault("kafka.producer_batch_size", 100)
+	viper.SetDefault("kafka.producer_batch_timeout", 10*time.Millisecond)
+	viper.SetDefault("kafka.producer_async", false)
 
 	// API defaults
 	viper.SetDefault("api.host", "0.0.0.0")
 	viper.SetDefault("api.port", 8080)
 	viper.SetDefault("api.grpc_port", 9090)
+	viper.SetDefault("api.id_generator", "uuidv4")
+	viper.SetDefault("api.test_send_enabled", false)
+	viper.SetDefault("api.sync_send_enabled", false)
+	viper.SetDefault("api.request_timeout", 10*time.Second)
+
+	// Auth defaults
+	viper.SetDefault("auth.require_api_key", true)
+
+	// Channel defaults
+	viper.SetDefault("channels.sendgrid.enabled", true)
+	viper.SetDefault("channels.twilio.enabled", true)
+	viper.SetDefault("channels.firebase.enabled", true)
+	viper.SetDefault("channels.sendgrid.base_url", "https://api.sendgrid.com")
+	viper.SetDefault("channels.twilio.base_url", "https://api.twilio.com")
+	viper.SetDefault("channels.twilio.from_numbers", []string{"+1234567890"})
+	viper.SetDefault("channels.twilio.from_selection", "round_robin")
+	viper.SetDefault("channels.twilio.http.timeout", 10*time.Second)
+	viper.SetDefault("channels.twilio.http.max_idle_conns_per_host", 20)
+	viper.SetDefault("channels.twilio.http.idle_conn_timeout", 90*time.Second)
+	viper.SetDefault("channels.sendgrid.attachments.allowed_types", []string{
+		"application/pdf", "image/png", "image/jpeg", "image/gif", "text/plain", "text/csv",
+	})
+	viper.SetDefault("channels.sendgrid.attachments.max_file_size", 10<<20)
+	viper.SetDefault("channels.sendgrid.attachments.max_total_size", 20<<20)
+	viper.SetDefault("channels.sendgrid.attachments.url_hosts", []string{})
+	viper.SetDefault("channels.sendgrid.attachments.fetch_timeout", 30*time.Second)
+	viper.SetDefault("channels.sendgrid.limits.max_subject", 998)
+	viper.SetDefault("channels.sendgrid.limits.max_body", 1<<20)
+	viper.SetDefault("channels.sendgrid.limits.overflow", "reject")
+	viper.SetDefault("channels.sendgrid.limits.subject", "required")
+	viper.SetDefault("channels.sendgrid.tracking.click_tracking", false)
+	viper.SetDefault("channels.sendgrid.tracking.open_tracking", false)
+	viper.SetDefault("channels.sendgrid.tracking.categories", []string{})
+	viper.SetDefault("channels.twilio.limits.max_subject", 0)
+	viper.SetDefault("channels.twilio.limits.max_body", 1600)
+	viper.SetDefault("channels.twilio.limits.overflow", "reject")
+	viper.SetDefault("channels.twilio.limits.subject", "ignored")
+	viper.SetDefault("channels.firebase.send_timeout", 10*time.Second)
+	viper.SetDefault("channels.firebase.max_attempts", 3)
+	viper.SetDefault("channels.firebase.limits.max_subject", 256)
+	viper.SetDefault("channels.firebase.limits.max_body", 2048)
+	viper.SetDefault("channels.firebase.limits.overflow", "reject")
+	viper.SetDefault("channels.firebase.limits.subject", "required")
+	viper.SetDefault("channels.retry_overrides", []string{})
+	viper.SetDefault("channels.shadow", []string{})
+	viper.SetDefault("channels.weights", []string{})
+	viper.SetDefault("channels.inline_templates", false)
 
 	// Metrics defaults
 	viper.SetDefault("metrics.enabled", true)
 	viper.SetDefault("metrics.port", 9091)
 	viper.SetDefault("metrics.path", "/metrics")
 
+	// Log defaults
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.encoding", "json")
+	viper.SetDefault("log.sampling", true)
+
+	// Worker defaults
+	viper.SetDefault("worker.channels", []string{})
+
+	// Delivery defaults
+	viper.SetDefault("delivery.sla", time.Hour)
+	viper.SetDefault("delivery.stale_policy", "flag")
+
+	// Scheduling defaults
+	viper.SetDefault("scheduling.max_horizon", 90*24*time.Hour)
+
+	// Encryption defaults
+	viper.SetDefault("encryption.keys", []string{})
+	viper.SetDefault("encryption.key_version", 0)
+
+	// Status callback defaults
+	viper.SetDefault("callbacks.timeout", 10*time.Second)
+	viper.SetDefault("callbacks.max_attempts", 8)
+
 	// Map environment variables
+	viper.BindEnv("log.level", "LOG_LEVEL")
+	viper.BindEnv("log.encoding", "LOG_ENCODING")
+	viper.BindEnv("log.sampling", "LOG_SAMPLING")
+	viper.BindEnv("worker.channels", "WORKER_CHANNELS")
+	viper.BindEnv("delivery.sla", "DELIVERY_SLA")
+	viper.BindEnv("delivery.stale_policy", "DELIVERY_STALE_POLICY")
+	viper.BindEnv("scheduling.max_horizon", "SCHEDULE_MAX_HORIZON")
+	viper.BindEnv("encryption.keys", "ENCRYPTION_KEYS")
+	viper.BindEnv("encryption.key_version", "ENCRYPTION_KEY_VERSION")
+	viper.BindEnv("callbacks.signing_secret", "CALLBACK_SIGNING_SECRET")
+	viper.BindEnv("callbacks.timeout", "CALLBACK_TIMEOUT")
+	viper.BindEnv("callbacks.max_attempts", "CALLBACK_MAX_ATTEMPTS")
 	viper.BindEnv("database.host", "DB_HOST")
 	viper.BindEnv("database.port", "DB_PORT")
 	viper.BindEnv("database.user", "DB_USER")
 	viper.BindEnv("database.password", "DB_PASSWORD")
 	viper.BindEnv("database.database", "DB_NAME")
+	viper.BindEnv("redis.mode", "REDIS_MODE")
 	viper.BindEnv("redis.addr", "REDIS_ADDR")
+	viper.BindEnv("redis.addrs", "REDIS_ADDRS")
+	viper.BindEnv("redis.master_name", "REDIS_MASTER_NAME")
 	viper.BindEnv("redis.password", "REDIS_PASSWORD")
+	viper.BindEnv("redis.sentinel_password", "REDIS_SENTINEL_PASSWORD")
+	viper.BindEnv("redis.notification_ttl", "REDIS_NOTIFICATION_TTL")
+	viper.BindEnv("redis.template_ttl", "REDIS_TEMPLATE_TTL")
+	viper.BindEnv("redis.preference_ttl", "REDIS_PREFERENCE_TTL")
+	viper.BindEnv("redis.warm_preferences", "REDIS_WARM_PREFERENCES")
+	viper.BindEnv("redis.warm_window", "REDIS_WARM_WINDOW")
+	viper.BindEnv("dedup.store", "DEDUP_STORE")
 	viper.BindEnv("kafka.brokers", "KAFKA_BROKERS")
+	viper.BindEnv("kafka.drain_legacy_topic", "KAFKA_DRAIN_LEGACY_TOPIC")
+	viper.BindEnv("kafka.required_acks", "KAFKA_REQUIRED_ACKS")
+	viper.BindEnv("kafka.start_offset", "KAFKA_START_OFFSET")
+	viper.BindEnv("kafka.max_attempts", "KAFKA_MAX_ATTEMPTS")
+	viper.BindEnv("kafka.partition_key", "KAFKA_PARTITION_KEY")
+	viper.BindEnv("kafka.tombstone_on_cancel", "KAFKA_TOMBSTONE_ON_CANCEL")
+	viper.BindEnv("kafka.priority_buffer", "KAFKA_PRIORITY_BUFFER")
+	viper.BindEnv("kafka.create_topics", "KAFKA_CREATE_TOPICS")
+	viper.BindEnv("kafka.topic_partitions", "KAFKA_TOPIC_PARTITIONS")
+	viper.BindEnv("kafka.topic_replication_factor", "KAFKA_TOPIC_REPLICATION_FACTOR")
+	viper.BindEnv("kafka.message_ttl", "KAFKA_MESSAGE_TTL")
+	viper.BindEnv("kafka.buffer_dir", "KAFKA_BUFFER_DIR")
+	viper.BindEnv("kafka.buffer_max_messages", "KAFKA_BUFFER_MAX_MESSAGES")
+	viper.BindEnv("kafka.buffer_flush_interval", "KAFKA_BUFFER_FLUSH_INTERVAL")
+	viper.BindEnv("kafka.batch_size", "KAFKA_BATCH_SIZE")
+	viper.BindEnv("kafka.batch_wait", "KAFKA_BATCH_WAIT")
+	viper.BindEnv("kafka.consumers", "KAFKA_CONSUMERS")
+	viper.BindEnv("kafka.channel_consumers", "KAFKA_CHANNEL_CONSUMERS")
+	viper.BindEnv("kafka.producer_batch_size", "KAFKA_PRODUCER_BATCH_SIZE")
+	viper.BindEnv("kafka.producer_batch_timeout", "KAFKA_PRODUCER_BATCH_TIMEOUT")
+	viper.BindEnv("kafka.producer_async", "KAFKA_PRODUCER_ASYNC")
+	viper.BindEnv("api.public_url", "API_PUBLIC_URL")
+	viper.BindEnv("api.id_generator", "ID_GENERATOR")
+	viper.BindEnv("api.test_send_enabled", "API_TEST_SEND_ENABLED")
+	viper.BindEnv("api.sync_send_enabled", "API_SYNC_SEND_ENABLED")
+	viper.BindEnv("api.request_timeout", "API_REQUEST_TIMEOUT")
 	viper.BindEnv("auth.jwt_secret", "JWT_SECRET")
+	viper.BindEnv("auth.require_api_key", "REQUIRE_API_KEY")
+	viper.BindEnv("auth.admin_token", "ADMIN_API_TOKEN")
+	viper.BindEnv("channels.sendgrid.enabled", "SENDGRID_ENABLED")
 	viper.BindEnv("channels.sendgrid.api_key", "SENDGRID_API_KEY")
+	viper.BindEnv("channels.sendgrid.base_url", "SENDGRID_BASE_URL")
+	viper.BindEnv("channels.sendgrid.webhook_public_key", "SENDGRID_WEBHOOK_PUBLIC_KEY")
+	viper.BindEnv("channels.twilio.enabled", "TWILIO_ENABLED")
 	viper.BindEnv("channels.twilio.account_sid", "TWILIO_ACCOUNT_SID")
 	viper.BindEnv("channels.twilio.auth_token", "TWILIO_AUTH_TOKEN")
+	viper.BindEnv("channels.twilio.base_url", "TWILIO_BASE_URL")
+	viper.BindEnv("channels.twilio.from_numbers", "TWILIO_FROM_NUMBERS")
+	viper.BindEnv("channels.twilio.from_selection", "TWILIO_FROM_SELECTION")
+	viper.BindEnv("channels.twilio.http.timeout", "TWILIO_HTTP_TIMEOUT")
+	viper.BindEnv("channels.twilio.http.max_idle_conns_per_host", "TWILIO_HTTP_MAX_IDLE_CONNS_PER_HOST")
+	viper.BindEnv("channels.twilio.http.idle_conn_timeout", "TWILIO_HTTP_IDLE_CONN_TIMEOUT")
+	viper.BindEnv("channels.firebase.enabled", "FIREBASE_ENABLED")
 	viper.BindEnv("channels.firebase.credentials_path", "FIREBASE_CREDENTIALS_PATH")
-}
\ No newline at end of file
+	viper.BindEnv("channels.firebase.send_timeout", "FIREBASE_SEND_TIMEOUT")
+	viper.BindEnv("channels.firebase.max_attempts", "FIREBASE_MAX_ATTEMPTS")
+	viper.BindEnv("channels.sendgrid.limits.max_subject", "SENDGRID_MAX_SUBJECT")
+	viper.BindEnv("channels.sendgrid.limits.max_body", "SENDGRID_MAX_BODY")
+	viper.BindEnv("channels.sendgrid.limits.overflow", "SENDGRID_OVERFLOW")
+	viper.BindEnv("channels.sendgrid.limits.subject", "SENDGRID_SUBJECT_RULE")
+	viper.BindEnv("channels.sendgrid.tracking.click_tracking", "SENDGRID_CLICK_TRACKING")
+	viper.BindEnv("channels.sendgrid.tracking.open_tracking", "SENDGRID_OPEN_TRACKING")
+	viper.BindEnv("channels.sendgrid.tracking.categories", "SENDGRID_CATEGORIES")
+	viper.BindEnv("channels.sendgrid.attachments.url_hosts", "SENDGRID_ATTACHMENT_URL_HOSTS")
+	viper.BindEnv("channels.sendgrid.attachments.fetch_timeout", "SENDGRID_ATTACHMENT_FETCH_TIMEOUT")
+	viper.BindEnv("channels.twilio.limits.max_body", "TWILIO_MAX_BODY")
+	viper.BindEnv("channels.twilio.limits.overflow", "TWILIO_OVERFLOW")
+	viper.BindEnv("channels.twilio.limits.subject", "TWILIO_SUBJECT_RULE")
+	viper.BindEnv("channels.firebase.limits.max_subject", "FIREBASE_MAX_SUBJECT")
+	viper.BindEnv("channels.firebase.limits.max_body", "FIREBASE_MAX_BODY")
+	viper.BindEnv("channels.firebase.limits.overflow", "FIREBASE_OVERFLOW")
+	viper.BindEnv("channels.firebase.limits.subject", "FIREBASE_SUBJECT_RULE")
+	viper.BindEnv("channels.retry_overrides", "RETRY_OVERRIDES")
+	viper.BindEnv("channels.shadow", "SHADOW_SENDS")
+	viper.BindEnv("channels.weights", "PROVIDER_WEIGHTS")
+	viper.BindEnv("channels.inline_templates", "INLINE_TEMPLATES")
+}
@@ -0,0 +1,397 @@
+// Package dbtest provides a scripted database/sql driver for tests of code
+// that queries PostgreSQL through database.PostgresDB. Tests list the
+// statements they expect, in order, with the rows or results to return.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database"
+)
+
+// Expectation kinds
+const (
+	kindQuery    = "query"
+	kindExec     = "exec"
+	kindBegin    = "begin"
+	kindCommit   = "commit"
+	kindRollback = "rollback"
+)
+
+// Mock holds the statements a test expects, in the order it expects them
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	next         int
+	t            testing.TB
+}
+
+// Expectation is one expected statement and what it returns
+type Expectation struct {
+	kind     string
+	contains string // collapsed SQL the statement must contain
+	args     []any  // nil to accept any arguments
+	rows     *Rows
+	result   int64 // rows affected
+	err      error
+	delay    time.Duration
+}
+
+// anyArg matches every argument
+type anyArg struct{}
+
+// AnyArg returns an argument that matches any value, for arguments such as
+// timestamps and generated IDs that a test cannot know
+func AnyArg() any {
+	return anyArg{}
+}
+
+var (
+	driverOnce sync.Once
+	mocks      sync.Map // DSN to *Mock
+	mockCount  atomic.Int64
+)
+
+// New returns a database backed by a new mock. Statements left unmet when the
+// test ends fail it.
+func New(t testing.TB) (*database.PostgresDB, *Mock) {
+	t.Helper()
+	driverOnce.Do(func() { sql.Register("dbtest", mockDriver{}) })
+
+	mock := &Mock{t: t}
+	dsn := fmt.Sprintf("mock-%d", mockCount.Add(1))
+	mocks.Store(dsn, mock)
+
+	db, err := sql.Open("dbtest", dsn)
+	if err != nil {
+		t.Fatalf("failed to open mock database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		mocks.Delete(dsn)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return &database.PostgresDB{DB: db}, mock
+}
+
+// ExpectQuery expects a query whose SQL contains sql, ignoring whitespace
+// differences
+func (m *Mock) ExpectQuery(sql string) *Expectation {
+	return m.expect(kindQuery, sql)
+}
+
+// ExpectExec expects a statement whose SQL contains sql, ignoring whitespace
+// differences
+func (m *Mock) ExpectExec(sql string) *Expectation {
+	return m.expect(kindExec, sql)
+}
+
+// ExpectBegin expects a transaction to begin
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(kindBegin, "")
+}
+
+// ExpectCommit expects the transaction to commit
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(kindCommit, "")
+}
+
+// ExpectRollback expects the transaction to roll back
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(kindRollback, "")
+}
+
+func (m *Mock) expect(kind, sql string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{kind: kind, contains: collapse(sql)}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// ExpectationsWereMet returns an error naming the first expected statement
+// that was not executed
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.next < len(m.expectations) {
+		e := m.expectations[m.next]
+		return fmt.Errorf("dbtest: expected %s %q was not executed", e.kind, e.contains)
+	}
+	return nil
+}
+
+// WithArgs sets the arguments the statement must be executed with
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	return e
+}
+
+// WillReturnRows sets the rows a query returns
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult sets the number of rows a statement affects
+func (e *Expectation) WillReturnResult(rowsAffected int64) *Expectation {
+	e.result = rowsAffected
+	return e
+}
+
+// WillReturnError makes the statement fail with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelayFor makes the statement take d, or fail with the context's error
+// when the context is done first, like a slow query that gets cancelled
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// wait blocks for the expectation's delay, returning early with ctx's error
+func (e *Expectation) wait(ctx context.Context) error {
+	if e.delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(e.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// match consumes the next expectation, failing when it is not a kind
+// statement with sql and args
+func (m *Mock) match(kind, sql string, args []driver.NamedValue) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sql = collapse(sql)
+	if m.next >= len(m.expectations) {
+		return nil, m.fail("dbtest: unexpected %s %q", kind, sql)
+	}
+	e := m.expectations[m.next]
+	if e.kind != kind || !strings.Contains(sql, e.contains) {
+		return nil, m.fail("dbtest: got %s %q, want %s containing %q", kind, sql, e.kind, e.contains)
+	}
+	if e.args != nil {
+		if err := matchArgs(e.args, args); err != nil {
+			return nil, m.fail("dbtest: %s %q: %v", kind, sql, err)
+		}
+	}
+	m.next++
+	return e, e.err
+}
+
+// fail reports a statement the test did not expect, returning it as the
+// statement's error so the code under test stops too
+func (m *Mock) fail(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	m.t.Error(err)
+	return err
+}
+
+// matchArgs compares expected arguments, converted as database/sql converts
+// them, with the executed ones
+func matchArgs(want []any, got []driver.NamedValue) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("got %d arguments, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if _, ok := w.(anyArg); ok {
+			continue
+		}
+		converted, err := driver.DefaultParameterConverter.ConvertValue(w)
+		if err != nil {
+			return fmt.Errorf("argument $%d: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(converted, got[i].Value) {
+			return fmt.Errorf("argument $%d = %#v, want %#v", i+1, got[i].Value, converted)
+		}
+	}
+	return nil
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// collapse trims sql and collapses its whitespace to single spaces
+func collapse(sql string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(sql, " "))
+}
+
+// Rows are the rows a query returns
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows returns an empty result with columns
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row of values, one per column
+func (r *Rows) AddRow(values ...any) *Rows {
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		converted, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("dbtest: column %d: %v", i, err))
+		}
+		row[i] = converted
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// mockDriver opens connections to the mock registered under the DSN
+type mockDriver struct{}
+
+func (mockDriver) Open(dsn string) (driver.Conn, error) {
+	mock, ok := mocks.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("dbtest: unknown mock %q", dsn)
+	}
+	return &conn{mock: mock.(*Mock)}, nil
+}
+
+type conn struct {
+	mock *Mock
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.mock.match(kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return &tx{mock: c.mock}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.mock.match(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.wait(ctx); err != nil {
+		return nil, err
+	}
+	if e.rows == nil {
+		return &rows{}, nil
+	}
+	return &rows{columns: e.rows.columns, values: e.rows.values}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.mock.match(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.wait(ctx); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(e.result), nil
+}
+
+// stmt is only used by code that prepares statements explicitly
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return values
+}
+
+type tx struct {
+	mock *Mock
+}
+
+func (t *tx) Commit() error {
+	_, err := t.mock.match(kindCommit, "", nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.mock.match(kindRollback, "", nil)
+	return err
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Dedup store backends
+const (
+	DedupStoreRedis    = "redis"
+	DedupStorePostgres = "postgres"
+)
+
+// DedupStore holds short-lived claims on keys, such as the claim a worker takes
+// on a notification while delivering it, so the same work is not done twice
+type DedupStore interface {
+	// Claim takes the claim on key for ttl, reporting false when it is
+	// already held
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release drops the claim on key so it can be taken again
+	Release(ctx context.Context, key string) error
+	// PurgeExpired deletes expired claims the backend does not expire by
+	// itself and returns the number deleted
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+// NewDedupStore returns the dedup store of backend, redis or postgres. It
+// returns nil without an error when backend is redis and redis is nil.
+func NewDedupStore(backend string, db *PostgresDB, redis *RedisClient) (DedupStore, error) {
+	switch backend {
+	case "", DedupStoreRedis:
+		if redis == nil {
+			return nil, nil
+		}
+		return &redisDedupStore{client: redis}, nil
+	case DedupStorePostgres:
+		if db == nil {
+			return nil, fmt.Errorf("postgres dedup store requires a database")
+		}
+		return &postgresDedupStore{db: db}, nil
+	default:
+		return nil, fmt.Errorf("unknown dedup store %q, must be redis or postgres", backend)
+	}
+}
+
+// redisDedupStore keeps claims as Redis keys that expire with their ttl
+type redisDedupStore struct {
+	client *RedisClient
+}
+
+func (s *redisDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, "1", ttl).Result()
+}
+
+func (s *redisDedupStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s *redisDedupStore) PurgeExpired(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// postgresDedupStore keeps claims as rows of dedup_claims. Expiry is computed
+// by the database, so workers with skewed clocks agree on it.
+type postgresDedupStore struct {
+	db *PostgresDB
+}
+
+func (s *postgresDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	// Take the key when it is free or its claim has expired; no row is
+	// returned while another claim holds it
+	var claimed string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO dedup_claims (key, expires_at)
+		VALUES ($1, NOW() + $2 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE dedup_claims.expires_at <= NOW()
+		RETURNING key`, key, ttl.Milliseconds()).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (s *postgresDedupStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM dedup_claims WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to release %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *postgresDedupStore) PurgeExpired(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM dedup_claims WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dedup claims: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dedup claims: %w", err)
+	}
+	return int(purged), nil
+}
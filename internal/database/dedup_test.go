@@ -0,0 +1,85 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/database/redistest"
+)
+
+// testDedupStoreContract checks the behaviour every dedup store shares: a
+// claim is exclusive until released
+func testDedupStoreContract(t *testing.T, store database.DedupStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	if claimed, err := store.Claim(ctx, "delivery_claim:n1", time.Minute); err != nil || !claimed {
+		t.Fatalf("first Claim() = %v, %v, want the claim taken", claimed, err)
+	}
+	if claimed, err := store.Claim(ctx, "delivery_claim:n1", time.Minute); err != nil || claimed {
+		t.Fatalf("second Claim() = %v, %v, want the claim held", claimed, err)
+	}
+	if err := store.Release(ctx, "delivery_claim:n1"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if claimed, err := store.Claim(ctx, "delivery_claim:n1", time.Minute); err != nil || !claimed {
+		t.Fatalf("Claim() after Release() = %v, %v, want the claim taken again", claimed, err)
+	}
+}
+
+func TestRedisDedupStore(t *testing.T) {
+	client, server := redistest.New(t)
+	store, err := database.NewDedupStore(database.DedupStoreRedis, nil, client)
+	if err != nil {
+		t.Fatalf("NewDedupStore() error = %v", err)
+	}
+
+	testDedupStoreContract(t, store)
+
+	// Claims expire with Redis keys, so there is nothing to purge
+	if ttl := server.TTL("delivery_claim:n1"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("claim TTL = %v, want at most a minute", ttl)
+	}
+	if purged, err := store.PurgeExpired(context.Background()); err != nil || purged != 0 {
+		t.Errorf("PurgeExpired() = %d, %v, want 0", purged, err)
+	}
+}
+
+func TestPostgresDedupStore(t *testing.T) {
+	db, mock := dbtest.New(t)
+	store, err := database.NewDedupStore(database.DedupStorePostgres, db, nil)
+	if err != nil {
+		t.Fatalf("NewDedupStore() error = %v", err)
+	}
+
+	// A held claim returns no row from the conditional upsert
+	claim := "INSERT INTO dedup_claims (key, expires_at)"
+	mock.ExpectQuery(claim).WithArgs("delivery_claim:n1", int64(60000)).WillReturnRows(dbtest.NewRows("key").AddRow("delivery_claim:n1"))
+	mock.ExpectQuery(claim).WithArgs("delivery_claim:n1", int64(60000)).WillReturnRows(dbtest.NewRows("key"))
+	mock.ExpectExec("DELETE FROM dedup_claims WHERE key = $1").WithArgs("delivery_claim:n1").WillReturnResult(1)
+	mock.ExpectQuery(claim).WithArgs("delivery_claim:n1", int64(60000)).WillReturnRows(dbtest.NewRows("key").AddRow("delivery_claim:n1"))
+
+	testDedupStoreContract(t, store)
+
+	mock.ExpectExec("DELETE FROM dedup_claims WHERE expires_at <= NOW()").WillReturnResult(3)
+	if purged, err := store.PurgeExpired(context.Background()); err != nil || purged != 3 {
+		t.Errorf("PurgeExpired() = %d, %v, want 3", purged, err)
+	}
+}
+
+func TestNewDedupStore(t *testing.T) {
+	db, _ := dbtest.New(t)
+
+	if store, err := database.NewDedupStore("", db, nil); err != nil || store != nil {
+		t.Errorf("NewDedupStore() without Redis = %v, %v, want no store", store, err)
+	}
+	if _, err := database.NewDedupStore(database.DedupStorePostgres, nil, nil); err == nil {
+		t.Error("NewDedupStore(postgres) without a database error = nil")
+	}
+	if _, err := database.NewDedupStore("memcached", db, nil); err == nil {
+		t.Error("NewDedupStore(memcached) error = nil")
+	}
+}
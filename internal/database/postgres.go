@@ -57,10 +57,32 @@ func (db *PostgresDB) InitSchema() error {
 		channel VARCHAR(50) NOT NULL, -- email, sms, push
 		enabled BOOLEAN DEFAULT true,
 		frequency VARCHAR(50) DEFAULT 'immediate', -- immediate, hourly, daily
+		locale VARCHAR(20), -- preferred template locale, e.g. en or pt-BR
 		created_at TIMESTAMP DEFAULT NOW(),
-		updated_at TIMESTAMP DEFAULT NOW(),
-		UNIQUE(user_id, channel)
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS locale VARCHAR(20);
+	-- Preferences are kept per tenant; rows without a tenant belong to untenanted requests
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+	ALTER TABLE user_preferences DROP CONSTRAINT IF EXISTS user_preferences_user_id_channel_key;
+	-- A preference with a category (transactional or marketing) overrides the channel-wide one for that category
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+	DROP INDEX IF EXISTS idx_user_preferences_tenant_user_channel;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_user_preferences_tenant_user_channel_category ON user_preferences(COALESCE(tenant_id, ''), user_id, channel, COALESCE(category, ''));
+
+	-- User devices table, one row per registered push token
+	CREATE TABLE IF NOT EXISTS user_devices (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token VARCHAR(500) NOT NULL, -- unique per tenant
+		platform VARCHAR(20), -- ios, android, web
+		active BOOLEAN DEFAULT true,
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
 	);
+	ALTER TABLE user_devices ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+	ALTER TABLE user_devices DROP CONSTRAINT IF EXISTS user_devices_token_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_user_devices_tenant_token ON user_devices(COALESCE(tenant_id, ''), token);
 
 	-- Notifications table
 	CREATE TABLE IF NOT EXISTS notifications (
@@ -75,30 +97,188 @@ func (db *PostgresDB) InitSchema() error {
 		error_message TEXT,
 		retry_count INTEGER DEFAULT 0,
 		scheduled_at TIMESTAMP,
+		expires_at TIMESTAMP, -- notification is cancelled instead of sent after this time
+		queued_at TIMESTAMP, -- set once the notification has been published to the queue
 		sent_at TIMESTAMP,
 		delivered_at TIMESTAMP,
+		resent_from UUID REFERENCES notifications(id), -- original notification when this is a resend
+		group_id UUID, -- shared by notifications fanned out from one request
+		attachments JSONB, -- email attachments, base64-encoded
+		collapse_key VARCHAR(64), -- newer notifications with the same key replace older ones
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS resent_from UUID REFERENCES notifications(id);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS group_id UUID;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS attachments JSONB;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS collapse_key VARCHAR(64);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS queued_at TIMESTAMP;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS category VARCHAR(50) NOT NULL DEFAULT 'transactional';
+	-- Version of the key recipient and body are encrypted with; NULL when stored in plaintext
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS key_version INTEGER;
+	ALTER TABLE notifications ALTER COLUMN recipient TYPE TEXT;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS stale_at TIMESTAMP; -- set when delivery was not confirmed within the delivery SLA
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS callback_url TEXT;
+	-- 1 = high, 2 = medium, 3 = low; decides push delivery priority
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 2;
+	-- Earliest and latest send times; notifications not sent by the end are expired
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS send_window_start TIMESTAMP;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS send_window_end TIMESTAMP;
+	-- Who created the notification (API key ID or admin) and through which API (api, grpc or admin)
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS created_by VARCHAR(255);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS source VARCHAR(20);
+	-- Full-text search over subject and body, subject matches ranking higher.
+	-- Encrypted bodies are left out so ciphertext is never indexed; columns
+	-- generated before that was the case are rebuilt.
+	DO $$
+	BEGIN
+		IF EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'notifications' AND column_name = 'search_vector'
+				AND generation_expression NOT LIKE '%key_version%'
+		) THEN
+			ALTER TABLE notifications DROP COLUMN search_vector;
+		END IF;
+	END $$;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', COALESCE(subject, '')), 'A') ||
+		setweight(to_tsvector('english', CASE WHEN key_version IS NULL THEN COALESCE(body, '') ELSE '' END), 'B')
+	) STORED;
+
+	-- Request metadata and provider options, republished with the notification
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS metadata JSONB;
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS provider_options JSONB;
+
+	-- Status history of notifications, for debugging deliveries
+	CREATE TABLE IF NOT EXISTS notification_events (
+		id BIGSERIAL PRIMARY KEY,
+		notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+		status VARCHAR(50) NOT NULL,
+		external_id VARCHAR(255),
+		detail TEXT, -- error message or reason for the change
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+
+	-- Queue messages written in the same transaction as their notification and
+	-- published to Kafka by the outbox relay
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+		payload JSONB NOT NULL, -- the queue message
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT,
+		available_at TIMESTAMP DEFAULT NOW(), -- next publish attempt
+		published_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+	-- Version of the key the payload's recipient and body are encrypted with
+	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS key_version INTEGER;
+
+	-- Status callbacks waiting to be POSTed to notifications' callback URLs;
+	-- rows that exhausted their attempts are dead-lettered with failed_at
+	CREATE TABLE IF NOT EXISTS status_callbacks (
+		id BIGSERIAL PRIMARY KEY,
+		notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT,
+		available_at TIMESTAMP DEFAULT NOW(), -- next delivery attempt
+		delivered_at TIMESTAMP,
+		failed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+
+	-- Dedup claims, such as workers' delivery claims, when DEDUP_STORE is
+	-- postgres; a claim is free again once expires_at has passed
+	CREATE TABLE IF NOT EXISTS dedup_claims (
+		key VARCHAR(255) PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	-- Push broadcasts to FCM topics, which have no user or recipient
+	CREATE TABLE IF NOT EXISTS broadcasts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		topic VARCHAR(900) NOT NULL,
+		subject VARCHAR(255),
+		body TEXT NOT NULL,
+		status VARCHAR(50) DEFAULT 'pending', -- pending, sent, failed
+		external_id VARCHAR(255),
+		error_message TEXT,
+		collapse_key VARCHAR(64),
+		sent_at TIMESTAMP,
 		created_at TIMESTAMP DEFAULT NOW(),
 		updated_at TIMESTAMP DEFAULT NOW()
 	);
+	ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+
+	-- Suppressed email addresses, added on hard bounces and spam complaints
+	CREATE TABLE IF NOT EXISTS suppressions (
+		email VARCHAR(255) PRIMARY KEY, -- stored lower-cased
+		reason VARCHAR(50) NOT NULL, -- bounce, complaint
+		detail TEXT,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+
+	-- Tenant-scoped API keys; only a SHA-256 hash of each key is stored
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id UUID PRIMARY KEY,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		key_prefix VARCHAR(20) NOT NULL,
+		tenant_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255),
+		scopes TEXT[] NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		revoked_at TIMESTAMP
+	);
 
 	-- Notification templates table
 	CREATE TABLE IF NOT EXISTS notification_templates (
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		name VARCHAR(255) UNIQUE NOT NULL,
+		name VARCHAR(255) NOT NULL,
 		channel VARCHAR(50) NOT NULL,
+		locale VARCHAR(20) NOT NULL DEFAULT 'en',
 		subject_template VARCHAR(255),
 		body_template TEXT NOT NULL,
 		variables JSONB,
 		created_at TIMESTAMP DEFAULT NOW(),
 		updated_at TIMESTAMP DEFAULT NOW()
 	);
+	-- Templates used to be unique by name only; they are now keyed by (name, channel, locale)
+	ALTER TABLE notification_templates ADD COLUMN IF NOT EXISTS locale VARCHAR(20) NOT NULL DEFAULT 'en';
+	ALTER TABLE notification_templates DROP CONSTRAINT IF EXISTS notification_templates_name_key;
+	-- Templates without a tenant are shared by all tenants
+	ALTER TABLE notification_templates ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+	ALTER TABLE notification_templates DROP CONSTRAINT IF EXISTS notification_templates_name_channel_locale_key;
+	DROP INDEX IF EXISTS idx_notification_templates_name_channel_locale;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_templates_tenant_name_channel_locale ON notification_templates(COALESCE(tenant_id, ''), name, channel, locale);
 
 	-- Create indexes for better performance
 	CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
 	CREATE INDEX IF NOT EXISTS idx_notifications_status ON notifications(status);
 	CREATE INDEX IF NOT EXISTS idx_notifications_channel ON notifications(channel);
 	CREATE INDEX IF NOT EXISTS idx_notifications_created_at ON notifications(created_at);
+	CREATE INDEX IF NOT EXISTS idx_notifications_resent_from ON notifications(resent_from);
+	CREATE INDEX IF NOT EXISTS idx_notifications_group_id ON notifications(group_id);
+	CREATE INDEX IF NOT EXISTS idx_notifications_tenant_id ON notifications(tenant_id, created_at) WHERE tenant_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_notifications_search ON notifications USING GIN (search_vector);
+	CREATE INDEX IF NOT EXISTS idx_status_callbacks_due ON status_callbacks(available_at, id) WHERE delivered_at IS NULL AND failed_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_status_callbacks_pending ON status_callbacks(notification_id, id) WHERE delivered_at IS NULL AND failed_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_status_callbacks_failed ON status_callbacks(failed_at) WHERE failed_at IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_notifications_scheduled ON notifications(scheduled_at) WHERE status = 'pending' AND queued_at IS NULL AND scheduled_at IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_notifications_unconfirmed ON notifications(sent_at) WHERE status = 'sent' AND stale_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys(tenant_id);
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_stats ON notifications(user_id, created_at, channel, status);
+	CREATE INDEX IF NOT EXISTS idx_notification_events_notification_id ON notification_events(notification_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_notifications_unqueued ON notifications(created_at) WHERE status = 'pending' AND queued_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_notifications_created_by ON notifications(created_by, created_at) WHERE created_by IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_notifications_in_flight ON notifications(queued_at) WHERE status = 'pending' AND queued_at IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox(available_at, id) WHERE published_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_outbox_notification_id ON outbox(notification_id) WHERE published_at IS NULL;
 	CREATE INDEX IF NOT EXISTS idx_user_preferences_user_id ON user_preferences(user_id);
+	CREATE INDEX IF NOT EXISTS idx_user_devices_user_id ON user_devices(user_id) WHERE active = true;
 	`
 
 	_, err := db.Exec(schema)
@@ -112,4 +292,4 @@ func (db *PostgresDB) InitSchema() error {
 // Close closes the database connection
 func (db *PostgresDB) Close() error {
 	return db.DB.Close()
-}
\ No newline at end of file
+}
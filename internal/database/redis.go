@@ -9,71 +9,204 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisClient wraps redis.Client for caching operations
+// Redis deployment modes
+const (
+	RedisModeSingle   = "single"   // one server at Addr
+	RedisModeSentinel = "sentinel" // the master named MasterName, found through the sentinels at Addrs
+	RedisModeCluster  = "cluster"  // a Redis Cluster, discovered from the nodes at Addrs
+)
+
+// RedisClient wraps a single-node, Sentinel or Cluster client for caching
+// operations
 type RedisClient struct {
-	*redis.Client
+	redis.UniversalClient
 }
 
-// NewRedisClient creates a new Redis client
+// NewRedisClient creates a new Redis client for the configured mode
 func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	rdb, err := newUniversalClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test the connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisClient{Client: rdb}, nil
+	return &RedisClient{UniversalClient: rdb}, nil
+}
+
+// newUniversalClient builds the client for cfg.Mode without connecting. Every
+// key the service uses is accessed on its own, so all operations work unchanged
+// against a Cluster.
+func newUniversalClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	addrs := cfg.Addrs
+	if len(addrs) == 0 && cfg.Addr != "" {
+		addrs = []string{cfg.Addr}
+	}
+
+	switch cfg.Mode {
+	case "", RedisModeSingle:
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}), nil
+	case RedisModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires a master name")
+		}
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel mode requires sentinel addresses")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+		}), nil
+	case RedisModeCluster:
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis cluster mode only supports database 0")
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode requires node addresses")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    addrs,
+			Password: cfg.Password,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q, must be single, sentinel or cluster", cfg.Mode)
+	}
+}
+
+// userPreferencesKey returns the cache key of a user's preference set
+func userPreferencesKey(userID string) string {
+	return fmt.Sprintf("user_preferences:%s", userID)
+}
+
+// CacheUserPreferences caches a user's serialized preference set for ttl
+func (r *RedisClient) CacheUserPreferences(ctx context.Context, userID string, data []byte, ttl time.Duration) error {
+	return r.Set(ctx, userPreferencesKey(userID), data, ttl).Err()
+}
+
+// CacheUserPreferencesBatch caches the serialized preference sets of several
+// users, keyed by user, for ttl in one round trip
+func (r *RedisClient) CacheUserPreferencesBatch(ctx context.Context, sets map[string][]byte, ttl time.Duration) error {
+	pipe := r.Pipeline()
+	for userID, data := range sets {
+		pipe.Set(ctx, userPreferencesKey(userID), data, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// InvalidateUserPreferences drops the cached preferences of a user
+func (r *RedisClient) InvalidateUserPreferences(ctx context.Context, userID string) error {
+	return r.Del(ctx, userPreferencesKey(userID)).Err()
 }
 
-// CacheUserPreferences caches user notification preferences
-func (r *RedisClient) CacheUserPreferences(ctx context.Context, userID string, preferences interface{}) error {
-	key := fmt.Sprintf("user_preferences:%s", userID)
-	return r.Set(ctx, key, preferences, time.Hour).Err()
+// GetUserPreferences retrieves a user's cached serialized preference set,
+// returning redis.Nil when it is not cached
+func (r *RedisClient) GetUserPreferences(ctx context.Context, userID string) ([]byte, error) {
+	return r.Get(ctx, userPreferencesKey(userID)).Bytes()
 }
 
-// GetUserPreferences retrieves cached user notification preferences
-func (r *RedisClient) GetUserPreferences(ctx context.Context, userID string) (string, error) {
-	key := fmt.Sprintf("user_preferences:%s", userID)
-	return r.Get(ctx, key).Result()
+// templateKey returns the cache key of a template name and channel. Every
+// resolution of the pair (per tenant and locale) is a field of one hash, so a
+// single delete invalidates them all.
+func templateKey(name, channel string) string {
+	return fmt.Sprintf("template:%s:%s", name, channel)
 }
 
-// CacheNotificationTemplate caches notification templates
-func (r *RedisClient) CacheNotificationTemplate(ctx context.Context, templateName string, template interface{}) error {
-	key := fmt.Sprintf("template:%s", templateName)
-	return r.Set(ctx, key, template, 24*time.Hour).Err()
+// CacheNotificationTemplate caches a serialized template resolved for field
+// (tenant and requested locale) for ttl
+func (r *RedisClient) CacheNotificationTemplate(ctx context.Context, name, channel, field string, data []byte, ttl time.Duration) error {
+	key := templateKey(name, channel)
+	pipe := r.TxPipeline()
+	pipe.HSet(ctx, key, field, data)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// GetNotificationTemplate retrieves cached notification template
-func (r *RedisClient) GetNotificationTemplate(ctx context.Context, templateName string) (string, error) {
-	key := fmt.Sprintf("template:%s", templateName)
-	return r.Get(ctx, key).Result()
+// GetNotificationTemplate retrieves a cached serialized template, returning
+// redis.Nil when it is not cached
+func (r *RedisClient) GetNotificationTemplate(ctx context.Context, name, channel, field string) ([]byte, error) {
+	return r.HGet(ctx, templateKey(name, channel), field).Bytes()
+}
+
+// DeleteNotificationTemplate removes every cached resolution of a template
+// name and channel
+func (r *RedisClient) DeleteNotificationTemplate(ctx context.Context, name, channel string) error {
+	return r.Del(ctx, templateKey(name, channel)).Err()
 }
 
 // IncrementRateLimit increments rate limit counter for a user
 func (r *RedisClient) IncrementRateLimit(ctx context.Context, userID string, window time.Duration) (int64, error) {
 	key := fmt.Sprintf("rate_limit:%s", userID)
 	pipe := r.Pipeline()
-	
+
 	incr := pipe.Incr(ctx, key)
 	pipe.Expire(ctx, key, window)
-	
+
 	_, err := pipe.Exec(ctx)
 	if err != nil {
 		return 0, err
 	}
-	
+
 	return incr.Val(), nil
 }
 
+// notificationKey returns the cache key of a notification
+func notificationKey(id string) string {
+	return fmt.Sprintf("notification:%s", id)
+}
+
+// CacheNotification caches a serialized notification for ttl
+func (r *RedisClient) CacheNotification(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return r.Set(ctx, notificationKey(id), data, ttl).Err()
+}
+
+// GetCachedNotification retrieves a cached serialized notification, returning
+// redis.Nil when it is not cached
+func (r *RedisClient) GetCachedNotification(ctx context.Context, id string) ([]byte, error) {
+	return r.Get(ctx, notificationKey(id)).Bytes()
+}
+
+// DeleteCachedNotification removes a notification from the cache
+func (r *RedisClient) DeleteCachedNotification(ctx context.Context, id string) error {
+	return r.Del(ctx, notificationKey(id)).Err()
+}
+
+// pausedKey is set while outbound sending is paused by an operator
+const pausedKey = "notifications:paused"
+
+// SetPaused sets or clears the global send pause flag
+func (r *RedisClient) SetPaused(ctx context.Context, paused bool) error {
+	if paused {
+		return r.Set(ctx, pausedKey, "1", 0).Err()
+	}
+	return r.Del(ctx, pausedKey).Err()
+}
+
+// IsPaused reports whether outbound sending is paused
+func (r *RedisClient) IsPaused(ctx context.Context) (bool, error) {
+	n, err := r.Exists(ctx, pausedKey).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 // Close closes the Redis connection
 func (r *RedisClient) Close() error {
-	return r.Client.Close()
-}
\ No newline at end of file
+	return r.UniversalClient.Close()
+}
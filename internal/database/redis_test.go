@@ -0,0 +1,88 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewUniversalClient(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		for _, mode := range []string{"", RedisModeSingle} {
+			client, err := newUniversalClient(config.RedisConfig{Mode: mode, Addr: "redis:6379", DB: 2})
+			if err != nil {
+				t.Fatalf("newUniversalClient(%q) error = %v", mode, err)
+			}
+			defer client.Close()
+			single, ok := client.(*redis.Client)
+			if !ok {
+				t.Fatalf("newUniversalClient(%q) = %T, want *redis.Client", mode, client)
+			}
+			if opts := single.Options(); opts.Addr != "redis:6379" || opts.DB != 2 {
+				t.Errorf("options = %s db %d, want redis:6379 db 2", opts.Addr, opts.DB)
+			}
+		}
+	})
+
+	t.Run("sentinel", func(t *testing.T) {
+		client, err := newUniversalClient(config.RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "mymaster"})
+		if err != nil {
+			t.Fatalf("newUniversalClient() error = %v", err)
+		}
+		defer client.Close()
+		// A failover client is a *redis.Client whose address is resolved by the sentinels
+		if failover, ok := client.(*redis.Client); !ok || failover.Options().Addr != "FailoverClient" {
+			t.Errorf("newUniversalClient() = %T, want a failover client", client)
+		}
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client, err := newUniversalClient(config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{"n1:6379", "n2:6379"}})
+		if err != nil {
+			t.Fatalf("newUniversalClient() error = %v", err)
+		}
+		defer client.Close()
+		cluster, ok := client.(*redis.ClusterClient)
+		if !ok {
+			t.Fatalf("newUniversalClient() = %T, want *redis.ClusterClient", client)
+		}
+		if addrs := cluster.Options().Addrs; len(addrs) != 2 || addrs[0] != "n1:6379" {
+			t.Errorf("Addrs = %v, want the configured nodes", addrs)
+		}
+	})
+
+	t.Run("cluster defaults to addr", func(t *testing.T) {
+		client, err := newUniversalClient(config.RedisConfig{Mode: RedisModeCluster, Addr: "n1:6379"})
+		if err != nil {
+			t.Fatalf("newUniversalClient() error = %v", err)
+		}
+		defer client.Close()
+		if cluster, ok := client.(*redis.ClusterClient); !ok || len(cluster.Options().Addrs) != 1 {
+			t.Errorf("newUniversalClient() = %T, want a cluster client of addr", client)
+		}
+	})
+}
+
+func TestNewUniversalClientRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RedisConfig
+		wantErr string
+	}{
+		{"sentinel without master", config.RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379"}}, "master name"},
+		{"sentinel without addresses", config.RedisConfig{Mode: RedisModeSentinel, Addr: "redis:6379", MasterName: "mymaster"}, "sentinel addresses"},
+		{"cluster with database", config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{"n1:6379"}, DB: 1}, "database 0"},
+		{"cluster without addresses", config.RedisConfig{Mode: RedisModeCluster}, "node addresses"},
+		{"unknown mode", config.RedisConfig{Mode: "ring", Addr: "redis:6379"}, "unknown redis mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newUniversalClient(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newUniversalClient() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
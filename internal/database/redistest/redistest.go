@@ -0,0 +1,372 @@
+// Package redistest runs an in-memory Redis server for tests of code using
+// database.RedisClient. It speaks enough of the Redis protocol for the
+// commands the service uses: strings, hashes, counters, expiry, pipelines and
+// MULTI/EXEC transactions.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// Server is an in-memory Redis server
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	failing  error // returned for every command while set
+}
+
+// New starts a server and returns a client connected to it. Both are closed
+// when the test ends.
+func New(t testing.TB) (*database.RedisClient, *Server) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start redis server: %v", err)
+	}
+	server := &Server{
+		listener: listener,
+		strings:  make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+		expires:  make(map[string]time.Time),
+	}
+	go server.serve()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), DisableIndentity: true, MaxRetries: -1})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return &database.RedisClient{UniversalClient: client}, server
+}
+
+// Get returns the string stored at key
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	value, ok := s.strings[key]
+	return value, ok
+}
+
+// HGet returns a field of the hash stored at key
+func (s *Server) HGet(key, field string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	value, ok := s.hashes[key][field]
+	return value, ok
+}
+
+// Set stores value at key without expiry
+func (s *Server) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.del(key)
+	s.strings[key] = value
+}
+
+// TTL returns how long key lives, 0 when it does not expire
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at, ok := s.expires[key]; ok {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// Fail makes every command fail with err until it is called with nil
+func (s *Server) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = err
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the commands of one connection
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var queued [][]string // commands of an open MULTI, nil outside one
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+
+		switch {
+		case name == "MULTI":
+			queued = [][]string{}
+			writeReply(w, "OK")
+		case name == "EXEC" && queued != nil:
+			replies := make([]any, len(queued))
+			for i, cmd := range queued {
+				replies[i] = s.execute(cmd)
+			}
+			queued = nil
+			writeReply(w, replies)
+		case name == "DISCARD" && queued != nil:
+			queued = nil
+			writeReply(w, "OK")
+		case queued != nil:
+			queued = append(queued, args)
+			writeReply(w, "QUEUED")
+		default:
+			writeReply(w, s.execute(args))
+		}
+
+		// Pipelined commands are answered together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// execute runs one command and returns its reply: a string for a status, an
+// int64, []byte for a bulk string, nil for a null reply, []any for an array or
+// an error
+func (s *Server) execute(args []string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		return "PONG"
+	case "CLIENT", "SELECT":
+		return "OK"
+	}
+	if s.failing != nil {
+		return s.failing
+	}
+	if len(args) > 1 {
+		s.expire(args[1])
+	}
+
+	switch {
+	case name == "GET" && len(args) == 2:
+		if value, ok := s.strings[args[1]]; ok {
+			return []byte(value)
+		}
+		return nil
+	case name == "SET" && len(args) >= 3:
+		return s.set(args[1], args[2], args[3:])
+	case name == "DEL" && len(args) >= 2:
+		var deleted int64
+		for _, key := range args[1:] {
+			s.expire(key)
+			if s.exists(key) {
+				s.del(key)
+				deleted++
+			}
+		}
+		return deleted
+	case name == "EXISTS" && len(args) >= 2:
+		var found int64
+		for _, key := range args[1:] {
+			s.expire(key)
+			if s.exists(key) {
+				found++
+			}
+		}
+		return found
+	case (name == "EXPIRE" || name == "PEXPIRE") && len(args) == 3:
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errors.New("ERR value is not an integer or out of range")
+		}
+		if !s.exists(args[1]) {
+			return int64(0)
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		s.expires[args[1]] = time.Now().Add(time.Duration(n) * unit)
+		return int64(1)
+	case name == "INCR" && len(args) == 2:
+		n, err := strconv.ParseInt(s.strings[args[1]], 10, 64)
+		if _, ok := s.strings[args[1]]; ok && err != nil {
+			return errors.New("ERR value is not an integer or out of range")
+		}
+		n++
+		s.strings[args[1]] = strconv.FormatInt(n, 10)
+		return n
+	case name == "HSET" && len(args) >= 4 && len(args)%2 == 0:
+		hash := s.hashes[args[1]]
+		if hash == nil {
+			hash = make(map[string]string)
+			s.hashes[args[1]] = hash
+		}
+		var added int64
+		for i := 2; i < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				added++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		return added
+	case name == "HGET" && len(args) == 3:
+		if value, ok := s.hashes[args[1]][args[2]]; ok {
+			return []byte(value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+}
+
+// set runs SET with its EX, PX, NX and XX options
+func (s *Server) set(key, value string, options []string) any {
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(options[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(options) {
+				return errors.New("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(options[i+1], 10, 64)
+			if err != nil {
+				return errors.New("ERR value is not an integer or out of range")
+			}
+			unit := time.Second
+			if strings.ToUpper(options[i]) == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return errors.New("ERR syntax error")
+		}
+	}
+
+	if (nx && s.exists(key)) || (xx && !s.exists(key)) {
+		return nil
+	}
+	s.del(key)
+	s.strings[key] = value
+	if ttl > 0 {
+		s.expires[key] = time.Now().Add(ttl)
+	}
+	return "OK"
+}
+
+func (s *Server) exists(key string) bool {
+	_, isString := s.strings[key]
+	_, isHash := s.hashes[key]
+	return isString || isHash
+}
+
+func (s *Server) del(key string) {
+	delete(s.strings, key)
+	delete(s.hashes, key)
+	delete(s.expires, key)
+}
+
+// expire deletes key when it has expired
+func (s *Server) expire(key string) {
+	if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+		s.del(key)
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid command header %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("invalid argument header %q", header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid argument header %q", header)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeReply encodes a reply returned by execute
+func writeReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		w.WriteString("+" + v + "\r\n")
+	case error:
+		w.WriteString("-" + v.Error() + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case []byte:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n")
+		w.Write(v)
+		w.WriteString("\r\n")
+	case []any:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// Supported log encodings
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// NewLogger builds a zap logger from the log configuration. It starts from
+// zap's production preset and applies the configured level, encoding and sampling.
+func NewLogger(cfg config.LogConfig) (*zap.Logger, error) {
+	zapCfg, err := zapConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return zapCfg.Build()
+}
+
+// zapConfig returns the zap configuration NewLogger builds the logger from
+func zapConfig(cfg config.LogConfig) (zap.Config, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return zap.Config{}, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	zapCfg := zap.NewProductionConfig()
+	zapCfg.Level = zap.NewAtomicLevelAt(level)
+
+	switch cfg.Encoding {
+	case EncodingJSON, "":
+		zapCfg.Encoding = EncodingJSON
+	case EncodingConsole:
+		zapCfg.Encoding = EncodingConsole
+		zapCfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		zapCfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	default:
+		return zap.Config{}, fmt.Errorf("invalid log encoding %q: must be %s or %s", cfg.Encoding, EncodingJSON, EncodingConsole)
+	}
+
+	if !cfg.Sampling {
+		zapCfg.Sampling = nil
+	}
+
+	return zapCfg, nil
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.LogConfig
+		wantLevel zapcore.Level
+		wantJSON  bool
+	}{
+		{"defaults", config.LogConfig{}, zapcore.InfoLevel, true},
+		{"debug json", config.LogConfig{Level: "debug", Encoding: "json"}, zapcore.DebugLevel, true},
+		{"warn console", config.LogConfig{Level: "warn", Encoding: "console"}, zapcore.WarnLevel, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zapCfg, err := zapConfig(tt.cfg)
+			if err != nil {
+				t.Fatalf("zapConfig() error = %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "log")
+			zapCfg.OutputPaths = []string{path}
+			logger, err := zapCfg.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+
+			if !logger.Core().Enabled(tt.wantLevel) || logger.Core().Enabled(tt.wantLevel-1) {
+				t.Errorf("logger is not at level %s", tt.wantLevel)
+			}
+
+			logger.Error("sent", zap.String("id", "n1"))
+			logger.Sync()
+			out, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read log: %v", err)
+			}
+			line := strings.TrimSpace(string(out))
+			var entry map[string]any
+			isJSON := json.Unmarshal([]byte(line), &entry) == nil
+			if isJSON != tt.wantJSON {
+				t.Errorf("logged %q, want JSON %v", line, tt.wantJSON)
+			}
+			if !strings.Contains(line, "n1") {
+				t.Errorf("logged %q without the id field", line)
+			}
+		})
+	}
+}
+
+func TestNewLoggerRejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []config.LogConfig{{Level: "loud"}, {Encoding: "xml"}} {
+		if _, err := NewLogger(cfg); err == nil {
+			t.Errorf("NewLogger(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestNewLoggerSampling(t *testing.T) {
+	for _, sampling := range []bool{false, true} {
+		zapCfg, err := zapConfig(config.LogConfig{Sampling: sampling})
+		if err != nil {
+			t.Fatalf("zapConfig() error = %v", err)
+		}
+		if (zapCfg.Sampling != nil) != sampling {
+			t.Errorf("Sampling = %+v with sampling %v", zapCfg.Sampling, sampling)
+		}
+	}
+}
@@ -2,11 +2,19 @@ package monitoring
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Reasons a notification is blocked, the reason label of notifications_blocked_total
+const (
+	BlockedPreferences = "preferences_disabled" // the user turned the channel or category off
+	BlockedSuppressed  = "suppressed"           // the recipient is on the suppression list
+	BlockedExpired     = "expired"              // expires_at or the send window passed before it was sent
+)
+
 // Metrics holds all Prometheus metrics for the notification service
 type Metrics struct {
 	NotificationsSent          *prometheus.CounterVec
@@ -18,6 +26,17 @@ type Metrics struct {
 	ActiveConnections          prometheus.Gauge
 	DatabaseConnections        *prometheus.GaugeVec
 	RetryCount                 *prometheus.CounterVec
+	ScheduledBacklog           prometheus.Gauge
+	ProducerBuffered           prometheus.Gauge
+	DeliveryStale              *prometheus.CounterVec
+	ConsumerPartitions         *prometheus.GaugeVec
+	ProviderRateLimitRemaining *prometheus.GaugeVec
+	StatusCallbacks            *prometheus.CounterVec
+	QueueWaitDuration          *prometheus.HistogramVec
+	NotificationsBlocked       *prometheus.CounterVec
+	ShadowSends                *prometheus.CounterVec
+	ShadowSendDuration         *prometheus.HistogramVec
+	UnknownChannel             *prometheus.CounterVec
 }
 
 // NewMetrics creates and registers all Prometheus metrics
@@ -28,14 +47,14 @@ func NewMetrics() *Metrics {
 				Name: "notifications_sent_total",
 				Help: "Total number of notifications sent",
 			},
-			[]string{"channel", "status"},
+			[]string{"channel", "provider", "status"},
 		),
 		NotificationsFailed: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "notifications_failed_total",
 				Help: "Total number of failed notifications",
 			},
-			[]string{"channel", "error_type"},
+			[]string{"channel", "provider", "error_type"},
 		),
 		NotificationsDelivered: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -86,6 +105,83 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"channel", "retry_reason"},
 		),
+		ScheduledBacklog: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "scheduled_backlog",
+				Help: "Number of pending scheduled notifications that are overdue for dispatch",
+			},
+		),
+		ProducerBuffered: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "producer_buffered_messages",
+				Help: "Number of messages buffered on disk waiting for Kafka",
+			},
+		),
+		DeliveryStale: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_stale_total",
+				Help: "Total number of sent notifications not confirmed delivered within the delivery SLA",
+			},
+			[]string{"channel"},
+		),
+		ConsumerPartitions: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "consumer_assigned_partitions",
+				Help: "Number of Kafka partitions assigned to each consumer of the process",
+			},
+			[]string{"channel", "consumer"},
+		),
+		ProviderRateLimitRemaining: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "provider_rate_limit_remaining",
+				Help: "Requests left in the provider's rate limit window, as last reported by the provider",
+			},
+			[]string{"channel", "provider"},
+		),
+		StatusCallbacks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "status_callbacks_total",
+				Help: "Total number of status callback delivery attempts by result",
+			},
+			[]string{"result"},
+		),
+		QueueWaitDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "queue_wait_duration_seconds",
+				Help:    "Time notifications waited in Kafka between being produced and consumed",
+				Buckets: prometheus.ExponentialBuckets(0.01, 4, 10), // 10ms to ~44min
+			},
+			[]string{"channel"},
+		),
+		NotificationsBlocked: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_blocked_total",
+				Help: "Total number of notifications intentionally not sent, by reason",
+			},
+			[]string{"channel", "reason"},
+		),
+		ShadowSends: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "shadow_sends_total",
+				Help: "Total number of sends mirrored to a candidate provider, by primary and candidate result",
+			},
+			[]string{"channel", "candidate", "primary_result", "candidate_result"},
+		),
+		ShadowSendDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "shadow_send_duration_seconds",
+				Help:    "Send duration of the primary and candidate providers on mirrored sends",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"channel", "provider", "role"},
+		),
+		UnknownChannel: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_unknown_channel_total",
+				Help: "Total number of messages dead-lettered because their channel is unknown, by consuming channel",
+			},
+			[]string{"channel"},
+		),
 	}
 
 	// Register all metrics
@@ -99,19 +195,40 @@ func NewMetrics() *Metrics {
 		metrics.ActiveConnections,
 		metrics.DatabaseConnections,
 		metrics.RetryCount,
+		metrics.ScheduledBacklog,
+		metrics.ProducerBuffered,
+		metrics.DeliveryStale,
+		metrics.ConsumerPartitions,
+		metrics.ProviderRateLimitRemaining,
+		metrics.StatusCallbacks,
+		metrics.QueueWaitDuration,
+		metrics.NotificationsBlocked,
+		metrics.ShadowSends,
+		metrics.ShadowSendDuration,
+		metrics.UnknownChannel,
 	)
 
 	return metrics
 }
 
-// RecordNotificationSent records a sent notification
+// RecordNotificationSent records a sent notification that did not go through a provider
 func (m *Metrics) RecordNotificationSent(channel, status string) {
-	m.NotificationsSent.WithLabelValues(channel, status).Inc()
+	m.RecordProviderSent(channel, "", status)
 }
 
-// RecordNotificationFailed records a failed notification
+// RecordNotificationFailed records a failed notification that did not go through a provider
 func (m *Metrics) RecordNotificationFailed(channel, errorType string) {
-	m.NotificationsFailed.WithLabelValues(channel, errorType).Inc()
+	m.RecordProviderFailed(channel, "", errorType)
+}
+
+// RecordProviderSent records a notification sent through a provider
+func (m *Metrics) RecordProviderSent(channel, provider, status string) {
+	m.NotificationsSent.WithLabelValues(channel, provider, status).Inc()
+}
+
+// RecordProviderFailed records a notification a provider failed to send
+func (m *Metrics) RecordProviderFailed(channel, provider, errorType string) {
+	m.NotificationsFailed.WithLabelValues(channel, provider, errorType).Inc()
 }
 
 // RecordNotificationDelivered records a delivered notification
@@ -129,6 +246,38 @@ func (m *Metrics) RecordChannelDuration(channel string, duration float64) {
 	m.ChannelProcessingDuration.WithLabelValues(channel).Observe(duration)
 }
 
+// RecordQueueWait records how long a message waited in the queue
+func (m *Metrics) RecordQueueWait(channel string, wait time.Duration) {
+	m.QueueWaitDuration.WithLabelValues(channel).Observe(wait.Seconds())
+}
+
+// RecordNotificationBlocked records a notification that was intentionally not
+// sent: blocked by the user's preferences, to a suppressed recipient or expired
+func (m *Metrics) RecordNotificationBlocked(channel, reason string) {
+	m.NotificationsBlocked.WithLabelValues(channel, reason).Inc()
+}
+
+// RecordScheduledExpired records scheduled notifications the scheduler
+// cancelled because their expiry or send window passed while they were held
+func (m *Metrics) RecordScheduledExpired(channel string, count int) {
+	m.NotificationsBlocked.WithLabelValues(channel, BlockedExpired).Add(float64(count))
+}
+
+// RecordShadowSend records a send mirrored to candidate, with the result of
+// the primary and the candidate provider ("sent" or "failed") and how long each
+// took
+func (m *Metrics) RecordShadowSend(channel, primary, candidate, primaryResult, candidateResult string, primaryDuration, candidateDuration time.Duration) {
+	m.ShadowSends.WithLabelValues(channel, candidate, primaryResult, candidateResult).Inc()
+	m.ShadowSendDuration.WithLabelValues(channel, primary, "primary").Observe(primaryDuration.Seconds())
+	m.ShadowSendDuration.WithLabelValues(channel, candidate, "candidate").Observe(candidateDuration.Seconds())
+}
+
+// RecordUnknownChannel records a message dead-lettered by channel's consumer
+// because its own channel is unknown
+func (m *Metrics) RecordUnknownChannel(channel string) {
+	m.UnknownChannel.WithLabelValues(channel).Inc()
+}
+
 // SetQueueSize sets the current queue size
 func (m *Metrics) SetQueueSize(size float64) {
 	m.QueueSize.Set(size)
@@ -154,7 +303,37 @@ func (m *Metrics) RecordRetry(channel, reason string) {
 	m.RetryCount.WithLabelValues(channel, reason).Inc()
 }
 
+// SetScheduledBacklog sets the number of overdue scheduled notifications
+func (m *Metrics) SetScheduledBacklog(count float64) {
+	m.ScheduledBacklog.Set(count)
+}
+
+// SetProducerBuffered sets the number of messages buffered for Kafka
+func (m *Metrics) SetProducerBuffered(count float64) {
+	m.ProducerBuffered.Set(count)
+}
+
+// RecordDeliveryStale records sent notifications that passed the delivery SLA
+func (m *Metrics) RecordDeliveryStale(channel string, count int) {
+	m.DeliveryStale.WithLabelValues(channel).Add(float64(count))
+}
+
+// SetConsumerPartitions sets the number of partitions assigned to a consumer
+func (m *Metrics) SetConsumerPartitions(channel, consumer string, count int) {
+	m.ConsumerPartitions.WithLabelValues(channel, consumer).Set(float64(count))
+}
+
+// SetProviderRateLimitRemaining sets the remaining requests a provider reported
+func (m *Metrics) SetProviderRateLimitRemaining(channel, provider string, remaining int) {
+	m.ProviderRateLimitRemaining.WithLabelValues(channel, provider).Set(float64(remaining))
+}
+
+// RecordStatusCallbacks records status callback attempts: delivered, retried or dead_lettered
+func (m *Metrics) RecordStatusCallbacks(result string, count int) {
+	m.StatusCallbacks.WithLabelValues(result).Add(float64(count))
+}
+
 // Handler returns the Prometheus metrics HTTP handler
 func (m *Metrics) Handler() http.Handler {
 	return promhttp.Handler()
-}
\ No newline at end of file
+}
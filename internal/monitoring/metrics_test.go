@@ -0,0 +1,102 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testMetrics is shared by all tests since metrics register globally
+var testMetrics = NewMetrics()
+
+// counterValue returns the value of the registered counter name with labels,
+// 0 when it was never incremented
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestRecordDeliveryStale(t *testing.T) {
+	testMetrics.RecordDeliveryStale("sms", 2)
+	testMetrics.RecordDeliveryStale("email", 1)
+	testMetrics.RecordDeliveryStale("sms", 3)
+
+	if got := counterValue(t, "delivery_stale_total", map[string]string{"channel": "sms"}); got != 5 {
+		t.Errorf("delivery_stale_total{channel=sms} = %v, want 5", got)
+	}
+	if got := counterValue(t, "delivery_stale_total", map[string]string{"channel": "email"}); got != 1 {
+		t.Errorf("delivery_stale_total{channel=email} = %v, want 1", got)
+	}
+}
+
+func TestSetProviderRateLimitRemaining(t *testing.T) {
+	testMetrics.SetProviderRateLimitRemaining("email", "sendgrid", 42)
+	testMetrics.SetProviderRateLimitRemaining("email", "sendgrid", 7)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "provider_rate_limit_remaining" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if got := metric.GetGauge().GetValue(); got != 7 {
+				t.Errorf("provider_rate_limit_remaining = %v, want the last reported 7", got)
+			}
+			return
+		}
+	}
+	t.Error("provider_rate_limit_remaining not exported")
+}
+
+func TestRecordNotificationBlocked(t *testing.T) {
+	testMetrics.RecordNotificationBlocked("email", BlockedSuppressed)
+	testMetrics.RecordNotificationBlocked("email", BlockedSuppressed)
+	testMetrics.RecordNotificationBlocked("sms", BlockedExpired)
+
+	if got := counterValue(t, "notifications_blocked_total", map[string]string{"channel": "email", "reason": "suppressed"}); got != 2 {
+		t.Errorf("notifications_blocked_total{channel=email,reason=suppressed} = %v, want 2", got)
+	}
+	if got := counterValue(t, "notifications_blocked_total", map[string]string{"channel": "sms", "reason": "expired"}); got != 1 {
+		t.Errorf("notifications_blocked_total{channel=sms,reason=expired} = %v, want 1", got)
+	}
+}
+
+func TestRecordScheduledExpired(t *testing.T) {
+	expired := map[string]string{"channel": "push", "reason": BlockedExpired}
+	before := counterValue(t, "notifications_blocked_total", expired)
+	testMetrics.RecordScheduledExpired("push", 3)
+
+	if got := counterValue(t, "notifications_blocked_total", expired) - before; got != 3 {
+		t.Errorf("notifications_blocked_total{channel=push,reason=expired} increased by %v, want 3", got)
+	}
+}
+
+func TestRecordUnknownChannel(t *testing.T) {
+	testMetrics.RecordUnknownChannel("push")
+	testMetrics.RecordUnknownChannel("push")
+
+	if got := counterValue(t, "notifications_unknown_channel_total", map[string]string{"channel": "push"}); got != 2 {
+		t.Errorf("notifications_unknown_channel_total{channel=push} = %v, want 2", got)
+	}
+}
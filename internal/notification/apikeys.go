@@ -0,0 +1,237 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// API key scopes
+const (
+	ScopeNotificationsCreate = "notifications:create" // create, resend and reschedule notifications
+	ScopeNotificationsRead   = "notifications:read"   // get and list notifications and user stats
+	ScopeNotificationsUpdate = "notifications:update" // report delivery status updates
+	ScopeDevicesWrite        = "devices:write"        // register push tokens
+	ScopeTemplatesRead       = "templates:read"       // preview templates
+	ScopePreferencesRead     = "preferences:read"     // get user preferences
+	ScopePreferencesWrite    = "preferences:write"    // replace user preferences
+)
+
+// apiKeyScopes lists the scopes a key may be granted
+var apiKeyScopes = map[string]bool{
+	ScopeNotificationsCreate: true,
+	ScopeNotificationsRead:   true,
+	ScopeNotificationsUpdate: true,
+	ScopeDevicesWrite:        true,
+	ScopeTemplatesRead:       true,
+	ScopePreferencesRead:     true,
+	ScopePreferencesWrite:    true,
+}
+
+// apiKeyPrefix starts every API key so leaked keys are easy to recognise
+const apiKeyPrefix = "nsk_"
+
+var (
+	// ErrInvalidAPIKey is returned when an API key is unknown
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyRevoked is returned when an API key has been revoked
+	ErrAPIKeyRevoked = errors.New("API key has been revoked")
+	// ErrAPIKeyNotFound is returned when revoking a key that does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIKeyRequest is returned when creating a key with a missing tenant or unknown scope
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+)
+
+// APIKey is a static credential for a B2B integrator, scoped to a tenant. Only
+// a SHA-256 hash of the key is stored; the key itself is shown once, when it
+// is created.
+type APIKey struct {
+	ID        string     `json:"id" db:"id"`
+	TenantID  string     `json:"tenant_id" db:"tenant_id"`
+	Name      string     `json:"name,omitempty" db:"name"`
+	Prefix    string     `json:"prefix" db:"key_prefix"` // first characters of the key, to tell keys apart
+	Scopes    []string   `json:"scopes" db:"scopes"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type (
+	tenantKey struct{}
+	apiKeyKey struct{}
+)
+
+// WithAPIKey returns a context carrying the API key a request was
+// authenticated with, scoped to the key's tenant
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return WithTenant(context.WithValue(ctx, apiKeyKey{}, key), key.TenantID)
+}
+
+// APIKeyFromContext returns the key set by WithAPIKey, or nil when the request
+// was not authenticated with an API key
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyKey{}).(*APIKey)
+	return key
+}
+
+// WithTenant returns a context carrying the tenant a request was authenticated
+// for. Notifications created with it are tagged with the tenant, and lookups
+// and lists only see that tenant's notifications.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or "" when the
+// request is not scoped to a tenant
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// hashAPIKey returns the hex SHA-256 of a key as stored in api_keys
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey issues a new key for tenantID with the given scopes. It returns
+// the stored key and the plaintext key, which cannot be retrieved later.
+func (s *Service) CreateAPIKey(ctx context.Context, tenantID, name string, scopes []string) (*APIKey, string, error) {
+	if tenantID == "" {
+		return nil, "", fmt.Errorf("%w: tenant_id is required", ErrInvalidAPIKeyRequest)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &APIKey{
+		ID:        s.ids.NewID(),
+		TenantID:  tenantID,
+		Name:      name,
+		Prefix:    plaintext[:len(apiKeyPrefix)+8],
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	query := `
+		INSERT INTO api_keys (id, key_hash, key_prefix, tenant_id, name, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := s.db.ExecContext(ctx, query, key.ID, hashAPIKey(plaintext), key.Prefix, key.TenantID,
+		nullIfEmpty(key.Name), pq.Array(key.Scopes), key.CreatedAt); err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	s.logger.Info("Created API key",
+		zap.String("id", key.ID),
+		zap.String("tenant_id", key.TenantID),
+		zap.Strings("scopes", key.Scopes),
+	)
+	return key, plaintext, nil
+}
+
+// AuthenticateAPIKey returns the key matching plaintext, failing with
+// ErrInvalidAPIKey for unknown keys and ErrAPIKeyRevoked for revoked ones
+func (s *Service) AuthenticateAPIKey(ctx context.Context, plaintext string) (*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(name, ''), key_prefix, scopes, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1`
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, hashAPIKey(plaintext)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	return key, nil
+}
+
+// RevokeAPIKey stops a key from authenticating. Revoking a revoked key is a no-op.
+func (s *Service) RevokeAPIKey(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	s.logger.Info("Revoked API key", zap.String("id", id))
+	return nil
+}
+
+// ListAPIKeys returns the keys of a tenant, or of all tenants when tenantID is
+// empty, newest first
+func (s *Service) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(name, ''), key_prefix, scopes, created_at, revoked_at
+		FROM api_keys
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// scanAPIKey reads an API key row without its hash
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
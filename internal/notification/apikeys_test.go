@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/lib/pq"
+)
+
+// apiKeyRows returns an api_keys row for key
+func apiKeyRows(key APIKey) *dbtest.Rows {
+	return dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at").
+		AddRow(key.ID, key.TenantID, key.Name, key.Prefix, pq.Array(key.Scopes), key.CreatedAt, key.RevokedAt)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("INSERT INTO api_keys").
+		WithArgs(dbtest.AnyArg(), dbtest.AnyArg(), dbtest.AnyArg(), "tenant-a", "ci", pq.Array([]string{ScopeNotificationsCreate}), dbtest.AnyArg()).
+		WillReturnResult(1)
+
+	key, plaintext, err := s.CreateAPIKey(context.Background(), "tenant-a", "ci", []string{ScopeNotificationsCreate})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) || !strings.HasPrefix(plaintext, key.Prefix) {
+		t.Errorf("key %q, prefix %q, want an %s key starting with its prefix", plaintext, key.Prefix, apiKeyPrefix)
+	}
+	if key.TenantID != "tenant-a" || !key.HasScope(ScopeNotificationsCreate) || key.HasScope(ScopeNotificationsRead) {
+		t.Errorf("key = %+v, want a create-only key for tenant-a", key)
+	}
+}
+
+func TestCreateAPIKeyStoresOnlyHash(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("INSERT INTO api_keys").WillReturnResult(1)
+
+	_, plaintext, err := s.CreateAPIKey(context.Background(), "tenant-a", "", []string{ScopeNotificationsRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	// The stored hash is what AuthenticateAPIKey looks the key up by
+	mock.ExpectQuery("WHERE key_hash = $1").WithArgs(hashAPIKey(plaintext)).
+		WillReturnRows(apiKeyRows(APIKey{ID: "k1", TenantID: "tenant-a", Scopes: []string{ScopeNotificationsRead}, CreatedAt: time.Now()}))
+	if _, err := s.AuthenticateAPIKey(context.Background(), plaintext); err != nil {
+		t.Errorf("AuthenticateAPIKey() error = %v", err)
+	}
+	if hashAPIKey(plaintext) == plaintext || len(hashAPIKey(plaintext)) != 64 {
+		t.Errorf("hashAPIKey() = %q, want a hex SHA-256", hashAPIKey(plaintext))
+	}
+}
+
+func TestCreateAPIKeyRejectsInvalidRequests(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	tests := []struct {
+		tenantID string
+		scopes   []string
+	}{
+		{"", []string{ScopeNotificationsRead}},
+		{"tenant-a", nil},
+		{"tenant-a", []string{"notifications:delete"}},
+	}
+	for _, tt := range tests {
+		if _, _, err := s.CreateAPIKey(context.Background(), tt.tenantID, "", tt.scopes); !errors.Is(err, ErrInvalidAPIKeyRequest) {
+			t.Errorf("CreateAPIKey(%q, %v) error = %v, want ErrInvalidAPIKeyRequest", tt.tenantID, tt.scopes, err)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		rows    *dbtest.Rows
+		wantErr error
+	}{
+		{"valid", apiKeyRows(APIKey{ID: "k1", TenantID: "tenant-a", Scopes: []string{ScopeNotificationsRead}, CreatedAt: time.Now()}), nil},
+		{"unknown", dbtest.NewRows("id", "tenant_id", "name", "key_prefix", "scopes", "created_at", "revoked_at"), ErrInvalidAPIKey},
+		{"revoked", apiKeyRows(APIKey{ID: "k1", TenantID: "tenant-a", Scopes: []string{ScopeNotificationsRead}, CreatedAt: time.Now(), RevokedAt: &revokedAt}), ErrAPIKeyRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			mock.ExpectQuery("FROM api_keys").WithArgs(hashAPIKey("nsk_key")).WillReturnRows(tt.rows)
+
+			key, err := s.AuthenticateAPIKey(context.Background(), "nsk_key")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (key.TenantID != "tenant-a" || !key.HasScope(ScopeNotificationsRead)) {
+				t.Errorf("AuthenticateAPIKey() = %+v, want tenant-a's read key", key)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").WithArgs("k1").WillReturnResult(1)
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").WithArgs("missing").WillReturnResult(0)
+
+	if err := s.RevokeAPIKey(context.Background(), "k1"); err != nil {
+		t.Errorf("RevokeAPIKey() error = %v", err)
+	}
+	if err := s.RevokeAPIKey(context.Background(), "missing"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("RevokeAPIKey(missing) error = %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestWithAPIKeyScopesContextToTenant(t *testing.T) {
+	key := &APIKey{ID: "k1", TenantID: "tenant-a"}
+	ctx := WithAPIKey(context.Background(), key)
+
+	if got := TenantFromContext(ctx); got != "tenant-a" {
+		t.Errorf("TenantFromContext() = %q, want tenant-a", got)
+	}
+	if got := APIKeyFromContext(ctx); got != key {
+		t.Errorf("APIKeyFromContext() = %v, want the key", got)
+	}
+	if TenantFromContext(context.Background()) != "" || APIKeyFromContext(context.Background()) != nil {
+		t.Error("context without a key is scoped to a tenant")
+	}
+}
@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// Attachment represents a file attached to an email notification.
+// Attachments with a ContentID are sent inline and can be referenced from an
+// HTML body as cid:<content_id>. Instead of Content, an attachment may give
+// the https URL of an object, e.g. a presigned S3 or GCS URL, which the email
+// channel fetches at send time; its content type is then detected when not given.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content,omitempty"` // base64-encoded file content
+	URL         string `json:"url,omitempty"`     // fetched at send time instead of content
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// IsInline reports whether the attachment should be sent as an inline image
+func (a Attachment) IsInline() bool {
+	return a.ContentID != ""
+}
+
+// IsReference reports whether the attachment's content is fetched from its URL
+func (a Attachment) IsReference() bool {
+	return a.URL != ""
+}
+
+// CheckAttachmentURL reports why an attachment may not be fetched from rawURL,
+// or returns "" when it may: the URL must be https and its host one of hosts,
+// where an entry starting with a dot matches any subdomain
+func CheckAttachmentURL(rawURL string, hosts []string) string {
+	if len(hosts) == 0 {
+		return "attachments by URL are not enabled"
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "url is malformed"
+	}
+	if parsed.Scheme != "https" {
+		return "url must use https"
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("host %s is not allowed", host)
+}
+
+// AttachmentError describes why an attachment was rejected
+type AttachmentError struct {
+	Filename string
+	Reason   string
+}
+
+func (e *AttachmentError) Error() string {
+	return fmt.Sprintf("invalid attachment %q: %s", e.Filename, e.Reason)
+}
+
+// ValidateAttachments checks attachment content types against the allowlist,
+// verifies the content is valid base64 and enforces per-file and total size caps.
+// Attachments by URL are checked against the allowed hosts; their content type
+// and size are only checked once they have been fetched.
+func ValidateAttachments(attachments []Attachment, cfg config.AttachmentConfig) error {
+	allowed := make(map[string]bool, len(cfg.AllowedTypes))
+	for _, t := range cfg.AllowedTypes {
+		allowed[strings.ToLower(t)] = true
+	}
+
+	var total int64
+	for _, a := range attachments {
+		if a.Filename == "" {
+			return &AttachmentError{Filename: a.Filename, Reason: "filename is required"}
+		}
+
+		if a.IsReference() {
+			if a.Content != "" {
+				return &AttachmentError{Filename: a.Filename, Reason: "content and url are mutually exclusive"}
+			}
+			if reason := CheckAttachmentURL(a.URL, cfg.URLHosts); reason != "" {
+				return &AttachmentError{Filename: a.Filename, Reason: reason}
+			}
+			if a.ContentType == "" {
+				continue
+			}
+		}
+
+		mediaType, _, err := mime.ParseMediaType(a.ContentType)
+		if err != nil {
+			return &AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("malformed content type %q", a.ContentType)}
+		}
+		if !allowed[mediaType] {
+			return &AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("content type %s is not allowed", mediaType)}
+		}
+		if a.IsInline() && !strings.HasPrefix(mediaType, "image/") {
+			return &AttachmentError{Filename: a.Filename, Reason: "only images can be sent inline"}
+		}
+		if a.IsReference() {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return &AttachmentError{Filename: a.Filename, Reason: "content is not valid base64"}
+		}
+
+		size := int64(len(decoded))
+		if cfg.MaxFileSize > 0 && size > cfg.MaxFileSize {
+			return &AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("size %d bytes exceeds limit of %d bytes", size, cfg.MaxFileSize)}
+		}
+
+		total += size
+		if cfg.MaxTotalSize > 0 && total > cfg.MaxTotalSize {
+			return &AttachmentError{Filename: a.Filename, Reason: fmt.Sprintf("total attachment size exceeds limit of %d bytes", cfg.MaxTotalSize)}
+		}
+	}
+
+	return nil
+}
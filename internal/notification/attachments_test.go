@@ -0,0 +1,134 @@
+package notification
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestValidateAttachments(t *testing.T) {
+	cfg := config.AttachmentConfig{
+		AllowedTypes: []string{"application/pdf", "image/png", "text/plain"},
+		MaxFileSize:  10,
+		MaxTotalSize: 15,
+		URLHosts:     []string{"files.example.com", ".s3.amazonaws.com"},
+	}
+	content := func(size int) string {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", size)))
+	}
+
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		wantFile    string // filename of the rejected attachment, empty when valid
+		wantReason  string
+	}{
+		{
+			name:        "valid",
+			attachments: []Attachment{{Filename: "a.pdf", ContentType: "application/pdf", Content: content(10)}},
+		},
+		{
+			name:        "content type parameters",
+			attachments: []Attachment{{Filename: "a.txt", ContentType: "text/plain; charset=utf-8", Content: content(1)}},
+		},
+		{
+			name:        "inline image",
+			attachments: []Attachment{{Filename: "logo.png", ContentType: "image/png", Content: content(1), ContentID: "logo"}},
+		},
+		{
+			name:        "oversize file",
+			attachments: []Attachment{{Filename: "big.pdf", ContentType: "application/pdf", Content: content(11)}},
+			wantFile:    "big.pdf",
+			wantReason:  "exceeds limit of 10 bytes",
+		},
+		{
+			name: "oversize total",
+			attachments: []Attachment{
+				{Filename: "a.pdf", ContentType: "application/pdf", Content: content(8)},
+				{Filename: "b.pdf", ContentType: "application/pdf", Content: content(8)},
+			},
+			wantFile:   "b.pdf",
+			wantReason: "total attachment size",
+		},
+		{
+			name:        "disallowed type",
+			attachments: []Attachment{{Filename: "run.exe", ContentType: "application/x-msdownload", Content: content(1)}},
+			wantFile:    "run.exe",
+			wantReason:  "is not allowed",
+		},
+		{
+			name:        "malformed type",
+			attachments: []Attachment{{Filename: "a.pdf", ContentType: "pdf;;", Content: content(1)}},
+			wantFile:    "a.pdf",
+			wantReason:  "malformed content type",
+		},
+		{
+			name:        "malformed base64",
+			attachments: []Attachment{{Filename: "a.pdf", ContentType: "application/pdf", Content: "not base64!"}},
+			wantFile:    "a.pdf",
+			wantReason:  "not valid base64",
+		},
+		{
+			name:        "inline non-image",
+			attachments: []Attachment{{Filename: "a.pdf", ContentType: "application/pdf", Content: content(1), ContentID: "doc"}},
+			wantFile:    "a.pdf",
+			wantReason:  "only images",
+		},
+		{
+			name:        "url",
+			attachments: []Attachment{{Filename: "a.pdf", URL: "https://bucket.s3.amazonaws.com/a.pdf"}},
+		},
+		{
+			name:        "url over http",
+			attachments: []Attachment{{Filename: "a.pdf", URL: "http://files.example.com/a.pdf"}},
+			wantFile:    "a.pdf",
+			wantReason:  "must use https",
+		},
+		{
+			name:        "url host not allowed",
+			attachments: []Attachment{{Filename: "a.pdf", URL: "https://evil.example.org/a.pdf"}},
+			wantFile:    "a.pdf",
+			wantReason:  "is not allowed",
+		},
+		{
+			name:        "url and content",
+			attachments: []Attachment{{Filename: "a.pdf", URL: "https://files.example.com/a.pdf", Content: content(1)}},
+			wantFile:    "a.pdf",
+			wantReason:  "mutually exclusive",
+		},
+		{
+			name:        "missing filename",
+			attachments: []Attachment{{ContentType: "application/pdf", Content: content(1)}},
+			wantReason:  "filename is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAttachments(tt.attachments, cfg)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("ValidateAttachments() error = %v", err)
+				}
+				return
+			}
+
+			var attachmentErr *AttachmentError
+			if !errors.As(err, &attachmentErr) {
+				t.Fatalf("ValidateAttachments() error = %v, want an AttachmentError", err)
+			}
+			if attachmentErr.Filename != tt.wantFile || !strings.Contains(attachmentErr.Reason, tt.wantReason) {
+				t.Errorf("ValidateAttachments() error = %v, want %q rejected with %q", err, tt.wantFile, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCheckAttachmentURLDisabled(t *testing.T) {
+	if reason := CheckAttachmentURL("https://files.example.com/a.pdf", nil); reason == "" {
+		t.Error("CheckAttachmentURL() without hosts allowed the URL")
+	}
+}
@@ -0,0 +1,42 @@
+package notification
+
+import "context"
+
+// Sources a notification can be created through, recorded for audit
+const (
+	SourceAPI   = "api"   // REST API
+	SourceGRPC  = "grpc"  // gRPC API and its JSON gateway
+	SourceAdmin = "admin" // admin routes
+)
+
+// PrincipalAdmin is recorded as the creator of notifications created with the
+// admin token
+const PrincipalAdmin = "admin"
+
+// sourceKey is the context key of the request's source
+type sourceKey struct{}
+
+// WithSource returns a context recording which API a request came through.
+// Notifications created with it are tagged with the source.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the source set by WithSource, or "" when the
+// notification is created by the service itself
+func SourceFromContext(ctx context.Context) string {
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
+
+// principalFromContext returns who a request is attributed to: the ID of its
+// API key, PrincipalAdmin on admin routes, or "" for unauthenticated requests
+func principalFromContext(ctx context.Context) string {
+	if key := APIKeyFromContext(ctx); key != nil {
+		return key.ID
+	}
+	if SourceFromContext(ctx) == SourceAdmin {
+		return PrincipalAdmin
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"api key", WithSource(WithAPIKey(context.Background(), &APIKey{ID: "k1", TenantID: "tenant-a"}), SourceAPI), "k1"},
+		{"admin token", WithSource(context.Background(), SourceAdmin), PrincipalAdmin},
+		{"unauthenticated", WithSource(context.Background(), SourceGRPC), ""},
+		{"service", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		if got := principalFromContext(tt.ctx); got != tt.want {
+			t.Errorf("%s: principalFromContext() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateNotificationRecordsAttribution(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		createdBy any
+		source    any
+	}{
+		{"api key", WithSource(WithAPIKey(context.Background(), &APIKey{ID: "k1"}), SourceAPI), "k1", SourceAPI},
+		{"admin", WithSource(context.Background(), SourceAdmin), PrincipalAdmin, SourceAdmin},
+		{"service", context.Background(), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+			withProducer(s)
+
+			args := insertArgs("+15551234567", nil)
+			args[21], args[22] = tt.createdBy, tt.source
+			expectPreferences(mock, "user-1")
+			expectStoreWithArgs(mock, args, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending})
+
+			_, err := s.CreateNotification(tt.ctx, NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+			if err != nil {
+				t.Fatalf("CreateNotification() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestGetNotificationReturnsAttribution(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusSent, CreatedBy: "k1", Source: SourceGRPC}))
+
+	notification, err := s.GetNotification(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("GetNotification() error = %v", err)
+	}
+	if notification.CreatedBy != "k1" || notification.Source != SourceGRPC {
+		t.Errorf("attribution = %q, %q, want k1, grpc", notification.CreatedBy, notification.Source)
+	}
+}
+
+func TestListNotificationsFiltersByAttribution(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND created_by = $2 AND source = $3").
+		WithArgs("user-1", "k1", SourceAPI).
+		WillReturnRows(dbtest.NewRows("count").AddRow(1))
+	mock.ExpectQuery("WHERE user_id = $1 AND created_by = $2 AND source = $3").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusSent, CreatedBy: "k1", Source: SourceAPI}))
+
+	result, err := s.ListNotifications(context.Background(), ListNotificationsFilter{UserID: "user-1", CreatedBy: "k1", Source: SourceAPI})
+	if err != nil {
+		t.Fatalf("ListNotifications() error = %v", err)
+	}
+	if len(result.Notifications) != 1 || result.Notifications[0].CreatedBy != "k1" {
+		t.Errorf("ListNotifications() = %+v, want n1 created by k1", result.Notifications)
+	}
+}
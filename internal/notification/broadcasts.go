@@ -0,0 +1,200 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/queue"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidBroadcast is returned when a topic broadcast request is malformed
+var ErrInvalidBroadcast = errors.New("invalid broadcast")
+
+// ErrBroadcastNotFound is returned when a broadcast does not exist
+var ErrBroadcastNotFound = errors.New("broadcast not found")
+
+// topicPattern matches the topic names FCM accepts
+var topicPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.~%]{1,900}$`)
+
+// Broadcast is a push notification sent to every device subscribed to an FCM
+// topic rather than to a user. Broadcasts skip user preferences and are stored
+// apart from notifications, which always belong to a user.
+type Broadcast struct {
+	ID              string             `json:"id" db:"id"`
+	Topic           string             `json:"topic" db:"topic"`
+	Subject         string             `json:"subject,omitempty" db:"subject"`
+	Body            string             `json:"body" db:"body"`
+	Status          NotificationStatus `json:"status" db:"status"`
+	ExternalID      string             `json:"external_id,omitempty" db:"external_id"`
+	ErrorMessage    string             `json:"error_message,omitempty" db:"error_message"`
+	CollapseKey     string             `json:"collapse_key,omitempty" db:"collapse_key"`
+	TenantID        string             `json:"tenant_id,omitempty" db:"tenant_id"`
+	Metadata        map[string]string  `json:"metadata,omitempty"`
+	ProviderOptions ProviderOptions    `json:"provider_options,omitempty"` // passed through to FCM, not stored
+	Truncated       []string           `json:"truncated,omitempty"`        // fields shortened to the channel's limits on creation
+	SentAt          *time.Time         `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// validateBroadcast checks the parts of a request that broadcasts support
+func validateBroadcast(req NotificationRequest) error {
+	switch {
+	case req.Channel != "push":
+		return fmt.Errorf("%w: topic broadcasts are only supported for push, not %s", ErrInvalidBroadcast, req.Channel)
+	case !topicPattern.MatchString(req.Topic):
+		return fmt.Errorf("%w: topic must match %s", ErrInvalidBroadcast, topicPattern.String())
+	case req.Recipient != "" || len(req.Recipients) > 0:
+		return fmt.Errorf("%w: recipients cannot be combined with a topic", ErrInvalidBroadcast)
+	case len(req.FallbackChannels) > 0:
+		return fmt.Errorf("%w: fallback_channels cannot be combined with a topic", ErrInvalidBroadcast)
+	case req.ScheduledAt != nil || req.ExpiresAt != nil || req.SendWindowStart != nil || req.SendWindowEnd != nil:
+		return fmt.Errorf("%w: broadcasts cannot be scheduled or expire", ErrInvalidBroadcast)
+	}
+	if err := ValidateProviderOptions(req.ProviderOptions); err != nil {
+		return err
+	}
+	return ValidatePushOptions(req.Metadata)
+}
+
+// CreateBroadcast stores a topic broadcast and queues it for the push service.
+// A template is rendered in req.Locale, as there is no user to take it from.
+func (s *Service) CreateBroadcast(ctx context.Context, req NotificationRequest) (*Broadcast, error) {
+	if err := validateBroadcast(req); err != nil {
+		return nil, err
+	}
+	if !s.config.Channels.IsEnabled(req.Channel) {
+		return nil, fmt.Errorf("%w: %s", ErrChannelDisabled, req.Channel)
+	}
+
+	if req.Template != "" {
+		tmpl, err := s.getTemplate(ctx, req.Template, req.Channel, req.Locale)
+		if err != nil {
+			return nil, err
+		}
+		req.Subject, req.Body, err = renderTemplate(tmpl, req.Variables)
+		if err != nil {
+			return nil, err
+		}
+	} else if s.config.Channels.InlineTemplates {
+		subject, body, err := renderInline(req.Subject, req.Body, req.Variables)
+		if err != nil {
+			return nil, err
+		}
+		req.Subject, req.Body = subject, body
+	}
+
+	// Enforce the channel's subject and body limits on the final content
+	subject, body, truncated, err := applyContentLimits(s.config.Channels.Limits(req.Channel), req.Channel, req.Subject, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Subject, req.Body = subject, body
+
+	priority := req.Priority
+	if priority == 0 {
+		priority = PriorityMedium
+	}
+
+	now := time.Now()
+	broadcast := &Broadcast{
+		ID:              s.ids.NewID(),
+		Topic:           req.Topic,
+		Subject:         req.Subject,
+		Body:            req.Body,
+		Status:          StatusPending,
+		CollapseKey:     req.CollapseKey,
+		TenantID:        TenantFromContext(ctx),
+		Metadata:        req.Metadata,
+		ProviderOptions: req.ProviderOptions,
+		Truncated:       truncated,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	query := `
+		INSERT INTO broadcasts (id, topic, subject, body, status, collapse_key, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = s.db.ExecContext(ctx, query,
+		broadcast.ID, broadcast.Topic, broadcast.Subject, broadcast.Body, broadcast.Status,
+		nullIfEmpty(broadcast.CollapseKey), nullIfEmpty(broadcast.TenantID), broadcast.CreatedAt, broadcast.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert broadcast: %w", err)
+	}
+
+	if s.producer == nil {
+		return nil, fmt.Errorf("%w: cannot publish broadcast %s", ErrNoProducer, broadcast.ID)
+	}
+	queueMsg := queue.NotificationMessage{
+		ID:              broadcast.ID,
+		Channel:         req.Channel,
+		Topic:           broadcast.Topic,
+		Subject:         broadcast.Subject,
+		Body:            broadcast.Body,
+		Metadata:        broadcast.Metadata,
+		ProviderOptions: broadcast.ProviderOptions,
+		Priority:        priority,
+		CreatedAt:       broadcast.CreatedAt,
+	}
+	if err := s.producer.PublishNotification(ctx, queueMsg); err != nil {
+		s.UpdateBroadcastStatus(ctx, broadcast.ID, StatusFailed, "", err.Error())
+		return nil, fmt.Errorf("failed to publish broadcast: %w", err)
+	}
+
+	s.logger.Info("Broadcast created", zap.String("id", broadcast.ID), zap.String("topic", broadcast.Topic))
+	return broadcast, nil
+}
+
+// GetBroadcast retrieves a broadcast by ID
+func (s *Service) GetBroadcast(ctx context.Context, id string) (*Broadcast, error) {
+	broadcast := &Broadcast{}
+	var subject, externalID, errorMessage, collapseKey, tenantID sql.NullString
+	query := `
+		SELECT id, topic, subject, body, status, external_id, error_message, collapse_key, tenant_id, sent_at, created_at, updated_at
+		FROM broadcasts WHERE id = $1 AND ($2 = '' OR tenant_id = $2)`
+	err := s.db.QueryRowContext(ctx, query, id, TenantFromContext(ctx)).Scan(
+		&broadcast.ID, &broadcast.Topic, &subject, &broadcast.Body, &broadcast.Status,
+		&externalID, &errorMessage, &collapseKey, &tenantID, &broadcast.SentAt,
+		&broadcast.CreatedAt, &broadcast.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBroadcastNotFound
+		}
+		return nil, fmt.Errorf("failed to get broadcast: %w", err)
+	}
+
+	broadcast.Subject = subject.String
+	broadcast.ExternalID = externalID.String
+	broadcast.TenantID = tenantID.String
+	broadcast.ErrorMessage = errorMessage.String
+	broadcast.CollapseKey = collapseKey.String
+	return broadcast, nil
+}
+
+// UpdateBroadcastStatus records the outcome of sending a broadcast
+func (s *Service) UpdateBroadcastStatus(ctx context.Context, id string, status NotificationStatus, externalID, errorMessage string) error {
+	var sentAt *time.Time
+	if status == StatusSent {
+		now := time.Now()
+		sentAt = &now
+	}
+
+	query := `
+		UPDATE broadcasts
+		SET status = $1, external_id = $2, error_message = $3, sent_at = COALESCE($4, sent_at), updated_at = NOW()
+		WHERE id = $5`
+	_, err := s.db.ExecContext(ctx, query, status, nullIfEmpty(externalID), nullIfEmpty(errorMessage), sentAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update broadcast status: %w", err)
+	}
+
+	s.logger.Info("Updated broadcast status", zap.String("id", id), zap.String("status", string(status)))
+	return nil
+}
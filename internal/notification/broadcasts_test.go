@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+)
+
+func TestValidateBroadcast(t *testing.T) {
+	scheduled := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		req     NotificationRequest
+		wantErr bool
+	}{
+		{"push topic", NotificationRequest{Channel: "push", Topic: "news", Body: "hi"}, false},
+		{"email", NotificationRequest{Channel: "email", Topic: "news", Body: "hi"}, true},
+		{"sms", NotificationRequest{Channel: "sms", Topic: "news", Body: "hi"}, true},
+		{"invalid topic", NotificationRequest{Channel: "push", Topic: "breaking news!", Body: "hi"}, true},
+		{"with recipient", NotificationRequest{Channel: "push", Topic: "news", Recipient: "token-1", Body: "hi"}, true},
+		{"with fallback", NotificationRequest{Channel: "push", Topic: "news", FallbackChannels: []string{"email"}, Body: "hi"}, true},
+		{"scheduled", NotificationRequest{Channel: "push", Topic: "news", ScheduledAt: &scheduled, Body: "hi"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBroadcast(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateBroadcast() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidBroadcast) {
+				t.Errorf("validateBroadcast() error = %v, want ErrInvalidBroadcast", err)
+			}
+		})
+	}
+}
+
+func TestCreateBroadcast(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	// No user preferences are read; the broadcast is stored on its own
+	mock.ExpectExec("INSERT INTO broadcasts").
+		WithArgs(dbtest.AnyArg(), "news", "Breaking", "Something happened", StatusPending, nil, nil, dbtest.AnyArg(), dbtest.AnyArg()).
+		WillReturnResult(1)
+
+	broadcast, err := s.CreateBroadcast(context.Background(), NotificationRequest{Channel: "push", Topic: "news", Subject: "Breaking", Body: "Something happened"})
+	if err != nil {
+		t.Fatalf("CreateBroadcast() error = %v", err)
+	}
+	if broadcast.Topic != "news" || broadcast.Status != StatusPending {
+		t.Errorf("CreateBroadcast() = %+v", broadcast)
+	}
+
+	published := recorder.Published(t)
+	if len(published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(published))
+	}
+	if msg := published[0]; msg.ID != broadcast.ID || msg.Topic != "news" || msg.Channel != "push" || msg.UserID != "" {
+		t.Errorf("published %+v, want the broadcast to topic news without a user", msg)
+	}
+}
+
+func TestCreateBroadcastRejectsOtherChannels(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	for _, channel := range []string{"email", "sms"} {
+		_, err := s.CreateBroadcast(context.Background(), NotificationRequest{Channel: channel, Topic: "news", Body: "hi"})
+		if !errors.Is(err, ErrInvalidBroadcast) {
+			t.Errorf("CreateBroadcast(%s) error = %v, want ErrInvalidBroadcast", channel, err)
+		}
+	}
+	if published := recorder.Published(t); len(published) != 0 {
+		t.Errorf("published %d messages, want none", len(published))
+	}
+}
@@ -0,0 +1,124 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// cacheEnabled reports whether notifications are cached in Redis
+func (s *Service) cacheEnabled() bool {
+	return s.redis != nil && s.config.Redis.NotificationTTL > 0
+}
+
+// cacheNotification stores a notification as read from the database, so hits
+// return exactly what a query would. The cache is best effort: failures are
+// logged and the database stays the source of truth.
+func (s *Service) cacheNotification(ctx context.Context, notification *Notification) {
+	if !s.cacheEnabled() {
+		return
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		s.logger.Warn("Failed to marshal notification for cache", zap.String("id", notification.ID), zap.Error(err))
+		return
+	}
+	if err := s.redis.CacheNotification(ctx, notification.ID, data, s.config.Redis.NotificationTTL); err != nil {
+		s.logger.Warn("Failed to cache notification", zap.String("id", notification.ID), zap.Error(err))
+	}
+}
+
+// cachedNotification returns the cached notification with the given ID
+func (s *Service) cachedNotification(ctx context.Context, id string) (*Notification, bool) {
+	if !s.cacheEnabled() {
+		return nil, false
+	}
+
+	data, err := s.redis.GetCachedNotification(ctx, id)
+	if err != nil {
+		if err != redis.Nil {
+			s.logger.Warn("Failed to read cached notification", zap.String("id", id), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(data, &notification); err != nil {
+		s.logger.Warn("Failed to unmarshal cached notification", zap.String("id", id), zap.Error(err))
+		return nil, false
+	}
+	return &notification, true
+}
+
+// invalidateNotification drops a notification from the cache after a change
+// that did not return the updated row
+func (s *Service) invalidateNotification(ctx context.Context, id string) {
+	if !s.cacheEnabled() {
+		return
+	}
+	if err := s.redis.DeleteCachedNotification(ctx, id); err != nil {
+		s.logger.Warn("Failed to invalidate cached notification", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// templateCacheEnabled reports whether resolved templates are cached in Redis
+func (s *Service) templateCacheEnabled() bool {
+	return s.redis != nil && s.config.Redis.TemplateTTL > 0
+}
+
+// templateCacheField identifies one resolution of a template name and channel:
+// the tenant and the requested locale decide which row is picked
+func templateCacheField(tenantID, locale string) string {
+	return tenantID + "|" + locale
+}
+
+// cachedTemplate returns the cached template resolved for the tenant and locale
+func (s *Service) cachedTemplate(ctx context.Context, name, channel, tenantID, locale string) (*NotificationTemplate, bool) {
+	if !s.templateCacheEnabled() {
+		return nil, false
+	}
+
+	data, err := s.redis.GetNotificationTemplate(ctx, name, channel, templateCacheField(tenantID, locale))
+	if err != nil {
+		if err != redis.Nil {
+			s.logger.Warn("Failed to read cached template", zap.String("template", name), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	var tmpl NotificationTemplate
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		s.logger.Warn("Failed to unmarshal cached template", zap.String("template", name), zap.Error(err))
+		return nil, false
+	}
+	return &tmpl, true
+}
+
+// cacheTemplate stores the template resolved for the tenant and locale for
+// REDIS_TEMPLATE_TTL. Failures are logged; the database stays the source of truth.
+func (s *Service) cacheTemplate(ctx context.Context, name, channel, tenantID, locale string, tmpl *NotificationTemplate) {
+	if !s.templateCacheEnabled() {
+		return
+	}
+
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		s.logger.Warn("Failed to marshal template for cache", zap.String("template", name), zap.Error(err))
+		return
+	}
+	if err := s.redis.CacheNotificationTemplate(ctx, name, channel, templateCacheField(tenantID, locale), data, s.config.Redis.TemplateTTL); err != nil {
+		s.logger.Warn("Failed to cache template", zap.String("template", name), zap.Error(err))
+	}
+}
+
+// invalidateTemplate drops every cached resolution of a template name and
+// channel, so a change applies to the next render
+func (s *Service) invalidateTemplate(ctx context.Context, name, channel string) error {
+	if !s.templateCacheEnabled() {
+		return nil
+	}
+	return s.redis.DeleteNotificationTemplate(ctx, name, channel)
+}
@@ -0,0 +1,88 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// cacheConfig enables the notification cache
+func cacheConfig() *config.Config {
+	return &config.Config{Redis: config.RedisConfig{NotificationTTL: time.Minute}}
+}
+
+func TestGetNotificationServedFromCache(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, cacheConfig())
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending}
+
+	// Only the first read reaches the database
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1").WillReturnRows(notificationRows(row))
+
+	for i := 0; i < 3; i++ {
+		notification, err := s.GetNotification(context.Background(), "n1")
+		if err != nil {
+			t.Fatalf("GetNotification() %d error = %v", i, err)
+		}
+		if notification.ID != "n1" || notification.Body != "hi" {
+			t.Errorf("GetNotification() %d = %+v, want n1", i, notification)
+		}
+	}
+	if ttl := server.TTL("notification:n1"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("cache TTL = %v, want up to a minute", ttl)
+	}
+}
+
+func TestUpdateNotificationStatusRefreshesCache(t *testing.T) {
+	s, mock, _ := newTestServiceWithRedis(t, cacheConfig())
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending}
+
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationRows(row))
+	if _, err := s.GetNotification(context.Background(), "n1"); err != nil {
+		t.Fatalf("GetNotification() error = %v", err)
+	}
+
+	row.Status = StatusSent
+	row.ExternalID = "SM123"
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationRows(row))
+	expectEvent(mock, "n1", StatusSent)
+	if err := s.UpdateNotificationStatus(context.Background(), "n1", StatusSent, "SM123", ""); err != nil {
+		t.Fatalf("UpdateNotificationStatus() error = %v", err)
+	}
+
+	// The refreshed entry is served without another query
+	notification, err := s.GetNotification(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("GetNotification() error = %v", err)
+	}
+	if notification.Status != StatusSent || notification.ExternalID != "SM123" {
+		t.Errorf("cached notification = %+v, want it sent with SM123", notification)
+	}
+}
+
+func TestGetNotificationWithoutCache(t *testing.T) {
+	s, mock, _ := newTestServiceWithRedis(t, &config.Config{})
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending}
+
+	// With a zero TTL every read queries the database
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationRows(row))
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetNotification(context.Background(), "n1"); err != nil {
+			t.Fatalf("GetNotification() %d error = %v", i, err)
+		}
+	}
+}
+
+func TestGetNotificationFallsBackWhenRedisFails(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, cacheConfig())
+	server.Fail(errors.New("ERR unavailable"))
+
+	mock.ExpectQuery("FROM notifications WHERE id = $1").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending}))
+	if _, err := s.GetNotification(context.Background(), "n1"); err != nil {
+		t.Errorf("GetNotification() error = %v, want the database row", err)
+	}
+}
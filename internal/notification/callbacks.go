@@ -0,0 +1,333 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidCallbackURL is returned for a callback URL that is malformed or
+	// given while status callbacks are not configured
+	ErrInvalidCallbackURL = errors.New("invalid callback URL")
+	// ErrCallbackNotFound is returned when retrying a callback that is not dead-lettered
+	ErrCallbackNotFound = errors.New("failed callback not found")
+)
+
+// Headers of status callbacks. The signature is the hex HMAC-SHA256, keyed
+// with the callback signing secret, of the timestamp, a dot and the body.
+const (
+	CallbackSignatureHeader = "X-Notification-Signature"
+	CallbackTimestampHeader = "X-Notification-Timestamp"
+)
+
+const (
+	// CallbackBatchSize caps how many callbacks are delivered per run
+	CallbackBatchSize = 50
+	// callbackRetryDelay is how long a failed callback waits, per attempt
+	callbackRetryDelay = 30 * time.Second
+	// callbackMaxRetryDelay caps the wait between attempts of a callback
+	callbackMaxRetryDelay = time.Hour
+	// maxCallbackURLLength bounds the stored callback URL
+	maxCallbackURLLength = 2048
+)
+
+// StatusCallback is the payload POSTed to a notification's callback URL when
+// its status changes
+type StatusCallback struct {
+	NotificationID string             `json:"notification_id"`
+	Status         NotificationStatus `json:"status"`
+	ExternalID     string             `json:"external_id,omitempty"`
+	Detail         string             `json:"detail,omitempty"`
+	Timestamp      time.Time          `json:"timestamp"`
+}
+
+// FailedCallback is a callback that exhausted its attempts and was dead-lettered
+type FailedCallback struct {
+	ID             int64          `json:"id"`
+	NotificationID string         `json:"notification_id"`
+	URL            string         `json:"url"`
+	Payload        StatusCallback `json:"payload"`
+	Attempts       int            `json:"attempts"`
+	LastError      string         `json:"last_error,omitempty"`
+	FailedAt       time.Time      `json:"failed_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// CallbackResult counts the outcomes of a callback delivery run
+type CallbackResult struct {
+	Delivered    int
+	Retried      int
+	DeadLettered int
+}
+
+// callbackEntry is a callback waiting to be delivered
+type callbackEntry struct {
+	id             int64
+	notificationID string
+	url            string
+	payload        []byte
+	attempts       int
+}
+
+// validateCallbackURL checks a request's callback URL. Callbacks are signed,
+// so they are only accepted once a signing secret is configured.
+func (s *Service) validateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if s.config.Callbacks.SigningSecret == "" {
+		return fmt.Errorf("%w: status callbacks are not enabled", ErrInvalidCallbackURL)
+	}
+	if len(callbackURL) > maxCallbackURLLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidCallbackURL, maxCallbackURLLength)
+	}
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("%w: must be an absolute http or https URL", ErrInvalidCallbackURL)
+	}
+	return nil
+}
+
+// enqueueCallback queues a status callback for the notification if it has a
+// callback URL. Like the status history, a failure to queue is logged rather
+// than failing the status change.
+func (s *Service) enqueueCallback(ctx context.Context, id string, status NotificationStatus, externalID, detail string) {
+	payload, err := json.Marshal(StatusCallback{
+		NotificationID: id,
+		Status:         status,
+		ExternalID:     externalID,
+		Detail:         detail,
+		Timestamp:      time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Error("Failed to marshal status callback", zap.String("id", id), zap.Error(err))
+		return
+	}
+
+	query := `
+		INSERT INTO status_callbacks (notification_id, url, payload)
+		SELECT id, callback_url, $2 FROM notifications
+		WHERE id = $1 AND callback_url IS NOT NULL`
+	if _, err := s.db.ExecContext(ctx, query, id, payload); err != nil {
+		s.logger.Error("Failed to queue status callback",
+			zap.String("id", id),
+			zap.String("status", string(status)),
+			zap.Error(err),
+		)
+	}
+}
+
+// DeliverCallbacks POSTs the status callbacks that are due, signed with the
+// callback signing secret. A notification's callbacks are delivered in order:
+// one is only sent once the previous one was delivered or dead-lettered. Rows
+// are locked while delivered, so several API instances can deliver
+// concurrently. Failed callbacks are retried with a growing delay and
+// dead-lettered after the configured number of attempts.
+func (s *Service) DeliverCallbacks(ctx context.Context) (CallbackResult, error) {
+	var result CallbackResult
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT c.id, c.notification_id, c.url, c.payload, c.attempts FROM status_callbacks c
+		WHERE c.delivered_at IS NULL AND c.failed_at IS NULL AND c.available_at <= NOW()
+		  AND NOT EXISTS (
+			SELECT 1 FROM status_callbacks p
+			WHERE p.notification_id = c.notification_id AND p.id < c.id
+			  AND p.delivered_at IS NULL AND p.failed_at IS NULL
+		  )
+		ORDER BY c.id
+		LIMIT $1
+		FOR UPDATE OF c SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, CallbackBatchSize)
+	if err != nil {
+		return result, fmt.Errorf("failed to read status callbacks: %w", err)
+	}
+
+	var entries []callbackEntry
+	for rows.Next() {
+		var entry callbackEntry
+		if err := rows.Scan(&entry.id, &entry.notificationID, &entry.url, &entry.payload, &entry.attempts); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to scan status callback: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read status callbacks: %w", err)
+	}
+
+	maxAttempts := s.config.Callbacks.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for _, entry := range entries {
+		err := s.postCallback(ctx, entry)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, `UPDATE status_callbacks SET delivered_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, entry.id); err != nil {
+				return result, fmt.Errorf("failed to mark status callback as delivered: %w", err)
+			}
+			result.Delivered++
+			continue
+		}
+
+		attempts := entry.attempts + 1
+		if attempts >= maxAttempts {
+			s.logger.Error("Status callback failed, dead-lettering it",
+				zap.Int64("callback_id", entry.id),
+				zap.String("id", entry.notificationID),
+				zap.Int("attempts", attempts),
+				zap.Error(err),
+			)
+			if _, err := tx.ExecContext(ctx, `UPDATE status_callbacks SET failed_at = NOW(), attempts = $2, last_error = $3 WHERE id = $1`, entry.id, attempts, err.Error()); err != nil {
+				return result, fmt.Errorf("failed to dead-letter status callback: %w", err)
+			}
+			result.DeadLettered++
+			continue
+		}
+
+		s.logger.Warn("Status callback failed, retrying later",
+			zap.Int64("callback_id", entry.id),
+			zap.String("id", entry.notificationID),
+			zap.Int("attempts", attempts),
+			zap.Error(err),
+		)
+		delay := callbackRetryDelay * time.Duration(attempts)
+		if delay > callbackMaxRetryDelay {
+			delay = callbackMaxRetryDelay
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE status_callbacks SET attempts = $2, last_error = $3, available_at = $4 WHERE id = $1`, entry.id, attempts, err.Error(), time.Now().Add(delay)); err != nil {
+			return result, fmt.Errorf("failed to record status callback failure: %w", err)
+		}
+		result.Retried++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return CallbackResult{}, fmt.Errorf("failed to commit status callbacks: %w", err)
+	}
+	return result, nil
+}
+
+// postCallback makes one delivery attempt of a callback. Any 2xx response
+// counts as delivered.
+func (s *Service) postCallback(ctx context.Context, entry callbackEntry) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, entry.url, bytes.NewReader(entry.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTimestampHeader, timestamp)
+	req.Header.Set(CallbackSignatureHeader, SignCallback(s.config.Callbacks.SigningSecret, timestamp, entry.payload))
+
+	resp, err := s.callbacks.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignCallback returns the signature of a callback body sent at timestamp, so
+// receivers can verify it with the shared secret
+func SignCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ListFailedCallbacks returns up to limit dead-lettered callbacks, newest first
+func (s *Service) ListFailedCallbacks(ctx context.Context, limit int) ([]FailedCallback, error) {
+	query := `
+		SELECT id, notification_id, url, payload, attempts, last_error, failed_at, created_at
+		FROM status_callbacks
+		WHERE failed_at IS NOT NULL
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	callbacks := []FailedCallback{}
+	for rows.Next() {
+		var callback FailedCallback
+		var payload []byte
+		var lastError sql.NullString
+		if err := rows.Scan(&callback.ID, &callback.NotificationID, &callback.URL, &payload, &callback.Attempts, &lastError, &callback.FailedAt, &callback.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan failed callback: %w", err)
+		}
+		if err := json.Unmarshal(payload, &callback.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode failed callback %d: %w", callback.ID, err)
+		}
+		callback.LastError = lastError.String
+		callbacks = append(callbacks, callback)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list failed callbacks: %w", err)
+	}
+	return callbacks, nil
+}
+
+// RetryCallback moves a dead-lettered callback back to delivery with a fresh
+// set of attempts
+func (s *Service) RetryCallback(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE status_callbacks SET failed_at = NULL, attempts = 0, available_at = NOW()
+		WHERE id = $1 AND failed_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry callback: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retry callback: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w: %d", ErrCallbackNotFound, id)
+	}
+	s.logger.Info("Retrying dead-lettered status callback", zap.Int64("callback_id", id))
+	return nil
+}
+
+// PurgeCallbacks deletes callbacks delivered more than olderThan ago and
+// returns the number deleted
+func (s *Service) PurgeCallbacks(ctx context.Context, olderThan time.Duration) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM status_callbacks WHERE delivered_at < $1`, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge status callbacks: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge status callbacks: %w", err)
+	}
+	return int(purged), nil
+}
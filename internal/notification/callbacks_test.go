@@ -0,0 +1,207 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+// callbackServer records the callbacks it receives, answering with status
+type callbackServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newCallbackServer(t *testing.T, status int) *callbackServer {
+	t.Helper()
+	server := &callbackServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		server.requests = append(server.requests, r)
+		server.bodies = append(server.bodies, body)
+		server.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// callbackConfig returns a config signing callbacks, dead-lettering them
+// after maxAttempts
+func callbackConfig(maxAttempts int) *config.Config {
+	return &config.Config{Callbacks: config.CallbacksConfig{SigningSecret: "callback-secret", Timeout: time.Second, MaxAttempts: maxAttempts}}
+}
+
+// expectDueCallbacks expects the due callbacks to be selected, returning one
+// to url with payload after attempts
+func expectDueCallbacks(mock *dbtest.Mock, url string, payload []byte, attempts int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM status_callbacks c WHERE c.delivered_at IS NULL AND c.failed_at IS NULL").WithArgs(CallbackBatchSize).
+		WillReturnRows(dbtest.NewRows("id", "notification_id", "url", "payload", "attempts").AddRow(7, "n1", url, payload, attempts))
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	s, _ := newTestService(t, callbackConfig(3))
+	for callbackURL, valid := range map[string]bool{
+		"":                                  true,
+		"https://example.com/hooks/status":  true,
+		"http://example.com:8080/callbacks": true,
+		"ftp://example.com/status":          false,
+		"/relative/path":                    false,
+		"https://":                          false,
+		"https://example.com/" + strings.Repeat("a", maxCallbackURLLength): false,
+	} {
+		err := s.validateCallbackURL(callbackURL)
+		if valid != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidCallbackURL)) {
+			t.Errorf("validateCallbackURL(%.40q) error = %v, want valid %v", callbackURL, err, valid)
+		}
+	}
+
+	// Callback URLs cannot be signed without a secret
+	unsigned, _ := newTestService(t, nil)
+	if err := unsigned.validateCallbackURL("https://example.com/hooks/status"); !errors.Is(err, ErrInvalidCallbackURL) {
+		t.Errorf("validateCallbackURL() without a secret error = %v, want ErrInvalidCallbackURL", err)
+	}
+}
+
+func TestStatusChangeQueuesCallback(t *testing.T) {
+	s, mock := newTestService(t, callbackConfig(3))
+	mock.ExpectQuery("UPDATE notifications").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusDelivered, CallbackURL: "https://example.com/hooks"}))
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs("n1", "delivered", "ext-1", dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks (notification_id, url, payload) SELECT id, callback_url, $2 FROM notifications WHERE id = $1 AND callback_url IS NOT NULL").
+		WithArgs("n1", dbtest.AnyArg()).WillReturnResult(1)
+
+	if err := s.UpdateNotificationStatus(context.Background(), "n1", StatusDelivered, "ext-1", ""); err != nil {
+		t.Fatalf("UpdateNotificationStatus() error = %v", err)
+	}
+}
+
+func TestDeliverCallbacksSigned(t *testing.T) {
+	server := newCallbackServer(t, http.StatusNoContent)
+	s, mock := newTestService(t, callbackConfig(3))
+	payload := []byte(`{"notification_id":"n1","status":"delivered","external_id":"ext-1","timestamp":"2024-05-01T12:00:00Z"}`)
+
+	expectDueCallbacks(mock, server.URL+"/hooks", payload, 0)
+	mock.ExpectExec("UPDATE status_callbacks SET delivered_at = NOW()").WithArgs(7).WillReturnResult(1)
+	mock.ExpectCommit()
+
+	result, err := s.DeliverCallbacks(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverCallbacks() error = %v", err)
+	}
+	if result != (CallbackResult{Delivered: 1}) {
+		t.Errorf("DeliverCallbacks() = %+v, want 1 delivered", result)
+	}
+
+	if len(server.requests) != 1 {
+		t.Fatalf("received %d callbacks, want 1", len(server.requests))
+	}
+	req, body := server.requests[0], server.bodies[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/hooks" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("callback = %s %s (%s), want a JSON POST to /hooks", req.Method, req.URL.Path, req.Header.Get("Content-Type"))
+	}
+	timestamp := req.Header.Get(CallbackTimestampHeader)
+	if want := SignCallback("callback-secret", timestamp, payload); timestamp == "" || req.Header.Get(CallbackSignatureHeader) != want {
+		t.Errorf("signature = %q at %q, want %q", req.Header.Get(CallbackSignatureHeader), timestamp, want)
+	}
+	var callback StatusCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		t.Fatalf("failed to decode callback: %v", err)
+	}
+	if callback.NotificationID != "n1" || callback.Status != StatusDelivered || callback.ExternalID != "ext-1" {
+		t.Errorf("callback = %+v, want n1 delivered", callback)
+	}
+}
+
+func TestSignCallback(t *testing.T) {
+	body := []byte(`{"notification_id":"n1"}`)
+	signature := SignCallback("secret", "1714564800", body)
+	if len(signature) != 64 {
+		t.Errorf("signature = %q, want hex SHA-256", signature)
+	}
+	if SignCallback("secret", "1714564800", body) != signature {
+		t.Error("signature is not deterministic")
+	}
+	for name, other := range map[string]string{
+		"secret":    SignCallback("other", "1714564800", body),
+		"timestamp": SignCallback("secret", "1714564801", body),
+		"body":      SignCallback("secret", "1714564800", []byte(`{"notification_id":"n2"}`)),
+	} {
+		if other == signature {
+			t.Errorf("changing the %s kept the signature", name)
+		}
+	}
+}
+
+func TestDeliverCallbacksRetriesFailures(t *testing.T) {
+	server := newCallbackServer(t, http.StatusInternalServerError)
+	s, mock := newTestService(t, callbackConfig(3))
+
+	// The first failure is retried later
+	expectDueCallbacks(mock, server.URL, []byte(`{}`), 0)
+	mock.ExpectExec("UPDATE status_callbacks SET attempts = $2, last_error = $3, available_at = $4 WHERE id = $1").
+		WithArgs(7, 1, "callback endpoint returned status 500", dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectCommit()
+
+	result, err := s.DeliverCallbacks(context.Background())
+	if err != nil || result != (CallbackResult{Retried: 1}) {
+		t.Fatalf("DeliverCallbacks() = %+v, %v, want 1 retried", result, err)
+	}
+
+	// The last attempt dead-letters it
+	expectDueCallbacks(mock, server.URL, []byte(`{}`), 2)
+	mock.ExpectExec("UPDATE status_callbacks SET failed_at = NOW(), attempts = $2, last_error = $3 WHERE id = $1").
+		WithArgs(7, 3, "callback endpoint returned status 500").WillReturnResult(1)
+	mock.ExpectCommit()
+
+	result, err = s.DeliverCallbacks(context.Background())
+	if err != nil || result != (CallbackResult{DeadLettered: 1}) {
+		t.Fatalf("DeliverCallbacks() = %+v, %v, want 1 dead-lettered", result, err)
+	}
+	if len(server.requests) != 2 {
+		t.Errorf("received %d attempts, want 2", len(server.requests))
+	}
+}
+
+func TestListFailedCallbacks(t *testing.T) {
+	s, mock := newTestService(t, callbackConfig(3))
+	failedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM status_callbacks WHERE failed_at IS NOT NULL ORDER BY failed_at DESC, id DESC LIMIT $1").WithArgs(10).
+		WillReturnRows(dbtest.NewRows("id", "notification_id", "url", "payload", "attempts", "last_error", "failed_at", "created_at").
+			AddRow(7, "n1", "https://example.com/hooks", []byte(`{"notification_id":"n1","status":"failed"}`), 3, "callback endpoint returned status 500", failedAt, failedAt))
+
+	callbacks, err := s.ListFailedCallbacks(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("ListFailedCallbacks() error = %v", err)
+	}
+	if len(callbacks) != 1 || callbacks[0].Payload.Status != StatusFailed || callbacks[0].Attempts != 3 || callbacks[0].LastError == "" {
+		t.Errorf("ListFailedCallbacks() = %+v, want the dead-lettered callback", callbacks)
+	}
+}
+
+func TestRetryCallback(t *testing.T) {
+	s, mock := newTestService(t, callbackConfig(3))
+	mock.ExpectExec("UPDATE status_callbacks SET failed_at = NULL, attempts = 0").WithArgs(7).WillReturnResult(1)
+	mock.ExpectExec("UPDATE status_callbacks SET failed_at = NULL, attempts = 0").WithArgs(8).WillReturnResult(0)
+
+	if err := s.RetryCallback(context.Background(), 7); err != nil {
+		t.Errorf("RetryCallback(7) error = %v", err)
+	}
+	if err := s.RetryCallback(context.Background(), 8); !errors.Is(err, ErrCallbackNotFound) {
+		t.Errorf("RetryCallback(8) error = %v, want ErrCallbackNotFound", err)
+	}
+}
@@ -0,0 +1,159 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RecipientAllDevices is stored as the recipient of a push notification that
+// was created without one for a user with registered devices. The push worker
+// resolves it to every active token of the user when sending.
+const RecipientAllDevices = "user_devices"
+
+// ErrUserNotFound is returned when registering a device for an unknown user
+var ErrUserNotFound = errors.New("user not found")
+
+// UserDevice is a push token registered for one of a user's devices
+type UserDevice struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Token     string    `json:"token" db:"token"`
+	Platform  string    `json:"platform,omitempty" db:"platform"` // ios, android or web
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PushTokenRequest registers or refreshes a user's push token
+type PushTokenRequest struct {
+	Token    string `json:"token" validate:"required,max=500"`
+	Platform string `json:"platform,omitempty" validate:"omitempty,oneof=ios android web"`
+	// PreviousToken is the token this one replaces when FCM rotated it; it is deactivated
+	PreviousToken string `json:"previous_token,omitempty" validate:"omitempty,max=500"`
+}
+
+// RegisterPushToken upserts a device token for the user in the request's
+// tenant. A token moves to the user registering it within the tenant, so a
+// device that changes hands is not notified for its previous owner; devices
+// are never shared between tenants. Without a tenant, the latest token is also
+// kept in users.push_token.
+func (s *Service) RegisterPushToken(ctx context.Context, userID string, req PushTokenRequest) (*UserDevice, error) {
+	tenantID := TenantFromContext(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// users.push_token is not tenant-scoped, so only requests without a
+	// tenant update it
+	var exists bool
+	if tenantID == "" {
+		result, err := tx.ExecContext(ctx, `UPDATE users SET push_token = $2, updated_at = NOW() WHERE id = $1`, userID, req.Token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update user push token: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		exists = err != nil || rows > 0
+	} else {
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check user: %w", err)
+		}
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+
+	if req.PreviousToken != "" && req.PreviousToken != req.Token {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE user_devices SET active = false, updated_at = NOW()
+			WHERE user_id = $1 AND token = $2 AND tenant_id IS NOT DISTINCT FROM NULLIF($3, '')`, userID, req.PreviousToken, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deactivate previous push token: %w", err)
+		}
+	}
+
+	device := &UserDevice{}
+	query := `
+		INSERT INTO user_devices (user_id, token, platform, active, tenant_id)
+		VALUES ($1, $2, $3, true, $4)
+		ON CONFLICT (COALESCE(tenant_id, ''), token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			platform = COALESCE(EXCLUDED.platform, user_devices.platform),
+			active = true,
+			updated_at = NOW()
+		RETURNING id, user_id, token, COALESCE(platform, ''), active, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, userID, req.Token, nullIfEmpty(req.Platform), nullIfEmpty(tenantID)).Scan(
+		&device.ID, &device.UserID, &device.Token, &device.Platform, &device.Active,
+		&device.CreatedAt, &device.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register push token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit push token: %w", err)
+	}
+
+	s.logger.Info("Registered push token",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID),
+		zap.String("device_id", device.ID),
+		zap.String("platform", device.Platform),
+	)
+	return device, nil
+}
+
+// GetActivePushTokens returns the tokens of all the user's active devices in
+// the request's tenant, most recently registered first
+func (s *Service) GetActivePushTokens(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT token FROM user_devices
+		WHERE user_id = $1 AND active = true AND tenant_id IS NOT DISTINCT FROM NULLIF($2, '')
+		ORDER BY updated_at DESC`, userID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get push tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, fmt.Errorf("failed to scan push token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeactivatePushToken stops sending to a token in the request's tenant, e.g.
+// once FCM reports it as unregistered
+func (s *Service) DeactivatePushToken(ctx context.Context, token string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE user_devices SET active = false, updated_at = NOW()
+		WHERE token = $1 AND tenant_id IS NOT DISTINCT FROM NULLIF($2, '')`, token, TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to deactivate push token: %w", err)
+	}
+	return nil
+}
+
+// hasActiveDevices reports whether the user has at least one active push token
+// in the request's tenant
+func (s *Service) hasActiveDevices(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_devices
+		WHERE user_id = $1 AND active = true AND tenant_id IS NOT DISTINCT FROM NULLIF($2, ''))`, userID, TenantFromContext(ctx)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user devices: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,104 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+// deviceRow returns the row returned when device is registered
+func deviceRow(device UserDevice) *dbtest.Rows {
+	return dbtest.NewRows("id", "user_id", "token", "platform", "active", "created_at", "updated_at").
+		AddRow(device.ID, device.UserID, device.Token, device.Platform, true, time.Now(), time.Now())
+}
+
+// expectRegister expects token to be registered for user-1 without a tenant
+func expectRegister(mock *dbtest.Mock, id, token, platform string) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET push_token = $2").WithArgs("user-1", token).WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO user_devices").WithArgs("user-1", token, nullIfEmpty(platform), nil).
+		WillReturnRows(deviceRow(UserDevice{ID: id, UserID: "user-1", Token: token, Platform: platform}))
+	mock.ExpectCommit()
+}
+
+func TestRegisterPushToken(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	expectRegister(mock, "d1", "token-1", "ios")
+
+	device, err := s.RegisterPushToken(context.Background(), "user-1", PushTokenRequest{Token: "token-1", Platform: "ios"})
+	if err != nil {
+		t.Fatalf("RegisterPushToken() error = %v", err)
+	}
+	if device.ID != "d1" || device.Token != "token-1" || device.Platform != "ios" || !device.Active {
+		t.Errorf("RegisterPushToken() = %+v", device)
+	}
+}
+
+func TestRegisterPushTokenMultipleDevices(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	ctx := context.Background()
+	expectRegister(mock, "d1", "token-phone", "ios")
+	expectRegister(mock, "d2", "token-tablet", "android")
+
+	for _, req := range []PushTokenRequest{{Token: "token-phone", Platform: "ios"}, {Token: "token-tablet", Platform: "android"}} {
+		if _, err := s.RegisterPushToken(ctx, "user-1", req); err != nil {
+			t.Fatalf("RegisterPushToken(%s) error = %v", req.Token, err)
+		}
+	}
+
+	// Both devices receive the user's push notifications
+	mock.ExpectQuery("SELECT token FROM user_devices").WithArgs("user-1", "").
+		WillReturnRows(dbtest.NewRows("token").AddRow("token-tablet").AddRow("token-phone"))
+	tokens, err := s.GetActivePushTokens(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetActivePushTokens() error = %v", err)
+	}
+	if want := []string{"token-tablet", "token-phone"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("GetActivePushTokens() = %v, want %v", tokens, want)
+	}
+}
+
+func TestRegisterPushTokenReplacesPreviousToken(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET push_token = $2").WithArgs("user-1", "token-2").WillReturnResult(1)
+	mock.ExpectExec("UPDATE user_devices SET active = false").WithArgs("user-1", "token-1", "").WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO user_devices").WithArgs("user-1", "token-2", nil, nil).
+		WillReturnRows(deviceRow(UserDevice{ID: "d1", UserID: "user-1", Token: "token-2"}))
+	mock.ExpectCommit()
+
+	if _, err := s.RegisterPushToken(context.Background(), "user-1", PushTokenRequest{Token: "token-2", PreviousToken: "token-1"}); err != nil {
+		t.Fatalf("RegisterPushToken() error = %v", err)
+	}
+}
+
+func TestRegisterPushTokenUnknownUser(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET push_token = $2").WithArgs("ghost", "token-1").WillReturnResult(0)
+	mock.ExpectRollback()
+
+	if _, err := s.RegisterPushToken(context.Background(), "ghost", PushTokenRequest{Token: "token-1"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("RegisterPushToken() error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestRegisterPushTokenInTenant(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	ctx := WithTenant(context.Background(), "acme")
+
+	// users.push_token is shared across tenants, so it is left alone
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)").WithArgs("user-1").WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectQuery("INSERT INTO user_devices").WithArgs("user-1", "token-1", nil, "acme").
+		WillReturnRows(deviceRow(UserDevice{ID: "d1", UserID: "user-1", Token: "token-1"}))
+	mock.ExpectCommit()
+
+	if _, err := s.RegisterPushToken(ctx, "user-1", PushTokenRequest{Token: "token-1"}); err != nil {
+		t.Fatalf("RegisterPushToken() error = %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidDryRun is returned for requests a dry run cannot validate
+var ErrInvalidDryRun = errors.New("invalid dry run")
+
+// DryRunResult is what creating a notification request would produce
+type DryRunResult struct {
+	// Notification is the notification that would be stored, with its channel,
+	// recipient and rendered content resolved. It has no ID.
+	Notification *Notification
+	// RequestedChannel is the channel of the request, which differs from the
+	// notification's when the user would be reached on a fallback channel
+	RequestedChannel string
+	// Scheduled reports whether the notification would be held until its
+	// scheduled time instead of queued right away
+	Scheduled bool
+}
+
+// DryRunNotification runs the checks CreateNotification runs on req, resolving
+// the channel and recipient and rendering the content, without storing or
+// queueing anything. It returns the same errors CreateNotification would.
+func (s *Service) DryRunNotification(ctx context.Context, req NotificationRequest) (*DryRunResult, error) {
+	if len(req.Recipients) > 0 || req.Topic != "" {
+		return nil, fmt.Errorf("%w: dry runs validate a single recipient", ErrInvalidDryRun)
+	}
+
+	pending, err := s.prepareNotification(ctx, req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	notification := pending.notification
+	notification.ID = ""
+	return &DryRunResult{
+		Notification:     notification,
+		RequestedChannel: req.Channel,
+		Scheduled:        isHeld(notification, time.Now()),
+	}, nil
+}
+
+// isHeld reports whether notification is held until its scheduled time rather
+// than queued when it is created at now
+func isHeld(notification *Notification, now time.Time) bool {
+	return notification.ScheduledAt != nil && !notification.ScheduledAt.Before(now)
+}
@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestDryRunNotification(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	// Only the preferences are read; nothing is stored or queued
+	expectPreferences(mock, "user-1")
+	result, err := s.DryRunNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi",
+	})
+	if err != nil {
+		t.Fatalf("DryRunNotification() error = %v", err)
+	}
+	notification := result.Notification
+	if notification.ID != "" || notification.Channel != "sms" || notification.Recipient != "+15551234567" || notification.Body != "hi" {
+		t.Errorf("Notification = %+v, want the resolved SMS without an ID", notification)
+	}
+	if result.RequestedChannel != "sms" || result.Scheduled {
+		t.Errorf("result = %+v, want sms queued right away", result)
+	}
+}
+
+func TestDryRunNotificationScheduled(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	scheduledAt := time.Now().Add(time.Hour)
+
+	expectPreferences(mock, "user-1")
+	result, err := s.DryRunNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", ScheduledAt: &scheduledAt,
+	})
+	if err != nil {
+		t.Fatalf("DryRunNotification() error = %v", err)
+	}
+	if !result.Scheduled {
+		t.Error("Scheduled = false, want the notification held until scheduled_at")
+	}
+}
+
+func TestDryRunNotificationRejected(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	// A request create would block fails the same way
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "sms", Enabled: false})
+	_, err := s.DryRunNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi",
+	})
+	if !errors.Is(err, ErrNotificationsDisabled) {
+		t.Errorf("DryRunNotification() error = %v, want ErrNotificationsDisabled", err)
+	}
+
+	for _, req := range []NotificationRequest{
+		{UserID: "user-1", Channel: "sms", Recipients: []string{"+15551234567", "+15557654321"}, Body: "hi"},
+		{Channel: "push", Topic: "news", Body: "hi"},
+	} {
+		if _, err := s.DryRunNotification(context.Background(), req); !errors.Is(err, ErrInvalidDryRun) {
+			t.Errorf("DryRunNotification(%+v) error = %v, want ErrInvalidDryRun", req, err)
+		}
+	}
+}
+
+func TestIsHeld(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	tests := []struct {
+		name        string
+		scheduledAt *time.Time
+		want        bool
+	}{
+		{"unscheduled", nil, false},
+		{"due", &past, false},
+		{"now", &now, true},
+		{"later", &future, true},
+	}
+	for _, tt := range tests {
+		if got := isHeld(&Notification{ScheduledAt: tt.scheduledAt}, now); got != tt.want {
+			t.Errorf("%s: isHeld() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
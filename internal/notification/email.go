@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metadata keys that control SendGrid tracking for a single email, overriding
+// the channel configuration
+const (
+	MetadataClickTracking = "click_tracking" // "true" or "false"
+	MetadataOpenTracking  = "open_tracking"  // "true" or "false"
+	MetadataCategories    = "categories"     // comma-separated, added to the configured categories
+)
+
+// MaxEmailCategories is the most categories SendGrid accepts on one email
+const MaxEmailCategories = 10
+
+// ErrInvalidEmailOptions is returned when email metadata options are malformed
+var ErrInvalidEmailOptions = errors.New("invalid email options")
+
+// ValidateEmailOptions checks the tracking options in metadata
+func ValidateEmailOptions(metadata map[string]string) error {
+	for _, key := range []string{MetadataClickTracking, MetadataOpenTracking} {
+		if value, ok := metadata[key]; ok {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("%w: %s must be true or false, got %q", ErrInvalidEmailOptions, key, value)
+			}
+		}
+	}
+
+	if categories := ParseCategories(metadata[MetadataCategories]); len(categories) > MaxEmailCategories {
+		return fmt.Errorf("%w: at most %d categories are allowed, got %d", ErrInvalidEmailOptions, MaxEmailCategories, len(categories))
+	}
+	return nil
+}
+
+// ParseCategories splits a comma-separated category list, dropping blanks
+func ParseCategories(value string) []string {
+	return splitList(value)
+}
+
+// splitList splits a comma-separated metadata value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
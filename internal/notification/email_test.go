@@ -0,0 +1,38 @@
+package notification
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateEmailOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"tracking", map[string]string{MetadataClickTracking: "true", MetadataOpenTracking: "false"}, false},
+		{"invalid click tracking", map[string]string{MetadataClickTracking: "sometimes"}, true},
+		{"invalid open tracking", map[string]string{MetadataOpenTracking: "yes please"}, true},
+		{"ten categories", map[string]string{MetadataCategories: strings.Repeat("c,", 10)}, false},
+		{"too many categories", map[string]string{MetadataCategories: "a,b,c,d,e,f,g,h,i,j,k"}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateEmailOptions(tt.metadata)
+		if tt.wantErr != errors.Is(err, ErrInvalidEmailOptions) {
+			t.Errorf("%s: ValidateEmailOptions() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	if got := ParseCategories(" news, ,promo,"); !reflect.DeepEqual(got, []string{"news", "promo"}) {
+		t.Errorf("ParseCategories() = %v, want [news promo]", got)
+	}
+	if got := ParseCategories(""); got != nil {
+		t.Errorf("ParseCategories(\"\") = %v, want nil", got)
+	}
+}
@@ -0,0 +1,160 @@
+package notification
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// ErrDecryption is returned when a stored field cannot be decrypted, e.g.
+// because its key version is no longer configured
+var ErrDecryption = errors.New("failed to decrypt notification field")
+
+// fieldCipher encrypts notification bodies and recipients at rest with
+// AES-256-GCM. Each row records the version of the key it was encrypted with,
+// so keys can be rotated: new rows use the active key while rows written with
+// an older key stay readable as long as that key is configured. A nil
+// fieldCipher leaves fields in plaintext.
+type fieldCipher struct {
+	active int
+	keys   map[int]cipher.AEAD
+}
+
+// newFieldCipher builds the cipher from "version:base64 key" entries. It
+// returns nil when no keys are configured.
+func newFieldCipher(cfg config.EncryptionConfig) (*fieldCipher, error) {
+	c := &fieldCipher{keys: make(map[int]cipher.AEAD)}
+	for _, entry := range cfg.Keys {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionText, encoded, ok := strings.Cut(entry, ":")
+		version, err := strconv.Atoi(versionText)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid encryption key entry, want version:base64 key with a positive version")
+		}
+		if _, exists := c.keys[version]; exists {
+			return nil, fmt.Errorf("duplicate encryption key version %d", version)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key version %d must be 32 bytes, base64-encoded", version)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d: %w", version, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d: %w", version, err)
+		}
+
+		c.keys[version] = aead
+		if version > c.active {
+			c.active = version
+		}
+	}
+
+	if len(c.keys) == 0 {
+		if cfg.KeyVersion != 0 {
+			return nil, fmt.Errorf("encryption key version %d is set but no keys are configured", cfg.KeyVersion)
+		}
+		return nil, nil
+	}
+	if cfg.KeyVersion != 0 {
+		if _, ok := c.keys[cfg.KeyVersion]; !ok {
+			return nil, fmt.Errorf("active encryption key version %d is not configured", cfg.KeyVersion)
+		}
+		c.active = cfg.KeyVersion
+	}
+	return c, nil
+}
+
+// keyVersion returns the version new fields are encrypted with, 0 when
+// encryption is disabled
+func (c *fieldCipher) keyVersion() int {
+	if c == nil {
+		return 0
+	}
+	return c.active
+}
+
+// encrypt seals plaintext with the active key. The ciphertext is bound to
+// aad, the notification ID and field name, so it cannot be copied to another
+// row or field. It returns plaintext unchanged when encryption is disabled.
+func (c *fieldCipher) encrypt(plaintext, aad string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+
+	aead := c.keys[c.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a field encrypted with key version, returning it unchanged
+// when version is 0 (stored before encryption was enabled)
+func (c *fieldCipher) decrypt(ciphertext string, version int, aad string) (string, error) {
+	if version == 0 {
+		return ciphertext, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("%w: encryption is not configured (key version %d)", ErrDecryption, version)
+	}
+	aead, ok := c.keys[version]
+	if !ok {
+		return "", fmt.Errorf("%w: key version %d is not configured", ErrDecryption, version)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed ciphertext", ErrDecryption)
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	return string(plaintext), nil
+}
+
+// encryptFields encrypts a notification's recipient and body for storage.
+// It returns them with the key version to store alongside.
+func (c *fieldCipher) encryptFields(id, recipient, body string) (string, string, int, error) {
+	encryptedRecipient, err := c.encrypt(recipient, id+":recipient")
+	if err != nil {
+		return "", "", 0, err
+	}
+	encryptedBody, err := c.encrypt(body, id+":body")
+	if err != nil {
+		return "", "", 0, err
+	}
+	return encryptedRecipient, encryptedBody, c.keyVersion(), nil
+}
+
+// decryptFields reverses encryptFields
+func (c *fieldCipher) decryptFields(id, recipient, body string, version int) (string, string, error) {
+	decryptedRecipient, err := c.decrypt(recipient, version, id+":recipient")
+	if err != nil {
+		return "", "", err
+	}
+	decryptedBody, err := c.decrypt(body, version, id+":body")
+	if err != nil {
+		return "", "", err
+	}
+	return decryptedRecipient, decryptedBody, nil
+}
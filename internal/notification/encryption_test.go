@@ -0,0 +1,211 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+)
+
+// testKey returns a "version:base64 key" entry of a 32-byte key filled with b
+func testKey(version string, b byte) string {
+	return version + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// newTestCipher returns a cipher for keys, failing the test on error
+func newTestCipher(t *testing.T, keyVersion int, keys ...string) *fieldCipher {
+	t.Helper()
+	c, err := newFieldCipher(config.EncryptionConfig{Keys: keys, KeyVersion: keyVersion})
+	if err != nil {
+		t.Fatalf("newFieldCipher() error = %v", err)
+	}
+	return c
+}
+
+func TestNewFieldCipher(t *testing.T) {
+	if c := newTestCipher(t, 0); c != nil {
+		t.Errorf("newFieldCipher() without keys = %v, want nil", c)
+	}
+	if c := newTestCipher(t, 0, testKey("1", 1), testKey("3", 3), " "); c.keyVersion() != 3 {
+		t.Errorf("keyVersion() = %d, want the highest version 3", c.keyVersion())
+	}
+	if c := newTestCipher(t, 1, testKey("1", 1), testKey("2", 2)); c.keyVersion() != 1 {
+		t.Errorf("keyVersion() = %d, want the configured version 1", c.keyVersion())
+	}
+
+	for name, cfg := range map[string]config.EncryptionConfig{
+		"missing version":     {Keys: []string{base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))}},
+		"zero version":        {Keys: []string{testKey("0", 1)}},
+		"duplicate version":   {Keys: []string{testKey("1", 1), testKey("1", 2)}},
+		"short key":           {Keys: []string{"1:" + base64.StdEncoding.EncodeToString([]byte("short"))}},
+		"not base64":          {Keys: []string{"1:not base64!"}},
+		"unknown key version": {Keys: []string{testKey("1", 1)}, KeyVersion: 2},
+		"version without key": {KeyVersion: 1},
+	} {
+		if _, err := newFieldCipher(cfg); err == nil {
+			t.Errorf("newFieldCipher(%s) succeeded, want error", name)
+		}
+	}
+}
+
+func TestFieldCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 0, testKey("1", 1))
+	recipient, body := "+15551234567", "Your code is 1234"
+
+	storedRecipient, storedBody, version, err := c.encryptFields("n1", recipient, body)
+	if err != nil {
+		t.Fatalf("encryptFields() error = %v", err)
+	}
+	if version != 1 {
+		t.Errorf("key version = %d, want 1", version)
+	}
+	for stored, plaintext := range map[string]string{storedRecipient: recipient, storedBody: body} {
+		if stored == plaintext || strings.Contains(stored, plaintext) {
+			t.Errorf("stored %q contains plaintext %q", stored, plaintext)
+		}
+		if decoded, _ := base64.StdEncoding.DecodeString(stored); bytes.Contains(decoded, []byte(plaintext)) {
+			t.Errorf("stored bytes of %q contain plaintext", plaintext)
+		}
+	}
+
+	gotRecipient, gotBody, err := c.decryptFields("n1", storedRecipient, storedBody, version)
+	if err != nil {
+		t.Fatalf("decryptFields() error = %v", err)
+	}
+	if gotRecipient != recipient || gotBody != body {
+		t.Errorf("decryptFields() = %q, %q, want %q, %q", gotRecipient, gotBody, recipient, body)
+	}
+
+	// Each encryption uses a fresh nonce
+	again, _ := c.encrypt(body, "n1:body")
+	if again == storedBody {
+		t.Error("encrypting the same body twice gave the same ciphertext")
+	}
+
+	// Ciphertext is bound to its row and field
+	if _, _, err := c.decryptFields("n2", storedRecipient, storedBody, version); !errors.Is(err, ErrDecryption) {
+		t.Errorf("decryptFields() for another row error = %v, want ErrDecryption", err)
+	}
+	if _, err := c.decrypt(storedBody, version, "n1:recipient"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("decrypt() as another field error = %v, want ErrDecryption", err)
+	}
+	if _, err := c.decrypt("bm9wZQ==", version, "n1:body"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("decrypt() of malformed ciphertext error = %v, want ErrDecryption", err)
+	}
+}
+
+func TestFieldCipherRotation(t *testing.T) {
+	old := newTestCipher(t, 0, testKey("1", 1))
+	_, storedBody, _, err := old.encryptFields("n1", "a@example.com", "hello")
+	if err != nil {
+		t.Fatalf("encryptFields() error = %v", err)
+	}
+
+	// New rows use the new key while old rows stay readable
+	rotated := newTestCipher(t, 0, testKey("1", 1), testKey("2", 2))
+	if rotated.keyVersion() != 2 {
+		t.Errorf("keyVersion() = %d, want 2", rotated.keyVersion())
+	}
+	if body, err := rotated.decrypt(storedBody, 1, "n1:body"); err != nil || body != "hello" {
+		t.Errorf("decrypt() with the old version = %q, %v, want hello", body, err)
+	}
+
+	// Once the old key is removed its rows can no longer be read
+	retired := newTestCipher(t, 0, testKey("2", 2))
+	if _, err := retired.decrypt(storedBody, 1, "n1:body"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("decrypt() with a removed version error = %v, want ErrDecryption", err)
+	}
+}
+
+func TestFieldCipherDisabled(t *testing.T) {
+	var c *fieldCipher
+	recipient, body, version, err := c.encryptFields("n1", "a@example.com", "hello")
+	if err != nil || recipient != "a@example.com" || body != "hello" || version != 0 {
+		t.Errorf("encryptFields() = %q, %q, %d, %v, want plaintext and version 0", recipient, body, version, err)
+	}
+
+	// Rows stored before encryption was enabled are read as they are
+	enabled := newTestCipher(t, 0, testKey("1", 1))
+	if got, err := enabled.decrypt("hello", 0, "n1:body"); err != nil || got != "hello" {
+		t.Errorf("decrypt() of version 0 = %q, %v, want hello", got, err)
+	}
+	if _, err := c.decrypt("c2VhbGVk", 1, "n1:body"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("decrypt() without keys error = %v, want ErrDecryption", err)
+	}
+}
+
+// encryptionConfig returns a config encrypting at rest with key version 1
+func encryptionConfig() *config.Config {
+	return &config.Config{Channels: enabledChannels(), Encryption: config.EncryptionConfig{Keys: []string{testKey("1", 1)}}}
+}
+
+func TestCreateNotificationStoresKeyVersion(t *testing.T) {
+	s, mock := newTestService(t, encryptionConfig())
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	args := make([]any, 25)
+	for i := range args {
+		args[i] = dbtest.AnyArg()
+	}
+	args[16] = 1
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending}
+	expectPreferences(mock, "user-1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox").WithArgs(dbtest.AnyArg(), dbtest.AnyArg(), 1).WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+
+	// The relay decrypts the outbox payload before publishing
+	recipient, body, version, err := s.cipher.encryptFields("n1", "+15551234567", "hi")
+	if err != nil {
+		t.Fatalf("encryptFields() error = %v", err)
+	}
+	payload := `{"id":"n1","user_id":"user-1","channel":"sms","recipient":"` + recipient + `","body":"` + body + `"}`
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").
+		WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version").AddRow(1, "n1", 0, []byte(payload), version))
+	expectPublished(mock, 1, "n1")
+	mock.ExpectCommit()
+
+	if _, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}); err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	published := recorder.Published(t)
+	if len(published) != 1 || published[0].Recipient != "+15551234567" || published[0].Body != "hi" {
+		t.Errorf("published %+v, want the decrypted message", published)
+	}
+}
+
+func TestGetNotificationDecrypts(t *testing.T) {
+	s, mock := newTestService(t, encryptionConfig())
+	recipient, body, version, err := s.cipher.encryptFields("n1", "a@example.com", "secret")
+	if err != nil {
+		t.Fatalf("encryptFields() error = %v", err)
+	}
+	values := notificationRowValues(Notification{ID: "n1", UserID: "user-1", Channel: "email", Status: StatusSent})
+	values[3], values[5], values[23] = recipient, body, version
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(dbtest.NewRows(notificationColumnNames()...).AddRow(values...))
+
+	got, err := s.GetNotification(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("GetNotification() error = %v", err)
+	}
+	if got.Recipient != "a@example.com" || got.Body != "secret" {
+		t.Errorf("GetNotification() = %q, %q, want the plaintext", got.Recipient, got.Body)
+	}
+
+	// Without the key the row cannot be read
+	plain, mock := newTestService(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(dbtest.NewRows(notificationColumnNames()...).AddRow(values...))
+	if _, err := plain.GetNotification(context.Background(), "n1"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("GetNotification() without keys error = %v, want ErrDecryption", err)
+	}
+}
@@ -0,0 +1,88 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NotificationEvent is one entry in a notification's status history. Moving a
+// failed notification back to pending for a replay is recorded as an event
+// too, so retries show up in the history.
+type NotificationEvent struct {
+	ID             int64              `json:"id" db:"id"`
+	NotificationID string             `json:"notification_id" db:"notification_id"`
+	Status         NotificationStatus `json:"status" db:"status"`
+	ExternalID     string             `json:"external_id,omitempty" db:"external_id"`
+	Detail         string             `json:"detail,omitempty" db:"detail"` // error message or reason for the change
+	CreatedAt      time.Time          `json:"created_at" db:"created_at"`
+}
+
+// recordEvent appends a status change to the notification's history and
+// queues a status callback when the notification has a callback URL. The
+// history is diagnostic, so a failure to record is logged rather than failing
+// the change itself.
+func (s *Service) recordEvent(ctx context.Context, id string, status NotificationStatus, externalID, detail string) {
+	query := `
+		INSERT INTO notification_events (notification_id, status, external_id, detail, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := s.db.ExecContext(ctx, query, id, status, nullIfEmpty(externalID), nullIfEmpty(detail)); err != nil {
+		s.logger.Error("Failed to record notification event",
+			zap.String("id", id),
+			zap.String("status", string(status)),
+			zap.Error(err),
+		)
+	}
+	s.enqueueCallback(ctx, id, status, externalID, detail)
+}
+
+// GetNotificationEvents returns the status history of a notification, oldest first
+func (s *Service) GetNotificationEvents(ctx context.Context, id string) ([]NotificationEvent, error) {
+	query := `
+		SELECT e.id, e.notification_id, e.status, e.external_id, e.detail, e.created_at
+		FROM notification_events e
+		JOIN notifications n ON n.id = e.notification_id
+		WHERE e.notification_id = $1 AND ($2 = '' OR n.tenant_id = $2)
+		ORDER BY e.created_at, e.id`
+
+	rows, err := s.db.QueryContext(ctx, query, id, TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []NotificationEvent{}
+	for rows.Next() {
+		var event NotificationEvent
+		var externalID, detail sql.NullString
+		if err := rows.Scan(&event.ID, &event.NotificationID, &event.Status, &externalID, &detail, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification event: %w", err)
+		}
+		event.ExternalID = externalID.String
+		event.Detail = detail.String
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification events: %w", err)
+	}
+	return events, nil
+}
+
+// DeliveryReportFor returns the provider's outcome for a notification as
+// recorded on it, or nil while it has not reached a provider
+func DeliveryReportFor(n *Notification) *DeliveryReport {
+	if n.ExternalID == "" && n.SentAt == nil && n.Status != StatusFailed {
+		return nil
+	}
+	return &DeliveryReport{
+		NotificationID: n.ID,
+		ExternalID:     n.ExternalID,
+		Status:         n.Status,
+		ErrorMessage:   n.ErrorMessage,
+		AcceptedAt:     n.SentAt,
+		DeliveredAt:    n.DeliveredAt,
+	}
+}
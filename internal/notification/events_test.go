@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveryReportFor(t *testing.T) {
+	sentAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if report := DeliveryReportFor(&Notification{ID: "n1", Status: StatusPending}); report != nil {
+		t.Errorf("DeliveryReportFor(pending) = %+v, want nil", report)
+	}
+
+	report := DeliveryReportFor(&Notification{ID: "n1", Status: StatusSent, ExternalID: "SM123", SentAt: &sentAt})
+	if report == nil || report.ExternalID != "SM123" || report.Status != StatusSent || report.AcceptedAt != &sentAt {
+		t.Errorf("DeliveryReportFor(sent) = %+v, want SM123 accepted at %v", report, sentAt)
+	}
+
+	// A notification rejected before reaching the provider still reports why
+	report = DeliveryReportFor(&Notification{ID: "n1", Status: StatusFailed, ErrorMessage: "invalid number"})
+	if report == nil || report.ErrorMessage != "invalid number" {
+		t.Errorf("DeliveryReportFor(failed) = %+v, want the error", report)
+	}
+}
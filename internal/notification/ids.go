@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ID generators selectable with the id_generator setting
+const (
+	IDGeneratorUUIDv4 = "uuidv4"
+	IDGeneratorUUIDv7 = "uuidv7"
+)
+
+// IDGenerator creates IDs for notifications, groups and broadcasts. IDs are
+// stored in UUID columns, so every generator returns UUIDs.
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDv4Generator creates random UUIDs
+type UUIDv4Generator struct{}
+
+// NewID returns a random UUIDv4
+func (UUIDv4Generator) NewID() string {
+	return uuid.New().String()
+}
+
+// UUIDv7Generator creates time-ordered UUIDs: IDs created later sort after
+// earlier ones, both as strings and as UUID column values, which keeps index
+// inserts local and makes IDs usable as a creation-order cursor
+type UUIDv7Generator struct{}
+
+// NewID returns a UUIDv7, falling back to a UUIDv4 if the random source fails
+func (UUIDv7Generator) NewID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+// NewIDGenerator returns the generator named by kind, defaulting to UUIDv4
+func NewIDGenerator(kind string, logger *zap.Logger) IDGenerator {
+	switch kind {
+	case IDGeneratorUUIDv7:
+		return UUIDv7Generator{}
+	case IDGeneratorUUIDv4, "":
+		return UUIDv4Generator{}
+	default:
+		logger.Warn("Invalid ID generator, using uuidv4", zap.String("value", kind))
+		return UUIDv4Generator{}
+	}
+}
@@ -0,0 +1,64 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestUUIDv7IDsIncreaseOverTime(t *testing.T) {
+	generator := UUIDv7Generator{}
+
+	previous := generator.NewID()
+	for i := 0; i < 1000; i++ {
+		// IDs created within the same millisecond are ordered too
+		if i%250 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+		id := generator.NewID()
+		if id <= previous {
+			t.Fatalf("ID %d = %s, not after %s", i, id, previous)
+		}
+		previous = id
+	}
+
+	parsed, err := uuid.Parse(previous)
+	if err != nil || parsed.Version() != 7 {
+		t.Errorf("NewID() = %s, want a UUIDv7", previous)
+	}
+}
+
+func TestUUIDv4Generator(t *testing.T) {
+	id := UUIDv4Generator{}.NewID()
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.Version() != 4 {
+		t.Errorf("NewID() = %s, want a UUIDv4", id)
+	}
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		kind string
+		want IDGenerator
+	}{
+		{"", UUIDv4Generator{}},
+		{IDGeneratorUUIDv4, UUIDv4Generator{}},
+		{IDGeneratorUUIDv7, UUIDv7Generator{}},
+		{"ulid", UUIDv4Generator{}},
+	}
+	for _, tt := range tests {
+		if got := NewIDGenerator(tt.kind, zap.NewNop()); got != tt.want {
+			t.Errorf("NewIDGenerator(%q) = %T, want %T", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestServiceUsesConfiguredIDGenerator(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{API: config.APIConfig{IDGenerator: IDGeneratorUUIDv7}})
+	if _, ok := s.ids.(UUIDv7Generator); !ok {
+		t.Errorf("service ID generator = %T, want UUIDv7Generator", s.ids)
+	}
+}
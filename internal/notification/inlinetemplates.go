@@ -0,0 +1,127 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// inlineFuncs are the template functions inline templates may call. Functions
+// that format arbitrary output (printf), call functions (call) or index into
+// values are left out, as are range, template and block actions, so an inline
+// template can only print, compare and escape its variables.
+var inlineFuncs = map[string]bool{
+	"and":      true,
+	"or":       true,
+	"not":      true,
+	"eq":       true,
+	"ne":       true,
+	"len":      true,
+	"html":     true,
+	"urlquery": true,
+}
+
+// renderInline renders a subject and body written with Go template syntax
+// using variables, for requests that do not use a stored template. Content
+// without template actions, or requests without variables, is returned as is.
+func renderInline(subject, body string, variables map[string]string) (string, string, error) {
+	if len(variables) == 0 {
+		return subject, body, nil
+	}
+
+	var err error
+	if subject, err = executeInline("inline:subject", subject, variables); err != nil {
+		return "", "", err
+	}
+	if body, err = executeInline("inline:body", body, variables); err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+// executeInline parses text, checks it only uses what inline templates allow
+// and executes it with variables
+func executeInline(name, text string, variables map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+	if len(t.Templates()) > 1 {
+		return "", fmt.Errorf("%w: %s: define is not allowed in inline templates", ErrTemplateRender, name)
+	}
+	if err := checkInline(t.Tree.Root); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, variables); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+	return buf.String(), nil
+}
+
+// checkInline returns an error for the first node of an inline template that
+// uses an action or function inline templates do not allow
+func checkInline(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkInline(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.ActionNode:
+		return checkInline(n.Pipe)
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkInline(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkInline(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.IdentifierNode:
+		if !inlineFuncs[n.Ident] {
+			return fmt.Errorf("function %q is not allowed in inline templates", n.Ident)
+		}
+		return nil
+	case *parse.TextNode, *parse.FieldNode, *parse.VariableNode, *parse.DotNode,
+		*parse.StringNode, *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+		return nil
+	default:
+		return fmt.Errorf("%q is not allowed in inline templates", node.String())
+	}
+}
+
+// checkBranch checks the condition and both branches of an if or with action
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkInline(n.Pipe); err != nil {
+		return err
+	}
+	if err := checkInline(n.List); err != nil {
+		return err
+	}
+	return checkInline(n.ElseList)
+}
@@ -0,0 +1,126 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestRenderInline(t *testing.T) {
+	variables := map[string]string{"name": "Ada", "plan": "pro", "next": "a&b"}
+	tests := []struct {
+		name        string
+		subject     string
+		body        string
+		wantSubject string
+		wantBody    string
+	}{
+		{"fields", "Hi {{.name}}", "Welcome to {{.plan}}", "Hi Ada", "Welcome to pro"},
+		{"plain text", "Hi", "No actions here", "Hi", "No actions here"},
+		{"conditions", "", `{{if eq .plan "pro"}}Thanks{{else}}Upgrade{{end}}, {{with .name}}{{.}}{{end}}`, "", "Thanks, Ada"},
+		{"escaping", "", `{{.next | urlquery}} {{html "<b>"}} {{len .name}}`, "", "a%26b &lt;b&gt; 3"},
+		{"logic", "", `{{if and .name (not (ne .plan "pro"))}}yes{{end}}{{if or false .plan}}!{{end}}`, "", "yes!"},
+	}
+	for _, tt := range tests {
+		subject, body, err := renderInline(tt.subject, tt.body, variables)
+		if err != nil {
+			t.Errorf("%s: renderInline() error = %v", tt.name, err)
+			continue
+		}
+		if subject != tt.wantSubject || body != tt.wantBody {
+			t.Errorf("%s: renderInline() = %q, %q, want %q, %q", tt.name, subject, body, tt.wantSubject, tt.wantBody)
+		}
+	}
+
+	// Without variables the content is kept as written
+	if subject, body, err := renderInline("Hi {{.name}}", "{{printf}}", nil); err != nil || subject != "Hi {{.name}}" || body != "{{printf}}" {
+		t.Errorf("renderInline() without variables = %q, %q, %v, want the content unchanged", subject, body, err)
+	}
+}
+
+func TestRenderInlineRejectsUnsafeTemplates(t *testing.T) {
+	variables := map[string]string{"name": "Ada"}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"parse error", "Hi {{.name"},
+		{"missing variable", "Hi {{.surname}}"},
+		{"printf", `{{printf "%s" .name}}`},
+		{"call", "{{call .name}}"},
+		{"index", `{{index . "name"}}`},
+		{"range", "{{range .}}{{.}}{{end}}"},
+		{"define", `{{define "x"}}x{{end}}Hi`},
+		{"template", `{{template "inline:body"}}`},
+		{"function in condition", `{{if printf "x"}}x{{end}}`},
+		{"function in else branch", `{{with .name}}{{.}}{{else}}{{print "x"}}{{end}}`},
+	}
+	for _, tt := range tests {
+		_, _, err := renderInline("Hi", tt.body, variables)
+		if !errors.Is(err, ErrTemplateRender) {
+			t.Errorf("%s: renderInline() error = %v, want ErrTemplateRender", tt.name, err)
+		}
+	}
+
+	// The subject is checked too
+	if _, _, err := renderInline("{{printf .name}}", "Hi", variables); !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("renderInline() subject error = %v, want ErrTemplateRender", err)
+	}
+}
+
+func TestCreateNotificationRendersInlineTemplate(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.InlineTemplates = true
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	args := insertArgs("+15551234567", nil)
+	args[5] = "Hi Ada, your code is 1234"
+	expectPreferences(mock, "user-1")
+	expectStoreWithArgs(mock, args, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending})
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Hi {{.name}}, your code is {{.code}}",
+		Variables: map[string]string{"name": "Ada", "code": "1234"},
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+}
+
+func TestCreateNotificationInlineTemplateFailures(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.InlineTemplates = true
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	// Nothing is stored when the template cannot be rendered
+	expectPreferences(mock, "user-1")
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: `{{printf "%s" .name}}`,
+		Variables: map[string]string{"name": "Ada"},
+	})
+	if !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("CreateNotification() error = %v, want ErrTemplateRender", err)
+	}
+}
+
+func TestCreateNotificationInlineTemplatesDisabled(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	args := insertArgs("+15551234567", nil)
+	args[5] = "Hi {{.name}}"
+	expectPreferences(mock, "user-1")
+	expectStoreWithArgs(mock, args, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending})
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Hi {{.name}}",
+		Variables: map[string]string{"name": "Ada"},
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+}
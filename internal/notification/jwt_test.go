@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testJWTSecret = "test-secret"
+
+// signJWT returns claims signed with secret using method
+func signJWT(t *testing.T, method jwt.SigningMethod, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return token
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Auth: config.AuthConfig{JWTSecret: testJWTSecret}})
+
+	token := signJWT(t, jwt.SigningMethodHS256, testJWTSecret, jwt.MapClaims{
+		"sub":       "user-1",
+		"tenant_id": "tenant-a",
+		"scope":     ScopeNotificationsRead + " " + ScopeNotificationsCreate,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	key, err := s.AuthenticateJWT(token)
+	if err != nil {
+		t.Fatalf("AuthenticateJWT() error = %v", err)
+	}
+	want := &APIKey{ID: "jwt:user-1", TenantID: "tenant-a", Scopes: []string{ScopeNotificationsRead, ScopeNotificationsCreate}}
+	if !reflect.DeepEqual(key, want) {
+		t.Errorf("AuthenticateJWT() = %+v, want %+v", key, want)
+	}
+}
+
+func TestAuthenticateJWTRejects(t *testing.T) {
+	valid := jwt.MapClaims{"sub": "user-1", "tenant_id": "tenant-a", "scope": ScopeNotificationsRead}
+	expired := jwt.MapClaims{"sub": "user-1", "tenant_id": "tenant-a", "exp": time.Now().Add(-time.Minute).Unix()}
+	untenanted := jwt.MapClaims{"sub": "user-1", "scope": ScopeNotificationsRead}
+
+	tests := []struct {
+		name   string
+		secret string
+		token  func(t *testing.T) string
+	}{
+		{"wrong secret", testJWTSecret, func(t *testing.T) string { return signJWT(t, jwt.SigningMethodHS256, "other-secret", valid) }},
+		{"other algorithm", testJWTSecret, func(t *testing.T) string { return signJWT(t, jwt.SigningMethodHS512, testJWTSecret, valid) }},
+		{"expired", testJWTSecret, func(t *testing.T) string { return signJWT(t, jwt.SigningMethodHS256, testJWTSecret, expired) }},
+		{"no tenant", testJWTSecret, func(t *testing.T) string { return signJWT(t, jwt.SigningMethodHS256, testJWTSecret, untenanted) }},
+		{"malformed", testJWTSecret, func(t *testing.T) string { return "not-a-jwt" }},
+		{"no secret configured", "", func(t *testing.T) string { return signJWT(t, jwt.SigningMethodHS256, "", valid) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, &config.Config{Auth: config.AuthConfig{JWTSecret: tt.secret}})
+			if _, err := s.AuthenticateJWT(tt.token(t)); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("AuthenticateJWT() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
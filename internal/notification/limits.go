@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// Overflow modes for content longer than a channel's limits
+const (
+	OverflowReject   = "reject"
+	OverflowTruncate = "truncate"
+)
+
+// Subject rules for a channel
+const (
+	SubjectOptional = "optional"
+	SubjectRequired = "required"
+	SubjectIgnored  = "ignored"
+)
+
+// Fields reported in Notification.Truncated
+const (
+	FieldSubject = "subject"
+	FieldBody    = "body"
+)
+
+// ErrContentTooLong is returned when a subject or body exceeds the channel's
+// limit and the channel is configured to reject oversized content
+var ErrContentTooLong = errors.New("content too long")
+
+// ErrSubjectRequired is returned when a notification has no subject on a
+// channel whose subject rule is required
+var ErrSubjectRequired = errors.New("subject required")
+
+// ellipsis marks truncated content
+const ellipsis = "…"
+
+// applyContentLimits enforces the channel's subject rule and its subject and
+// body limits, counted in characters. A missing subject is rejected with
+// ErrSubjectRequired where the channel requires one, and a subject is dropped
+// where the channel ignores it. Oversized content is rejected with
+// ErrContentTooLong or, in truncate mode, cut short with an ellipsis. It
+// returns the possibly truncated subject and body and the names of the fields
+// that were truncated.
+func applyContentLimits(limits config.ContentLimits, channel, subject, body string) (string, string, []string, error) {
+	switch limits.Subject {
+	case SubjectRequired:
+		if strings.TrimSpace(subject) == "" {
+			return "", "", nil, fmt.Errorf("%w: %s notifications need a subject", ErrSubjectRequired, channel)
+		}
+	case SubjectIgnored:
+		subject = ""
+	}
+
+	var truncated []string
+	fields := []struct {
+		name  string
+		value *string
+		max   int
+	}{
+		{FieldSubject, &subject, limits.MaxSubject},
+		{FieldBody, &body, limits.MaxBody},
+	}
+
+	for _, field := range fields {
+		length := utf8.RuneCountInString(*field.value)
+		if field.max <= 0 || length <= field.max {
+			continue
+		}
+		if limits.Overflow != OverflowTruncate {
+			return "", "", nil, fmt.Errorf("%w: %s %s is %d characters, the limit is %d", ErrContentTooLong, channel, field.name, length, field.max)
+		}
+		*field.value = truncate(*field.value, field.max)
+		truncated = append(truncated, field.name)
+	}
+
+	return subject, body, truncated, nil
+}
+
+// truncate shortens s to max characters, the last of which is an ellipsis
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	if max <= utf8.RuneCountInString(ellipsis) {
+		return string(runes[:max])
+	}
+	return string(runes[:max-utf8.RuneCountInString(ellipsis)]) + ellipsis
+}
@@ -0,0 +1,189 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// defaultLimits mirrors the per-channel defaults in config.Load
+var defaultLimits = map[string]config.ContentLimits{
+	"email": {MaxSubject: 998, MaxBody: 1 << 20, Overflow: OverflowReject, Subject: SubjectRequired},
+	"sms":   {MaxBody: 1600, Overflow: OverflowReject, Subject: SubjectIgnored},
+	"push":  {MaxSubject: 256, MaxBody: 2048, Overflow: OverflowReject, Subject: SubjectRequired},
+}
+
+func TestApplyContentLimitsReject(t *testing.T) {
+	for channel, limits := range defaultLimits {
+		t.Run(channel, func(t *testing.T) {
+			subject := "Hello"
+			if limits.MaxSubject > 0 {
+				subject = strings.Repeat("s", limits.MaxSubject)
+			}
+			body := strings.Repeat("b", limits.MaxBody)
+
+			// Content exactly at the limits is accepted unchanged
+			_, gotBody, truncated, err := applyContentLimits(limits, channel, subject, body)
+			if err != nil {
+				t.Fatalf("applyContentLimits() at the limits error = %v", err)
+			}
+			if gotBody != body || truncated != nil {
+				t.Errorf("applyContentLimits() at the limits changed the content, truncated %v", truncated)
+			}
+
+			if _, _, _, err := applyContentLimits(limits, channel, subject, body+"b"); !errors.Is(err, ErrContentTooLong) {
+				t.Errorf("applyContentLimits() with body over the limit error = %v, want ErrContentTooLong", err)
+			}
+			if limits.MaxSubject > 0 {
+				if _, _, _, err := applyContentLimits(limits, channel, subject+"s", "hi"); !errors.Is(err, ErrContentTooLong) {
+					t.Errorf("applyContentLimits() with subject over the limit error = %v, want ErrContentTooLong", err)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyContentLimitsTruncate(t *testing.T) {
+	for channel, limits := range defaultLimits {
+		t.Run(channel, func(t *testing.T) {
+			limits.Overflow = OverflowTruncate
+
+			subject := "Hello"
+			wantTruncated := []string{FieldBody}
+			if limits.MaxSubject > 0 {
+				subject = strings.Repeat("s", limits.MaxSubject+10)
+				wantTruncated = []string{FieldSubject, FieldBody}
+			}
+
+			gotSubject, gotBody, truncated, err := applyContentLimits(limits, channel, subject, strings.Repeat("b", limits.MaxBody+1))
+			if err != nil {
+				t.Fatalf("applyContentLimits() error = %v", err)
+			}
+			if !reflect.DeepEqual(truncated, wantTruncated) {
+				t.Errorf("truncated = %v, want %v", truncated, wantTruncated)
+			}
+			if n := len([]rune(gotBody)); n != limits.MaxBody || !strings.HasSuffix(gotBody, ellipsis) {
+				t.Errorf("body is %d characters, want %d ending in an ellipsis", n, limits.MaxBody)
+			}
+			if limits.MaxSubject > 0 {
+				if n := len([]rune(gotSubject)); n != limits.MaxSubject || !strings.HasSuffix(gotSubject, ellipsis) {
+					t.Errorf("subject is %d characters, want %d ending in an ellipsis", n, limits.MaxSubject)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyContentLimitsSubjectRule(t *testing.T) {
+	if _, _, _, err := applyContentLimits(defaultLimits["email"], "email", " ", "body"); !errors.Is(err, ErrSubjectRequired) {
+		t.Errorf("email without subject error = %v, want ErrSubjectRequired", err)
+	}
+
+	subject, _, _, err := applyContentLimits(defaultLimits["sms"], "sms", "dropped", "body")
+	if err != nil || subject != "" {
+		t.Errorf("sms subject = %q, %v, want it dropped", subject, err)
+	}
+
+	subject, _, _, err = applyContentLimits(config.ContentLimits{Subject: SubjectOptional}, "push", "", "body")
+	if err != nil || subject != "" {
+		t.Errorf("optional subject = %q, %v, want an empty subject accepted", subject, err)
+	}
+}
+
+func TestApplyContentLimitsSubjectPerChannel(t *testing.T) {
+	tests := []struct {
+		channel     string
+		subject     string
+		wantSubject string
+		wantErr     error
+	}{
+		{"email", "Your receipt", "Your receipt", nil},
+		{"email", "", "", ErrSubjectRequired},
+		{"push", "New message", "New message", nil},
+		{"push", "", "", ErrSubjectRequired},
+		{"sms", "", "", nil},
+		{"sms", "Ignored", "", nil},
+	}
+
+	for _, tt := range tests {
+		subject, body, _, err := applyContentLimits(defaultLimits[tt.channel], tt.channel, tt.subject, "body")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s with subject %q: error = %v, want %v", tt.channel, tt.subject, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (subject != tt.wantSubject || body != "body") {
+			t.Errorf("%s with subject %q: got %q, %q, want subject %q", tt.channel, tt.subject, subject, body, tt.wantSubject)
+		}
+	}
+}
+
+func TestCreateNotificationRequiresSubject(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Firebase.Limits = defaultLimits["push"]
+	s, mock := newTestService(t, cfg)
+	expectPreferences(mock, "user-1")
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "push", Recipient: "device-token", Body: "hello",
+	})
+	if !errors.Is(err, ErrSubjectRequired) {
+		t.Errorf("CreateNotification() error = %v, want ErrSubjectRequired", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 5, "hello"},
+		{"hello world", 6, "hello…"},
+		// Limits count characters, not bytes
+		{"héllo wörld", 6, "héllo…"},
+		{"hello", 1, "h"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestCreateNotificationReportsTruncation(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Twilio.Limits = config.ContentLimits{MaxBody: 10, Overflow: OverflowTruncate, Subject: SubjectIgnored}
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	expectPreferences(mock, "user-1")
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Your code…", Status: StatusPending})
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Your code is 1234",
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if !reflect.DeepEqual(created.Truncated, []string{FieldBody}) {
+		t.Errorf("Truncated = %v, want [body]", created.Truncated)
+	}
+}
+
+func TestCreateNotificationRejectsOversizedContent(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Twilio.Limits = config.ContentLimits{MaxBody: 10, Overflow: OverflowReject}
+	s, mock := newTestService(t, cfg)
+	expectPreferences(mock, "user-1")
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Your code is 1234",
+	})
+	if !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("CreateNotification() error = %v, want ErrContentTooLong", err)
+	}
+}
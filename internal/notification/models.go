@@ -6,24 +6,72 @@ import (
 
 // Notification represents a notification entity
 type Notification struct {
-	ID          string            `json:"id" db:"id"`
-	UserID      string            `json:"user_id" db:"user_id"`
-	Channel     string            `json:"channel" db:"channel"`
-	Recipient   string            `json:"recipient" db:"recipient"`
-	Subject     string            `json:"subject,omitempty" db:"subject"`
-	Body        string            `json:"body" db:"body"`
-	Status      NotificationStatus `json:"status" db:"status"`
-	ExternalID  string            `json:"external_id,omitempty" db:"external_id"`
-	ErrorMessage string           `json:"error_message,omitempty" db:"error_message"`
-	RetryCount  int               `json:"retry_count" db:"retry_count"`
-	ScheduledAt *time.Time        `json:"scheduled_at,omitempty" db:"scheduled_at"`
-	SentAt      *time.Time        `json:"sent_at,omitempty" db:"sent_at"`
-	DeliveredAt *time.Time        `json:"delivered_at,omitempty" db:"delivered_at"`
-	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
-	Metadata    map[string]string `json:"metadata,omitempty"`
+	ID              string             `json:"id" db:"id"`
+	UserID          string             `json:"user_id" db:"user_id"`
+	Channel         string             `json:"channel" db:"channel"`
+	Recipient       string             `json:"recipient" db:"recipient"`
+	Subject         string             `json:"subject,omitempty" db:"subject"`
+	Body            string             `json:"body" db:"body"`
+	Status          NotificationStatus `json:"status" db:"status"`
+	ExternalID      string             `json:"external_id,omitempty" db:"external_id"`
+	ErrorMessage    string             `json:"error_message,omitempty" db:"error_message"`
+	RetryCount      int                `json:"retry_count" db:"retry_count"`
+	ScheduledAt     *time.Time         `json:"scheduled_at,omitempty" db:"scheduled_at"`
+	ExpiresAt       *time.Time         `json:"expires_at,omitempty" db:"expires_at"`
+	SendWindowStart *time.Time         `json:"send_window_start,omitempty" db:"send_window_start"` // earliest send time
+	SendWindowEnd   *time.Time         `json:"send_window_end,omitempty" db:"send_window_end"`     // latest send time
+	QueuedAt        *time.Time         `json:"queued_at,omitempty" db:"queued_at"`
+	SentAt          *time.Time         `json:"sent_at,omitempty" db:"sent_at"`
+	DeliveredAt     *time.Time         `json:"delivered_at,omitempty" db:"delivered_at"`
+	ResentFrom      string             `json:"resent_from,omitempty" db:"resent_from"`
+	GroupID         string             `json:"group_id,omitempty" db:"group_id"`
+	CollapseKey     string             `json:"collapse_key,omitempty" db:"collapse_key"`
+	TenantID        string             `json:"tenant_id,omitempty" db:"tenant_id"`       // set when created with a tenant API key
+	Category        string             `json:"category" db:"category"`                   // transactional or marketing
+	CallbackURL     string             `json:"callback_url,omitempty" db:"callback_url"` // receives a signed POST on each status change
+	Priority        int                `json:"priority" db:"priority"`                   // 1 = high, 2 = medium, 3 = low
+	CreatedBy       string             `json:"created_by,omitempty" db:"created_by"`     // ID of the API key, or admin, that created it
+	Source          string             `json:"source,omitempty" db:"source"`             // api, grpc or admin; empty when created by the service
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" db:"updated_at"`
+	Metadata        map[string]string  `json:"metadata,omitempty"`
+	ProviderOptions ProviderOptions    `json:"provider_options,omitempty"` // passed through to the provider
+	Attachments     []Attachment       `json:"attachments,omitempty"`
+	Truncated       []string           `json:"truncated,omitempty"`       // fields shortened to the channel's limits on creation
+	DeferredReason  string             `json:"deferred_reason,omitempty"` // why it was held until ScheduledAt on creation, see DeferredScheduled; not stored
 }
 
+// IsExpired reports whether the notification's expiry time, or the end of its
+// send window, has passed
+func (n *Notification) IsExpired(now time.Time) bool {
+	if n.SendWindowEnd != nil && !now.Before(*n.SendWindowEnd) {
+		return true
+	}
+	return n.ExpiresAt != nil && !now.Before(*n.ExpiresAt)
+}
+
+// Notification categories. Users can opt out of a category on a channel while
+// still receiving the other; marketing opt-outs never block transactional
+// notifications.
+const (
+	CategoryTransactional = "transactional"
+	CategoryMarketing     = "marketing"
+)
+
+// IsValidCategory reports whether category is a known notification category
+func IsValidCategory(category string) bool {
+	return category == CategoryTransactional || category == CategoryMarketing
+}
+
+// Notification priorities. Higher priorities are consumed first when the
+// priority buffer is enabled, and high-priority push notifications are sent
+// with high FCM and APNs priority.
+const (
+	PriorityHigh   = 1
+	PriorityMedium = 2 // default
+	PriorityLow    = 3
+)
+
 // NotificationStatus represents the status of a notification
 type NotificationStatus string
 
@@ -33,20 +81,123 @@ const (
 	StatusDelivered NotificationStatus = "delivered"
 	StatusFailed    NotificationStatus = "failed"
 	StatusCancelled NotificationStatus = "cancelled"
+	// StatusUnknown marks a sent notification whose delivery was never
+	// confirmed within the delivery SLA, see SweepStaleSent
+	StatusUnknown NotificationStatus = "unknown"
 )
 
+// statusTransitions lists the statuses each status may move to. Statuses only
+// move forward: delivered, failed and cancelled are terminal, and a failed
+// notification is retried by resending it as a new notification.
+var statusTransitions = map[NotificationStatus][]NotificationStatus{
+	StatusPending: {StatusSent, StatusDelivered, StatusFailed, StatusCancelled},
+	// A bounce can still fail a notification the provider accepted
+	StatusSent: {StatusDelivered, StatusFailed, StatusUnknown},
+	// A late webhook still settles a notification past its delivery SLA
+	StatusUnknown: {StatusDelivered, StatusFailed},
+}
+
+// CanTransitionTo reports whether a notification in status s may move to next
+func (s NotificationStatus) CanTransitionTo(next NotificationStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// previousStatuses returns the statuses that may move to status
+func previousStatuses(status NotificationStatus) []string {
+	var from []string
+	for prev, nexts := range statusTransitions {
+		for _, next := range nexts {
+			if next == status {
+				from = append(from, string(prev))
+			}
+		}
+	}
+	return from
+}
+
+// IsValid reports whether the status is a known notification status
+func (s NotificationStatus) IsValid() bool {
+	switch s {
+	case StatusPending, StatusSent, StatusDelivered, StatusFailed, StatusCancelled, StatusUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
 // NotificationRequest represents a request to send a notification
 type NotificationRequest struct {
-	UserID    string            `json:"user_id" validate:"required"`
-	Channel   string            `json:"channel" validate:"required,oneof=email sms push"`
-	Recipient string            `json:"recipient" validate:"required"`
-	Subject   string            `json:"subject,omitempty"`
-	Body      string            `json:"body" validate:"required"`
-	Priority  int               `json:"priority,omitempty"` // 1 = high, 2 = medium, 3 = low
-	ScheduledAt *time.Time      `json:"scheduled_at,omitempty"`
-	Template  string            `json:"template,omitempty"`
-	Variables map[string]string `json:"variables,omitempty"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	UserID           string            `json:"user_id" validate:"required_without=Topic"`
+	Channel          string            `json:"channel" validate:"required,oneof=email sms push"`
+	Topic            string            `json:"topic,omitempty"`             // broadcast to an FCM topic instead of a user, see CreateBroadcast
+	FallbackChannels []string          `json:"fallback_channels,omitempty"` // tried in order when the user has disabled Channel
+	Recipient        string            `json:"recipient,omitempty"`         // resolved from the users table when empty
+	Recipients       []string          `json:"recipients,omitempty"`        // fan out to several recipients, see CreateNotificationGroup
+	Subject          string            `json:"subject,omitempty"`
+	Body             string            `json:"body" validate:"required_without=Template"`
+	Priority         int               `json:"priority,omitempty"` // 1 = high, 2 = medium, 3 = low
+	ScheduledAt      *time.Time        `json:"scheduled_at,omitempty"`
+	ExpiresAt        *time.Time        `json:"expires_at,omitempty"`        // notification is cancelled instead of sent after this time
+	SendWindowStart  *time.Time        `json:"send_window_start,omitempty"` // held until this time
+	SendWindowEnd    *time.Time        `json:"send_window_end,omitempty"`   // cancelled as expired when not sent by this time
+	Template         string            `json:"template,omitempty"`
+	Locale           string            `json:"locale,omitempty"` // template locale; defaults to the user's preferred locale
+	Variables        map[string]string `json:"variables,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	ProviderOptions  ProviderOptions   `json:"provider_options,omitempty"`                                            // options passed through to each provider, see ValidateProviderOptions
+	Attachments      []Attachment      `json:"attachments,omitempty"`                                                 // email only
+	CollapseKey      string            `json:"collapse_key,omitempty"`                                                // newer notifications with the same key replace older ones on the device
+	Category         string            `json:"category,omitempty" validate:"omitempty,oneof=transactional marketing"` // defaults to transactional
+	CallbackURL      string            `json:"callback_url,omitempty"`                                                // receives a signed POST on each status change, see DeliverCallbacks
+}
+
+// NotificationStatusSummary is the delivery status of a notification without its content
+type NotificationStatusSummary struct {
+	ID         string             `json:"id"`
+	Status     NotificationStatus `json:"status"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	ExternalID string             `json:"external_id,omitempty"`
+}
+
+// NotificationGroup holds the notifications created by fanning out one request to several recipients
+type NotificationGroup struct {
+	GroupID       string          `json:"group_id"`
+	Notifications []*Notification `json:"notifications"`
+}
+
+// ListNotificationsFilter holds filtering and pagination options for listing notifications
+type ListNotificationsFilter struct {
+	UserID    string
+	Channel   string
+	Status    NotificationStatus
+	From      *time.Time // inclusive lower bound on created_at
+	To        *time.Time // exclusive upper bound on created_at
+	TenantID  string     // only the tenant's notifications, set from the request's API key
+	CreatedBy string     // API key ID, or admin
+	Source    string     // api, grpc or admin
+	Limit     int
+	PageToken string
+}
+
+// ListNotificationsResult holds a page of notifications
+type ListNotificationsResult struct {
+	Notifications []*Notification
+	NextPageToken string
+	TotalCount    int
+}
+
+// UserStats holds a user's notification counts grouped by channel and status
+type UserStats struct {
+	UserID   string                                `json:"user_id"`
+	From     *time.Time                            `json:"from,omitempty"`
+	To       *time.Time                            `json:"to,omitempty"`
+	Total    int                                   `json:"total"`
+	Channels map[string]map[NotificationStatus]int `json:"channels"`
 }
 
 // User represents a user entity
@@ -66,6 +217,8 @@ type UserPreference struct {
 	Channel   string    `json:"channel" db:"channel"`
 	Enabled   bool      `json:"enabled" db:"enabled"`
 	Frequency string    `json:"frequency" db:"frequency"` // immediate, hourly, daily
+	Locale    string    `json:"locale,omitempty" db:"locale"`
+	Category  string    `json:"category,omitempty" db:"category"` // empty applies to every category without its own preference
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -75,6 +228,7 @@ type NotificationTemplate struct {
 	ID              string            `json:"id" db:"id"`
 	Name            string            `json:"name" db:"name"`
 	Channel         string            `json:"channel" db:"channel"`
+	Locale          string            `json:"locale" db:"locale"`
 	SubjectTemplate string            `json:"subject_template,omitempty" db:"subject_template"`
 	BodyTemplate    string            `json:"body_template" db:"body_template"`
 	Variables       map[string]string `json:"variables,omitempty" db:"variables"`
@@ -82,12 +236,31 @@ type NotificationTemplate struct {
 	UpdatedAt       time.Time         `json:"updated_at" db:"updated_at"`
 }
 
-// DeliveryReport represents a delivery report from a channel provider
+// DeliveryReport represents a delivery report from a channel provider. A send
+// only tells whether the provider accepted the notification: Status is then
+// StatusSent and AcceptedAt is set. Delivery is confirmed asynchronously by
+// provider webhooks, which set StatusDelivered and DeliveredAt.
 type DeliveryReport struct {
 	NotificationID string             `json:"notification_id"`
 	ExternalID     string             `json:"external_id"`
 	Status         NotificationStatus `json:"status"`
 	ErrorMessage   string             `json:"error_message,omitempty"`
+	Retryable      bool               `json:"retryable"`               // true if the failure is transient and the send may be retried
+	ProviderCode   string             `json:"provider_code,omitempty"` // provider-specific error or status code
+	Provider       string             `json:"provider,omitempty"`      // provider that sent, set when a channel chooses between several
+	AcceptedAt     *time.Time         `json:"accepted_at,omitempty"`   // when the provider accepted the notification
 	DeliveredAt    *time.Time         `json:"delivered_at,omitempty"`
 	Metadata       map[string]string  `json:"metadata,omitempty"`
-}
\ No newline at end of file
+}
+
+// ErrorType classifies a failed delivery for metrics: the provider's error code
+// when known, otherwise whether the failure was transient
+func (r *DeliveryReport) ErrorType() string {
+	if r.ProviderCode != "" {
+		return r.ProviderCode
+	}
+	if r.Retryable {
+		return "transient"
+	}
+	return "provider_error"
+}
@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to NotificationStatus
+		want     bool
+	}{
+		{StatusPending, StatusSent, true},
+		{StatusPending, StatusDelivered, true},
+		{StatusPending, StatusFailed, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusSent, StatusDelivered, true},
+		{StatusSent, StatusFailed, true},
+		{StatusSent, StatusUnknown, true},
+		{StatusUnknown, StatusDelivered, true},
+		{StatusUnknown, StatusFailed, true},
+
+		{StatusDelivered, StatusSent, false},
+		{StatusDelivered, StatusFailed, false},
+		{StatusSent, StatusPending, false},
+		{StatusSent, StatusCancelled, false},
+		{StatusFailed, StatusPending, false},
+		{StatusFailed, StatusSent, false},
+		{StatusCancelled, StatusSent, false},
+		{StatusPending, StatusPending, false},
+		{StatusPending, StatusUnknown, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPreviousStatuses(t *testing.T) {
+	tests := []struct {
+		status NotificationStatus
+		want   []string
+	}{
+		{StatusSent, []string{"pending"}},
+		{StatusDelivered, []string{"pending", "sent", "unknown"}},
+		{StatusFailed, []string{"pending", "sent", "unknown"}},
+		{StatusCancelled, []string{"pending"}},
+		{StatusUnknown, []string{"sent"}},
+		{StatusPending, nil},
+	}
+	for _, tt := range tests {
+		got := previousStatuses(tt.status)
+		sort.Strings(got)
+		if len(got) != len(tt.want) {
+			t.Errorf("previousStatuses(%s) = %v, want %v", tt.status, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("previousStatuses(%s) = %v, want %v", tt.status, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestIsValidCategory(t *testing.T) {
+	for category, want := range map[string]bool{
+		CategoryTransactional: true,
+		CategoryMarketing:     true,
+		"":                    false,
+		"promotional":         false,
+	} {
+		if got := IsValidCategory(category); got != want {
+			t.Errorf("IsValidCategory(%q) = %v, want %v", category, got, want)
+		}
+	}
+}
+
+func TestDeliveryReportErrorType(t *testing.T) {
+	tests := []struct {
+		name   string
+		report DeliveryReport
+		want   string
+	}{
+		{"provider code", DeliveryReport{ProviderCode: "21211"}, "21211"},
+		{"provider code of a transient failure", DeliveryReport{ProviderCode: "20429", Retryable: true}, "20429"},
+		{"transient without code", DeliveryReport{Retryable: true}, "transient"},
+		{"permanent without code", DeliveryReport{}, "provider_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.ErrorType(); got != tt.want {
+				t.Errorf("ErrorType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
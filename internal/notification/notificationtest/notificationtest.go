@@ -0,0 +1,86 @@
+// Package notificationtest provides database fixtures for tests of packages
+// built on the notification service
+package notificationtest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/notification"
+)
+
+// columns are the columns the service scans notifications from, in order
+var columns = strings.Split("id, user_id, channel, recipient, subject, body, status, external_id, "+
+	"error_message, retry_count, scheduled_at, expires_at, queued_at, sent_at, delivered_at, resent_from, group_id, "+
+	"attachments, collapse_key, tenant_id, category, created_at, updated_at, key_version, callback_url, priority, "+
+	"send_window_start, send_window_end, created_by, source, metadata, provider_options", ", ")
+
+// Rows returns notifications as rows of the notifications table
+func Rows(notifications ...notification.Notification) *dbtest.Rows {
+	rows := dbtest.NewRows(columns...)
+	for _, n := range notifications {
+		rows.AddRow(values(n)...)
+	}
+	return rows
+}
+
+// SearchRows returns notifications as rows of a full-text search, each
+// followed by its rank from ranks
+func SearchRows(ranks []float32, notifications ...notification.Notification) *dbtest.Rows {
+	rows := dbtest.NewRows(append(append([]string(nil), columns...), "rank")...)
+	for i, n := range notifications {
+		rows.AddRow(append(values(n), ranks[i])...)
+	}
+	return rows
+}
+
+// BatchRows returns notifications as rows of a batch status update, each
+// preceded by the ordinal of its update from ordinals
+func BatchRows(ordinals []int64, notifications ...notification.Notification) *dbtest.Rows {
+	rows := dbtest.NewRows(append([]string{"ordinal"}, columns...)...)
+	for i, n := range notifications {
+		rows.AddRow(append([]any{ordinals[i]}, values(n)...)...)
+	}
+	return rows
+}
+
+// values returns n's column values in order
+func values(n notification.Notification) []any {
+	category := n.Category
+	if category == "" {
+		category = notification.CategoryTransactional
+	}
+	priority := n.Priority
+	if priority == 0 {
+		priority = notification.PriorityMedium
+	}
+	return []any{n.ID, n.UserID, n.Channel, n.Recipient, n.Subject, n.Body, string(n.Status), null(n.ExternalID),
+		null(n.ErrorMessage), n.RetryCount, n.ScheduledAt, n.ExpiresAt, n.QueuedAt, n.SentAt, n.DeliveredAt, null(n.ResentFrom), null(n.GroupID),
+		nil, null(n.CollapseKey), null(n.TenantID), category, n.CreatedAt, n.UpdatedAt, nil, null(n.CallbackURL), priority,
+		n.SendWindowStart, n.SendWindowEnd, null(n.CreatedBy), null(n.Source), jsonColumn(n.Metadata), jsonColumn(n.ProviderOptions)}
+}
+
+// jsonColumn returns v encoded for a JSONB column, nil when it is empty
+func jsonColumn[T ~map[string]V, V any](v T) any {
+	if len(v) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// null returns nil for an empty column value
+func null(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ExpectEvent expects a status change of id, or dbtest.AnyArg(), to be
+// recorded in its history
+func ExpectEvent(mock *dbtest.Mock, id any, status notification.NotificationStatus) {
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(id, status, dbtest.AnyArg(), dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WithArgs(id, dbtest.AnyArg()).WillReturnResult(0)
+}
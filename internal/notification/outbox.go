@@ -0,0 +1,218 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/queue"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutboxBatchSize caps how many outbox rows are published per relay run
+	OutboxBatchSize = 100
+	// outboxRetryDelay is how long a row that failed to publish waits, per attempt
+	outboxRetryDelay = 30 * time.Second
+	// outboxMaxRetryDelay caps the wait between publish attempts of a row
+	outboxMaxRetryDelay = 10 * time.Minute
+)
+
+// outboxEntry is a queue message waiting to be published
+type outboxEntry struct {
+	id             int64
+	notificationID string
+	attempts       int
+	message        queue.NotificationMessage
+}
+
+// queueMessage builds the queue message that delivers the notification
+func queueMessage(notification *Notification) queue.NotificationMessage {
+	return queue.NotificationMessage{
+		ID:              notification.ID,
+		UserID:          notification.UserID,
+		Channel:         notification.Channel,
+		Recipient:       notification.Recipient,
+		Subject:         notification.Subject,
+		Body:            notification.Body,
+		Metadata:        notification.Metadata,
+		ProviderOptions: notification.ProviderOptions,
+		Priority:        notification.Priority,
+		CreatedAt:       notification.CreatedAt,
+	}
+}
+
+// insertOutbox adds the notification's queue message to the outbox within tx,
+// so it is published if and only if the notification is committed. The
+// message's recipient and body are encrypted like the notification's. It
+// returns the outbox row ID.
+func (s *Service) insertOutbox(ctx context.Context, tx *sql.Tx, notification *Notification) (int64, error) {
+	message := queueMessage(notification)
+	var keyVersion int
+	var err error
+	message.Recipient, message.Body, keyVersion, err = s.cipher.encryptFields(notification.ID, message.Recipient, message.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt queue message: %w", err)
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal queue message: %w", err)
+	}
+
+	var id int64
+	query := `INSERT INTO outbox (notification_id, payload, key_version) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, notification.ID, payload, nullIfZero(keyVersion)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+	return id, nil
+}
+
+// RelayOutbox publishes the outbox rows that are due, oldest first, and marks
+// them published. Rows are locked while they are published, so concurrent
+// relays in several API instances never publish the same row twice; a row is
+// only published again if marking it failed after the publish. Rows that fail
+// to publish are retried with a growing delay. It returns the number published.
+func (s *Service) RelayOutbox(ctx context.Context) (int, error) {
+	query := `SELECT id, notification_id, attempts, payload, key_version FROM outbox
+		WHERE published_at IS NULL AND available_at <= NOW()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+	return s.relayOutbox(ctx, query, OutboxBatchSize)
+}
+
+// relayOutboxEntries publishes the given outbox rows right after their
+// notifications were committed, so delivery does not wait for the next relay run
+func (s *Service) relayOutboxEntries(ctx context.Context, ids []int64) error {
+	query := `SELECT id, notification_id, attempts, payload, key_version FROM outbox
+		WHERE id = ANY($1) AND published_at IS NULL
+		ORDER BY id
+		FOR UPDATE SKIP LOCKED`
+	_, err := s.relayOutbox(ctx, query, pq.Array(ids))
+	return err
+}
+
+// relayOutbox publishes the outbox rows selected and locked by query in one
+// transaction. It returns the number published, and the first publish error.
+func (s *Service) relayOutbox(ctx context.Context, query string, args ...interface{}) (int, error) {
+	if s.producer == nil {
+		return 0, fmt.Errorf("%w: cannot relay outbox", ErrNoProducer)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var entries []outboxEntry
+	for rows.Next() {
+		var entry outboxEntry
+		var payload []byte
+		var keyVersion sql.NullInt64
+		if err := rows.Scan(&entry.id, &entry.notificationID, &entry.attempts, &payload, &keyVersion); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		if err := json.Unmarshal(payload, &entry.message); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode outbox entry %d: %w", entry.id, err)
+		}
+		entry.message.Recipient, entry.message.Body, err = s.cipher.decryptFields(entry.notificationID, entry.message.Recipient, entry.message.Body, int(keyVersion.Int64))
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decrypt outbox entry %d: %w", entry.id, err)
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	published := 0
+	var publishErr error
+	var queued []string
+	for _, entry := range entries {
+		err := s.publishOutboxEntry(ctx, tx, entry)
+		if err == nil {
+			published++
+			queued = append(queued, entry.notificationID)
+			continue
+		}
+
+		s.logger.Error("Failed to publish outbox entry",
+			zap.Int64("outbox_id", entry.id),
+			zap.String("id", entry.notificationID),
+			zap.Int("attempts", entry.attempts+1),
+			zap.Error(err),
+		)
+		if publishErr == nil {
+			publishErr = fmt.Errorf("failed to publish notification %s: %w", entry.notificationID, err)
+		}
+
+		delay := outboxRetryDelay * time.Duration(entry.attempts+1)
+		if delay > outboxMaxRetryDelay {
+			delay = outboxMaxRetryDelay
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE outbox SET attempts = attempts + 1, last_error = $2, available_at = $3
+			WHERE id = $1`, entry.id, err.Error(), time.Now().Add(delay))
+		if err != nil {
+			return published, fmt.Errorf("failed to record outbox failure: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox: %w", err)
+	}
+
+	// The cached copies predate queued_at
+	for _, id := range queued {
+		s.invalidateNotification(ctx, id)
+	}
+	return published, publishErr
+}
+
+// publishOutboxEntry publishes one outbox row and marks it and its
+// notification as published within tx
+func (s *Service) publishOutboxEntry(ctx context.Context, tx *sql.Tx, entry outboxEntry) error {
+	if !s.config.Channels.IsEnabled(entry.message.Channel) {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, entry.message.Channel)
+	}
+	if err := s.producer.PublishNotification(ctx, entry.message); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = $2, attempts = attempts + 1, last_error = NULL WHERE id = $1`, entry.id, now); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as published: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE notifications SET queued_at = $2 WHERE id = $1`, entry.notificationID, now); err != nil {
+		return fmt.Errorf("failed to mark notification as queued: %w", err)
+	}
+	return nil
+}
+
+// PurgeOutbox deletes outbox rows published more than olderThan ago and
+// returns the number deleted
+func (s *Service) PurgeOutbox(ctx context.Context, olderThan time.Duration) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return int(purged), nil
+}
@@ -0,0 +1,183 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+)
+
+// outboxRows returns outbox rows for notifications, numbered from 1, with
+// payloads as written by insertOutbox
+func outboxRows(notifications ...Notification) *dbtest.Rows {
+	rows := dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version")
+	for i, n := range notifications {
+		payload := `{"id":"` + n.ID + `","user_id":"` + n.UserID + `","channel":"` + n.Channel + `","recipient":"` + n.Recipient + `","body":"` + n.Body + `"}`
+		rows.AddRow(i+1, n.ID, 0, []byte(payload), nil)
+	}
+	return rows
+}
+
+// expectPublished expects outbox row id and its notification to be marked as
+// published
+func expectPublished(mock *dbtest.Mock, outboxID int, notificationID string) {
+	mock.ExpectExec("UPDATE outbox SET published_at = $2").WithArgs(outboxID, dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectExec("UPDATE notifications SET queued_at = $2").WithArgs(notificationID, dbtest.AnyArg()).WillReturnResult(1)
+}
+
+func TestCreateNotificationWritesOutboxInSameTransaction(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	now := time.Now()
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending, CreatedAt: now, UpdatedAt: now}
+	expectPreferences(mock, "user-1")
+	// The notification and its outbox row commit together
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox (notification_id, payload, key_version)").WithArgs(dbtest.AnyArg(), dbtest.AnyArg(), nil).
+		WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	// Then the new row is relayed right away
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1) AND published_at IS NULL").WillReturnRows(outboxRows(row))
+	expectPublished(mock, 1, "n1")
+	mock.ExpectCommit()
+
+	if _, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}); err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if published := recorder.Published(t); len(published) != 1 || published[0].ID != "n1" {
+		t.Errorf("published %+v, want n1 once", published)
+	}
+}
+
+func TestCreateNotificationWithoutOutboxRowIsNotCommitted(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	now := time.Now()
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending, CreatedAt: now, UpdatedAt: now}
+	expectPreferences(mock, "user-1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	if _, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}); err == nil {
+		t.Fatal("CreateNotification() succeeded, want the outbox error")
+	}
+	if published := recorder.Published(t); len(published) != 0 {
+		t.Errorf("published %+v, want nothing for a rolled back notification", published)
+	}
+}
+
+func TestCreateNotificationSucceedsWhenKafkaIsDown(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+	recorder.Fail(errors.New("kafka unavailable"))
+
+	now := time.Now()
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending, CreatedAt: now, UpdatedAt: now}
+	expectPreferences(mock, "user-1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	// The row stays in the outbox for the relay to retry
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(outboxRows(row))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts + 1, last_error = $2").WithArgs(1, dbtest.AnyArg(), dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectCommit()
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v, want the notification created for a later relay", err)
+	}
+	if created.Status != StatusPending {
+		t.Errorf("created status = %q, want pending", created.Status)
+	}
+}
+
+func TestRelayOutboxPublishesEachRowOnce(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	first := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "one"}
+	second := Notification{ID: "n2", UserID: "user-2", Channel: "email", Recipient: "b@example.com", Body: "two"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE published_at IS NULL AND available_at <= NOW() ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED").
+		WithArgs(OutboxBatchSize).WillReturnRows(outboxRows(first, second))
+	expectPublished(mock, 1, "n1")
+	expectPublished(mock, 2, "n2")
+	mock.ExpectCommit()
+	// Published rows are no longer selected
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE published_at IS NULL").WillReturnRows(outboxRows())
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	if published, err := s.RelayOutbox(ctx); err != nil || published != 2 {
+		t.Fatalf("RelayOutbox() = %d, %v, want 2 published", published, err)
+	}
+	if published, err := s.RelayOutbox(ctx); err != nil || published != 0 {
+		t.Fatalf("second RelayOutbox() = %d, %v, want nothing left to publish", published, err)
+	}
+
+	published := recorder.Published(t)
+	if len(published) != 2 || published[0].ID != "n1" || published[1].ID != "n2" {
+		t.Fatalf("published %+v, want n1 and n2 once each", published)
+	}
+	if published[1].Recipient != "b@example.com" || published[1].Body != "two" {
+		t.Errorf("published %+v, want the outbox payload", published[1])
+	}
+}
+
+func TestRelayOutboxRetriesFailedRowsLater(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+	recorder.Fail(errors.New("kafka unavailable"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE published_at IS NULL").
+		WillReturnRows(outboxRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts + 1, last_error = $2, available_at = $3 WHERE id = $1").
+		WithArgs(1, "failed to write message to Kafka: kafka unavailable", dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectCommit()
+
+	published, err := s.RelayOutbox(context.Background())
+	if err == nil || published != 0 {
+		t.Errorf("RelayOutbox() = %d, %v, want nothing published and the publish error", published, err)
+	}
+	if published := recorder.Published(t); len(published) != 0 {
+		t.Errorf("published %+v, want nothing", published)
+	}
+}
+
+func TestRelayOutboxRequiresProducer(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	if _, err := s.RelayOutbox(context.Background()); !errors.Is(err, ErrNoProducer) {
+		t.Errorf("RelayOutbox() error = %v, want ErrNoProducer", err)
+	}
+}
+
+func TestPurgeOutbox(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("DELETE FROM outbox WHERE published_at < $1").WithArgs(dbtest.AnyArg()).WillReturnResult(3)
+
+	purged, err := s.PurgeOutbox(context.Background(), 24*time.Hour)
+	if err != nil || purged != 3 {
+		t.Errorf("PurgeOutbox() = %d, %v, want 3", purged, err)
+	}
+}
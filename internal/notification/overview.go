@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// overviewPingTimeout bounds each dependency check of GetSystemOverview
+const overviewPingTimeout = 2 * time.Second
+
+// SystemOverview summarizes notification volume, queue depth and dependency
+// health for operators
+type SystemOverview struct {
+	Since       time.Time                  `json:"since"`
+	Total       int                        `json:"total"`
+	Statuses    map[NotificationStatus]int `json:"statuses"` // notifications created since Since, by status
+	Queue       QueueOverview              `json:"queue"`
+	Health      HealthOverview             `json:"health"`
+	Paused      bool                       `json:"paused"`
+	GeneratedAt time.Time                  `json:"generated_at"`
+}
+
+// QueueOverview tells where undelivered notifications are waiting
+type QueueOverview struct {
+	Outbox           int     `json:"outbox"`            // committed but not yet published to Kafka
+	ScheduledBacklog int     `json:"scheduled_backlog"` // scheduled_at passed but not yet dispatched
+	InFlight         int     `json:"in_flight"`         // published to Kafka but not yet sent by a worker
+	LagSeconds       float64 `json:"lag_seconds"`       // how long the oldest in-flight notification has waited
+}
+
+// HealthOverview reports whether the service's dependencies answer
+type HealthOverview struct {
+	Healthy  bool `json:"healthy"` // every dependency answered
+	Database bool `json:"database"`
+	Redis    bool `json:"redis"`
+}
+
+// GetSystemOverview counts the notifications created since since by status,
+// measures the queue and checks that Postgres and Redis answer. A dependency
+// that does not answer is reported unhealthy rather than failing the overview;
+// without the database only health is reported.
+func (s *Service) GetSystemOverview(ctx context.Context, since time.Time) (*SystemOverview, error) {
+	overview := &SystemOverview{
+		Since:       since,
+		Statuses:    make(map[NotificationStatus]int),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	overview.Health.Database = s.ping(ctx, "database", func(ctx context.Context) error { return s.db.PingContext(ctx) })
+	if s.redis != nil {
+		overview.Health.Redis = s.ping(ctx, "redis", func(ctx context.Context) error { return s.redis.Ping(ctx).Err() })
+	}
+	overview.Health.Healthy = overview.Health.Database && overview.Health.Redis
+
+	if overview.Health.Redis {
+		paused, err := s.IsPaused(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to check pause flag", zap.Error(err))
+		}
+		overview.Paused = paused
+	}
+	if !overview.Health.Database {
+		return overview, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM notifications WHERE created_at >= $1 GROUP BY status`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status NotificationStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan notification count: %w", err)
+		}
+		overview.Statuses[status] = count
+		overview.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE published_at IS NULL`).Scan(&overview.Queue.Outbox); err != nil {
+		return nil, fmt.Errorf("failed to count outbox: %w", err)
+	}
+
+	if overview.Queue.ScheduledBacklog, err = s.CountScheduledBacklog(ctx); err != nil {
+		return nil, err
+	}
+
+	var oldest sql.NullTime
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MIN(queued_at) FROM notifications
+		WHERE status = $1 AND queued_at IS NOT NULL`, StatusPending).Scan(&overview.Queue.InFlight, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure queue lag: %w", err)
+	}
+	if oldest.Valid {
+		overview.Queue.LagSeconds = time.Since(oldest.Time).Seconds()
+	}
+
+	return overview, nil
+}
+
+// ping reports whether check succeeds within overviewPingTimeout, logging why not
+func (s *Service) ping(ctx context.Context, dependency string, check func(context.Context) error) bool {
+	ctx, cancel := context.WithTimeout(ctx, overviewPingTimeout)
+	defer cancel()
+
+	if err := check(ctx); err != nil {
+		s.logger.Warn("Dependency health check failed", zap.String("dependency", dependency), zap.Error(err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+// expectOverviewQueries expects the counts of GetSystemOverview, with the
+// oldest in-flight notification queued at oldest
+func expectOverviewQueries(mock *dbtest.Mock, since time.Time, oldest any) {
+	mock.ExpectQuery("SELECT status, COUNT(*) FROM notifications WHERE created_at >= $1 GROUP BY status").WithArgs(since).
+		WillReturnRows(dbtest.NewRows("status", "count").AddRow("sent", 7).AddRow("failed", 2).AddRow("pending", 3))
+	mock.ExpectQuery("SELECT COUNT(*) FROM outbox WHERE published_at IS NULL").WillReturnRows(dbtest.NewRows("count").AddRow(1))
+	mock.ExpectQuery("scheduled_at <= NOW()").WithArgs(StatusPending).WillReturnRows(dbtest.NewRows("count").AddRow(4))
+	mock.ExpectQuery("SELECT COUNT(*), MIN(queued_at) FROM notifications").WithArgs(StatusPending).
+		WillReturnRows(dbtest.NewRows("count", "min").AddRow(2, oldest))
+}
+
+func TestGetSystemOverview(t *testing.T) {
+	s, mock, _ := newTestServiceWithRedis(t, nil)
+	since := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	expectOverviewQueries(mock, since, time.Now().Add(-90*time.Second))
+	if err := s.SetPaused(context.Background(), true); err != nil {
+		t.Fatalf("SetPaused() error = %v", err)
+	}
+
+	overview, err := s.GetSystemOverview(context.Background(), since)
+	if err != nil {
+		t.Fatalf("GetSystemOverview() error = %v", err)
+	}
+	if overview.Total != 12 || overview.Statuses[StatusSent] != 7 || overview.Statuses[StatusFailed] != 2 || overview.Statuses[StatusPending] != 3 {
+		t.Errorf("counts = %d %v, want 12 by status", overview.Total, overview.Statuses)
+	}
+	if q := overview.Queue; q.Outbox != 1 || q.ScheduledBacklog != 4 || q.InFlight != 2 || q.LagSeconds < 90 || q.LagSeconds > 100 {
+		t.Errorf("Queue = %+v, want 1 in the outbox, 4 scheduled, 2 in flight for about 90s", q)
+	}
+	if h := overview.Health; !h.Healthy || !h.Database || !h.Redis {
+		t.Errorf("Health = %+v, want all healthy", h)
+	}
+	if !overview.Paused {
+		t.Error("Paused = false, want the pause flag reported")
+	}
+}
+
+func TestGetSystemOverviewUnhealthy(t *testing.T) {
+	t.Run("redis down", func(t *testing.T) {
+		s, mock, _ := newTestServiceWithRedis(t, nil)
+		since := time.Now().Add(-time.Hour)
+		expectOverviewQueries(mock, since, nil)
+		s.redis.Close()
+
+		overview, err := s.GetSystemOverview(context.Background(), since)
+		if err != nil {
+			t.Fatalf("GetSystemOverview() error = %v", err)
+		}
+		if h := overview.Health; h.Healthy || !h.Database || h.Redis {
+			t.Errorf("Health = %+v, want only Redis unhealthy", h)
+		}
+		if overview.Total != 12 || overview.Queue.LagSeconds != 0 {
+			t.Errorf("overview = %+v, want counts without lag", overview)
+		}
+	})
+
+	t.Run("without redis", func(t *testing.T) {
+		s, mock := newTestService(t, nil)
+		since := time.Now().Add(-time.Hour)
+		expectOverviewQueries(mock, since, nil)
+
+		overview, err := s.GetSystemOverview(context.Background(), since)
+		if err != nil {
+			t.Fatalf("GetSystemOverview() error = %v", err)
+		}
+		if h := overview.Health; h.Healthy || !h.Database || h.Redis {
+			t.Errorf("Health = %+v, want Redis reported unhealthy", h)
+		}
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		s, _, _ := newTestServiceWithRedis(t, nil)
+		s.db.Close()
+
+		// Only health is reported
+		overview, err := s.GetSystemOverview(context.Background(), time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("GetSystemOverview() error = %v", err)
+		}
+		if h := overview.Health; h.Healthy || h.Database || !h.Redis {
+			t.Errorf("Health = %+v, want only the database unhealthy", h)
+		}
+		if overview.Total != 0 {
+			t.Errorf("Total = %d, want no counts", overview.Total)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ErrDuplicatePreference is returned when a preference set has more than one
+// preference for the same channel and category
+var ErrDuplicatePreference = errors.New("duplicate preference")
+
+// UserPreferenceRequest is one preference of a user's full preference set
+type UserPreferenceRequest struct {
+	Channel   string `json:"channel" validate:"required,oneof=email sms push"`
+	Enabled   *bool  `json:"enabled" validate:"required"`
+	Frequency string `json:"frequency,omitempty" validate:"omitempty,oneof=immediate hourly daily"` // defaults to immediate
+	Locale    string `json:"locale,omitempty" validate:"omitempty,max=20"`
+	Category  string `json:"category,omitempty" validate:"omitempty,oneof=transactional marketing"` // empty applies to every category
+}
+
+// preferenceWarmBatch is how many users' preferences are read and cached per
+// round trip when warming the cache
+const preferenceWarmBatch = 500
+
+// userPreferenceColumns lists the columns read by scanUserPreference, in order
+const userPreferenceColumns = `id, user_id, channel, enabled, frequency, COALESCE(locale, ''), COALESCE(category, ''), created_at, updated_at`
+
+// scanUserPreference scans a row selected with userPreferenceColumns, followed by extra
+func scanUserPreference(row rowScanner, extra ...interface{}) (UserPreference, error) {
+	var pref UserPreference
+	err := row.Scan(append([]interface{}{
+		&pref.ID, &pref.UserID, &pref.Channel, &pref.Enabled,
+		&pref.Frequency, &pref.Locale, &pref.Category, &pref.CreatedAt, &pref.UpdatedAt,
+	}, extra...)...)
+	return pref, err
+}
+
+// preferenceCacheID returns the ID a user's preference set is cached under
+// in a tenant
+func preferenceCacheID(tenantID, userID string) string {
+	if tenantID == "" {
+		return userID
+	}
+	return tenantID + ":" + userID
+}
+
+// GetUserPreferenceSet returns every stored preference of the user in the
+// request's tenant. Channels and categories without one use the defaults.
+func (s *Service) GetUserPreferenceSet(ctx context.Context, userID string) ([]UserPreference, error) {
+	return s.loadUserPreferences(ctx, userID, TenantFromContext(ctx))
+}
+
+// loadUserPreferences reads the user's preferences in a tenant from the database
+func (s *Service) loadUserPreferences(ctx context.Context, userID, tenantID string) ([]UserPreference, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+userPreferenceColumns+`
+		FROM user_preferences
+		WHERE user_id = $1 AND tenant_id IS NOT DISTINCT FROM NULLIF($2, '')
+		ORDER BY channel, category NULLS FIRST`, userID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+	defer rows.Close()
+
+	preferences := []UserPreference{}
+	for rows.Next() {
+		pref, err := scanUserPreference(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user preference: %w", err)
+		}
+		preferences = append(preferences, pref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+	return preferences, nil
+}
+
+// preferenceCacheEnabled reports whether preference sets are cached in Redis
+func (s *Service) preferenceCacheEnabled() bool {
+	return s.redis != nil && s.config.Redis.PreferenceTTL > 0
+}
+
+// userPreferenceSet returns the user's preferences in the request's tenant,
+// from the cache when they are cached. Users without preferences are cached
+// too, so they don't read the database on every notification either.
+func (s *Service) userPreferenceSet(ctx context.Context, userID string) ([]UserPreference, error) {
+	tenantID := TenantFromContext(ctx)
+	cacheID := preferenceCacheID(tenantID, userID)
+
+	if s.preferenceCacheEnabled() {
+		data, err := s.redis.GetUserPreferences(ctx, cacheID)
+		if err == nil {
+			var preferences []UserPreference
+			if err := json.Unmarshal(data, &preferences); err == nil {
+				return preferences, nil
+			}
+			s.logger.Warn("Failed to unmarshal cached user preferences", zap.String("user_id", userID), zap.Error(err))
+		} else if err != redis.Nil {
+			s.logger.Warn("Failed to read cached user preferences", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+
+	preferences, err := s.loadUserPreferences(ctx, userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.preferenceCacheEnabled() {
+		if data, err := json.Marshal(preferences); err == nil {
+			if err := s.redis.CacheUserPreferences(ctx, cacheID, data, s.config.Redis.PreferenceTTL); err != nil {
+				s.logger.Warn("Failed to cache user preferences", zap.String("user_id", userID), zap.Error(err))
+			}
+		}
+	}
+	return preferences, nil
+}
+
+// WarmPreferenceCache caches the preference sets of the limit users with the
+// most notifications created within window, so the first requests after a
+// restart find them cached instead of all reading the database at once. Sets
+// are read and cached preferenceWarmBatch users at a time. It returns how many
+// users were cached; on error, the users cached before it stay cached.
+func (s *Service) WarmPreferenceCache(ctx context.Context, limit int, window time.Duration) (int, error) {
+	if !s.preferenceCacheEnabled() || limit <= 0 {
+		return 0, nil
+	}
+
+	type activeUser struct{ userID, tenantID string }
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, COALESCE(tenant_id, '') FROM notifications
+		WHERE created_at >= $1
+		GROUP BY user_id, tenant_id
+		ORDER BY COUNT(*) DESC
+		LIMIT $2`, time.Now().Add(-window), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find active users: %w", err)
+	}
+	var users []activeUser
+	for rows.Next() {
+		var user activeUser
+		if err := rows.Scan(&user.userID, &user.tenantID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan active user: %w", err)
+		}
+		users = append(users, user)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find active users: %w", err)
+	}
+
+	warmed := 0
+	for start := 0; start < len(users); start += preferenceWarmBatch {
+		batch := users[start:min(start+preferenceWarmBatch, len(users))]
+
+		// Every user in the batch is cached, those without preferences as empty sets
+		sets := make(map[string][]UserPreference, len(batch))
+		userIDs := make([]string, 0, len(batch))
+		for _, user := range batch {
+			sets[preferenceCacheID(user.tenantID, user.userID)] = []UserPreference{}
+			userIDs = append(userIDs, user.userID)
+		}
+
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT `+userPreferenceColumns+`, COALESCE(tenant_id, '')
+			FROM user_preferences
+			WHERE user_id = ANY($1::uuid[])
+			ORDER BY channel, category NULLS FIRST`, pq.Array(userIDs))
+		if err != nil {
+			return warmed, fmt.Errorf("failed to get user preferences: %w", err)
+		}
+		for rows.Next() {
+			var tenantID string
+			pref, err := scanUserPreference(rows, &tenantID)
+			if err != nil {
+				rows.Close()
+				return warmed, fmt.Errorf("failed to scan user preference: %w", err)
+			}
+			// Skip preferences of tenants the user was not active in
+			cacheID := preferenceCacheID(tenantID, pref.UserID)
+			if set, ok := sets[cacheID]; ok {
+				sets[cacheID] = append(set, pref)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return warmed, fmt.Errorf("failed to get user preferences: %w", err)
+		}
+
+		encoded := make(map[string][]byte, len(sets))
+		for cacheID, set := range sets {
+			data, err := json.Marshal(set)
+			if err != nil {
+				return warmed, fmt.Errorf("failed to marshal user preferences: %w", err)
+			}
+			encoded[cacheID] = data
+		}
+		if err := s.redis.CacheUserPreferencesBatch(ctx, encoded, s.config.Redis.PreferenceTTL); err != nil {
+			return warmed, fmt.Errorf("failed to cache user preferences: %w", err)
+		}
+		warmed += len(batch)
+	}
+
+	return warmed, nil
+}
+
+// ReplaceUserPreferences replaces the user's preferences in the request's
+// tenant with the given set in one transaction, so a failure leaves the
+// previous set untouched. An empty set resets the user to the defaults.
+func (s *Service) ReplaceUserPreferences(ctx context.Context, userID string, reqs []UserPreferenceRequest) ([]UserPreference, error) {
+	seen := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		key := req.Channel + "/" + req.Category
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s %q", ErrDuplicatePreference, req.Channel, req.Category)
+		}
+		seen[key] = true
+	}
+
+	tenantID := TenantFromContext(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the user so concurrent replacements apply one after the other
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 FOR UPDATE)`, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_preferences WHERE user_id = $1 AND tenant_id IS NOT DISTINCT FROM NULLIF($2, '')`, userID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete user preferences: %w", err)
+	}
+
+	preferences := make([]UserPreference, 0, len(reqs))
+	for _, req := range reqs {
+		frequency := req.Frequency
+		if frequency == "" {
+			frequency = "immediate"
+		}
+
+		pref, err := scanUserPreference(tx.QueryRowContext(ctx, `
+			INSERT INTO user_preferences (user_id, channel, enabled, frequency, locale, category, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+userPreferenceColumns,
+			userID, req.Channel, *req.Enabled, frequency, nullIfEmpty(req.Locale), nullIfEmpty(req.Category), nullIfEmpty(tenantID),
+		))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert %s user preference: %w", req.Channel, err)
+		}
+		preferences = append(preferences, pref)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user preferences: %w", err)
+	}
+
+	if s.redis != nil {
+		if err := s.redis.InvalidateUserPreferences(ctx, preferenceCacheID(tenantID, userID)); err != nil {
+			s.logger.Warn("Failed to invalidate cached user preferences", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("Replaced user preferences",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID),
+		zap.Int("preferences", len(preferences)),
+	)
+	return preferences, nil
+}
@@ -0,0 +1,240 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+// preferenceRow returns the row an insert into user_preferences returns
+func preferenceRow(id, channel string, enabled bool, frequency, category string) *dbtest.Rows {
+	return dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at").
+		AddRow(id, "user-1", channel, enabled, frequency, "", category, time.Now(), time.Now())
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestReplaceUserPreferences(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, &config.Config{})
+	server.Set("user_preferences:user-1", `[{"channel":"email","enabled":true}]`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 FOR UPDATE)").WithArgs("user-1").
+		WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectExec("DELETE FROM user_preferences WHERE user_id = $1").WithArgs("user-1", "").WillReturnResult(2)
+	mock.ExpectQuery("INSERT INTO user_preferences").
+		WithArgs("user-1", "email", false, "immediate", nil, nil, nil).
+		WillReturnRows(preferenceRow("p1", "email", false, "immediate", ""))
+	mock.ExpectQuery("INSERT INTO user_preferences").
+		WithArgs("user-1", "sms", true, "daily", nil, "marketing", nil).
+		WillReturnRows(preferenceRow("p2", "sms", true, "daily", "marketing"))
+	mock.ExpectCommit()
+
+	preferences, err := s.ReplaceUserPreferences(context.Background(), "user-1", []UserPreferenceRequest{
+		{Channel: "email", Enabled: boolPtr(false)},
+		{Channel: "sms", Enabled: boolPtr(true), Frequency: "daily", Category: "marketing"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceUserPreferences() error = %v", err)
+	}
+	if len(preferences) != 2 || preferences[0].ID != "p1" || preferences[1].Category != "marketing" {
+		t.Errorf("ReplaceUserPreferences() = %+v, want the replaced set", preferences)
+	}
+	if _, ok := server.Get("user_preferences:user-1"); ok {
+		t.Error("cached preferences were not invalidated")
+	}
+}
+
+func TestReplaceUserPreferencesEmptySetResetsDefaults(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectExec("DELETE FROM user_preferences WHERE user_id = $1").WillReturnResult(3)
+	mock.ExpectCommit()
+
+	preferences, err := s.ReplaceUserPreferences(context.Background(), "user-1", nil)
+	if err != nil {
+		t.Fatalf("ReplaceUserPreferences() error = %v", err)
+	}
+	if len(preferences) != 0 {
+		t.Errorf("ReplaceUserPreferences() = %+v, want no preferences", preferences)
+	}
+}
+
+func TestReplaceUserPreferencesRollsBack(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, &config.Config{})
+	server.Set("user_preferences:user-1", `[]`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectExec("DELETE FROM user_preferences WHERE user_id = $1").WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO user_preferences").WillReturnRows(preferenceRow("p1", "email", true, "immediate", ""))
+	mock.ExpectQuery("INSERT INTO user_preferences").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err := s.ReplaceUserPreferences(context.Background(), "user-1", []UserPreferenceRequest{
+		{Channel: "email", Enabled: boolPtr(true)},
+		{Channel: "push", Enabled: boolPtr(false)},
+	})
+	if err == nil {
+		t.Fatal("ReplaceUserPreferences() error = nil, want the insert failure")
+	}
+	// The previous set is kept, so the cache stays valid
+	if _, ok := server.Get("user_preferences:user-1"); !ok {
+		t.Error("cached preferences were invalidated after a rollback")
+	}
+}
+
+func TestReplaceUserPreferencesRejected(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{})
+
+	_, err := s.ReplaceUserPreferences(context.Background(), "user-1", []UserPreferenceRequest{
+		{Channel: "email", Enabled: boolPtr(true), Category: "marketing"},
+		{Channel: "email", Enabled: boolPtr(false), Category: "marketing"},
+	})
+	if !errors.Is(err, ErrDuplicatePreference) {
+		t.Errorf("duplicate preference error = %v, want ErrDuplicatePreference", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users WHERE id = $1 FOR UPDATE").WillReturnRows(dbtest.NewRows("exists").AddRow(false))
+	mock.ExpectRollback()
+	_, err = s.ReplaceUserPreferences(context.Background(), "ghost", []UserPreferenceRequest{{Channel: "email", Enabled: boolPtr(true)}})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestGetUserPreferenceSet(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{})
+	expectPreferences(mock, "user-1",
+		UserPreference{ID: "p1", Channel: "email", Enabled: true},
+		UserPreference{ID: "p2", Channel: "sms", Enabled: false, Category: "marketing"},
+	)
+
+	preferences, err := s.GetUserPreferenceSet(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("GetUserPreferenceSet() error = %v", err)
+	}
+	if len(preferences) != 2 || preferences[1].Enabled || preferences[1].Category != "marketing" {
+		t.Errorf("GetUserPreferenceSet() = %+v, want both preferences", preferences)
+	}
+}
+
+// preferenceCacheConfig enables the preference cache
+func preferenceCacheConfig() *config.Config {
+	return &config.Config{Redis: config.RedisConfig{PreferenceTTL: time.Hour}}
+}
+
+func TestUserPreferenceSetServedFromCache(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, preferenceCacheConfig())
+
+	// Only the first lookup reaches the database
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "email", Enabled: false})
+	for i := 0; i < 3; i++ {
+		pref, err := s.getUserPreferences(context.Background(), "user-1", "email", "")
+		if err != nil {
+			t.Fatalf("getUserPreferences() %d error = %v", i, err)
+		}
+		if pref.ID != "p1" || pref.Enabled {
+			t.Errorf("getUserPreferences() %d = %+v, want the disabled email preference", i, pref)
+		}
+	}
+	if ttl := server.TTL("user_preferences:user-1"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("cache TTL = %v, want up to an hour", ttl)
+	}
+}
+
+func TestWarmPreferenceCache(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, preferenceCacheConfig())
+
+	mock.ExpectQuery("SELECT user_id, COALESCE(tenant_id, '') FROM notifications WHERE created_at >= $1").WithArgs(dbtest.AnyArg(), 2).
+		WillReturnRows(dbtest.NewRows("user_id", "tenant_id").AddRow("user-1", "").AddRow("user-2", "tenant-a"))
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = ANY($1::uuid[])").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at", "tenant_id").
+			AddRow("p1", "user-2", "sms", false, "immediate", "", "", time.Now(), time.Now(), "tenant-a").
+			AddRow("p2", "user-2", "sms", true, "immediate", "", "", time.Now(), time.Now(), "tenant-b"))
+
+	warmed, err := s.WarmPreferenceCache(context.Background(), 2, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("WarmPreferenceCache() error = %v", err)
+	}
+	if warmed != 2 {
+		t.Errorf("WarmPreferenceCache() = %d, want 2", warmed)
+	}
+
+	// Users without preferences are cached as empty sets, and preferences of
+	// tenants the user was not active in are left out
+	if data, ok := server.Get("user_preferences:user-1"); !ok || data != "[]" {
+		t.Errorf("user-1 cached %q, %v, want an empty set", data, ok)
+	}
+	data, ok := server.Get("user_preferences:tenant-a:user-2")
+	if !ok {
+		t.Fatal("user-2 preferences were not cached")
+	}
+	var cached []UserPreference
+	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+		t.Fatalf("failed to decode cached preferences: %v", err)
+	}
+	if len(cached) != 1 || cached[0].ID != "p1" {
+		t.Errorf("user-2 cached %+v, want only p1", cached)
+	}
+	if _, ok := server.Get("user_preferences:tenant-b:user-2"); ok {
+		t.Error("preferences of an inactive tenant were cached")
+	}
+	if ttl := server.TTL("user_preferences:user-1"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("cache TTL = %v, want up to an hour", ttl)
+	}
+
+	// Warmed users are served without reading the database
+	if _, err := s.userPreferenceSet(WithTenant(context.Background(), "tenant-a"), "user-2"); err != nil {
+		t.Errorf("userPreferenceSet() error = %v", err)
+	}
+}
+
+func TestWarmPreferenceCacheDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		redis bool
+		ttl   time.Duration
+		limit int
+	}{
+		{"without redis", false, time.Hour, 10},
+		{"cache disabled", true, 0, 10},
+		{"no limit", true, time.Hour, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Redis: config.RedisConfig{PreferenceTTL: tt.ttl}}
+			s, _ := newTestService(t, cfg)
+			if tt.redis {
+				s, _, _ = newTestServiceWithRedis(t, cfg)
+			}
+			if warmed, err := s.WarmPreferenceCache(context.Background(), tt.limit, time.Hour); warmed != 0 || err != nil {
+				t.Errorf("WarmPreferenceCache() = %d, %v, want nothing warmed", warmed, err)
+			}
+		})
+	}
+}
+
+func TestWarmPreferenceCacheFailures(t *testing.T) {
+	s, mock, server := newTestServiceWithRedis(t, preferenceCacheConfig())
+	mock.ExpectQuery("FROM notifications WHERE created_at >= $1").WillReturnError(errors.New("connection reset"))
+	if _, err := s.WarmPreferenceCache(context.Background(), 10, time.Hour); err == nil {
+		t.Error("WarmPreferenceCache() error = nil, want the lookup failure")
+	}
+
+	mock.ExpectQuery("FROM notifications WHERE created_at >= $1").WillReturnRows(dbtest.NewRows("user_id", "tenant_id").AddRow("user-1", ""))
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = ANY($1::uuid[])").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at", "tenant_id"))
+	server.Fail(errors.New("READONLY"))
+	if warmed, err := s.WarmPreferenceCache(context.Background(), 10, time.Hour); err == nil || warmed != 0 {
+		t.Errorf("WarmPreferenceCache() = %d, %v, want the cache failure", warmed, err)
+	}
+}
@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// ProviderOptions holds options passed through to a provider's API, keyed by
+// provider name and then by option. Options are only applied by the channel of
+// their provider, so a request can carry options for each of its fallback
+// channels. Providers and options other than the known ones below are ignored.
+type ProviderOptions map[string]map[string]string
+
+// Provider names, as reported by the channels' GetProviderName
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderTwilio   = "twilio"
+	ProviderFCM      = "fcm"
+)
+
+// Known provider options
+const (
+	// OptionASMGroupID is the SendGrid unsubscribe group of an email
+	OptionASMGroupID = "asm_group_id"
+	// OptionASMGroupsToDisplay lists the unsubscribe groups, comma-separated,
+	// shown on SendGrid's preferences page; requires asm_group_id
+	OptionASMGroupsToDisplay = "asm_groups_to_display"
+	// OptionIPPoolName is the SendGrid IP pool an email is sent from
+	OptionIPPoolName = "ip_pool_name"
+	// OptionStatusCallback is the URL Twilio posts the message's status to
+	OptionStatusCallback = "status_callback"
+	// OptionValidityPeriod is how many seconds Twilio keeps trying to deliver a message
+	OptionValidityPeriod = "validity_period"
+	// OptionAnalyticsLabel labels the message in FCM delivery data
+	OptionAnalyticsLabel = "analytics_label"
+)
+
+// Limits of the known provider options, as documented by the providers
+const (
+	maxASMGroupsToDisplay = 25
+	maxIPPoolNameLength   = 64
+	maxValidityPeriod     = 36000
+)
+
+// analyticsLabelPattern matches the analytics labels FCM accepts
+var analyticsLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.~%]{1,50}$`)
+
+// ErrInvalidProviderOptions is returned when a known provider option is malformed
+var ErrInvalidProviderOptions = errors.New("invalid provider options")
+
+// Get returns the value of a provider's option, empty when it is not set
+func (o ProviderOptions) Get(provider, option string) string {
+	return o[provider][option]
+}
+
+// ValidateProviderOptions checks the known options of each provider in
+// options. Unknown providers and options are accepted and ignored when sending.
+func ValidateProviderOptions(options ProviderOptions) error {
+	sendgrid := options[ProviderSendGrid]
+	if value, ok := sendgrid[OptionASMGroupID]; ok {
+		if id, err := strconv.Atoi(value); err != nil || id < 1 {
+			return fmt.Errorf("%w: sendgrid %s must be a positive integer, got %q", ErrInvalidProviderOptions, OptionASMGroupID, value)
+		}
+	}
+	if value, ok := sendgrid[OptionASMGroupsToDisplay]; ok {
+		if _, ok := sendgrid[OptionASMGroupID]; !ok {
+			return fmt.Errorf("%w: sendgrid %s requires %s", ErrInvalidProviderOptions, OptionASMGroupsToDisplay, OptionASMGroupID)
+		}
+		groups := splitList(value)
+		if len(groups) > maxASMGroupsToDisplay {
+			return fmt.Errorf("%w: sendgrid %s allows at most %d groups, got %d", ErrInvalidProviderOptions, OptionASMGroupsToDisplay, maxASMGroupsToDisplay, len(groups))
+		}
+		for _, group := range groups {
+			if id, err := strconv.Atoi(group); err != nil || id < 1 {
+				return fmt.Errorf("%w: sendgrid %s must list positive integers, got %q", ErrInvalidProviderOptions, OptionASMGroupsToDisplay, group)
+			}
+		}
+	}
+	if value, ok := sendgrid[OptionIPPoolName]; ok && (value == "" || len(value) > maxIPPoolNameLength) {
+		return fmt.Errorf("%w: sendgrid %s must be 1 to %d characters", ErrInvalidProviderOptions, OptionIPPoolName, maxIPPoolNameLength)
+	}
+
+	twilio := options[ProviderTwilio]
+	if value, ok := twilio[OptionStatusCallback]; ok {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: twilio %s must be an absolute http or https URL", ErrInvalidProviderOptions, OptionStatusCallback)
+		}
+	}
+	if value, ok := twilio[OptionValidityPeriod]; ok {
+		if seconds, err := strconv.Atoi(value); err != nil || seconds < 1 || seconds > maxValidityPeriod {
+			return fmt.Errorf("%w: twilio %s must be between 1 and %d seconds, got %q", ErrInvalidProviderOptions, OptionValidityPeriod, maxValidityPeriod, value)
+		}
+	}
+
+	if value, ok := options[ProviderFCM][OptionAnalyticsLabel]; ok && !analyticsLabelPattern.MatchString(value) {
+		return fmt.Errorf("%w: fcm %s must be 1 to 50 letters, digits or -_.~%%", ErrInvalidProviderOptions, OptionAnalyticsLabel)
+	}
+	return nil
+}
+
+// ParseASMGroups parses a validated comma-separated list of unsubscribe groups
+func ParseASMGroups(value string) []int {
+	var groups []int
+	for _, group := range splitList(value) {
+		if id, err := strconv.Atoi(group); err == nil {
+			groups = append(groups, id)
+		}
+	}
+	return groups
+}
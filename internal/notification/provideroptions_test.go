@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateProviderOptions(t *testing.T) {
+	valid := ProviderOptions{
+		ProviderSendGrid: {OptionASMGroupID: "42", OptionASMGroupsToDisplay: "42,43", OptionIPPoolName: "transactional"},
+		ProviderTwilio:   {OptionStatusCallback: "https://example.com/status", OptionValidityPeriod: "36000"},
+		ProviderFCM:      {OptionAnalyticsLabel: "spring_sale-2024"},
+		// Unknown providers and options pass through
+		"mailgun": {"tag": "anything"},
+	}
+	if err := ValidateProviderOptions(valid); err != nil {
+		t.Errorf("ValidateProviderOptions() error = %v, want nil", err)
+	}
+	if err := ValidateProviderOptions(nil); err != nil {
+		t.Errorf("ValidateProviderOptions(nil) error = %v, want nil", err)
+	}
+
+	tooManyGroups := strings.TrimSuffix(strings.Repeat("1,", maxASMGroupsToDisplay+1), ",")
+	tests := []struct {
+		name    string
+		options ProviderOptions
+	}{
+		{"zero asm group", ProviderOptions{ProviderSendGrid: {OptionASMGroupID: "0"}}},
+		{"non-numeric asm group", ProviderOptions{ProviderSendGrid: {OptionASMGroupID: "news"}}},
+		{"groups without group id", ProviderOptions{ProviderSendGrid: {OptionASMGroupsToDisplay: "1"}}},
+		{"too many groups", ProviderOptions{ProviderSendGrid: {OptionASMGroupID: "1", OptionASMGroupsToDisplay: tooManyGroups}}},
+		{"invalid group", ProviderOptions{ProviderSendGrid: {OptionASMGroupID: "1", OptionASMGroupsToDisplay: "1,x"}}},
+		{"empty ip pool", ProviderOptions{ProviderSendGrid: {OptionIPPoolName: ""}}},
+		{"long ip pool", ProviderOptions{ProviderSendGrid: {OptionIPPoolName: strings.Repeat("p", maxIPPoolNameLength+1)}}},
+		{"relative callback", ProviderOptions{ProviderTwilio: {OptionStatusCallback: "/status"}}},
+		{"ftp callback", ProviderOptions{ProviderTwilio: {OptionStatusCallback: "ftp://example.com/status"}}},
+		{"zero validity", ProviderOptions{ProviderTwilio: {OptionValidityPeriod: "0"}}},
+		{"long validity", ProviderOptions{ProviderTwilio: {OptionValidityPeriod: "36001"}}},
+		{"label with spaces", ProviderOptions{ProviderFCM: {OptionAnalyticsLabel: "spring sale"}}},
+		{"long label", ProviderOptions{ProviderFCM: {OptionAnalyticsLabel: strings.Repeat("a", 51)}}},
+	}
+	for _, tt := range tests {
+		if err := ValidateProviderOptions(tt.options); !errors.Is(err, ErrInvalidProviderOptions) {
+			t.Errorf("%s: ValidateProviderOptions() error = %v, want ErrInvalidProviderOptions", tt.name, err)
+		}
+	}
+}
+
+func TestProviderOptionsGet(t *testing.T) {
+	options := ProviderOptions{ProviderTwilio: {OptionValidityPeriod: "60"}}
+	if got := options.Get(ProviderTwilio, OptionValidityPeriod); got != "60" {
+		t.Errorf("Get(twilio, validity_period) = %q, want 60", got)
+	}
+	if got := options.Get(ProviderSendGrid, OptionIPPoolName); got != "" {
+		t.Errorf("Get(sendgrid, ip_pool_name) = %q, want empty", got)
+	}
+	if got := ProviderOptions(nil).Get(ProviderFCM, OptionAnalyticsLabel); got != "" {
+		t.Errorf("nil Get() = %q, want empty", got)
+	}
+}
+
+func TestParseASMGroups(t *testing.T) {
+	if got := ParseASMGroups(" 1, 2 ,3"); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("ParseASMGroups() = %v, want [1 2 3]", got)
+	}
+	if got := ParseASMGroups(""); got != nil {
+		t.Errorf("ParseASMGroups(\"\") = %v, want nil", got)
+	}
+}
@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Metadata keys that control platform-specific push options
+const (
+	MetadataBadge             = "badge"              // APNs badge count, a non-negative integer
+	MetadataCategory          = "category"           // APNs notification category
+	MetadataThreadID          = "thread_id"          // APNs thread for grouping
+	MetadataInterruptionLevel = "interruption_level" // APNs interruption level
+	MetadataAndroidChannelID  = "channel_id"         // Android notification channel
+	MetadataClickAction       = "click_action"       // Android click action
+)
+
+// ErrInvalidPushOptions is returned when push metadata options are malformed
+var ErrInvalidPushOptions = errors.New("invalid push options")
+
+// interruptionLevels are the values APNs accepts for interruption-level
+var interruptionLevels = map[string]bool{
+	"passive":        true,
+	"active":         true,
+	"time-sensitive": true,
+	"critical":       true,
+}
+
+// ValidatePushOptions checks the platform-specific push options in metadata
+func ValidatePushOptions(metadata map[string]string) error {
+	if badge, ok := metadata[MetadataBadge]; ok {
+		if _, err := ParseBadge(badge); err != nil {
+			return err
+		}
+	}
+
+	if level, ok := metadata[MetadataInterruptionLevel]; ok && !interruptionLevels[level] {
+		return fmt.Errorf("%w: interruption_level must be one of passive, active, time-sensitive or critical", ErrInvalidPushOptions)
+	}
+
+	return nil
+}
+
+// ParseBadge parses an APNs badge count, which must be a non-negative integer
+func ParseBadge(value string) (int, error) {
+	badge, err := strconv.Atoi(value)
+	if err != nil || badge < 0 {
+		return 0, fmt.Errorf("%w: badge must be a non-negative integer, got %q", ErrInvalidPushOptions, value)
+	}
+	return badge, nil
+}
@@ -0,0 +1,35 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePushOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"badge", map[string]string{MetadataBadge: "5"}, false},
+		{"cleared badge", map[string]string{MetadataBadge: "0"}, false},
+		{"negative badge", map[string]string{MetadataBadge: "-1"}, true},
+		{"non-numeric badge", map[string]string{MetadataBadge: "many"}, true},
+		{"interruption level", map[string]string{MetadataInterruptionLevel: "time-sensitive"}, false},
+		{"unknown interruption level", map[string]string{MetadataInterruptionLevel: "urgent"}, true},
+		{"free-form options", map[string]string{MetadataCategory: "MESSAGE", MetadataThreadID: "chat-42", MetadataAndroidChannelID: "messages"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePushOptions(tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePushOptions() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPushOptions) {
+				t.Errorf("ValidatePushOptions() error = %v, want ErrInvalidPushOptions", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+)
+
+// firstCursor is the ID RequeueChannel starts its keyset scan after
+const firstCursor = "00000000-0000-0000-0000-000000000000"
+
+// expectQueued expects notification n to be marked queued after its publish
+func expectQueued(mock *dbtest.Mock, n Notification) {
+	mock.ExpectQuery("UPDATE notifications SET queued_at = $1 WHERE id = $2").WithArgs(dbtest.AnyArg(), n.ID).
+		WillReturnRows(notificationRows(n))
+}
+
+func TestRequeueChannel(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	created := time.Now().Add(-time.Hour)
+	n1 := Notification{ID: "n1", UserID: "user-1", Channel: "email", Recipient: "a@example.com", Status: StatusPending, CreatedAt: created, UpdatedAt: created,
+		Metadata: map[string]string{"track_opens": "true"}, ProviderOptions: ProviderOptions{ProviderSendGrid: {OptionIPPoolName: "transactional"}}}
+	n2 := Notification{ID: "n2", UserID: "user-2", Channel: "email", Recipient: "b@example.com", Status: StatusPending, CreatedAt: created.Add(time.Second), UpdatedAt: created}
+
+	// Only pending email notifications are selected, in keyset batches
+	mock.ExpectQuery("WHERE channel = $1 AND status = $2 AND created_at <= $3").
+		WithArgs("email", StatusPending, dbtest.AnyArg(), time.Time{}, firstCursor, reconcileBatchSize).
+		WillReturnRows(notificationRows(n1, n2))
+	expectQueued(mock, n1)
+	expectQueued(mock, n2)
+	mock.ExpectQuery("WHERE channel = $1 AND status = $2 AND created_at <= $3").
+		WithArgs("email", StatusPending, dbtest.AnyArg(), n2.CreatedAt, "n2", reconcileBatchSize).
+		WillReturnRows(notificationRows())
+
+	requeued, err := s.RequeueChannel(context.Background(), "email", false)
+	if err != nil {
+		t.Fatalf("RequeueChannel() error = %v", err)
+	}
+	if requeued != 2 {
+		t.Errorf("RequeueChannel() = %d, want 2", requeued)
+	}
+	published := recorder.Published(t)
+	if len(published) != 2 || published[0].ID != "n1" || published[1].ID != "n2" {
+		t.Fatalf("published %+v, want n1 and n2", published)
+	}
+	// The stored metadata and provider options are republished
+	if !reflect.DeepEqual(published[0].Metadata, n1.Metadata) || !reflect.DeepEqual(ProviderOptions(published[0].ProviderOptions), n1.ProviderOptions) {
+		t.Errorf("published n1 with metadata %v and provider options %v, want %v and %v",
+			published[0].Metadata, published[0].ProviderOptions, n1.Metadata, n1.ProviderOptions)
+	}
+}
+
+func TestRequeueChannelIncludesFailed(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+
+	// Failed SMS notifications are reopened before the pending ones are read
+	mock.ExpectQuery("WHERE channel = $2 AND status = $3").WithArgs(StatusPending, "sms", StatusFailed).
+		WillReturnRows(dbtest.NewRows("id").AddRow("f1"))
+	expectEvent(mock, "f1", StatusPending)
+
+	reopened := Notification{ID: "f1", UserID: "user-1", Channel: "sms", Status: StatusPending, CreatedAt: time.Now().Add(-time.Hour)}
+	mock.ExpectQuery("WHERE channel = $1 AND status = $2").WithArgs("sms", StatusPending, dbtest.AnyArg(), dbtest.AnyArg(), firstCursor, reconcileBatchSize).
+		WillReturnRows(notificationRows(reopened))
+	expectQueued(mock, reopened)
+	mock.ExpectQuery("WHERE channel = $1 AND status = $2").WillReturnRows(notificationRows())
+
+	requeued, err := s.RequeueChannel(context.Background(), "sms", true)
+	if err != nil {
+		t.Fatalf("RequeueChannel() error = %v", err)
+	}
+	if published := recorder.Published(t); requeued != 1 || len(published) != 1 || published[0].ID != "f1" {
+		t.Errorf("RequeueChannel() = %d, published %+v, want f1", requeued, published)
+	}
+}
+
+func TestRequeueChannelErrors(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Channels: enabledChannels()})
+	if _, err := s.RequeueChannel(context.Background(), "email", false); !errors.Is(err, ErrNoProducer) {
+		t.Errorf("RequeueChannel() without producer error = %v, want ErrNoProducer", err)
+	}
+
+	s, _ = newTestService(t, nil)
+	s.producer, _ = queuetest.NewProducer(t)
+	if _, err := s.RequeueChannel(context.Background(), "email", false); !errors.Is(err, ErrChannelDisabled) {
+		t.Errorf("RequeueChannel() for a disabled channel error = %v, want ErrChannelDisabled", err)
+	}
+}
+
+func TestClaimDelivery(t *testing.T) {
+	s, _, _ := newTestServiceWithRedis(t, nil)
+	ctx := context.Background()
+	pending := &Notification{ID: "n1", Status: StatusPending}
+
+	// A requeued duplicate of a notification being delivered is skipped
+	if !s.ClaimDelivery(ctx, pending) {
+		t.Fatal("ClaimDelivery() = false for an unclaimed notification")
+	}
+	if s.ClaimDelivery(ctx, pending) {
+		t.Error("ClaimDelivery() = true for a claimed notification")
+	}
+
+	// A failed send releases the claim for the next attempt
+	s.ReleaseDelivery(ctx, "n1")
+	if !s.ClaimDelivery(ctx, pending) {
+		t.Error("ClaimDelivery() = false after the claim was released")
+	}
+
+	if s.ClaimDelivery(ctx, &Notification{ID: "n2", Status: StatusSent}) {
+		t.Error("ClaimDelivery() = true for a sent notification")
+	}
+}
+
+func TestClaimDeliveryWithoutDedupStore(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	pending := &Notification{ID: "n1", Status: StatusPending}
+
+	// Only the status is checked
+	if !s.ClaimDelivery(context.Background(), pending) || !s.ClaimDelivery(context.Background(), pending) {
+		t.Error("ClaimDelivery() = false for a pending notification without a dedup store")
+	}
+}
+
+func TestClaimDeliveryWithPostgresDedupStore(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Dedup: config.DedupConfig{Store: "postgres"}})
+	ctx := context.Background()
+	pending := &Notification{ID: "n1", Status: StatusPending}
+
+	// Claims are kept in dedup_claims without Redis
+	mock.ExpectQuery("INSERT INTO dedup_claims").WithArgs("delivery_claim:n1", dbtest.AnyArg()).WillReturnRows(dbtest.NewRows("key").AddRow("delivery_claim:n1"))
+	mock.ExpectQuery("INSERT INTO dedup_claims").WithArgs("delivery_claim:n1", dbtest.AnyArg()).WillReturnRows(dbtest.NewRows("key"))
+	if !s.ClaimDelivery(ctx, pending) {
+		t.Fatal("ClaimDelivery() = false for an unclaimed notification")
+	}
+	if s.ClaimDelivery(ctx, pending) {
+		t.Error("ClaimDelivery() = true for a claimed notification")
+	}
+
+	mock.ExpectExec("DELETE FROM dedup_claims WHERE key = $1").WithArgs("delivery_claim:n1").WillReturnResult(1)
+	s.ReleaseDelivery(ctx, "n1")
+
+	mock.ExpectExec("DELETE FROM dedup_claims WHERE expires_at <= NOW()").WillReturnResult(2)
+	if purged, err := s.PurgeDedupClaims(ctx); err != nil || purged != 2 {
+		t.Errorf("PurgeDedupClaims() = %d, %v, want 2", purged, err)
+	}
+}
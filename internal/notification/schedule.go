@@ -0,0 +1,246 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ScheduledBatchSize caps how many due scheduled notifications are dispatched per run
+const ScheduledBatchSize = 100
+
+// ErrNotReschedulable is returned when the schedule of a notification that was
+// already dispatched, or is no longer pending, is changed
+var ErrNotReschedulable = errors.New("notification can no longer be rescheduled")
+
+// ErrScheduleTooFar is returned when scheduled_at is further ahead than the
+// configured scheduling horizon
+var ErrScheduleTooFar = errors.New("scheduled_at is too far ahead")
+
+// checkScheduleHorizon rejects a scheduledAt further than the configured
+// maximum horizon from now
+func (s *Service) checkScheduleHorizon(scheduledAt *time.Time, now time.Time) error {
+	horizon := s.config.Scheduling.MaxHorizon
+	if scheduledAt == nil || horizon <= 0 || !scheduledAt.After(now.Add(horizon)) {
+		return nil
+	}
+	return fmt.Errorf("%w: notifications can be scheduled at most %s ahead", ErrScheduleTooFar, horizon)
+}
+
+// RescheduleNotification changes when a pending scheduled notification is sent.
+// A scheduledAt in the future moves the send to that time, earlier or later; a
+// nil scheduledAt, or one that has passed, sends it now. Notifications that were
+// already handed to the outbox or queue cannot be rescheduled, nor moved
+// outside their send window.
+func (s *Service) RescheduleNotification(ctx context.Context, id string, scheduledAt *time.Time) (*Notification, error) {
+	if err := s.checkScheduleHorizon(scheduledAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the notification first, so the dispatch check below sees an
+	// outbox row inserted by a concurrent DispatchScheduled
+	var status NotificationStatus
+	var queued bool
+	var windowStart, windowEnd sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, queued_at IS NOT NULL, send_window_start, send_window_end FROM notifications
+		WHERE id = $1 AND ($2 = '' OR tenant_id = $2)
+		FOR UPDATE`, id, TenantFromContext(ctx)).Scan(&status, &queued, &windowStart, &windowEnd)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+	if status != StatusPending {
+		return nil, fmt.Errorf("%w: notification %s is %s", ErrNotReschedulable, id, status)
+	}
+
+	var dispatched bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM outbox WHERE notification_id = $1 AND published_at IS NULL)`, id).Scan(&dispatched); err != nil {
+		return nil, fmt.Errorf("failed to check outbox: %w", err)
+	}
+	if queued || dispatched {
+		return nil, fmt.Errorf("%w: notification %s was already dispatched", ErrNotReschedulable, id)
+	}
+
+	now := time.Now()
+	due := scheduledAt == nil || !scheduledAt.After(now)
+	if due {
+		scheduledAt = nil
+	}
+
+	// The new send time must stay inside the send window
+	window := Notification{}
+	if windowStart.Valid {
+		window.SendWindowStart = &windowStart.Time
+	}
+	if windowEnd.Valid {
+		window.SendWindowEnd = &windowEnd.Time
+	}
+	sendAt := now
+	if !due {
+		sendAt = *scheduledAt
+	}
+	if reason := window.checkSendWindow(sendAt); reason != "" {
+		return nil, fmt.Errorf("%w: notification %s cannot be sent then, %s", ErrNotReschedulable, id, reason)
+	}
+
+	query := `UPDATE notifications SET scheduled_at = $2, updated_at = $3 WHERE id = $1 RETURNING ` + notificationColumns
+	notification, err := s.scanNotification(tx.QueryRowContext(ctx, query, id, scheduledAt, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reschedule notification: %w", err)
+	}
+
+	var outboxID int64
+	if due {
+		if outboxID, err = s.insertOutbox(ctx, tx, notification); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reschedule: %w", err)
+	}
+
+	detail := "sent now instead of as scheduled"
+	if !due {
+		detail = "rescheduled to " + scheduledAt.UTC().Format(time.RFC3339)
+	}
+	s.recordEvent(ctx, id, StatusPending, "", detail)
+	s.cacheNotification(ctx, notification)
+
+	if due {
+		if err := s.relayOutboxEntries(ctx, []int64{outboxID}); err != nil {
+			s.logger.Error("Failed to publish notification to queue, leaving it in the outbox",
+				zap.String("id", id),
+				zap.Error(err),
+			)
+		}
+	}
+	return notification, nil
+}
+
+// DispatchScheduled hands scheduled notifications whose scheduled_at has passed
+// to the outbox, earliest first, and publishes them. Those whose send window or
+// expiry passed while they were held are cancelled instead. Rows are locked while
+// they are dispatched, so concurrent runs in several API instances never
+// dispatch the same notification twice. It returns the number dispatched and
+// the number cancelled by channel, which is returned even when dispatching fails.
+func (s *Service) DispatchScheduled(ctx context.Context) (int, map[string]int, error) {
+	expired, err := s.expireScheduled(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, expired, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications
+		WHERE status = $1 AND queued_at IS NULL AND scheduled_at IS NOT NULL AND scheduled_at <= NOW()
+		  AND NOT EXISTS (SELECT 1 FROM outbox o WHERE o.notification_id = notifications.id AND o.published_at IS NULL)
+		ORDER BY scheduled_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, StatusPending, ScheduledBatchSize)
+	if err != nil {
+		return 0, expired, fmt.Errorf("failed to find due scheduled notifications: %w", err)
+	}
+
+	var due []*Notification
+	for rows.Next() {
+		notification, err := s.scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return 0, expired, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		due = append(due, notification)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, expired, fmt.Errorf("failed to find due scheduled notifications: %w", err)
+	}
+	if len(due) == 0 {
+		return 0, expired, nil
+	}
+
+	outboxIDs := make([]int64, 0, len(due))
+	for _, notification := range due {
+		outboxID, err := s.insertOutbox(ctx, tx, notification)
+		if err != nil {
+			return 0, expired, err
+		}
+		outboxIDs = append(outboxIDs, outboxID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, expired, fmt.Errorf("failed to commit scheduled dispatch: %w", err)
+	}
+
+	// Publish right away; if that fails the outbox relay publishes them later
+	if err := s.relayOutboxEntries(ctx, outboxIDs); err != nil {
+		s.logger.Error("Failed to publish scheduled notifications to queue, leaving them in the outbox",
+			zap.Int("count", len(outboxIDs)),
+			zap.Error(err),
+		)
+	}
+	return len(due), expired, nil
+}
+
+// expireScheduled cancels held notifications whose send window or expiry passed
+// before they were dispatched, e.g. while no API instance was running, and
+// returns the number cancelled by channel
+func (s *Service) expireScheduled(ctx context.Context) (map[string]int, error) {
+	query := `
+		UPDATE notifications
+		SET status = $1, updated_at = NOW(),
+		    error_message = CASE WHEN send_window_end <= NOW() THEN $3 ELSE 'expired' END
+		WHERE status = $2 AND queued_at IS NULL AND scheduled_at IS NOT NULL
+		  AND (send_window_end <= NOW() OR expires_at <= NOW())
+		  AND NOT EXISTS (SELECT 1 FROM outbox o WHERE o.notification_id = notifications.id AND o.published_at IS NULL)
+		RETURNING ` + notificationColumns
+
+	rows, err := s.db.QueryContext(ctx, query, StatusCancelled, StatusPending, detailWindowMissed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire scheduled notifications: %w", err)
+	}
+
+	var expired []*Notification
+	for rows.Next() {
+		notification, err := s.scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		expired = append(expired, notification)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to expire scheduled notifications: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, notification := range expired {
+		s.recordEvent(ctx, notification.ID, StatusCancelled, "", notification.ErrorMessage)
+		s.cacheNotification(ctx, notification)
+		counts[notification.Channel]++
+	}
+	if len(expired) > 0 {
+		s.logger.Info("Expired scheduled notifications", zap.Int("count", len(expired)))
+	}
+	return counts, nil
+}
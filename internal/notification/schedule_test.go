@@ -0,0 +1,307 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+)
+
+// expectRescheduleLock expects notification id to be locked for a reschedule,
+// returning status and whether it was queued or is waiting in the outbox
+func expectRescheduleLock(mock *dbtest.Mock, id string, status NotificationStatus, queued, inOutbox bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, queued_at IS NOT NULL, send_window_start, send_window_end FROM notifications").WithArgs(id, "").
+		WillReturnRows(dbtest.NewRows("status", "queued", "send_window_start", "send_window_end").AddRow(string(status), queued, nil, nil))
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM outbox WHERE notification_id = $1 AND published_at IS NULL)").WithArgs(id).
+		WillReturnRows(dbtest.NewRows("exists").AddRow(inOutbox))
+}
+
+// expectRescheduleEvent expects the reschedule of id to be recorded with detail
+func expectRescheduleEvent(mock *dbtest.Mock, id, detail string) {
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(id, "pending", nil, detail).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WithArgs(id, dbtest.AnyArg()).WillReturnResult(0)
+}
+
+func TestRescheduleNotification(t *testing.T) {
+	originally := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name        string
+		scheduledAt time.Time
+	}{
+		{"earlier", originally.Add(-time.Hour)},
+		{"later", originally.Add(24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, ScheduledAt: &tt.scheduledAt}
+
+			expectRescheduleLock(mock, "n1", StatusPending, false, false)
+			mock.ExpectQuery("UPDATE notifications SET scheduled_at = $2, updated_at = $3 WHERE id = $1").WithArgs("n1", tt.scheduledAt, dbtest.AnyArg()).
+				WillReturnRows(notificationRows(row))
+			// Nothing is dispatched: the scheduler sends it at the new time
+			mock.ExpectCommit()
+			expectRescheduleEvent(mock, "n1", "rescheduled to "+tt.scheduledAt.Format(time.RFC3339))
+
+			rescheduled, err := s.RescheduleNotification(context.Background(), "n1", &tt.scheduledAt)
+			if err != nil {
+				t.Fatalf("RescheduleNotification() error = %v", err)
+			}
+			if rescheduled.ScheduledAt == nil || !rescheduled.ScheduledAt.Equal(tt.scheduledAt) {
+				t.Errorf("ScheduledAt = %v, want %v", rescheduled.ScheduledAt, tt.scheduledAt)
+			}
+		})
+	}
+}
+
+func TestRescheduleNotificationSendNow(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	for name, scheduledAt := range map[string]*time.Time{"no time": nil, "past time": &past} {
+		t.Run(name, func(t *testing.T) {
+			s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+			producer, recorder := queuetest.NewProducer(t)
+			s.producer = producer
+			row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending}
+
+			expectRescheduleLock(mock, "n1", StatusPending, false, false)
+			mock.ExpectQuery("UPDATE notifications SET scheduled_at = $2").WithArgs("n1", nil, dbtest.AnyArg()).WillReturnRows(notificationRows(row))
+			mock.ExpectQuery("INSERT INTO outbox").WithArgs("n1", dbtest.AnyArg(), nil).WillReturnRows(dbtest.NewRows("id").AddRow(1))
+			mock.ExpectCommit()
+			expectRescheduleEvent(mock, "n1", "sent now instead of as scheduled")
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(outboxRows(row))
+			expectPublished(mock, 1, "n1")
+			mock.ExpectCommit()
+
+			rescheduled, err := s.RescheduleNotification(context.Background(), "n1", scheduledAt)
+			if err != nil {
+				t.Fatalf("RescheduleNotification() error = %v", err)
+			}
+			if rescheduled.ScheduledAt != nil {
+				t.Errorf("ScheduledAt = %v, want cleared", rescheduled.ScheduledAt)
+			}
+			if published := recorder.Published(t); len(published) != 1 || published[0].ID != "n1" {
+				t.Errorf("published %+v, want n1 right away", published)
+			}
+		})
+	}
+}
+
+func TestRescheduleNotificationRejected(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	t.Run("not found", func(t *testing.T) {
+		s, mock := newTestService(t, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").WillReturnRows(dbtest.NewRows("status", "queued", "send_window_start", "send_window_end"))
+		mock.ExpectRollback()
+
+		if _, err := s.RescheduleNotification(context.Background(), "n1", &later); !errors.Is(err, ErrNotificationNotFound) {
+			t.Errorf("RescheduleNotification() error = %v, want ErrNotificationNotFound", err)
+		}
+	})
+
+	t.Run("already sent", func(t *testing.T) {
+		s, mock := newTestService(t, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").
+			WillReturnRows(dbtest.NewRows("status", "queued", "send_window_start", "send_window_end").AddRow("sent", true, nil, nil))
+		mock.ExpectRollback()
+
+		if _, err := s.RescheduleNotification(context.Background(), "n1", &later); !errors.Is(err, ErrNotReschedulable) {
+			t.Errorf("RescheduleNotification() error = %v, want ErrNotReschedulable", err)
+		}
+	})
+
+	for name, queued := range map[string]bool{"queued": true, "waiting in the outbox": false} {
+		t.Run(name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			expectRescheduleLock(mock, "n1", StatusPending, queued, !queued)
+			mock.ExpectRollback()
+
+			if _, err := s.RescheduleNotification(context.Background(), "n1", &later); !errors.Is(err, ErrNotReschedulable) {
+				t.Errorf("RescheduleNotification() error = %v, want ErrNotReschedulable", err)
+			}
+		})
+	}
+
+	t.Run("outside the send window", func(t *testing.T) {
+		s, mock := newTestService(t, nil)
+		windowEnd := time.Now().Add(30 * time.Minute)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, queued_at IS NOT NULL").
+			WillReturnRows(dbtest.NewRows("status", "queued", "send_window_start", "send_window_end").AddRow("pending", false, nil, windowEnd))
+		mock.ExpectQuery("FROM outbox").WillReturnRows(dbtest.NewRows("exists").AddRow(false))
+		mock.ExpectRollback()
+
+		if _, err := s.RescheduleNotification(context.Background(), "n1", &later); !errors.Is(err, ErrNotReschedulable) {
+			t.Errorf("RescheduleNotification() error = %v, want ErrNotReschedulable", err)
+		}
+	})
+}
+
+func TestCheckScheduleHorizon(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name        string
+		horizon     time.Duration
+		scheduledAt *time.Time
+		wantErr     bool
+	}{
+		{"unscheduled", 90 * 24 * time.Hour, nil, false},
+		{"within horizon", 90 * 24 * time.Hour, at(30 * 24 * time.Hour), false},
+		{"at horizon", 90 * 24 * time.Hour, at(90 * 24 * time.Hour), false},
+		{"beyond horizon", 90 * 24 * time.Hour, at(90*24*time.Hour + time.Second), true},
+		{"no limit", 0, at(5 * 365 * 24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		s := &Service{config: &config.Config{Scheduling: config.SchedulingConfig{MaxHorizon: tt.horizon}}}
+		err := s.checkScheduleHorizon(tt.scheduledAt, now)
+		if tt.wantErr != errors.Is(err, ErrScheduleTooFar) {
+			t.Errorf("%s: checkScheduleHorizon() error = %v, want ErrScheduleTooFar %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateNotificationScheduleHorizon(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels(), Scheduling: config.SchedulingConfig{MaxHorizon: 90 * 24 * time.Hour}}
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	// Rejected before anything is stored
+	tooFar := time.Now().Add(91 * 24 * time.Hour)
+	expectPreferences(mock, "user-1")
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", ScheduledAt: &tooFar,
+	})
+	if !errors.Is(err, ErrScheduleTooFar) {
+		t.Errorf("CreateNotification() beyond horizon error = %v, want ErrScheduleTooFar", err)
+	}
+
+	// Stored scheduled, without an outbox entry
+	scheduledAt := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	args := insertArgs("+15551234567", nil)
+	args[7] = scheduledAt
+	expectPreferences(mock, "user-1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, ScheduledAt: &scheduledAt}))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+
+	notif, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", ScheduledAt: &scheduledAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() within horizon error = %v", err)
+	}
+	if notif.ScheduledAt == nil || !notif.ScheduledAt.Equal(scheduledAt) {
+		t.Errorf("ScheduledAt = %v, want %v", notif.ScheduledAt, scheduledAt)
+	}
+	if notif.DeferredReason != DeferredScheduled {
+		t.Errorf("DeferredReason = %q, want %q", notif.DeferredReason, DeferredScheduled)
+	}
+}
+
+func TestRescheduleNotificationBeyondHorizon(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Scheduling: config.SchedulingConfig{MaxHorizon: 24 * time.Hour}})
+
+	tooFar := time.Now().Add(48 * time.Hour)
+	if _, err := s.RescheduleNotification(context.Background(), "n1", &tooFar); !errors.Is(err, ErrScheduleTooFar) {
+		t.Errorf("RescheduleNotification() error = %v, want ErrScheduleTooFar", err)
+	}
+}
+
+func TestDispatchScheduledSendsDueNotifications(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+	due := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending}
+
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WithArgs(StatusCancelled, StatusPending, detailWindowMissed).WillReturnRows(notificationRows())
+	mock.ExpectBegin()
+	// Only notifications whose current scheduled_at has passed are picked up
+	mock.ExpectQuery("WHERE status = $1 AND queued_at IS NULL AND scheduled_at IS NOT NULL AND scheduled_at <= NOW()").WithArgs(StatusPending, ScheduledBatchSize).
+		WillReturnRows(notificationRows(due))
+	mock.ExpectQuery("INSERT INTO outbox").WithArgs("n1", dbtest.AnyArg(), nil).WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(outboxRows(due))
+	expectPublished(mock, 1, "n1")
+	mock.ExpectCommit()
+
+	dispatched, expired, err := s.DispatchScheduled(context.Background())
+	if err != nil || dispatched != 1 || len(expired) != 0 {
+		t.Fatalf("DispatchScheduled() = %d, %v, %v, want 1 dispatched and none expired", dispatched, expired, err)
+	}
+	if published := recorder.Published(t); len(published) != 1 || published[0].ID != "n1" {
+		t.Errorf("published %+v, want n1", published)
+	}
+}
+
+func TestDispatchScheduledKeepsMetadata(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	s.producer, _ = queuetest.NewProducer(t)
+	due := Notification{
+		ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending, Priority: PriorityMedium,
+		Metadata:        map[string]string{"media_urls": "https://example.com/a.png"},
+		ProviderOptions: ProviderOptions{ProviderTwilio: {OptionStatusCallback: "https://example.com/cb"}},
+	}
+	payload, err := json.Marshal(queueMessage(&due))
+	if err != nil {
+		t.Fatalf("failed to marshal queue message: %v", err)
+	}
+
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WillReturnRows(notificationRows())
+	mock.ExpectBegin()
+	mock.ExpectQuery("scheduled_at <= NOW()").WillReturnRows(notificationRows(due))
+	// The stored metadata and provider options are published with it
+	mock.ExpectQuery("INSERT INTO outbox").WithArgs("n1", payload, nil).WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(outboxRows(due))
+	expectPublished(mock, 1, "n1")
+	mock.ExpectCommit()
+
+	if dispatched, _, err := s.DispatchScheduled(context.Background()); err != nil || dispatched != 1 {
+		t.Fatalf("DispatchScheduled() = %d, %v, want 1 dispatched", dispatched, err)
+	}
+}
+
+func TestDispatchScheduledCountsExpired(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	past := time.Now().Add(-time.Minute)
+	expired := []Notification{
+		{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusCancelled, ErrorMessage: "expired", ExpiresAt: &past},
+		{ID: "n2", UserID: "user-1", Channel: "email", Status: StatusCancelled, ErrorMessage: "expired", ExpiresAt: &past},
+		{ID: "n3", UserID: "user-2", Channel: "sms", Status: StatusCancelled, ErrorMessage: detailWindowMissed, SendWindowEnd: &past},
+	}
+
+	// Counted even when dispatching the due notifications fails afterwards
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WithArgs(StatusCancelled, StatusPending, detailWindowMissed).WillReturnRows(notificationRows(expired...))
+	for _, notification := range expired {
+		mock.ExpectExec("INSERT INTO notification_events").WithArgs(notification.ID, string(StatusCancelled), nil, notification.ErrorMessage).WillReturnResult(1)
+		mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+	}
+	mock.ExpectBegin().WillReturnError(errors.New("connection reset"))
+
+	dispatched, counts, err := s.DispatchScheduled(context.Background())
+	if err == nil || dispatched != 0 {
+		t.Errorf("DispatchScheduled() = %d, %v, want the begin failure", dispatched, err)
+	}
+	if want := map[string]int{"sms": 2, "email": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("DispatchScheduled() expired %v, want %v", counts, want)
+	}
+}
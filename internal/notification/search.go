@@ -0,0 +1,151 @@
+package notification
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchNotificationsFilter holds the query, scope and pagination options for
+// a full-text notification search
+type SearchNotificationsFilter struct {
+	Query     string // web search syntax: words, "quoted phrases", or, -excluded
+	UserID    string
+	Channel   string
+	TenantID  string // only the tenant's notifications, set from the request's API key
+	Limit     int
+	PageToken string
+}
+
+// SearchNotifications returns a page of notifications whose subject or body
+// match the query, best matches first. Subject matches rank above body
+// matches; notifications with equal rank are ordered newest first.
+func (s *Service) SearchNotifications(ctx context.Context, filter SearchNotificationsFilter) (*ListNotificationsResult, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	args := []interface{}{filter.Query}
+	conditions := []string{"search_vector @@ websearch_to_tsquery('english', $1)"}
+	addCondition := func(expr string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(expr, len(args)))
+	}
+
+	// Requests authenticated with a tenant API key only search the tenant's notifications
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		filter.TenantID = tenantID
+	}
+	if filter.TenantID != "" {
+		addCondition("tenant_id = $%d", filter.TenantID)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Channel != "" {
+		addCondition("channel = $%d", filter.Channel)
+	}
+
+	var total int
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications"+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	const rank = "ts_rank(search_vector, websearch_to_tsquery('english', $1))"
+
+	// Continue after the last row of the previous page
+	if filter.PageToken != "" {
+		cursorRank, cursorTime, cursorID, err := decodeSearchPageToken(filter.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursorRank, cursorTime, cursorID)
+		conditions = append(conditions, fmt.Sprintf("(%s, created_at, id) < ($%d::real, $%d, $%d)", rank, len(args)-2, len(args)-1, len(args)))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Fetch one extra row to know whether another page exists
+	args = append(args, limit+1)
+	query := `SELECT ` + notificationColumns + `, ` + rank + ` FROM notifications` + where +
+		fmt.Sprintf(" ORDER BY %s DESC, created_at DESC, id DESC LIMIT $%d", rank, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*Notification, 0, limit)
+	ranks := make([]float32, 0, limit)
+	for rows.Next() {
+		var rank float32
+		notification, err := s.scanNotification(rankedRow{rows: rows, rank: &rank})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search notifications: %w", err)
+	}
+
+	result := &ListNotificationsResult{TotalCount: total}
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		result.NextPageToken = encodeSearchPageToken(ranks[limit-1], last.CreatedAt, last.ID)
+	}
+	result.Notifications = notifications
+
+	return result, nil
+}
+
+// rankedRow scans a notification followed by its search rank
+type rankedRow struct {
+	rows rowScanner
+	rank *float32
+}
+
+// Scan implements rowScanner
+func (r rankedRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.rank)...)
+}
+
+// encodeSearchPageToken builds an opaque cursor from the last row of a search page
+func encodeSearchPageToken(rank float32, createdAt time.Time, id string) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSearchPageToken parses a cursor built by encodeSearchPageToken
+func decodeSearchPageToken(token string) (float32, time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, time.Time{}, "", ErrInvalidPageToken
+	}
+
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return 0, time.Time{}, "", ErrInvalidPageToken
+	}
+
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return 0, time.Time{}, "", ErrInvalidPageToken
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return 0, time.Time{}, "", ErrInvalidPageToken
+	}
+
+	return float32(rank), createdAt, parts[2], nil
+}
@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+// searchMatch is a notification matching a search, with its rank
+type searchMatch struct {
+	notification Notification
+	rank         float32
+}
+
+// searchRows returns matches as rows of a search query: the notification
+// columns followed by the rank
+func searchRows(matches ...searchMatch) *dbtest.Rows {
+	rows := dbtest.NewRows(append(notificationColumnNames(), "rank")...)
+	for _, m := range matches {
+		rows.AddRow(append(notificationRowValues(m.notification), m.rank)...)
+	}
+	return rows
+}
+
+const (
+	searchWhere = "WHERE search_vector @@ websearch_to_tsquery('english', $1)"
+	searchOrder = "ORDER BY ts_rank(search_vector, websearch_to_tsquery('english', $1)) DESC, created_at DESC, id DESC"
+)
+
+func TestSearchNotificationsRanksMatches(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	subject := searchMatch{Notification{ID: "n1", UserID: "user-1", Channel: "email", Subject: "Password reset", Body: "Use the link", CreatedAt: createdAt}, 0.9}
+	body := searchMatch{Notification{ID: "n2", UserID: "user-1", Channel: "email", Subject: "Security", Body: "Your password reset is ready", CreatedAt: createdAt.Add(time.Hour)}, 0.4}
+	weak := searchMatch{Notification{ID: "n3", UserID: "user-1", Channel: "sms", Body: "password changed", CreatedAt: createdAt}, 0.1}
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications "+searchWhere+" AND user_id = $2").WithArgs("password reset", "user-1").
+		WillReturnRows(dbtest.NewRows("count").AddRow(3))
+	mock.ExpectQuery(searchWhere+" AND user_id = $2 "+searchOrder+" LIMIT $3").WithArgs("password reset", "user-1", 3).
+		WillReturnRows(searchRows(subject, body, weak))
+
+	result, err := s.SearchNotifications(context.Background(), SearchNotificationsFilter{Query: "password reset", UserID: "user-1", Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchNotifications() error = %v", err)
+	}
+	if result.TotalCount != 3 || len(result.Notifications) != 2 {
+		t.Fatalf("result = %d of %d, want 2 of 3", len(result.Notifications), result.TotalCount)
+	}
+	// Best matches first, as ranked by the database, even when newer matches rank lower
+	if result.Notifications[0].ID != "n1" || result.Notifications[1].ID != "n2" {
+		t.Errorf("matches = %s, %s, want n1, n2", result.Notifications[0].ID, result.Notifications[1].ID)
+	}
+
+	rank, cursorTime, cursorID, err := decodeSearchPageToken(result.NextPageToken)
+	if err != nil || rank != 0.4 || !cursorTime.Equal(body.notification.CreatedAt) || cursorID != "n2" {
+		t.Errorf("next page token = %v, %v, %q, %v, want after n2", rank, cursorTime, cursorID, err)
+	}
+
+	// The next page continues below the last rank
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications " + searchWhere + " AND user_id = $2").WillReturnRows(dbtest.NewRows("count").AddRow(3))
+	mock.ExpectQuery(searchWhere+" AND user_id = $2 AND (ts_rank(search_vector, websearch_to_tsquery('english', $1)), created_at, id) < ($3::real, $4, $5) "+searchOrder+" LIMIT $6").
+		WithArgs("password reset", "user-1", float32(0.4), dbtest.AnyArg(), "n2", 3).
+		WillReturnRows(searchRows(weak))
+
+	result, err = s.SearchNotifications(context.Background(), SearchNotificationsFilter{Query: "password reset", UserID: "user-1", Limit: 2, PageToken: result.NextPageToken})
+	if err != nil {
+		t.Fatalf("SearchNotifications() page 2 error = %v", err)
+	}
+	if len(result.Notifications) != 1 || result.Notifications[0].ID != "n3" || result.NextPageToken != "" {
+		t.Errorf("page 2 = %+v, want only n3 and no further page", result)
+	}
+}
+
+func TestSearchNotificationsScopedToTenant(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	ctx := WithTenant(context.Background(), "tenant-a")
+
+	// The API key's tenant wins over one given in the filter
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications "+searchWhere+" AND tenant_id = $2 AND channel = $3").WithArgs("invoice", "tenant-a", "email").
+		WillReturnRows(dbtest.NewRows("count").AddRow(0))
+	mock.ExpectQuery(searchWhere+" AND tenant_id = $2 AND channel = $3 "+searchOrder+" LIMIT $4").WithArgs("invoice", "tenant-a", "email", defaultListLimit+1).
+		WillReturnRows(searchRows())
+
+	result, err := s.SearchNotifications(ctx, SearchNotificationsFilter{Query: "invoice", TenantID: "tenant-b", Channel: "email"})
+	if err != nil {
+		t.Fatalf("SearchNotifications() error = %v", err)
+	}
+	if result.TotalCount != 0 || len(result.Notifications) != 0 || result.NextPageToken != "" {
+		t.Errorf("result = %+v, want no matches", result)
+	}
+}
+
+func TestSearchNotificationsInvalidPageToken(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	for _, token := range []string{"not base64!", "bm8tc2VwYXJhdG9ycw", encodeSearchPageToken(0.5, time.Now(), "")} {
+		mock.ExpectQuery("SELECT COUNT(*) FROM notifications").WillReturnRows(dbtest.NewRows("count").AddRow(1))
+		_, err := s.SearchNotifications(context.Background(), SearchNotificationsFilter{Query: "reset", PageToken: token})
+		if !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("SearchNotifications(page_token=%q) error = %v, want ErrInvalidPageToken", token, err)
+		}
+	}
+}
+
+func TestSearchPageTokenRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	rank, gotTime, id, err := decodeSearchPageToken(encodeSearchPageToken(0.0607927, createdAt, "n|1"))
+	if err != nil {
+		t.Fatalf("decodeSearchPageToken() error = %v", err)
+	}
+	if rank != 0.0607927 || !gotTime.Equal(createdAt) || id != "n|1" {
+		t.Errorf("decoded %v, %v, %q, want the encoded cursor", rank, gotTime, id)
+	}
+}
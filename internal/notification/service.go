@@ -3,130 +3,1033 @@ package notification
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/alexnthnz/notification-system/internal/database"
 	"github.com/alexnthnz/notification-system/internal/queue"
-	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+
+	// pausePollInterval is how often paused workers re-check the pause flag
+	pausePollInterval = 5 * time.Second
+	// reconcileBatchSize caps how many unqueued notifications are republished per run
+	reconcileBatchSize = 100
+	// deliveryClaimTTL is how long a worker's claim on a notification lasts
+	deliveryClaimTTL = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidPageToken is returned when a list page token cannot be decoded
+	ErrInvalidPageToken = errors.New("invalid page token")
+	// ErrNotResendable is returned when resending a notification that has not failed
+	ErrNotResendable = errors.New("only failed notifications can be resent")
+	// ErrChannelDisabled is returned when a channel has been disabled in configuration
+	ErrChannelDisabled = errors.New("channel is disabled")
+	// ErrNotificationNotFound is returned when a notification does not exist or
+	// belongs to another tenant
+	ErrNotificationNotFound = errors.New("notification not found")
+	// ErrNoRecipient is returned when no recipient was given and none could be resolved for the user
+	ErrNoRecipient = errors.New("no recipient for channel")
+	// ErrNotificationsDisabled is returned when the user has turned off notifications on the channel
+	ErrNotificationsDisabled = errors.New("notifications disabled")
+	// ErrInvalidStatusTransition is returned when a status update would move a
+	// notification backwards or out of a terminal status
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	// ErrNoProducer is returned when publishing from a service constructed without a queue producer,
+	// such as the one used by the channel workers
+	ErrNoProducer = errors.New("notification service has no queue producer")
 )
 
 // Service handles notification business logic
 type Service struct {
-	db       *database.PostgresDB
-	redis    *database.RedisClient
-	producer *queue.Producer
+	config    *config.Config
+	db        *database.PostgresDB
+	redis     *database.RedisClient
+	dedup     database.DedupStore // nil without Redis when claims are kept in Redis
+	producer  *queue.Producer
+	ids       IDGenerator
+	cipher    *fieldCipher // nil unless encryption at rest is configured
+	callbacks *http.Client // delivers status callbacks
+	logger    *zap.Logger
 }
 
-// NewService creates a new notification service
-func NewService(db *database.PostgresDB, redis *database.RedisClient, producer *queue.Producer) *Service {
+// NewService creates a new notification service. It fails when the encryption
+// keys in cfg are invalid.
+func NewService(cfg *config.Config, db *database.PostgresDB, redis *database.RedisClient, producer *queue.Producer, logger *zap.Logger) (*Service, error) {
+	cipher, err := newFieldCipher(cfg.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption configuration: %w", err)
+	}
+	if cipher != nil {
+		logger.Info("Encrypting notification recipients and bodies at rest", zap.Int("key_version", cipher.keyVersion()))
+	}
+	dedup, err := database.NewDedupStore(cfg.Dedup.Store, db, redis)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dedup configuration: %w", err)
+	}
+
 	return &Service{
+		config:   cfg,
 		db:       db,
 		redis:    redis,
+		dedup:    dedup,
 		producer: producer,
-	}
+		ids:      NewIDGenerator(cfg.API.IDGenerator, logger),
+		cipher:   cipher,
+		callbacks: &http.Client{
+			Timeout: cfg.Callbacks.Timeout,
+			// Callbacks go to the URL given, never to where it redirects
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: logger,
+	}, nil
 }
 
 // CreateNotification creates a new notification request
 func (s *Service) CreateNotification(ctx context.Context, req NotificationRequest) (*Notification, error) {
+	return s.createNotification(ctx, req, "")
+}
+
+// CreateNotificationGroup fans a request out to each of req.Recipients, creating
+// one notification per recipient linked by a shared group ID. Every recipient
+// is validated first and the group is stored and queued in one transaction, so
+// if any recipient fails nothing is created and the request can be retried.
+func (s *Service) CreateNotificationGroup(ctx context.Context, req NotificationRequest) (*NotificationGroup, error) {
+	groupID := s.ids.NewID()
+
+	batch := make([]pendingNotification, 0, len(req.Recipients))
+	for i, recipient := range req.Recipients {
+		single := req
+		single.Recipient = recipient
+		single.Recipients = nil
+
+		pending, err := s.prepareNotification(ctx, single, groupID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notification for recipient %d of %d: %w", i+1, len(req.Recipients), err)
+		}
+		batch = append(batch, *pending)
+	}
+	if err := s.storeNotifications(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	group := &NotificationGroup{
+		GroupID:       groupID,
+		Notifications: make([]*Notification, 0, len(batch)),
+	}
+	for _, pending := range batch {
+		group.Notifications = append(group.Notifications, pending.notification)
+	}
+
+	s.logger.Info("Created notification group",
+		zap.String("group_id", group.GroupID),
+		zap.String("user_id", req.UserID),
+		zap.String("channel", req.Channel),
+		zap.Int("count", len(group.Notifications)),
+	)
+	return group, nil
+}
+
+// createNotification creates a single notification, optionally as part of a group
+func (s *Service) createNotification(ctx context.Context, req NotificationRequest, groupID string) (*Notification, error) {
+	pending, err := s.prepareNotification(ctx, req, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeNotifications(ctx, []pendingNotification{*pending}); err != nil {
+		return nil, err
+	}
+	return pending.notification, nil
+}
+
+// CreateNotifications creates the notifications for reqs, storing all that
+// pass validation in one transaction and publishing them together. It returns
+// the created notification, or the error, for each request in order.
+func (s *Service) CreateNotifications(ctx context.Context, reqs []NotificationRequest) ([]*Notification, []error) {
+	notifications := make([]*Notification, len(reqs))
+	errs := make([]error, len(reqs))
+
+	var batch []pendingNotification
+	var positions []int
+	for i, req := range reqs {
+		pending, err := s.prepareNotification(ctx, req, "")
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, *pending)
+		positions = append(positions, i)
+	}
+	if len(batch) == 0 {
+		return notifications, errs
+	}
+
+	if err := s.storeNotifications(ctx, batch); err != nil {
+		for _, i := range positions {
+			errs[i] = err
+		}
+		return notifications, errs
+	}
+	for j, i := range positions {
+		notifications[i] = batch[j].notification
+	}
+	return notifications, errs
+}
+
+// pendingNotification is a validated notification waiting to be stored
+type pendingNotification struct {
+	notification *Notification
+}
+
+// prepareNotification validates req and builds the notification to store:
+// it selects the channel, resolves the recipient, checks the channel options
+// and renders the content
+func (s *Service) prepareNotification(ctx context.Context, req NotificationRequest, groupID string) (*pendingNotification, error) {
 	// Generate unique ID
-	id := uuid.New().String()
+	id := s.ids.NewID()
+
+	if req.Category == "" {
+		req.Category = CategoryTransactional
+	}
+
+	// Pick the requested channel, or the first fallback the user can receive on
+	channel, preferences, err := s.selectChannel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if channel != req.Channel {
+		s.logger.Info("Falling back to another channel",
+			zap.String("user_id", req.UserID),
+			zap.String("requested_channel", req.Channel),
+			zap.String("channel", channel),
+		)
+		// The given recipient is an address on the requested channel
+		req.Recipient = ""
+		req.Channel = channel
+	}
+
+	// Look up the user's contact for this channel when no recipient was given
+	if req.Recipient == "" {
+		recipient, err := s.resolveRecipient(ctx, req.UserID, req.Channel)
+		if err != nil {
+			return nil, err
+		}
+		req.Recipient = recipient
+	}
 
-	// Validate user preferences
-	preferences, err := s.getUserPreferences(ctx, req.UserID, req.Channel)
+	if err := s.validateCallbackURL(req.CallbackURL); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	heldForWindow, err := applySendWindow(&req, now)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+		return nil, err
+	}
+	if err := s.checkScheduleHorizon(req.ScheduledAt, now); err != nil {
+		return nil, err
+	}
+
+	// Note why the notification is held instead of queued right away
+	var deferred string
+	switch {
+	case heldForWindow:
+		deferred = DeferredSendWindow
+	case req.ScheduledAt != nil && req.ScheduledAt.After(now):
+		deferred = DeferredScheduled
+	}
+
+	// Validate attachments before anything is stored
+	if len(req.Attachments) > 0 {
+		if req.Channel != "email" {
+			return nil, &AttachmentError{Filename: req.Attachments[0].Filename, Reason: "attachments are only supported for email"}
+		}
+		if err := ValidateAttachments(req.Attachments, s.config.Channels.SendGrid.Attachments); err != nil {
+			return nil, err
+		}
+	}
+
+	// Don't email addresses that hard-bounced or complained
+	if req.Channel == "email" {
+		if err := s.checkSuppressed(ctx, req.Recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ValidateProviderOptions(req.ProviderOptions); err != nil {
+		return nil, err
+	}
+
+	// Validate channel-specific options carried in metadata
+	switch req.Channel {
+	case "push":
+		if err := ValidatePushOptions(req.Metadata); err != nil {
+			return nil, err
+		}
+	case "email":
+		if err := ValidateEmailOptions(req.Metadata); err != nil {
+			return nil, err
+		}
+	case "sms":
+		if err := ValidateSMSOptions(req.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	// Render the subject and body from a template in the user's language
+	if req.Template != "" {
+		locale := req.Locale
+		if locale == "" {
+			locale = preferences.Locale
+		}
+
+		tmpl, err := s.getTemplate(ctx, req.Template, req.Channel, locale)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Subject, req.Body, err = renderTemplate(tmpl, req.Variables)
+		if err != nil {
+			return nil, err
+		}
+	} else if s.config.Channels.InlineTemplates {
+		req.Subject, req.Body, err = renderInline(req.Subject, req.Body, req.Variables)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if !preferences.Enabled {
-		return nil, fmt.Errorf("notifications disabled for user %s on channel %s", req.UserID, req.Channel)
+	// Enforce the channel's subject and body limits on the final content
+	var truncated []string
+	req.Subject, req.Body, truncated, err = applyContentLimits(s.config.Channels.Limits(req.Channel), req.Channel, req.Subject, req.Body)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set default priority if not specified
 	priority := req.Priority
 	if priority == 0 {
-		priority = 2 // Medium priority
+		priority = PriorityMedium
 	}
 
 	// Create notification record
 	notification := &Notification{
-		ID:          id,
-		UserID:      req.UserID,
-		Channel:     req.Channel,
-		Recipient:   req.Recipient,
-		Subject:     req.Subject,
-		Body:        req.Body,
-		Status:      StatusPending,
-		RetryCount:  0,
-		ScheduledAt: req.ScheduledAt,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		Metadata:    req.Metadata,
-	}
-
-	// Insert into database
+		ID:              id,
+		UserID:          req.UserID,
+		Channel:         req.Channel,
+		Recipient:       req.Recipient,
+		Subject:         req.Subject,
+		Body:            req.Body,
+		Status:          StatusPending,
+		RetryCount:      0,
+		ScheduledAt:     req.ScheduledAt,
+		ExpiresAt:       req.ExpiresAt,
+		SendWindowStart: req.SendWindowStart,
+		SendWindowEnd:   req.SendWindowEnd,
+		GroupID:         groupID,
+		CollapseKey:     req.CollapseKey,
+		TenantID:        TenantFromContext(ctx),
+		Category:        req.Category,
+		CallbackURL:     req.CallbackURL,
+		Priority:        priority,
+		CreatedBy:       principalFromContext(ctx),
+		Source:          SourceFromContext(ctx),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		Metadata:        req.Metadata,
+		ProviderOptions: req.ProviderOptions,
+		Attachments:     req.Attachments,
+		Truncated:       truncated,
+		DeferredReason:  deferred,
+	}
+
+	return &pendingNotification{notification: notification}, nil
+}
+
+// storeNotifications inserts the notifications, and the queue messages of
+// those due now into the outbox, in one transaction so each is queued if and
+// only if it is stored. The outbox rows are then published right away; rows
+// that fail to publish are left to the outbox relay.
+func (s *Service) storeNotifications(ctx context.Context, pending []pendingNotification) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notifications (id, user_id, channel, recipient, subject, body, status, scheduled_at, expires_at, group_id, attachments, collapse_key, tenant_id, category, created_at, updated_at, key_version, callback_url, priority, send_window_start, send_window_end, created_by, source, metadata, provider_options)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+		RETURNING ` + notificationColumns
+
+	stored := make([]*Notification, 0, len(pending))
+	var outboxIDs []int64
+	for _, p := range pending {
+		notification := p.notification
+		attachments, err := marshalAttachments(notification.Attachments)
+		if err != nil {
+			return err
+		}
+		recipient, body, keyVersion, err := s.cipher.encryptFields(notification.ID, notification.Recipient, notification.Body)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt notification: %w", err)
+		}
+		metadata, providerOptions, err := marshalMetadata(notification.Metadata, notification.ProviderOptions)
+		if err != nil {
+			return err
+		}
+
+		row, err := s.scanNotification(tx.QueryRowContext(ctx, query,
+			notification.ID, notification.UserID, notification.Channel, recipient,
+			notification.Subject, body, notification.Status, notification.ScheduledAt,
+			notification.ExpiresAt, nullIfEmpty(notification.GroupID), attachments, nullIfEmpty(notification.CollapseKey),
+			nullIfEmpty(notification.TenantID), notification.Category, notification.CreatedAt, notification.UpdatedAt,
+			nullIfZero(keyVersion), nullIfEmpty(notification.CallbackURL), notification.Priority,
+			notification.SendWindowStart, notification.SendWindowEnd,
+			nullIfEmpty(notification.CreatedBy), nullIfEmpty(notification.Source), metadata, providerOptions,
+		))
+		if err != nil {
+			return fmt.Errorf("failed to insert notification: %w", err)
+		}
+		stored = append(stored, row)
+
+		// Scheduled notifications are published when they are due
+		if !isHeld(notification, time.Now()) {
+			outboxID, err := s.insertOutbox(ctx, tx, notification)
+			if err != nil {
+				return err
+			}
+			outboxIDs = append(outboxIDs, outboxID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notifications: %w", err)
+	}
+	for i, p := range pending {
+		s.recordEvent(ctx, p.notification.ID, p.notification.Status, "", "created")
+		s.cacheNotification(ctx, stored[i])
+		s.logger.Info("Created notification",
+			zap.String("id", p.notification.ID),
+			zap.String("user_id", p.notification.UserID),
+			zap.String("channel", p.notification.Channel),
+		)
+	}
+
+	// Publish right away; if that fails the outbox relay publishes them later
+	if len(outboxIDs) > 0 {
+		if err := s.relayOutboxEntries(ctx, outboxIDs); err != nil {
+			s.logger.Error("Failed to publish notifications to queue, leaving them in the outbox",
+				zap.Int("count", len(outboxIDs)),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil
+}
+
+// selectChannel returns the first of the requested channel and its fallback
+// channels that is enabled both globally and in the user's preferences for the
+// request's category, together with the user's preferences for it
+func (s *Service) selectChannel(ctx context.Context, req NotificationRequest) (string, *UserPreference, error) {
+	candidates := append([]string{req.Channel}, req.FallbackChannels...)
+
+	var firstErr error
+	for _, channel := range candidates {
+		// Skip channels that are turned off globally
+		if !s.config.Channels.IsEnabled(channel) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%w: %s", ErrChannelDisabled, channel)
+			}
+			continue
+		}
+
+		preferences, err := s.getUserPreferences(ctx, req.UserID, channel, req.Category)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get user preferences: %w", err)
+		}
+		if preferences.Enabled {
+			return channel, preferences, nil
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w for user %s on channel %s (%s)", ErrNotificationsDisabled, req.UserID, channel, req.Category)
+		}
+	}
+
+	if len(candidates) > 1 {
+		return "", nil, fmt.Errorf("%w for user %s on channels %s", ErrNotificationsDisabled, req.UserID, strings.Join(candidates, ", "))
+	}
+	return "", nil, firstErr
+}
+
+// ResendNotification creates a copy of a failed notification and queues it for delivery.
+// The new notification references the original through ResentFrom.
+func (s *Service) ResendNotification(ctx context.Context, id string) (*Notification, error) {
+	original, err := s.GetNotification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.Status != StatusFailed {
+		return nil, fmt.Errorf("%w: notification %s is %s", ErrNotResendable, id, original.Status)
+	}
+
+	// Don't create a copy that could never be queued
+	if s.producer == nil {
+		return nil, fmt.Errorf("%w: cannot resend notification %s", ErrNoProducer, id)
+	}
+
+	// The address may have bounced since the original was sent
+	if original.Channel == "email" {
+		if err := s.checkSuppressed(ctx, original.Recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	notification := &Notification{
+		ID:              s.ids.NewID(),
+		UserID:          original.UserID,
+		Channel:         original.Channel,
+		Recipient:       original.Recipient,
+		Subject:         original.Subject,
+		Body:            original.Body,
+		Status:          StatusPending,
+		ResentFrom:      original.ID,
+		CollapseKey:     original.CollapseKey,
+		TenantID:        original.TenantID,
+		Category:        original.Category,
+		CallbackURL:     original.CallbackURL,
+		Priority:        original.Priority,
+		CreatedBy:       principalFromContext(ctx),
+		Source:          SourceFromContext(ctx),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Metadata:        original.Metadata,
+		ProviderOptions: original.ProviderOptions,
+		Attachments:     original.Attachments,
+	}
+
+	attachments, err := marshalAttachments(notification.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	recipient, body, keyVersion, err := s.cipher.encryptFields(notification.ID, notification.Recipient, notification.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt notification: %w", err)
+	}
+
+	metadata, providerOptions, err := marshalMetadata(notification.Metadata, notification.ProviderOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO notifications (id, user_id, channel, recipient, subject, body, status, scheduled_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO notifications (id, user_id, channel, recipient, subject, body, status, resent_from, attachments, collapse_key, tenant_id, category, created_at, updated_at, key_version, callback_url, priority, created_by, source, metadata, provider_options)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 	`
-	_, err = s.db.ExecContext(ctx, query,
-		notification.ID, notification.UserID, notification.Channel, notification.Recipient,
-		notification.Subject, notification.Body, notification.Status, notification.ScheduledAt,
-		notification.CreatedAt, notification.UpdatedAt,
+	_, err = tx.ExecContext(ctx, query,
+		notification.ID, notification.UserID, notification.Channel, recipient,
+		notification.Subject, body, notification.Status, notification.ResentFrom,
+		attachments, nullIfEmpty(notification.CollapseKey), nullIfEmpty(notification.TenantID), notification.Category, notification.CreatedAt, notification.UpdatedAt,
+		nullIfZero(keyVersion), nullIfEmpty(notification.CallbackURL), notification.Priority,
+		nullIfEmpty(notification.CreatedBy), nullIfEmpty(notification.Source), metadata, providerOptions,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert notification: %w", err)
+		return nil, fmt.Errorf("failed to insert resent notification: %w", err)
+	}
+
+	outboxID, err := s.insertOutbox(ctx, tx, notification)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit resent notification: %w", err)
+	}
+	s.recordEvent(ctx, notification.ID, notification.Status, "", "resent from "+original.ID)
+
+	if err := s.relayOutboxEntries(ctx, []int64{outboxID}); err != nil {
+		s.logger.Error("Failed to publish resent notification to queue, leaving it in the outbox",
+			zap.String("id", notification.ID),
+			zap.String("channel", notification.Channel),
+			zap.Error(err),
+		)
+	}
+
+	s.logger.Info("Resent notification",
+		zap.String("id", notification.ID),
+		zap.String("resent_from", original.ID),
+		zap.String("user_id", notification.UserID),
+		zap.String("channel", notification.Channel),
+	)
+	return notification, nil
+}
+
+// publish queues a notification for delivery by the channel workers
+func (s *Service) publish(ctx context.Context, notification *Notification) error {
+	if s.producer == nil {
+		return fmt.Errorf("%w: cannot publish notification %s", ErrNoProducer, notification.ID)
+	}
+	if !s.config.Channels.IsEnabled(notification.Channel) {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, notification.Channel)
+	}
+
+	if err := s.producer.PublishNotification(ctx, queueMessage(notification)); err != nil {
+		return err
+	}
+
+	// Record the publish so reconciliation can tell queued notifications apart
+	now := time.Now()
+	query := `UPDATE notifications SET queued_at = $1 WHERE id = $2 RETURNING ` + notificationColumns
+	if stored, err := s.scanNotification(s.db.QueryRowContext(ctx, query, now, notification.ID)); err != nil {
+		s.logger.Error("Failed to mark notification as queued", zap.String("id", notification.ID), zap.Error(err))
+	} else {
+		notification.QueuedAt = &now
+		s.cacheNotification(ctx, stored)
+	}
+
+	return nil
+}
+
+// ReconcileUnqueued republishes immediate notifications that are still pending
+// and were never published, e.g. notifications created before the outbox was
+// introduced. Notifications still waiting in the outbox are left to the outbox
+// relay, and only notifications older than olderThan are considered so
+// in-flight creations are left alone. It returns the number republished.
+func (s *Service) ReconcileUnqueued(ctx context.Context, olderThan time.Duration) (int, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications
+		WHERE status = $1 AND queued_at IS NULL AND created_at < $2
+		  AND (scheduled_at IS NULL OR scheduled_at <= created_at)
+		  AND NOT EXISTS (SELECT 1 FROM outbox o WHERE o.notification_id = notifications.id AND o.published_at IS NULL)
+		ORDER BY created_at
+		LIMIT $3`
+
+	rows, err := s.db.QueryContext(ctx, query, StatusPending, time.Now().Add(-olderThan), reconcileBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find unqueued notifications: %w", err)
 	}
 
-	// Check if notification should be sent immediately or scheduled
-	if req.ScheduledAt == nil || req.ScheduledAt.Before(time.Now()) {
-		// Publish to queue for immediate processing
-		queueMsg := queue.NotificationMessage{
-			ID:        notification.ID,
-			UserID:    notification.UserID,
-			Channel:   notification.Channel,
-			Recipient: notification.Recipient,
-			Subject:   notification.Subject,
-			Body:      notification.Body,
-			Metadata:  notification.Metadata,
-			Priority:  priority,
-			CreatedAt: notification.CreatedAt,
+	var pending []*Notification
+	for rows.Next() {
+		notification, err := s.scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan notification: %w", err)
 		}
+		pending = append(pending, notification)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find unqueued notifications: %w", err)
+	}
 
-		if err := s.producer.PublishNotification(ctx, queueMsg); err != nil {
-			log.Printf("Failed to publish notification %s to queue: %v", id, err)
-			// Don't return error here, notification is still created and can be retried
+	republished := 0
+	for _, notification := range pending {
+		if err := s.publish(ctx, notification); err != nil {
+			return republished, fmt.Errorf("failed to republish notification %s: %w", notification.ID, err)
 		}
+		republished++
 	}
 
-	log.Printf("Created notification %s for user %s via %s", id, req.UserID, req.Channel)
-	return notification, nil
+	if republished > 0 {
+		s.logger.Info("Republished unqueued notifications", zap.Int("count", republished))
+	}
+	return republished, nil
+}
+
+// RequeueChannel republishes every pending notification of channel that is due,
+// for operators recovering from a worker outage. With includeFailed, failed
+// notifications are first moved back to pending and requeued too. Rows are
+// republished in batches in creation order; workers skip notifications already
+// being delivered, see ClaimDelivery. It returns the number republished.
+func (s *Service) RequeueChannel(ctx context.Context, channel string, includeFailed bool) (int, error) {
+	if s.producer == nil {
+		return 0, fmt.Errorf("%w: cannot requeue %s notifications", ErrNoProducer, channel)
+	}
+	if !s.config.Channels.IsEnabled(channel) {
+		return 0, fmt.Errorf("%w: %s", ErrChannelDisabled, channel)
+	}
+
+	if includeFailed {
+		if err := s.reopenFailed(ctx, channel); err != nil {
+			return 0, err
+		}
+	}
+
+	// Only rows that existed when the requeue started, so new notifications,
+	// which are published anyway, don't extend the run
+	startedAt := time.Now()
+	var cursorTime time.Time
+	var cursorID string
+	requeued := 0
+
+	for {
+		query := `SELECT ` + notificationColumns + ` FROM notifications
+			WHERE channel = $1 AND status = $2 AND created_at <= $3
+			  AND (scheduled_at IS NULL OR scheduled_at <= NOW())
+			  AND (created_at, id) > ($4, $5)
+			ORDER BY created_at, id
+			LIMIT $6`
+		if cursorID == "" {
+			cursorID = "00000000-0000-0000-0000-000000000000"
+		}
+
+		rows, err := s.db.QueryContext(ctx, query, channel, StatusPending, startedAt, cursorTime, cursorID, reconcileBatchSize)
+		if err != nil {
+			return requeued, fmt.Errorf("failed to find pending notifications: %w", err)
+		}
+
+		var batch []*Notification
+		for rows.Next() {
+			notification, err := s.scanNotification(rows)
+			if err != nil {
+				rows.Close()
+				return requeued, fmt.Errorf("failed to scan notification: %w", err)
+			}
+			batch = append(batch, notification)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return requeued, fmt.Errorf("failed to find pending notifications: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, notification := range batch {
+			if err := s.publish(ctx, notification); err != nil {
+				return requeued, fmt.Errorf("failed to requeue notification %s: %w", notification.ID, err)
+			}
+			requeued++
+		}
+
+		last := batch[len(batch)-1]
+		cursorTime, cursorID = last.CreatedAt, last.ID
+	}
+
+	s.logger.Info("Requeued notifications",
+		zap.String("channel", channel),
+		zap.Bool("include_failed", includeFailed),
+		zap.Int("count", requeued),
+	)
+	return requeued, nil
+}
+
+// reopenFailed moves the failed notifications of channel back to pending so
+// they can be requeued
+func (s *Service) reopenFailed(ctx context.Context, channel string) error {
+	query := `
+		UPDATE notifications
+		SET status = $1, error_message = NULL, updated_at = NOW()
+		WHERE channel = $2 AND status = $3
+		RETURNING id`
+
+	rows, err := s.db.QueryContext(ctx, query, StatusPending, channel, StatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to reopen failed notifications: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reopened notification: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to reopen failed notifications: %w", err)
+	}
+
+	for _, id := range ids {
+		s.recordEvent(ctx, id, StatusPending, "", "reopened for requeue")
+		s.invalidateNotification(ctx, id)
+		s.ReleaseDelivery(ctx, id)
+	}
+	return nil
+}
+
+// notificationColumns lists the columns read by scanNotification, in order
+const notificationColumns = `id, user_id, channel, recipient, subject, body, status, external_id, 
+		       error_message, retry_count, scheduled_at, expires_at, queued_at, sent_at, delivered_at, resent_from, group_id, attachments, collapse_key, tenant_id, category, created_at, updated_at, key_version, callback_url, priority, send_window_start, send_window_end, created_by, source, metadata, provider_options`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
 // GetNotification retrieves a notification by ID
 func (s *Service) GetNotification(ctx context.Context, id string) (*Notification, error) {
+	notification, err := s.getNotification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tenants only see their own notifications
+	if tenantID := TenantFromContext(ctx); tenantID != "" && notification.TenantID != tenantID {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, nil
+}
+
+// getNotification retrieves a notification by ID from the cache or database
+func (s *Service) getNotification(ctx context.Context, id string) (*Notification, error) {
+	// Status pollers fetch the same notification repeatedly
+	if notification, ok := s.cachedNotification(ctx, id); ok {
+		return notification, nil
+	}
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE id = $1`
+
+	notification, err := s.scanNotification(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+
+	s.cacheNotification(ctx, notification)
+	return notification, nil
+}
+
+// GetNotificationStatus retrieves only the status fields of a notification,
+// for pollers that don't need the body or metadata
+func (s *Service) GetNotificationStatus(ctx context.Context, id string) (*NotificationStatusSummary, error) {
+	query := `SELECT id, status, updated_at, external_id FROM notifications WHERE id = $1 AND ($2 = '' OR tenant_id = $2)`
+
+	var summary NotificationStatusSummary
+	var externalID sql.NullString
+	err := s.db.QueryRowContext(ctx, query, id, TenantFromContext(ctx)).Scan(&summary.ID, &summary.Status, &summary.UpdatedAt, &externalID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("failed to get notification status: %w", err)
+	}
+
+	if externalID.Valid {
+		summary.ExternalID = externalID.String
+	}
+
+	return &summary, nil
+}
+
+// ListNotifications returns a page of notifications matching the filter, newest first
+func (s *Service) ListNotifications(ctx context.Context, filter ListNotificationsFilter) (*ListNotificationsResult, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	// Build the filter conditions shared by the page and count queries
+	var conditions []string
+	var args []interface{}
+	addCondition := func(expr string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(expr, len(args)))
+	}
+
+	// Requests authenticated with a tenant API key only list the tenant's notifications
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		filter.TenantID = tenantID
+	}
+	if filter.TenantID != "" {
+		addCondition("tenant_id = $%d", filter.TenantID)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Channel != "" {
+		addCondition("channel = $%d", filter.Channel)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.CreatedBy != "" {
+		addCondition("created_by = $%d", filter.CreatedBy)
+	}
+	if filter.Source != "" {
+		addCondition("source = $%d", filter.Source)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications"+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	// Continue after the last row of the previous page
+	if filter.PageToken != "" {
+		cursorTime, cursorID, err := decodePageToken(filter.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursorTime, cursorID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Fetch one extra row to know whether another page exists
+	args = append(args, limit+1)
+	query := `SELECT ` + notificationColumns + ` FROM notifications` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*Notification, 0, limit)
+	for rows.Next() {
+		notification, err := s.scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	result := &ListNotificationsResult{TotalCount: total}
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		result.NextPageToken = encodePageToken(last.CreatedAt, last.ID)
+	}
+	result.Notifications = notifications
+
+	return result, nil
+}
+
+// GetUserStats counts a user's notifications by channel and status, optionally
+// restricted to a created_at range [from, to)
+func (s *Service) GetUserStats(ctx context.Context, userID string, from, to *time.Time) (*UserStats, error) {
+	query := `SELECT channel, status, COUNT(*) FROM notifications WHERE user_id = $1`
+	args := []interface{}{userID}
+
+	if from != nil {
+		args = append(args, *from)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if to != nil {
+		args = append(args, *to)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		args = append(args, tenantID)
+		query += fmt.Sprintf(" AND tenant_id = $%d", len(args))
+	}
+	query += " GROUP BY channel, status"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &UserStats{
+		UserID:   userID,
+		From:     from,
+		To:       to,
+		Channels: make(map[string]map[NotificationStatus]int),
+	}
+	for rows.Next() {
+		var channel string
+		var status NotificationStatus
+		var count int
+		if err := rows.Scan(&channel, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan user stats: %w", err)
+		}
+
+		if stats.Channels[channel] == nil {
+			stats.Channels[channel] = make(map[NotificationStatus]int)
+		}
+		stats.Channels[channel][status] = count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// CountScheduledBacklog returns the number of pending notifications whose
+// scheduled_at has passed but which have not been dispatched yet
+func (s *Service) CountScheduledBacklog(ctx context.Context) (int, error) {
 	query := `
-		SELECT id, user_id, channel, recipient, subject, body, status, external_id, 
-		       error_message, retry_count, scheduled_at, sent_at, delivered_at, created_at, updated_at
-		FROM notifications WHERE id = $1
+		SELECT COUNT(*) FROM notifications
+		WHERE status = $1 AND queued_at IS NULL AND scheduled_at IS NOT NULL AND scheduled_at <= NOW()
+		  AND NOT EXISTS (SELECT 1 FROM outbox o WHERE o.notification_id = notifications.id AND o.published_at IS NULL)
 	`
 
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, StatusPending).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count scheduled backlog: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanNotification scans a row selected with notificationColumns, decrypting
+// its recipient and body
+func (s *Service) scanNotification(row rowScanner) (*Notification, error) {
 	var notification Notification
-	var scheduledAt, sentAt, deliveredAt sql.NullTime
-	var externalID, errorMessage sql.NullString
+	var scheduledAt, expiresAt, queuedAt, sentAt, deliveredAt, windowStart, windowEnd sql.NullTime
+	var externalID, errorMessage, resentFrom, groupID, collapseKey, tenantID, callbackURL, createdBy, source sql.NullString
+	var keyVersion sql.NullInt64
+	var attachments, metadata, providerOptions []byte
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(
 		&notification.ID, &notification.UserID, &notification.Channel, &notification.Recipient,
 		&notification.Subject, &notification.Body, &notification.Status, &externalID,
-		&errorMessage, &notification.RetryCount, &scheduledAt, &sentAt, &deliveredAt,
-		&notification.CreatedAt, &notification.UpdatedAt,
+		&errorMessage, &notification.RetryCount, &scheduledAt, &expiresAt, &queuedAt, &sentAt, &deliveredAt,
+		&resentFrom, &groupID, &attachments, &collapseKey, &tenantID, &notification.Category, &notification.CreatedAt, &notification.UpdatedAt,
+		&keyVersion, &callbackURL, &notification.Priority, &windowStart, &windowEnd, &createdBy, &source, &metadata, &providerOptions,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("notification not found")
-		}
-		return nil, fmt.Errorf("failed to get notification: %w", err)
+		return nil, err
+	}
+
+	notification.Recipient, notification.Body, err = s.cipher.decryptFields(notification.ID, notification.Recipient, notification.Body, int(keyVersion.Int64))
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle nullable fields
@@ -139,18 +1042,141 @@ func (s *Service) GetNotification(ctx context.Context, id string) (*Notification
 	if scheduledAt.Valid {
 		notification.ScheduledAt = &scheduledAt.Time
 	}
+	if expiresAt.Valid {
+		notification.ExpiresAt = &expiresAt.Time
+	}
+	if windowStart.Valid {
+		notification.SendWindowStart = &windowStart.Time
+	}
+	if windowEnd.Valid {
+		notification.SendWindowEnd = &windowEnd.Time
+	}
+	if queuedAt.Valid {
+		notification.QueuedAt = &queuedAt.Time
+	}
 	if sentAt.Valid {
 		notification.SentAt = &sentAt.Time
 	}
 	if deliveredAt.Valid {
 		notification.DeliveredAt = &deliveredAt.Time
 	}
+	if resentFrom.Valid {
+		notification.ResentFrom = resentFrom.String
+	}
+	if groupID.Valid {
+		notification.GroupID = groupID.String
+	}
+	if collapseKey.Valid {
+		notification.CollapseKey = collapseKey.String
+	}
+	if tenantID.Valid {
+		notification.TenantID = tenantID.String
+	}
+	if callbackURL.Valid {
+		notification.CallbackURL = callbackURL.String
+	}
+	if createdBy.Valid {
+		notification.CreatedBy = createdBy.String
+	}
+	if source.Valid {
+		notification.Source = source.String
+	}
+	if len(attachments) > 0 {
+		if err := json.Unmarshal(attachments, &notification.Attachments); err != nil {
+			return nil, fmt.Errorf("failed to decode attachments: %w", err)
+		}
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &notification.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	if len(providerOptions) > 0 {
+		if err := json.Unmarshal(providerOptions, &notification.ProviderOptions); err != nil {
+			return nil, fmt.Errorf("failed to decode provider options: %w", err)
+		}
+	}
 
 	return &notification, nil
 }
 
-// UpdateNotificationStatus updates the status of a notification
+// marshalMetadata encodes metadata and provider options for their JSONB
+// columns, each NULL when empty
+func marshalMetadata(metadata map[string]string, options ProviderOptions) (metadataJSON, optionsJSON []byte, err error) {
+	if len(metadata) > 0 {
+		if metadataJSON, err = json.Marshal(metadata); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode metadata: %w", err)
+		}
+	}
+	if len(options) > 0 {
+		if optionsJSON, err = json.Marshal(options); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode provider options: %w", err)
+		}
+	}
+	return metadataJSON, optionsJSON, nil
+}
+
+// marshalAttachments encodes attachments for the JSONB column, or NULL when there are none
+func marshalAttachments(attachments []Attachment) ([]byte, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode attachments: %w", err)
+	}
+	return data, nil
+}
+
+// nullIfEmpty maps an empty string to SQL NULL
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullIfZero maps zero to SQL NULL
+func nullIfZero(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
+
+// encodePageToken builds an opaque cursor from the last row of a page
+func encodePageToken(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePageToken parses a cursor built by encodePageToken
+func decodePageToken(token string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+
+	return createdAt, parts[1], nil
+}
+
+// UpdateNotificationStatus updates the status of a notification. Statuses only
+// move forward (see NotificationStatus.CanTransitionTo): repeating the current
+// status is a no-op, and any other move out of the current status returns
+// ErrInvalidStatusTransition without changing the notification.
 func (s *Service) UpdateNotificationStatus(ctx context.Context, id string, status NotificationStatus, externalID, errorMessage string) error {
+	// A tenant may only update its own notifications
+	if TenantFromContext(ctx) != "" {
+		if _, err := s.GetNotification(ctx, id); err != nil {
+			return err
+		}
+	}
+
 	now := time.Now()
 
 	query := `
@@ -171,61 +1197,245 @@ func (s *Service) UpdateNotificationStatus(ctx context.Context, id string, statu
 		args = append(args, now)
 	}
 
+	// Only update from a status that may move to the new one, so concurrent
+	// updates cannot regress a notification
 	query += " WHERE id = $" + fmt.Sprintf("%d", len(args)+1)
 	args = append(args, id)
+	query += " AND status = ANY($" + fmt.Sprintf("%d", len(args)+1) + "::text[])"
+	args = append(args, pq.Array(previousStatuses(status)))
 
-	_, err := s.db.ExecContext(ctx, query, args...)
-	if err != nil {
+	// Return the updated row to refresh the cache without another query
+	query += " RETURNING " + notificationColumns
+	updated, err := s.scanNotification(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("failed to update notification status: %w", err)
 	}
+	if err == sql.ErrNoRows {
+		var current NotificationStatus
+		err := s.db.QueryRowContext(ctx, `SELECT status FROM notifications WHERE id = $1`, id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrNotificationNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get notification status: %w", err)
+		}
+		if current == status {
+			s.logger.Debug("Notification already has status", zap.String("id", id), zap.String("status", string(status)))
+			return nil
+		}
+		return fmt.Errorf("%w: notification %s cannot move from %s to %s", ErrInvalidStatusTransition, id, current, status)
+	}
 
-	log.Printf("Updated notification %s status to %s", id, status)
+	s.recordEvent(ctx, id, status, externalID, errorMessage)
+	s.cacheNotification(ctx, updated)
+	if status == StatusCancelled {
+		s.publishTombstone(ctx, updated)
+	}
+	s.logger.Info("Updated notification status", zap.String("id", id), zap.String("status", string(status)))
 	return nil
 }
 
-// getUserPreferences retrieves user preferences for a specific channel
-func (s *Service) getUserPreferences(ctx context.Context, userID, channel string) (*UserPreference, error) {
-	// Try to get from cache first
-	if s.redis != nil {
-		cacheKey := fmt.Sprintf("user_preferences:%s:%s", userID, channel)
-		if _, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
-			log.Printf("Retrieved user preferences from cache for user %s", userID)
-			// In a real implementation, you'd unmarshal the cached data
-			// For now, we'll fall through to database
-		}
+// publishTombstone publishes a tombstone for a cancelled notification that
+// was queued, when the service publishes to Kafka and tombstones are enabled.
+// Failures are only logged: the cancellation itself already succeeded.
+func (s *Service) publishTombstone(ctx context.Context, notification *Notification) {
+	if s.producer == nil || notification.QueuedAt == nil {
+		return
+	}
+	if err := s.producer.PublishTombstone(ctx, notification.Channel, notification.ID); err != nil {
+		s.logger.Warn("Failed to publish tombstone for cancelled notification", zap.String("id", notification.ID), zap.Error(err))
 	}
+}
 
-	// Get from database
+// ReopenForReplay moves a failed notification back to pending so a replayed
+// dead letter can be sent again. It is the only way out of a terminal status
+// and is meant for operator-driven replays, not automatic retries. Notifications
+// that are not failed are left unchanged; reopened ones start over with no
+// retries.
+func (s *Service) ReopenForReplay(ctx context.Context, id string) error {
 	query := `
-		SELECT id, user_id, channel, enabled, frequency, created_at, updated_at
-		FROM user_preferences 
-		WHERE user_id = $1 AND channel = $2
-	`
+		UPDATE notifications
+		SET status = $1, error_message = NULL, retry_count = 0, updated_at = NOW()
+		WHERE id = $2 AND status = $3`
+	result, err := s.db.ExecContext(ctx, query, StatusPending, id, StatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to reopen notification %s: %w", id, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+		s.recordEvent(ctx, id, StatusPending, "", "reopened for replay")
+		s.invalidateNotification(ctx, id)
+		s.ReleaseDelivery(ctx, id)
+	}
+	return nil
+}
 
-	var pref UserPreference
-	err := s.db.QueryRowContext(ctx, query, userID, channel).Scan(
-		&pref.ID, &pref.UserID, &pref.Channel, &pref.Enabled,
-		&pref.Frequency, &pref.CreatedAt, &pref.UpdatedAt,
-	)
+// SetPaused pauses or resumes outbound sending across all channel workers
+func (s *Service) SetPaused(ctx context.Context, paused bool) error {
+	if s.redis == nil {
+		return fmt.Errorf("pause requires redis")
+	}
+
+	if err := s.redis.SetPaused(ctx, paused); err != nil {
+		return fmt.Errorf("failed to set pause flag: %w", err)
+	}
+
+	s.logger.Info("Changed outbound sending pause flag", zap.Bool("paused", paused))
+	return nil
+}
+
+// IsPaused reports whether outbound sending is paused
+func (s *Service) IsPaused(ctx context.Context) (bool, error) {
+	if s.redis == nil {
+		return false, nil
+	}
+	return s.redis.IsPaused(ctx)
+}
+
+// WaitWhilePaused blocks until sending is resumed or ctx is done.
+// Redis errors are logged and treated as not paused so workers keep running.
+func (s *Service) WaitWhilePaused(ctx context.Context) error {
+	for {
+		paused, err := s.IsPaused(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to check pause flag", zap.Error(err))
+			return nil
+		}
+		if !paused {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pausePollInterval):
+		}
+	}
+}
+
+// deliveryClaimKey returns the dedup key held while a worker delivers a notification
+func deliveryClaimKey(id string) string {
+	return "delivery_claim:" + id
+}
+
+// ClaimDelivery marks a notification as being delivered by this worker so a
+// duplicate queue message, e.g. from a requeue or a retried publish, is not
+// sent twice. It reports false when the notification is no longer pending or
+// another worker holds the claim. Claims expire after deliveryClaimTTL so a
+// worker that died mid-send does not block the notification forever. Without
+// a dedup store, or when it fails, only the status is checked.
+func (s *Service) ClaimDelivery(ctx context.Context, notification *Notification) bool {
+	if notification.Status != StatusPending {
+		return false
+	}
+	if s.dedup == nil {
+		return true
+	}
+
+	claimed, err := s.dedup.Claim(ctx, deliveryClaimKey(notification.ID), deliveryClaimTTL)
+	if err != nil {
+		s.logger.Warn("Failed to claim notification delivery", zap.String("id", notification.ID), zap.Error(err))
+		return true
+	}
+	return claimed
+}
+
+// ReleaseDelivery drops the delivery claim after a failed send, so a replay or
+// requeue of the notification can claim it again
+func (s *Service) ReleaseDelivery(ctx context.Context, id string) {
+	if s.dedup == nil {
+		return
+	}
+	if err := s.dedup.Release(ctx, deliveryClaimKey(id)); err != nil {
+		s.logger.Warn("Failed to release notification delivery claim", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// PurgeDedupClaims deletes expired dedup claims from stores that do not
+// expire them by themselves and returns the number deleted
+func (s *Service) PurgeDedupClaims(ctx context.Context) (int, error) {
+	if s.dedup == nil {
+		return 0, nil
+	}
+	return s.dedup.PurgeExpired(ctx)
+}
+
+// resolveRecipient returns the user's contact address for a channel:
+// email for email, phone for sms and push_token for push. Push notifications
+// for users with registered devices go to all of them, see RecipientAllDevices.
+func (s *Service) resolveRecipient(ctx context.Context, userID, channel string) (string, error) {
+	if channel == "push" {
+		hasDevices, err := s.hasActiveDevices(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		if hasDevices {
+			return RecipientAllDevices, nil
+		}
+	}
+
+	var column string
+	switch channel {
+	case "email":
+		column = "email"
+	case "sms":
+		column = "phone"
+	case "push":
+		column = "push_token"
+	default:
+		return "", fmt.Errorf("%w: unsupported channel %s", ErrNoRecipient, channel)
+	}
+
+	var recipient sql.NullString
+	query := `SELECT ` + column + ` FROM users WHERE id = $1`
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&recipient)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// Return default preferences if not found
-			return &UserPreference{
-				UserID:    userID,
-				Channel:   channel,
-				Enabled:   true,
-				Frequency: "immediate",
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}, nil
+			return "", fmt.Errorf("%w: user %s not found", ErrNoRecipient, userID)
 		}
-		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+		return "", fmt.Errorf("failed to resolve recipient: %w", err)
+	}
+
+	if !recipient.Valid || recipient.String == "" {
+		return "", fmt.Errorf("%w: user %s has no %s", ErrNoRecipient, userID, column)
 	}
 
-	// Cache the result
-	if s.redis != nil {
-		s.redis.CacheUserPreferences(ctx, userID, pref)
+	return recipient.String, nil
+}
+
+// getUserPreferences retrieves user preferences for a specific channel and
+// category. A preference for the category takes precedence over the
+// channel-wide one, so opting out of marketing leaves transactional
+// notifications on the channel enabled.
+func (s *Service) getUserPreferences(ctx context.Context, userID, channel, category string) (*UserPreference, error) {
+	preferences, err := s.userPreferenceSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var channelWide *UserPreference
+	for i := range preferences {
+		pref := &preferences[i]
+		if pref.Channel != channel {
+			continue
+		}
+		if pref.Category != "" && pref.Category == category {
+			return pref, nil
+		}
+		if pref.Category == "" {
+			channelWide = pref
+		}
+	}
+	if channelWide != nil {
+		return channelWide, nil
 	}
 
-	return &pref, nil
+	// Return default preferences if not found
+	return &UserPreference{
+		UserID:    userID,
+		Channel:   channel,
+		Enabled:   true,
+		Frequency: "immediate",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
 }
@@ -0,0 +1,872 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/database/redistest"
+	"github.com/alexnthnz/notification-system/internal/queue"
+	"github.com/alexnthnz/notification-system/internal/queue/queuetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestService returns a service backed by a mock database, without Redis
+// or Kafka
+func newTestService(t *testing.T, cfg *config.Config) (*Service, *dbtest.Mock) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	db, mock := dbtest.New(t)
+	service, err := NewService(cfg, db, nil, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return service, mock
+}
+
+// newTestServiceWithRedis returns a service backed by a mock database and an
+// in-memory Redis server, without Kafka
+func newTestServiceWithRedis(t *testing.T, cfg *config.Config) (*Service, *dbtest.Mock, *redistest.Server) {
+	t.Helper()
+	s, mock := newTestService(t, cfg)
+	client, server := redistest.New(t)
+	s.redis = client
+	s.dedup, _ = database.NewDedupStore(s.config.Dedup.Store, s.db, client)
+	return s, mock, server
+}
+
+// withProducer gives s a producer for an unreachable broker. Tests using it
+// must not expect a publish to succeed.
+func withProducer(s *Service) *Service {
+	s.producer = queue.NewProducer(config.KafkaConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "notifications", MaxAttempts: 1}, zap.NewNop())
+	return s
+}
+
+// enabledChannels returns a channel config with all channels enabled
+func enabledChannels() config.ChannelsConfig {
+	var channels config.ChannelsConfig
+	channels.SendGrid.Enabled = true
+	channels.Twilio.Enabled = true
+	channels.Firebase.Enabled = true
+	return channels
+}
+
+// notificationRows returns notifications as rows of the notifications table
+func notificationRows(notifications ...Notification) *dbtest.Rows {
+	rows := dbtest.NewRows(notificationColumnNames()...)
+	for _, n := range notifications {
+		rows.AddRow(notificationRowValues(n)...)
+	}
+	return rows
+}
+
+// notificationColumnNames splits notificationColumns into column names
+func notificationColumnNames() []string {
+	return strings.Split(strings.Join(strings.Fields(notificationColumns), " "), ", ")
+}
+
+// notificationRowValues returns n's values in notificationColumns order
+func notificationRowValues(n Notification) []any {
+	category := n.Category
+	if category == "" {
+		category = CategoryTransactional
+	}
+	priority := n.Priority
+	if priority == 0 {
+		priority = PriorityMedium
+	}
+	metadata, providerOptions, _ := marshalMetadata(n.Metadata, n.ProviderOptions)
+	return []any{n.ID, n.UserID, n.Channel, n.Recipient, n.Subject, n.Body, string(n.Status), nullIfEmpty(n.ExternalID),
+		nullIfEmpty(n.ErrorMessage), n.RetryCount, n.ScheduledAt, n.ExpiresAt, n.QueuedAt, n.SentAt, n.DeliveredAt, nullIfEmpty(n.ResentFrom), nullIfEmpty(n.GroupID), nil,
+		nullIfEmpty(n.CollapseKey), nullIfEmpty(n.TenantID), category, n.CreatedAt, n.UpdatedAt, nil, nullIfEmpty(n.CallbackURL), priority,
+		n.SendWindowStart, n.SendWindowEnd, nullIfEmpty(n.CreatedBy), nullIfEmpty(n.Source), metadata, providerOptions}
+}
+
+func TestResendNotification(t *testing.T) {
+	now := time.Now()
+	original := Notification{
+		ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Your code is 1234",
+		Status: StatusFailed, ErrorMessage: "carrier error", CreatedAt: now, UpdatedAt: now,
+		Metadata: map[string]string{"media_urls": "https://example.com/a.png"}, ProviderOptions: ProviderOptions{ProviderTwilio: {OptionValidityPeriod: "600"}},
+	}
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1").WillReturnRows(notificationRows(original))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs(dbtest.AnyArg(), "user-1", "sms", "+15551234567", "", "Your code is 1234", "pending", "n1",
+			dbtest.AnyArg(), dbtest.AnyArg(), nil, CategoryTransactional, dbtest.AnyArg(), dbtest.AnyArg(), nil, nil, dbtest.AnyArg(), nil, nil,
+			[]byte(`{"media_urls":"https://example.com/a.png"}`), []byte(`{"twilio":{"validity_period":"600"}}`)).
+		WillReturnResult(1)
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(7))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+
+	resent, err := s.ResendNotification(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("ResendNotification() error = %v", err)
+	}
+	if resent.ResentFrom != "n1" {
+		t.Errorf("ResentFrom = %q, want n1", resent.ResentFrom)
+	}
+	if resent.ID == "" || resent.ID == "n1" {
+		t.Errorf("ID = %q, want a new ID", resent.ID)
+	}
+	if resent.Status != StatusPending || resent.Body != original.Body || resent.Recipient != original.Recipient {
+		t.Errorf("resent = %+v, want a pending copy of the original", resent)
+	}
+	if !reflect.DeepEqual(resent.Metadata, original.Metadata) || !reflect.DeepEqual(resent.ProviderOptions, original.ProviderOptions) {
+		t.Errorf("resent metadata %v and provider options %v, want %v and %v", resent.Metadata, resent.ProviderOptions, original.Metadata, original.ProviderOptions)
+	}
+}
+
+func TestResendNotificationRejectsNonFailed(t *testing.T) {
+	for _, status := range []NotificationStatus{StatusPending, StatusSent, StatusDelivered, StatusCancelled} {
+		t.Run(string(status), func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			withProducer(s)
+			mock.ExpectQuery("FROM notifications WHERE id = $1").
+				WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: status}))
+
+			_, err := s.ResendNotification(context.Background(), "n1")
+			if !errors.Is(err, ErrNotResendable) {
+				t.Errorf("ResendNotification() error = %v, want ErrNotResendable", err)
+			}
+		})
+	}
+}
+
+func TestResendNotificationNotFound(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationRows())
+
+	if _, err := s.ResendNotification(context.Background(), "missing"); !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("ResendNotification() error = %v, want ErrNotificationNotFound", err)
+	}
+}
+
+// expectPreferences expects the user's preference set to be read
+func expectPreferences(mock *dbtest.Mock, userID string, preferences ...UserPreference) {
+	rows := dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at")
+	for _, p := range preferences {
+		rows.AddRow(p.ID, userID, p.Channel, p.Enabled, "immediate", p.Locale, p.Category, time.Now(), time.Now())
+	}
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WithArgs(userID, dbtest.AnyArg()).WillReturnRows(rows)
+}
+
+// expectStore expects one notification to be stored, queued in the outbox
+// and relayed, returning row as the stored notification
+func expectStore(mock *dbtest.Mock, row Notification) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+}
+
+// expectEvent expects the status history entry recorded for a notification
+func expectEvent(mock *dbtest.Mock, id any, status NotificationStatus) {
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs(id, status, dbtest.AnyArg(), dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WithArgs(id, dbtest.AnyArg()).WillReturnResult(0)
+}
+
+func TestCreateNotificationRejectsDisabledChannel(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Twilio.Enabled = false
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	// A disabled channel is rejected before anything is read or stored
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if !errors.Is(err, ErrChannelDisabled) {
+		t.Fatalf("CreateNotification(sms) error = %v, want ErrChannelDisabled", err)
+	}
+
+	// Other channels still work
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT reason FROM suppressions").WillReturnRows(dbtest.NewRows("reason"))
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "email", Status: StatusPending})
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "email", Recipient: "a@example.com", Subject: "Hi", Body: "hi"})
+	if err != nil {
+		t.Fatalf("CreateNotification(email) error = %v", err)
+	}
+	if created.Channel != "email" || created.Status != StatusPending {
+		t.Errorf("created = %+v, want a pending email", created)
+	}
+}
+
+func TestPublishSkipsDisabledChannel(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Twilio.Enabled = false
+	s, _ := newTestService(t, cfg)
+	withProducer(s)
+
+	err := s.publish(context.Background(), &Notification{ID: "n1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if !errors.Is(err, ErrChannelDisabled) {
+		t.Errorf("publish() error = %v, want ErrChannelDisabled", err)
+	}
+}
+
+func TestRelayOutboxSkipsDisabledChannel(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Twilio.Enabled = false
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE published_at IS NULL").
+		WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version").
+			AddRow(1, "n1", 0, []byte(`{"id":"n1","channel":"sms","recipient":"+15551234567","body":"hi"}`), nil))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts + 1, last_error = $2").WithArgs(1, dbtest.AnyArg(), dbtest.AnyArg()).WillReturnResult(1)
+	mock.ExpectCommit()
+
+	published, err := s.RelayOutbox(context.Background())
+	if !errors.Is(err, ErrChannelDisabled) || published != 0 {
+		t.Errorf("RelayOutbox() = %d, %v, want nothing published and ErrChannelDisabled", published, err)
+	}
+}
+
+func TestPauseFlag(t *testing.T) {
+	s, _, _ := newTestServiceWithRedis(t, nil)
+	ctx := context.Background()
+
+	if err := s.SetPaused(ctx, true); err != nil {
+		t.Fatalf("SetPaused(true) error = %v", err)
+	}
+	if paused, err := s.IsPaused(ctx); err != nil || !paused {
+		t.Fatalf("IsPaused() = %v, %v, want paused", paused, err)
+	}
+
+	// Paused workers wait until their context is done
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := s.WaitWhilePaused(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitWhilePaused() while paused = %v, want context.DeadlineExceeded", err)
+	}
+
+	if err := s.SetPaused(ctx, false); err != nil {
+		t.Fatalf("SetPaused(false) error = %v", err)
+	}
+	if paused, err := s.IsPaused(ctx); err != nil || paused {
+		t.Fatalf("IsPaused() = %v, %v, want resumed", paused, err)
+	}
+	if err := s.WaitWhilePaused(ctx); err != nil {
+		t.Errorf("WaitWhilePaused() after resume = %v, want nil", err)
+	}
+}
+
+func TestWaitWhilePausedResumes(t *testing.T) {
+	s, _, _ := newTestServiceWithRedis(t, nil)
+	ctx := context.Background()
+	if err := s.SetPaused(ctx, true); err != nil {
+		t.Fatalf("SetPaused(true) error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.WaitWhilePaused(ctx) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("WaitWhilePaused() returned %v while paused", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := s.SetPaused(ctx, false); err != nil {
+		t.Fatalf("SetPaused(false) error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitWhilePaused() = %v, want nil after resume", err)
+		}
+	case <-time.After(2 * pausePollInterval):
+		t.Fatal("WaitWhilePaused() did not return after resume")
+	}
+}
+
+func TestWaitWhilePausedIgnoresRedisErrors(t *testing.T) {
+	s, _, redis := newTestServiceWithRedis(t, nil)
+	redis.Fail(errors.New("ERR unavailable"))
+
+	if err := s.WaitWhilePaused(context.Background()); err != nil {
+		t.Errorf("WaitWhilePaused() = %v, want nil so workers keep sending", err)
+	}
+}
+
+func TestSetPausedRequiresRedis(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	if err := s.SetPaused(context.Background(), true); err == nil {
+		t.Error("SetPaused() without Redis succeeded, want an error")
+	}
+	if paused, err := s.IsPaused(context.Background()); err != nil || paused {
+		t.Errorf("IsPaused() without Redis = %v, %v, want not paused", paused, err)
+	}
+}
+
+func TestResolveRecipient(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		column  string
+		devices bool
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "email", channel: "email", column: "email", value: "a@example.com", want: "a@example.com"},
+		{name: "sms", channel: "sms", column: "phone", value: "+15551234567", want: "+15551234567"},
+		{name: "push token", channel: "push", column: "push_token", value: "token-1", want: "token-1"},
+		{name: "push devices", channel: "push", devices: true, want: RecipientAllDevices},
+		{name: "missing email", channel: "email", column: "email", value: nil, wantErr: ErrNoRecipient},
+		{name: "empty phone", channel: "sms", column: "phone", value: "", wantErr: ErrNoRecipient},
+		{name: "missing push token", channel: "push", column: "push_token", value: nil, wantErr: ErrNoRecipient},
+		{name: "unsupported channel", channel: "fax", wantErr: ErrNoRecipient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			if tt.channel == "push" {
+				mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM user_devices").WithArgs("user-1", "").
+					WillReturnRows(dbtest.NewRows("exists").AddRow(tt.devices))
+			}
+			if tt.column != "" {
+				mock.ExpectQuery("SELECT " + tt.column + " FROM users WHERE id = $1").WithArgs("user-1").
+					WillReturnRows(dbtest.NewRows(tt.column).AddRow(tt.value))
+			}
+
+			got, err := s.resolveRecipient(context.Background(), "user-1", tt.channel)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveRecipient() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveRecipient() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRecipientUnknownUser(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("SELECT email FROM users WHERE id = $1").WithArgs("ghost").WillReturnRows(dbtest.NewRows("email"))
+
+	if _, err := s.resolveRecipient(context.Background(), "ghost", "email"); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("resolveRecipient() error = %v, want ErrNoRecipient", err)
+	}
+}
+
+func TestCreateNotificationWithoutContact(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT phone FROM users WHERE id = $1").WithArgs("user-1").WillReturnRows(dbtest.NewRows("phone").AddRow(nil))
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Body: "hi"})
+	if !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("CreateNotification() error = %v, want ErrNoRecipient", err)
+	}
+}
+
+// insertArgs returns the arguments of a notification insert, matching only
+// the recipient and group ID
+func insertArgs(recipient string, groupID any) []any {
+	args := make([]any, 25)
+	for i := range args {
+		args[i] = dbtest.AnyArg()
+	}
+	args[3] = recipient
+	args[9] = groupID
+	return args
+}
+
+func TestCreateNotificationGroup(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+
+	for range recipients {
+		expectPreferences(mock, "user-1")
+		mock.ExpectQuery("SELECT reason FROM suppressions").WillReturnRows(dbtest.NewRows("reason"))
+	}
+	mock.ExpectBegin()
+	for _, recipient := range recipients {
+		mock.ExpectQuery("INSERT INTO notifications").WithArgs(insertArgs(recipient, dbtest.AnyArg())...).
+			WillReturnRows(notificationRows(Notification{ID: "n" + recipient, UserID: "user-1", Channel: "email", Recipient: recipient, Status: StatusPending}))
+		mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	}
+	mock.ExpectCommit()
+	for range recipients {
+		expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+
+	group, err := s.CreateNotificationGroup(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "email", Recipients: recipients, Subject: "Hi", Body: "hi",
+	})
+	if err != nil {
+		t.Fatalf("CreateNotificationGroup() error = %v", err)
+	}
+	if group.GroupID == "" || len(group.Notifications) != len(recipients) {
+		t.Fatalf("group = %+v, want %d notifications with a group ID", group, len(recipients))
+	}
+
+	ids := make(map[string]bool)
+	for i, n := range group.Notifications {
+		if n.GroupID != group.GroupID {
+			t.Errorf("notification %d GroupID = %q, want %q", i, n.GroupID, group.GroupID)
+		}
+		if n.Recipient != recipients[i] {
+			t.Errorf("notification %d Recipient = %q, want %q", i, n.Recipient, recipients[i])
+		}
+		if n.ID == group.GroupID || ids[n.ID] {
+			t.Errorf("notification %d ID %q is not unique", i, n.ID)
+		}
+		ids[n.ID] = true
+	}
+}
+
+func TestCreateNotificationGroupStoresNothingOnFailure(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	// The second recipient is suppressed, so the group is rejected before
+	// anything is stored
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT reason FROM suppressions").WillReturnRows(dbtest.NewRows("reason"))
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT reason FROM suppressions").WillReturnRows(dbtest.NewRows("reason").AddRow("bounce"))
+
+	_, err := s.CreateNotificationGroup(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "email", Recipients: []string{"a@example.com", "b@example.com", "c@example.com"}, Subject: "Hi", Body: "hi",
+	})
+	if !errors.Is(err, ErrRecipientSuppressed) {
+		t.Errorf("CreateNotificationGroup() error = %v, want ErrRecipientSuppressed", err)
+	}
+}
+
+func TestPublishWithoutProducer(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+
+	// Services of the channel workers have no producer; publishing from them
+	// fails instead of panicking
+	err := s.publish(context.Background(), &Notification{ID: "n1", UserID: "user-1", Channel: "sms"})
+	if !errors.Is(err, ErrNoProducer) {
+		t.Errorf("publish() error = %v, want ErrNoProducer", err)
+	}
+
+	// A resend is refused before a copy is stored that could never be queued
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs("n1").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusFailed}))
+	if _, err := s.ResendNotification(context.Background(), "n1"); !errors.Is(err, ErrNoProducer) {
+		t.Errorf("ResendNotification() error = %v, want ErrNoProducer", err)
+	}
+}
+
+func TestReconcileUnqueuedRepublishesFailedPublish(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+	created := time.Now().Add(-10 * time.Minute)
+	notif := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending, CreatedAt: created, UpdatedAt: created,
+		Metadata: map[string]string{"media_urls": "https://example.com/a.png"}, ProviderOptions: ProviderOptions{ProviderTwilio: {OptionValidityPeriod: "600"}}}
+
+	// Kafka is down when the notification is created: the publish fails and the
+	// notification is not marked queued
+	recorder.Fail(errors.New("kafka unavailable"))
+	if err := s.publish(context.Background(), &notif); err == nil {
+		t.Fatal("publish() succeeded while Kafka is down")
+	}
+
+	// Once Kafka is back, reconciliation finds the pending notification and
+	// republishes it, marking it queued
+	recorder.Fail(nil)
+	mock.ExpectQuery("WHERE status = $1 AND queued_at IS NULL AND created_at < $2").WithArgs(StatusPending, dbtest.AnyArg(), reconcileBatchSize).
+		WillReturnRows(notificationRows(notif))
+	mock.ExpectQuery("UPDATE notifications SET queued_at = $1 WHERE id = $2").WithArgs(dbtest.AnyArg(), "n1").WillReturnRows(notificationRows(notif))
+
+	republished, err := s.ReconcileUnqueued(context.Background(), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("ReconcileUnqueued() error = %v", err)
+	}
+	if republished != 1 {
+		t.Errorf("ReconcileUnqueued() = %d, want 1", republished)
+	}
+	published := recorder.Published(t)
+	if len(published) != 1 || published[0].ID != "n1" {
+		t.Fatalf("published %+v, want n1 once", published)
+	}
+	// The stored metadata and provider options are republished
+	if !reflect.DeepEqual(published[0].Metadata, notif.Metadata) || !reflect.DeepEqual(ProviderOptions(published[0].ProviderOptions), notif.ProviderOptions) {
+		t.Errorf("published metadata %v and provider options %v, want %v and %v",
+			published[0].Metadata, published[0].ProviderOptions, notif.Metadata, notif.ProviderOptions)
+	}
+}
+
+func TestReconcileUnqueuedStopsOnPublishFailure(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	producer, recorder := queuetest.NewProducer(t)
+	s.producer = producer
+	recorder.Fail(errors.New("kafka unavailable"))
+
+	created := time.Now().Add(-10 * time.Minute)
+	mock.ExpectQuery("WHERE status = $1 AND queued_at IS NULL").
+		WillReturnRows(notificationRows(
+			Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, CreatedAt: created, UpdatedAt: created},
+			Notification{ID: "n2", UserID: "user-1", Channel: "sms", Status: StatusPending, CreatedAt: created, UpdatedAt: created},
+		))
+
+	// Unqueued notifications stay unmarked for the next run
+	republished, err := s.ReconcileUnqueued(context.Background(), 5*time.Minute)
+	if err == nil || republished != 0 {
+		t.Errorf("ReconcileUnqueued() = %d, %v, want 0 and the publish error", republished, err)
+	}
+}
+
+func TestCreateNotificationFallsBackToEnabledChannel(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	// Email is turned off by the user, so the notification goes out by SMS to
+	// the user's phone instead of the given email address
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "email", Enabled: false})
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT phone FROM users WHERE id = $1").WithArgs("user-1").WillReturnRows(dbtest.NewRows("phone").AddRow("+15551234567"))
+	args := insertArgs("+15551234567", nil)
+	args[2] = "sms"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Status: StatusPending}))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "email", FallbackChannels: []string{"sms"}, Recipient: "a@example.com", Subject: "Hi", Body: "hi",
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if created.Channel != "sms" || created.Recipient != "+15551234567" {
+		t.Errorf("created on %s to %s, want sms to the user's phone", created.Channel, created.Recipient)
+	}
+}
+
+func TestCreateNotificationFallbackAllDisabled(t *testing.T) {
+	cfg := &config.Config{Channels: enabledChannels()}
+	cfg.Channels.Firebase.Enabled = false
+	s, mock := newTestService(t, cfg)
+	withProducer(s)
+
+	// Push is skipped without reading preferences since it is off globally
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "email", Enabled: false})
+	expectPreferences(mock, "user-1", UserPreference{ID: "p2", Channel: "sms", Enabled: false})
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "email", FallbackChannels: []string{"push", "sms"}, Recipient: "a@example.com", Body: "hi",
+	})
+	if !errors.Is(err, ErrNotificationsDisabled) {
+		t.Errorf("CreateNotification() error = %v, want ErrNotificationsDisabled", err)
+	}
+}
+
+func TestCreateNotificationLogsStructuredFields(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s.logger = zap.New(core)
+
+	expectPreferences(mock, "user-1")
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Status: StatusPending})
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+
+	entries := logs.FilterMessage("Created notification").AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d \"Created notification\" entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	want := map[string]string{"id": created.ID, "user_id": "user-1", "channel": "sms"}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("logged %s = %v, want %q", key, fields[key], value)
+		}
+	}
+}
+
+func TestUpdateNotificationStatus(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("UPDATE notifications").
+		WithArgs(StatusDelivered, "ext-1", "", dbtest.AnyArg(), dbtest.AnyArg(), "n1", dbtest.AnyArg()).
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusDelivered}))
+	expectEvent(mock, "n1", StatusDelivered)
+
+	if err := s.UpdateNotificationStatus(context.Background(), "n1", StatusDelivered, "ext-1", ""); err != nil {
+		t.Errorf("UpdateNotificationStatus() error = %v", err)
+	}
+}
+
+func TestCancelPublishesTombstone(t *testing.T) {
+	queuedAt := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name     string
+		queuedAt *time.Time
+		want     []string
+	}{
+		{"queued", &queuedAt, []string{"n1"}},
+		// Never published, so there is nothing to compact away
+		{"not queued", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			producer, recorder := queuetest.NewProducerWithConfig(t, config.KafkaConfig{Topic: "notifications", PartitionKey: queue.PartitionKeyID, TombstoneOnCancel: true})
+			s.producer = producer
+			mock.ExpectQuery("UPDATE notifications").
+				WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusCancelled, QueuedAt: tt.queuedAt}))
+			expectEvent(mock, "n1", StatusCancelled)
+
+			if err := s.UpdateNotificationStatus(context.Background(), "n1", StatusCancelled, "", ""); err != nil {
+				t.Fatalf("UpdateNotificationStatus() error = %v", err)
+			}
+			if got := recorder.Tombstones(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tombstones = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateNotificationStatusRejectsDeliveredToSent(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationRows())
+	mock.ExpectQuery("SELECT status FROM notifications WHERE id = $1").WithArgs("n1").
+		WillReturnRows(dbtest.NewRows("status").AddRow("delivered"))
+
+	err := s.UpdateNotificationStatus(context.Background(), "n1", StatusSent, "ext-1", "")
+	if !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("UpdateNotificationStatus() error = %v, want ErrInvalidStatusTransition", err)
+	}
+}
+
+func TestUpdateNotificationStatusRepeatedIsNoOp(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationRows())
+	mock.ExpectQuery("SELECT status FROM notifications WHERE id = $1").WithArgs("n1").
+		WillReturnRows(dbtest.NewRows("status").AddRow("delivered"))
+
+	// Repeating the current status is not an invalid transition
+	if err := s.UpdateNotificationStatus(context.Background(), "n1", StatusDelivered, "ext-1", ""); err != nil {
+		t.Errorf("UpdateNotificationStatus() error = %v, want nil", err)
+	}
+}
+
+func TestUpdateNotificationStatusNotFound(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationRows())
+	mock.ExpectQuery("SELECT status FROM notifications WHERE id = $1").WillReturnRows(dbtest.NewRows("status"))
+
+	err := s.UpdateNotificationStatus(context.Background(), "missing", StatusSent, "", "")
+	if !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("UpdateNotificationStatus() error = %v, want ErrNotificationNotFound", err)
+	}
+}
+
+func TestReopenForReplay(t *testing.T) {
+	s, mock := newTestService(t, nil)
+
+	// A failed notification is reopened with its error and retry count cleared
+	mock.ExpectExec("SET status = $1, error_message = NULL, retry_count = 0").WithArgs(StatusPending, "n1", StatusFailed).WillReturnResult(1)
+	expectEvent(mock, "n1", StatusPending)
+	if err := s.ReopenForReplay(context.Background(), "n1"); err != nil {
+		t.Fatalf("ReopenForReplay(failed) error = %v", err)
+	}
+
+	// Other notifications are left alone
+	mock.ExpectExec("SET status = $1, error_message = NULL, retry_count = 0").WithArgs(StatusPending, "n2", StatusFailed).WillReturnResult(0)
+	if err := s.ReopenForReplay(context.Background(), "n2"); err != nil {
+		t.Fatalf("ReopenForReplay(not failed) error = %v", err)
+	}
+}
+
+func TestCreateNotificationTagsTenant(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	ctx := WithTenant(context.Background(), "tenant-a")
+
+	// The tenant's own preferences apply, and the notification is stored for it
+	mock.ExpectQuery("FROM user_preferences WHERE user_id = $1").WithArgs("user-1", "tenant-a").
+		WillReturnRows(dbtest.NewRows("id", "user_id", "channel", "enabled", "frequency", "locale", "category", "created_at", "updated_at"))
+	args := insertArgs("+15551234567", nil)
+	args[12] = "tenant-a"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Status: StatusPending, TenantID: "tenant-a"}))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+
+	created, err := s.CreateNotification(ctx, NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if created.TenantID != "tenant-a" {
+		t.Errorf("TenantID = %q, want tenant-a", created.TenantID)
+	}
+}
+
+func TestNotificationInvisibleToOtherTenants(t *testing.T) {
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, TenantID: "tenant-a"}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{"owning tenant", WithTenant(context.Background(), "tenant-a"), nil},
+		{"other tenant", WithTenant(context.Background(), "tenant-b"), ErrNotificationNotFound},
+		{"operator without tenant", context.Background(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			mock.ExpectQuery("FROM notifications WHERE id = $1").WillReturnRows(notificationRows(row))
+
+			if _, err := s.GetNotification(tt.ctx, "n1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetNotification() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateNotificationStatusRejectsOtherTenant(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("FROM notifications WHERE id = $1").
+		WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, TenantID: "tenant-a"}))
+
+	// The notification is looked up but never updated
+	err := s.UpdateNotificationStatus(WithTenant(context.Background(), "tenant-b"), "n1", StatusSent, "SM123", "")
+	if !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("UpdateNotificationStatus() error = %v, want ErrNotificationNotFound", err)
+	}
+}
+
+func TestListNotificationsFiltersByTenant(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE tenant_id = $1").WithArgs("tenant-b", "user-1").
+		WillReturnRows(dbtest.NewRows("count").AddRow(0))
+	mock.ExpectQuery("WHERE tenant_id = $1").WillReturnRows(notificationRows())
+
+	result, err := s.ListNotifications(WithTenant(context.Background(), "tenant-b"), ListNotificationsFilter{UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("ListNotifications() error = %v", err)
+	}
+	if len(result.Notifications) != 0 {
+		t.Errorf("listed %d notifications for tenant-b, want none", len(result.Notifications))
+	}
+}
+
+func TestGetUserPreferencesByCategory(t *testing.T) {
+	optOut := UserPreference{ID: "p1", Channel: "email", Category: CategoryMarketing, Enabled: false}
+	channelWide := UserPreference{ID: "p2", Channel: "email", Enabled: false}
+	transactional := UserPreference{ID: "p3", Channel: "email", Category: CategoryTransactional, Enabled: true}
+	tests := []struct {
+		name        string
+		preferences []UserPreference
+		channel     string
+		category    string
+		wantID      string
+		wantEnabled bool
+	}{
+		{"marketing opt-out blocks marketing", []UserPreference{optOut}, "email", CategoryMarketing, "p1", false},
+		{"marketing opt-out leaves transactional", []UserPreference{optOut}, "email", CategoryTransactional, "", true},
+		{"marketing opt-out leaves other channels", []UserPreference{optOut}, "sms", CategoryMarketing, "", true},
+		{"channel-wide applies to every category", []UserPreference{channelWide}, "email", CategoryMarketing, "p2", false},
+		{"category beats channel-wide", []UserPreference{channelWide, transactional}, "email", CategoryTransactional, "p3", true},
+		{"no preferences", nil, "email", CategoryMarketing, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+			expectPreferences(mock, "user-1", tt.preferences...)
+
+			pref, err := s.getUserPreferences(context.Background(), "user-1", tt.channel, tt.category)
+			if err != nil {
+				t.Fatalf("getUserPreferences() error = %v", err)
+			}
+			if pref.ID != tt.wantID || pref.Enabled != tt.wantEnabled {
+				t.Errorf("getUserPreferences() = %+v, want ID %q enabled %v", pref, tt.wantID, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestCreateNotificationMarketingOptOut(t *testing.T) {
+	optOut := UserPreference{ID: "p1", Channel: "sms", Category: CategoryMarketing, Enabled: false}
+	req := NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}
+
+	t.Run("marketing is blocked", func(t *testing.T) {
+		s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+		withProducer(s)
+		expectPreferences(mock, "user-1", optOut)
+
+		marketing := req
+		marketing.Category = CategoryMarketing
+		_, err := s.CreateNotification(context.Background(), marketing)
+		if !errors.Is(err, ErrNotificationsDisabled) {
+			t.Errorf("CreateNotification() error = %v, want ErrNotificationsDisabled", err)
+		}
+	})
+
+	// Requests without a category are transactional and still go out
+	t.Run("transactional is sent", func(t *testing.T) {
+		s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+		withProducer(s)
+		expectPreferences(mock, "user-1", optOut)
+		args := insertArgs("+15551234567", nil)
+		args[13] = CategoryTransactional
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).
+			WillReturnRows(notificationRows(Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Status: StatusPending, Category: CategoryTransactional}))
+		mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+		mock.ExpectCommit()
+		expectEvent(mock, dbtest.AnyArg(), StatusPending)
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+		mock.ExpectCommit()
+
+		created, err := s.CreateNotification(context.Background(), req)
+		if err != nil {
+			t.Fatalf("CreateNotification() error = %v", err)
+		}
+		if created.Category != CategoryTransactional {
+			t.Errorf("Category = %q, want transactional", created.Category)
+		}
+	})
+}
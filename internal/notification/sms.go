@@ -0,0 +1,39 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// MetadataMediaURLs lists the media to attach to an SMS, comma-separated.
+// Messages with media are sent as MMS.
+const MetadataMediaURLs = "media_urls"
+
+// MaxSMSMediaURLs is the most media Twilio accepts on one message
+const MaxSMSMediaURLs = 10
+
+// ErrInvalidSMSOptions is returned when SMS metadata options are malformed
+var ErrInvalidSMSOptions = errors.New("invalid SMS options")
+
+// ValidateSMSOptions checks the media URLs in metadata. Twilio fetches the
+// media itself, so each URL must be an absolute http or https URL.
+func ValidateSMSOptions(metadata map[string]string) error {
+	mediaURLs := ParseMediaURLs(metadata[MetadataMediaURLs])
+	if len(mediaURLs) > MaxSMSMediaURLs {
+		return fmt.Errorf("%w: at most %d media URLs are allowed, got %d", ErrInvalidSMSOptions, MaxSMSMediaURLs, len(mediaURLs))
+	}
+
+	for _, mediaURL := range mediaURLs {
+		u, err := url.Parse(mediaURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: media URL %q must be an absolute http or https URL", ErrInvalidSMSOptions, mediaURL)
+		}
+	}
+	return nil
+}
+
+// ParseMediaURLs splits a comma-separated media URL list, dropping blanks
+func ParseMediaURLs(value string) []string {
+	return splitList(value)
+}
@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+// mediaURLs returns n comma-separated media URLs
+func mediaURLs(n int) string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://cdn.example.com/image-%d.png", i)
+	}
+	return strings.Join(urls, ",")
+}
+
+func TestValidateSMSOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"http and https", map[string]string{MetadataMediaURLs: "https://cdn.example.com/a.png, http://cdn.example.com/b.gif"}, false},
+		{"blank entries", map[string]string{MetadataMediaURLs: " , https://cdn.example.com/a.png,"}, false},
+		{"at the limit", map[string]string{MetadataMediaURLs: mediaURLs(MaxSMSMediaURLs)}, false},
+		{"too many", map[string]string{MetadataMediaURLs: mediaURLs(MaxSMSMediaURLs + 1)}, true},
+		{"relative", map[string]string{MetadataMediaURLs: "/images/a.png"}, true},
+		{"unsupported scheme", map[string]string{MetadataMediaURLs: "ftp://cdn.example.com/a.png"}, true},
+		{"no host", map[string]string{MetadataMediaURLs: "https:///a.png"}, true},
+		{"malformed", map[string]string{MetadataMediaURLs: "https://cdn.example.com/%zz"}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateSMSOptions(tt.metadata)
+		if tt.wantErr != errors.Is(err, ErrInvalidSMSOptions) {
+			t.Errorf("%s: ValidateSMSOptions() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseMediaURLs(t *testing.T) {
+	got := ParseMediaURLs(" https://cdn.example.com/a.png, ,https://cdn.example.com/b.png,")
+	if want := []string{"https://cdn.example.com/a.png", "https://cdn.example.com/b.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMediaURLs() = %v, want %v", got, want)
+	}
+	if got := ParseMediaURLs(""); got != nil {
+		t.Errorf("ParseMediaURLs(\"\") = %v, want nil", got)
+	}
+}
+
+func TestCreateNotificationRejectsTooManyMediaURLs(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	expectPreferences(mock, "user-1")
+
+	_, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "Look",
+		Metadata: map[string]string{MetadataMediaURLs: mediaURLs(MaxSMSMediaURLs + 1)},
+	})
+	if !errors.Is(err, ErrInvalidSMSOptions) {
+		t.Errorf("CreateNotification() error = %v, want ErrInvalidSMSOptions", err)
+	}
+}
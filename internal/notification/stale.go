@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policies for sent notifications whose delivery was not confirmed within the SLA
+const (
+	// StalePolicyFlag only counts them, leaving them sent
+	StalePolicyFlag = "flag"
+	// StalePolicyDelivered assumes they were delivered
+	StalePolicyDelivered = "delivered"
+	// StalePolicyUnknown moves them to the unknown status
+	StalePolicyUnknown = "unknown"
+)
+
+// StaleBatchSize caps how many stale notifications are swept per run
+const StaleBatchSize = 500
+
+// staleDetail is recorded in the status history of notifications moved by the sweeper
+const staleDetail = "delivery not confirmed within SLA"
+
+// NewStalePolicy returns the stale policy named by value, defaulting to flag
+func NewStalePolicy(value string, logger *zap.Logger) string {
+	switch value {
+	case StalePolicyFlag, StalePolicyDelivered, StalePolicyUnknown:
+		return value
+	case "":
+		return StalePolicyFlag
+	default:
+		logger.Warn("Invalid delivery stale policy, using flag", zap.String("value", value))
+		return StalePolicyFlag
+	}
+}
+
+// SweepStaleSent flags up to StaleBatchSize notifications that were sent more
+// than sla ago without a delivery confirmation. Each notification is flagged
+// once; with StalePolicyDelivered or StalePolicyUnknown it is also moved to
+// that status. Rows are locked while swept, so concurrent sweeps in several API
+// instances never flag the same notification twice. It returns the number
+// flagged per channel.
+func (s *Service) SweepStaleSent(ctx context.Context, sla time.Duration, policy string) (map[string]int, error) {
+	now := time.Now()
+	args := []interface{}{StatusSent, now.Add(-sla), StaleBatchSize, now}
+
+	set := "stale_at = $4"
+	status := NotificationStatus("")
+	switch policy {
+	case StalePolicyDelivered:
+		status = StatusDelivered
+		set += ", status = $5, delivered_at = $4, updated_at = $4"
+		args = append(args, status)
+	case StalePolicyUnknown:
+		status = StatusUnknown
+		set += ", status = $5, updated_at = $4"
+		args = append(args, status)
+	}
+
+	query := `
+		WITH stale AS (
+			SELECT id AS stale_id FROM notifications
+			WHERE status = $1 AND stale_at IS NULL AND sent_at < $2
+			ORDER BY sent_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE notifications SET ` + set + `
+		FROM stale WHERE notifications.id = stale.stale_id
+		RETURNING ` + notificationColumns
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep stale notifications: %w", err)
+	}
+
+	var swept []*Notification
+	for rows.Next() {
+		notification, err := s.scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		swept = append(swept, notification)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to sweep stale notifications: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, notification := range swept {
+		counts[notification.Channel]++
+		if status != "" {
+			s.recordEvent(ctx, notification.ID, status, "", staleDetail)
+			s.cacheNotification(ctx, notification)
+		}
+	}
+
+	if len(swept) > 0 {
+		s.logger.Warn("Sent notifications past delivery SLA",
+			zap.Int("count", len(swept)),
+			zap.Duration("sla", sla),
+			zap.String("policy", policy),
+		)
+	}
+	return counts, nil
+}
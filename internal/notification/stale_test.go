@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"go.uber.org/zap"
+)
+
+func TestNewStalePolicy(t *testing.T) {
+	for value, want := range map[string]string{
+		"":          StalePolicyFlag,
+		"flag":      StalePolicyFlag,
+		"delivered": StalePolicyDelivered,
+		"unknown":   StalePolicyUnknown,
+		"drop":      StalePolicyFlag,
+	} {
+		if got := NewStalePolicy(value, zap.NewNop()); got != want {
+			t.Errorf("NewStalePolicy(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+// staleRows returns sent notifications past the SLA, as returned by the sweep
+// after it moved them to status
+func staleRows(status NotificationStatus) *dbtest.Rows {
+	sentAt := time.Now().Add(-2 * time.Hour)
+	return notificationRows(
+		Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: status, SentAt: &sentAt},
+		Notification{ID: "n2", UserID: "user-2", Channel: "email", Status: status, SentAt: &sentAt},
+		Notification{ID: "n3", UserID: "user-3", Channel: "sms", Status: status, SentAt: &sentAt},
+	)
+}
+
+func TestSweepStaleSent(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantSet    string
+		wantStatus NotificationStatus // status the notifications move to, empty when left sent
+	}{
+		{StalePolicyFlag, "SET stale_at = $4 FROM stale", ""},
+		{StalePolicyDelivered, "SET stale_at = $4, status = $5, delivered_at = $4, updated_at = $4 FROM stale", StatusDelivered},
+		{StalePolicyUnknown, "SET stale_at = $4, status = $5, updated_at = $4 FROM stale", StatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s, mock := newTestService(t, nil)
+
+			// Only sent notifications not flagged before, sent before the SLA
+			args := []any{StatusSent, dbtest.AnyArg(), StaleBatchSize, dbtest.AnyArg()}
+			rowStatus := StatusSent
+			if tt.wantStatus != "" {
+				args = append(args, tt.wantStatus)
+				rowStatus = tt.wantStatus
+			}
+			mock.ExpectQuery("WHERE status = $1 AND stale_at IS NULL AND sent_at < $2 ORDER BY sent_at LIMIT $3 FOR UPDATE SKIP LOCKED ) UPDATE notifications " + tt.wantSet).
+				WithArgs(args...).WillReturnRows(staleRows(rowStatus))
+			if tt.wantStatus != "" {
+				for _, id := range []string{"n1", "n2", "n3"} {
+					expectEvent(mock, id, tt.wantStatus)
+				}
+			}
+
+			counts, err := s.SweepStaleSent(context.Background(), time.Hour, tt.policy)
+			if err != nil {
+				t.Fatalf("SweepStaleSent() error = %v", err)
+			}
+			if want := map[string]int{"sms": 2, "email": 1}; !reflect.DeepEqual(counts, want) {
+				t.Errorf("SweepStaleSent() = %v, want %v", counts, want)
+			}
+		})
+	}
+}
+
+func TestSweepStaleSentNothingStale(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("WITH stale AS").WillReturnRows(notificationRows())
+
+	counts, err := s.SweepStaleSent(context.Background(), time.Hour, StalePolicyUnknown)
+	if err != nil || len(counts) != 0 {
+		t.Errorf("SweepStaleSent() = %v, %v, want nothing flagged", counts, err)
+	}
+}
@@ -0,0 +1,188 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// MaxStatusBatchSize caps how many status updates UpdateNotificationStatusBatch applies at once
+const MaxStatusBatchSize = 500
+
+// StatusUpdate is one status change of UpdateNotificationStatusBatch
+type StatusUpdate struct {
+	ID           string
+	Status       NotificationStatus
+	ExternalID   string
+	ErrorMessage string
+}
+
+// ordinalScanner scans a row returned with its update's ordinal in front of
+// notificationColumns
+type ordinalScanner struct {
+	rows    *sql.Rows
+	ordinal *int64
+}
+
+func (s ordinalScanner) Scan(dest ...interface{}) error {
+	return s.rows.Scan(append([]interface{}{s.ordinal}, dest...)...)
+}
+
+// UpdateNotificationStatusBatch applies many status updates in one statement.
+// Each update follows the rules of UpdateNotificationStatus: repeating the
+// current status is a no-op, a move the status cannot make fails with
+// ErrInvalidStatusTransition and an unknown notification is not found. It
+// returns one error per update, nil for those applied, and an error only when
+// the batch as a whole could not be applied.
+func (s *Service) UpdateNotificationStatusBatch(ctx context.Context, updates []StatusUpdate) ([]error, error) {
+	if len(updates) > MaxStatusBatchSize {
+		return nil, fmt.Errorf("at most %d status updates can be applied at once", MaxStatusBatchSize)
+	}
+	errs := make([]error, len(updates))
+
+	// A notification may only change once per batch, and IDs that are not
+	// UUIDs would fail the whole statement. IDs are compared in the canonical
+	// form Postgres returns.
+	var ids, statuses, externalIDs, errorMessages []string
+	var ordinals []int64
+	canonical := make(map[int64]string, len(updates))
+	seen := make(map[string]bool, len(updates))
+	for i, update := range updates {
+		parsed, err := uuid.Parse(update.ID)
+		switch {
+		case !update.Status.IsValid():
+			errs[i] = fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, update.Status)
+			continue
+		case err != nil:
+			errs[i] = ErrNotificationNotFound
+			continue
+		case seen[parsed.String()]:
+			errs[i] = fmt.Errorf("notification %s is updated more than once in the batch", update.ID)
+			continue
+		}
+		id := parsed.String()
+		seen[id] = true
+		canonical[int64(i)] = id
+		ids = append(ids, id)
+		statuses = append(statuses, string(update.Status))
+		externalIDs = append(externalIDs, update.ExternalID)
+		errorMessages = append(errorMessages, update.ErrorMessage)
+		ordinals = append(ordinals, int64(i))
+	}
+	if len(ids) == 0 {
+		return errs, nil
+	}
+
+	var fromStatuses, toStatuses []string
+	for from, nexts := range statusTransitions {
+		for _, to := range nexts {
+			fromStatuses = append(fromStatuses, string(from))
+			toStatuses = append(toStatuses, string(to))
+		}
+	}
+
+	// Like UpdateNotificationStatus, only rows whose status may move to the
+	// new one are updated, so concurrent updates cannot regress a notification
+	query := `
+		UPDATE notifications
+		SET status = u.new_status, external_id = u.new_external_id, error_message = u.new_error_message, updated_at = $7,
+		    sent_at = CASE WHEN u.new_status = 'sent' THEN $7 ELSE sent_at END,
+		    delivered_at = CASE WHEN u.new_status = 'delivered' THEN $7 ELSE delivered_at END
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::bigint[])
+		     AS u(update_id, new_status, new_external_id, new_error_message, ordinal)
+		WHERE notifications.id = u.update_id
+		  AND ($6 = '' OR notifications.tenant_id = $6)
+		  AND EXISTS (
+		      SELECT 1 FROM unnest($8::text[], $9::text[]) AS t(from_status, to_status)
+		      WHERE t.from_status = notifications.status AND t.to_status = u.new_status)
+		RETURNING u.ordinal, ` + notificationColumns
+
+	rows, err := s.db.QueryContext(ctx, query,
+		pq.Array(ids), pq.Array(statuses), pq.Array(externalIDs), pq.Array(errorMessages), pq.Array(ordinals),
+		TenantFromContext(ctx), time.Now(), pq.Array(fromStatuses), pq.Array(toStatuses),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification statuses: %w", err)
+	}
+
+	updated := make(map[int64]*Notification, len(ids))
+	for rows.Next() {
+		var ordinal int64
+		notification, err := s.scanNotification(ordinalScanner{rows: rows, ordinal: &ordinal})
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan updated notification: %w", err)
+		}
+		updated[ordinal] = notification
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to update notification statuses: %w", err)
+	}
+
+	// Tell no-ops apart from invalid moves and unknown notifications
+	if len(updated) < len(ids) {
+		current, err := s.currentStatuses(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, ordinal := range ordinals {
+			if updated[ordinal] != nil {
+				continue
+			}
+			update := updates[ordinal]
+			status, ok := current[canonical[ordinal]]
+			switch {
+			case !ok:
+				errs[ordinal] = ErrNotificationNotFound
+			case status == update.Status:
+				s.logger.Debug("Notification already has status", zap.String("id", update.ID), zap.String("status", string(status)))
+			default:
+				errs[ordinal] = fmt.Errorf("%w: notification %s cannot move from %s to %s", ErrInvalidStatusTransition, update.ID, status, update.Status)
+			}
+		}
+	}
+
+	for _, ordinal := range ordinals {
+		notification := updated[ordinal]
+		if notification == nil {
+			continue
+		}
+		update := updates[ordinal]
+		s.recordEvent(ctx, notification.ID, update.Status, update.ExternalID, update.ErrorMessage)
+		s.cacheNotification(ctx, notification)
+	}
+	s.logger.Info("Updated notification statuses", zap.Int("count", len(updated)), zap.Int("requested", len(updates)))
+	return errs, nil
+}
+
+// currentStatuses returns the status of each of the notifications that exists
+// and is visible to the tenant in ctx
+func (s *Service) currentStatuses(ctx context.Context, ids []string) (map[string]NotificationStatus, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, status FROM notifications
+		WHERE id = ANY($1::uuid[]) AND ($2 = '' OR tenant_id = $2)`, pq.Array(ids), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]NotificationStatus, len(ids))
+	for rows.Next() {
+		var id string
+		var status NotificationStatus
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, fmt.Errorf("failed to scan notification status: %w", err)
+		}
+		statuses[id] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification statuses: %w", err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+const (
+	batchID1 = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b01"
+	batchID2 = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b02"
+	batchID3 = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b03"
+	batchID4 = "8f14e45f-ceea-467f-a0e6-8d7b6c2c9b04"
+)
+
+// updatedRows returns rows as returned by the batch update: each update's
+// ordinal in front of the updated notification
+func updatedRows(ordinals []int64, notifications ...Notification) *dbtest.Rows {
+	rows := dbtest.NewRows(append([]string{"ordinal"}, notificationColumnNames()...)...)
+	for i, n := range notifications {
+		rows.AddRow(append([]any{ordinals[i]}, notificationRowValues(n)...)...)
+	}
+	return rows
+}
+
+func TestUpdateNotificationStatusBatch(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	updates := []StatusUpdate{
+		{ID: batchID1, Status: StatusSent, ExternalID: "SM1"},
+		{ID: batchID2, Status: StatusFailed, ErrorMessage: "carrier error"},
+	}
+
+	// Both rows are updated in one statement
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").WillReturnRows(updatedRows([]int64{1, 0},
+		Notification{ID: batchID2, Channel: "sms", Status: StatusFailed, ErrorMessage: "carrier error"},
+		Notification{ID: batchID1, Channel: "sms", Status: StatusSent, ExternalID: "SM1"},
+	))
+	expectEvent(mock, batchID1, StatusSent)
+	expectEvent(mock, batchID2, StatusFailed)
+
+	errs, err := s.UpdateNotificationStatusBatch(context.Background(), updates)
+	if err != nil {
+		t.Fatalf("UpdateNotificationStatusBatch() error = %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("update %d error = %v, want applied", i, err)
+		}
+	}
+}
+
+func TestUpdateNotificationStatusBatchPartialFailure(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	updates := []StatusUpdate{
+		{ID: batchID1, Status: StatusSent},             // applied
+		{ID: batchID2, Status: StatusPending},          // cannot move back from sent
+		{ID: batchID3, Status: StatusSent},             // already sent: a no-op
+		{ID: batchID4, Status: StatusSent},             // unknown
+		{ID: "not-a-uuid", Status: StatusSent},         // never reaches the database
+		{ID: batchID1, Status: StatusDelivered},        // second update of batchID1
+		{ID: batchID2, Status: NotificationStatus("")}, // unknown status
+	}
+
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").
+		WillReturnRows(updatedRows([]int64{0}, Notification{ID: batchID1, Channel: "sms", Status: StatusSent}))
+	mock.ExpectQuery("SELECT id, status FROM notifications WHERE id = ANY($1::uuid[])").
+		WillReturnRows(dbtest.NewRows("id", "status").AddRow(batchID1, "sent").AddRow(batchID2, "sent").AddRow(batchID3, "sent"))
+	expectEvent(mock, batchID1, StatusSent)
+
+	errs, err := s.UpdateNotificationStatusBatch(context.Background(), updates)
+	if err != nil {
+		t.Fatalf("UpdateNotificationStatusBatch() error = %v", err)
+	}
+	if len(errs) != len(updates) {
+		t.Fatalf("got %d results, want one per update", len(errs))
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("errs[0], errs[2] = %v, %v, want applied and a no-op", errs[0], errs[2])
+	}
+	if !errors.Is(errs[1], ErrInvalidStatusTransition) {
+		t.Errorf("errs[1] = %v, want ErrInvalidStatusTransition", errs[1])
+	}
+	if !errors.Is(errs[3], ErrNotificationNotFound) || !errors.Is(errs[4], ErrNotificationNotFound) {
+		t.Errorf("errs[3], errs[4] = %v, %v, want ErrNotificationNotFound", errs[3], errs[4])
+	}
+	if errs[5] == nil {
+		t.Error("errs[5] = nil, want the repeated update rejected")
+	}
+	if !errors.Is(errs[6], ErrInvalidStatusTransition) {
+		t.Errorf("errs[6] = %v, want ErrInvalidStatusTransition", errs[6])
+	}
+}
+
+func TestUpdateNotificationStatusBatchFailure(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").WillReturnError(errors.New("connection reset"))
+
+	if _, err := s.UpdateNotificationStatusBatch(context.Background(), []StatusUpdate{{ID: batchID1, Status: StatusSent}}); err == nil {
+		t.Error("UpdateNotificationStatusBatch() error = nil, want the batch to fail")
+	}
+
+	// Oversized batches are rejected without touching the database
+	if _, err := s.UpdateNotificationStatusBatch(context.Background(), make([]StatusUpdate, MaxStatusBatchSize+1)); err == nil {
+		t.Error("UpdateNotificationStatusBatch() of an oversized batch error = nil")
+	}
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reasons an address is suppressed
+const (
+	SuppressionBounce    = "bounce"
+	SuppressionComplaint = "complaint"
+)
+
+var (
+	// ErrRecipientSuppressed is returned when emailing an address that bounced or complained
+	ErrRecipientSuppressed = errors.New("recipient is suppressed")
+	// ErrSuppressionNotFound is returned when removing an address that is not suppressed
+	ErrSuppressionNotFound = errors.New("suppression not found")
+)
+
+// Suppression is an email address that is no longer sent to
+type Suppression struct {
+	Email     string    `json:"email" db:"email"`
+	Reason    string    `json:"reason" db:"reason"` // bounce or complaint
+	Detail    string    `json:"detail,omitempty" db:"detail"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// normalizeEmail lower-cases and trims an address so lookups ignore case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// AddSuppression stops email to the address. Suppressing an address again
+// updates the reason and detail.
+func (s *Service) AddSuppression(ctx context.Context, email, reason, detail string) error {
+	query := `
+		INSERT INTO suppressions (email, reason, detail)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE SET reason = EXCLUDED.reason, detail = EXCLUDED.detail`
+	if _, err := s.db.ExecContext(ctx, query, normalizeEmail(email), reason, nullIfEmpty(detail)); err != nil {
+		return fmt.Errorf("failed to add suppression: %w", err)
+	}
+
+	s.logger.Info("Suppressed email address", zap.String("reason", reason))
+	return nil
+}
+
+// RemoveSuppression re-enables email to the address
+func (s *Service) RemoveSuppression(ctx context.Context, email string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM suppressions WHERE email = $1`, normalizeEmail(email))
+	if err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+	if rows == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// ListSuppressions returns suppressed addresses, newest first
+func (s *Service) ListSuppressions(ctx context.Context, limit int) ([]Suppression, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT email, reason, COALESCE(detail, ''), created_at
+		FROM suppressions
+		ORDER BY created_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	suppressions := make([]Suppression, 0)
+	for rows.Next() {
+		var sup Suppression
+		if err := rows.Scan(&sup.Email, &sup.Reason, &sup.Detail, &sup.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan suppression: %w", err)
+		}
+		suppressions = append(suppressions, sup)
+	}
+	return suppressions, rows.Err()
+}
+
+// checkSuppressed returns ErrRecipientSuppressed when the address is suppressed
+func (s *Service) checkSuppressed(ctx context.Context, email string) error {
+	var reason string
+	err := s.db.QueryRowContext(ctx, `SELECT reason FROM suppressions WHERE email = $1`, normalizeEmail(email)).Scan(&reason)
+	if err == nil {
+		return fmt.Errorf("%w: address is suppressed after a %s", ErrRecipientSuppressed, reason)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return fmt.Errorf("failed to check suppression list: %w", err)
+}
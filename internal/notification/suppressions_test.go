@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+func TestCreateNotificationRejectsSuppressedAddress(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	ctx := context.Background()
+	req := NotificationRequest{UserID: "user-1", Channel: "email", Recipient: " Bounced@Example.com", Subject: "Hi", Body: "hi"}
+
+	// The address is looked up normalized, and rejected while suppressed
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT reason FROM suppressions WHERE email = $1").WithArgs("bounced@example.com").
+		WillReturnRows(dbtest.NewRows("reason").AddRow(SuppressionBounce))
+	if _, err := s.CreateNotification(ctx, req); !errors.Is(err, ErrRecipientSuppressed) {
+		t.Fatalf("CreateNotification() while suppressed error = %v, want ErrRecipientSuppressed", err)
+	}
+
+	// Removing the suppression re-enables sending
+	mock.ExpectExec("DELETE FROM suppressions WHERE email = $1").WithArgs("bounced@example.com").WillReturnResult(1)
+	if err := s.RemoveSuppression(ctx, "Bounced@Example.com"); err != nil {
+		t.Fatalf("RemoveSuppression() error = %v", err)
+	}
+	expectPreferences(mock, "user-1")
+	mock.ExpectQuery("SELECT reason FROM suppressions WHERE email = $1").WithArgs("bounced@example.com").
+		WillReturnRows(dbtest.NewRows("reason"))
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "email", Recipient: "bounced@example.com", Status: StatusPending})
+	if _, err := s.CreateNotification(ctx, req); err != nil {
+		t.Fatalf("CreateNotification() after removal error = %v", err)
+	}
+}
+
+func TestSuppressionsOnlyApplyToEmail(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	// SMS recipients are never looked up in the suppression list
+	expectPreferences(mock, "user-1")
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Status: StatusPending})
+	if _, err := s.CreateNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}); err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+}
+
+func TestAddSuppression(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("INSERT INTO suppressions").WithArgs("a@example.com", SuppressionComplaint, nil).WillReturnResult(1)
+
+	if err := s.AddSuppression(context.Background(), "A@example.com ", SuppressionComplaint, ""); err != nil {
+		t.Fatalf("AddSuppression() error = %v", err)
+	}
+}
+
+func TestRemoveSuppressionNotFound(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectExec("DELETE FROM suppressions WHERE email = $1").WithArgs("a@example.com").WillReturnResult(0)
+
+	if err := s.RemoveSuppression(context.Background(), "a@example.com"); !errors.Is(err, ErrSuppressionNotFound) {
+		t.Errorf("RemoveSuppression() error = %v, want ErrSuppressionNotFound", err)
+	}
+}
+
+func TestListSuppressions(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	mock.ExpectQuery("FROM suppressions").WithArgs(maxListLimit).
+		WillReturnRows(dbtest.NewRows("email", "reason", "detail", "created_at").AddRow("a@example.com", SuppressionBounce, "550 mailbox unavailable", time.Now()))
+
+	suppressions, err := s.ListSuppressions(context.Background(), maxListLimit+1)
+	if err != nil {
+		t.Fatalf("ListSuppressions() error = %v", err)
+	}
+	if len(suppressions) != 1 || suppressions[0].Email != "a@example.com" || suppressions[0].Detail != "550 mailbox unavailable" {
+		t.Errorf("ListSuppressions() = %+v", suppressions)
+	}
+}
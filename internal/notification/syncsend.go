@@ -0,0 +1,38 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSyncSend is returned for requests that cannot be sent synchronously
+var ErrInvalidSyncSend = errors.New("invalid synchronous send")
+
+// syncSendDetail is recorded in the status history of synchronous sends
+const syncSendDetail = "sync send"
+
+// PrepareSyncNotification validates req and builds its notification as
+// CreateNotification would, for a caller that sends it through its channel
+// before responding instead of queueing it. The notification is stored with
+// the outcome of the send by RecordSyncSend.
+func (s *Service) PrepareSyncNotification(ctx context.Context, req NotificationRequest) (*Notification, error) {
+	if len(req.Recipients) > 0 || req.Topic != "" {
+		return nil, fmt.Errorf("%w: synchronous sends go to a single recipient", ErrInvalidSyncSend)
+	}
+	if req.ScheduledAt != nil || req.SendWindowStart != nil || req.SendWindowEnd != nil {
+		return nil, fmt.Errorf("%w: synchronous sends cannot be scheduled", ErrInvalidSyncSend)
+	}
+
+	pending, err := s.prepareNotification(ctx, req, "")
+	if err != nil {
+		return nil, err
+	}
+	return pending.notification, nil
+}
+
+// RecordSyncSend stores a notification sent synchronously with the outcome in
+// report. It is never queued, so workers do not send it again.
+func (s *Service) RecordSyncSend(ctx context.Context, notification *Notification, report *DeliveryReport) error {
+	return s.recordDirectSend(ctx, notification, report, syncSendDetail)
+}
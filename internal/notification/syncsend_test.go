@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestPrepareSyncNotificationRejectsQueuedFeatures(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Channels: enabledChannels()})
+	later := time.Now().Add(time.Hour)
+	base := NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}
+
+	for name, modify := range map[string]func(*NotificationRequest){
+		"several recipients": func(r *NotificationRequest) { r.Recipients = []string{"+15551234567", "+15557654321"} },
+		"topic":              func(r *NotificationRequest) { r.Topic = "news" },
+		"scheduled":          func(r *NotificationRequest) { r.ScheduledAt = &later },
+		"send window start":  func(r *NotificationRequest) { r.SendWindowStart = &later },
+		"send window end":    func(r *NotificationRequest) { r.SendWindowEnd = &later },
+	} {
+		req := base
+		modify(&req)
+		if _, err := s.PrepareSyncNotification(context.Background(), req); !errors.Is(err, ErrInvalidSyncSend) {
+			t.Errorf("PrepareSyncNotification(%s) error = %v, want ErrInvalidSyncSend", name, err)
+		}
+	}
+}
+
+func TestPrepareSyncNotification(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+
+	// Checked like a created notification, so blocked users are not sent to
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "sms", Enabled: false})
+	_, err := s.PrepareSyncNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if !errors.Is(err, ErrNotificationsDisabled) {
+		t.Errorf("PrepareSyncNotification() error = %v, want ErrNotificationsDisabled", err)
+	}
+
+	expectPreferences(mock, "user-2")
+	notif, err := s.PrepareSyncNotification(context.Background(), NotificationRequest{UserID: "user-2", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("PrepareSyncNotification() error = %v", err)
+	}
+	if notif.ID == "" || notif.Status != StatusPending || notif.Recipient != "+15551234567" {
+		t.Errorf("PrepareSyncNotification() = %+v, want a pending notification", notif)
+	}
+}
+
+func TestRecordSyncSend(t *testing.T) {
+	s, mock := newTestService(t, nil)
+
+	// Stored with the outcome and never handed to the outbox
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs("n1", "failed", nil, "sync send: invalid number").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WillReturnResult(0)
+
+	notif := &Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending}
+	err := s.RecordSyncSend(context.Background(), notif, &DeliveryReport{NotificationID: "n1", Status: StatusFailed, ErrorMessage: "invalid number"})
+	if err != nil {
+		t.Fatalf("RecordSyncSend() error = %v", err)
+	}
+	if notif.Status != StatusFailed || notif.ErrorMessage != "invalid number" {
+		t.Errorf("notification = %+v, want failed with the send error", notif)
+	}
+
+	mock.ExpectExec("INSERT INTO notifications").WillReturnError(errors.New("connection reset"))
+	if err := s.RecordSyncSend(context.Background(), notif, &DeliveryReport{Status: StatusSent}); err == nil {
+		t.Error("RecordSyncSend() error = nil, want the insert failure")
+	}
+}
@@ -0,0 +1,299 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/lib/pq"
+)
+
+// DefaultLocale is used when neither the request nor the user's preferences pick a template locale
+const DefaultLocale = "en"
+
+var (
+	// ErrTemplateNotFound is returned when no template matches the requested name, channel and locale
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrTemplateRender is returned when a template cannot be parsed or executed with the given variables
+	ErrTemplateRender = errors.New("failed to render template")
+)
+
+// TemplatePreview is a template rendered for given variables without sending anything
+type TemplatePreview struct {
+	Name     string   `json:"name"`
+	Channel  string   `json:"channel"`
+	Locale   string   `json:"locale"` // locale of the template that was rendered, after fallback
+	Subject  string   `json:"subject,omitempty"`
+	Body     string   `json:"body"`
+	Warnings []string `json:"warnings"`
+}
+
+// localeCandidates returns the locales to try, most specific first:
+// the locale itself, its base language (pt-BR -> pt) and DefaultLocale
+func localeCandidates(locale string) []string {
+	candidates := make([]string, 0, 3)
+	add := func(l string) {
+		if l == "" {
+			return
+		}
+		for _, c := range candidates {
+			if c == l {
+				return
+			}
+		}
+		candidates = append(candidates, l)
+	}
+
+	add(locale)
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		add(locale[:i])
+	}
+	add(DefaultLocale)
+
+	return candidates
+}
+
+// getTemplate loads the best matching template for name and channel,
+// falling back from locale to its base language and then to DefaultLocale.
+// Tenants see their own templates and the shared ones without a tenant,
+// preferring their own for the same locale. Resolved templates are cached in
+// Redis until UpsertTemplate changes the name and channel.
+func (s *Service) getTemplate(ctx context.Context, name, channel, locale string) (*NotificationTemplate, error) {
+	tenantID := TenantFromContext(ctx)
+	if tmpl, ok := s.cachedTemplate(ctx, name, channel, tenantID, locale); ok {
+		return tmpl, nil
+	}
+
+	candidates := localeCandidates(locale)
+
+	query := `
+		SELECT id, name, channel, locale, COALESCE(subject_template, ''), body_template, variables, created_at, updated_at
+		FROM notification_templates
+		WHERE name = $1 AND channel = $2 AND locale = ANY($3::text[])
+		  AND (tenant_id IS NULL OR tenant_id = NULLIF($4, ''))
+		ORDER BY array_position($3::text[], locale::text), tenant_id IS NULL
+		LIMIT 1
+	`
+
+	var tmpl NotificationTemplate
+	var variables []byte
+	err := s.db.QueryRowContext(ctx, query, name, channel, pq.Array(candidates), tenantID).Scan(
+		&tmpl.ID, &tmpl.Name, &tmpl.Channel, &tmpl.Locale, &tmpl.SubjectTemplate,
+		&tmpl.BodyTemplate, &variables, &tmpl.CreatedAt, &tmpl.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s (%s, %s)", ErrTemplateNotFound, name, channel, strings.Join(candidates, ", "))
+		}
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	if len(variables) > 0 {
+		if err := json.Unmarshal(variables, &tmpl.Variables); err != nil {
+			return nil, fmt.Errorf("failed to decode template variables: %w", err)
+		}
+	}
+
+	s.cacheTemplate(ctx, name, channel, tenantID, locale, &tmpl)
+	return &tmpl, nil
+}
+
+// UpsertTemplate creates the template or replaces the one with the same name,
+// channel and locale, for the tenant in ctx or shared when there is none. The
+// subject and body must parse. Cached resolutions of the name and channel are
+// deleted before it returns, so the next render uses the new content.
+func (s *Service) UpsertTemplate(ctx context.Context, tmpl *NotificationTemplate) error {
+	if tmpl.Locale == "" {
+		tmpl.Locale = DefaultLocale
+	}
+	if _, err := template.New(tmpl.Name + ":subject").Parse(tmpl.SubjectTemplate); err != nil {
+		return fmt.Errorf("%w: %s:subject: %v", ErrTemplateRender, tmpl.Name, err)
+	}
+	if _, err := template.New(tmpl.Name + ":body").Parse(tmpl.BodyTemplate); err != nil {
+		return fmt.Errorf("%w: %s:body: %v", ErrTemplateRender, tmpl.Name, err)
+	}
+
+	var variables []byte
+	if len(tmpl.Variables) > 0 {
+		var err error
+		if variables, err = json.Marshal(tmpl.Variables); err != nil {
+			return fmt.Errorf("failed to encode template variables: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO notification_templates (name, channel, locale, subject_template, body_template, variables, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		ON CONFLICT (COALESCE(tenant_id, ''), name, channel, locale) DO UPDATE SET
+			subject_template = EXCLUDED.subject_template,
+			body_template = EXCLUDED.body_template,
+			variables = EXCLUDED.variables,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+	err := s.db.QueryRowContext(ctx, query, tmpl.Name, tmpl.Channel, tmpl.Locale, nullIfEmpty(tmpl.SubjectTemplate),
+		tmpl.BodyTemplate, variables, TenantFromContext(ctx)).Scan(&tmpl.ID, &tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save template: %w", err)
+	}
+
+	if err := s.invalidateTemplate(ctx, tmpl.Name, tmpl.Channel); err != nil {
+		return fmt.Errorf("template saved but its cache could not be invalidated: %w", err)
+	}
+	return nil
+}
+
+// renderTemplate renders the template's subject and body. Variables passed in the
+// request override the template's default variables.
+func renderTemplate(tmpl *NotificationTemplate, variables map[string]string) (subject, body string, err error) {
+	data := make(map[string]string, len(tmpl.Variables)+len(variables))
+	for k, v := range tmpl.Variables {
+		data[k] = v
+	}
+	for k, v := range variables {
+		data[k] = v
+	}
+
+	if subject, err = executeTemplate(tmpl.Name+":subject", tmpl.SubjectTemplate, data); err != nil {
+		return "", "", err
+	}
+	if body, err = executeTemplate(tmpl.Name+":body", tmpl.BodyTemplate, data); err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+// executeTemplate parses and executes a single text/template string
+func executeTemplate(name, text string, data map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// PreviewTemplate renders a template as CreateNotification would, but without
+// persisting or sending anything. Variables the template uses that are neither
+// supplied nor defaulted are rendered empty and reported as warnings.
+func (s *Service) PreviewTemplate(ctx context.Context, name, channel, locale string, variables map[string]string) (*TemplatePreview, error) {
+	tmpl, err := s.getTemplate(ctx, name, channel, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(tmpl.Variables)+len(variables))
+	for k, v := range tmpl.Variables {
+		data[k] = v
+	}
+	for k, v := range variables {
+		data[k] = v
+	}
+
+	preview := &TemplatePreview{
+		Name:     tmpl.Name,
+		Channel:  tmpl.Channel,
+		Locale:   tmpl.Locale,
+		Warnings: []string{},
+	}
+
+	missing := make(map[string]bool)
+	if preview.Subject, err = previewTemplate(tmpl.Name+":subject", tmpl.SubjectTemplate, data, missing); err != nil {
+		return nil, err
+	}
+	if preview.Body, err = previewTemplate(tmpl.Name+":body", tmpl.BodyTemplate, data, missing); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("variable %q is not set", name))
+	}
+
+	return preview, nil
+}
+
+// previewTemplate executes a template string leniently, rendering unset
+// variables empty and adding their names to missing
+func previewTemplate(name, text string, data map[string]string, missing map[string]bool) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+
+	for _, variable := range templateVariables(t.Tree.Root) {
+		if _, ok := data[variable]; !ok {
+			missing[variable] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTemplateRender, name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// templateVariables returns the top-level variables (.Name) a parsed template refers to
+func templateVariables(node parse.Node) []string {
+	var names []string
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			// Fields inside the body refer to the element, not the variables
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			names = append(names, n.Ident[0])
+		}
+	}
+	walk(node)
+	return names
+}
@@ -0,0 +1,316 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/lib/pq"
+)
+
+// templateRows returns templates as rows of the notification_templates table
+func templateRows(templates ...NotificationTemplate) *dbtest.Rows {
+	rows := dbtest.NewRows("id", "name", "channel", "locale", "subject_template", "body_template", "variables", "created_at", "updated_at")
+	for _, tmpl := range templates {
+		var variables any
+		if len(tmpl.Variables) > 0 {
+			variables, _ = json.Marshal(tmpl.Variables)
+		}
+		rows.AddRow(tmpl.ID, tmpl.Name, tmpl.Channel, tmpl.Locale, tmpl.SubjectTemplate, tmpl.BodyTemplate, variables, time.Now(), time.Now())
+	}
+	return rows
+}
+
+// expectTemplate expects a template lookup trying locales in order,
+// returning templates
+func expectTemplate(mock *dbtest.Mock, name, channel string, locales []string, templates ...NotificationTemplate) {
+	mock.ExpectQuery("FROM notification_templates").WithArgs(name, channel, pq.Array(locales), "").
+		WillReturnRows(templateRows(templates...))
+}
+
+func TestLocaleCandidates(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"", []string{"en"}},
+		{"en", []string{"en"}},
+		{"fr", []string{"fr", "en"}},
+		{"pt-BR", []string{"pt-BR", "pt", "en"}},
+		{"pt_BR", []string{"pt_BR", "pt", "en"}},
+		{"en-GB", []string{"en-GB", "en"}},
+	}
+
+	for _, tt := range tests {
+		if got := localeCandidates(tt.locale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("localeCandidates(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTemplateInTwoLocales(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	english := NotificationTemplate{ID: "t1", Name: "welcome", Channel: "email", Locale: "en", SubjectTemplate: "Welcome {{.name}}", BodyTemplate: "Hello {{.name}}"}
+	french := NotificationTemplate{ID: "t2", Name: "welcome", Channel: "email", Locale: "fr", SubjectTemplate: "Bienvenue {{.name}}", BodyTemplate: "Bonjour {{.name}}"}
+	expectTemplate(mock, "welcome", "email", []string{"en"}, english)
+	expectTemplate(mock, "welcome", "email", []string{"fr", "en"}, french)
+
+	tests := []struct {
+		locale      string
+		wantSubject string
+		wantBody    string
+	}{
+		{"en", "Welcome Ana", "Hello Ana"},
+		{"fr", "Bienvenue Ana", "Bonjour Ana"},
+	}
+	for _, tt := range tests {
+		tmpl, err := s.getTemplate(context.Background(), "welcome", "email", tt.locale)
+		if err != nil {
+			t.Fatalf("getTemplate(%s) error = %v", tt.locale, err)
+		}
+		subject, body, err := renderTemplate(tmpl, map[string]string{"name": "Ana"})
+		if err != nil {
+			t.Fatalf("renderTemplate(%s) error = %v", tt.locale, err)
+		}
+		if subject != tt.wantSubject || body != tt.wantBody {
+			t.Errorf("%s: rendered %q, %q, want %q, %q", tt.locale, subject, body, tt.wantSubject, tt.wantBody)
+		}
+	}
+}
+
+func TestGetTemplateFallsBackToDefaultLocale(t *testing.T) {
+	s, mock := newTestService(t, nil)
+
+	// The database returns the first candidate locale that has the template,
+	// here the default one since there is no German template
+	expectTemplate(mock, "welcome", "email", []string{"de-AT", "de", "en"},
+		NotificationTemplate{ID: "t1", Name: "welcome", Channel: "email", Locale: "en", BodyTemplate: "Hello"})
+
+	tmpl, err := s.getTemplate(context.Background(), "welcome", "email", "de-AT")
+	if err != nil {
+		t.Fatalf("getTemplate() error = %v", err)
+	}
+	if tmpl.Locale != DefaultLocale {
+		t.Errorf("Locale = %q, want the default %q", tmpl.Locale, DefaultLocale)
+	}
+}
+
+func TestGetTemplateNotFound(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	expectTemplate(mock, "welcome", "sms", []string{"fr", "en"})
+
+	if _, err := s.getTemplate(context.Background(), "welcome", "sms", "fr"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("getTemplate() error = %v, want ErrTemplateNotFound", err)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tmpl := &NotificationTemplate{
+		Name:            "reset",
+		SubjectTemplate: "{{.product}} password reset",
+		BodyTemplate:    "Hi {{.name}}, use code {{.code}}",
+		Variables:       map[string]string{"product": "Acme", "name": "there"},
+	}
+
+	subject, body, err := renderTemplate(tmpl, map[string]string{"name": "Ana", "code": "1234"})
+	if err != nil {
+		t.Fatalf("renderTemplate() error = %v", err)
+	}
+	if subject != "Acme password reset" || body != "Hi Ana, use code 1234" {
+		t.Errorf("renderTemplate() = %q, %q", subject, body)
+	}
+
+	// Variables without a value or default fail the render
+	if _, _, err := renderTemplate(tmpl, nil); !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("renderTemplate() without code error = %v, want ErrTemplateRender", err)
+	}
+}
+
+func TestCreateNotificationUsesPreferredLocale(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+
+	expectPreferences(mock, "user-1", UserPreference{ID: "p1", Channel: "sms", Enabled: true, Locale: "fr"})
+	expectTemplate(mock, "welcome", "sms", []string{"fr", "en"},
+		NotificationTemplate{ID: "t2", Name: "welcome", Channel: "sms", Locale: "fr", BodyTemplate: "Bonjour {{.name}}"})
+	expectStore(mock, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending})
+
+	created, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Template: "welcome", Variables: map[string]string{"name": "Ana"},
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if created.Body != "Bonjour Ana" {
+		t.Errorf("Body = %q, want the French template", created.Body)
+	}
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	expectTemplate(mock, "reset", "email", []string{"fr", "en"}, NotificationTemplate{
+		ID: "t1", Name: "reset", Channel: "email", Locale: "fr",
+		SubjectTemplate: "Réinitialisation {{.product}}", BodyTemplate: "Bonjour {{.name}}, code {{.code}}",
+		Variables: map[string]string{"product": "Acme"},
+	})
+
+	preview, err := s.PreviewTemplate(context.Background(), "reset", "email", "fr", map[string]string{"name": "Ana", "code": "1234"})
+	if err != nil {
+		t.Fatalf("PreviewTemplate() error = %v", err)
+	}
+	if preview.Subject != "Réinitialisation Acme" || preview.Body != "Bonjour Ana, code 1234" || preview.Locale != "fr" {
+		t.Errorf("PreviewTemplate() = %+v", preview)
+	}
+	if len(preview.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", preview.Warnings)
+	}
+}
+
+func TestPreviewTemplateWarnsAboutMissingVariables(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	expectTemplate(mock, "reset", "sms", []string{"en"}, NotificationTemplate{
+		ID: "t1", Name: "reset", Channel: "sms", Locale: "en", BodyTemplate: "Hi {{.name}}, use {{.code}}{{if .expires}} before {{.expires}}{{end}}",
+	})
+
+	// Unlike sending, previewing renders unset variables empty
+	preview, err := s.PreviewTemplate(context.Background(), "reset", "sms", "", map[string]string{"name": "Ana"})
+	if err != nil {
+		t.Fatalf("PreviewTemplate() error = %v", err)
+	}
+	if preview.Body != "Hi Ana, use " {
+		t.Errorf("Body = %q", preview.Body)
+	}
+	want := []string{`variable "code" is not set`, `variable "expires" is not set`}
+	if !reflect.DeepEqual(preview.Warnings, want) {
+		t.Errorf("Warnings = %v, want %v", preview.Warnings, want)
+	}
+}
+
+func TestPreviewTemplateRejectsMalformedTemplate(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	expectTemplate(mock, "broken", "sms", []string{"en"}, NotificationTemplate{ID: "t1", Name: "broken", Channel: "sms", Locale: "en", BodyTemplate: "Hi {{.name"})
+
+	if _, err := s.PreviewTemplate(context.Background(), "broken", "sms", "", nil); !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("PreviewTemplate() error = %v, want ErrTemplateRender", err)
+	}
+}
+
+// templateCacheConfig returns a config caching resolved templates for ttl
+func templateCacheConfig(ttl time.Duration) *config.Config {
+	return &config.Config{Redis: config.RedisConfig{TemplateTTL: ttl}}
+}
+
+// expectUpsertTemplate expects a template to be saved
+func expectUpsertTemplate(mock *dbtest.Mock, tmpl NotificationTemplate) {
+	mock.ExpectQuery("INSERT INTO notification_templates").
+		WithArgs(tmpl.Name, tmpl.Channel, tmpl.Locale, nullIfEmpty(tmpl.SubjectTemplate), tmpl.BodyTemplate, []byte(nil), "").
+		WillReturnRows(dbtest.NewRows("id", "created_at", "updated_at").AddRow(tmpl.ID, time.Now(), time.Now()))
+}
+
+func TestGetTemplateIsCached(t *testing.T) {
+	s, mock, redis := newTestServiceWithRedis(t, templateCacheConfig(time.Hour))
+	welcome := NotificationTemplate{ID: "t1", Name: "welcome", Channel: "email", Locale: "en", SubjectTemplate: "Welcome", BodyTemplate: "Hello {{.name}}"}
+	expectTemplate(mock, "welcome", "email", []string{"en"}, welcome)
+
+	// Only the first lookup reads the database
+	for i := 0; i < 3; i++ {
+		tmpl, err := s.getTemplate(context.Background(), "welcome", "email", "en")
+		if err != nil {
+			t.Fatalf("getTemplate() error = %v", err)
+		}
+		if tmpl.ID != "t1" || tmpl.BodyTemplate != welcome.BodyTemplate {
+			t.Errorf("getTemplate() = %+v, want %+v", tmpl, welcome)
+		}
+	}
+
+	if _, ok := redis.HGet("template:welcome:email", templateCacheField("", "en")); !ok {
+		t.Error("template not cached under its name and channel")
+	}
+	if ttl := redis.TTL("template:welcome:email"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("cache TTL = %v, want the configured hour", ttl)
+	}
+}
+
+func TestGetTemplateCacheDisabled(t *testing.T) {
+	s, mock, _ := newTestServiceWithRedis(t, templateCacheConfig(0))
+	welcome := NotificationTemplate{ID: "t1", Name: "welcome", Channel: "email", Locale: "en", BodyTemplate: "Hello"}
+	expectTemplate(mock, "welcome", "email", []string{"en"}, welcome)
+	expectTemplate(mock, "welcome", "email", []string{"en"}, welcome)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.getTemplate(context.Background(), "welcome", "email", "en"); err != nil {
+			t.Fatalf("getTemplate() error = %v", err)
+		}
+	}
+}
+
+func TestUpsertTemplateInvalidatesCache(t *testing.T) {
+	s, mock, redis := newTestServiceWithRedis(t, templateCacheConfig(time.Hour))
+	ctx := context.Background()
+	original := NotificationTemplate{ID: "t1", Name: "welcome", Channel: "email", Locale: "en", SubjectTemplate: "Welcome", BodyTemplate: "Hello {{.name}}"}
+	updated := original
+	updated.BodyTemplate = "Hi {{.name}}, welcome aboard"
+
+	// Render once so the original is cached, in two locales
+	expectTemplate(mock, "welcome", "email", []string{"en"}, original)
+	expectTemplate(mock, "welcome", "email", []string{"fr", "en"}, original)
+	for _, locale := range []string{"en", "fr"} {
+		if _, err := s.getTemplate(ctx, "welcome", "email", locale); err != nil {
+			t.Fatalf("getTemplate(%s) error = %v", locale, err)
+		}
+	}
+
+	expectUpsertTemplate(mock, updated)
+	saved := updated
+	if err := s.UpsertTemplate(ctx, &saved); err != nil {
+		t.Fatalf("UpsertTemplate() error = %v", err)
+	}
+	for _, locale := range []string{"en", "fr"} {
+		if _, ok := redis.HGet("template:welcome:email", templateCacheField("", locale)); ok {
+			t.Errorf("cached %s resolution survived the update", locale)
+		}
+	}
+
+	// The next render reads and uses the new content
+	expectTemplate(mock, "welcome", "email", []string{"en"}, updated)
+	tmpl, err := s.getTemplate(ctx, "welcome", "email", "en")
+	if err != nil {
+		t.Fatalf("getTemplate() error = %v", err)
+	}
+	_, body, err := renderTemplate(tmpl, map[string]string{"name": "Ana"})
+	if err != nil {
+		t.Fatalf("renderTemplate() error = %v", err)
+	}
+	if body != "Hi Ana, welcome aboard" {
+		t.Errorf("rendered body = %q, want the updated template", body)
+	}
+}
+
+func TestUpsertTemplate(t *testing.T) {
+	s, mock := newTestService(t, nil)
+
+	// The locale defaults to DefaultLocale
+	tmpl := NotificationTemplate{Name: "welcome", Channel: "sms", BodyTemplate: "Hello {{.name}}"}
+	expectUpsertTemplate(mock, NotificationTemplate{ID: "t1", Name: "welcome", Channel: "sms", Locale: DefaultLocale, BodyTemplate: tmpl.BodyTemplate})
+	if err := s.UpsertTemplate(context.Background(), &tmpl); err != nil {
+		t.Fatalf("UpsertTemplate() error = %v", err)
+	}
+	if tmpl.ID != "t1" || tmpl.Locale != DefaultLocale {
+		t.Errorf("saved template = %+v, want ID t1 in %s", tmpl, DefaultLocale)
+	}
+
+	// Templates that do not parse are not saved
+	for _, malformed := range []NotificationTemplate{
+		{Name: "broken", Channel: "sms", BodyTemplate: "Hello {{.name"},
+		{Name: "broken", Channel: "email", SubjectTemplate: "{{if}}", BodyTemplate: "Hello"},
+	} {
+		if err := s.UpsertTemplate(context.Background(), &malformed); !errors.Is(err, ErrTemplateRender) {
+			t.Errorf("UpsertTemplate(%+v) error = %v, want ErrTemplateRender", malformed, err)
+		}
+	}
+}
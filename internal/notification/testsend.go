@@ -0,0 +1,92 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidTestSend is returned for test sends that cannot be sent directly
+var ErrInvalidTestSend = errors.New("invalid test send")
+
+// testSendDetail is recorded in the status history of persisted test sends
+const testSendDetail = "test send"
+
+// PrepareTestNotification validates req and builds its notification exactly
+// as CreateNotification would, without storing or queueing it, so the caller
+// can send it through a channel directly
+func (s *Service) PrepareTestNotification(ctx context.Context, req NotificationRequest) (*Notification, error) {
+	if len(req.Recipients) > 0 || req.Topic != "" {
+		return nil, fmt.Errorf("%w: test sends go to a single recipient", ErrInvalidTestSend)
+	}
+	if req.ScheduledAt != nil || req.SendWindowStart != nil || req.SendWindowEnd != nil {
+		return nil, fmt.Errorf("%w: test sends cannot be scheduled", ErrInvalidTestSend)
+	}
+
+	pending, err := s.prepareNotification(ctx, req, "")
+	if err != nil {
+		return nil, err
+	}
+	return pending.notification, nil
+}
+
+// RecordTestSend stores a notification sent directly by a test send with the
+// outcome in report. It is never queued, so workers do not send it again.
+func (s *Service) RecordTestSend(ctx context.Context, notification *Notification, report *DeliveryReport) error {
+	return s.recordDirectSend(ctx, notification, report, testSendDetail)
+}
+
+// recordDirectSend stores a notification sent directly through its channel
+// instead of queued, with the outcome in report. detail describes the send in
+// the notification's status history.
+func (s *Service) recordDirectSend(ctx context.Context, notification *Notification, report *DeliveryReport, detail string) error {
+	now := time.Now()
+	notification.Status = report.Status
+	notification.ExternalID = report.ExternalID
+	notification.ErrorMessage = report.ErrorMessage
+	notification.UpdatedAt = now
+	if report.Status == StatusSent {
+		notification.SentAt = &now
+	}
+
+	attachments, err := marshalAttachments(notification.Attachments)
+	if err != nil {
+		return err
+	}
+	recipient, body, keyVersion, err := s.cipher.encryptFields(notification.ID, notification.Recipient, notification.Body)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt notification: %w", err)
+	}
+
+	query := `
+		INSERT INTO notifications (id, user_id, channel, recipient, subject, body, status, external_id, error_message, sent_at, attachments, collapse_key, tenant_id, category, created_at, updated_at, key_version, callback_url, priority, created_by, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	`
+	_, err = s.db.ExecContext(ctx, query,
+		notification.ID, notification.UserID, notification.Channel, recipient,
+		notification.Subject, body, notification.Status, nullIfEmpty(notification.ExternalID),
+		nullIfEmpty(notification.ErrorMessage), notification.SentAt, attachments, nullIfEmpty(notification.CollapseKey),
+		nullIfEmpty(notification.TenantID), notification.Category, notification.CreatedAt, notification.UpdatedAt,
+		nullIfZero(keyVersion), nullIfEmpty(notification.CallbackURL), notification.Priority,
+		nullIfEmpty(notification.CreatedBy), nullIfEmpty(notification.Source),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert notification: %w", err)
+	}
+
+	if notification.ErrorMessage != "" {
+		detail += ": " + notification.ErrorMessage
+	}
+	s.recordEvent(ctx, notification.ID, notification.Status, notification.ExternalID, detail)
+	s.cacheNotification(ctx, notification)
+	s.logger.Info("Recorded direct send",
+		zap.String("id", notification.ID),
+		zap.String("detail", detail),
+		zap.String("channel", notification.Channel),
+		zap.String("status", string(notification.Status)),
+	)
+	return nil
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestPrepareTestNotificationRejectsQueuedFeatures(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{Channels: enabledChannels()})
+	later := time.Now().Add(time.Hour)
+	base := NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"}
+
+	for name, modify := range map[string]func(*NotificationRequest){
+		"several recipients": func(r *NotificationRequest) { r.Recipients = []string{"+15551234567", "+15557654321"} },
+		"topic":              func(r *NotificationRequest) { r.Topic = "news" },
+		"scheduled":          func(r *NotificationRequest) { r.ScheduledAt = &later },
+		"send window":        func(r *NotificationRequest) { r.SendWindowEnd = &later },
+	} {
+		req := base
+		modify(&req)
+		if _, err := s.PrepareTestNotification(context.Background(), req); !errors.Is(err, ErrInvalidTestSend) {
+			t.Errorf("PrepareTestNotification(%s) error = %v, want ErrInvalidTestSend", name, err)
+		}
+	}
+}
+
+func TestPrepareTestNotification(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	expectPreferences(mock, "user-1")
+
+	// Prepared like a created notification, but nothing is stored
+	notif, err := s.PrepareTestNotification(context.Background(), NotificationRequest{UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi"})
+	if err != nil {
+		t.Fatalf("PrepareTestNotification() error = %v", err)
+	}
+	if notif.ID == "" || notif.Status != StatusPending || notif.Category != CategoryTransactional || notif.Recipient != "+15551234567" {
+		t.Errorf("PrepareTestNotification() = %+v, want a pending transactional notification", notif)
+	}
+}
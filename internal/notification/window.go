@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSendWindow is returned when a notification's send window is empty,
+// has already passed or does not contain its scheduled time
+var ErrInvalidSendWindow = errors.New("invalid send window")
+
+// Reasons a notification is held on creation instead of queued right away
+const (
+	DeferredScheduled  = "scheduled"   // scheduled_at is in the future
+	DeferredSendWindow = "send_window" // its send window has not opened yet
+)
+
+// detailWindowMissed is recorded when a notification is expired because its
+// send window passed before it could be sent
+const detailWindowMissed = "send window missed"
+
+// applySendWindow validates the send window of req and holds the notification
+// until the window opens by moving its scheduled time to the window start. A
+// window given in a local offset, e.g. 09:00-05:00 to 17:00-05:00, keeps
+// sends within local business hours. It reports whether the scheduled time
+// was moved.
+func applySendWindow(req *NotificationRequest, now time.Time) (bool, error) {
+	start, end := req.SendWindowStart, req.SendWindowEnd
+	if start == nil && end == nil {
+		return false, nil
+	}
+
+	switch {
+	case start != nil && end != nil && !end.After(*start):
+		return false, fmt.Errorf("%w: send_window_end must be after send_window_start", ErrInvalidSendWindow)
+	case end != nil && !end.After(now):
+		return false, fmt.Errorf("%w: send_window_end has already passed", ErrInvalidSendWindow)
+	case end != nil && req.ScheduledAt != nil && !req.ScheduledAt.Before(*end):
+		return false, fmt.Errorf("%w: scheduled_at is after the send window", ErrInvalidSendWindow)
+	}
+
+	if start != nil && start.After(now) && (req.ScheduledAt == nil || req.ScheduledAt.Before(*start)) {
+		scheduledAt := *start
+		req.ScheduledAt = &scheduledAt
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkSendWindow reports why a notification cannot be sent at t, or "" when t
+// is inside its send window
+func (n *Notification) checkSendWindow(t time.Time) string {
+	switch {
+	case n.SendWindowStart != nil && t.Before(*n.SendWindowStart):
+		return "it is before the send window"
+	case n.SendWindowEnd != nil && !t.Before(*n.SendWindowEnd):
+		return "it is after the send window"
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+)
+
+func TestApplySendWindow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name          string
+		req           NotificationRequest
+		wantHeld      bool
+		wantScheduled *time.Time
+		wantErr       bool
+	}{
+		{name: "no window"},
+		{name: "inside the window", req: NotificationRequest{SendWindowStart: at(-time.Hour), SendWindowEnd: at(time.Hour)}},
+		{name: "only an end", req: NotificationRequest{SendWindowEnd: at(time.Hour)}},
+		{
+			name:          "held until the window opens",
+			req:           NotificationRequest{SendWindowStart: at(2 * time.Hour), SendWindowEnd: at(4 * time.Hour)},
+			wantHeld:      true,
+			wantScheduled: at(2 * time.Hour),
+		},
+		{
+			name:          "scheduled before the window",
+			req:           NotificationRequest{ScheduledAt: at(time.Hour), SendWindowStart: at(2 * time.Hour)},
+			wantHeld:      true,
+			wantScheduled: at(2 * time.Hour),
+		},
+		{
+			name:          "scheduled inside the window",
+			req:           NotificationRequest{ScheduledAt: at(3 * time.Hour), SendWindowStart: at(2 * time.Hour), SendWindowEnd: at(4 * time.Hour)},
+			wantScheduled: at(3 * time.Hour),
+		},
+		{name: "empty window", req: NotificationRequest{SendWindowStart: at(2 * time.Hour), SendWindowEnd: at(2 * time.Hour)}, wantErr: true},
+		{name: "window passed", req: NotificationRequest{SendWindowStart: at(-2 * time.Hour), SendWindowEnd: at(-time.Hour)}, wantErr: true},
+		{name: "scheduled after the window", req: NotificationRequest{ScheduledAt: at(5 * time.Hour), SendWindowEnd: at(4 * time.Hour)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			held, err := applySendWindow(&req, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSendWindow) {
+					t.Errorf("applySendWindow() error = %v, want ErrInvalidSendWindow", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applySendWindow() error = %v", err)
+			}
+			if held != tt.wantHeld {
+				t.Errorf("applySendWindow() held = %v, want %v", held, tt.wantHeld)
+			}
+			if (req.ScheduledAt == nil) != (tt.wantScheduled == nil) || (req.ScheduledAt != nil && !req.ScheduledAt.Equal(*tt.wantScheduled)) {
+				t.Errorf("ScheduledAt = %v, want %v", req.ScheduledAt, tt.wantScheduled)
+			}
+		})
+	}
+}
+
+func TestCheckSendWindow(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	n := Notification{SendWindowStart: &start, SendWindowEnd: &end}
+
+	if reason := n.checkSendWindow(start.Add(time.Hour)); reason != "" {
+		t.Errorf("checkSendWindow() inside the window = %q", reason)
+	}
+	if reason := n.checkSendWindow(start.Add(-time.Minute)); reason == "" {
+		t.Error("checkSendWindow() before the window allowed the send")
+	}
+	if reason := n.checkSendWindow(end); reason == "" {
+		t.Error("checkSendWindow() at the window end allowed the send")
+	}
+
+	// Workers cancel notifications whose window closed as expired
+	if n.IsExpired(start.Add(time.Hour)) || !n.IsExpired(end) {
+		t.Errorf("IsExpired() does not follow the window end")
+	}
+}
+
+func TestCreateNotificationHeldUntilSendWindow(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	start := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	end := start.Add(8 * time.Hour)
+
+	// The notification is stored scheduled at the window start, without an
+	// outbox entry
+	args := insertArgs("+15551234567", nil)
+	args[7] = start
+	args[19], args[20] = start, end
+	row := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", Status: StatusPending,
+		ScheduledAt: &start, SendWindowStart: &start, SendWindowEnd: &end}
+	expectPreferences(mock, "user-1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).WillReturnRows(notificationRows(row))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+
+	notif, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", SendWindowStart: &start, SendWindowEnd: &end,
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if notif.DeferredReason != DeferredSendWindow {
+		t.Errorf("DeferredReason = %q, want %q", notif.DeferredReason, DeferredSendWindow)
+	}
+}
+
+func TestCreateNotificationInsideSendWindow(t *testing.T) {
+	s, mock := newTestService(t, &config.Config{Channels: enabledChannels()})
+	withProducer(s)
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	// Sent right away: stored unscheduled and handed to the outbox
+	args := insertArgs("+15551234567", nil)
+	args[7] = nil
+	expectPreferences(mock, "user-1")
+	expectStoreWithArgs(mock, args, Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusPending, SendWindowStart: &start, SendWindowEnd: &end})
+
+	notif, err := s.CreateNotification(context.Background(), NotificationRequest{
+		UserID: "user-1", Channel: "sms", Recipient: "+15551234567", Body: "hi", SendWindowStart: &start, SendWindowEnd: &end,
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if notif.DeferredReason != "" {
+		t.Errorf("DeferredReason = %q, want none", notif.DeferredReason)
+	}
+}
+
+// expectStoreWithArgs is expectStore with the notification inserted with args
+func expectStoreWithArgs(mock *dbtest.Mock, args []any, row Notification) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notifications").WithArgs(args...).WillReturnRows(notificationRows(row))
+	mock.ExpectQuery("INSERT INTO outbox").WillReturnRows(dbtest.NewRows("id").AddRow(1))
+	mock.ExpectCommit()
+	expectEvent(mock, dbtest.AnyArg(), StatusPending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE id = ANY($1)").WillReturnRows(dbtest.NewRows("id", "notification_id", "attempts", "payload", "key_version"))
+	mock.ExpectCommit()
+}
+
+func TestDispatchScheduledExpiresMissedWindow(t *testing.T) {
+	s, mock := newTestService(t, nil)
+	end := time.Now().Add(-time.Minute)
+	missed := Notification{ID: "n1", UserID: "user-1", Channel: "sms", Status: StatusCancelled, ErrorMessage: detailWindowMissed, SendWindowEnd: &end}
+
+	// The notification is cancelled with the reason recorded, and not dispatched
+	mock.ExpectQuery("UPDATE notifications SET status = $1").WithArgs(StatusCancelled, StatusPending, detailWindowMissed).WillReturnRows(notificationRows(missed))
+	mock.ExpectExec("INSERT INTO notification_events").WithArgs("n1", string(StatusCancelled), nil, detailWindowMissed).WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO status_callbacks").WithArgs("n1", dbtest.AnyArg()).WillReturnResult(0)
+	mock.ExpectBegin()
+	mock.ExpectQuery("scheduled_at <= NOW()").WillReturnRows(notificationRows())
+	mock.ExpectRollback()
+
+	dispatched, expired, err := s.DispatchScheduled(context.Background())
+	if err != nil || dispatched != 0 {
+		t.Errorf("DispatchScheduled() = %d, %v, want 0", dispatched, err)
+	}
+	if !reflect.DeepEqual(expired, map[string]int{"sms": 1}) {
+		t.Errorf("DispatchScheduled() expired %v, want one sms", expired)
+	}
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// subscribedFormat is the message kafka-go's Reader logs with the partitions
+// assigned to it each time its consumer group rebalances
+const subscribedFormat = "subscribed to topics and partitions: %+v"
+
+// Assignment maps topics to the partitions a consumer owns, sorted
+type Assignment map[string][]int
+
+// Count returns the number of partitions in the assignment
+func (a Assignment) Count() int {
+	count := 0
+	for _, partitions := range a {
+		count += len(partitions)
+	}
+	return count
+}
+
+// assignmentTracker follows the partitions a consumer group member owns
+// across rebalances. kafka-go's Reader does not expose its assignment, so the
+// tracker reads it from the Reader's log of each new generation.
+type assignmentTracker struct {
+	mu       sync.Mutex
+	current  Assignment
+	onChange func(Assignment)
+	fields   []zap.Field
+	logger   *zap.Logger
+}
+
+// newAssignmentTracker creates a tracker that logs rebalances with fields
+func newAssignmentTracker(logger *zap.Logger, fields ...zap.Field) *assignmentTracker {
+	return &assignmentTracker{current: Assignment{}, fields: fields, logger: logger}
+}
+
+// printf implements kafka.LoggerFunc, picking the assignment out of the
+// Reader's log messages and dropping the rest
+func (t *assignmentTracker) printf(msg string, args ...interface{}) {
+	if msg != subscribedFormat || len(args) != 1 {
+		return
+	}
+	t.update(parseSubscribed(args[0]))
+}
+
+// parseSubscribed converts the Reader's map of topic partitions to offsets
+// into an Assignment
+func parseSubscribed(offsets interface{}) Assignment {
+	assignment := Assignment{}
+	value := reflect.ValueOf(offsets)
+	if value.Kind() != reflect.Map {
+		return assignment
+	}
+	for _, key := range value.MapKeys() {
+		if key.Kind() != reflect.Struct {
+			continue
+		}
+		topic, partition := key.FieldByName("topic"), key.FieldByName("partition")
+		if topic.Kind() != reflect.String || !partition.CanInt() {
+			continue
+		}
+		assignment[topic.String()] = append(assignment[topic.String()], int(partition.Int()))
+	}
+	for _, partitions := range assignment {
+		sort.Ints(partitions)
+	}
+	return assignment
+}
+
+// update records a new assignment, logging the partitions assigned and
+// revoked since the previous one
+func (t *assignmentTracker) update(assignment Assignment) {
+	t.mu.Lock()
+	assigned := diffAssignment(assignment, t.current)
+	revoked := diffAssignment(t.current, assignment)
+	t.current = assignment
+	onChange := t.onChange
+	t.mu.Unlock()
+
+	fields := append([]zap.Field{}, t.fields...)
+	t.logger.Info("Consumer group rebalanced", append(fields,
+		zap.Any("assigned", assigned),
+		zap.Any("revoked", revoked),
+		zap.Any("partitions", assignment),
+		zap.Int("partition_count", assignment.Count()),
+	)...)
+	if onChange != nil {
+		onChange(assignment)
+	}
+}
+
+// assignment returns a copy of the current assignment
+func (t *assignmentTracker) assignment() Assignment {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	assignment := make(Assignment, len(t.current))
+	for topic, partitions := range t.current {
+		assignment[topic] = append([]int(nil), partitions...)
+	}
+	return assignment
+}
+
+// setOnChange sets the function called with each new assignment, calling it
+// right away with the current one
+func (t *assignmentTracker) setOnChange(onChange func(Assignment)) {
+	t.mu.Lock()
+	t.onChange = onChange
+	t.mu.Unlock()
+	onChange(t.assignment())
+}
+
+// diffAssignment returns the partitions in a that are not in b
+func diffAssignment(a, b Assignment) Assignment {
+	diff := Assignment{}
+	for topic, partitions := range a {
+		owned := make(map[int]bool, len(b[topic]))
+		for _, partition := range b[topic] {
+			owned[partition] = true
+		}
+		for _, partition := range partitions {
+			if !owned[partition] {
+				diff[topic] = append(diff[topic], partition)
+			}
+		}
+	}
+	return diff
+}
+
+// kafkaLogger returns the kafka-go logger that feeds the tracker
+func (t *assignmentTracker) kafkaLogger() kafka.Logger {
+	return kafka.LoggerFunc(t.printf)
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// readerPartition mirrors the key of the offsets map kafka-go's Reader logs
+// when it joins a new generation
+type readerPartition struct {
+	topic     string
+	partition int32
+}
+
+// subscribed returns the offsets map the Reader logs for partitions of topic
+func subscribed(topic string, partitions ...int32) map[readerPartition]int64 {
+	offsets := make(map[readerPartition]int64, len(partitions))
+	for _, partition := range partitions {
+		offsets[readerPartition{topic: topic, partition: partition}] = -1
+	}
+	return offsets
+}
+
+// gaugeValue returns the value of the registered gauge name with labels, 0
+// when it was never set
+func gaugeValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetGauge().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestParseSubscribed(t *testing.T) {
+	offsets := subscribed("notifications.sms", 4, 0, 2)
+	offsets[readerPartition{topic: "notifications", partition: 1}] = 10
+
+	want := Assignment{"notifications.sms": {0, 2, 4}, "notifications": {1}}
+	if got := parseSubscribed(offsets); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSubscribed() = %v, want %v", got, want)
+	}
+	if got := parseSubscribed("not a map"); len(got) != 0 {
+		t.Errorf("parseSubscribed(string) = %v, want empty", got)
+	}
+	if got := parseSubscribed(map[string]int64{"notifications": 1}); len(got) != 0 {
+		t.Errorf("parseSubscribed(map[string]int64) = %v, want empty", got)
+	}
+}
+
+func TestAssignmentTrackerLogsRebalances(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	tracker := newAssignmentTracker(zap.New(core), zap.String("channel", "sms"))
+
+	// Other Reader messages are ignored
+	tracker.printf("committed offsets for group %s: %v", "sms-service", nil)
+	if logs.Len() != 0 {
+		t.Fatalf("logged %d entries for an unrelated message, want none", logs.Len())
+	}
+
+	tracker.printf(subscribedFormat, subscribed("notifications.sms", 0, 1, 2))
+	tracker.printf(subscribedFormat, subscribed("notifications.sms", 2, 3))
+
+	entries := logs.FilterMessage("Consumer group rebalanced").AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d rebalances, want 2", len(entries))
+	}
+	second := entries[1].ContextMap()
+	if second["channel"] != "sms" || second["partition_count"] != int64(2) {
+		t.Errorf("rebalance fields = %v, want channel sms and 2 partitions", second)
+	}
+	if assigned := second["assigned"]; !reflect.DeepEqual(assigned, Assignment{"notifications.sms": {3}}) {
+		t.Errorf("assigned = %v, want partition 3", assigned)
+	}
+	if revoked := second["revoked"]; !reflect.DeepEqual(revoked, Assignment{"notifications.sms": {0, 1}}) {
+		t.Errorf("revoked = %v, want partitions 0 and 1", revoked)
+	}
+
+	// The current assignment is a copy
+	current := tracker.assignment()
+	current["notifications.sms"][0] = 99
+	if got := tracker.assignment(); !reflect.DeepEqual(got, Assignment{"notifications.sms": {2, 3}}) {
+		t.Errorf("assignment() = %v, want partitions 2 and 3", got)
+	}
+}
+
+func TestConsumerOnRebalanceUpdatesMetric(t *testing.T) {
+	metrics := monitoring.NewMetrics()
+	consumer, _ := newTestConsumer("sms", "", newFakeReader())
+	labels := map[string]string{"channel": "sms", "consumer": "0"}
+
+	var seen []int
+	consumer.OnRebalance(func(assignment Assignment) {
+		seen = append(seen, assignment.Count())
+		metrics.SetConsumerPartitions("sms", "0", assignment.Count())
+	})
+
+	// Called right away with the empty assignment before joining the group
+	if got := gaugeValue(t, "consumer_assigned_partitions", labels); got != 0 || len(seen) != 1 {
+		t.Fatalf("gauge = %v after %d calls, want 0 after 1", got, len(seen))
+	}
+
+	consumer.assignment.printf(subscribedFormat, subscribed("notifications.sms", 0, 1, 2, 3))
+	if got := gaugeValue(t, "consumer_assigned_partitions", labels); got != 4 {
+		t.Errorf("gauge = %v, want 4 after joining", got)
+	}
+
+	// Another instance joins and takes half the partitions
+	consumer.assignment.printf(subscribedFormat, subscribed("notifications.sms", 1, 3))
+	if got := gaugeValue(t, "consumer_assigned_partitions", labels); got != 2 {
+		t.Errorf("gauge = %v, want 2 after the rebalance", got)
+	}
+	if want := (Assignment{"notifications.sms": {1, 3}}); !reflect.DeepEqual(consumer.Assignment(), want) {
+		t.Errorf("Assignment() = %v, want %v", consumer.Assignment(), want)
+	}
+	if want := []int{0, 4, 2}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("rebalances = %v, want %v", seen, want)
+	}
+}
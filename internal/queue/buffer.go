@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrBufferFull is returned when a message cannot be published and the local
+// buffer already holds its maximum number of messages
+var ErrBufferFull = errors.New("producer buffer is full")
+
+// spooledMessage is the on-disk form of a message waiting to be published
+type spooledMessage struct {
+	Topic   string         `json:"topic"`
+	Key     []byte         `json:"key"`
+	Value   []byte         `json:"value"`
+	Headers []kafka.Header `json:"headers"`
+	Time    time.Time      `json:"time"`
+}
+
+// diskBuffer persists messages that failed to publish, one file per message
+// in dir. File names start with the time they were buffered so flushing
+// replays them in order.
+type diskBuffer struct {
+	dir   string
+	max   int
+	mu    sync.Mutex
+	count int
+}
+
+// newDiskBuffer opens the buffer in dir, creating it if needed and counting
+// messages left over from a previous run
+func newDiskBuffer(dir string, max int) (*diskBuffer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create producer buffer directory: %w", err)
+	}
+
+	names, err := bufferedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &diskBuffer{dir: dir, max: max, count: len(names)}, nil
+}
+
+// add persists msg, failing with ErrBufferFull when the buffer is at capacity
+func (b *diskBuffer) add(msg kafka.Message, id string) error {
+	data, err := json.Marshal(spooledMessage{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: msg.Headers,
+		Time:    msg.Time,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal buffered message: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.max > 0 && b.count >= b.max {
+		return ErrBufferFull
+	}
+
+	// Write to a temporary name first so a crash never leaves a partial
+	// message for the flusher to trip over
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), id)
+	tmp := filepath.Join(b.dir, "."+name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write buffered message: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(b.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write buffered message: %w", err)
+	}
+
+	b.count++
+	return nil
+}
+
+// flush publishes buffered messages in order with write, removing each once
+// written. It stops at the first failure, leaving the rest for the next
+// flush, and returns the number published.
+func (b *diskBuffer) flush(ctx context.Context, write func(context.Context, kafka.Message) error) (int, error) {
+	names, err := bufferedFiles(b.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+
+		path := filepath.Join(b.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return published, fmt.Errorf("failed to read buffered message %s: %w", name, err)
+		}
+
+		var buffered spooledMessage
+		if err := json.Unmarshal(data, &buffered); err != nil {
+			return published, fmt.Errorf("failed to unmarshal buffered message %s: %w", name, err)
+		}
+
+		msg := kafka.Message{
+			Topic:   buffered.Topic,
+			Key:     buffered.Key,
+			Value:   buffered.Value,
+			Headers: buffered.Headers,
+			Time:    buffered.Time,
+		}
+		if err := write(ctx, msg); err != nil {
+			return published, err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return published, fmt.Errorf("failed to remove buffered message %s: %w", name, err)
+		}
+		b.mu.Lock()
+		b.count--
+		b.mu.Unlock()
+		published++
+	}
+	return published, nil
+}
+
+// len returns the number of buffered messages
+func (b *diskBuffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// bufferedFiles lists the buffered message files in dir, oldest first
+func bufferedFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read producer buffer directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// errKafkaDown is returned by the fake writer while Kafka is unavailable
+var errKafkaDown = errors.New("dial tcp: connection refused")
+
+// publishedIDs returns the notification IDs of written messages, in order
+func publishedIDs(t *testing.T, messages []kafka.Message) []string {
+	t.Helper()
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		var msg NotificationMessage
+		if err := json.Unmarshal(message.Value, &msg); err != nil {
+			t.Fatalf("failed to decode message: %v", err)
+		}
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestProducerBuffersWhileKafkaIsDown(t *testing.T) {
+	producer, writer := newTestProducer(config.KafkaConfig{BufferDir: t.TempDir(), BufferMaxMessages: 10})
+	writer.err = errKafkaDown
+
+	for _, id := range []string{"n1", "n2"} {
+		if err := producer.PublishNotification(context.Background(), NotificationMessage{ID: id, UserID: "user-1", Channel: "email"}); err != nil {
+			t.Fatalf("PublishNotification(%s) error = %v, want the message buffered", id, err)
+		}
+	}
+	if got := producer.Buffered(); got != 2 {
+		t.Fatalf("Buffered() = %d, want 2", got)
+	}
+
+	// Kafka is still down: nothing is published and nothing is lost
+	if published, err := producer.FlushBuffer(context.Background()); err == nil || published != 0 {
+		t.Errorf("FlushBuffer() = %d, %v, want 0 and an error", published, err)
+	}
+	if got := producer.Buffered(); got != 2 {
+		t.Errorf("Buffered() after failed flush = %d, want 2", got)
+	}
+
+	// Kafka recovers. A message published before the flush queues behind the
+	// buffered ones so it is not published ahead of them.
+	writer.mu.Lock()
+	writer.err = nil
+	writer.mu.Unlock()
+	if err := producer.PublishNotification(context.Background(), NotificationMessage{ID: "n3", UserID: "user-1", Channel: "email"}); err != nil {
+		t.Fatalf("PublishNotification(n3) error = %v", err)
+	}
+	if len(writer.written()) != 0 {
+		t.Fatal("published n3 ahead of buffered messages")
+	}
+
+	published, err := producer.FlushBuffer(context.Background())
+	if err != nil || published != 3 {
+		t.Fatalf("FlushBuffer() = %d, %v, want 3", published, err)
+	}
+	if got := producer.Buffered(); got != 0 {
+		t.Errorf("Buffered() after flush = %d, want 0", got)
+	}
+	written := writer.written()
+	ids := publishedIDs(t, written)
+	if len(ids) != 3 || ids[0] != "n1" || ids[1] != "n2" || ids[2] != "n3" {
+		t.Errorf("published %v, want [n1 n2 n3]", ids)
+	}
+	if written[0].Topic != "notifications.email" || string(written[0].Key) != "user-1" {
+		t.Errorf("flushed message topic %q key %q, want the original topic and key", written[0].Topic, written[0].Key)
+	}
+
+	// Kafka is up and the buffer is empty, so publishes go straight through
+	if err := producer.PublishNotification(context.Background(), NotificationMessage{ID: "n4", UserID: "user-1", Channel: "email"}); err != nil {
+		t.Fatalf("PublishNotification(n4) error = %v", err)
+	}
+	if got := len(writer.written()); got != 4 {
+		t.Errorf("wrote %d messages, want 4", got)
+	}
+}
+
+func TestProducerBufferFull(t *testing.T) {
+	producer, writer := newTestProducer(config.KafkaConfig{BufferDir: t.TempDir(), BufferMaxMessages: 1})
+	writer.err = errKafkaDown
+
+	if err := producer.PublishNotification(context.Background(), NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+		t.Fatalf("PublishNotification(n1) error = %v", err)
+	}
+	err := producer.PublishNotification(context.Background(), NotificationMessage{ID: "n2", Channel: "sms"})
+	if !errors.Is(err, ErrBufferFull) {
+		t.Errorf("PublishNotification(n2) error = %v, want ErrBufferFull", err)
+	}
+	if got := producer.Buffered(); got != 1 {
+		t.Errorf("Buffered() = %d, want 1", got)
+	}
+}
+
+func TestProducerDoesNotBufferUnknownTopic(t *testing.T) {
+	producer, writer := newTestProducer(config.KafkaConfig{BufferDir: t.TempDir()})
+	writer.err = kafka.UnknownTopicOrPartition
+
+	err := producer.PublishNotification(context.Background(), NotificationMessage{ID: "n1", Channel: "sms"})
+	if !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		t.Errorf("PublishNotification() error = %v, want UnknownTopicOrPartition", err)
+	}
+	if got := producer.Buffered(); got != 0 {
+		t.Errorf("Buffered() = %d, want 0", got)
+	}
+}
+
+func TestDiskBufferSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := newDiskBuffer(dir, 0)
+	if err != nil {
+		t.Fatalf("newDiskBuffer() error = %v", err)
+	}
+	for _, id := range []string{"n1", "n2"} {
+		if err := buffer.add(kafka.Message{Topic: "notifications.sms", Value: []byte(`{"id":"` + id + `"}`)}, id); err != nil {
+			t.Fatalf("add(%s) error = %v", id, err)
+		}
+	}
+	// A partial write left behind by a crash is ignored
+	if err := os.WriteFile(dir+"/.00000000000000000000-n0.json", []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := newDiskBuffer(dir, 0)
+	if err != nil {
+		t.Fatalf("newDiskBuffer() error = %v", err)
+	}
+	if got := reopened.len(); got != 2 {
+		t.Fatalf("len() after reopening = %d, want 2", got)
+	}
+
+	var flushed []kafka.Message
+	published, err := reopened.flush(context.Background(), func(ctx context.Context, msg kafka.Message) error {
+		flushed = append(flushed, msg)
+		return nil
+	})
+	if err != nil || published != 2 {
+		t.Fatalf("flush() = %d, %v, want 2", published, err)
+	}
+	if ids := publishedIDs(t, flushed); ids[0] != "n1" || ids[1] != "n2" {
+		t.Errorf("flushed %v, want [n1 n2]", ids)
+	}
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// topicPartition identifies the partition a message was fetched from
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// fetchedOffset is a fetched message and whether it has been settled
+type fetchedOffset struct {
+	msg     kafka.Message
+	settled bool
+}
+
+// commitTracker decides which fetched messages can be committed. A Kafka
+// commit covers every earlier offset of its partition, so a message is only
+// committed once every message fetched before it from the same partition has
+// been settled too: handled, dead-lettered or skipped. Messages that are never
+// settled, such as ones interrupted by shutdown, are redelivered.
+type commitTracker struct {
+	mu      sync.Mutex
+	fetched map[topicPartition][]fetchedOffset // unsettled messages and those after them, in fetch order
+}
+
+func newCommitTracker() *commitTracker {
+	return &commitTracker{fetched: make(map[topicPartition][]fetchedOffset)}
+}
+
+// add records a fetched message. A message at or before offsets already
+// tracked for its partition means the partition was fetched again from its
+// committed offset after a rebalance, so the earlier entries are dropped.
+func (t *commitTracker) add(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	offsets := t.fetched[key]
+	if n := len(offsets); n > 0 && msg.Offset <= offsets[n-1].msg.Offset {
+		offsets = nil
+	}
+	t.fetched[key] = append(offsets, fetchedOffset{msg: msg})
+}
+
+// settle marks msg as settled and returns the message to commit: the last of
+// the settled messages at the front of its partition, if any. Messages the
+// tracker does not know are ignored.
+func (t *commitTracker) settle(msg kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	offsets := t.fetched[key]
+	found := false
+	for i := range offsets {
+		if offsets[i].msg.Offset == msg.Offset {
+			offsets[i].settled = true
+			found = true
+			break
+		}
+	}
+	if !found {
+		return kafka.Message{}, false
+	}
+
+	settled := 0
+	for settled < len(offsets) && offsets[settled].settled {
+		settled++
+	}
+	if settled == 0 {
+		return kafka.Message{}, false
+	}
+
+	commit := offsets[settled-1].msg
+	if settled == len(offsets) {
+		delete(t.fetched, key)
+	} else {
+		t.fetched[key] = offsets[settled:]
+	}
+	return commit, true
+}
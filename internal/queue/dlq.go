@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// Headers added to messages written to the dead letter topic
+const (
+	headerDLQError         = "dlq_error"
+	headerDLQOriginalTopic = "dlq_original_topic"
+	headerDLQFailedAt      = "dlq_failed_at"
+)
+
+// DLQTopic returns the dead letter topic for the base topic, e.g. notifications.dlq
+func DLQTopic(baseTopic string) string {
+	return baseTopic + ".dlq"
+}
+
+// newDLQWriter creates the writer used to park messages that failed processing
+func newDLQWriter(cfg config.KafkaConfig, logger *zap.Logger) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  DLQTopic(cfg.Topic),
+		Balancer:               &kafka.LeastBytes{},
+		RequiredAcks:           requiredAcks(cfg.RequiredAcks, logger),
+		MaxAttempts:            cfg.MaxAttempts,
+		AllowAutoTopicCreation: false,
+	}
+}
+
+// sendToDLQ parks a message that failed processing on the dead letter topic,
+// recording the error and the topic it came from
+func (c *Consumer) sendToDLQ(ctx context.Context, msg kafka.Message, processErr error) {
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: headerDLQError, Value: []byte(processErr.Error())},
+		kafka.Header{Key: headerDLQOriginalTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: headerDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	dead := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	}
+	if err := c.dlq.WriteMessages(ctx, dead); err != nil {
+		c.logger.Error("Failed to write message to dead letter topic",
+			zap.String("topic", msg.Topic),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err),
+		)
+	}
+}
+
+// DeadLetter is a message read from the dead letter topic
+type DeadLetter struct {
+	Notification  NotificationMessage
+	Channel       string
+	Error         string
+	OriginalTopic string
+	message       kafka.Message
+}
+
+// DLQReplayer reads dead letters and republishes them to the channel topics
+type DLQReplayer struct {
+	reader    messageReader
+	writer    MessageWriter
+	baseTopic string
+}
+
+// NewDLQReplayer creates a replayer that reads the dead letter topic as groupID
+func NewDLQReplayer(cfg config.KafkaConfig, groupID string, logger *zap.Logger) *DLQReplayer {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Brokers,
+		Topic:       DLQTopic(cfg.Topic),
+		GroupID:     groupID,
+		MinBytes:    1,
+		MaxBytes:    10e6, // 10MB
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Balancer:               &kafka.LeastBytes{},
+		RequiredAcks:           requiredAcks(cfg.RequiredAcks, logger),
+		MaxAttempts:            cfg.MaxAttempts,
+		AllowAutoTopicCreation: true,
+	}
+
+	return &DLQReplayer{reader: reader, writer: writer, baseTopic: cfg.Topic}
+}
+
+// Fetch reads the next dead letter without committing it
+func (r *DLQReplayer) Fetch(ctx context.Context) (*DeadLetter, error) {
+	msg, err := r.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dead := &DeadLetter{message: msg}
+	if err := json.Unmarshal(msg.Value, &dead.Notification); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter at offset %d: %w", msg.Offset, err)
+	}
+
+	dead.Channel = dead.Notification.Channel
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case headerDLQError:
+			dead.Error = string(h.Value)
+		case headerDLQOriginalTopic:
+			dead.OriginalTopic = string(h.Value)
+		}
+	}
+
+	return dead, nil
+}
+
+// Republish writes a dead letter back to its channel topic without the
+// dead letter headers, so it is processed as a fresh message
+func (r *DLQReplayer) Republish(ctx context.Context, dead *DeadLetter) error {
+	var headers []kafka.Header
+	for _, h := range dead.message.Headers {
+		if !strings.HasPrefix(h.Key, "dlq_") {
+			headers = append(headers, h)
+		}
+	}
+
+	msg := kafka.Message{
+		Topic:   ChannelTopic(r.baseTopic, dead.Channel),
+		Key:     dead.message.Key,
+		Value:   dead.message.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	}
+	if err := r.writer.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("failed to republish notification %s: %w", dead.Notification.ID, err)
+	}
+	return nil
+}
+
+// Requeue writes dead letters back to the end of the dead letter topic unchanged
+func (r *DLQReplayer) Requeue(ctx context.Context, dead []*DeadLetter) error {
+	if len(dead) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(dead))
+	for _, d := range dead {
+		msgs = append(msgs, kafka.Message{
+			Topic:   DLQTopic(r.baseTopic),
+			Key:     d.message.Key,
+			Value:   d.message.Value,
+			Headers: d.message.Headers,
+			Time:    time.Now(),
+		})
+	}
+	if err := r.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to requeue dead letters: %w", err)
+	}
+	return nil
+}
+
+// Commit marks a dead letter as handled for the replayer's consumer group
+func (r *DLQReplayer) Commit(ctx context.Context, dead *DeadLetter) error {
+	return r.reader.CommitMessages(ctx, dead.message)
+}
+
+// Close closes the replayer
+func (r *DLQReplayer) Close() error {
+	if err := r.reader.Close(); err != nil {
+		return err
+	}
+	return r.writer.Close()
+}
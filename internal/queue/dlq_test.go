@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// deadLetterMessage returns msg as the consumer parks it after failing with
+// reason on topic
+func deadLetterMessage(t *testing.T, offset int64, topic, reason string, msg NotificationMessage) kafka.Message {
+	t.Helper()
+	dead := kafkaMessage(t, DLQTopic("notifications"), offset, msg)
+	dead.Headers = append(dead.Headers,
+		kafka.Header{Key: headerDLQError, Value: []byte(reason)},
+		kafka.Header{Key: headerDLQOriginalTopic, Value: []byte(topic)},
+		kafka.Header{Key: headerDLQFailedAt, Value: []byte("2024-05-01T12:00:00Z")},
+	)
+	return dead
+}
+
+// newTestReplayer returns a replayer reading reader and writing to the
+// returned writer
+func newTestReplayer(reader *fakeReader) (*DLQReplayer, *fakeWriter) {
+	writer := &fakeWriter{}
+	return &DLQReplayer{reader: reader, writer: writer, baseTopic: "notifications"}, writer
+}
+
+func TestSendToDLQRecordsFailure(t *testing.T) {
+	consumer, dlq := newTestConsumer("sms", "", newFakeReader())
+	msg := kafkaMessage(t, "notifications.sms", 4, NotificationMessage{ID: "s1", UserID: "user-1", Channel: "sms"})
+
+	consumer.sendToDLQ(context.Background(), msg, errors.New("twilio error: invalid number"))
+
+	written := dlq.written()
+	if len(written) != 1 {
+		t.Fatalf("dead-lettered %d messages, want 1", len(written))
+	}
+	dead := written[0]
+	if string(dead.Value) != string(msg.Value) || string(dead.Key) != string(msg.Key) {
+		t.Errorf("dead letter = %s, want the original message", dead.Value)
+	}
+	if got := headerValue(dead, headerDLQError); got != "twilio error: invalid number" {
+		t.Errorf("%s = %q", headerDLQError, got)
+	}
+	if got := headerValue(dead, headerDLQOriginalTopic); got != "notifications.sms" {
+		t.Errorf("%s = %q", headerDLQOriginalTopic, got)
+	}
+	if headerValue(dead, headerDLQFailedAt) == "" {
+		t.Errorf("%s is missing", headerDLQFailedAt)
+	}
+}
+
+func TestDLQReplayerRepublishes(t *testing.T) {
+	reader := newFakeReader(deadLetterMessage(t, 0, "notifications.sms", "twilio error: timeout", NotificationMessage{ID: "s1", UserID: "user-1", Channel: "sms"}))
+	replayer, writer := newTestReplayer(reader)
+	ctx := context.Background()
+
+	dead, err := replayer.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if dead.Notification.ID != "s1" || dead.Channel != "sms" || dead.Error != "twilio error: timeout" || dead.OriginalTopic != "notifications.sms" {
+		t.Errorf("Fetch() = %+v", dead)
+	}
+
+	if err := replayer.Republish(ctx, dead); err != nil {
+		t.Fatalf("Republish() error = %v", err)
+	}
+	if err := replayer.Commit(ctx, dead); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// The message goes back to its channel topic as a fresh message
+	written := writer.written()
+	if len(written) != 1 {
+		t.Fatalf("republished %d messages, want 1", len(written))
+	}
+	if written[0].Topic != "notifications.sms" {
+		t.Errorf("republished to %q, want notifications.sms", written[0].Topic)
+	}
+	for _, h := range written[0].Headers {
+		if h.Key == headerDLQError || h.Key == headerDLQOriginalTopic || h.Key == headerDLQFailedAt {
+			t.Errorf("republished message keeps the %s header", h.Key)
+		}
+	}
+	if got := headerValue(written[0], "channel"); got != "sms" {
+		t.Errorf("channel header = %q, want sms", got)
+	}
+	if committed := reader.committed(); len(committed) != 1 || committed[0].Offset != 0 {
+		t.Errorf("committed %d dead letters, want the replayed one", len(committed))
+	}
+}
+
+func TestDLQReplayerRequeuesUnchanged(t *testing.T) {
+	original := deadLetterMessage(t, 0, "notifications.email", "sendgrid error: bounce", NotificationMessage{ID: "e1", UserID: "user-1", Channel: "email"})
+	replayer, writer := newTestReplayer(newFakeReader(original))
+	ctx := context.Background()
+
+	dead, err := replayer.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := replayer.Requeue(ctx, nil); err != nil || len(writer.written()) != 0 {
+		t.Fatalf("Requeue(nil) error = %v, wrote %d messages", err, len(writer.written()))
+	}
+	if err := replayer.Requeue(ctx, []*DeadLetter{dead}); err != nil {
+		t.Fatalf("Requeue() error = %v", err)
+	}
+
+	written := writer.written()
+	if len(written) != 1 || written[0].Topic != DLQTopic("notifications") {
+		t.Fatalf("requeued %+v, want one message on the dead letter topic", written)
+	}
+	if got := headerValue(written[0], headerDLQError); got != "sendgrid error: bounce" {
+		t.Errorf("requeued %s = %q, want the original error kept", headerDLQError, got)
+	}
+}
+
+func TestDLQReplayerFetchRejectsMalformedMessages(t *testing.T) {
+	replayer, _ := newTestReplayer(newFakeReader(kafka.Message{Topic: DLQTopic("notifications"), Value: []byte("not json")}))
+	if _, err := replayer.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() of a malformed dead letter succeeded")
+	}
+}
@@ -3,12 +3,13 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/alexnthnz/notification-system/internal/config"
 	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
 )
 
 // NotificationMessage represents a message in the notification queue
@@ -17,50 +18,295 @@ type NotificationMessage struct {
 	UserID    string            `json:"user_id"`
 	Channel   string            `json:"channel"`
 	Recipient string            `json:"recipient"`
+	Topic     string            `json:"topic,omitempty"` // set for topic broadcasts, which have no user or recipient
 	Subject   string            `json:"subject,omitempty"`
 	Body      string            `json:"body"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
-	Priority  int               `json:"priority"` // 1 = high, 2 = medium, 3 = low
-	CreatedAt time.Time         `json:"created_at"`
+	// ProviderOptions are passed through to the provider, keyed by provider name
+	ProviderOptions map[string]map[string]string `json:"provider_options,omitempty"`
+	Priority        int                          `json:"priority"` // 1 = high, 2 = medium, 3 = low
+	CreatedAt       time.Time                    `json:"created_at"`
+
+	// ProducedAt is the Kafka timestamp of the consumed message; it is not
+	// part of the payload
+	ProducedAt time.Time `json:"-"`
+}
+
+// QueueWait returns how long the message waited between being produced and
+// picked up at now. Messages without a Kafka timestamp fall back to their
+// creation time.
+func (m NotificationMessage) QueueWait(now time.Time) time.Duration {
+	since := m.ProducedAt
+	if since.IsZero() {
+		since = m.CreatedAt
+	}
+	if since.IsZero() || since.After(now) {
+		return 0
+	}
+	return now.Sub(since)
+}
+
+// MessageWriter is the part of kafka.Writer used to publish messages, see
+// Producer.UseWriter
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// messageReader is the part of kafka.Reader used to consume messages
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
 }
 
+// Partition keys for published messages
+const (
+	// PartitionKeyUserID keeps each user's notifications on one partition, in order
+	PartitionKeyUserID = "user_id"
+	// PartitionKeyID spreads notifications evenly with no ordering between them
+	PartitionKeyID = "id"
+)
+
 // Producer handles publishing messages to Kafka
 type Producer struct {
-	writer *kafka.Writer
+	writer       MessageWriter
+	baseTopic    string
+	partitionKey string
+	tombstones   bool        // publish tombstones for cancelled notifications
+	buffer       *diskBuffer // nil unless BufferDir is set
+	logger       *zap.Logger
 }
 
 // Consumer handles consuming messages from Kafka
 type Consumer struct {
-	reader *kafka.Reader
+	reader         messageReader
+	dlq            MessageWriter
+	channel        string
+	waitBeforeRead func(context.Context) error
+	commits        *commitTracker
+	priorityBuffer int
+	assignment     *assignmentTracker
+	registered     map[string]bool // nil unless ValidateChannels was called
+	legacyTopic    string          // shared topic drained during migration, empty when not drained
+	ownsLegacy     bool            // dead-letters unknown channels from the legacy topic
+	onUnknown      func(channel string)
+	logger         *zap.Logger
 }
 
-// NewProducer creates a new Kafka producer
-func NewProducer(cfg config.KafkaConfig) *Producer {
+// Offsets of settled messages are committed in the background at this
+// interval and when the consumer is closed, so a crash can redeliver up to this
+// much of already handled messages
+const commitInterval = time.Second
+
+// commitTimeout bounds committing an offset, which is done even while the
+// consumer is shutting down
+const commitTimeout = 5 * time.Second
+
+// ErrUnknownChannel is the reason messages for a channel that is not
+// registered are dead-lettered, see ValidateChannels
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// ChannelTopic returns the per-channel topic for channel, e.g. notifications.email
+func ChannelTopic(baseTopic, channel string) string {
+	return baseTopic + "." + channel
+}
+
+// NewProducer creates a new Kafka producer. Messages are routed to the
+// per-channel topic of their channel, so the writer has no fixed topic.
+//
+// Messages are hashed to a partition by their key, the user ID by default, so
+// one user's notifications are consumed in the order they were published.
+//
+// Topics are not created on demand: EnsureTopics checks them at startup, and a
+// publish to a topic that has since gone missing fails with the topic's name.
+//
+// When cfg.BufferDir is set, messages that cannot be written are persisted
+// there instead and published later by FlushBuffer, see PublishNotification.
+//
+// Writes wait for cfg.RequiredAcks (all in-sync replicas by default). kafka-go
+// does not implement idempotent production, so a retried write can still
+// duplicate a message; consumers can recognise duplicates by notification ID.
+//
+// Messages are written in batches of up to cfg.ProducerBatchSize per
+// partition, waiting at most cfg.ProducerBatchTimeout for a batch to fill.
+// Writes are synchronous by default, so a publish only succeeds once Kafka has
+// acknowledged it and concurrent publishes share batches. With
+// cfg.ProducerAsync, publishes return as soon as the message is queued in
+// memory; failed writes are then buffered on disk when buffering is enabled and
+// logged otherwise, and the reconciler cannot see them.
+//
+// With cfg.TombstoneOnCancel and messages keyed by notification ID,
+// PublishTombstone marks cancelled notifications for compacted topics.
+func NewProducer(cfg config.KafkaConfig, logger *zap.Logger) *Producer {
+	partitionKey := cfg.PartitionKey
+	if partitionKey != PartitionKeyUserID && partitionKey != PartitionKeyID {
+		logger.Warn("Invalid Kafka partition key, using user_id", zap.String("value", partitionKey))
+		partitionKey = PartitionKeyUserID
+	}
+
+	// A tombstone keyed by user ID would compact away all of the user's
+	// notifications, not just the cancelled one
+	tombstones := cfg.TombstoneOnCancel
+	if tombstones && partitionKey != PartitionKeyID {
+		logger.Warn("Kafka tombstones require the id partition key, not publishing them", zap.String("partition_key", partitionKey))
+		tombstones = false
+	}
+
+	batchSize := cfg.ProducerBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	batchTimeout := cfg.ProducerBatchTimeout
+	if batchTimeout <= 0 {
+		batchTimeout = 10 * time.Millisecond
+	}
+
 	writer := &kafka.Writer{
-		Addr:     kafka.TCP(cfg.Brokers...),
-		Topic:    cfg.Topic,
-		Balancer: &kafka.LeastBytes{},
-		BatchTimeout: 10 * time.Millisecond,
-		BatchSize:    100,
-		Async:        false, // Synchronous for reliability
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Balancer:               &kafka.Hash{},
+		BatchTimeout:           batchTimeout,
+		BatchSize:              batchSize,
+		Async:                  cfg.ProducerAsync, // Synchronous by default for reliability
+		AllowAutoTopicCreation: false,
+		RequiredAcks:           requiredAcks(cfg.RequiredAcks, logger),
+		MaxAttempts:            cfg.MaxAttempts,
 	}
 
-	return &Producer{writer: writer}
+	producer := &Producer{writer: writer, baseTopic: cfg.Topic, partitionKey: partitionKey, tombstones: tombstones, logger: logger}
+	if cfg.ProducerAsync {
+		writer.Completion = producer.completeAsync
+	}
+	if cfg.BufferDir != "" {
+		buffer, err := newDiskBuffer(cfg.BufferDir, cfg.BufferMaxMessages)
+		if err != nil {
+			logger.Error("Failed to open producer buffer, publishing without it", zap.String("dir", cfg.BufferDir), zap.Error(err))
+		} else {
+			producer.buffer = buffer
+			logger.Info("Producer buffer enabled", zap.String("dir", cfg.BufferDir), zap.Int("buffered", buffer.len()))
+		}
+	}
+	return producer
+}
+
+// messageKey returns the partition key for msg
+func (p *Producer) messageKey(msg NotificationMessage) []byte {
+	if p.partitionKey == PartitionKeyID || msg.UserID == "" {
+		return []byte(msg.ID)
+	}
+	return []byte(msg.UserID)
+}
+
+// UseWriter makes the producer publish through writer instead of its Kafka
+// connection, e.g. the recorder of package queuetest. It must be called before
+// the producer is used.
+func (p *Producer) UseWriter(writer MessageWriter) {
+	p.writer = writer
+}
+
+// requiredAcks parses the configured acks level, defaulting to all replicas
+func requiredAcks(value string, logger *zap.Logger) kafka.RequiredAcks {
+	if value == "" {
+		return kafka.RequireAll
+	}
+
+	var acks kafka.RequiredAcks
+	if err := acks.UnmarshalText([]byte(value)); err != nil {
+		logger.Warn("Invalid Kafka required acks, using all", zap.String("value", value), zap.Error(err))
+		return kafka.RequireAll
+	}
+	return acks
 }
 
-// NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg config.KafkaConfig, groupID string) *Consumer {
+// Start offsets of consumer groups without committed offsets
+const (
+	// StartOffsetEarliest reads the backlog already on the topics
+	StartOffsetEarliest = "earliest"
+	// StartOffsetLatest only reads messages published from now on
+	StartOffsetLatest = "latest"
+)
+
+// startOffset parses the configured start offset, defaulting to latest
+func startOffset(value string, logger *zap.Logger) int64 {
+	switch value {
+	case StartOffsetEarliest:
+		return kafka.FirstOffset
+	case StartOffsetLatest, "":
+		return kafka.LastOffset
+	default:
+		logger.Warn("Invalid Kafka start offset, using latest", zap.String("value", value))
+		return kafka.LastOffset
+	}
+}
+
+// NewConsumer creates a new Kafka consumer that only receives messages for channel.
+// It subscribes to the channel's own topic and, while DrainLegacyTopic is set,
+// also to the shared legacy topic, skipping messages for other channels there
+// based on the channel header.
+//
+// Offsets are committed once messages are settled, not when they are read, so
+// messages still being handled when the consumer stops are redelivered, see
+// commitTracker. A consumer group without committed offsets starts at
+// cfg.StartOffset; once it has committed, it resumes where it left off.
+func NewConsumer(cfg config.KafkaConfig, groupID, channel string, logger *zap.Logger) *Consumer {
+	topics := []string{ChannelTopic(cfg.Topic, channel)}
+	var legacyTopic string
+	if cfg.DrainLegacyTopic {
+		legacyTopic = cfg.Topic
+		topics = append(topics, legacyTopic)
+	}
+
+	assignment := newAssignmentTracker(logger, zap.String("group_id", groupID), zap.String("channel", channel))
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:     cfg.Brokers,
-		Topic:       cfg.Topic,
-		GroupID:     groupID,
-		MinBytes:    10e3, // 10KB
-		MaxBytes:    10e6, // 10MB
-		MaxWait:     1 * time.Second,
-		StartOffset: kafka.LastOffset,
+		Brokers:        cfg.Brokers,
+		GroupTopics:    topics,
+		GroupID:        groupID,
+		MinBytes:       10e3, // 10KB
+		MaxBytes:       10e6, // 10MB
+		MaxWait:        1 * time.Second,
+		StartOffset:    startOffset(cfg.StartOffset, logger),
+		CommitInterval: commitInterval,
+		Logger:         assignment.kafkaLogger(),
 	})
 
-	return &Consumer{reader: reader}
+	return &Consumer{
+		reader:         reader,
+		dlq:            newDLQWriter(cfg, logger),
+		channel:        channel,
+		commits:        newCommitTracker(),
+		legacyTopic:    legacyTopic,
+		priorityBuffer: cfg.PriorityBuffer,
+		assignment:     assignment,
+		logger:         logger,
+	}
+}
+
+// Assignment returns the partitions currently assigned to the consumer by its
+// consumer group, empty until it has joined the group
+func (c *Consumer) Assignment() Assignment {
+	return c.assignment.assignment()
+}
+
+// OnRebalance registers a function called with the consumer's assignment each
+// time its consumer group rebalances, and right away with the current one.
+// Rebalances are logged whether or not a function is registered.
+func (c *Consumer) OnRebalance(onChange func(Assignment)) {
+	c.assignment.setOnChange(onChange)
+}
+
+// ValidateChannels makes the consumer dead-letter messages whose channel, in
+// their header or payload, is not one of registered, calling onUnknown with
+// the channel of each. Every channel's consumer group reads the shared legacy
+// topic, so there only the group of the first registered channel dead-letters
+// them and the others skip them, and each message is dead-lettered once.
+// Without it, messages for other channels are skipped whatever their channel.
+func (c *Consumer) ValidateChannels(registered []string, onUnknown func(channel string)) {
+	c.registered = make(map[string]bool, len(registered))
+	for _, channel := range registered {
+		c.registered[channel] = true
+	}
+	c.ownsLegacy = len(registered) > 0 && registered[0] == c.channel
+	c.onUnknown = onUnknown
 }
 
 // PublishNotification publishes a notification message to Kafka
@@ -73,7 +319,8 @@ func (p *Producer) PublishNotification(ctx context.Context, msg NotificationMess
 
 	// Create Kafka message
 	kafkaMsg := kafka.Message{
-		Key:   []byte(msg.ID),
+		Topic: ChannelTopic(p.baseTopic, msg.Channel),
+		Key:   p.messageKey(msg),
 		Value: data,
 		Headers: []kafka.Header{
 			{Key: "channel", Value: []byte(msg.Channel)},
@@ -82,48 +329,461 @@ func (p *Producer) PublishNotification(ctx context.Context, msg NotificationMess
 		Time: time.Now(),
 	}
 
+	if err := p.publish(ctx, kafkaMsg, msg); err != nil {
+		return err
+	}
+
+	p.logger.Debug("Published notification",
+		zap.String("id", msg.ID),
+		zap.String("user_id", msg.UserID),
+		zap.String("channel", msg.Channel),
+		zap.String("topic", kafkaMsg.Topic),
+	)
+	return nil
+}
+
+// PublishTombstone publishes a message with the notification's ID as key and
+// no value to its channel topic, so a compacted topic drops the notification
+// once it was cancelled. It is a no-op unless tombstones are enabled, which
+// requires messages keyed by notification ID; consumers skip tombstones.
+func (p *Producer) PublishTombstone(ctx context.Context, channel, id string) error {
+	if !p.tombstones {
+		return nil
+	}
+
+	kafkaMsg := kafka.Message{
+		Topic: ChannelTopic(p.baseTopic, channel),
+		Key:   []byte(id),
+		Headers: []kafka.Header{
+			{Key: "channel", Value: []byte(channel)},
+		},
+		Time: time.Now(),
+	}
+	if err := p.publish(ctx, kafkaMsg, NotificationMessage{ID: id, Channel: channel}); err != nil {
+		return err
+	}
+
+	p.logger.Debug("Published tombstone",
+		zap.String("id", id),
+		zap.String("channel", channel),
+		zap.String("topic", kafkaMsg.Topic),
+	)
+	return nil
+}
+
+// publish writes kafkaMsg, buffering it on disk when enabled and Kafka is
+// unavailable. msg identifies the message in logs and buffer file names.
+func (p *Producer) publish(ctx context.Context, kafkaMsg kafka.Message, msg NotificationMessage) error {
+	// While earlier messages are still buffered Kafka is treated as down:
+	// new messages join the buffer so they are not published ahead of them
+	if p.buffer != nil && p.buffer.len() > 0 {
+		return p.bufferMessage(kafkaMsg, msg, nil)
+	}
+
 	// Write message to Kafka
 	if err := p.writer.WriteMessages(ctx, kafkaMsg); err != nil {
+		if errors.Is(err, kafka.UnknownTopicOrPartition) {
+			return fmt.Errorf("failed to write message to Kafka: topic %s does not exist: %w", kafkaMsg.Topic, err)
+		}
+		if p.buffer != nil {
+			return p.bufferMessage(kafkaMsg, msg, err)
+		}
 		return fmt.Errorf("failed to write message to Kafka: %w", err)
 	}
+	return nil
+}
+
+// completeAsync handles the outcome of an asynchronous batch write. Failed
+// messages are buffered on disk when buffering is enabled; otherwise they are
+// lost and only logged.
+func (p *Producer) completeAsync(messages []kafka.Message, err error) {
+	if err == nil {
+		return
+	}
+
+	for _, kafkaMsg := range messages {
+		var msg NotificationMessage
+		if kafkaMsg.Value == nil {
+			msg = NotificationMessage{ID: string(kafkaMsg.Key), Channel: headerValue(kafkaMsg, "channel")}
+		} else if jsonErr := json.Unmarshal(kafkaMsg.Value, &msg); jsonErr != nil {
+			p.logger.Error("Failed to decode unpublished message", zap.String("topic", kafkaMsg.Topic), zap.Error(jsonErr))
+			continue
+		}
+
+		if p.buffer != nil && !errors.Is(err, kafka.UnknownTopicOrPartition) {
+			if bufferErr := p.bufferMessage(kafkaMsg, msg, err); bufferErr == nil {
+				continue
+			}
+		}
+		p.logger.Error("Failed to publish notification asynchronously",
+			zap.String("id", msg.ID),
+			zap.String("channel", msg.Channel),
+			zap.String("topic", kafkaMsg.Topic),
+			zap.Error(err),
+		)
+	}
+}
 
-	log.Printf("Published notification %s to Kafka topic", msg.ID)
+// bufferMessage persists a message that could not be written to Kafka. The
+// publish counts as successful once the message is on disk.
+func (p *Producer) bufferMessage(kafkaMsg kafka.Message, msg NotificationMessage, writeErr error) error {
+	if err := p.buffer.add(kafkaMsg, msg.ID); err != nil {
+		if writeErr != nil {
+			return fmt.Errorf("failed to write message to Kafka: %w (buffering failed: %v)", writeErr, err)
+		}
+		return fmt.Errorf("failed to buffer message: %w", err)
+	}
+
+	p.logger.Warn("Buffered notification until Kafka is available",
+		zap.String("id", msg.ID),
+		zap.String("channel", msg.Channel),
+		zap.Int("buffered", p.buffer.len()),
+		zap.NamedError("write_error", writeErr),
+	)
 	return nil
 }
 
-// ConsumeNotifications consumes notification messages from Kafka
+// FlushBuffer publishes buffered messages in the order they were buffered,
+// stopping at the first failure. It returns the number published; it is a
+// no-op when buffering is disabled.
+func (p *Producer) FlushBuffer(ctx context.Context) (int, error) {
+	if p.buffer == nil {
+		return 0, nil
+	}
+
+	published, err := p.buffer.flush(ctx, func(ctx context.Context, msg kafka.Message) error {
+		if err := p.writer.WriteMessages(ctx, msg); err != nil {
+			return fmt.Errorf("failed to write buffered message to Kafka: %w", err)
+		}
+		return nil
+	})
+	if published > 0 {
+		p.logger.Info("Published buffered notifications", zap.Int("count", published), zap.Int("remaining", p.buffer.len()))
+	}
+	return published, err
+}
+
+// Buffered returns the number of messages waiting in the buffer
+func (p *Producer) Buffered() int {
+	if p.buffer == nil {
+		return 0
+	}
+	return p.buffer.len()
+}
+
+// ConsumeNotifications consumes notification messages from Kafka. When a
+// priority buffer is configured, messages are handled by priority rather than
+// in topic order, see consumeByPriority.
 func (c *Consumer) ConsumeNotifications(ctx context.Context, handler func(NotificationMessage) error) error {
+	if c.priorityBuffer > 0 {
+		return c.consumeByPriority(ctx, handler)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			// Read message from Kafka
-			msg, err := c.reader.ReadMessage(ctx)
+			// Fetch message from Kafka
+			msg, err := c.fetch(ctx)
 			if err != nil {
-				log.Printf("Error reading message from Kafka: %v", err)
+				if ctx.Err() == nil {
+					c.logger.Error("Failed to read message from Kafka", zap.String("channel", c.channel), zap.Error(err))
+				}
 				continue
 			}
 
-			// Unmarshal the notification message
-			var notification NotificationMessage
-			if err := json.Unmarshal(msg.Value, &notification); err != nil {
-				log.Printf("Error unmarshaling notification message: %v", err)
+			// Skip messages for other channels on the legacy shared topic
+			// without deserializing them
+			if !c.accepts(ctx, msg) {
+				c.settle(msg)
 				continue
 			}
 
-			// Process the message
-			if err := handler(notification); err != nil {
-				log.Printf("Error processing notification %s: %v", notification.ID, err)
-				// In a production system, you might want to implement a dead letter queue here
+			c.handle(ctx, msg, handler)
+		}
+	}
+}
+
+// consumeByPriority reads messages into a bounded buffer in the background and
+// hands the most urgent buffered message to the handler first, using the
+// priority header set by the producer. High-priority messages overtake
+// lower-priority ones already read but not yet handled, at the cost of per-user
+// ordering. Buffered messages are not committed until they are handled, and a
+// partition's offsets are only committed up to its oldest unhandled message,
+// so messages still buffered when the consumer stops are redelivered.
+func (c *Consumer) consumeByPriority(ctx context.Context, handler func(NotificationMessage) error) error {
+	buffer := newPriorityBuffer(c.priorityBuffer)
+
+	go func() {
+		for {
+			msg, err := c.fetch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				c.logger.Error("Failed to read message from Kafka", zap.String("channel", c.channel), zap.Error(err))
+				continue
+			}
+
+			if !c.accepts(ctx, msg) {
+				c.settle(msg)
 				continue
 			}
 
-			log.Printf("Successfully processed notification %s", notification.ID)
+			if err := buffer.push(ctx, msg); err != nil {
+				return
+			}
 		}
+	}()
+
+	for {
+		msg, err := buffer.pop(ctx)
+		if err != nil {
+			return err
+		}
+		c.handle(ctx, msg, handler)
 	}
 }
 
+// handle decodes a message and passes it to the handler, dead-lettering it when
+// processing fails, and settles it unless it was interrupted by shutdown
+func (c *Consumer) handle(ctx context.Context, msg kafka.Message, handler func(NotificationMessage) error) {
+	notification, ok := c.decode(msg)
+	if !ok || !c.knownChannel(ctx, msg, notification.Channel) {
+		c.settle(msg)
+		return
+	}
+
+	// Process the message
+	if c.finish(ctx, msg, notification, handler(notification)) {
+		c.settle(msg)
+	}
+}
+
+// decode unmarshals a notification message, logging messages that cannot be decoded
+func (c *Consumer) decode(msg kafka.Message) (NotificationMessage, bool) {
+	var notification NotificationMessage
+	// Tombstones only mark cancelled notifications for compaction
+	if msg.Value == nil {
+		c.logger.Debug("Skipping tombstone", zap.String("key", string(msg.Key)), zap.String("topic", msg.Topic))
+		return notification, false
+	}
+	if err := json.Unmarshal(msg.Value, &notification); err != nil {
+		c.logger.Error("Failed to unmarshal notification message",
+			zap.String("topic", msg.Topic),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err),
+		)
+		return notification, false
+	}
+	notification.ProducedAt = msg.Time
+	return notification, true
+}
+
+// finish records the outcome of processing a message, dead-lettering it when
+// processing failed. It reports whether the message is settled and its offset
+// can be committed.
+func (c *Consumer) finish(ctx context.Context, msg kafka.Message, notification NotificationMessage, err error) bool {
+	if err != nil {
+		// Messages interrupted by shutdown are left uncommitted, so they are
+		// redelivered instead of dead-lettered
+		if ctx.Err() != nil {
+			c.logger.Info("Notification interrupted by shutdown, leaving it for redelivery",
+				zap.String("id", notification.ID),
+				zap.String("channel", notification.Channel),
+				zap.Error(err),
+			)
+			return false
+		}
+		c.logger.Error("Failed to process notification",
+			zap.String("id", notification.ID),
+			zap.String("user_id", notification.UserID),
+			zap.String("channel", notification.Channel),
+			zap.Error(err),
+		)
+		c.sendToDLQ(ctx, msg, err)
+		return true
+	}
+
+	c.logger.Debug("Processed notification",
+		zap.String("id", notification.ID),
+		zap.String("user_id", notification.UserID),
+		zap.String("channel", notification.Channel),
+	)
+	return true
+}
+
+// ConsumeBatches consumes notification messages in batches of up to size,
+// waiting at most wait after the first message for the batch to fill, so
+// channels that can send several notifications in one provider call can do
+// so. The handler returns one error per message, nil for those processed;
+// failed messages are dead-lettered individually.
+func (c *Consumer) ConsumeBatches(ctx context.Context, size int, wait time.Duration, handler func([]NotificationMessage) []error) error {
+	for {
+		raw, err := c.readBatch(ctx, size, wait)
+		if err != nil {
+			return err
+		}
+
+		msgs := make([]kafka.Message, 0, len(raw))
+		batch := make([]NotificationMessage, 0, len(raw))
+		for _, msg := range raw {
+			if notification, ok := c.decode(msg); ok && c.knownChannel(ctx, msg, notification.Channel) {
+				msgs = append(msgs, msg)
+				batch = append(batch, notification)
+			} else {
+				c.settle(msg)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		errs := handler(batch)
+		for i, msg := range msgs {
+			var err error
+			if i < len(errs) {
+				err = errs[i]
+			}
+			if c.finish(ctx, msg, batch[i], err) {
+				c.settle(msg)
+			}
+		}
+	}
+}
+
+// WaitBeforeRead registers a function the consumer calls before reading each
+// message, such as one blocking while sending is paused, so messages are left
+// on the topic instead of being read and held while it blocks. Reading stops
+// when it returns an error.
+func (c *Consumer) WaitBeforeRead(wait func(context.Context) error) {
+	c.waitBeforeRead = wait
+}
+
+// fetch waits for the function registered with WaitBeforeRead, if any, and
+// fetches the next message without committing it; see settle
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	if c.waitBeforeRead != nil {
+		if err := c.waitBeforeRead(ctx); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+	msg, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+	c.commits.add(msg)
+	return msg, nil
+}
+
+// settle marks a fetched message as done with, committing its offset once
+// every message fetched before it from its partition is settled too. The
+// commit is made even while the consumer is shutting down.
+func (c *Consumer) settle(msg kafka.Message) {
+	commit, ok := c.commits.settle(msg)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+	if err := c.reader.CommitMessages(ctx, commit); err != nil {
+		c.logger.Error("Failed to commit offset",
+			zap.String("topic", commit.Topic),
+			zap.Int("partition", commit.Partition),
+			zap.Int64("offset", commit.Offset),
+			zap.Error(err),
+		)
+	}
+}
+
+// readBatch blocks for the first message for the consumer's channel, then
+// reads more until size messages were read or wait has passed
+func (c *Consumer) readBatch(ctx context.Context, size int, wait time.Duration) ([]kafka.Message, error) {
+	var batch []kafka.Message
+	for len(batch) == 0 {
+		msg, err := c.fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			c.logger.Error("Failed to read message from Kafka", zap.String("channel", c.channel), zap.Error(err))
+			continue
+		}
+		if c.accepts(ctx, msg) {
+			batch = append(batch, msg)
+		} else {
+			c.settle(msg)
+		}
+	}
+
+	fillCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	for len(batch) < size {
+		msg, err := c.fetch(fillCtx)
+		if err != nil {
+			if fillCtx.Err() == nil {
+				c.logger.Error("Failed to read message from Kafka", zap.String("channel", c.channel), zap.Error(err))
+			}
+			break
+		}
+		if c.accepts(ctx, msg) {
+			batch = append(batch, msg)
+		} else {
+			c.settle(msg)
+		}
+	}
+	return batch, nil
+}
+
+// accepts reports whether msg belongs to the consumer's channel, using the
+// channel header set by the producer. Messages for other registered channels
+// are skipped; messages for an unknown channel are dead-lettered, see
+// ValidateChannels.
+func (c *Consumer) accepts(ctx context.Context, msg kafka.Message) bool {
+	channel := headerValue(msg, "channel")
+	if channel == c.channel {
+		return true
+	}
+	// Another group dead-letters unknown channels from the legacy topic
+	if msg.Topic == c.legacyTopic && !c.ownsLegacy {
+		return false
+	}
+	c.knownChannel(ctx, msg, channel)
+	return false
+}
+
+// knownChannel reports whether channel is registered, dead-lettering msg with
+// ErrUnknownChannel when it is not. Every channel is known until
+// ValidateChannels is called.
+func (c *Consumer) knownChannel(ctx context.Context, msg kafka.Message, channel string) bool {
+	if c.registered == nil || c.registered[channel] {
+		return true
+	}
+
+	c.logger.Warn("Dead-lettering message for unknown channel",
+		zap.String("channel", channel),
+		zap.String("topic", msg.Topic),
+		zap.Int64("offset", msg.Offset),
+	)
+	c.sendToDLQ(ctx, msg, fmt.Errorf("%w %q", ErrUnknownChannel, channel))
+	if c.onUnknown != nil {
+		c.onUnknown(channel)
+	}
+	return false
+}
+
+// headerValue returns the value of msg's header key, empty when it is not set
+func headerValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
 // Close closes the producer
 func (p *Producer) Close() error {
 	return p.writer.Close()
@@ -131,5 +791,8 @@ func (p *Producer) Close() error {
 
 // Close closes the consumer
 func (c *Consumer) Close() error {
-	return c.reader.Close()
-}
\ No newline at end of file
+	if err := c.reader.Close(); err != nil {
+		return err
+	}
+	return c.dlq.Close()
+}
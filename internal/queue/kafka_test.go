@@ -0,0 +1,769 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+	produceAPI "github.com/segmentio/kafka-go/protocol/produce"
+	"go.uber.org/zap"
+)
+
+// fakeWriter records the messages written to it
+type fakeWriter struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	err      error // returned by every write while set
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+// written returns the messages written so far
+func (w *fakeWriter) written() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.messages...)
+}
+
+// fakeReader serves a fixed list of messages and records the commits. Once
+// every message was fetched, drained is closed and fetches block until their
+// context is done.
+type fakeReader struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	commits  []kafka.Message
+	drained  chan struct{}
+}
+
+func newFakeReader(messages ...kafka.Message) *fakeReader {
+	return &fakeReader{messages: messages, drained: make(chan struct{})}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.messages) > 0 {
+		msg := r.messages[0]
+		r.messages = r.messages[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	select {
+	case <-r.drained:
+	default:
+		close(r.drained)
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	return nil
+}
+
+// committed returns the messages committed so far
+func (r *fakeReader) committed() []kafka.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]kafka.Message(nil), r.commits...)
+}
+
+// newTestConsumer returns a consumer for channel reading from reader, with
+// legacyTopic drained when it is not empty, and the writer of its dead
+// letters
+func newTestConsumer(channel, legacyTopic string, reader *fakeReader) (*Consumer, *fakeWriter) {
+	dlq := &fakeWriter{}
+	return &Consumer{
+		reader:      reader,
+		dlq:         dlq,
+		channel:     channel,
+		legacyTopic: legacyTopic,
+		assignment:  newAssignmentTracker(zap.NewNop()),
+		commits:     newCommitTracker(),
+		logger:      zap.NewNop(),
+	}, dlq
+}
+
+// newTestProducer returns a producer for cfg writing to a fake writer
+func newTestProducer(cfg config.KafkaConfig) (*Producer, *fakeWriter) {
+	if cfg.Topic == "" {
+		cfg.Topic = "notifications"
+	}
+	producer := NewProducer(cfg, zap.NewNop())
+	writer := &fakeWriter{}
+	producer.writer = writer
+	return producer, writer
+}
+
+// kafkaMessage returns msg as the producer publishes it, at offset of its
+// topic's first partition
+func kafkaMessage(t *testing.T, topic string, offset int64, msg NotificationMessage) kafka.Message {
+	t.Helper()
+	value, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+	return kafka.Message{
+		Topic:   topic,
+		Offset:  offset,
+		Key:     []byte(msg.ID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "channel", Value: []byte(msg.Channel)}},
+	}
+}
+
+// consumeAll runs ConsumeNotifications until every message of reader was
+// read and returns the messages passed to the handler
+func consumeAll(t *testing.T, c *Consumer, reader *fakeReader) []NotificationMessage {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var handled []NotificationMessage
+	done := make(chan error, 1)
+	go func() {
+		done <- c.ConsumeNotifications(ctx, func(msg NotificationMessage) error {
+			mu.Lock()
+			handled = append(handled, msg)
+			mu.Unlock()
+			return nil
+		})
+	}()
+
+	select {
+	case <-reader.drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not read all messages")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	return handled
+}
+
+func TestProducerPublishesToChannelTopic(t *testing.T) {
+	producer, writer := newTestProducer(config.KafkaConfig{})
+
+	for _, channel := range []string{"email", "sms", "push"} {
+		msg := NotificationMessage{ID: "n-" + channel, UserID: "user-1", Channel: channel}
+		if err := producer.PublishNotification(context.Background(), msg); err != nil {
+			t.Fatalf("PublishNotification(%s) error = %v", channel, err)
+		}
+	}
+
+	written := writer.written()
+	if len(written) != 3 {
+		t.Fatalf("wrote %d messages, want 3", len(written))
+	}
+	for i, channel := range []string{"email", "sms", "push"} {
+		if want := "notifications." + channel; written[i].Topic != want {
+			t.Errorf("%s message topic = %q, want %q", channel, written[i].Topic, want)
+		}
+		if got := headerValue(written[i], "channel"); got != channel {
+			t.Errorf("%s message channel header = %q", channel, got)
+		}
+	}
+}
+
+func TestNewConsumerSubscribesToChannelTopic(t *testing.T) {
+	tests := []struct {
+		name       string
+		drain      bool
+		wantTopics []string
+	}{
+		{"channel topic only", false, []string{"notifications.push"}},
+		{"draining the legacy topic", true, []string{"notifications.push", "notifications"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.KafkaConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "notifications", DrainLegacyTopic: tt.drain}
+			consumer := NewConsumer(cfg, "push-service", "push", zap.NewNop())
+			defer consumer.Close()
+
+			topics := consumer.reader.(*kafka.Reader).Config().GroupTopics
+			if len(topics) != len(tt.wantTopics) {
+				t.Fatalf("GroupTopics = %v, want %v", topics, tt.wantTopics)
+			}
+			for i := range topics {
+				if topics[i] != tt.wantTopics[i] {
+					t.Errorf("GroupTopics = %v, want %v", topics, tt.wantTopics)
+				}
+			}
+		})
+	}
+}
+
+func TestPushConsumerNeverReceivesEmail(t *testing.T) {
+	// During the migration the push worker drains the shared legacy topic too,
+	// where messages of every channel are mixed
+	reader := newFakeReader(
+		kafkaMessage(t, "notifications", 0, NotificationMessage{ID: "e1", UserID: "user-1", Channel: "email"}),
+		kafkaMessage(t, "notifications", 1, NotificationMessage{ID: "p1", UserID: "user-1", Channel: "push"}),
+		kafkaMessage(t, "notifications.push", 0, NotificationMessage{ID: "p2", UserID: "user-1", Channel: "push"}),
+		kafkaMessage(t, "notifications", 2, NotificationMessage{ID: "s1", UserID: "user-1", Channel: "sms"}),
+	)
+	consumer, dlq := newTestConsumer("push", "notifications", reader)
+
+	handled := consumeAll(t, consumer, reader)
+	if len(handled) != 2 || handled[0].ID != "p1" || handled[1].ID != "p2" {
+		t.Fatalf("handled %+v, want only the push messages p1 and p2", handled)
+	}
+	for _, msg := range handled {
+		if msg.Channel != "push" {
+			t.Errorf("push consumer received a %s message", msg.Channel)
+		}
+	}
+
+	// Skipped messages are committed, not dead-lettered
+	if len(dlq.written()) != 0 {
+		t.Errorf("dead-lettered %d messages, want none", len(dlq.written()))
+	}
+	var legacyCommitted int64 = -1
+	for _, msg := range reader.committed() {
+		if msg.Topic == "notifications" && msg.Offset > legacyCommitted {
+			legacyCommitted = msg.Offset
+		}
+	}
+	if legacyCommitted != 2 {
+		t.Errorf("legacy topic committed up to offset %d, want 2", legacyCommitted)
+	}
+}
+
+// unknownChannelMessages returns a fax message on the legacy topic, one on
+// the push topic whose body drifted from its header, and known messages
+func unknownChannelMessages(t *testing.T) []kafka.Message {
+	drifted := kafkaMessage(t, "notifications.push", 0, NotificationMessage{ID: "x2", UserID: "user-1", Channel: "fax"})
+	drifted.Headers = []kafka.Header{{Key: "channel", Value: []byte("push")}}
+	return []kafka.Message{
+		kafkaMessage(t, "notifications", 0, NotificationMessage{ID: "x1", UserID: "user-1", Channel: "fax"}),
+		kafkaMessage(t, "notifications", 1, NotificationMessage{ID: "e1", UserID: "user-1", Channel: "email"}),
+		drifted,
+		kafkaMessage(t, "notifications.push", 1, NotificationMessage{ID: "p1", UserID: "user-1", Channel: "push"}),
+	}
+}
+
+func TestConsumerDeadLettersUnknownChannels(t *testing.T) {
+	reader := newFakeReader(unknownChannelMessages(t)...)
+	consumer, dlq := newTestConsumer("push", "notifications", reader)
+	var unknown []string
+	consumer.ValidateChannels([]string{"push", "email", "sms"}, func(channel string) { unknown = append(unknown, channel) })
+
+	handled := consumeAll(t, consumer, reader)
+	if len(handled) != 1 || handled[0].ID != "p1" {
+		t.Errorf("handled %+v, want only p1", handled)
+	}
+
+	// Messages of other known channels are still skipped silently
+	dead := dlq.written()
+	if len(dead) != 2 {
+		t.Fatalf("dead-lettered %d messages, want 2", len(dead))
+	}
+	for i, want := range []string{"x1", "x2"} {
+		var msg NotificationMessage
+		if err := json.Unmarshal(dead[i].Value, &msg); err != nil || msg.ID != want {
+			t.Errorf("dead letter %d = %s, want %s", i, dead[i].Value, want)
+		}
+		if reason := headerValue(dead[i], headerDLQError); !strings.Contains(reason, `unknown channel "fax"`) {
+			t.Errorf("dead letter %d reason = %q, want the unknown channel", i, reason)
+		}
+	}
+	if !reflect.DeepEqual(unknown, []string{"fax", "fax"}) {
+		t.Errorf("unknown channels reported = %v, want fax twice", unknown)
+	}
+}
+
+func TestConsumerUnknownChannelsOnLegacyTopicDeadLetteredOnce(t *testing.T) {
+	// The email group owns the legacy topic, so the push group leaves x1 to it
+	reader := newFakeReader(unknownChannelMessages(t)...)
+	consumer, dlq := newTestConsumer("push", "notifications", reader)
+	consumer.ValidateChannels([]string{"email", "sms", "push"}, nil)
+
+	consumeAll(t, consumer, reader)
+	dead := dlq.written()
+	if len(dead) != 1 {
+		t.Fatalf("dead-lettered %d messages, want only the drifted x2", len(dead))
+	}
+	var msg NotificationMessage
+	if err := json.Unmarshal(dead[0].Value, &msg); err != nil || msg.ID != "x2" {
+		t.Errorf("dead letter = %s, want x2", dead[0].Value)
+	}
+}
+
+func TestConsumerWithoutChannelValidation(t *testing.T) {
+	reader := newFakeReader(unknownChannelMessages(t)...)
+	consumer, dlq := newTestConsumer("push", "notifications", reader)
+
+	// Unknown channels are skipped or handled like before
+	handled := consumeAll(t, consumer, reader)
+	if len(handled) != 2 {
+		t.Errorf("handled %d messages, want x2 and p1", len(handled))
+	}
+	if len(dlq.written()) != 0 {
+		t.Errorf("dead-lettered %d messages, want none", len(dlq.written()))
+	}
+}
+
+func TestRequiredAcks(t *testing.T) {
+	tests := []struct {
+		value string
+		want  kafka.RequiredAcks
+	}{
+		{"", kafka.RequireAll},
+		{"all", kafka.RequireAll},
+		{"-1", kafka.RequireAll},
+		{"one", kafka.RequireOne},
+		{"1", kafka.RequireOne},
+		{"none", kafka.RequireNone},
+		{"0", kafka.RequireNone},
+		{"most", kafka.RequireAll},
+	}
+
+	for _, tt := range tests {
+		if got := requiredAcks(tt.value, zap.NewNop()); got != tt.want {
+			t.Errorf("requiredAcks(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStartOffset(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"", kafka.LastOffset},
+		{StartOffsetLatest, kafka.LastOffset},
+		{StartOffsetEarliest, kafka.FirstOffset},
+		{"beginning", kafka.LastOffset},
+	}
+
+	for _, tt := range tests {
+		if got := startOffset(tt.value, zap.NewNop()); got != tt.want {
+			t.Errorf("startOffset(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewConsumerStartOffset(t *testing.T) {
+	tests := []struct {
+		startOffset string
+		want        int64
+	}{
+		{StartOffsetEarliest, kafka.FirstOffset},
+		{StartOffsetLatest, kafka.LastOffset},
+		{"", kafka.LastOffset},
+	}
+
+	for _, tt := range tests {
+		cfg := config.KafkaConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "notifications", StartOffset: tt.startOffset}
+		consumer := NewConsumer(cfg, "sms-service", "sms", zap.NewNop())
+		if got := consumer.reader.(*kafka.Reader).Config().StartOffset; got != tt.want {
+			t.Errorf("StartOffset %q: reader StartOffset = %v, want %v", tt.startOffset, got, tt.want)
+		}
+		consumer.Close()
+	}
+}
+
+func TestNewProducerWriterSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.KafkaConfig
+		wantAcks     kafka.RequiredAcks
+		wantAttempts int
+	}{
+		{"defaults to all replicas", config.KafkaConfig{}, kafka.RequireAll, 0},
+		{"configured", config.KafkaConfig{RequiredAcks: "one", MaxAttempts: 5}, kafka.RequireOne, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := NewProducer(tt.cfg, zap.NewNop())
+			writer := producer.writer.(*kafka.Writer)
+			if writer.RequiredAcks != tt.wantAcks {
+				t.Errorf("RequiredAcks = %v, want %v", writer.RequiredAcks, tt.wantAcks)
+			}
+			if writer.MaxAttempts != tt.wantAttempts {
+				t.Errorf("MaxAttempts = %d, want %d", writer.MaxAttempts, tt.wantAttempts)
+			}
+			if writer.Async {
+				t.Error("Async = true, want synchronous writes by default")
+			}
+			if writer.AllowAutoTopicCreation {
+				t.Error("AllowAutoTopicCreation = true, want topics to be created explicitly")
+			}
+		})
+	}
+}
+
+func TestProducerPartitionsByUser(t *testing.T) {
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	balancer := NewProducer(config.KafkaConfig{}, zap.NewNop()).writer.(*kafka.Writer).Balancer
+	producer, writer := newTestProducer(config.KafkaConfig{})
+	ctx := context.Background()
+
+	for _, msg := range []NotificationMessage{
+		{ID: "n1", UserID: "user-1", Channel: "email"},
+		{ID: "n2", UserID: "user-1", Channel: "email"},
+	} {
+		if err := producer.PublishNotification(ctx, msg); err != nil {
+			t.Fatalf("PublishNotification(%s) error = %v", msg.ID, err)
+		}
+	}
+	written := writer.written()
+	first, second := balancer.Balance(written[0], partitions...), balancer.Balance(written[1], partitions...)
+	if first != second {
+		t.Errorf("user-1's notifications went to partitions %d and %d, want the same one", first, second)
+	}
+
+	// Different users are spread across the partitions
+	used := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		msg := NotificationMessage{ID: fmt.Sprintf("n%d", i), UserID: fmt.Sprintf("user-%d", i), Channel: "email"}
+		if err := producer.PublishNotification(ctx, msg); err != nil {
+			t.Fatalf("PublishNotification(%s) error = %v", msg.ID, err)
+		}
+	}
+	for _, msg := range writer.written()[2:] {
+		used[balancer.Balance(msg, partitions...)] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("50 users used %d partitions, want them spread", len(used))
+	}
+}
+
+func TestProducerPartitionKey(t *testing.T) {
+	tests := []struct {
+		partitionKey string
+		msg          NotificationMessage
+		wantKey      string
+	}{
+		{"", NotificationMessage{ID: "n1", UserID: "user-1"}, "user-1"},
+		{PartitionKeyUserID, NotificationMessage{ID: "n1", UserID: "user-1"}, "user-1"},
+		{PartitionKeyID, NotificationMessage{ID: "n1", UserID: "user-1"}, "n1"},
+		{"tenant", NotificationMessage{ID: "n1", UserID: "user-1"}, "user-1"},
+		// Messages without a user fall back to their own ID
+		{PartitionKeyUserID, NotificationMessage{ID: "n1"}, "n1"},
+	}
+
+	for _, tt := range tests {
+		producer, writer := newTestProducer(config.KafkaConfig{PartitionKey: tt.partitionKey})
+		tt.msg.Channel = "sms"
+		if err := producer.PublishNotification(context.Background(), tt.msg); err != nil {
+			t.Fatalf("PublishNotification() error = %v", err)
+		}
+		if got := string(writer.written()[0].Key); got != tt.wantKey {
+			t.Errorf("partition key %q: message key = %q, want %q", tt.partitionKey, got, tt.wantKey)
+		}
+	}
+}
+
+func TestProducerPublishTombstone(t *testing.T) {
+	producer, writer := newTestProducer(config.KafkaConfig{PartitionKey: PartitionKeyID, TombstoneOnCancel: true})
+	if err := producer.PublishTombstone(context.Background(), "sms", "n1"); err != nil {
+		t.Fatalf("PublishTombstone() error = %v", err)
+	}
+
+	written := writer.written()
+	if len(written) != 1 {
+		t.Fatalf("wrote %d messages, want 1", len(written))
+	}
+	tombstone := written[0]
+	if tombstone.Topic != "notifications.sms" || string(tombstone.Key) != "n1" || tombstone.Value != nil {
+		t.Errorf("tombstone = topic %q, key %q, value %q, want a nil value keyed n1 on notifications.sms", tombstone.Topic, tombstone.Key, tombstone.Value)
+	}
+	if channel := headerValue(tombstone, "channel"); channel != "sms" {
+		t.Errorf("channel header = %q, want sms", channel)
+	}
+}
+
+func TestProducerPublishTombstoneDisabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.KafkaConfig
+	}{
+		{"tombstones off", config.KafkaConfig{PartitionKey: PartitionKeyID}},
+		// A tombstone keyed by user would compact away the user's other notifications
+		{"keyed by user", config.KafkaConfig{PartitionKey: PartitionKeyUserID, TombstoneOnCancel: true}},
+	}
+	for _, tt := range tests {
+		producer, writer := newTestProducer(tt.cfg)
+		if err := producer.PublishTombstone(context.Background(), "sms", "n1"); err != nil {
+			t.Fatalf("%s: PublishTombstone() error = %v", tt.name, err)
+		}
+		if len(writer.written()) != 0 {
+			t.Errorf("%s: wrote %d messages, want none", tt.name, len(writer.written()))
+		}
+	}
+}
+
+func TestConsumerSkipsTombstones(t *testing.T) {
+	reader := newFakeReader(
+		kafkaMessage(t, "notifications.sms", 0, NotificationMessage{ID: "n1", UserID: "user-1", Channel: "sms"}),
+		kafka.Message{Topic: "notifications.sms", Offset: 1, Key: []byte("n1"), Headers: []kafka.Header{{Key: "channel", Value: []byte("sms")}}},
+		kafkaMessage(t, "notifications.sms", 2, NotificationMessage{ID: "n2", UserID: "user-1", Channel: "sms"}),
+	)
+	consumer, dlq := newTestConsumer("sms", "", reader)
+
+	handled := consumeAll(t, consumer, reader)
+	if len(handled) != 2 || handled[0].ID != "n1" || handled[1].ID != "n2" {
+		t.Fatalf("handled %+v, want n1 and n2 without the tombstone", handled)
+	}
+	if len(dlq.written()) != 0 {
+		t.Errorf("dead-lettered %d messages, want none", len(dlq.written()))
+	}
+}
+
+func TestConsumeBatches(t *testing.T) {
+	var messages []kafka.Message
+	for i := 0; i < 5; i++ {
+		messages = append(messages, kafkaMessage(t, "notifications.push", int64(i), NotificationMessage{ID: fmt.Sprintf("p%d", i+1), UserID: "user-1", Channel: "push"}))
+	}
+	reader := newFakeReader(messages...)
+	consumer, dlq := newTestConsumer("push", "", reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var batches [][]string
+	handledAll := make(chan struct{})
+	handled := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeBatches(ctx, 2, 20*time.Millisecond, func(msgs []NotificationMessage) []error {
+			ids := make([]string, len(msgs))
+			errs := make([]error, len(msgs))
+			for i, msg := range msgs {
+				ids[i] = msg.ID
+				if msg.ID == "p3" {
+					errs[i] = fmt.Errorf("invalid token")
+				}
+			}
+			batches = append(batches, ids)
+			if handled += len(msgs); handled == len(messages) {
+				close(handledAll)
+			}
+			return errs
+		})
+	}()
+
+	select {
+	case <-handledAll:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not handle all messages")
+	}
+	cancel()
+	<-done
+
+	want := [][]string{{"p1", "p2"}, {"p3", "p4"}, {"p5"}}
+	if fmt.Sprint(batches) != fmt.Sprint(want) {
+		t.Errorf("batches = %v, want %v", batches, want)
+	}
+
+	// Only the failed message is dead-lettered
+	dead := dlq.written()
+	if len(dead) != 1 {
+		t.Fatalf("dead-lettered %d messages, want 1", len(dead))
+	}
+	var msg NotificationMessage
+	if err := json.Unmarshal(dead[0].Value, &msg); err != nil || msg.ID != "p3" {
+		t.Errorf("dead-lettered %s, want p3", dead[0].Value)
+	}
+}
+
+func TestNewProducerBatchSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.KafkaConfig
+		wantSize    int
+		wantTimeout time.Duration
+		wantAsync   bool
+	}{
+		{"defaults", config.KafkaConfig{}, 100, 10 * time.Millisecond, false},
+		{"high throughput", config.KafkaConfig{ProducerBatchSize: 1000, ProducerBatchTimeout: 50 * time.Millisecond, ProducerAsync: true}, 1000, 50 * time.Millisecond, true},
+		{"low latency", config.KafkaConfig{ProducerBatchSize: 1, ProducerBatchTimeout: time.Millisecond}, 1, time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := NewProducer(tt.cfg, zap.NewNop()).writer.(*kafka.Writer)
+			if writer.BatchSize != tt.wantSize {
+				t.Errorf("BatchSize = %d, want %d", writer.BatchSize, tt.wantSize)
+			}
+			if writer.BatchTimeout != tt.wantTimeout {
+				t.Errorf("BatchTimeout = %v, want %v", writer.BatchTimeout, tt.wantTimeout)
+			}
+			if writer.Async != tt.wantAsync {
+				t.Errorf("Async = %v, want %v", writer.Async, tt.wantAsync)
+			}
+			// Async failures must reach the producer to be buffered or logged
+			if (writer.Completion != nil) != tt.wantAsync {
+				t.Errorf("Completion set = %v, want %v", writer.Completion != nil, tt.wantAsync)
+			}
+		})
+	}
+}
+
+// fakeBroker is a single-broker cluster answering metadata and produce
+// requests in memory, taking latency per produce request like a network round
+// trip would
+type fakeBroker struct {
+	partitions int
+	latency    time.Duration
+	requests   atomic.Int64
+	records    atomic.Int64
+}
+
+func (b *fakeBroker) RoundTrip(ctx context.Context, addr net.Addr, msg protocol.Message) (protocol.Message, error) {
+	switch req := msg.(type) {
+	case *metadataAPI.Request:
+		resp := &metadataAPI.Response{Brokers: []metadataAPI.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}}, ControllerID: 1}
+		for _, topic := range req.TopicNames {
+			partitions := make([]metadataAPI.ResponsePartition, b.partitions)
+			for i := range partitions {
+				partitions[i] = metadataAPI.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1}
+			}
+			resp.Topics = append(resp.Topics, metadataAPI.ResponseTopic{Name: topic, Partitions: partitions})
+		}
+		return resp, nil
+	case *produceAPI.Request:
+		b.requests.Add(1)
+		time.Sleep(b.latency)
+		resp := &produceAPI.Response{}
+		for _, topic := range req.Topics {
+			responseTopic := produceAPI.ResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				for {
+					if _, err := partition.RecordSet.Records.ReadRecord(); err != nil {
+						if err != io.EOF {
+							return nil, err
+						}
+						break
+					}
+					b.records.Add(1)
+				}
+				responseTopic.Partitions = append(responseTopic.Partitions, produceAPI.ResponsePartition{Partition: partition.Partition})
+			}
+			resp.Topics = append(resp.Topics, responseTopic)
+		}
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("fakeBroker: unexpected %T", msg)
+	}
+}
+
+// BenchmarkProducerBatching publishes from many goroutines, as the API does
+// under load, with different batch settings against a broker with a 1ms round
+// trip. It reports the produce requests per message: larger batches need
+// fewer round trips for the same throughput.
+func BenchmarkProducerBatching(b *testing.B) {
+	settings := []struct {
+		name string
+		cfg  config.KafkaConfig
+	}{
+		{"unbatched", config.KafkaConfig{ProducerBatchSize: 1, ProducerBatchTimeout: time.Millisecond}},
+		{"default", config.KafkaConfig{}},
+		{"large", config.KafkaConfig{ProducerBatchSize: 1000, ProducerBatchTimeout: 20 * time.Millisecond}},
+		{"async", config.KafkaConfig{ProducerBatchSize: 1000, ProducerBatchTimeout: 20 * time.Millisecond, ProducerAsync: true}},
+	}
+
+	for _, setting := range settings {
+		b.Run(setting.name, func(b *testing.B) {
+			cfg := setting.cfg
+			cfg.Brokers = []string{"localhost:9092"}
+			cfg.Topic = "notifications"
+			producer := NewProducer(cfg, zap.NewNop())
+			broker := &fakeBroker{partitions: 8, latency: time.Millisecond}
+			producer.writer.(*kafka.Writer).Transport = broker
+
+			var n atomic.Int64
+			b.SetParallelism(64)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := n.Add(1)
+					msg := NotificationMessage{ID: fmt.Sprintf("n%d", i), UserID: fmt.Sprintf("user-%d", i%100), Channel: "email"}
+					if err := producer.PublishNotification(context.Background(), msg); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			// Closing flushes messages still queued by async writes
+			producer.Close()
+			b.StopTimer()
+
+			b.ReportMetric(float64(broker.requests.Load())/float64(b.N), "requests/msg")
+			if got := broker.records.Load(); got != int64(b.N) {
+				b.Errorf("broker received %d messages, want %d", got, b.N)
+			}
+		})
+	}
+}
+
+func TestNotificationMessageQueueWait(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		msg  NotificationMessage
+		want time.Duration
+	}{
+		{"produced", NotificationMessage{ProducedAt: now.Add(-90 * time.Second), CreatedAt: now.Add(-time.Hour)}, 90 * time.Second},
+		{"created only", NotificationMessage{CreatedAt: now.Add(-5 * time.Second)}, 5 * time.Second},
+		{"no timestamps", NotificationMessage{}, 0},
+		{"clock skew", NotificationMessage{ProducedAt: now.Add(time.Second)}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.msg.QueueWait(now); got != tt.want {
+			t.Errorf("%s: QueueWait() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConsumerDecodeSetsProducedAt(t *testing.T) {
+	consumer, _ := newTestConsumer("sms", "", newFakeReader())
+	msg := kafkaMessage(t, "notifications.sms", 1, NotificationMessage{ID: "n1", Channel: "sms"})
+	msg.Time = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	decoded, ok := consumer.decode(msg)
+	if !ok {
+		t.Fatal("decode() failed")
+	}
+	if !decoded.ProducedAt.Equal(msg.Time) {
+		t.Errorf("ProducedAt = %v, want the Kafka timestamp %v", decoded.ProducedAt, msg.Time)
+	}
+}
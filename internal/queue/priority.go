@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// defaultPriority is used for messages without a valid priority header
+const defaultPriority = 2
+
+// messagePriority returns the priority header set by the producer
+// (1 = high, 2 = medium, 3 = low)
+func messagePriority(msg kafka.Message) int {
+	for _, h := range msg.Headers {
+		if h.Key == "priority" {
+			if priority, err := strconv.Atoi(string(h.Value)); err == nil {
+				return priority
+			}
+			break
+		}
+	}
+	return defaultPriority
+}
+
+// bufferedMessage is a fetched message waiting in a priorityBuffer
+type bufferedMessage struct {
+	msg      kafka.Message
+	priority int
+	seq      uint64 // arrival order, keeps messages of equal priority FIFO
+}
+
+// messageHeap orders buffered messages by priority, then arrival
+type messageHeap []bufferedMessage
+
+func (h messageHeap) Len() int { return len(h) }
+func (h messageHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority < h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h messageHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *messageHeap) Push(x interface{}) { *h = append(*h, x.(bufferedMessage)) }
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// priorityBuffer is a bounded buffer that releases the most urgent message
+// first. push blocks while the buffer is full and pop while it is empty.
+type priorityBuffer struct {
+	mu    sync.Mutex
+	heap  messageHeap
+	seq   uint64
+	slots chan struct{} // one token per occupied slot
+	ready chan struct{} // one token per buffered message
+}
+
+// newPriorityBuffer creates a buffer holding up to size messages
+func newPriorityBuffer(size int) *priorityBuffer {
+	return &priorityBuffer{
+		slots: make(chan struct{}, size),
+		ready: make(chan struct{}, size),
+	}
+}
+
+// push adds a message, waiting for a free slot
+func (b *priorityBuffer) push(ctx context.Context, msg kafka.Message) error {
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mu.Lock()
+	b.seq++
+	heap.Push(&b.heap, bufferedMessage{msg: msg, priority: messagePriority(msg), seq: b.seq})
+	b.mu.Unlock()
+
+	b.ready <- struct{}{}
+	return nil
+}
+
+// pop removes the most urgent message, waiting for one to arrive
+func (b *priorityBuffer) pop(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-b.ready:
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+
+	b.mu.Lock()
+	item := heap.Pop(&b.heap).(bufferedMessage)
+	b.mu.Unlock()
+
+	<-b.slots
+	return item.msg, nil
+}
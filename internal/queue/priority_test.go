@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// priorityMessage returns a message with offset and priority header
+func priorityMessage(offset int64, priority string) kafka.Message {
+	msg := kafka.Message{Offset: offset}
+	if priority != "" {
+		msg.Headers = []kafka.Header{{Key: "priority", Value: []byte(priority)}}
+	}
+	return msg
+}
+
+func TestMessagePriority(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"1", 1},
+		{"3", 3},
+		{"", defaultPriority},
+		{"urgent", defaultPriority},
+	}
+
+	for _, tt := range tests {
+		if got := messagePriority(priorityMessage(0, tt.header)); got != tt.want {
+			t.Errorf("messagePriority(%q) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityBufferReleasesMostUrgentFirst(t *testing.T) {
+	buffer := newPriorityBuffer(10)
+	ctx := context.Background()
+	for i, priority := range []string{"3", "2", "1", "3", "1", ""} {
+		if err := buffer.push(ctx, priorityMessage(int64(i), priority)); err != nil {
+			t.Fatalf("push() error = %v", err)
+		}
+	}
+
+	// High before medium before low, in arrival order within a priority
+	want := []int64{2, 4, 1, 5, 0, 3}
+	for _, offset := range want {
+		msg, err := buffer.pop(ctx)
+		if err != nil {
+			t.Fatalf("pop() error = %v", err)
+		}
+		if msg.Offset != offset {
+			t.Fatalf("pop() = offset %d, want %d", msg.Offset, offset)
+		}
+	}
+}
+
+func TestPriorityBufferBlocksWhenFull(t *testing.T) {
+	buffer := newPriorityBuffer(1)
+	if err := buffer.push(context.Background(), priorityMessage(0, "1")); err != nil {
+		t.Fatalf("push() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := buffer.push(ctx, priorityMessage(1, "1")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("push() into a full buffer = %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := buffer.pop(context.Background()); err != nil {
+		t.Errorf("pop() error = %v", err)
+	}
+	if _, err := buffer.pop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("pop() from an empty buffer = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestConsumerHandlesHighPriorityFirst(t *testing.T) {
+	ids := []string{"low-1", "medium-1", "low-2", "high-1", "medium-2", "high-2"}
+	priorities := map[string]string{"low-1": "3", "medium-1": "2", "low-2": "3", "high-1": "1", "medium-2": "2", "high-2": "1"}
+	var messages []kafka.Message
+	for i, id := range ids {
+		msg := kafkaMessage(t, "notifications.sms", int64(i), NotificationMessage{ID: id, UserID: "user-1", Channel: "sms"})
+		msg.Headers = append(msg.Headers, kafka.Header{Key: "priority", Value: []byte(priorities[id])})
+		messages = append(messages, msg)
+	}
+	reader := newFakeReader(messages...)
+	consumer, _ := newTestConsumer("sms", "", reader)
+	consumer.priorityBuffer = 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handled := make(chan string, len(ids))
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeNotifications(ctx, func(msg NotificationMessage) error {
+			// Holding the first message until every message was read lets
+			// the rest queue up in the buffer
+			if len(handled) == 0 {
+				<-reader.drained
+			}
+			handled <- msg.ID
+			return nil
+		})
+	}()
+
+	var got []string
+	for len(got) < len(ids) {
+		select {
+		case id := <-handled:
+			got = append(got, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handled %v, want all %d messages", got, len(ids))
+		}
+	}
+	cancel()
+	<-done
+
+	// Whichever message was handled first, the buffered ones follow by
+	// priority, in arrival order within a priority
+	var want []string
+	for _, priority := range []string{"1", "2", "3"} {
+		for _, id := range ids {
+			if priorities[id] == priority && id != got[0] {
+				want = append(want, id)
+			}
+		}
+	}
+	for i := range want {
+		if got[i+1] != want[i] {
+			t.Fatalf("handled %v, want %s then %v", got, got[0], want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package queuetest provides a queue.Producer that records the messages it
+// publishes instead of writing them to Kafka, for tests of code publishing
+// notifications.
+package queuetest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/queue"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// Recorder records the messages written by a producer
+type Recorder struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	failing  error // returned for every write while set
+}
+
+// NewProducer returns a producer for the "notifications" topic writing to the
+// returned recorder
+func NewProducer(t testing.TB) (*queue.Producer, *Recorder) {
+	t.Helper()
+	return NewProducerWithConfig(t, config.KafkaConfig{Topic: "notifications"})
+}
+
+// NewProducerWithConfig is NewProducer with the producer configured by cfg
+func NewProducerWithConfig(t testing.TB, cfg config.KafkaConfig) (*queue.Producer, *Recorder) {
+	t.Helper()
+	producer := queue.NewProducer(cfg, zap.NewNop())
+	recorder := &Recorder{}
+	producer.UseWriter(recorder)
+	return producer, recorder
+}
+
+// WriteMessages implements queue.MessageWriter
+func (r *Recorder) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing != nil {
+		return r.failing
+	}
+	r.messages = append(r.messages, msgs...)
+	return nil
+}
+
+// Close implements queue.MessageWriter
+func (r *Recorder) Close() error {
+	return nil
+}
+
+// Fail makes every write fail with err until it is called with nil
+func (r *Recorder) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = err
+}
+
+// Published returns the notification messages written so far, in order.
+// Tombstones and other messages without a notification are skipped.
+func (r *Recorder) Published(t testing.TB) []queue.NotificationMessage {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var published []queue.NotificationMessage
+	for _, msg := range r.messages {
+		if msg.Value == nil {
+			continue
+		}
+		var notification queue.NotificationMessage
+		if err := json.Unmarshal(msg.Value, &notification); err != nil {
+			t.Fatalf("failed to decode published message: %v", err)
+		}
+		published = append(published, notification)
+	}
+	return published
+}
+
+// Tombstones returns the keys of the tombstones written so far, in order
+func (r *Recorder) Tombstones() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []string
+	for _, msg := range r.messages {
+		if msg.Value == nil {
+			keys = append(keys, string(msg.Key))
+		}
+	}
+	return keys
+}
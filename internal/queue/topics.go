@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// ErrTopicsMissing is returned by EnsureTopics when required topics do not exist
+var ErrTopicsMissing = errors.New("kafka topics do not exist")
+
+// topicAdmin is the part of the Kafka client used to check and create topics
+type topicAdmin interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error)
+}
+
+// ChannelTopics returns the per-channel topics for channels plus the dead
+// letter topic, the topics a service publishes to or consumes from
+func ChannelTopics(baseTopic string, channels ...string) []string {
+	topics := make([]string, 0, len(channels)+1)
+	for _, channel := range channels {
+		topics = append(topics, ChannelTopic(baseTopic, channel))
+	}
+	return append(topics, DLQTopic(baseTopic))
+}
+
+// EnsureTopics checks that topics exist so a missing topic fails at startup
+// instead of at the first publish. When cfg.CreateTopics is set, missing
+// topics are created with the configured partitions, replication factor and
+// message TTL; otherwise an error naming them is returned.
+func EnsureTopics(ctx context.Context, cfg config.KafkaConfig, topics []string, logger *zap.Logger) error {
+	client := &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: 10 * time.Second}
+	return ensureTopics(ctx, client, cfg, topics, logger)
+}
+
+func ensureTopics(ctx context.Context, admin topicAdmin, cfg config.KafkaConfig, topics []string, logger *zap.Logger) error {
+	missing, err := missingTopics(ctx, admin, topics)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if !cfg.CreateTopics {
+		return fmt.Errorf("%w: %s (create them or set KAFKA_CREATE_TOPICS=true)", ErrTopicsMissing, strings.Join(missing, ", "))
+	}
+
+	req := &kafka.CreateTopicsRequest{Topics: make([]kafka.TopicConfig, len(missing))}
+	for i, topic := range missing {
+		req.Topics[i] = kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     cfg.TopicPartitions,
+			ReplicationFactor: cfg.TopicReplicationFactor,
+		}
+		if cfg.MessageTTL > 0 {
+			req.Topics[i].ConfigEntries = []kafka.ConfigEntry{
+				{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(cfg.MessageTTL.Milliseconds(), 10)},
+			}
+		}
+	}
+
+	resp, err := admin.CreateTopics(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka topics %s: %w", strings.Join(missing, ", "), err)
+	}
+	for _, topic := range missing {
+		// Another instance starting at the same time may have won the race
+		if err := resp.Errors[topic]; err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+			return fmt.Errorf("failed to create kafka topic %s: %w", topic, err)
+		}
+		logger.Info("Created Kafka topic",
+			zap.String("topic", topic),
+			zap.Int("partitions", cfg.TopicPartitions),
+			zap.Int("replication_factor", cfg.TopicReplicationFactor),
+		)
+	}
+	return nil
+}
+
+// missingTopics returns the topics the cluster reports as unknown
+func missingTopics(ctx context.Context, admin topicAdmin, topics []string) ([]string, error) {
+	resp, err := admin.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kafka topic metadata: %w", err)
+	}
+
+	found := make(map[string]bool, len(resp.Topics))
+	for _, topic := range resp.Topics {
+		if topic.Error == nil {
+			found[topic.Name] = true
+		} else if !errors.Is(topic.Error, kafka.UnknownTopicOrPartition) {
+			return nil, fmt.Errorf("failed to read metadata for kafka topic %s: %w", topic.Name, topic.Error)
+		}
+	}
+
+	var missing []string
+	for _, topic := range topics {
+		if !found[topic] {
+			missing = append(missing, topic)
+		}
+	}
+	return missing, nil
+}
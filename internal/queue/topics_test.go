@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// fakeAdmin is a controller client whose cluster has the topics in existing
+type fakeAdmin struct {
+	existing     map[string]bool
+	metadataErr  error
+	createErrors map[string]error
+	created      []kafka.TopicConfig
+}
+
+func (a *fakeAdmin) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	if a.metadataErr != nil {
+		return nil, a.metadataErr
+	}
+	resp := &kafka.MetadataResponse{}
+	for _, topic := range req.Topics {
+		t := kafka.Topic{Name: topic}
+		if !a.existing[topic] {
+			t.Error = kafka.UnknownTopicOrPartition
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+	return resp, nil
+}
+
+func (a *fakeAdmin) CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error) {
+	a.created = append(a.created, req.Topics...)
+	return &kafka.CreateTopicsResponse{Errors: a.createErrors}, nil
+}
+
+func TestChannelTopics(t *testing.T) {
+	got := ChannelTopics("notifications", "email", "sms")
+	want := []string{ChannelTopic("notifications", "email"), ChannelTopic("notifications", "sms"), DLQTopic("notifications")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelTopics() = %v, want %v", got, want)
+	}
+}
+
+func TestEnsureTopicsExisting(t *testing.T) {
+	admin := &fakeAdmin{existing: map[string]bool{"a": true, "b": true}}
+	if err := ensureTopics(context.Background(), admin, config.KafkaConfig{}, []string{"a", "b"}, zap.NewNop()); err != nil {
+		t.Fatalf("ensureTopics() error = %v", err)
+	}
+	if len(admin.created) != 0 {
+		t.Errorf("created %v, want nothing", admin.created)
+	}
+}
+
+func TestEnsureTopicsMissing(t *testing.T) {
+	admin := &fakeAdmin{existing: map[string]bool{"a": true}}
+	err := ensureTopics(context.Background(), admin, config.KafkaConfig{}, []string{"a", "b"}, zap.NewNop())
+	if !errors.Is(err, ErrTopicsMissing) {
+		t.Fatalf("ensureTopics() error = %v, want ErrTopicsMissing", err)
+	}
+	if len(admin.created) != 0 {
+		t.Errorf("created %v without KAFKA_CREATE_TOPICS", admin.created)
+	}
+}
+
+func TestEnsureTopicsCreatesMissing(t *testing.T) {
+	admin := &fakeAdmin{
+		existing:     map[string]bool{"a": true},
+		createErrors: map[string]error{"c": kafka.TopicAlreadyExists},
+	}
+	cfg := config.KafkaConfig{CreateTopics: true, TopicPartitions: 6, TopicReplicationFactor: 3, MessageTTL: 24 * time.Hour}
+
+	if err := ensureTopics(context.Background(), admin, cfg, []string{"a", "b", "c"}, zap.NewNop()); err != nil {
+		t.Fatalf("ensureTopics() error = %v", err)
+	}
+	if len(admin.created) != 2 || admin.created[0].Topic != "b" || admin.created[1].Topic != "c" {
+		t.Fatalf("created %v, want b and c", admin.created)
+	}
+	created := admin.created[0]
+	if created.NumPartitions != 6 || created.ReplicationFactor != 3 {
+		t.Errorf("created %+v, want 6 partitions and replication factor 3", created)
+	}
+	want := []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "86400000"}}
+	if !reflect.DeepEqual(created.ConfigEntries, want) {
+		t.Errorf("ConfigEntries = %v, want %v", created.ConfigEntries, want)
+	}
+}
+
+func TestEnsureTopicsCreateFailure(t *testing.T) {
+	admin := &fakeAdmin{createErrors: map[string]error{"a": kafka.InvalidReplicationFactor}}
+	cfg := config.KafkaConfig{CreateTopics: true, TopicPartitions: 1, TopicReplicationFactor: 5}
+
+	if err := ensureTopics(context.Background(), admin, cfg, []string{"a"}, zap.NewNop()); !errors.Is(err, kafka.InvalidReplicationFactor) {
+		t.Errorf("ensureTopics() error = %v, want InvalidReplicationFactor", err)
+	}
+}
+
+func TestEnsureTopicsMetadataFailure(t *testing.T) {
+	admin := &fakeAdmin{metadataErr: errors.New("connection refused")}
+	if err := ensureTopics(context.Background(), admin, config.KafkaConfig{}, []string{"a"}, zap.NewNop()); err == nil {
+		t.Error("ensureTopics() error = nil, want the metadata error")
+	}
+}
@@ -0,0 +1,297 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database"
+	"github.com/alexnthnz/notification-system/internal/logging"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+// Main runs a worker process named name for channelTypes, or for the channels
+// in WORKER_CHANNELS when none are given, until it receives SIGINT or SIGTERM.
+// Each channel is built from the channel registry and runs in its own consumer
+// group, so the channel services and the multi-channel worker can replace each
+// other.
+func Main(name string, channelTypes ...string) {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := logging.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("Starting " + name)
+
+	// Initialize metrics
+	metrics := monitoring.NewMetrics()
+
+	// Connect to PostgreSQL
+	postgres, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+	}
+	defer postgres.Close()
+
+	// Connect to Redis
+	redis, err := database.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Fatal("Failed to connect to Redis", zap.Error(err))
+	}
+	defer redis.Close()
+
+	// Initialize notification service
+	notificationService, err := notification.NewService(cfg, postgres, redis, nil, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize notification service", zap.Error(err))
+	}
+
+	// Build the channels
+	if len(channelTypes) == 0 {
+		channelTypes = cfg.Worker.Channels
+	}
+	manager, err := channels.NewConfiguredManager(context.Background(), cfg.Channels, channelTypes, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize channels", zap.Error(err))
+	}
+	channelTypes = manager.ChannelTypes()
+	if len(channelTypes) == 0 {
+		logger.Fatal("No enabled channels to run")
+	}
+
+	// Size each channel's consumers to its latency
+	consumers, err := ParseChannelConsumers(cfg.Kafka.ChannelConsumers)
+	if err != nil {
+		logger.Fatal("Invalid per-channel consumer counts", zap.Error(err))
+	}
+
+	// Split sends between the weighted providers of each channel
+	if err := applyProviderWeights(manager, cfg.Channels, logger); err != nil {
+		logger.Fatal("Failed to initialize weighted providers", zap.Error(err))
+	}
+
+	// Build the candidate providers sends are mirrored to
+	shadows, err := newShadows(cfg.Channels, channelTypes, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize shadow sends", zap.Error(err))
+	}
+
+	// Fail fast if the channel topics are missing, or create them when configured
+	if err := queue.EnsureTopics(context.Background(), cfg.Kafka, queue.ChannelTopics(cfg.Kafka.Topic, channelTypes...), logger); err != nil {
+		logger.Fatal("Kafka topics are not ready", zap.Error(err))
+	}
+
+	// Messages for channels that are not registered are dead-lettered
+	registered := registeredChannels(cfg.Channels)
+
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Start consuming notifications, KAFKA_CONSUMERS consumers per channel
+	partitions := NewPartitions()
+	var wg sync.WaitGroup
+	for _, channelType := range channelTypes {
+		channel, _ := manager.GetChannel(channelType)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Run(ctx, channelKafkaConfig(cfg.Kafka, consumers, channelType), channel, registered, shadows[channelType], notificationService, metrics, partitions, logger); err != nil && err != context.Canceled {
+				logger.Error("Consumer error", zap.String("channel", channel.GetChannelType()), zap.Error(err))
+			}
+		}()
+	}
+
+	// Serve metrics and the current partition assignment
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.Metrics.Path, metrics.Handler())
+		mux.Handle("/partitions", partitions)
+		metricsServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.Metrics.Port),
+			Handler: mux,
+		}
+
+		go func() {
+			logger.Info("Starting metrics server", zap.Int("port", cfg.Metrics.Port))
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Metrics server error", zap.Error(err))
+			}
+		}()
+	}
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down " + name + "...")
+	cancel()
+
+	// Give the consumers some time to finish their current messages
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		for _, shadow := range shadows {
+			shadow.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	logger.Info(name + " exited")
+}
+
+// channelKafkaConfig returns the Kafka configuration of channelType's
+// consumers: cfg with the channel's own consumer count, when it has one
+func channelKafkaConfig(cfg config.KafkaConfig, consumers map[string]int, channelType string) config.KafkaConfig {
+	if count, ok := consumers[channelType]; ok {
+		cfg.Consumers = count
+	}
+	return cfg
+}
+
+// ParseChannelConsumers parses "channel=count" entries, such as "email=4",
+// into the number of consumers to run for each channel, overriding
+// KAFKA_CONSUMERS
+func ParseChannelConsumers(entries []string) (map[string]int, error) {
+	consumers := make(map[string]int)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		channel, value, ok := strings.Cut(entry, "=")
+		channel = strings.TrimSpace(channel)
+		count, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || channel == "" || err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid channel consumers %q, want channel=count with a count of at least 1", entry)
+		}
+		consumers[channel] = count
+	}
+	return consumers, nil
+}
+
+// applyProviderWeights replaces each channel in manager that has weighted
+// providers configured in cfg.Weights with a channel that splits its sends
+// between them. A provider registered under the channel's own type reuses the
+// channel already built.
+func applyProviderWeights(manager *channels.ChannelManager, cfg config.ChannelsConfig, logger *zap.Logger) error {
+	configs, err := channels.ParseProviderWeights(cfg.Weights)
+	if err != nil {
+		return err
+	}
+
+	for _, channelType := range manager.ChannelTypes() {
+		weights, ok := configs[channelType]
+		if !ok {
+			continue
+		}
+
+		providers := make([]channels.Channel, 0, len(weights))
+		values := make([]int, 0, len(weights))
+		for _, weight := range weights {
+			provider, _ := manager.GetChannel(channelType)
+			if weight.Provider != channelType {
+				provider, err = channels.NewChannel(context.Background(), weight.Provider, cfg, logger)
+				if err != nil {
+					return err
+				}
+			}
+			if provider == nil {
+				return fmt.Errorf("weighted provider %s of the %s channel is disabled", weight.Provider, channelType)
+			}
+			providers = append(providers, provider)
+			values = append(values, weight.Weight)
+			logger.Info("Weighted provider",
+				zap.String("channel", channelType),
+				zap.String("provider", provider.GetProviderName()),
+				zap.Int("weight", weight.Weight),
+			)
+		}
+
+		weighted, err := channels.NewWeighted(providers, values)
+		if err != nil {
+			return fmt.Errorf("invalid weighted providers of the %s channel: %w", channelType, err)
+		}
+		manager.RegisterChannel(weighted)
+	}
+	return nil
+}
+
+// newShadows builds a shadow for each of channelTypes that has a candidate
+// provider configured in cfg.Shadow, keyed by channel type
+func newShadows(cfg config.ChannelsConfig, channelTypes []string, logger *zap.Logger) (map[string]*channels.Shadow, error) {
+	configs, err := channels.ParseShadowSends(cfg.Shadow)
+	if err != nil {
+		return nil, err
+	}
+
+	shadows := make(map[string]*channels.Shadow)
+	for _, channelType := range channelTypes {
+		shadowCfg, ok := configs[channelType]
+		if !ok || shadowCfg.Percent == 0 {
+			continue
+		}
+
+		candidate, err := channels.NewChannel(context.Background(), shadowCfg.Candidate, cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			return nil, fmt.Errorf("shadow candidate %s of the %s channel is disabled", shadowCfg.Candidate, channelType)
+		}
+		if candidate.GetChannelType() != channelType {
+			return nil, fmt.Errorf("shadow candidate %s sends %s, not %s", shadowCfg.Candidate, candidate.GetChannelType(), channelType)
+		}
+
+		shadows[channelType] = channels.NewShadow(candidate, shadowCfg.Percent, logger)
+		logger.Info("Mirroring sends to a candidate provider",
+			zap.String("channel", channelType),
+			zap.String("candidate", candidate.GetProviderName()),
+			zap.Float64("percent", shadowCfg.Percent),
+		)
+	}
+	return shadows, nil
+}
+
+// registeredChannels returns the registered channel types with those enabled
+// in cfg first, so the group that dead-letters unknown channels from the
+// legacy topic is one that is running
+func registeredChannels(cfg config.ChannelsConfig) []string {
+	registered := channels.Registered()
+	sort.SliceStable(registered, func(i, j int) bool {
+		return cfg.IsEnabled(registered[i]) && !cfg.IsEnabled(registered[j])
+	})
+	return registered
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/config"
+)
+
+func TestParseChannelConsumers(t *testing.T) {
+	consumers, err := ParseChannelConsumers([]string{"email=4", " push = 1 ", ""})
+	if err != nil {
+		t.Fatalf("ParseChannelConsumers() error = %v", err)
+	}
+	if want := map[string]int{"email": 4, "push": 1}; !reflect.DeepEqual(consumers, want) {
+		t.Errorf("ParseChannelConsumers() = %v, want %v", consumers, want)
+	}
+
+	for _, entry := range []string{"email", "=4", "email=", "email=many", "email=0", "email=-2"} {
+		if _, err := ParseChannelConsumers([]string{entry}); err == nil {
+			t.Errorf("ParseChannelConsumers(%q) error = nil, want an error", entry)
+		}
+	}
+}
+
+func TestChannelKafkaConfig(t *testing.T) {
+	cfg := config.KafkaConfig{Topic: "notifications", Consumers: 2}
+	consumers := map[string]int{"email": 6, "push": 1}
+
+	tests := []struct {
+		channel string
+		want    int
+	}{
+		{"email", 6},
+		{"push", 1},
+		// Channels without their own count use KAFKA_CONSUMERS
+		{"sms", 2},
+	}
+	for _, tt := range tests {
+		got := channelKafkaConfig(cfg, consumers, tt.channel)
+		if got.Consumers != tt.want || got.Topic != "notifications" {
+			t.Errorf("channelKafkaConfig(%s) = %d consumers on %q, want %d on notifications", tt.channel, got.Consumers, got.Topic, tt.want)
+		}
+	}
+	if cfg.Consumers != 2 {
+		t.Errorf("shared config consumers = %d, want it unchanged", cfg.Consumers)
+	}
+}
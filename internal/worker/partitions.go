@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+// Partitions holds the Kafka partitions assigned to each consumer of a worker
+// process, by channel and consumer index
+type Partitions struct {
+	mu          sync.Mutex
+	assignments map[string]map[int]queue.Assignment
+}
+
+// NewPartitions creates an empty partition registry
+func NewPartitions() *Partitions {
+	return &Partitions{assignments: make(map[string]map[int]queue.Assignment)}
+}
+
+// set records the assignment of a channel's consumer
+func (p *Partitions) set(channel string, consumer int, assignment queue.Assignment) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.assignments[channel] == nil {
+		p.assignments[channel] = make(map[int]queue.Assignment)
+	}
+	p.assignments[channel][consumer] = assignment
+}
+
+// remove forgets a channel's consumer once it has stopped
+func (p *Partitions) remove(channel string, consumer int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.assignments[channel], consumer)
+}
+
+// ServeHTTP writes the current assignments as JSON, e.g.
+// {"email": {"0": {"notifications.email": [0, 2]}}}
+func (p *Partitions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.assignments)
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+func TestPartitionsServeHTTP(t *testing.T) {
+	partitions := NewPartitions()
+	partitions.set("email", 0, queue.Assignment{"notifications.email": {0, 2}})
+	partitions.set("email", 1, queue.Assignment{"notifications.email": {1, 3}})
+	partitions.set("sms", 0, queue.Assignment{"notifications.sms": {0}})
+	partitions.remove("sms", 0)
+
+	rec := httptest.NewRecorder()
+	partitions.ServeHTTP(rec, httptest.NewRequest("GET", "/partitions", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var got map[string]map[string]map[string][]int
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]map[string]map[string][]int{
+		"email": {
+			"0": {"notifications.email": {0, 2}},
+			"1": {"notifications.email": {1, 3}},
+		},
+		"sms": {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partitions = %v, want %v", got, want)
+	}
+}
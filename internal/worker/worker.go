@@ -0,0 +1,528 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/queue"
+)
+
+// Run consumes the notifications queued for channel and sends them through
+// it until ctx is cancelled, recording each outcome with service. The
+// consumer group is named after the channel type, e.g. "email-service", and
+// cfg.Consumers members of it run concurrently, each owning distinct
+// partitions; their assignments are kept in partitions and the
+// consumer_assigned_partitions metric. Channels that are
+// channels.RateLimitReporters export their provider's remaining quota as
+// provider_rate_limit_remaining. Notifications are consumed in batches
+// sent with one provider call when the channel is a channels.BatchSender and
+// cfg.BatchSize is above one. A non-nil shadow mirrors its sampled share of
+// sends to a candidate provider, see mirror. Messages for channels not in
+// registered are dead-lettered; the first of registered dead-letters those on
+// the shared legacy topic, see queue.Consumer.ValidateChannels.
+func Run(
+	ctx context.Context,
+	cfg config.KafkaConfig,
+	channel channels.Channel,
+	registered []string,
+	shadow *channels.Shadow,
+	service *notification.Service,
+	metrics *monitoring.Metrics,
+	partitions *Partitions,
+	logger *zap.Logger,
+) error {
+	instances := cfg.Consumers
+	if instances < 1 {
+		instances = 1
+	}
+
+	p := &processor{channel: channel, registered: registered, shadow: shadow, service: service, metrics: metrics, logger: logger}
+
+	// Export the quota each provider reports, which it throttles on
+	for _, provider := range channels.Providers(channel) {
+		if reporter, ok := provider.(channels.RateLimitReporter); ok {
+			reporter.OnRateLimit(func(remaining int) {
+				metrics.SetProviderRateLimitRemaining(provider.GetChannelType(), provider.GetProviderName(), remaining)
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, instances)
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.consume(ctx, cfg, i, partitions)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consume runs consumer number instance of the channel's consumer group until
+// ctx is cancelled
+func (p *processor) consume(ctx context.Context, cfg config.KafkaConfig, instance int, partitions *Partitions) error {
+	channelType := p.channel.GetChannelType()
+	logger := p.logger.With(zap.Int("consumer", instance))
+	consumer := queue.NewConsumer(cfg, channelType+"-service", channelType, logger)
+	defer consumer.Close()
+
+	label := strconv.Itoa(instance)
+	consumer.OnRebalance(func(assignment queue.Assignment) {
+		p.metrics.SetConsumerPartitions(channelType, label, assignment.Count())
+		partitions.set(channelType, instance, assignment)
+	})
+	defer func() {
+		p.metrics.SetConsumerPartitions(channelType, label, 0)
+		partitions.remove(channelType, instance)
+	}()
+	// Stop reading while sending is paused by an operator, so a restart
+	// during the pause does not lose messages already read
+	consumer.WaitBeforeRead(p.service.WaitWhilePaused)
+	consumer.ValidateChannels(p.registered, func(string) {
+		p.metrics.RecordUnknownChannel(channelType)
+	})
+
+	_, batches := p.channel.(channels.BatchSender)
+	batches = batches && cfg.BatchSize > 1
+
+	logger.Info("Starting to consume notifications", zap.String("channel", channelType), zap.Bool("batched", batches))
+	if batches {
+		return consumer.ConsumeBatches(ctx, cfg.BatchSize, cfg.BatchWait, func(msgs []queue.NotificationMessage) []error {
+			return p.processBatch(ctx, msgs)
+		})
+	}
+	return consumer.ConsumeNotifications(ctx, func(msg queue.NotificationMessage) error {
+		return p.process(ctx, msg)
+	})
+}
+
+// processor sends queued notifications through one channel
+type processor struct {
+	channel    channels.Channel
+	registered []string         // channel types messages may be for
+	shadow     *channels.Shadow // nil unless sends are mirrored to a candidate provider
+	service    *notification.Service
+	metrics    *monitoring.Metrics
+	logger     *zap.Logger
+}
+
+// process sends one queued notification and records the outcome on it
+func (p *processor) process(ctx context.Context, msg queue.NotificationMessage) error {
+	p.metrics.RecordQueueWait(p.channel.GetChannelType(), msg.QueueWait(time.Now()))
+
+	// Hold a message read just before sending was paused
+	if err := p.service.WaitWhilePaused(ctx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		p.metrics.RecordChannelDuration(msg.Channel, duration)
+	}()
+
+	// Topic broadcasts have no user, so they are not stored as notifications
+	if msg.Topic != "" {
+		return p.processBroadcast(ctx, msg)
+	}
+
+	p.logger.Info("Processing notification",
+		zap.String("id", msg.ID),
+		zap.String("channel", msg.Channel),
+	)
+
+	// Get full notification details
+	notif, err := p.service.GetNotification(ctx, msg.ID)
+	if err != nil {
+		p.logger.Error("Failed to get notification details", zap.Error(err), zap.String("id", msg.ID))
+		return err
+	}
+
+	// Metadata and provider options are not stored with the notification, so
+	// take them from the queue message
+	if notif.Metadata == nil {
+		notif.Metadata = msg.Metadata
+	}
+	if notif.ProviderOptions == nil {
+		notif.ProviderOptions = msg.ProviderOptions
+	}
+
+	// Skip duplicate messages for a notification that is already sent or being sent
+	if !p.service.ClaimDelivery(ctx, notif) {
+		p.logger.Info("Skipping duplicate notification", zap.String("id", msg.ID), zap.String("status", string(notif.Status)))
+		return nil
+	}
+
+	// Skip notifications that expired while queued or scheduled. This runs
+	// after the claim so a replayed message for a notification that was
+	// already sent or cancelled is dropped as a duplicate instead.
+	if notif.IsExpired(time.Now()) {
+		return p.cancelExpired(ctx, msg.Channel, msg.ID)
+	}
+
+	// Send to each of the user's devices when no token was given
+	var report *notification.DeliveryReport
+	if notif.Recipient == notification.RecipientAllDevices {
+		report, err = p.sendToDevices(ctx, *notif)
+	} else {
+		sendStart := time.Now()
+		report, err = p.channel.SendNotification(ctx, *notif)
+		p.mirror(ctx, *notif, report, err, time.Since(sendStart))
+	}
+	report = acceptedOnly(report)
+	if err == nil && report.Status == notification.StatusFailed && report.ErrorMessage != "" {
+		err = fmt.Errorf("%s", report.ErrorMessage)
+	}
+	if err != nil {
+		p.logger.Error("Failed to send notification", zap.Error(err), zap.String("id", msg.ID), zap.String("channel", msg.Channel))
+		errorType := "send_error"
+		if report != nil {
+			errorType = report.ErrorType()
+		}
+		p.metrics.RecordProviderFailed(msg.Channel, p.providerName(report), errorType)
+
+		// Update notification status
+		p.service.UpdateNotificationStatus(ctx, msg.ID, notification.StatusFailed, "", err.Error())
+		p.service.ReleaseDelivery(ctx, msg.ID)
+		return err
+	}
+
+	// Update notification status based on report
+	if report.Status == notification.StatusSent {
+		p.metrics.RecordProviderSent(msg.Channel, p.providerName(report), "sent")
+		err = p.service.UpdateNotificationStatus(ctx, msg.ID, notification.StatusSent, report.ExternalID, "")
+	} else {
+		p.metrics.RecordProviderFailed(msg.Channel, p.providerName(report), report.ErrorType())
+		err = p.service.UpdateNotificationStatus(ctx, msg.ID, report.Status, report.ExternalID, report.ErrorMessage)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to update notification status", zap.Error(err), zap.String("id", msg.ID))
+		return err
+	}
+
+	p.logger.Info("Notification processed successfully", zap.String("id", msg.ID), zap.String("channel", msg.Channel), zap.String("provider", p.providerName(report)))
+	return nil
+}
+
+// cancelExpired cancels a claimed notification that expired before it was
+// sent, releasing the claim when the cancel fails so a redelivery can retry it
+func (p *processor) cancelExpired(ctx context.Context, channel, id string) error {
+	p.logger.Info("Skipping expired notification", zap.String("id", id))
+	if err := p.service.UpdateNotificationStatus(ctx, id, notification.StatusCancelled, "", "expired"); err != nil {
+		p.service.ReleaseDelivery(ctx, id)
+		return err
+	}
+	p.metrics.RecordNotificationBlocked(channel, monitoring.BlockedExpired)
+	return nil
+}
+
+// processBatch sends the notifications in msgs through channels.SendBatch,
+// one provider call per batch. Broadcasts and notifications fanned out to all
+// of a user's devices are processed one at a time. It returns one error per
+// message.
+func (p *processor) processBatch(ctx context.Context, msgs []queue.NotificationMessage) []error {
+	channelType := p.channel.GetChannelType()
+	errs := make([]error, len(msgs))
+
+	now := time.Now()
+	for _, msg := range msgs {
+		p.metrics.RecordQueueWait(channelType, msg.QueueWait(now))
+	}
+
+	// Hold the batch while sending is paused by an operator
+	if err := p.service.WaitWhilePaused(ctx); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	var batch []notification.Notification
+	var positions []int
+	for i, msg := range msgs {
+		if msg.Topic != "" || msg.Recipient == notification.RecipientAllDevices {
+			errs[i] = p.process(ctx, msg)
+			continue
+		}
+
+		notif, err := p.service.GetNotification(ctx, msg.ID)
+		if err != nil {
+			p.logger.Error("Failed to get notification details", zap.Error(err), zap.String("id", msg.ID))
+			errs[i] = err
+			continue
+		}
+		if notif.Metadata == nil {
+			notif.Metadata = msg.Metadata
+		}
+		if notif.ProviderOptions == nil {
+			notif.ProviderOptions = msg.ProviderOptions
+		}
+		if !p.service.ClaimDelivery(ctx, notif) {
+			p.logger.Info("Skipping duplicate notification", zap.String("id", msg.ID), zap.String("status", string(notif.Status)))
+			continue
+		}
+		if notif.IsExpired(time.Now()) {
+			errs[i] = p.cancelExpired(ctx, channelType, msg.ID)
+			continue
+		}
+
+		batch = append(batch, *notif)
+		positions = append(positions, i)
+	}
+	if len(batch) == 0 {
+		return errs
+	}
+
+	p.logger.Info("Processing notification batch", zap.String("channel", channelType), zap.Int("count", len(batch)))
+
+	start := time.Now()
+	reports, err := channels.SendBatch(ctx, p.channel, batch)
+	for _, report := range reports {
+		acceptedOnly(report)
+	}
+	for j, notif := range batch {
+		var report *notification.DeliveryReport
+		if err == nil {
+			report = reports[j]
+		}
+		p.mirror(ctx, notif, report, err, time.Since(start))
+	}
+	duration := time.Since(start).Seconds()
+	if err != nil {
+		p.logger.Error("Failed to send notification batch", zap.Error(err), zap.String("channel", channelType), zap.Int("count", len(batch)))
+	}
+
+	// Record the outcomes in one statement
+	updates := make([]notification.StatusUpdate, len(batch))
+	for j, notif := range batch {
+		i := positions[j]
+		p.metrics.RecordChannelDuration(channelType, duration)
+
+		if err != nil {
+			p.metrics.RecordProviderFailed(channelType, p.channel.GetProviderName(), "send_error")
+			updates[j] = notification.StatusUpdate{ID: notif.ID, Status: notification.StatusFailed, ErrorMessage: err.Error()}
+			errs[i] = err
+			continue
+		}
+
+		report := reports[j]
+		if report.Status != notification.StatusSent {
+			p.metrics.RecordProviderFailed(channelType, p.providerName(report), report.ErrorType())
+			updates[j] = notification.StatusUpdate{ID: notif.ID, Status: notification.StatusFailed, ExternalID: report.ExternalID, ErrorMessage: report.ErrorMessage}
+			errs[i] = fmt.Errorf("%s notification failed: %s", channelType, report.ErrorMessage)
+			continue
+		}
+
+		p.metrics.RecordProviderSent(channelType, p.providerName(report), "sent")
+		updates[j] = notification.StatusUpdate{ID: notif.ID, Status: notification.StatusSent, ExternalID: report.ExternalID}
+	}
+
+	updateErrs, updateErr := p.service.UpdateNotificationStatusBatch(ctx, updates)
+	if updateErr != nil {
+		p.logger.Error("Failed to update notification statuses", zap.Error(updateErr), zap.String("channel", channelType), zap.Int("count", len(updates)))
+	}
+	for j, update := range updates {
+		i := positions[j]
+		if update.Status == notification.StatusFailed {
+			p.service.ReleaseDelivery(ctx, update.ID)
+			continue
+		}
+
+		statusErr := updateErr
+		if statusErr == nil {
+			statusErr = updateErrs[j]
+		}
+		if statusErr != nil {
+			p.logger.Error("Failed to update notification status", zap.Error(statusErr), zap.String("id", update.ID))
+			errs[i] = statusErr
+		}
+	}
+
+	return errs
+}
+
+// providerName returns the provider that produced report: the one recorded in
+// it by a channel that chooses between providers, otherwise the channel's own
+func (p *processor) providerName(report *notification.DeliveryReport) string {
+	if report != nil && report.Provider != "" {
+		return report.Provider
+	}
+	return p.channel.GetProviderName()
+}
+
+// mirror shadow-sends notif through the candidate provider when it is sampled
+// and records how the candidate's outcome compares with the primary's. The
+// candidate's outcome never changes the notification's status.
+func (p *processor) mirror(ctx context.Context, notif notification.Notification, primary *notification.DeliveryReport, primaryErr error, primaryDuration time.Duration) {
+	if p.shadow == nil || !p.shadow.Sampled(notif.ID) {
+		return
+	}
+
+	channelType := p.channel.GetChannelType()
+	candidate := p.shadow.Candidate().GetProviderName()
+	primaryResult := sendResult(primary, primaryErr)
+	p.shadow.Mirror(ctx, notif, func(report *notification.DeliveryReport, err error, duration time.Duration) {
+		candidateResult := sendResult(report, err)
+		p.metrics.RecordShadowSend(channelType, p.providerName(primary), candidate, primaryResult, candidateResult, primaryDuration, duration)
+		if candidateResult != primaryResult {
+			p.logger.Info("Shadow send outcome differs from primary",
+				zap.String("id", notif.ID),
+				zap.String("channel", channelType),
+				zap.String("candidate", candidate),
+				zap.String("primary_result", primaryResult),
+				zap.String("candidate_result", candidateResult),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+// sendResult reduces the outcome of a send to "sent" or "failed"
+func sendResult(report *notification.DeliveryReport, err error) string {
+	if err != nil || report == nil || report.Status == notification.StatusFailed {
+		return "failed"
+	}
+	return "sent"
+}
+
+// processBroadcast sends a topic broadcast through a channel that supports
+// topics and records the outcome on the broadcast
+func (p *processor) processBroadcast(ctx context.Context, msg queue.NotificationMessage) error {
+	p.logger.Info("Processing broadcast", zap.String("id", msg.ID), zap.String("channel", msg.Channel), zap.String("topic", msg.Topic))
+
+	topicSender, ok := p.channel.(channels.TopicSender)
+	if !ok {
+		err := fmt.Errorf("%s channel does not support topic broadcasts", msg.Channel)
+		p.service.UpdateBroadcastStatus(ctx, msg.ID, notification.StatusFailed, "", err.Error())
+		return err
+	}
+
+	broadcast, err := p.service.GetBroadcast(ctx, msg.ID)
+	if err != nil {
+		p.logger.Error("Failed to get broadcast details", zap.Error(err), zap.String("id", msg.ID))
+		return err
+	}
+
+	notif := notification.Notification{
+		ID:              broadcast.ID,
+		Channel:         msg.Channel,
+		Subject:         broadcast.Subject,
+		Body:            broadcast.Body,
+		CollapseKey:     broadcast.CollapseKey,
+		Priority:        msg.Priority,
+		Metadata:        msg.Metadata,
+		ProviderOptions: msg.ProviderOptions,
+	}
+
+	report, err := topicSender.SendToTopic(ctx, broadcast.Topic, notif)
+	report = acceptedOnly(report)
+	if err != nil {
+		p.logger.Error("Failed to send broadcast", zap.Error(err), zap.String("id", msg.ID))
+		errorType := "send_error"
+		if report != nil {
+			errorType = report.ErrorType()
+		}
+		p.metrics.RecordProviderFailed(msg.Channel, p.providerName(report), errorType)
+
+		p.service.UpdateBroadcastStatus(ctx, msg.ID, notification.StatusFailed, "", err.Error())
+		return err
+	}
+
+	p.metrics.RecordProviderSent(msg.Channel, p.providerName(report), "sent")
+	if err := p.service.UpdateBroadcastStatus(ctx, msg.ID, notification.StatusSent, report.ExternalID, ""); err != nil {
+		p.logger.Error("Failed to update broadcast status", zap.Error(err), zap.String("id", msg.ID))
+		return err
+	}
+
+	p.logger.Info("Broadcast processed successfully", zap.String("id", msg.ID))
+	return nil
+}
+
+// sendToDevices sends the notification to every active token of the user.
+// Tokens the provider reports as unregistered are deactivated. The
+// notification counts as sent when it reached at least one device.
+func (p *processor) sendToDevices(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	// Devices belong to the notification's tenant
+	ctx = notification.WithTenant(ctx, notif.TenantID)
+	tokens, err := p.service.GetActivePushTokens(ctx, notif.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &notification.DeliveryReport{
+			NotificationID: notif.ID,
+			Status:         notification.StatusFailed,
+			ErrorMessage:   "user has no active devices",
+			ProviderCode:   "no_devices",
+		}, nil
+	}
+
+	var sent, failed *notification.DeliveryReport
+	var lastErr error
+	for _, token := range tokens {
+		device := notif
+		device.Recipient = token
+		// Each send adds its own keys to the metadata map
+		device.Metadata = make(map[string]string, len(notif.Metadata))
+		for k, v := range notif.Metadata {
+			device.Metadata[k] = v
+		}
+
+		report, err := p.channel.SendNotification(ctx, device)
+		report = acceptedOnly(report)
+		if err == nil && report.Status == notification.StatusSent {
+			if sent == nil {
+				sent = report
+			}
+			continue
+		}
+
+		failed, lastErr = report, err
+		if report != nil && report.ProviderCode == "UNREGISTERED" {
+			if err := p.service.DeactivatePushToken(ctx, token); err != nil {
+				p.logger.Error("Failed to deactivate push token", zap.Error(err), zap.String("user_id", notif.UserID))
+			}
+		}
+	}
+
+	p.logger.Info("Sent notification to user devices",
+		zap.String("id", notif.ID),
+		zap.String("user_id", notif.UserID),
+		zap.Int("devices", len(tokens)),
+		zap.Bool("delivered", sent != nil),
+	)
+
+	if sent != nil {
+		return sent, nil
+	}
+	return failed, lastErr
+}
+
+// acceptedOnly treats a report claiming delivery as accepted: a send can only
+// tell that the provider accepted the notification, and delivered is recorded
+// when the provider's webhook confirms it
+func acceptedOnly(report *notification.DeliveryReport) *notification.DeliveryReport {
+	if report != nil && report.Status == notification.StatusDelivered {
+		report.Status = notification.StatusSent
+		report.DeliveredAt = nil
+	}
+	return report
+}
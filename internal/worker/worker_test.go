@@ -0,0 +1,560 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/notification-system/internal/channels"
+	"github.com/alexnthnz/notification-system/internal/config"
+	"github.com/alexnthnz/notification-system/internal/database/dbtest"
+	"github.com/alexnthnz/notification-system/internal/database/redistest"
+	"github.com/alexnthnz/notification-system/internal/monitoring"
+	"github.com/alexnthnz/notification-system/internal/notification"
+	"github.com/alexnthnz/notification-system/internal/notification/notificationtest"
+	"github.com/alexnthnz/notification-system/internal/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// testMetrics is shared by all tests since metrics register globally
+var testMetrics = monitoring.NewMetrics()
+
+// fakeChannel accepts every notification and counts the sends
+type fakeChannel struct {
+	sends atomic.Int64
+}
+
+func (c *fakeChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	c.sends.Add(1)
+	return &notification.DeliveryReport{Status: notification.StatusSent, ExternalID: "ext-" + notif.ID}, nil
+}
+
+func (c *fakeChannel) GetChannelType() string {
+	return "sms"
+}
+
+func (c *fakeChannel) GetProviderName() string {
+	return "fake"
+}
+
+// counterValue returns the value of the registered counter name with labels,
+// 0 when it was never incremented
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// newTestProcessor returns a processor sending through channel, with a
+// service backed by a mock database and an in-memory Redis server
+func newTestProcessor(t *testing.T, channel channels.Channel) (*processor, *dbtest.Mock, *notification.Service) {
+	t.Helper()
+	db, mock := dbtest.New(t)
+	redis, _ := redistest.New(t)
+	service, err := notification.NewService(&config.Config{}, db, redis, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	p := &processor{channel: channel, registered: []string{"sms"}, service: service, metrics: testMetrics, logger: zap.NewNop()}
+	return p, mock, service
+}
+
+// testNotification returns a pending SMS notification
+func testNotification(id string) notification.Notification {
+	now := time.Now()
+	return notification.Notification{
+		ID: id, UserID: "user-1", Channel: "sms", Recipient: "+15550000002", Body: "hi",
+		Status: notification.StatusPending, CreatedAt: now, UpdatedAt: now,
+	}
+}
+
+// expectNotification expects notif to be loaded from the database
+func expectNotification(mock *dbtest.Mock, notif notification.Notification) {
+	mock.ExpectQuery("FROM notifications WHERE id = $1").WithArgs(notif.ID).WillReturnRows(notificationtest.Rows(notif))
+}
+
+// expectStatusUpdate expects notif to move to status and the change to be
+// recorded
+func expectStatusUpdate(mock *dbtest.Mock, notif notification.Notification, status notification.NotificationStatus) {
+	notif.Status = status
+	mock.ExpectQuery("UPDATE notifications").WillReturnRows(notificationtest.Rows(notif))
+	notificationtest.ExpectEvent(mock, notif.ID, status)
+}
+
+func TestProcessWaitsWhilePaused(t *testing.T) {
+	channel := &fakeChannel{}
+	p, mock, service := newTestProcessor(t, channel)
+	ctx := context.Background()
+	msg := queue.NotificationMessage{ID: "n1", Channel: "sms"}
+
+	if err := service.SetPaused(ctx, true); err != nil {
+		t.Fatalf("SetPaused(true) error = %v", err)
+	}
+
+	// Paused messages are held until the consumer stops, without a send
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := p.process(waitCtx, msg); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("process() while paused = %v, want context.DeadlineExceeded", err)
+	}
+	if sends := channel.sends.Load(); sends != 0 {
+		t.Fatalf("sends while paused = %d, want 0", sends)
+	}
+
+	if err := service.SetPaused(ctx, false); err != nil {
+		t.Fatalf("SetPaused(false) error = %v", err)
+	}
+
+	// Once resumed the message is sent and marked sent
+	notif := testNotification("n1")
+	expectNotification(mock, notif)
+	expectStatusUpdate(mock, notif, notification.StatusSent)
+
+	if err := p.process(ctx, msg); err != nil {
+		t.Fatalf("process() after resume = %v", err)
+	}
+	if sends := channel.sends.Load(); sends != 1 {
+		t.Errorf("sends after resume = %d, want 1", sends)
+	}
+}
+
+func TestProcessSkipsExpiredNotifications(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		expiresAt  *time.Time
+		wantStatus notification.NotificationStatus
+		wantSends  int64
+	}{
+		{"no expiry", nil, notification.StatusSent, 1},
+		{"not expired", &future, notification.StatusSent, 1},
+		{"expired", &past, notification.StatusCancelled, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := &fakeChannel{}
+			p, mock, _ := newTestProcessor(t, channel)
+
+			notif := testNotification("n1")
+			notif.ExpiresAt = tt.expiresAt
+			expectNotification(mock, notif)
+			if tt.wantStatus == notification.StatusCancelled {
+				cancelled := notif
+				cancelled.Status = notification.StatusCancelled
+				mock.ExpectQuery("UPDATE notifications").WithArgs(notification.StatusCancelled, "", "expired", dbtest.AnyArg(), "n1", dbtest.AnyArg()).
+					WillReturnRows(notificationtest.Rows(cancelled))
+				notificationtest.ExpectEvent(mock, "n1", notification.StatusCancelled)
+			} else {
+				expectStatusUpdate(mock, notif, tt.wantStatus)
+			}
+
+			blocked := map[string]string{"channel": "sms", "reason": monitoring.BlockedExpired}
+			before := counterValue(t, "notifications_blocked_total", blocked)
+			failed := map[string]string{"channel": "sms", "error_type": "expired"}
+			failedBefore := counterValue(t, "notifications_failed_total", failed)
+
+			if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+				t.Fatalf("process() error = %v", err)
+			}
+			if sends := channel.sends.Load(); sends != tt.wantSends {
+				t.Errorf("sends = %d, want %d", sends, tt.wantSends)
+			}
+			if got, want := counterValue(t, "notifications_blocked_total", blocked)-before, float64(1-tt.wantSends); got != want {
+				t.Errorf("notifications_blocked_total{reason=expired} increased by %v, want %v", got, want)
+			}
+			// Skipped on purpose, so not counted as a failure
+			if got := counterValue(t, "notifications_failed_total", failed) - failedBefore; got != 0 {
+				t.Errorf("notifications_failed_total{error_type=expired} increased by %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestProcessIgnoresRedeliveredExpiredNotifications(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	for _, status := range []notification.NotificationStatus{notification.StatusSent, notification.StatusCancelled} {
+		t.Run(string(status), func(t *testing.T) {
+			channel := &fakeChannel{}
+			p, mock, _ := newTestProcessor(t, channel)
+
+			// Nothing is updated: any further statement fails the test
+			notif := testNotification("n1")
+			notif.ExpiresAt = &past
+			notif.Status = status
+			expectNotification(mock, notif)
+			expectNotification(mock, notif)
+
+			blocked := map[string]string{"channel": "sms", "reason": monitoring.BlockedExpired}
+			before := counterValue(t, "notifications_blocked_total", blocked)
+			msg := queue.NotificationMessage{ID: "n1", Channel: "sms"}
+
+			if err := p.process(context.Background(), msg); err != nil {
+				t.Errorf("process() error = %v", err)
+			}
+			if errs := p.processBatch(context.Background(), []queue.NotificationMessage{msg}); errs[0] != nil {
+				t.Errorf("processBatch() error = %v", errs[0])
+			}
+			if sends := channel.sends.Load(); sends != 0 {
+				t.Errorf("sends = %d, want 0", sends)
+			}
+			if got := counterValue(t, "notifications_blocked_total", blocked) - before; got != 0 {
+				t.Errorf("notifications_blocked_total{reason=expired} increased by %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestProcessLabelsSendMetricsByProvider(t *testing.T) {
+	sendGrid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Message-Id", "sg-1")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer sendGrid.Close()
+	twilio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+	}))
+	defer twilio.Close()
+
+	sent := map[string]string{"channel": "email", "provider": "sendgrid", "status": "sent"}
+	failed := map[string]string{"channel": "sms", "provider": "twilio", "error_type": "21211"}
+	sentBefore := counterValue(t, "notifications_sent_total", sent)
+	failedBefore := counterValue(t, "notifications_failed_total", failed)
+
+	// A SendGrid success is counted as sent by sendgrid
+	email := channels.NewEmailChannel(config.SendGridConfig{APIKey: "SG.test", BaseURL: sendGrid.URL}, zap.NewNop())
+	p, mock, _ := newTestProcessor(t, email)
+	notif := testNotification("n1")
+	notif.Channel, notif.Recipient, notif.Subject = "email", "a@example.com", "Hi"
+	expectNotification(mock, notif)
+	expectStatusUpdate(mock, notif, notification.StatusSent)
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "email"}); err != nil {
+		t.Fatalf("process(email) error = %v", err)
+	}
+
+	// A permanent Twilio failure is counted with its Twilio error code
+	sms, err := channels.NewSMSChannel(config.TwilioConfig{AccountSID: "AC123", BaseURL: twilio.URL, FromNumbers: []string{"+15550000001"}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSMSChannel() error = %v", err)
+	}
+	p, mock, _ = newTestProcessor(t, sms)
+	notif = testNotification("n2")
+	expectNotification(mock, notif)
+	expectStatusUpdate(mock, notif, notification.StatusFailed)
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n2", Channel: "sms"}); err == nil {
+		t.Fatal("process(sms) succeeded, want the Twilio error")
+	}
+
+	if got := counterValue(t, "notifications_sent_total", sent) - sentBefore; got != 1 {
+		t.Errorf("notifications_sent_total%v increased by %v, want 1", sent, got)
+	}
+	if got := counterValue(t, "notifications_failed_total", failed) - failedBefore; got != 1 {
+		t.Errorf("notifications_failed_total%v increased by %v, want 1", failed, got)
+	}
+}
+
+// scriptedChannel answers every send with report and err
+type scriptedChannel struct {
+	report *notification.DeliveryReport
+	err    error
+}
+
+func (c *scriptedChannel) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	return c.report, c.err
+}
+
+func (c *scriptedChannel) GetChannelType() string  { return "sms" }
+func (c *scriptedChannel) GetProviderName() string { return "fake" }
+
+func TestProcessUpdatesStatusFromSendResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		channel     *scriptedChannel
+		wantStatus  notification.NotificationStatus
+		wantExtID   string
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:       "sent",
+			channel:    &scriptedChannel{report: &notification.DeliveryReport{Status: notification.StatusSent, ExternalID: "SM1"}},
+			wantStatus: notification.StatusSent, wantExtID: "SM1",
+		},
+		{
+			name:       "send error",
+			channel:    &scriptedChannel{err: errors.New("provider unavailable")},
+			wantStatus: notification.StatusFailed, wantMessage: "provider unavailable", wantErr: true,
+		},
+		{
+			name:       "failed report",
+			channel:    &scriptedChannel{report: &notification.DeliveryReport{Status: notification.StatusFailed, ErrorMessage: "invalid number"}},
+			wantStatus: notification.StatusFailed, wantMessage: "invalid number", wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, mock, service := newTestProcessor(t, tt.channel)
+			notif := testNotification("n1")
+			expectNotification(mock, notif)
+
+			updated := notif
+			updated.Status = tt.wantStatus
+			args := []any{tt.wantStatus, tt.wantExtID, tt.wantMessage, dbtest.AnyArg()}
+			if tt.wantStatus == notification.StatusSent {
+				args = append(args, dbtest.AnyArg()) // sent_at
+			}
+			args = append(args, "n1", dbtest.AnyArg())
+			mock.ExpectQuery("UPDATE notifications").WithArgs(args...).WillReturnRows(notificationtest.Rows(updated))
+			notificationtest.ExpectEvent(mock, "n1", tt.wantStatus)
+
+			err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("process() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			// A failed send releases its delivery claim so a requeue can send it,
+			// a successful one keeps it so a redelivered message is skipped
+			if claimed := service.ClaimDelivery(context.Background(), &notif); claimed != tt.wantErr {
+				t.Errorf("ClaimDelivery() after process = %v, want %v", claimed, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessStatusUpdateFailure(t *testing.T) {
+	p, mock, _ := newTestProcessor(t, &fakeChannel{})
+	notif := testNotification("n1")
+	expectNotification(mock, notif)
+	mock.ExpectQuery("UPDATE notifications").WillReturnError(errors.New("connection reset"))
+
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err == nil {
+		t.Fatal("process() succeeded, want the status update error so the message is retried")
+	}
+}
+
+func TestProcessSkipsAlreadySentNotifications(t *testing.T) {
+	channel := &fakeChannel{}
+	p, mock, _ := newTestProcessor(t, channel)
+	notif := testNotification("n1")
+	notif.Status = notification.StatusSent
+	expectNotification(mock, notif)
+
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+	if sends := channel.sends.Load(); sends != 0 {
+		t.Errorf("sends = %d, want 0 for an already sent notification", sends)
+	}
+}
+
+func TestProcessNeverMarksDelivered(t *testing.T) {
+	deliveredAt := time.Now()
+	channel := &scriptedChannel{report: &notification.DeliveryReport{Status: notification.StatusDelivered, ExternalID: "SM1", DeliveredAt: &deliveredAt}}
+	p, mock, _ := newTestProcessor(t, channel)
+	notif := testNotification("n1")
+	expectNotification(mock, notif)
+
+	// Delivery is only recorded from provider webhooks, so the send marks it sent
+	sent := notif
+	sent.Status = notification.StatusSent
+	mock.ExpectQuery("UPDATE notifications").WithArgs(notification.StatusSent, "SM1", "", dbtest.AnyArg(), dbtest.AnyArg(), "n1", dbtest.AnyArg()).
+		WillReturnRows(notificationtest.Rows(sent))
+	notificationtest.ExpectEvent(mock, "n1", notification.StatusSent)
+
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+}
+
+func TestAcceptedOnly(t *testing.T) {
+	deliveredAt := time.Now()
+	report := acceptedOnly(&notification.DeliveryReport{Status: notification.StatusDelivered, DeliveredAt: &deliveredAt})
+	if report.Status != notification.StatusSent || report.DeliveredAt != nil {
+		t.Errorf("acceptedOnly(delivered) = %+v, want sent without DeliveredAt", report)
+	}
+
+	failed := &notification.DeliveryReport{Status: notification.StatusFailed, ErrorMessage: "invalid number"}
+	if report := acceptedOnly(failed); report.Status != notification.StatusFailed {
+		t.Errorf("acceptedOnly(failed) = %+v, want it unchanged", report)
+	}
+	if report := acceptedOnly(nil); report != nil {
+		t.Errorf("acceptedOnly(nil) = %+v, want nil", report)
+	}
+}
+
+// histogramSamples returns the sample count and sum of the registered
+// histogram name with labels
+func histogramSamples(t *testing.T, name string, labels map[string]string) (uint64, float64) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestProcessRecordsQueueWait(t *testing.T) {
+	labels := map[string]string{"channel": "sms"}
+	channel := &fakeChannel{}
+	p, mock, _ := newTestProcessor(t, channel)
+	notif := testNotification("n1")
+	notif.Status = notification.StatusSent
+	expectNotification(mock, notif)
+
+	countBefore, sumBefore := histogramSamples(t, "queue_wait_duration_seconds", labels)
+	msg := queue.NotificationMessage{ID: "n1", Channel: "sms", ProducedAt: time.Now().Add(-90 * time.Second)}
+	if err := p.process(context.Background(), msg); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	count, sum := histogramSamples(t, "queue_wait_duration_seconds", labels)
+	if count != countBefore+1 {
+		t.Fatalf("observed %d waits, want 1", count-countBefore)
+	}
+	if wait := sum - sumBefore; wait < 90 || wait > 91 {
+		t.Errorf("observed wait = %vs, want about 90s", wait)
+	}
+}
+
+func TestProcessBatchUpdatesStatusesTogether(t *testing.T) {
+	channel := &fakeChannel{}
+	p, mock, _ := newTestProcessor(t, channel)
+	first := testNotification("8f14e45f-ceea-467f-a0e6-8d7b6c2c9b01")
+	second := testNotification("8f14e45f-ceea-467f-a0e6-8d7b6c2c9b02")
+	expectNotification(mock, first)
+	expectNotification(mock, second)
+
+	// Both outcomes are recorded by one statement
+	first.Status, second.Status = notification.StatusSent, notification.StatusSent
+	mock.ExpectQuery("UPDATE notifications SET status = u.new_status").WillReturnRows(notificationtest.BatchRows([]int64{0, 1}, first, second))
+	notificationtest.ExpectEvent(mock, first.ID, notification.StatusSent)
+	notificationtest.ExpectEvent(mock, second.ID, notification.StatusSent)
+
+	errs := p.processBatch(context.Background(), []queue.NotificationMessage{{ID: first.ID, Channel: "sms"}, {ID: second.ID, Channel: "sms"}})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("processBatch() error %d = %v", i, err)
+		}
+	}
+	if sends := channel.sends.Load(); sends != 2 {
+		t.Errorf("sends = %d, want 2", sends)
+	}
+}
+
+func TestProcessMirrorsToShadow(t *testing.T) {
+	tests := []struct {
+		name         string
+		percent      float64
+		wantMirrored float64
+	}{
+		{"sampled", 100, 1},
+		{"not sampled", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The candidate fails, but only the primary's outcome is recorded
+			candidate := &scriptedChannel{err: errors.New("candidate unavailable")}
+			p, mock, _ := newTestProcessor(t, &fakeChannel{})
+			p.shadow = channels.NewShadow(candidate, tt.percent, zap.NewNop())
+			labels := map[string]string{"channel": "sms", "candidate": "fake", "primary_result": "sent", "candidate_result": "failed"}
+			before := counterValue(t, "shadow_sends_total", labels)
+
+			notif := testNotification("n1")
+			expectNotification(mock, notif)
+			expectStatusUpdate(mock, notif, notification.StatusSent)
+
+			if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+				t.Fatalf("process() error = %v", err)
+			}
+			p.shadow.Wait()
+			if got := counterValue(t, "shadow_sends_total", labels) - before; got != tt.wantMirrored {
+				t.Errorf("shadow_sends_total increased by %v, want %v", got, tt.wantMirrored)
+			}
+		})
+	}
+}
+
+// namedSMSProvider is an SMS provider named name that sends every notification
+type namedSMSProvider struct {
+	name string
+}
+
+func (c *namedSMSProvider) SendNotification(ctx context.Context, notif notification.Notification) (*notification.DeliveryReport, error) {
+	return &notification.DeliveryReport{Status: notification.StatusSent, ExternalID: "ext-" + notif.ID}, nil
+}
+
+func (c *namedSMSProvider) GetChannelType() string  { return "sms" }
+func (c *namedSMSProvider) GetProviderName() string { return c.name }
+
+func TestProcessRecordsWeightedProvider(t *testing.T) {
+	primary := &fakeChannel{}
+	weighted, err := channels.NewWeighted([]channels.Channel{primary, &namedSMSProvider{name: "vonage"}}, []int{0, 1})
+	if err != nil {
+		t.Fatalf("NewWeighted() error = %v", err)
+	}
+	p, mock, _ := newTestProcessor(t, weighted)
+	chosen := map[string]string{"channel": "sms", "provider": "vonage", "status": "sent"}
+	other := map[string]string{"channel": "sms", "provider": "weighted", "status": "sent"}
+	chosenBefore, otherBefore := counterValue(t, "notifications_sent_total", chosen), counterValue(t, "notifications_sent_total", other)
+
+	notif := testNotification("n1")
+	expectNotification(mock, notif)
+	expectStatusUpdate(mock, notif, notification.StatusSent)
+	if err := p.process(context.Background(), queue.NotificationMessage{ID: "n1", Channel: "sms"}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	// The send is counted for the provider the weighted channel chose
+	if got := counterValue(t, "notifications_sent_total", chosen) - chosenBefore; got != 1 {
+		t.Errorf("notifications_sent_total%v increased by %v, want 1", chosen, got)
+	}
+	if got := counterValue(t, "notifications_sent_total", other) - otherBefore; got != 0 {
+		t.Errorf("notifications_sent_total%v increased by %v, want 0", other, got)
+	}
+	if primary.sends.Load() != 0 {
+		t.Errorf("provider weighted 0 sent %d notifications", primary.sends.Load())
+	}
+}
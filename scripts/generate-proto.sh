@@ -24,6 +24,12 @@ if ! command -v protoc-gen-go-grpc &> /dev/null; then
     go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
 fi
 
+# Check if protoc-gen-grpc-gateway is installed
+if ! command -v protoc-gen-grpc-gateway &> /dev/null; then
+    echo "Installing protoc-gen-grpc-gateway..."
+    go install github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-grpc-gateway@latest
+fi
+
 # Create output directory
 mkdir -p api/proto/gen
 
@@ -34,7 +40,10 @@ protoc \
     --go_opt=paths=source_relative \
     --go-grpc_out=api/proto/gen \
     --go-grpc_opt=paths=source_relative \
+    --grpc-gateway_out=api/proto/gen \
+    --grpc-gateway_opt=paths=source_relative \
     --proto_path=api/proto \
+    --proto_path=third_party/googleapis \
     api/proto/notification.proto
 
 echo "✅ Protocol buffer generation completed successfully!"